@@ -0,0 +1,10 @@
+// Command admin runs ops tasks against the server's database: seeding
+// chapters, creating staff accounts, resetting a user's progress,
+// rebuilding indexes and running migrations. See internal/app/admin_cli.go.
+package main
+
+import "resume-learning-backend/internal/app"
+
+func main() {
+	app.AdminMain()
+}
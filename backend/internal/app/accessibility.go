@@ -0,0 +1,196 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ACCESSIBILITY MODELS
+// ============================================================================
+
+// Accessibility describes the accessibility features of a chapter's media
+type Accessibility struct {
+	HasCaptions         bool     `bson:"has_captions" json:"hasCaptions"`
+	CaptionLanguages    []string `bson:"caption_languages" json:"captionLanguages"`
+	HasAudioDescription bool     `bson:"has_audio_description" json:"hasAudioDescription"`
+	ContentWarnings     []string `bson:"content_warnings" json:"contentWarnings"`
+}
+
+// AccessibilityPreferences are a learner's accessibility requirements
+type AccessibilityPreferences struct {
+	RequireCaptions         bool     `bson:"require_captions" json:"requireCaptions"`
+	RequireAudioDescription bool     `bson:"require_audio_description" json:"requireAudioDescription"`
+	AvoidContentWarnings    []string `bson:"avoid_content_warnings" json:"avoidContentWarnings"`
+	// FilterMode hides non-matching chapters when true; otherwise they are
+	// returned with accessibilityIssues flagged
+	FilterMode bool `bson:"filter_mode" json:"filterMode"`
+}
+
+// contentWarnings is the closed set of warnings a chapter can carry
+var contentWarnings = map[string]bool{
+	"flashing_lights": true,
+	"loud_audio":      true,
+	"violence":        true,
+	"strong_language": true,
+	"medical_imagery": true,
+}
+
+// languageTagPattern accepts simple BCP 47 tags like "en" or "pt-BR"
+var languageTagPattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// validateAccessibility checks metadata before a chapter is published
+func validateAccessibility(a Accessibility) error {
+	if a.HasCaptions && len(a.CaptionLanguages) == 0 {
+		return fmt.Errorf("captioned chapters must list at least one caption language")
+	}
+	if !a.HasCaptions && len(a.CaptionLanguages) > 0 {
+		return fmt.Errorf("caption languages given but captions are not available")
+	}
+	for _, lang := range a.CaptionLanguages {
+		if !languageTagPattern.MatchString(lang) {
+			return fmt.Errorf("invalid caption language %q", lang)
+		}
+	}
+	for _, warning := range a.ContentWarnings {
+		if !contentWarnings[warning] {
+			return fmt.Errorf("unknown content warning %q", warning)
+		}
+	}
+	return nil
+}
+
+// accessibilityIssues lists the ways a chapter fails a learner's preferences
+func accessibilityIssues(a Accessibility, prefs AccessibilityPreferences) []string {
+	var issues []string
+	if prefs.RequireCaptions && !a.HasCaptions {
+		issues = append(issues, "missing_captions")
+	}
+	if prefs.RequireAudioDescription && !a.HasAudioDescription {
+		issues = append(issues, "missing_audio_description")
+	}
+	for _, avoided := range prefs.AvoidContentWarnings {
+		for _, warning := range a.ContentWarnings {
+			if warning == avoided {
+				issues = append(issues, "content_warning:"+warning)
+			}
+		}
+	}
+	return issues
+}
+
+// applyAccessibilityPreferences flags or filters chapters for a learner
+func applyAccessibilityPreferences(chapters []Chapter, prefs AccessibilityPreferences) []Chapter {
+	result := make([]Chapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		chapter.AccessibilityIssues = accessibilityIssues(chapter.Accessibility, prefs)
+		if prefs.FilterMode && len(chapter.AccessibilityIssues) > 0 {
+			continue
+		}
+		result = append(result, chapter)
+	}
+	return result
+}
+
+// ============================================================================
+// ACCESSIBILITY HANDLERS
+// ============================================================================
+
+// UpdateAccessibilityPreferences stores a learner's accessibility requirements
+func UpdateAccessibilityPreferences(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	var prefs AccessibilityPreferences
+	if !decodeJSON(w, r, &prefs) {
+		return
+	}
+	if prefs.AvoidContentWarnings == nil {
+		prefs.AvoidContentWarnings = []string{}
+	}
+	for _, warning := range prefs.AvoidContentWarnings {
+		if !contentWarnings[warning] {
+			sendError(w, http.StatusBadRequest, "Unknown content warning: "+warning)
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	var user User
+	err := usersCol.FindOneAndUpdate(ctx, bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"accessibility": prefs, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update accessibility preferences")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Accessibility preferences updated successfully",
+		Data:    user.Accessibility,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateChapterAccessibility validates and publishes a chapter's accessibility metadata
+func UpdateChapterAccessibility(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var a Accessibility
+	if !decodeJSON(w, r, &a) {
+		return
+	}
+	if a.CaptionLanguages == nil {
+		a.CaptionLanguages = []string{}
+	}
+	if a.ContentWarnings == nil {
+		a.ContentWarnings = []string{}
+	}
+	for i, lang := range a.CaptionLanguages {
+		a.CaptionLanguages[i] = strings.TrimSpace(lang)
+	}
+
+	if err := validateAccessibility(a); err != nil {
+		sendError(w, http.StatusUnprocessableEntity, "Invalid accessibility metadata: "+err.Error())
+		return
+	}
+
+	ctx := r.Context()
+
+	var chapter Chapter
+	err := chaptersCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}),
+		bson.M{"$set": bson.M{"accessibility": a, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update chapter accessibility")
+		return
+	}
+
+	chapterWritten(ctx, chapter)
+	log.Printf("✅ Accessibility metadata updated: chapter=%s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter accessibility updated successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
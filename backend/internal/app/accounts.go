@@ -0,0 +1,592 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ============================================================================
+// ACCOUNT MODELS
+// ============================================================================
+
+// Besides the guest login by user ID, learners can register with an email
+// and a password. Their email is verified with a link sent by email, and a
+// forgotten password is reset the same way. A password account can't be
+// entered with its user ID alone, nor can one linked to Google or Apple
+// (see oauth.go).
+
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72 // bcrypt ignores anything longer
+
+	defaultEmailVerificationTTL = 48 * time.Hour
+	defaultPasswordResetTTL     = time.Hour
+
+	// accountEmailInterval is how long to wait before sending the same
+	// kind of email to a user again
+	accountEmailInterval = time.Minute
+)
+
+// Account token purposes
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// Error codes for password accounts and guests
+const (
+	ErrCodeInvalidCredentials  = "invalid_credentials"
+	ErrCodePasswordRequired    = "password_required"
+	ErrCodeEmailNotVerified    = "email_not_verified"
+	ErrCodeInvalidAccountToken = "invalid_account_token"
+	ErrCodeInvalidGuestSecret  = "invalid_guest_secret"
+)
+
+// AccountToken is a single-use token mailed to a user to verify their email
+// or reset their password. Only its hash is stored.
+type AccountToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	TokenHash string             `bson:"token_hash"`
+	UserID    string             `bson:"user_id"`
+	Purpose   string             `bson:"purpose"`
+	Email     string             `bson:"email"` // the address it was sent to
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+}
+
+type RegisterRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Name     string `json:"name"`
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+	DeviceID string `json:"deviceId"`
+}
+
+type PasswordLoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	DeviceID string `json:"deviceId"`
+}
+
+type AccountTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ============================================================================
+// ACCOUNT HANDLERS
+// ============================================================================
+
+// Register creates a password account, mails a link to verify its email
+// and logs it in, unless REQUIRE_EMAIL_VERIFICATION holds the login back
+// until the email is verified
+func Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	name := strings.TrimSpace(req.Name)
+	req.Timezone = strings.TrimSpace(req.Timezone)
+
+	var errs fieldErrors
+	if email == "" {
+		errs.add("email", CodeRequired, "is required")
+	} else if !isEmailAddress(email) {
+		errs.add("email", CodeInvalid, "must be an email address")
+	} else if inUse, err := emailInUse(ctx, email, ""); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if inUse {
+		errs.add("email", CodeTaken, "is already in use")
+	}
+	checkPassword(&errs, "password", req.Password)
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		errs.add("name", CodeOutOfRange, "must be at most 80 characters")
+	}
+	if _, ok := loadTimezone(req.Timezone); req.Timezone != "" && !ok {
+		errs.add("timezone", CodeInvalid, "must be an IANA timezone such as 'Europe/Berlin'")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	if name == "" {
+		name = email[:strings.Index(email, "@")]
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	now := time.Now()
+	login := newLoginRecord(r, req.DeviceID)
+	user := User{
+		PublicID:     newPublicID(),
+		UserID:       newPublicID(),
+		OrgID:        orgID(ctx),
+		Name:         name,
+		Email:        email,
+		Locale:       normalizeLocale(req.Locale),
+		Timezone:     req.Timezone,
+		Role:         RoleLearner,
+		Status:       UserActive,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		PasswordHash: string(hash),
+	}
+	verified := !requireEmailVerification()
+	if verified {
+		user.LastLoginAt = &login.At
+		user.RecentLogins = []LoginRecord{login}
+	}
+
+	if _, err := usersCol.InsertOne(ctx, user); mongo.IsDuplicateKeyError(err) {
+		// Another account took the email since it was checked
+		sendError(w, http.StatusConflict, "Email is already in use")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+
+	log.Printf("✅ Account registered: %s", user.UserID)
+	welcomeUser(ctx, user)
+	mailAccountToken(ctx, user, TokenVerifyEmail)
+
+	if !verified {
+		response := ApiResponse{
+			Success: true,
+			Message: "Account created, check your email to verify it",
+			Data:    user,
+		}
+		sendJSON(w, http.StatusCreated, response)
+		return
+	}
+	completeLogin(w, r, user, req.DeviceID, http.StatusCreated)
+}
+
+// PasswordLogin logs a password account in with its email and password
+func PasswordLogin(w http.ResponseWriter, r *http.Request) {
+	var req PasswordLoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	errs.required("email", req.Email)
+	if req.Password == "" {
+		errs.add("password", CodeRequired, "is required")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var user User
+	err := usersCol.FindOne(ctx, tenantFilter(ctx, bson.M{
+		"email":         email,
+		"password_hash": bson.M{"$exists": true},
+	})).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		// Take as long as a wrong password would, so the answer doesn't
+		// tell which emails have accounts
+		bcrypt.CompareHashAndPassword(decoyPasswordHash(), []byte(req.Password))
+		sendErrorCode(w, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid email or password")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		sendErrorCode(w, http.StatusUnauthorized, ErrCodeInvalidCredentials, "Invalid email or password")
+		return
+	}
+
+	if status := accountStatus(user); status != UserActive {
+		sendAccountBlocked(w, status)
+		return
+	}
+	if user.EmailVerifiedAt == nil && requireEmailVerification() {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeEmailNotVerified, "Verify your email address before logging in")
+		return
+	}
+
+	updated, err := recordLogin(ctx, user.UserID, newLoginRecord(r, req.DeviceID), bson.M{"updated_at": time.Now()})
+	if err != nil {
+		log.Printf("❌ Error recording login for %s: %v", user.UserID, err)
+	} else if updated != nil {
+		user = *updated
+		log.Printf("✅ User logged in with a password: %s", user.UserID)
+	}
+
+	completeLogin(w, r, user, req.DeviceID, http.StatusOK)
+}
+
+// RequestEmailVerification mails the user a new link to verify their email
+func RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var user User
+	err := usersCol.FindOne(ctx, bson.M{"user_id": authUserID(ctx)}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if user.Email == "" {
+		sendError(w, http.StatusConflict, "Add an email address to your profile first")
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		sendJSON(w, http.StatusOK, ApiResponse{Success: true, Message: "Email is already verified"})
+		return
+	}
+
+	mailAccountToken(ctx, user, TokenVerifyEmail)
+	sendJSON(w, http.StatusAccepted, ApiResponse{Success: true, Message: "Verification email sent"})
+}
+
+// ConfirmEmailVerification marks an email verified with the token from the
+// link mailed to it
+func ConfirmEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var req AccountTokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var errs fieldErrors
+	errs.required("token", req.Token)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+	token, ok := redeemAccountToken(ctx, w, req.Token, TokenVerifyEmail)
+	if !ok {
+		return
+	}
+
+	// The link only counts while the user still has the address it went to
+	now := time.Now()
+	result, err := usersCol.UpdateOne(ctx, bson.M{"user_id": token.UserID, "email": token.Email}, bson.M{"$set": bson.M{
+		"email_verified_at": now,
+		"updated_at":        now,
+	}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendErrorCode(w, http.StatusBadRequest, ErrCodeInvalidAccountToken, "This link is invalid or has expired")
+		return
+	}
+
+	log.Printf("✅ Email verified: user=%s", token.UserID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Email verified successfully",
+		Data: map[string]interface{}{
+			"email":           token.Email,
+			"emailVerifiedAt": now,
+		},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RequestPasswordReset mails a password reset link to the account with the
+// email. The answer is the same whether or not there is one.
+func RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	var errs fieldErrors
+	if email == "" {
+		errs.add("email", CodeRequired, "is required")
+	} else if !isEmailAddress(email) {
+		errs.add("email", CodeInvalid, "must be an email address")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+	var user User
+	err := usersCol.FindOne(ctx, tenantFilter(ctx, bson.M{
+		"email":         email,
+		"password_hash": bson.M{"$exists": true},
+	})).Decode(&user)
+	if err == nil && accountStatus(user) == UserActive {
+		mailAccountToken(ctx, user, TokenResetPassword)
+	} else if err != nil && err != mongo.ErrNoDocuments {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	sendJSON(w, http.StatusAccepted, ApiResponse{
+		Success: true,
+		Message: "If an account uses this email, a password reset link has been sent to it",
+	})
+}
+
+// ResetPassword sets a new password with the token from a reset link. Every
+// session of the account is revoked, and the email counts as verified.
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var errs fieldErrors
+	errs.required("token", req.Token)
+	checkPassword(&errs, "password", req.Password)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	ctx := r.Context()
+	token, ok := redeemAccountToken(ctx, w, req.Token, TokenResetPassword)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	filter := bson.M{"user_id": token.UserID, "email": token.Email, "password_hash": bson.M{"$exists": true}}
+	result, err := usersCol.UpdateOne(ctx, filter, bson.A{bson.M{"$set": bson.M{
+		"password_hash":     string(hash),
+		"email_verified_at": bson.M{"$ifNull": bson.A{"$email_verified_at", now}},
+		"updated_at":        now,
+	}}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendErrorCode(w, http.StatusBadRequest, ErrCodeInvalidAccountToken, "This link is invalid or has expired")
+		return
+	}
+
+	if err := sessionStore.RevokeForUser(ctx, token.UserID, now); err != nil {
+		log.Printf("❌ Error revoking sessions of user %s after a password reset: %v", token.UserID, err)
+	}
+
+	log.Printf("✅ Password reset: user=%s", token.UserID)
+
+	sendJSON(w, http.StatusOK, ApiResponse{Success: true, Message: "Password reset successfully, log in with the new one"})
+}
+
+// ============================================================================
+// ACCOUNT HELPERS
+// ============================================================================
+
+// accountSignIn returns how userID signs in: "password", the provider of a
+// linked identity, or "" for guests and unknown users
+func accountSignIn(ctx context.Context, userID string) (string, error) {
+	// Passwords and linked identities are set by routes that need MongoDB,
+	// so without it every account is a guest
+	if !mongoConnected() {
+		return "", nil
+	}
+
+	var user User
+	err := usersCol.FindOne(ctx, bson.M{"user_id": userID},
+		options.FindOne().SetProjection(bson.M{"password_hash": 1, "identities": 1})).Decode(&user)
+	switch {
+	case err == mongo.ErrNoDocuments:
+		return "", nil
+	case err != nil:
+		return "", err
+	case user.PasswordHash != "":
+		return "password", nil
+	case len(user.Identities) > 0:
+		return user.Identities[0].Provider, nil
+	}
+	return "", nil
+}
+
+// newGuestSecret returns a random secret for a new guest and the hash that
+// is stored
+func newGuestSecret() (secret, hash string) {
+	return newRefreshToken()
+}
+
+// guestSecretMatches reports whether secret is the one user was given when
+// it was created. Users without one match nothing.
+func guestSecretMatches(user User, secret string) bool {
+	if user.GuestSecretHash == "" || secret == "" {
+		return false
+	}
+	hash := hashRefreshToken(strings.TrimSpace(secret))
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(user.GuestSecretHash)) == 1
+}
+
+// checkPassword adds a field error unless password is 8 to 72 bytes long
+func checkPassword(errs *fieldErrors, field, password string) {
+	switch {
+	case password == "":
+		errs.add(field, CodeRequired, "is required")
+	case utf8.RuneCountInString(password) < minPasswordLength:
+		errs.add(field, CodeOutOfRange, "must be at least 8 characters")
+	case len(password) > maxPasswordLength:
+		errs.add(field, CodeOutOfRange, "must be at most 72 bytes")
+	}
+}
+
+// requireEmailVerification reports whether password accounts must verify
+// their email before logging in
+func requireEmailVerification() bool {
+	return config.RequireEmailVerification
+}
+
+var (
+	decoyHashOnce sync.Once
+	decoyHash     []byte
+)
+
+// decoyPasswordHash is a hash of a random password, checked against when
+// there is no account so a miss costs as much as a wrong password
+func decoyPasswordHash() []byte {
+	decoyHashOnce.Do(func() {
+		password, _ := newRefreshToken()
+		decoyHash, _ = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	})
+	return decoyHash
+}
+
+// accountTokenTTL is how long a token for purpose lasts
+func accountTokenTTL(purpose string) time.Duration {
+	if purpose == TokenResetPassword {
+		return config.PasswordResetTTL
+	}
+	return config.EmailVerificationTTL
+}
+
+// mailAccountToken issues a token for purpose and mails its link to the
+// user. It replaces the user's earlier tokens for the purpose, and sends
+// nothing when one went out under a minute ago, so the endpoints can't be
+// used to flood an inbox. Failures are logged, not returned: the client
+// can ask again.
+func mailAccountToken(ctx context.Context, user User, purpose string) {
+	now := time.Now()
+	recent, err := accountTokensCol.CountDocuments(ctx, bson.M{
+		"user_id":    user.UserID,
+		"purpose":    purpose,
+		"created_at": bson.M{"$gt": now.Add(-accountEmailInterval)},
+	})
+	if err != nil {
+		log.Printf("❌ Error checking %s tokens of user %s: %v", purpose, user.UserID, err)
+		return
+	} else if recent > 0 {
+		return
+	}
+
+	if _, err := accountTokensCol.DeleteMany(ctx, bson.M{"user_id": user.UserID, "purpose": purpose}); err != nil {
+		log.Printf("❌ Error replacing %s tokens of user %s: %v", purpose, user.UserID, err)
+		return
+	}
+	secret, hash := newRefreshToken()
+	token := AccountToken{
+		TokenHash: hash,
+		UserID:    user.UserID,
+		Purpose:   purpose,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(accountTokenTTL(purpose)),
+	}
+	if _, err := accountTokensCol.InsertOne(ctx, token); err != nil {
+		log.Printf("❌ Error saving %s token of user %s: %v", purpose, user.UserID, err)
+		return
+	}
+
+	// Emails are written in the user's language
+	locale := user.Locale
+	var subject, body string
+	if purpose == TokenVerifyEmail {
+		subject = T(locale, "Verify your email address")
+		body = T(locale, "Open this link to verify your email address:") + "\n" +
+			accountLink("/verify-email", secret) + "\n\n" +
+			T(locale, "If you didn't create an account, ignore this email.")
+	} else {
+		subject = T(locale, "Reset your password")
+		body = T(locale, "Open this link to choose a new password:") + "\n" +
+			accountLink("/reset-password", secret) + "\n\n" +
+			T(locale, "If you didn't ask to reset your password, ignore this email.")
+	}
+
+	// Sending can be slow; the request doesn't wait for it
+	go func() {
+		if err := sendEmail(token.Email, subject, body); err != nil {
+			log.Printf("❌ Error sending %s email to user %s: %v", purpose, user.UserID, err)
+		}
+	}()
+}
+
+// accountLink is the page of the app at APP_BASE_URL that redeems a token.
+// Without APP_BASE_URL the email carries the bare token.
+func accountLink(path, token string) string {
+	base := strings.TrimSuffix(config.AppBaseURL, "/")
+	if base == "" {
+		return token
+	}
+	return base + path + "?token=" + url.QueryEscape(token)
+}
+
+// redeemAccountToken uses up a token for purpose, or sends a 400 and returns
+// false when it is unknown, already used or expired
+func redeemAccountToken(ctx context.Context, w http.ResponseWriter, secret, purpose string) (AccountToken, bool) {
+	var token AccountToken
+	err := accountTokensCol.FindOneAndDelete(ctx, bson.M{
+		"token_hash": hashRefreshToken(strings.TrimSpace(secret)),
+		"purpose":    purpose,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusBadRequest, ErrCodeInvalidAccountToken, "This link is invalid or has expired")
+		return token, false
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return token, false
+	}
+	return token, true
+}
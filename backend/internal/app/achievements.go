@@ -0,0 +1,312 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// ACHIEVEMENT MODELS
+// ============================================================================
+
+// Achievements are checked after every progress write and kept in
+// achievements once earned, so a learner never loses one: resetting
+// progress or breaking a streak doesn't take it back. The unique index on
+// (user_id, achievement) lets only one write unlock each, and that write
+// tells the user's live sockets and the admin event stream.
+
+// Achievement IDs
+const (
+	AchievementFirstChapter = "first_chapter"
+	AchievementWeekStreak   = "week_streak"
+	AchievementPerfectQuiz  = "perfect_quiz"
+	AchievementAllChapters  = "all_chapters"
+)
+
+// weekStreakDays is the streak that earns week_streak
+const weekStreakDays = 7
+
+// achievement is one entry of the catalog
+type achievement struct {
+	ID          string
+	Title       string
+	Description string
+	// earned checks whether the user has met the achievement's goal
+	earned func(f *achievementFacts) (bool, error)
+}
+
+// achievementCatalog lists every achievement, in the order they are shown
+var achievementCatalog = []achievement{
+	{
+		ID:          AchievementFirstChapter,
+		Title:       "First Steps",
+		Description: "Complete your first chapter",
+		earned: func(f *achievementFacts) (bool, error) {
+			progress, err := f.progress()
+			if err != nil {
+				return false, err
+			}
+			for _, p := range progress {
+				if p.ChapterCompleted {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	},
+	{
+		ID:          AchievementWeekStreak,
+		Title:       "On a Roll",
+		Description: "Learn 7 days in a row",
+		earned: func(f *achievementFacts) (bool, error) {
+			streak, err := f.streak()
+			if err != nil {
+				return false, err
+			}
+			return streak.LongestStreak >= weekStreakDays, nil
+		},
+	},
+	{
+		ID:          AchievementPerfectQuiz,
+		Title:       "Perfectionist",
+		Description: "Score 100% on a quiz",
+		earned: func(f *achievementFacts) (bool, error) {
+			progress, err := f.progress()
+			if err != nil {
+				return false, err
+			}
+			for _, p := range progress {
+				if p.QuizScore != nil && *p.QuizScore == 100 {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	},
+	{
+		ID:          AchievementAllChapters,
+		Title:       "Graduate",
+		Description: "Complete every chapter of your courses",
+		earned: func(f *achievementFacts) (bool, error) {
+			enrolled, err := enrolledChapterIDs(f.ctx, f.userID)
+			if err != nil || len(enrolled) == 0 {
+				return false, err
+			}
+			progress, err := f.progress()
+			if err != nil {
+				return false, err
+			}
+			completed := 0
+			for _, p := range progress {
+				if p.ChapterCompleted && enrolled[p.ChapterID] {
+					completed++
+				}
+			}
+			return completed == len(enrolled), nil
+		},
+	},
+}
+
+// AchievementRecord is an achievement a user has unlocked
+type AchievementRecord struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID      string             `bson:"user_id" json:"userId"`
+	Achievement string             `bson:"achievement" json:"achievement"`
+	UnlockedAt  time.Time          `bson:"unlocked_at" json:"unlockedAt"`
+}
+
+// Achievement is one achievement as a user sees it
+type Achievement struct {
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Unlocked    bool       `json:"unlocked"`
+	UnlockedAt  *time.Time `json:"unlockedAt,omitempty"`
+}
+
+// UserAchievements is every achievement with the user's progress on them
+type UserAchievements struct {
+	UserID       string        `json:"userId"`
+	Unlocked     int           `json:"unlocked"`
+	Total        int           `json:"total"`
+	Achievements []Achievement `json:"achievements"` // in catalog order
+}
+
+// ============================================================================
+// ACHIEVEMENT HANDLERS
+// ============================================================================
+
+// GetUserAchievements lists every achievement, with when the user unlocked
+// the ones they have
+func GetUserAchievements(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	if _, err := userStore.Get(ctx, userID); err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	unlocked, err := unlockedAchievements(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch achievements")
+		return
+	}
+
+	result := UserAchievements{
+		UserID:       userID,
+		Unlocked:     len(unlocked),
+		Total:        len(achievementCatalog),
+		Achievements: achievementViews(unlocked, responseLocale(w)),
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Achievements fetched successfully",
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// ACHIEVEMENT HELPERS
+// ============================================================================
+
+// achievementFacts loads what the goals are checked against, once, and only
+// when a goal still to be met needs it
+type achievementFacts struct {
+	ctx    context.Context
+	userID string
+
+	progressList []Progress
+	progressErr  error
+	progressDone bool
+
+	streakValue Streak
+	streakErr   error
+	streakDone  bool
+}
+
+// progress is every chapter's progress of the user
+func (f *achievementFacts) progress() ([]Progress, error) {
+	if !f.progressDone {
+		f.progressList, _, f.progressErr = progressStore.List(f.ctx, ProgressQuery{UserID: f.userID})
+		f.progressDone = true
+	}
+	return f.progressList, f.progressErr
+}
+
+// streak is the user's streak as of today
+func (f *achievementFacts) streak() (Streak, error) {
+	if !f.streakDone {
+		days, err := activeDays(f.ctx, f.userID)
+		if err == nil {
+			today := startOfDay(time.Now(), userLocation(f.ctx, f.userID))
+			f.streakValue = computeStreak(days, today)
+		}
+		f.streakErr, f.streakDone = err, true
+	}
+	return f.streakValue, f.streakErr
+}
+
+// view is the achievement as the user sees it, not yet unlocked
+func (a achievement) view(locale string) Achievement {
+	return Achievement{ID: a.ID, Title: T(locale, a.Title), Description: T(locale, a.Description)}
+}
+
+// achievementViews is the catalog as a user with the unlocked achievements
+// sees it
+func achievementViews(unlocked map[string]time.Time, locale string) []Achievement {
+	views := make([]Achievement, 0, len(achievementCatalog))
+	for _, a := range achievementCatalog {
+		view := a.view(locale)
+		if at, ok := unlocked[a.ID]; ok {
+			view.Unlocked, view.UnlockedAt = true, &at
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// unlockedAchievements maps the user's achievements to when they were
+// unlocked
+func unlockedAchievements(ctx context.Context, userID string) (map[string]time.Time, error) {
+	cursor, err := achievementsCol.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []AchievementRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	unlocked := make(map[string]time.Time, len(records))
+	for _, record := range records {
+		unlocked[record.Achievement] = record.UnlockedAt
+	}
+	return unlocked, nil
+}
+
+// evaluateAchievements unlocks the achievements whose goals the user has
+// met since the last check. Failures are logged; achievements never fail a
+// progress write. Without MongoDB there are none.
+func evaluateAchievements(ctx context.Context, userID string) {
+	if !mongoConnected() {
+		return
+	}
+	unlocked, err := unlockedAchievements(ctx, userID)
+	if err != nil {
+		log.Printf("❌ Error loading achievements for %s: %v", userID, err)
+		return
+	}
+	if len(unlocked) >= len(achievementCatalog) {
+		return
+	}
+
+	facts := &achievementFacts{ctx: ctx, userID: userID}
+	for _, a := range achievementCatalog {
+		if _, ok := unlocked[a.ID]; ok {
+			continue
+		}
+		earned, err := a.earned(facts)
+		if err != nil {
+			log.Printf("❌ Error checking achievement: user=%s, achievement=%s: %v", userID, a.ID, err)
+			continue
+		}
+		if earned {
+			unlockAchievement(ctx, userID, a)
+		}
+	}
+}
+
+// unlockAchievement records that the user earned a, and tells their
+// sockets and the admin event stream unless another write got there first
+func unlockAchievement(ctx context.Context, userID string, a achievement) {
+	record := AchievementRecord{UserID: userID, Achievement: a.ID, UnlockedAt: time.Now()}
+	if _, err := achievementsCol.InsertOne(ctx, record); mongo.IsDuplicateKeyError(err) {
+		return
+	} else if err != nil {
+		log.Printf("❌ Error unlocking achievement: user=%s, achievement=%s: %v", userID, a.ID, err)
+		return
+	}
+
+	log.Printf("🏆 Achievement unlocked: user=%s, achievement=%s", userID, a.ID)
+
+	view := a.view(userLocale(ctx, userID))
+	view.Unlocked, view.UnlockedAt = true, &record.UnlockedAt
+	liveProgress.publish(userID, LiveMessage{Type: LiveAchievement, Data: view})
+	publishAdminEvent(ctx, AdminEvent{Type: AdminEventAchievement, UserID: userID, Achievement: a.ID})
+}
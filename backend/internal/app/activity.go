@@ -0,0 +1,265 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ACTIVITY MODELS
+// ============================================================================
+
+// Activity types
+const (
+	ActivityVideoWatched     = "video_watched"
+	ActivityVideoCompleted   = "video_completed"
+	ActivityQuizPassed       = "quiz_passed"
+	ActivityQuizFailed       = "quiz_failed"
+	ActivityChapterCompleted = "chapter_completed"
+)
+
+var activityTypes = map[string]bool{
+	ActivityVideoWatched:     true,
+	ActivityVideoCompleted:   true,
+	ActivityQuizPassed:       true,
+	ActivityQuizFailed:       true,
+	ActivityChapterCompleted: true,
+}
+
+// ActivityEvent is one entry of a user's progress log. Video heartbeats are
+// batched: while the learner keeps watching the same chapter, each heartbeat
+// extends the latest video_watched event instead of adding a new one.
+type ActivityEvent struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID     string             `bson:"public_id,omitempty" json:"id"`
+	OrgID        string             `bson:"org_id,omitempty" json:"-"`
+	UserID       string             `bson:"user_id" json:"userId"`
+	Type         string             `bson:"type" json:"type"`
+	ChapterID    string             `bson:"chapter_id" json:"chapterId"`
+	ChapterTitle string             `bson:"-" json:"chapterTitle"`
+	FromSeconds  int                `bson:"from_seconds,omitempty" json:"fromSeconds,omitempty"` // video_watched
+	ToSeconds    int                `bson:"to_seconds,omitempty" json:"toSeconds,omitempty"`     // video_watched
+	Score        int                `bson:"score,omitempty" json:"score,omitempty"`              // quiz_*
+	Total        int                `bson:"total,omitempty" json:"total,omitempty"`              // quiz_*
+	StartedAt    time.Time          `bson:"started_at" json:"startedAt"`
+	OccurredAt   time.Time          `bson:"occurred_at" json:"occurredAt"` // last update of a batch
+	Summary      string             `bson:"-" json:"summary"`              // localized, never stored
+}
+
+// ============================================================================
+// ACTIVITY HANDLERS
+// ============================================================================
+
+// GetActivityFeed lists a user's activity, newest first, filtered by
+// ?type= (comma-separated) and paginated with ?limit= and ?cursor=
+func GetActivityFeed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	query := r.URL.Query()
+
+	var errs fieldErrors
+	filter := bson.M{"user_id": userID}
+
+	if v := query.Get("type"); v != "" {
+		types := []string{}
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !activityTypes[t] {
+				errs.add("type", CodeUnknownValue, "must be one of video_watched, video_completed, quiz_passed, quiz_failed, chapter_completed")
+				break
+			}
+			types = append(types, t)
+		}
+		filter["type"] = bson.M{"$in": types}
+	}
+
+	limit := parsePageLimit(r, &errs)
+
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := decodeTimeCursor(token)
+		if err != nil {
+			errs.add("cursor", CodeInvalid, "is not a valid cursor")
+		} else {
+			filter = bson.M{"$and": bson.A{filter, cursor.after("occurred_at")}}
+		}
+	}
+
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Fetch one extra to know whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+	cursor, err := activityCol.Find(ctx, tenantFilter(ctx, filter), opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch activity")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	events := []ActivityEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode activity")
+		return
+	}
+
+	page := Page{}
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[limit-1]
+		page.NextCursor = timeCursor{At: last.OccurredAt, ID: last.ID}.encode()
+	}
+
+	if err := describeActivity(ctx, events, responseLocale(w)); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch chapters")
+		return
+	}
+	page.Items = events
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Activity fetched successfully",
+		Data:    page,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// ACTIVITY HELPERS
+// ============================================================================
+
+// describeActivity fills in chapter titles and the localized summary line
+func describeActivity(ctx context.Context, events []ActivityEvent, locale string) error {
+	chapterIDs := []string{}
+	for _, e := range events {
+		chapterIDs = append(chapterIDs, e.ChapterID)
+	}
+
+	titles := map[string]string{}
+	if len(chapterIDs) > 0 {
+		cursor, err := chaptersCol.Find(ctx, bson.M{"chapter_id": bson.M{"$in": chapterIDs}},
+			options.Find().SetProjection(bson.M{"chapter_id": 1, "title": 1}))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		var chapters []Chapter
+		if err := cursor.All(ctx, &chapters); err != nil {
+			return err
+		}
+		for _, c := range chapters {
+			titles[c.ChapterID] = c.Title
+		}
+	}
+
+	for i := range events {
+		e := &events[i]
+		e.ChapterTitle = titles[e.ChapterID]
+		if e.ChapterTitle == "" {
+			e.ChapterTitle = e.ChapterID // chapter was deleted
+		}
+
+		percent := 0
+		if e.Total > 0 {
+			percent = e.Score * 100 / e.Total
+		}
+		switch e.Type {
+		case ActivityVideoWatched:
+			minutes := (e.ToSeconds - e.FromSeconds + 30) / 60
+			if minutes < 1 {
+				e.Summary = T(locale, "Watched %s", e.ChapterTitle)
+			} else {
+				e.Summary = T(locale, "Watched %d min of %s", minutes, e.ChapterTitle)
+			}
+		case ActivityVideoCompleted:
+			e.Summary = T(locale, "Finished the video of %s", e.ChapterTitle)
+		case ActivityQuizPassed:
+			e.Summary = T(locale, "Passed the quiz for %s with %d%%", e.ChapterTitle, percent)
+		case ActivityQuizFailed:
+			e.Summary = T(locale, "Scored %d%% on the quiz for %s", percent, e.ChapterTitle)
+		case ActivityChapterCompleted:
+			e.Summary = T(locale, "Completed %s", e.ChapterTitle)
+		}
+	}
+	return nil
+}
+
+// recordActivity appends an event to the user's activity log. Failures are
+// logged; the feed never fails a progress write. Without MongoDB there is
+// no feed, nor the certificates a completion would issue.
+func recordActivity(ctx context.Context, event ActivityEvent) {
+	if !mongoConnected() {
+		return
+	}
+	now := time.Now()
+	event.PublicID = newPublicID()
+	event.OrgID = orgID(ctx)
+	event.StartedAt = now
+	event.OccurredAt = now
+	if _, err := activityCol.InsertOne(ctx, event); err != nil {
+		log.Printf("❌ Error recording activity: user=%s, type=%s: %v", event.UserID, event.Type, err)
+	}
+	if event.Type == ActivityChapterCompleted {
+		publishAdminEvent(ctx, AdminEvent{Type: AdminEventChapterCompleted, UserID: event.UserID, ChapterID: event.ChapterID})
+		fireWebhook(ctx, orgID(ctx), WebhookChapterCompleted, map[string]interface{}{
+			"userId":      event.UserID,
+			"chapterId":   event.ChapterID,
+			"completedAt": now,
+		})
+		// The chapter may have been the last one of a course
+		issueCertificates(ctx, event.UserID, event.ChapterID)
+	}
+}
+
+// recordVideoWatched extends the user's latest video_watched event if it is
+// for the same chapter and recent enough to be the same sitting, and starts
+// a new one otherwise
+func recordVideoWatched(ctx context.Context, userID, chapterID string, from, to int) {
+	if to <= from || !mongoConnected() {
+		return // seeking backwards or replaying a heartbeat, or no feed
+	}
+
+	var latest ActivityEvent
+	err := activityCol.FindOne(ctx, tenantFilter(ctx, bson.M{"user_id": userID}),
+		options.FindOne().SetSort(bson.D{{Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}})).Decode(&latest)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Printf("❌ Error loading activity for %s: %v", userID, err)
+		return
+	}
+
+	if err == nil && latest.Type == ActivityVideoWatched && latest.ChapterID == chapterID &&
+		time.Since(latest.OccurredAt) < sessionIdleTimeout {
+		_, err := activityCol.UpdateOne(ctx, bson.M{"_id": latest.ID}, bson.M{
+			"$set": bson.M{"occurred_at": time.Now()},
+			"$max": bson.M{"to_seconds": to},
+			"$min": bson.M{"from_seconds": from},
+		})
+		if err != nil {
+			log.Printf("❌ Error updating activity for %s: %v", userID, err)
+		}
+		return
+	}
+
+	recordActivity(ctx, ActivityEvent{
+		UserID:      userID,
+		Type:        ActivityVideoWatched,
+		ChapterID:   chapterID,
+		FromSeconds: from,
+		ToSeconds:   to,
+	})
+}
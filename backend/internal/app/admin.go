@@ -0,0 +1,80 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================================================
+// ADMIN ACCESS
+// ============================================================================
+
+// requireAdmin guards admin routes. Platform admins use the shared
+// ADMIN_API_KEY; org admins use their organization's key and are scoped to
+// it by TenantMiddleware, as are users with the admin role, who use their
+// access token. Without ADMIN_API_KEY only org admins get in.
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t, _ := requestTenant(r.Context()); !t.admin {
+			sendRoleForbidden(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireSuperAdmin guards platform routes, which only ADMIN_API_KEY opens
+func requireSuperAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, _ := requestTenant(r.Context())
+		if !t.admin {
+			sendRoleForbidden(w, r)
+			return
+		}
+		if !t.superAdmin {
+			sendError(w, http.StatusForbidden, "Only platform admins can do this")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// adminKey returns the admin key of a request. EventSource can't set
+// headers, so streams also take it as the admin_key query parameter.
+func adminKey(r *http.Request) string {
+	if key := r.Header.Get("X-Admin-Key"); key != "" {
+		return key
+	}
+	if streamingRoutes[routeKey(r)] {
+		return r.URL.Query().Get("admin_key")
+	}
+	return ""
+}
+
+// isAdminRoute reports whether a request matched one of the /api/admin or
+// /api/instructor routes
+func isAdminRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	template, err := route.GetPathTemplate()
+	return err == nil && isAdminTemplate(template)
+}
+
+// isAdminTemplate reports whether a route template is under /api/admin or
+// /api/instructor, whose routes take an admin key as well as a token
+func isAdminTemplate(template string) bool {
+	return strings.HasPrefix(template, "/api/admin/") || strings.HasPrefix(template, "/api/instructor/")
+}
+
+// sendAdminUnauthorized rejects a request without a valid admin key
+func sendAdminUnauthorized(w http.ResponseWriter, r *http.Request) {
+	if config.AdminAPIKey == "" && adminKey(r) == "" {
+		sendError(w, http.StatusForbidden, "Admin API is disabled")
+		return
+	}
+	sendError(w, http.StatusUnauthorized, "Invalid admin key")
+}
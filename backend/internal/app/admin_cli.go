@@ -0,0 +1,403 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ============================================================================
+// ADMIN COMMAND
+// ============================================================================
+
+// The admin binary (cmd/admin) runs the ops tasks that would otherwise need
+// hand-written mongo shell commands. It is built from this package so it
+// goes through the same stores, validation and audit log as the API; its
+// audit entries have the "cli" actor.
+
+// adminCommands are the admin subcommands, each given its own arguments
+var adminCommands = map[string]struct {
+	usage    string
+	run      func(args []string) error
+	postgres bool // also runs on the Postgres backend without MongoDB
+}{
+	"seed":            {"seed [--dry-run] [--force] [--allow-production] [--seed-file path]", runSeedCommand, false},
+	"create-admin":    {"create-admin --email address [--name name] [--org orgId] [--role admin|instructor] < password", runCreateAdminCommand, false},
+	"reset-progress":  {"reset-progress --user userId", runResetProgressCommand, false},
+	"rebuild-indexes": {"rebuild-indexes", runRebuildIndexesCommand, false},
+	"migrate":         {"migrate [--status]", runMigrateCommand, true},
+}
+
+// AdminMain runs the admin command named by the arguments and exits when it
+// is done. The admin command is a call to it.
+func AdminMain() {
+	// The commands have flags of their own, so the configuration comes from
+	// the environment and .env only
+	if err := loadConfig(nil); err != nil {
+		log.Fatal(err)
+	}
+	if err := runAdminCommand(os.Args[1:]); err != nil {
+		log.Fatal("Admin command failed: ", err)
+	}
+}
+
+// runAdminCommand implements `admin <command> [flags]`
+func runAdminCommand(args []string) error {
+	if len(args) == 0 || adminCommands[args[0]].run == nil {
+		usage := []string{"usage: admin <command>"}
+		for _, command := range adminCommands {
+			usage = append(usage, "  "+command.usage)
+		}
+		sort.Strings(usage[1:])
+		return errors.New(strings.Join(usage, "\n"))
+	}
+	// The commands work on MongoDB's collections
+	command := adminCommands[args[0]]
+	if !backendNeedsMongo() && !(command.postgres && config.StorageBackend == StoragePostgres) {
+		return fmt.Errorf("%s needs MongoDB, which STORAGE_BACKEND=%s runs without", args[0], config.StorageBackend)
+	}
+	return command.run(args[1:])
+}
+
+// cliContext acts with the access of the platform admin key, optionally
+// within one organization, and is recorded in the audit log as the CLI
+func cliContext(org string) context.Context {
+	return context.WithValue(context.Background(), tenantContextKey{},
+		tenant{orgID: org, admin: true, superAdmin: true, cli: true})
+}
+
+// ============================================================================
+// ADMIN SUBCOMMANDS
+// ============================================================================
+
+// runCreateAdminCommand creates a password account with a staff role. The
+// password is read from the first line of stdin, so it stays out of shell
+// history and process listings.
+func runCreateAdminCommand(args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	email := fs.String("email", "", "the account's email, used to log in")
+	name := fs.String("name", "", "display name; the email's local part if empty")
+	org := fs.String("org", defaultOrgID, "organization the account belongs to")
+	role := fs.String("role", RoleAdmin, "admin or instructor")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return errors.New("pipe the password on stdin")
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	*email = strings.ToLower(strings.TrimSpace(*email))
+	*name = strings.TrimSpace(*name)
+
+	if err := InitDB(); err != nil {
+		return err
+	}
+	defer CloseDB()
+
+	ctx := cliContext(*org)
+
+	var errs fieldErrors
+	if *email == "" {
+		errs.add("email", CodeRequired, "is required")
+	} else if !isEmailAddress(*email) {
+		errs.add("email", CodeInvalid, "must be an email address")
+	} else if inUse, err := emailInUse(ctx, *email, ""); err != nil {
+		return err
+	} else if inUse {
+		errs.add("email", CodeTaken, "is already in use")
+	}
+	checkPassword(&errs, "password", password)
+	if *role != RoleAdmin && *role != RoleInstructor {
+		errs.add("role", CodeUnknownValue, "must be 'admin' or 'instructor'")
+	}
+	if *org != defaultOrgID {
+		if _, err := findOrganization(ctx, *org); err == mongo.ErrNoDocuments {
+			errs.add("org", CodeUnknownValue, "is not a known organization")
+		} else if err != nil {
+			return err
+		}
+	}
+	if err := cliErrors(errs); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		*name = (*email)[:strings.Index(*email, "@")]
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// The operator vouches for the address, so it needs no verification
+	now := time.Now()
+	user := User{
+		PublicID:        newPublicID(),
+		UserID:          newPublicID(),
+		OrgID:           *org,
+		Name:            *name,
+		Email:           *email,
+		Role:            *role,
+		Status:          UserActive,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		PasswordHash:    string(hash),
+		EmailVerifiedAt: &now,
+	}
+	if _, err := usersCol.InsertOne(ctx, user); mongo.IsDuplicateKeyError(err) {
+		return errors.New("email is already in use")
+	} else if err != nil {
+		return err
+	}
+	welcomeUser(ctx, user)
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditUserCreated,
+		Target:  AuditTarget{Type: "user", ID: user.UserID},
+		Changes: auditDiff(nil, bson.M{"email": user.Email, "role": user.Role}),
+	})
+
+	fmt.Printf("✅ Created %s %s (%s) in organization %s\n", user.Role, user.UserID, user.Email, user.OrgID)
+	return nil
+}
+
+// runResetProgressCommand deletes a user's progress, like the admin
+// endpoint
+func runResetProgressCommand(args []string) error {
+	fs := flag.NewFlagSet("reset-progress", flag.ContinueOnError)
+	userID := fs.String("user", "", "user ID or public ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *userID == "" {
+		return errors.New("--user is required")
+	}
+
+	if err := InitDB(); err != nil {
+		return err
+	}
+	defer CloseDB()
+
+	key := resolveUserKey(cliContext(""), *userID)
+	user, err := userStore.Get(cliContext(""), key)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("user %s not found", *userID)
+	} else if err != nil {
+		return err
+	}
+
+	deleted, err := resetUserProgress(cliContext(userOrgID(user)), user.UserID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Reset progress of %s: deleted %d records\n", user.UserID, deleted)
+	return nil
+}
+
+// runRebuildIndexesCommand creates missing indexes and replaces ones that
+// conflict with requiredIndexes, such as an index that should now be
+// unique. The server only creates indexes at startup, and logs conflicts.
+func runRebuildIndexesCommand(args []string) error {
+	fs := flag.NewFlagSet("rebuild-indexes", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := InitDB(); err != nil {
+		return err
+	}
+	defer CloseDB()
+
+	failed := 0
+	for _, idx := range requiredIndexes() {
+		action, err := rebuildIndex(context.Background(), idx)
+		keys, _ := idx.model.Keys.(bson.D)
+		signature := idx.col.Name() + " " + indexSignature(keys, false)
+		if err != nil {
+			fmt.Printf("! %s: %v\n", signature, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s %s\n", action, signature)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d indexes could not be built", failed)
+	}
+	return nil
+}
+
+// runMigrateCommand brings stored data up to date with the code: it runs
+// the backfills the server runs at startup (Postgres migrations, public
+// IDs, organizations, starter courses and their enrollments), then the
+// pending migrations, and reports the first failure instead of logging it.
+// --status lists the migrations without running any.
+func runMigrateCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	status := fs.Bool("status", false, "list applied and pending migrations without running any")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// InitDB applies Postgres migrations and backfills public IDs
+	if err := InitDB(); err != nil {
+		return err
+	}
+	defer CloseDB()
+
+	// The rest of the migrations are MongoDB's
+	if !mongoConnected() {
+		fmt.Println("✅ Postgres schema is up to date")
+		return nil
+	}
+
+	ctx := context.Background()
+	if *status {
+		return printMigrationStatus(ctx)
+	}
+	if err := ensureDefaultOrg(ctx); err != nil {
+		return fmt.Errorf("failed to create the default organization: %w", err)
+	}
+	backfillOrgIDs(ctx)
+	if err := ensureDefaultCourses(ctx); err != nil {
+		return fmt.Errorf("failed to create starter courses: %w", err)
+	}
+	if err := adoptOrphanChapters(ctx); err != nil {
+		return fmt.Errorf("failed to move chapters into the default course: %w", err)
+	}
+	if err := backfillDefaultEnrollments(ctx); err != nil {
+		return fmt.Errorf("failed to enroll existing users: %w", err)
+	}
+
+	applied, err := runMigrations(ctx)
+	for _, m := range applied {
+		fmt.Printf("+ %s: %s (%dms)\n", m.Version, m.Description, m.DurationMs)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("= no pending migrations")
+	}
+	fmt.Println("✅ Migrations complete")
+	return nil
+}
+
+// printMigrationStatus lists applied migrations with when they ran, then
+// pending ones
+func printMigrationStatus(ctx context.Context) error {
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	pending, err := pendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		fmt.Printf("= %s: applied %s\n", m.Version, m.AppliedAt.UTC().Format(time.RFC3339))
+	}
+	for _, m := range pending {
+		fmt.Printf("- %s: pending, %s\n", m.Version, m.Description)
+	}
+	return nil
+}
+
+// ============================================================================
+// ADMIN COMMAND HELPERS
+// ============================================================================
+
+// cliErrors prints field errors and sums them up as one error
+func cliErrors(errs fieldErrors) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, e := range errs {
+		fmt.Printf("! --%s %s\n", e.Field, e.Message)
+	}
+	return fmt.Errorf("%d invalid arguments", len(errs))
+}
+
+// rebuildIndex creates an index, first dropping an existing index on the
+// same keys if its options conflict. It returns "+" when the index was
+// created, "~" when it was replaced and "=" when it was already there.
+func rebuildIndex(ctx context.Context, idx collectionIndex) (string, error) {
+	keys, _ := idx.model.Keys.(bson.D)
+	signatures, err := indexSignatures(ctx, idx.col)
+	if err != nil {
+		return "", err
+	}
+	unique := idx.model.Options != nil && idx.model.Options.Unique != nil && *idx.model.Options.Unique
+	action := "+"
+	if signatures[indexSignature(keys, unique)] {
+		action = "="
+	}
+
+	_, err = idx.col.Indexes().CreateOne(ctx, idx.model)
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) || (cmdErr.Code != 85 && cmdErr.Code != 86) {
+		return action, err
+	}
+
+	// 85 and 86 are IndexOptionsConflict and IndexKeySpecsConflict
+	name, err := conflictingIndex(ctx, idx)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", cmdErr
+	}
+	if _, err := idx.col.Indexes().DropOne(ctx, name); err != nil {
+		return "", fmt.Errorf("failed to drop %s: %w", name, err)
+	}
+	if _, err := idx.col.Indexes().CreateOne(ctx, idx.model); err != nil {
+		return "", err
+	}
+	return "~", nil
+}
+
+// conflictingIndex names the existing index that stands in the way of a
+// required one: one on the same keys or, for text indexes, of which a
+// collection may only have one, any text index
+func conflictingIndex(ctx context.Context, idx collectionIndex) (string, error) {
+	keys, _ := idx.model.Keys.(bson.D)
+	cursor, err := idx.col.Indexes().List(ctx)
+	if err != nil {
+		return "", err
+	}
+	var specs []struct {
+		Name string `bson:"name"`
+		Key  bson.D `bson:"key"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return "", err
+	}
+	for _, spec := range specs {
+		if spec.Name == "_id_" {
+			continue
+		}
+		if indexSignature(spec.Key, false) == indexSignature(keys, false) {
+			return spec.Name, nil
+		}
+		if isTextIndex(keys) {
+			for _, k := range spec.Key {
+				if k.Key == "_fts" {
+					return spec.Name, nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// ADMIN EVENT STREAM
+// ============================================================================
+
+// GET /api/admin/events streams logins, chapter completions, quiz
+// submissions and unlocked achievements as Server-Sent Events for live
+// operations dashboards. Org admins see their organization's events;
+// platform admins see everyone's.
+// Like live progress, events are kept per server instance.
+
+// Kinds of admin event; they are the SSE event names
+const (
+	AdminEventLogin            = "login"
+	AdminEventChapterCompleted = "chapter_completed"
+	AdminEventQuizSubmitted    = "quiz_submitted"
+	AdminEventAchievement      = "achievement_unlocked"
+)
+
+const (
+	// adminEventBacklog is how many recent events a reconnecting dashboard
+	// can catch up on with Last-Event-ID
+	adminEventBacklog = 256
+	// adminEventBuffer is how far a dashboard may fall behind before it is
+	// disconnected
+	adminEventBuffer = 64
+	// adminEventKeepAlive spaces the comments that keep idle proxies from
+	// closing the stream
+	adminEventKeepAlive = 15 * time.Second
+)
+
+// AdminEvent is one entry of the stream
+type AdminEvent struct {
+	ID          int64     `json:"id"`
+	Type        string    `json:"type"`
+	OrgID       string    `json:"orgId"`
+	UserID      string    `json:"userId"`
+	ChapterID   string    `json:"chapterId,omitempty"`
+	Score       int       `json:"score,omitempty"`       // quiz_submitted
+	Total       int       `json:"total,omitempty"`       // quiz_submitted
+	Passed      *bool     `json:"passed,omitempty"`      // quiz_submitted
+	Achievement string    `json:"achievement,omitempty"` // achievement_unlocked
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+// adminEventHub fans events out to the open streams and keeps a short
+// backlog for reconnects
+type adminEventHub struct {
+	mu      sync.Mutex
+	nextID  int64
+	backlog []AdminEvent
+	streams map[chan AdminEvent]string // to the org they watch, "" for all
+}
+
+var adminEvents = &adminEventHub{streams: map[chan AdminEvent]string{}}
+
+// publishAdminEvent stamps an event with the request's organization, unless
+// it names one, and sends it to the dashboards watching
+func publishAdminEvent(ctx context.Context, event AdminEvent) {
+	if event.OrgID == "" {
+		event.OrgID = orgID(ctx)
+	}
+	event.OccurredAt = time.Now()
+	adminEvents.publish(event)
+}
+
+func (h *adminEventHub) publish(event AdminEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	event.ID = h.nextID
+	h.backlog = append(h.backlog, event)
+	if len(h.backlog) > adminEventBacklog {
+		h.backlog = h.backlog[len(h.backlog)-adminEventBacklog:]
+	}
+
+	for stream, org := range h.streams {
+		if org != "" && org != event.OrgID {
+			continue
+		}
+		select {
+		case stream <- event:
+		default:
+			// A dashboard that can't keep up reconnects and catches up
+			// from the backlog
+			delete(h.streams, stream)
+			close(stream)
+		}
+	}
+}
+
+// subscribe opens a stream of org's events ("" for all) and returns the
+// backlog after lastID to send first
+func (h *adminEventHub) subscribe(org string, lastID int64) (chan AdminEvent, []AdminEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	missed := []AdminEvent{}
+	if lastID > 0 {
+		for _, event := range h.backlog {
+			if event.ID > lastID && (org == "" || event.OrgID == org) {
+				missed = append(missed, event)
+			}
+		}
+	}
+	stream := make(chan AdminEvent, adminEventBuffer)
+	h.streams[stream] = org
+	return stream, missed
+}
+
+func (h *adminEventHub) unsubscribe(stream chan AdminEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.streams[stream]; ok {
+		delete(h.streams, stream)
+		close(stream)
+	}
+}
+
+// closeAll ends every stream, so shutdown doesn't wait on them
+func (h *adminEventHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for stream := range h.streams {
+		delete(h.streams, stream)
+		close(stream)
+	}
+}
+
+// StreamAdminEvents sends admin events as Server-Sent Events until the
+// client goes away
+func StreamAdminEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	t, _ := requestTenant(ctx)
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout is for ordinary responses
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		sendError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	var lastID int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		lastID, _ = strconv.ParseInt(v, 10, 64)
+	}
+	stream, missed := adminEvents.subscribe(t.orgID, lastID)
+	defer adminEvents.unsubscribe(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would hold events back
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", time.Second.Milliseconds())
+	for _, event := range missed {
+		writeAdminEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(adminEventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			writeAdminEvent(w, event)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-ctx.Done():
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeAdminEvent writes one event in text/event-stream format
+func writeAdminEvent(w http.ResponseWriter, event AdminEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("❌ Error encoding admin event: %v", err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,505 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ANALYTICS MODELS
+// ============================================================================
+
+// sessionIdleTimeout ends a session after this long without activity
+const sessionIdleTimeout = 30 * time.Minute
+
+// retentionWeeks is how many weekly signup cohorts a rollup covers
+const retentionWeeks = 8
+
+// rollupDateLayout is the format of AnalyticsRollup.Date
+const rollupDateLayout = "2006-01-02"
+
+// Platforms
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+	PlatformMacOS   = "macos"
+	PlatformWindows = "windows"
+	PlatformLinux   = "linux"
+	PlatformOther   = "other"
+)
+
+var knownPlatforms = map[string]bool{
+	PlatformAndroid: true, PlatformIOS: true, PlatformWeb: true,
+	PlatformMacOS: true, PlatformWindows: true, PlatformLinux: true,
+}
+
+// Session is a stretch of activity from one device. Logins and progress
+// writes extend the current session; a gap longer than sessionIdleTimeout
+// starts a new one.
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID   string             `bson:"public_id,omitempty" json:"id"`
+	OrgID      string             `bson:"org_id,omitempty" json:"-"`
+	UserID     string             `bson:"user_id" json:"userId"`
+	Device     string             `bson:"device" json:"device"`
+	Platform   string             `bson:"platform" json:"platform"`
+	StartedAt  time.Time          `bson:"started_at" json:"startedAt"`
+	LastSeenAt time.Time          `bson:"last_seen_at" json:"lastSeenAt"`
+}
+
+// durationBuckets are the lower bounds, in seconds, of the session duration
+// histogram. The last bucket is open-ended.
+var durationBuckets = []struct {
+	From  int
+	Label string
+}{
+	{0, "<1m"},
+	{60, "1-5m"},
+	{300, "5-15m"},
+	{900, "15-30m"},
+	{1800, "30-60m"},
+	{3600, "60m+"},
+}
+
+// AnalyticsRollup is one UTC day of an organization's session analytics,
+// written by the rollup job. Admin endpoints only ever read rollups.
+type AnalyticsRollup struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID         string             `bson:"public_id,omitempty" json:"id"`
+	OrgID            string             `bson:"org_id" json:"-"`
+	Date             string             `bson:"date" json:"date"` // YYYY-MM-DD, UTC
+	ActiveDevices    []PlatformActivity `bson:"active_devices" json:"activeDevices"`
+	Sessions         int                `bson:"sessions" json:"sessions"`
+	SessionDurations []DurationBucket   `bson:"session_durations" json:"sessionDurations"`
+	Retention        []RetentionCohort  `bson:"retention" json:"retention"`
+	ComputedAt       time.Time          `bson:"computed_at" json:"computedAt"`
+}
+
+// PlatformActivity counts devices and users active on a platform that day
+type PlatformActivity struct {
+	Platform string `bson:"platform" json:"platform"`
+	Devices  int    `bson:"devices" json:"devices"`
+	Users    int    `bson:"users" json:"users"`
+}
+
+// DurationBucket counts sessions started that day by length
+type DurationBucket struct {
+	Bucket   string `bson:"bucket" json:"bucket"`
+	Sessions int    `bson:"sessions" json:"sessions"`
+}
+
+// RetentionCohort is the users who signed up in one week. Active[n] counts
+// those with a session n weeks after their signup week; Active[0] is the
+// signup week itself.
+type RetentionCohort struct {
+	WeekOf string `bson:"week_of" json:"weekOf"` // Monday, YYYY-MM-DD
+	Users  int    `bson:"users" json:"users"`
+	Active []int  `bson:"active" json:"active"`
+}
+
+// ============================================================================
+// ANALYTICS HANDLERS
+// ============================================================================
+
+// GetAnalyticsRollups lists the organization's daily rollups between
+// ?from= and ?to= (YYYY-MM-DD, inclusive), the last 30 days by default.
+// Platform admins without X-Org-ID get the default organization's.
+func GetAnalyticsRollups(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	today := startOfDay(time.Now(), time.UTC)
+	from, to := today.AddDate(0, 0, -29), today
+
+	var errs fieldErrors
+	if v := query.Get("from"); v != "" {
+		if t, err := time.Parse(rollupDateLayout, v); err != nil {
+			errs.add("from", CodeInvalid, "must be a date in YYYY-MM-DD format")
+		} else {
+			from = t
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if t, err := time.Parse(rollupDateLayout, v); err != nil {
+			errs.add("to", CodeInvalid, "must be a date in YYYY-MM-DD format")
+		} else {
+			to = t
+		}
+	}
+	if len(errs) == 0 && to.Before(from) {
+		errs.add("to", CodeOutOfRange, "must not be before from")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Dates are fixed-width, so they sort and compare as strings
+	cursor, err := analyticsRollupsCol.Find(ctx,
+		bson.M{"org_id": orgID(ctx), "date": bson.M{
+			"$gte": from.Format(rollupDateLayout),
+			"$lte": to.Format(rollupDateLayout),
+		}},
+		options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch analytics")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	rollups := []AnalyticsRollup{}
+	if err := cursor.All(ctx, &rollups); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode analytics")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Analytics fetched successfully",
+		Data:    rollups,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RunAnalyticsRollup recomputes the organization's rollup for ?date=
+// (YYYY-MM-DD, today by default) without waiting for the scheduler, e.g. to
+// backfill a day
+func RunAnalyticsRollup(w http.ResponseWriter, r *http.Request) {
+	day := startOfDay(time.Now(), time.UTC)
+	if v := r.URL.Query().Get("date"); v != "" {
+		t, err := time.Parse(rollupDateLayout, v)
+		if err != nil {
+			var errs fieldErrors
+			errs.add("date", CodeInvalid, "must be a date in YYYY-MM-DD format")
+			sendValidationErrors(w, errs)
+			return
+		}
+		day = t
+	}
+
+	rollup, err := computeRollup(r.Context(), orgID(r.Context()), day)
+	if err != nil {
+		log.Printf("❌ Error computing analytics rollup for %s: %v", day.Format(rollupDateLayout), err)
+		sendError(w, http.StatusInternalServerError, "Failed to compute analytics")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Analytics computed successfully",
+		Data:    rollup,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// SESSION TRACKING
+// ============================================================================
+
+// requestPlatform reads the X-Platform header the apps send, falling back
+// to a guess from the user agent
+func requestPlatform(r *http.Request) string {
+	if p := strings.ToLower(strings.TrimSpace(r.Header.Get("X-Platform"))); knownPlatforms[p] {
+		return p
+	}
+	ua := strings.ToLower(r.UserAgent())
+	switch {
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		return PlatformIOS
+	case strings.Contains(ua, "mozilla"):
+		return PlatformWeb
+	}
+	return PlatformOther
+}
+
+// touchSession extends the user's current session on this device, or starts
+// a new one. Failures are logged; analytics never fail a request, and
+// without MongoDB they aren't kept.
+func touchSession(ctx context.Context, r *http.Request, userID, deviceID string) {
+	if !mongoConnected() {
+		return
+	}
+	now := time.Now()
+	device := requestDevice(r, deviceID)
+	_, err := sessionsCol.UpdateOne(ctx,
+		bson.M{
+			"user_id":      userID,
+			"device":       device,
+			"last_seen_at": bson.M{"$gte": now.Add(-sessionIdleTimeout)},
+		},
+		bson.M{
+			"$set": bson.M{"last_seen_at": now},
+			"$setOnInsert": bson.M{
+				"public_id":  newPublicID(),
+				"org_id":     orgID(ctx),
+				"platform":   requestPlatform(r),
+				"started_at": now,
+			},
+		},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("❌ Error tracking session for %s: %v", userID, err)
+	}
+}
+
+// ============================================================================
+// ROLLUP JOB
+// ============================================================================
+
+// startAnalyticsScheduler recomputes every organization's rollups for today
+// and yesterday every hour. Yesterday is redone so sessions running past
+// midnight are counted in full. It stops when ctx is cancelled.
+func startAnalyticsScheduler(ctx context.Context) {
+	registerJob("analytics", time.Hour)
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		defer ticker.Stop()
+		for {
+			var lastErr error
+			today := startOfDay(time.Now(), time.UTC)
+			orgIDs, err := organizationsCol.Distinct(ctx, "org_id", bson.M{})
+			if err != nil {
+				log.Printf("❌ Error listing organizations for analytics: %v", err)
+				lastErr = err
+			}
+			for _, org := range orgIDs {
+				org, _ := org.(string)
+				for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+					if _, err := computeRollup(ctx, org, day); err != nil {
+						log.Printf("❌ Error computing analytics rollup for %s on %s: %v", org, day.Format(rollupDateLayout), err)
+						lastErr = err
+					}
+				}
+			}
+			recordJobRun("analytics", lastErr)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// computeRollup aggregates an organization's UTC day starting at day and
+// stores the result
+func computeRollup(ctx context.Context, orgID string, day time.Time) (*AnalyticsRollup, error) {
+	end := day.AddDate(0, 0, 1)
+	rollup := AnalyticsRollup{OrgID: orgID, Date: day.Format(rollupDateLayout)}
+
+	var err error
+	if rollup.ActiveDevices, err = activeDevices(ctx, orgID, day, end); err != nil {
+		return nil, err
+	}
+	if rollup.SessionDurations, rollup.Sessions, err = sessionDurations(ctx, orgID, day, end); err != nil {
+		return nil, err
+	}
+	if rollup.Retention, err = retentionCohorts(ctx, orgID, end); err != nil {
+		return nil, err
+	}
+	rollup.ComputedAt = time.Now()
+
+	err = analyticsRollupsCol.FindOneAndUpdate(ctx,
+		bson.M{"org_id": orgID, "date": rollup.Date},
+		bson.M{
+			"$set": bson.M{
+				"active_devices":    rollup.ActiveDevices,
+				"sessions":          rollup.Sessions,
+				"session_durations": rollup.SessionDurations,
+				"retention":         rollup.Retention,
+				"computed_at":       rollup.ComputedAt,
+			},
+			"$setOnInsert": bson.M{"public_id": newPublicID()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&rollup)
+	if err != nil {
+		return nil, err
+	}
+	return &rollup, nil
+}
+
+// activeDevices counts devices and users per platform with a session of
+// the organization overlapping [start, end)
+func activeDevices(ctx context.Context, orgID string, start, end time.Time) ([]PlatformActivity, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"org_id":       orgID,
+			"started_at":   bson.M{"$lt": end},
+			"last_seen_at": bson.M{"$gte": start},
+		}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{
+			"platform": "$platform",
+			"device":   "$device",
+			"user_id":  "$user_id",
+		}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$_id.platform",
+			"devices": bson.M{"$sum": 1},
+			"users":   bson.M{"$addToSet": "$_id.user_id"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"platform": "$_id",
+			"devices":  1,
+			"users":    bson.M{"$size": "$users"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"platform": 1}}},
+	}
+
+	cursor, err := sessionsCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	platforms := []PlatformActivity{}
+	if err := cursor.All(ctx, &platforms); err != nil {
+		return nil, err
+	}
+	return platforms, nil
+}
+
+// sessionDurations buckets the organization's sessions started in
+// [start, end) by length. Every bucket is returned, empty ones with zero
+// sessions.
+func sessionDurations(ctx context.Context, orgID string, start, end time.Time) ([]DurationBucket, int, error) {
+	boundaries := bson.A{}
+	for _, b := range durationBuckets {
+		boundaries = append(boundaries, b.From)
+	}
+	last := durationBuckets[len(durationBuckets)-1].From
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"org_id": orgID, "started_at": bson.M{"$gte": start, "$lt": end}}}},
+		{{Key: "$bucket", Value: bson.M{
+			"groupBy": bson.M{"$dateDiff": bson.M{
+				"startDate": "$started_at",
+				"endDate":   "$last_seen_at",
+				"unit":      "second",
+			}},
+			"boundaries": boundaries,
+			"default":    last, // everything from the last bound up
+			"output":     bson.M{"sessions": bson.M{"$sum": 1}},
+		}}},
+	}
+
+	cursor, err := sessionsCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		From     int `bson:"_id"`
+		Sessions int `bson:"sessions"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, 0, err
+	}
+
+	byFrom := map[int]int{}
+	total := 0
+	for _, c := range counts {
+		byFrom[c.From] = c.Sessions
+		total += c.Sessions
+	}
+	buckets := make([]DurationBucket, 0, len(durationBuckets))
+	for _, b := range durationBuckets {
+		buckets = append(buckets, DurationBucket{Bucket: b.Label, Sessions: byFrom[b.From]})
+	}
+	return buckets, total, nil
+}
+
+// retentionCohorts builds the organization's weekly signup cohorts for the
+// retentionWeeks weeks up to end, with how many of each cohort were active
+// in every week since
+func retentionCohorts(ctx context.Context, orgID string, end time.Time) ([]RetentionCohort, error) {
+	lastWeek := startOfWeek(end.Add(-time.Nanosecond))
+	firstWeek := lastWeek.AddDate(0, 0, -7*(retentionWeeks-1))
+
+	userCursor, err := usersCol.Find(ctx,
+		bson.M{"org_id": orgID, "created_at": bson.M{"$gte": firstWeek, "$lt": end}},
+		options.Find().SetProjection(bson.M{"user_id": 1, "created_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer userCursor.Close(ctx)
+
+	var users []User
+	if err := userCursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	// Weeks each user had a session in, by week number since firstWeek
+	cursor, err := sessionsCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"org_id": orgID, "started_at": bson.M{"$gte": firstWeek, "$lt": end}}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{
+			"user_id": "$user_id",
+			"week": bson.M{"$dateTrunc": bson.M{
+				"date":        "$started_at",
+				"unit":        "week",
+				"startOfWeek": "monday",
+			}},
+		}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var activity []struct {
+		Key struct {
+			UserID string    `bson:"user_id"`
+			Week   time.Time `bson:"week"`
+		} `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &activity); err != nil {
+		return nil, err
+	}
+
+	weekIndex := func(t time.Time) int {
+		return int(startOfWeek(t).Sub(firstWeek) / (7 * 24 * time.Hour))
+	}
+	activeWeeks := map[string]map[int]bool{}
+	for _, a := range activity {
+		if activeWeeks[a.Key.UserID] == nil {
+			activeWeeks[a.Key.UserID] = map[int]bool{}
+		}
+		activeWeeks[a.Key.UserID][weekIndex(a.Key.Week)] = true
+	}
+
+	cohorts := make([]RetentionCohort, retentionWeeks)
+	for i := range cohorts {
+		cohorts[i] = RetentionCohort{
+			WeekOf: firstWeek.AddDate(0, 0, 7*i).Format(rollupDateLayout),
+			Active: make([]int, retentionWeeks-i),
+		}
+	}
+	for _, u := range users {
+		cohort := weekIndex(u.CreatedAt)
+		cohorts[cohort].Users++
+		for week := range activeWeeks[u.UserID] {
+			if week >= cohort {
+				cohorts[cohort].Active[week-cohort]++
+			}
+		}
+	}
+	return cohorts, nil
+}
+
+// startOfWeek returns midnight UTC of the Monday starting t's week
+func startOfWeek(t time.Time) time.Time {
+	day := startOfDay(t, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
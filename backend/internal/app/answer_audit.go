@@ -0,0 +1,121 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ANSWER AUDIT MODELS
+// ============================================================================
+
+// AnswerChange is one change to a stored quiz answer. The answer_changes
+// collection is append-only: nothing in the server updates or deletes it,
+// including progress resets, so it can settle "the app lost my answer".
+type AnswerChange struct {
+	PublicID      string    `bson:"public_id,omitempty" json:"id"`
+	UserID        string    `bson:"user_id" json:"userId"`
+	ChapterID     string    `bson:"chapter_id" json:"chapterId"`
+	QuestionIndex int       `bson:"question_index" json:"questionIndex"`
+	OldAnswer     Answer    `bson:"old_answer" json:"oldAnswer"` // -1 if unanswered
+	NewAnswer     Answer    `bson:"new_answer" json:"newAnswer"`
+	SessionID     string    `bson:"session_id,omitempty" json:"sessionId,omitempty"`
+	UserAgent     string    `bson:"user_agent,omitempty" json:"userAgent,omitempty"`
+	ChangedAt     time.Time `bson:"changed_at" json:"changedAt"`
+}
+
+// AttemptAnswerChanges is the answer history leading up to an attempt
+type AttemptAnswerChanges struct {
+	Attempt QuizAttempt    `json:"attempt"`
+	Changes []AnswerChange `json:"changes"`
+}
+
+// ============================================================================
+// ANSWER AUDIT HANDLERS
+// ============================================================================
+
+// GetAttemptAnswerChanges lists every answer change made between the
+// previous attempt at the same quiz and this one
+func GetAttemptAnswerChanges(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filter, ok := idFilter(vars["attemptId"])
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	ctx := r.Context()
+
+	var attempt QuizAttempt
+	err := quizAttemptsCol.FindOne(ctx, tenantFilter(ctx, filter)).Decode(&attempt)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Attempt not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	window := bson.M{"$lte": attempt.CompletedAt}
+	var previous QuizAttempt
+	err = quizAttemptsCol.FindOne(ctx,
+		tenantFilter(ctx, bson.M{
+			"user_id":      attempt.UserID,
+			"chapter_id":   attempt.ChapterID,
+			"completed_at": bson.M{"$lt": attempt.CompletedAt},
+		}),
+		options.FindOne().SetSort(bson.D{{Key: "completed_at", Value: -1}})).Decode(&previous)
+	if err == nil {
+		window["$gt"] = previous.CompletedAt
+	} else if err != mongo.ErrNoDocuments {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	cursor, err := answerChangesCol.Find(ctx,
+		bson.M{"user_id": attempt.UserID, "chapter_id": attempt.ChapterID, "changed_at": window},
+		options.Find().SetSort(bson.D{{Key: "changed_at", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch answer changes")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	changes := []AnswerChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode answer changes")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Answer changes fetched successfully",
+		Data:    AttemptAnswerChanges{Attempt: attempt, Changes: changes},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// ANSWER AUDIT HELPERS
+// ============================================================================
+
+// recordAnswerChange appends to the answer log. A failed write is logged but
+// doesn't fail the answer itself. Without MongoDB there is no log.
+func recordAnswerChange(ctx context.Context, change AnswerChange) {
+	if !mongoConnected() {
+		return
+	}
+	change.PublicID = newPublicID()
+	change.ChangedAt = time.Now()
+	if _, err := answerChangesCol.InsertOne(ctx, change); err != nil {
+		log.Printf("❌ Error recording answer change: user=%s, chapter=%s, question=%d: %v",
+			change.UserID, change.ChapterID, change.QuestionIndex, err)
+	}
+}
@@ -0,0 +1,237 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// ============================================================================
+// QUESTION TYPES AND ANSWERS
+// ============================================================================
+
+// Kinds of quiz question. Questions saved before types existed have no
+// type and are single choice.
+const (
+	QuestionSingleChoice = "single_choice" // one option; CorrectAnswer is its index
+	QuestionMultiSelect  = "multi_select"  // any options; CorrectAnswers are their indexes
+	QuestionTrueFalse    = "true_false"    // options "True" and "False"; CorrectAnswer is 0 or 1
+	QuestionFillIn       = "fill_in"       // typed text; AcceptedAnswers lists what counts
+)
+
+// trueFalseOptions are the options of every true/false question
+var trueFalseOptions = []string{"True", "False"}
+
+// maxAnswerTextLength bounds a fill-in answer, in characters
+const maxAnswerTextLength = 500
+
+// kind is the question's type, with untyped questions as single choice
+func (q Question) kind() string {
+	if q.Type == "" {
+		return QuestionSingleChoice
+	}
+	return q.Type
+}
+
+// Answer is a learner's answer to one question. Its JSON and BSON form
+// depends on the question: an option index for single choice and
+// true/false, an array of option indexes for multi-select, and a string for
+// fill-in. The index -1 means not answered, so answers stored before
+// question types existed read back unchanged.
+type Answer struct {
+	Choice  int     // single choice and true/false
+	Choices []int   // multi-select; non-nil for a multi-select answer
+	Text    *string // fill-in; non-nil for a fill-in answer
+
+	// malformed is set when the JSON fit no question type; answerErrors
+	// reports it against the answer's field, which UnmarshalJSON can't name
+	malformed bool
+}
+
+// noAnswer is the answer to a question not answered yet
+var noAnswer = Answer{Choice: -1}
+
+// choiceAnswer is the answer picking option i
+func choiceAnswer(i int) Answer {
+	return Answer{Choice: i}
+}
+
+// answered reports whether a is an answer at all
+func (a Answer) answered() bool {
+	return !a.malformed && (a.Choices != nil || a.Text != nil || a.Choice >= 0)
+}
+
+// Equal reports whether two answers are the same, ignoring the order of
+// multi-select choices
+func (a Answer) Equal(b Answer) bool {
+	switch {
+	case a.Text != nil || b.Text != nil:
+		return a.Text != nil && b.Text != nil && *a.Text == *b.Text
+	case a.Choices != nil || b.Choices != nil:
+		return a.Choices != nil && b.Choices != nil && sameChoices(a.Choices, b.Choices)
+	}
+	return a.Choice == b.Choice
+}
+
+// value is the answer as the plain Go value it encodes as
+func (a Answer) value() interface{} {
+	switch {
+	case a.Text != nil:
+		return *a.Text
+	case a.Choices != nil:
+		return a.Choices
+	}
+	return a.Choice
+}
+
+func (a Answer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.value())
+}
+
+func (a *Answer) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*a = Answer{Choice: -1, malformed: true}
+	switch v := v.(type) {
+	case float64:
+		if v == float64(int(v)) {
+			*a = Answer{Choice: int(v)}
+		}
+	case string:
+		*a = Answer{Text: &v}
+	case []interface{}:
+		choices := []int{}
+		if json.Unmarshal(data, &choices) == nil {
+			*a = Answer{Choices: choices}
+		}
+	case nil:
+		*a = noAnswer
+	}
+	return nil
+}
+
+func (a Answer) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(a.value())
+}
+
+func (a *Answer) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Int32, bsontype.Int64, bsontype.Double:
+		i, ok := raw.AsInt64OK()
+		if !ok {
+			return fmt.Errorf("answer %v is not an option index", raw)
+		}
+		*a = Answer{Choice: int(i)}
+	case bsontype.String:
+		text := raw.StringValue()
+		*a = Answer{Text: &text}
+	case bsontype.Array:
+		choices := []int{}
+		if err := raw.Unmarshal(&choices); err != nil {
+			return err
+		}
+		*a = Answer{Choices: choices}
+	case bsontype.Null:
+		*a = noAnswer
+	default:
+		return fmt.Errorf("answer of BSON type %v is not supported", t)
+	}
+	return nil
+}
+
+// ============================================================================
+// GRADING
+// ============================================================================
+
+// isCorrect grades an answer against the question's key. Multi-select
+// answers must pick exactly the correct options; fill-in answers must match
+// an accepted answer once both are normalized.
+func (q Question) isCorrect(a Answer) bool {
+	if a.malformed {
+		return false
+	}
+	switch q.kind() {
+	case QuestionMultiSelect:
+		return a.Choices != nil && sameChoices(a.Choices, q.CorrectAnswers)
+	case QuestionFillIn:
+		if a.Text == nil {
+			return false
+		}
+		text := normalizeAnswerText(*a.Text)
+		for _, accepted := range q.AcceptedAnswers {
+			if text != "" && text == normalizeAnswerText(accepted) {
+				return true
+			}
+		}
+		return false
+	}
+	return a.Choices == nil && a.Text == nil && a.Choice == q.CorrectAnswer
+}
+
+// normalizeAnswerText makes fill-in answers comparable: case, surrounding
+// punctuation and runs of whitespace don't count
+func normalizeAnswerText(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	return strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+}
+
+// sameChoices reports whether two lists pick the same options
+func sameChoices(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	picked := make(map[int]bool, len(a))
+	for _, i := range a {
+		picked[i] = true
+	}
+	for _, i := range b {
+		if !picked[i] {
+			return false
+		}
+	}
+	return len(picked) == len(b)
+}
+
+// answerErrors checks that an answer has the question's shape and only
+// picks options it has. field names the answer, e.g. "answer".
+func (q Question) answerErrors(field string, a Answer) fieldErrors {
+	var errs fieldErrors
+	switch q.kind() {
+	case QuestionMultiSelect:
+		if a.Choices == nil || a.malformed {
+			errs.add(field, CodeInvalidType, "must be an array of option indexes")
+			return errs
+		}
+		seen := map[int]bool{}
+		for i, choice := range a.Choices {
+			if choice < 0 || choice >= len(q.Options) {
+				errs.add(fmt.Sprintf("%s[%d]", field, i), CodeOutOfRange, "is not an option of this question")
+			} else if seen[choice] {
+				errs.add(fmt.Sprintf("%s[%d]", field, i), CodeInvalid, "picks the same option twice")
+			}
+			seen[choice] = true
+		}
+	case QuestionFillIn:
+		if a.Text == nil || a.malformed {
+			errs.add(field, CodeInvalidType, "must be a string")
+		} else if len([]rune(*a.Text)) > maxAnswerTextLength {
+			errs.add(field, CodeInvalid, fmt.Sprintf("must be at most %d characters", maxAnswerTextLength))
+		}
+	default:
+		if a.Choices != nil || a.Text != nil || a.malformed {
+			errs.add(field, CodeInvalidType, "must be an integer")
+		} else if a.Choice < 0 || a.Choice >= len(q.Options) {
+			errs.add(field, CodeOutOfRange, "is not an option of this question")
+		}
+	}
+	return errs
+}
@@ -0,0 +1,452 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// QUIZ ATTEMPT MODELS
+// ============================================================================
+
+// defaultPassScore is the percent of correct answers needed to pass a quiz
+// whose chapter doesn't set its own
+const defaultPassScore = 70
+
+// QuizAttempt is a finished run through a chapter's quiz
+type QuizAttempt struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID        string             `bson:"public_id,omitempty" json:"id"`
+	OrgID           string             `bson:"org_id,omitempty" json:"-"`
+	UserID          string             `bson:"user_id" json:"userId"`
+	ChapterID       string             `bson:"chapter_id" json:"chapterId"`
+	Number          int                `bson:"number" json:"number"` // 1 for the first attempt at the chapter
+	Answers         []Answer           `bson:"answers" json:"answers"`
+	QuestionIDs     []string           `bson:"question_ids,omitempty" json:"questionIds,omitempty"` // bank questions drawn, in order
+	Score           int                `bson:"score" json:"score"`                                  // correct answers
+	Total           int                `bson:"total" json:"total"`                                  // questions in the quiz
+	Percent         int                `bson:"percent" json:"percent"`                              // score as a percent of total, rounded down
+	PassScore       int                `bson:"pass_score" json:"passScore"`                         // percent needed to pass at the time
+	Passed          bool               `bson:"passed" json:"passed"`
+	StartedAt       *time.Time         `bson:"started_at,omitempty" json:"startedAt,omitempty"`
+	DurationSeconds int                `bson:"duration_seconds" json:"durationSeconds"` // 0 when the start is unknown
+	CompletedAt     time.Time          `bson:"completed_at" json:"completedAt"`
+
+	Review []AnswerReview `bson:"-" json:"review,omitempty"` // per-chapter history only
+}
+
+// AnswerReview pairs a learner's answer with the question, for reviewing a
+// past attempt. It reflects the quiz as it is now.
+type AnswerReview struct {
+	QuestionID      string   `json:"questionId"`
+	Type            string   `json:"type"`
+	QuestionText    string   `json:"questionText"`
+	Options         []string `json:"options"`
+	Answer          Answer   `json:"answer"` // -1 if unanswered
+	CorrectAnswer   int      `json:"correctAnswer"`
+	CorrectAnswers  []int    `json:"correctAnswers,omitempty"`  // multi-select
+	AcceptedAnswers []string `json:"acceptedAnswers,omitempty"` // fill-in
+	Correct         bool     `json:"correct"`
+	Explanation     string   `json:"explanation,omitempty"`
+	// Image is the question's picture, if it has one
+	Image *ImageRef `json:"image,omitempty"`
+}
+
+// QuizReview is a learner's finished quiz, question by question
+type QuizReview struct {
+	ChapterID string         `json:"chapterId"`
+	Result    QuizResult     `json:"result"`
+	Questions []AnswerReview `json:"questions"`
+}
+
+// recordQuizAttempt scores a completed quiz against the answer key of the
+// attempt's quiz and stores it in the attempt history. stored is the
+// learner's progress with the finished attempt. Without MongoDB there is
+// no history, and the attempt is scored but not stored.
+func recordQuizAttempt(ctx context.Context, chapter Chapter, stored Progress) (*QuizAttempt, error) {
+	userID, chapterID := stored.UserID, chapter.ChapterID
+	answers, startedAt := stored.QuizAnswers, stored.QuizStartedAt
+
+	var previous int64
+	if mongoConnected() {
+		var err error
+		previous, err = quizAttemptsCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"user_id": userID, "chapter_id": chapterID}))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	attempt := QuizAttempt{
+		PublicID:    newPublicID(),
+		OrgID:       orgID(ctx),
+		UserID:      userID,
+		ChapterID:   chapterID,
+		Number:      int(previous) + 1,
+		Answers:     answers,
+		QuestionIDs: stored.QuizQuestionIDs,
+		Total:       len(chapter.Quiz.Questions),
+		StartedAt:   startedAt,
+		CompletedAt: time.Now(),
+	}
+	if startedAt != nil {
+		attempt.DurationSeconds = int(attempt.CompletedAt.Sub(*startedAt).Seconds())
+	}
+	score := scoreQuiz(chapter, answers)
+	attempt.Score, attempt.Percent, attempt.PassScore, attempt.Passed = score.Score, score.Percent, score.PassScore, score.Passed
+	if !mongoConnected() {
+		return &attempt, nil
+	}
+
+	result, err := quizAttemptsCol.InsertOne(ctx, attempt)
+	if err != nil {
+		return nil, err
+	}
+	attempt.ID = result.InsertedID.(primitive.ObjectID)
+	return &attempt, nil
+}
+
+// QuizResult is how a finished attempt scored
+type QuizResult struct {
+	Score     int  `json:"score"`     // correct answers
+	Total     int  `json:"total"`     // questions in the quiz
+	Percent   int  `json:"percent"`   // score as a percent of total, rounded down
+	PassScore int  `json:"passScore"` // percent needed to pass
+	Passed    bool `json:"passed"`
+}
+
+// scoreQuiz grades answers against the chapter's answer key. A quiz passes
+// when the correct answers reach the chapter's pass score; an empty quiz
+// can't be passed.
+func scoreQuiz(chapter Chapter, answers []Answer) QuizResult {
+	result := QuizResult{Total: len(chapter.Quiz.Questions), PassScore: chapter.passScore()}
+	for i, q := range chapter.Quiz.Questions {
+		if i < len(answers) && q.isCorrect(answers[i]) {
+			result.Score++
+		}
+	}
+	if result.Total > 0 {
+		result.Percent = result.Score * 100 / result.Total
+	}
+	// Compared in whole numbers, so 7 of 10 passes a pass score of 70
+	result.Passed = result.Total > 0 && result.Score*100 >= result.PassScore*result.Total
+	return result
+}
+
+// passScore is the percent of correct answers needed to pass the chapter's quiz
+func (c Chapter) passScore() int {
+	if c.PassScore > 0 {
+		return c.PassScore
+	}
+	return defaultPassScore
+}
+
+// ============================================================================
+// QUIZ ATTEMPT HANDLERS
+// ============================================================================
+
+// GetQuizAttempts lists a user's attempts, newest first, filtered by
+// ?chapterId=, ?passed=, ?from= and ?to= (dates or RFC 3339) and paginated
+// with ?limit= and ?cursor=
+func GetQuizAttempts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	listQuizAttempts(w, r, vars["userId"], r.URL.Query().Get("chapterId"), nil)
+}
+
+// GetChapterQuizAttempts lists a user's attempts at one chapter's quiz,
+// newest first, each with a question-by-question review. It takes the same
+// filters and paging as GetQuizAttempts.
+func GetChapterQuizAttempts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	chapterID := vars["chapterId"]
+
+	var chapter Chapter
+	err := chaptersCol.FindOne(r.Context(), sharedOrOwn(r.Context(), bson.M{"chapter_id": chapterID})).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	listQuizAttempts(w, r, userID, chapterID, &chapter)
+}
+
+// RetakeQuiz clears a learner's answers so they can take a chapter's quiz
+// again, reshuffled if the quiz is shuffled. Earlier attempts stay in the
+// history, and a completed chapter stays completed.
+func RetakeQuiz(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	if !checkUserActive(ctx, w, userID) {
+		return
+	}
+	if !checkEnrolled(ctx, w, userID, chapterID) || !checkUnlocked(ctx, w, userID, chapterID) {
+		return
+	}
+
+	var chapter Chapter
+	err := chaptersCol.FindOne(ctx, liveChapters(ctx, bson.M{"chapter_id": chapterID})).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var progress Progress
+	err = progressCol.FindOne(ctx, tenantFilter(ctx, bson.M{"user_id": userID, "chapter_id": chapterID})).Decode(&progress)
+	if err == mongo.ErrNoDocuments || (err == nil && !progress.QuizCompleted) {
+		sendError(w, http.StatusConflict, "Finish the quiz before retaking it")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	answers := make([]Answer, len(progress.QuizAnswers))
+	for i := range answers {
+		answers[i] = noAnswer
+	}
+	now := time.Now()
+	err = progressCol.FindOneAndUpdate(ctx,
+		bson.M{"_id": progress.ID, "quiz_completed": true},
+		bson.M{
+			"$set": bson.M{
+				"quiz_progress":    0,
+				"quiz_answers":     answers,
+				"quiz_completed":   false,
+				"quiz_started_at":  now,
+				"last_accessed_at": now,
+				"updated_at":       now,
+			},
+			// A shuffled quiz gets a new order, and a quiz drawn from a
+			// bank new questions, for the new attempt
+			"$unset": bson.M{"quiz_seed": "", "quiz_question_ids": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&progress)
+	if err == mongo.ErrNoDocuments {
+		// Someone else restarted it first
+		sendError(w, http.StatusConflict, "Finish the quiz before retaking it")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to restart quiz")
+		return
+	}
+
+	log.Printf("🔁 Quiz restarted: user=%s, chapter=%s", userID, chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Quiz restarted successfully",
+		Data:    progress,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetQuizReview returns the learner's answers to a chapter's quiz next to the
+// answer key and each question's explanation. The key is only given out once
+// the quiz is finished, so until then it answers 409.
+func GetQuizReview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	// Clients may send public IDs instead of business keys
+	chapterID := resolveChapterKey(ctx, vars["chapterId"])
+
+	if !checkUserActive(ctx, w, userID) {
+		return
+	}
+	if !checkEnrolled(ctx, w, userID, chapterID) {
+		return
+	}
+
+	chapter, err := chapterStore.Get(ctx, chapterID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	progress, err := progressService.Progress.Get(ctx, userID, chapterID)
+	if err == ErrNotFound || (err == nil && !progress.QuizCompleted) {
+		sendError(w, http.StatusConflict, "Finish the quiz to review it")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// The questions this attempt drew, if the quiz draws from a bank
+	chapter, err = withBankQuestions(ctx, chapter, progress.QuizQuestionIDs)
+	if err != nil {
+		log.Printf("❌ Error loading drawn questions for %s: %v", userID, err)
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Reviewed in the quiz's own order, whatever order it was taken in
+	answers := sizeQuizAnswers(progress.QuizAnswers, len(chapter.Quiz.Questions))
+	review := QuizReview{
+		ChapterID: chapterID,
+		Result:    scoreQuiz(chapter, answers),
+		Questions: reviewAnswers(chapter, answers),
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Quiz review fetched successfully",
+		Data:    review,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// QUIZ ATTEMPT HELPERS
+// ============================================================================
+
+// listQuizAttempts sends a page of a user's attempts, optionally at one
+// chapter. With the chapter loaded, attempts carry a review of each answer.
+func listQuizAttempts(w http.ResponseWriter, r *http.Request, userID, chapterID string, chapter *Chapter) {
+	query := r.URL.Query()
+
+	var errs fieldErrors
+	filter := bson.M{"user_id": userID}
+
+	if chapterID != "" {
+		filter["chapter_id"] = chapterID
+	}
+	if v := query.Get("passed"); v != "" {
+		passed, err := strconv.ParseBool(v)
+		if err != nil {
+			errs.add("passed", CodeInvalidType, "must be a boolean")
+		} else {
+			filter["passed"] = passed
+		}
+	}
+
+	// Plain dates are days in the user's timezone; "to" includes that day
+	loc := userLocation(r.Context(), userID)
+	dateRange := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+		if v := query.Get(param); v != "" {
+			t, err := parseDateOrTime(v, loc)
+			if err != nil {
+				errs.add(param, CodeInvalid, "must be a YYYY-MM-DD date or an RFC 3339 timestamp")
+				continue
+			}
+			if param == "to" && len(v) == len("2006-01-02") {
+				t = t.AddDate(0, 0, 1)
+			}
+			dateRange[op] = t
+		}
+	}
+	if len(dateRange) > 0 {
+		filter["completed_at"] = dateRange
+	}
+
+	limit := parsePageLimit(r, &errs)
+
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := decodeTimeCursor(token)
+		if err != nil {
+			errs.add("cursor", CodeInvalid, "is not a valid cursor")
+		} else {
+			filter = bson.M{"$and": bson.A{filter, cursor.after("completed_at")}}
+		}
+	}
+
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Fetch one extra to know whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "completed_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+	cursor, err := quizAttemptsCol.Find(ctx, tenantFilter(ctx, filter), opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch quiz attempts")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	attempts := []QuizAttempt{}
+	if err := cursor.All(ctx, &attempts); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode quiz attempts")
+		return
+	}
+
+	page := Page{}
+	if len(attempts) > limit {
+		attempts = attempts[:limit]
+		last := attempts[limit-1]
+		page.NextCursor = timeCursor{At: last.CompletedAt, ID: last.ID}.encode()
+	}
+	if chapter != nil {
+		for i := range attempts {
+			quiz, err := withBankQuestions(ctx, *chapter, attempts[i].QuestionIDs)
+			if err != nil {
+				sendError(w, http.StatusInternalServerError, "Failed to fetch quiz attempts")
+				return
+			}
+			attempts[i].Review = reviewAnswers(quiz, attempts[i].Answers)
+		}
+	}
+	page.Items = attempts
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Quiz attempts fetched successfully",
+		Data:    page,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// reviewAnswers lines answers up with the chapter's questions
+func reviewAnswers(chapter Chapter, answers []Answer) []AnswerReview {
+	review := make([]AnswerReview, 0, len(chapter.Quiz.Questions))
+	for i, q := range chapter.Quiz.Questions {
+		answer := noAnswer
+		if i < len(answers) {
+			answer = answers[i]
+		}
+		review = append(review, AnswerReview{
+			QuestionID:      q.ID,
+			Type:            q.kind(),
+			QuestionText:    q.QuestionText,
+			Options:         q.Options,
+			Answer:          answer,
+			CorrectAnswer:   q.CorrectAnswer,
+			CorrectAnswers:  q.CorrectAnswers,
+			AcceptedAnswers: q.AcceptedAnswers,
+			Correct:         q.isCorrect(answer),
+			Explanation:     q.Explanation,
+			Image:           q.Image,
+		})
+	}
+	return review
+}
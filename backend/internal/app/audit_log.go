@@ -0,0 +1,275 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// AUDIT LOG MODELS
+// ============================================================================
+
+// Admin and destructive actions leave an entry in audit_log: who acted, what
+// they did to which target, and how the target changed. An entry is written
+// after the action succeeds; a failed write is logged and doesn't undo the
+// action. Admins read their organization's entries with GET /api/admin/audit.
+
+// Audit actions
+const (
+	AuditChapterCreated      = "chapter.created"
+	AuditChapterUpdated      = "chapter.updated"
+	AuditChapterPublished    = "chapter.published"
+	AuditChapterArchived     = "chapter.archived"
+	AuditChapterRestored     = "chapter.restored"
+	AuditChapterPurged       = "chapter.purged"
+	AuditProgressReset       = "progress.reset"
+	AuditProgressRepaired    = "progress.repaired"
+	AuditBulkDeleted         = "bulk_delete.completed"
+	AuditUserCreated         = "user.created"
+	AuditUserDeleted         = "user.deleted"
+	AuditUserRoleChanged     = "user.role_changed"
+	AuditUserStatusChanged   = "user.status_changed"
+	AuditUsersImported       = "user.imported"
+	AuditOrganizationCreated = "organization.created"
+	AuditOrganizationUpdated = "organization.updated"
+	AuditAdminKeyRotated     = "organization.admin_key_rotated"
+	AuditWebhookCreated      = "webhook.created"
+	AuditWebhookUpdated      = "webhook.updated"
+	AuditWebhookDeleted      = "webhook.deleted"
+)
+
+// Kinds of actor
+const (
+	ActorUser        = "user"          // an access token
+	ActorOrgAdminKey = "org_admin_key" // an organization's admin key
+	ActorPlatformKey = "platform_key"  // ADMIN_API_KEY
+	ActorCLI         = "cli"           // the admin command, run on the server
+)
+
+// auditRedacted stands in for secrets in recorded changes
+const auditRedacted = "[redacted]"
+
+// auditSecretFields are recorded as changed without their values
+var auditSecretFields = map[string]bool{
+	"password_hash":  true,
+	"admin_key_hash": true,
+	"secret":         true,
+}
+
+// auditIgnoredFields change with every write and aren't worth recording
+var auditIgnoredFields = map[string]bool{
+	"_id":        true,
+	"updated_at": true,
+}
+
+// AuditEntry records one admin or destructive action
+type AuditEntry struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID string             `bson:"public_id" json:"id"`
+	OrgID    string             `bson:"org_id,omitempty" json:"orgId,omitempty"` // empty for platform-wide actions
+	Actor    AuditActor         `bson:"actor" json:"actor"`
+	Action   string             `bson:"action" json:"action"`
+	Target   AuditTarget        `bson:"target" json:"target"`
+	Changes  []FieldChange      `bson:"changes,omitempty" json:"changes,omitempty"` // top-level fields, before and after
+	Details  interface{}        `bson:"details,omitempty" json:"details,omitempty"` // what a bulk action did
+	At       time.Time          `bson:"at" json:"at"`
+}
+
+// AuditActor is who performed an action
+type AuditActor struct {
+	Type   string `bson:"type" json:"type"`
+	UserID string `bson:"user_id,omitempty" json:"userId,omitempty"`
+	Role   string `bson:"role,omitempty" json:"role,omitempty"`
+}
+
+// AuditTarget is what an action was performed on
+type AuditTarget struct {
+	Type string `bson:"type" json:"type"` // chapter, user, organization, webhook, ...
+	ID   string `bson:"id" json:"id"`
+}
+
+// ============================================================================
+// AUDIT LOG HANDLERS
+// ============================================================================
+
+// GetAuditLog lists audit entries, newest first, paged with ?limit= and
+// ?cursor=. ?action=, ?actor= (a user ID), ?targetType=, ?targetId=, ?from=
+// and ?to= narrow them down.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var errs fieldErrors
+	limit := parsePageLimit(r, &errs)
+
+	ctx := r.Context()
+	conditions := bson.A{tenantFilter(ctx, bson.M{})}
+	for param, field := range map[string]string{
+		"action":     "action",
+		"actor":      "actor.user_id",
+		"targetType": "target.type",
+		"targetId":   "target.id",
+	} {
+		if v := query.Get(param); v != "" {
+			conditions = append(conditions, bson.M{field: v})
+		}
+	}
+	at := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+		if v := query.Get(param); v != "" {
+			t, err := parseDateOrTime(v, time.UTC)
+			if err != nil {
+				errs.add(param, CodeInvalid, "must be a YYYY-MM-DD date or an RFC 3339 timestamp")
+				continue
+			}
+			at[op] = t
+		}
+	}
+	if len(at) > 0 {
+		conditions = append(conditions, bson.M{"at": at})
+	}
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := decodeTimeCursor(token)
+		if err != nil {
+			errs.add("cursor", CodeInvalid, "is not a valid cursor")
+		} else {
+			conditions = append(conditions, cursor.after("at"))
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	// Fetch one extra to know whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+	cursor, err := auditLogCol.Find(ctx, bson.M{"$and": conditions}, opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch audit log")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	entries := []AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode audit log")
+		return
+	}
+
+	page := Page{}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		page.NextCursor = timeCursor{At: last.At, ID: last.ID}.encode()
+	}
+	page.Items = entries
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Audit log fetched successfully",
+		Data:    page,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// AUDIT LOG HELPERS
+// ============================================================================
+
+// recordAudit writes an audit entry for the request in ctx, filling in the
+// actor, the time and, unless set, the request's organization. A failed
+// write is logged but doesn't fail the action.
+func recordAudit(ctx context.Context, entry AuditEntry) {
+	entry.PublicID = newPublicID()
+	entry.Actor = auditActor(ctx)
+	entry.At = time.Now()
+	if entry.OrgID == "" {
+		if t, ok := requestTenant(ctx); ok {
+			entry.OrgID = t.orgID
+		}
+	}
+
+	if _, err := auditLogCol.InsertOne(ctx, entry); err != nil {
+		log.Printf("❌ Error recording audit entry %s on %s %s: %v", entry.Action, entry.Target.Type, entry.Target.ID, err)
+	}
+}
+
+// auditActor works out who a request acts as: a user by their token, or
+// the holder of an admin key
+func auditActor(ctx context.Context) AuditActor {
+	if claims, ok := authClaims(ctx); ok {
+		return AuditActor{Type: ActorUser, UserID: claims.Subject, Role: claims.Role}
+	}
+	t, _ := requestTenant(ctx)
+	if t.cli {
+		return AuditActor{Type: ActorCLI}
+	}
+	if t.superAdmin {
+		return AuditActor{Type: ActorPlatformKey}
+	}
+	return AuditActor{Type: ActorOrgAdminKey}
+}
+
+// auditDiff lists the top-level fields that differ between two versions of
+// a document, either of which may be nil for a creation or deletion.
+// Secrets are recorded as changed without their values.
+func auditDiff(before, after interface{}) []FieldChange {
+	from, to := auditFields(before), auditFields(after)
+
+	fields := make([]string, 0, len(from)+len(to))
+	for field := range from {
+		fields = append(fields, field)
+	}
+	for field := range to {
+		if _, ok := from[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		old, had := from[field]
+		cur, has := to[field]
+		if auditIgnoredFields[field] || (had == has && reflect.DeepEqual(old, cur)) {
+			continue
+		}
+		if auditSecretFields[field] {
+			old, cur = redactAudit(had), redactAudit(has)
+		}
+		changes = append(changes, FieldChange{Field: field, From: old, To: cur})
+	}
+	return changes
+}
+
+// auditFields is a document's stored fields, or none for nil
+func auditFields(doc interface{}) bson.M {
+	fields := bson.M{}
+	if doc == nil || reflect.ValueOf(doc).IsZero() {
+		return fields
+	}
+	raw, err := bson.Marshal(doc)
+	if err == nil {
+		err = bson.Unmarshal(raw, &fields)
+	}
+	if err != nil {
+		log.Printf("⚠️ Error reading %T for the audit log: %v", doc, err)
+	}
+	return fields
+}
+
+func redactAudit(present bool) interface{} {
+	if present {
+		return auditRedacted
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================================================
+// AUTH MODELS
+// ============================================================================
+
+// Login returns a signed access token (an HS256 JWT) and every other route
+// except the public ones below requires it as "Authorization: Bearer
+// <token>". Handlers act for the user in the token, never for a userId the
+// client names. Admin routes use X-Admin-Key instead.
+
+const defaultAccessTokenTTL = time.Hour
+
+// Error codes for authentication
+const (
+	ErrCodeUnauthorized = "unauthorized"
+	ErrCodeTokenExpired = "token_expired"
+	ErrCodeForbidden    = "forbidden"
+)
+
+// publicRoutes need no access token
+var publicRoutes = map[string]bool{
+	"/livez":                          true,
+	"/readyz":                         true,
+	"/api/health":                     true,
+	"/api/health/deep":                true,
+	"/api/openapi.json":               true,
+	"/api/docs":                       true,
+	"/api/login":                      true,
+	"/api/login/password":             true,
+	"/api/register":                   true,
+	"/api/auth/google":                true,
+	"/api/auth/apple":                 true,
+	"/api/email-verification/confirm": true,
+	"/api/password-reset":             true,
+	"/api/password-reset/confirm":     true,
+	"/api/token/refresh":              true,
+	"/api/org":                        true,
+	"/api/public/profiles/{handle}":   true,
+	"/api/certificates/verify/{code}": true,
+	"/api/media/uploads/{uploadId}":   true, // signed URLs
+}
+
+var errInvalidToken = errors.New("invalid token")
+var errTokenExpired = errors.New("token expired")
+
+// Claims is the payload of an access token
+type Claims struct {
+	Subject   string `json:"sub"` // user ID
+	OrgID     string `json:"org"`
+	Role      string `json:"role,omitempty"` // see roles.go; empty in older tokens
+	SessionID string `json:"sid,omitempty"`  // the auth session that issued it
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// AuthToken is the access token handed to a client, with the refresh token
+// that replaces it when it expires
+type AuthToken struct {
+	AccessToken      string     `json:"accessToken"`
+	TokenType        string     `json:"tokenType"` // always "Bearer"
+	ExpiresAt        time.Time  `json:"expiresAt"`
+	RefreshToken     string     `json:"refreshToken,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refreshExpiresAt,omitempty"`
+	SessionID        string     `json:"sessionId,omitempty"`
+}
+
+// AuthenticatedUser is the data of a successful login: the user, plus the
+// token to send with every later request
+type AuthenticatedUser struct {
+	User
+	AuthToken
+	// Sent once, to the client that created a guest: it logs the guest in
+	// again and proves the client holds it in a merge
+	GuestSecret string `json:"guestSecret,omitempty"`
+}
+
+// ============================================================================
+// AUTH MIDDLEWARE
+// ============================================================================
+
+type claimsContextKey struct{}
+
+// authClaims returns the verified token claims of the request, if any
+func authClaims(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsContextKey{}).(Claims)
+	return c, ok
+}
+
+// authUserID returns the authenticated user of the request, or "" for public
+// and admin requests
+func authUserID(ctx context.Context) string {
+	c, _ := authClaims(ctx)
+	return c.Subject
+}
+
+// AuthMiddleware verifies the bearer token and puts its claims on the request
+// context. Public routes skip it, and admin routes authenticate with their
+// admin key instead.
+func AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		template := ""
+		if route := mux.CurrentRoute(r); route != nil {
+			template, _ = route.GetPathTemplate()
+		}
+		if publicRoutes[template] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok && streamingRoutes[routeKey(r)] {
+			// Browsers can't set headers on WebSocket and EventSource
+			// requests, so streams take the token as a query parameter
+			token = r.URL.Query().Get("access_token")
+			ok = token != ""
+		}
+		if !ok && isAdminTemplate(template) {
+			// Admin routes also take an admin key; the admin guards check it
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ok {
+			sendErrorCode(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+		claims, err := parseAccessToken(token, time.Now())
+		if err == errTokenExpired {
+			sendErrorCode(w, http.StatusUnauthorized, ErrCodeTokenExpired, "Access token has expired")
+			return
+		} else if err != nil {
+			sendErrorCode(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Invalid access token")
+			return
+		}
+		if claims.SessionID != "" && !checkSessionActive(r.Context(), w, claims.SessionID) {
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsContextKey{}, claims)))
+	})
+}
+
+// SelfOnlyMiddleware stops learners from naming anyone but themselves in a
+// {userId} or {viewerId} route variable or a ?userId= parameter. Staff may
+// name their organization's learners when reading, and on the admin routes,
+// whose guards check their role. It runs after IDResolutionMiddleware so
+// public IDs compare as business keys.
+func SelfOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := authClaims(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if staffRole(claims.Role) && (r.Method == http.MethodGet || isAdminRoute(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		self := claims.Subject
+
+		vars := mux.Vars(r)
+		named := []string{vars["userId"], vars["viewerId"], r.URL.Query().Get("userId")}
+		for _, userID := range named {
+			if userID != "" && userID != self {
+				sendErrorCode(w, http.StatusForbidden, ErrCodeForbidden, "You can only access your own data")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// actingUserID returns the user a request acts for. Learners act for
+// themselves; a userId in their body must be their own (or absent). Admins
+// act for the user they name, resolved to a business key, which may be "".
+func actingUserID(w http.ResponseWriter, r *http.Request, named string) (string, bool) {
+	ctx := r.Context()
+	if named != "" {
+		named = resolveUserKey(ctx, named)
+	}
+
+	self := authUserID(ctx)
+	if self == "" {
+		return named, true
+	}
+	if named != "" && named != self {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeForbidden, "You can only access your own data")
+		return "", false
+	}
+	return self, true
+}
+
+// ============================================================================
+// ACCESS TOKENS
+// ============================================================================
+
+// jwtHeader is the fixed header of every token we issue
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+var (
+	jwtSecretOnce sync.Once
+	jwtSecret     []byte
+)
+
+// signingKey returns JWT_SECRET, or a random key if it is unset. A random
+// key means tokens don't survive a restart or work across replicas.
+func signingKey() []byte {
+	jwtSecretOnce.Do(func() {
+		if secret := config.JWTSecret; secret != "" {
+			jwtSecret = []byte(secret)
+			return
+		}
+		log.Println("⚠️ JWT_SECRET is not set; using a random key, so tokens won't survive a restart")
+		jwtSecret = make([]byte, 32)
+		if _, err := rand.Read(jwtSecret); err != nil {
+			panic("crypto/rand failed: " + err.Error())
+		}
+	})
+	return jwtSecret
+}
+
+// issueAccessToken signs a token for the user of an auth session, in the
+// session's organization and with the role they logged in with
+func issueAccessToken(session AuthSession, now time.Time) AuthToken {
+	expiresAt := now.Add(config.AccessTokenTTL)
+	payload, _ := json.Marshal(Claims{
+		Subject:   session.UserID,
+		OrgID:     session.OrgID,
+		Role:      session.Role,
+		SessionID: session.PublicID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return AuthToken{
+		AccessToken: unsigned + "." + signJWT(unsigned),
+		TokenType:   "Bearer",
+		ExpiresAt:   time.Unix(expiresAt.Unix(), 0).UTC(),
+		SessionID:   session.PublicID,
+	}
+}
+
+// parseAccessToken verifies a token from issueAccessToken and returns its
+// claims
+func parseAccessToken(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return Claims{}, errInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signJWT(parts[0]+"."+parts[1]))) {
+		return Claims{}, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, errInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Subject == "" {
+		return Claims{}, errInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Claims{}, errTokenExpired
+	}
+	return claims, nil
+}
+
+func signJWT(unsigned string) string {
+	mac := hmac.New(sha256.New, signingKey())
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// bearerToken extracts the token from "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,242 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ============================================================================
+// AUTH SESSION MODELS
+// ============================================================================
+
+// Each login opens a session on the device with a refresh token. The client
+// trades the refresh token for a new access token (and a new refresh token)
+// at POST /api/token/refresh, so it stays logged in without logging in
+// again. Revoking the session signs the device out at once.
+
+const defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+// Error codes for refresh tokens
+const (
+	ErrCodeInvalidRefreshToken = "invalid_refresh_token"
+	ErrCodeRefreshTokenReused  = "refresh_token_reused"
+	ErrCodeSessionRevoked      = "session_revoked"
+)
+
+// AuthSession is a device's login. The access tokens it issues carry its ID.
+type AuthSession struct {
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID          string             `bson:"public_id,omitempty" json:"id"`
+	UserID            string             `bson:"user_id" json:"userId"`
+	OrgID             string             `bson:"org_id" json:"-"`
+	Role              string             `bson:"role,omitempty" json:"-"` // the user's role at login
+	Device            string             `bson:"device" json:"device"`
+	Platform          string             `bson:"platform" json:"platform"`
+	UserAgent         string             `bson:"user_agent" json:"userAgent"`
+	IP                string             `bson:"ip" json:"ip"`
+	RefreshTokenHash  string             `bson:"refresh_token_hash" json:"-"`
+	PreviousTokenHash string             `bson:"previous_token_hash,omitempty" json:"-"` // spots reuse of a rotated token
+	CreatedAt         time.Time          `bson:"created_at" json:"createdAt"`
+	LastUsedAt        time.Time          `bson:"last_used_at" json:"lastUsedAt"`
+	ExpiresAt         time.Time          `bson:"expires_at" json:"expiresAt"`
+	RevokedAt         *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+	Current           bool               `bson:"-" json:"current"` // the session making the request
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// ============================================================================
+// AUTH SESSION HANDLERS
+// ============================================================================
+
+// RefreshAccessToken trades a refresh token for a new access token and a new
+// refresh token. Presenting a refresh token that was already traded revokes
+// the session, since either the client or a thief holds a stolen copy.
+func RefreshAccessToken(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	var errs fieldErrors
+	errs.required("refreshToken", req.RefreshToken)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+	now := time.Now()
+	hash := hashRefreshToken(req.RefreshToken)
+	refreshToken, newHash := newRefreshToken()
+
+	session, err := sessionStore.Rotate(ctx, hash, newHash, now, now.Add(config.RefreshTokenTTL))
+	if err == ErrNotFound {
+		if revokeReusedRefreshToken(ctx, hash, now) {
+			sendErrorCode(w, http.StatusUnauthorized, ErrCodeRefreshTokenReused, "Refresh token was already used; the session has been revoked")
+			return
+		}
+		sendErrorCode(w, http.StatusUnauthorized, ErrCodeInvalidRefreshToken, "Invalid or expired refresh token")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if !checkUserActive(ctx, w, session.UserID) {
+		return
+	}
+
+	token := issueAccessToken(session, now)
+	token.RefreshToken = refreshToken
+	token.RefreshExpiresAt = &session.ExpiresAt
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Token refreshed successfully",
+		Data:    token,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetAuthSessions lists the signed-in user's active sessions, most recently
+// used first
+func GetAuthSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	claims, _ := authClaims(ctx)
+
+	sessions, err := sessionStore.List(ctx, claims.Subject)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].PublicID == claims.SessionID
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Sessions fetched successfully",
+		Data:    sessions,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RevokeAuthSession signs one of the user's devices out, including the one
+// making the request. Its refresh token and access tokens stop working.
+func RevokeAuthSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["sessionId"]
+
+	ctx := r.Context()
+
+	session, err := sessionStore.Revoke(ctx, authUserID(ctx), sessionID, time.Now())
+	if err == ErrNotFound {
+		sendError(w, http.StatusNotFound, "Session not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	log.Printf("✅ Session revoked: user=%s, session=%s, device=%q", session.UserID, session.PublicID, session.Device)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Session revoked successfully",
+		Data:    session,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// AUTH SESSION HELPERS
+// ============================================================================
+
+// startAuthSession opens a session for a login and returns its tokens. An
+// earlier session on the same device is revoked, so logging in again doesn't
+// pile up sessions.
+func startAuthSession(ctx context.Context, r *http.Request, user User, deviceID string) (AuthToken, error) {
+	now := time.Now()
+	device := requestDevice(r, deviceID)
+
+	refreshToken, hash := newRefreshToken()
+	session := AuthSession{
+		PublicID:         newPublicID(),
+		UserID:           user.UserID,
+		OrgID:            userOrgID(user),
+		Role:             userRole(user),
+		Device:           device,
+		Platform:         requestPlatform(r),
+		UserAgent:        r.UserAgent(),
+		IP:               clientIP(r),
+		RefreshTokenHash: hash,
+		CreatedAt:        now,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(config.RefreshTokenTTL),
+	}
+	if err := sessionStore.Start(ctx, session); err != nil {
+		return AuthToken{}, err
+	}
+
+	token := issueAccessToken(session, now)
+	token.RefreshToken = refreshToken
+	token.RefreshExpiresAt = &session.ExpiresAt
+	return token, nil
+}
+
+// checkSessionActive sends a 401 and returns false when the session behind
+// an access token has been revoked or has expired
+func checkSessionActive(ctx context.Context, w http.ResponseWriter, sessionID string) bool {
+	active, err := sessionStore.Active(ctx, sessionID)
+	if err != nil {
+		log.Printf("❌ Error checking session %s: %v", sessionID, err)
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if !active {
+		sendErrorCode(w, http.StatusUnauthorized, ErrCodeSessionRevoked, "This session has been signed out")
+		return false
+	}
+	return true
+}
+
+// revokeReusedRefreshToken revokes the session whose previous refresh token
+// is hash and reports whether there was one
+func revokeReusedRefreshToken(ctx context.Context, hash string, now time.Time) bool {
+	session, err := sessionStore.RevokeReused(ctx, hash, now)
+	if err != nil {
+		if err != ErrNotFound {
+			log.Printf("❌ Error checking refresh token reuse: %v", err)
+		}
+		return false
+	}
+	log.Printf("⚠️ Refresh token reused, session revoked: user=%s, session=%s", session.UserID, session.PublicID)
+	return true
+}
+
+// newRefreshToken returns a random refresh token and the hash that is stored
+func newRefreshToken() (token, hash string) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	token = base64.RawURLEncoding.EncodeToString(b[:])
+	return token, hashRefreshToken(token)
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
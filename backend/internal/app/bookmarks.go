@@ -0,0 +1,310 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================================================
+// BOOKMARK MODELS
+// ============================================================================
+
+// A learner watching on more than one device has one VideoProgress, the
+// last position any of them reported, so the phone's place is lost once the
+// tablet plays on. Video writes that name their device (deviceId, or the
+// X-Device-ID header) also keep that device's own position, and the resume
+// endpoint offers each device its own place along with the others. Named
+// bookmarks are kept on the progress record too, and like the positions
+// belong to its chapter.
+
+const (
+	// maxBookmarksPerChapter bounds how many bookmarks a user keeps on one
+	// chapter
+	maxBookmarksPerChapter = 100
+	// maxBookmarkNameLength bounds a bookmark's name, in characters
+	maxBookmarkNameLength = 100
+)
+
+// Where a resume position came from
+const (
+	ResumeFromDevice = "device" // the requesting device's own position
+	ResumeFromLatest = "latest" // the last position any device reported
+	ResumeFromStart  = "start"  // the user hasn't watched the video yet
+)
+
+// deviceIDPattern is what a device ID may look like. IDs are keys of the
+// stored positions, so dots, dollars and the like are kept out.
+var deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// DevicePosition is where one device left a chapter's video
+type DevicePosition struct {
+	Position  int       `bson:"position" json:"position"` // in seconds
+	UpdatedAt time.Time `bson:"updated_at" json:"updatedAt"`
+}
+
+// Bookmark is a named point in a chapter's video
+type Bookmark struct {
+	ID        string    `bson:"id" json:"id"`
+	Name      string    `bson:"name" json:"name"`
+	Position  int       `bson:"position" json:"position"` // in seconds
+	CreatedAt time.Time `bson:"created_at" json:"createdAt"`
+}
+
+type CreateBookmarkRequest struct {
+	Name     string `json:"name"`
+	Position *int   `json:"position"`
+}
+
+// DeviceResume is one device's place in a chapter's video
+type DeviceResume struct {
+	DeviceID  string    `json:"deviceId"`
+	Position  int       `json:"position"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ResumePoints are the places a player can jump to in a chapter's video
+type ResumePoints struct {
+	ChapterID string         `json:"chapterId"`
+	DeviceID  string         `json:"deviceId,omitempty"` // the requesting device, when named
+	Position  int            `json:"position"`           // where this device should resume
+	Source    string         `json:"source"`             // device, latest or start
+	Latest    int            `json:"latest"`             // the last position any device reported
+	Devices   []DeviceResume `json:"devices"`            // newest first
+	Bookmarks []Bookmark     `json:"bookmarks"`          // by position
+}
+
+// ============================================================================
+// BOOKMARK HANDLERS
+// ============================================================================
+
+// GetBookmarks lists the user's bookmarks in a chapter, by position
+func GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	progress, err := progressStore.Get(ctx, userID, chapterID)
+	if err != nil && err != ErrNotFound {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch bookmarks")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Bookmarks fetched successfully",
+		Data:    sortedBookmarks(progress.Bookmarks),
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateBookmark adds a named bookmark at a position in a chapter's video
+func CreateBookmark(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	chapterID := vars["chapterId"]
+
+	var req CreateBookmarkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Validate input
+	var errs fieldErrors
+	name := strings.TrimSpace(req.Name)
+	errs.required("name", name)
+	if len([]rune(name)) > maxBookmarkNameLength {
+		errs.add("name", CodeInvalid, fmt.Sprintf("must be at most %d characters", maxBookmarkNameLength))
+	}
+	if req.Position == nil {
+		errs.add("position", CodeRequired, "is required")
+	} else if *req.Position < 0 {
+		errs.add("position", CodeOutOfRange, "must not be negative")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	chapter, err := chapterStore.Get(ctx, chapterID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !checkUserActive(ctx, w, userID) || !checkEnrolled(ctx, w, userID, chapterID) {
+		return
+	}
+	if errs := notePositionErrors(chapter, *req.Position); len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	bookmark := Bookmark{
+		ID:        newPublicID(),
+		Name:      name,
+		Position:  *req.Position,
+		CreatedAt: time.Now(),
+	}
+	saved, err := progressStore.AddBookmark(ctx, userID, chapterID, bookmark, maxBookmarksPerChapter)
+	if err != nil {
+		log.Printf("❌ Error adding bookmark: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to create bookmark")
+		return
+	}
+	if !hasBookmark(saved.Bookmarks, bookmark.ID) {
+		sendError(w, http.StatusConflict, fmt.Sprintf("A chapter can have at most %d bookmarks", maxBookmarksPerChapter))
+		return
+	}
+
+	log.Printf("🔖 Bookmark created: user=%s, chapter=%s, position=%d", userID, chapterID, bookmark.Position)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Bookmark created successfully",
+		Data:    bookmark,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// DeleteBookmark removes one of the user's bookmarks in a chapter
+func DeleteBookmark(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	err := progressStore.DeleteBookmark(ctx, userID, chapterID, vars["bookmarkId"])
+	if err == ErrNotFound {
+		sendError(w, http.StatusNotFound, "Bookmark not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to delete bookmark")
+		return
+	}
+
+	log.Printf("🔖 Bookmark deleted: user=%s, chapter=%s", userID, chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Bookmark deleted successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetResumePoints tells the device named by ?deviceId= (or X-Device-ID)
+// where to resume a chapter's video: its own last position, else the last
+// one any device reported. Every device's place and the bookmarks come
+// along so the player can offer to jump to them.
+func GetResumePoints(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	chapterID := vars["chapterId"]
+
+	var errs fieldErrors
+	deviceID := resumeDevice(r, r.URL.Query().Get("deviceId"), &errs)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	progress, err := progressStore.Get(ctx, userID, chapterID)
+	if err != nil && err != ErrNotFound {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch resume points")
+		return
+	}
+
+	points := ResumePoints{
+		ChapterID: chapterID,
+		DeviceID:  deviceID,
+		Source:    ResumeFromStart,
+		Latest:    progress.VideoProgress,
+		Devices:   []DeviceResume{},
+		Bookmarks: sortedBookmarks(progress.Bookmarks),
+	}
+	if err == nil {
+		points.Position, points.Source = progress.VideoProgress, ResumeFromLatest
+	}
+	if own, ok := progress.DevicePositions[deviceID]; ok && deviceID != "" {
+		points.Position, points.Source = own.Position, ResumeFromDevice
+	}
+	for device, position := range progress.DevicePositions {
+		points.Devices = append(points.Devices, DeviceResume{
+			DeviceID:  device,
+			Position:  position.Position,
+			UpdatedAt: position.UpdatedAt,
+		})
+	}
+	sort.Slice(points.Devices, func(i, j int) bool {
+		a, b := points.Devices[i], points.Devices[j]
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		return a.DeviceID < b.DeviceID
+	})
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Resume points fetched successfully",
+		Data:    points,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// BOOKMARK HELPERS
+// ============================================================================
+
+// resumeDevice is the device a video write or resume lookup is for: the
+// given device ID, else the X-Device-ID header, else "" for none. Unlike
+// requestDevice it doesn't fall back to the user agent, which two phones of
+// the same model share.
+func resumeDevice(r *http.Request, deviceID string, errs *fieldErrors) string {
+	device := strings.TrimSpace(deviceID)
+	if device == "" {
+		device = strings.TrimSpace(r.Header.Get("X-Device-ID"))
+	}
+	if device != "" && !deviceIDPattern.MatchString(device) {
+		errs.add("deviceId", CodeInvalid, "must be 1 to 64 letters, digits, dashes or underscores")
+		return ""
+	}
+	return device
+}
+
+// sortedBookmarks returns bookmarks by position, then by when they were
+// made, never nil
+func sortedBookmarks(bookmarks []Bookmark) []Bookmark {
+	sorted := append([]Bookmark{}, bookmarks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Position != sorted[j].Position {
+			return sorted[i].Position < sorted[j].Position
+		}
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+	return sorted
+}
+
+// hasBookmark reports whether bookmarks include one with the ID
+func hasBookmark(bookmarks []Bookmark, id string) bool {
+	for _, b := range bookmarks {
+		if b.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// BULK DELETE MODELS
+// ============================================================================
+
+// Every bulk delete is run twice: first with ?dryRun=true, which reports what
+// would go and returns a confirmation token, then again with that token to
+// actually delete. The token is bound to the exact operation and expires.
+
+// Bulk delete operations
+const (
+	BulkOpChapterProgress = "chapter_progress"
+	BulkOpUsers           = "users"
+)
+
+const (
+	confirmationTokenTTL = 10 * time.Minute
+	bulkDeleteSampleSize = 3
+)
+
+type BulkDeleteChapterProgressRequest struct {
+	ChapterID         string `json:"chapterId"`
+	ConfirmationToken string `json:"confirmationToken,omitempty"`
+}
+
+// BulkDeleteUsersRequest selects a cohort by user ID, by signup date, or both
+type BulkDeleteUsersRequest struct {
+	UserIDs           []string `json:"userIds,omitempty"`
+	SignedUpFrom      string   `json:"signedUpFrom,omitempty"` // YYYY-MM-DD (UTC) or RFC 3339, inclusive
+	SignedUpTo        string   `json:"signedUpTo,omitempty"`   // YYYY-MM-DD (UTC) or RFC 3339, exclusive
+	ConfirmationToken string   `json:"confirmationToken,omitempty"`
+}
+
+// BulkDeleteResult reports what a bulk delete removed, or would remove
+type BulkDeleteResult struct {
+	Operation         string                 `json:"operation"`
+	DryRun            bool                   `json:"dryRun"`
+	Total             int64                  `json:"total"`
+	Collections       []BulkDeleteCollection `json:"collections"`
+	ConfirmationToken string                 `json:"confirmationToken,omitempty"` // dry runs only
+	TokenExpiresAt    *time.Time             `json:"tokenExpiresAt,omitempty"`
+}
+
+// BulkDeleteCollection is one collection's share of a bulk delete
+type BulkDeleteCollection struct {
+	Collection string   `json:"collection"`
+	Count      int64    `json:"count"`
+	Samples    []bson.M `json:"samples,omitempty"` // dry runs only
+}
+
+// bulkTarget is the documents a bulk delete removes from one collection
+type bulkTarget struct {
+	col    *mongo.Collection
+	filter bson.M
+}
+
+// ============================================================================
+// BULK DELETE HANDLERS
+// ============================================================================
+
+// BulkDeleteChapterProgress deletes every learner's progress on a chapter
+func BulkDeleteChapterProgress(w http.ResponseWriter, r *http.Request) {
+	var req BulkDeleteChapterProgressRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	errs.required("chapterId", req.ChapterID)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+	req.ChapterID = resolveChapterKey(r.Context(), req.ChapterID)
+
+	targets := []bulkTarget{{progressCol, bson.M{"chapter_id": req.ChapterID}}}
+	runBulkDelete(w, r, BulkOpChapterProgress, req.ChapterID, req.ConfirmationToken, targets)
+}
+
+// BulkDeleteUsers deletes a cohort of users and everything they own. The
+// answer change log is append-only and is kept.
+func BulkDeleteUsers(w http.ResponseWriter, r *http.Request) {
+	var req BulkDeleteUsersRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	selector := bson.A{}
+	if len(req.UserIDs) > 0 {
+		userIDs := make([]string, 0, len(req.UserIDs))
+		for _, id := range req.UserIDs {
+			userIDs = append(userIDs, resolveUserKey(r.Context(), id))
+		}
+		req.UserIDs = userIDs
+		selector = append(selector, bson.M{"user_id": bson.M{"$in": userIDs}})
+	}
+	signedUp := bson.M{}
+	for field, value := range map[string]string{"signedUpFrom": req.SignedUpFrom, "signedUpTo": req.SignedUpTo} {
+		if value == "" {
+			continue
+		}
+		t, err := parseDateOrTime(value, time.UTC)
+		if err != nil {
+			errs.add(field, CodeInvalid, "must be a YYYY-MM-DD date or an RFC 3339 timestamp")
+			continue
+		}
+		if field == "signedUpFrom" {
+			signedUp["$gte"] = t
+		} else {
+			signedUp["$lt"] = t
+		}
+	}
+	if len(signedUp) > 0 {
+		selector = append(selector, bson.M{"created_at": signedUp})
+	}
+	if len(selector) == 0 && len(errs) == 0 {
+		errs.add("userIds", CodeRequired, "or signedUpFrom/signedUpTo is required")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	users, err := usersCol.Distinct(ctx, "user_id", bson.M{"$and": selector})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	owned, err := userDataTargets(ctx, users)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	targets := append([]bulkTarget{{usersCol, bson.M{"user_id": bson.M{"$in": users}}}}, owned...)
+
+	// The token covers the selection as sent, so a date-based cohort picks
+	// up users who signed up between the dry run and the delete
+	spec, _ := json.Marshal(BulkDeleteUsersRequest{
+		UserIDs:      req.UserIDs,
+		SignedUpFrom: req.SignedUpFrom,
+		SignedUpTo:   req.SignedUpTo,
+	})
+	runBulkDelete(w, r, BulkOpUsers, string(spec), req.ConfirmationToken, targets)
+}
+
+// ============================================================================
+// BULK DELETE HELPERS
+// ============================================================================
+
+// userDataTargets is everything users own outside their user documents,
+// deleted with them. The answer change log is left to the caller.
+func userDataTargets(ctx context.Context, users []interface{}) ([]bulkTarget, error) {
+	comments, err := commentsCol.Distinct(ctx, "_id", bson.M{"user_id": bson.M{"$in": users}})
+	if err != nil {
+		return nil, err
+	}
+
+	owned := bson.M{"user_id": bson.M{"$in": users}}
+	return []bulkTarget{
+		{progressCol, owned},
+		{quizAttemptsCol, owned},
+		{enrollmentsCol, owned},
+		{pathEnrollmentsCol, owned},
+		{pathsCol, bson.M{"owner_id": bson.M{"$in": users}}},
+		{profileChangesCol, owned},
+		{activityCol, owned},
+		{dailyActivityCol, owned},
+		{xpAwardsCol, owned},
+		{achievementsCol, owned},
+		{certificatesCol, owned},
+		{notesCol, owned},
+		{sessionsCol, owned},
+		{analyticsEventsCol, bson.M{"meta.user_id": bson.M{"$in": users}}},
+		{uploadsCol, owned},
+		{authSessionsCol, owned},
+		{accountTokensCol, owned},
+		{accountMergesCol, bson.M{"$or": bson.A{owned, bson.M{"guest_id": bson.M{"$in": users}}}}},
+		{commentsCol, owned},
+		// Their reports, and everyone's reports on their comments
+		{reportsCol, bson.M{"$or": bson.A{owned, bson.M{"comment_id": bson.M{"$in": comments}}}}},
+		{viewerGrantsCol, bson.M{"$or": bson.A{
+			bson.M{"learner_id": bson.M{"$in": users}},
+			bson.M{"viewer_id": bson.M{"$in": users}},
+		}}},
+	}, nil
+}
+
+// runBulkDelete reports the targets on a dry run, and deletes them when the
+// request carries a valid confirmation token for the same operation and spec
+func runBulkDelete(w http.ResponseWriter, r *http.Request, operation, spec, token string, targets []bulkTarget) {
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			var errs fieldErrors
+			errs.add("dryRun", CodeInvalidType, "must be a boolean")
+			sendValidationErrors(w, errs)
+			return
+		}
+	}
+
+	if !dryRun {
+		var errs fieldErrors
+		errs.required("confirmationToken", token)
+		if len(errs) == 0 && !validConfirmationToken(token, operation, spec, time.Now()) {
+			errs.add("confirmationToken", CodeInvalid, "does not match this operation or has expired; run it with dryRun=true first")
+		}
+		if len(errs) > 0 {
+			sendValidationErrors(w, errs)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	result := BulkDeleteResult{Operation: operation, DryRun: dryRun, Collections: []BulkDeleteCollection{}}
+
+	for _, target := range targets {
+		entry := BulkDeleteCollection{Collection: target.col.Name()}
+		var err error
+		if dryRun {
+			entry.Count, entry.Samples, err = previewDelete(ctx, target)
+		} else {
+			var deleted *mongo.DeleteResult
+			if deleted, err = target.col.DeleteMany(ctx, target.filter); err == nil {
+				entry.Count = deleted.DeletedCount
+			}
+		}
+		if err != nil {
+			log.Printf("❌ Error in bulk delete %s on %s: %v", operation, entry.Collection, err)
+			sendError(w, http.StatusInternalServerError, "Bulk delete failed")
+			return
+		}
+		result.Total += entry.Count
+		result.Collections = append(result.Collections, entry)
+	}
+
+	message := "Bulk delete completed successfully"
+	if dryRun {
+		expiresAt := time.Now().Add(confirmationTokenTTL)
+		result.ConfirmationToken = confirmationToken(operation, spec, expiresAt)
+		result.TokenExpiresAt = &expiresAt
+		message = "Dry run completed successfully"
+	} else {
+		log.Printf("🗑️ Bulk delete %s removed %d documents: %s", operation, result.Total, spec)
+		recordAudit(ctx, AuditEntry{
+			Action: AuditBulkDeleted,
+			Target: AuditTarget{Type: "bulk_delete", ID: operation},
+			Details: bson.M{
+				"spec":        spec,
+				"total":       result.Total,
+				"collections": result.Collections,
+			},
+		})
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// previewDelete counts a target's documents and returns a few of them
+func previewDelete(ctx context.Context, target bulkTarget) (int64, []bson.M, error) {
+	count, err := target.col.CountDocuments(ctx, target.filter)
+	if err != nil || count == 0 {
+		return count, nil, err
+	}
+
+	cursor, err := target.col.Find(ctx, target.filter,
+		options.Find().SetLimit(bulkDeleteSampleSize).SetProjection(bson.M{"_id": 0}))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var samples []bson.M
+	if err := cursor.All(ctx, &samples); err != nil {
+		return 0, nil, err
+	}
+	return count, samples, nil
+}
+
+// confirmationToken signs operation and spec until expiresAt with the admin
+// key, so tokens need no storage and die with a key rotation
+func confirmationToken(operation, spec string, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(config.AdminAPIKey))
+	mac.Write([]byte(operation + "\n" + spec + "\n" + expiry))
+	return expiry + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validConfirmationToken checks a token from confirmationToken
+func validConfirmationToken(token, operation, spec string, now time.Time) bool {
+	expiry, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	seconds, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.After(time.Unix(seconds, 0)) {
+		return false
+	}
+	expected := confirmationToken(operation, spec, time.Unix(seconds, 0))
+	return hmac.Equal([]byte(token), []byte(expected))
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// ============================================================================
+// CERTIFICATE PDF
+// ============================================================================
+
+// Certificates are rendered on request from their record, so a PDF always
+// matches what the verification endpoint says. The page is drawn by a
+// text/template of PDF content operators, templates/certificate.tmpl unless
+// CERTIFICATE_TEMPLATE names another file, and set in the standard Courier
+// fonts, which every PDF reader has and whose fixed width makes centering
+// exact without embedding font metrics.
+
+//go:embed templates/certificate.tmpl
+var embeddedTemplates embed.FS
+
+const (
+	// certificatePageWidth and certificatePageHeight are landscape A4, in
+	// points
+	certificatePageWidth  = 842
+	certificatePageHeight = 595
+	// certificateMargin keeps centered text inside the border
+	certificateMargin = 60
+	// courierAdvance is the width of every Courier glyph, per point of size
+	courierAdvance = 0.6
+)
+
+var (
+	certificateTemplateMu sync.RWMutex
+	certificateTemplate   *template.Template
+)
+
+// certificatePage is what the template can draw
+type certificatePage struct {
+	Name        string
+	CourseTitle string
+	IssuedOn    string // e.g. "March 10, 2026"
+	Code        string
+	VerifyURL   string
+}
+
+// LoadCertificateTemplate parses the certificate template, from
+// config.CertificateTemplate if set
+func LoadCertificateTemplate() error {
+	source, err := embeddedTemplates.ReadFile("templates/certificate.tmpl")
+	if err != nil {
+		return err
+	}
+	if path := config.CertificateTemplate; path != "" {
+		if source, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	tmpl, err := template.New("certificate").
+		Funcs(template.FuncMap{"center": pdfCenteredText}).
+		Option("missingkey=error").
+		Parse(string(source))
+	if err != nil {
+		return err
+	}
+
+	certificateTemplateMu.Lock()
+	certificateTemplate = tmpl
+	certificateTemplateMu.Unlock()
+	return nil
+}
+
+// renderCertificatePDF draws a certificate as a one-page PDF
+func renderCertificatePDF(cert Certificate) ([]byte, error) {
+	certificateTemplateMu.RLock()
+	tmpl := certificateTemplate
+	certificateTemplateMu.RUnlock()
+	if tmpl == nil {
+		return nil, fmt.Errorf("certificate template not loaded")
+	}
+
+	var content bytes.Buffer
+	err := tmpl.Execute(&content, certificatePage{
+		Name:        cert.UserName,
+		CourseTitle: cert.CourseTitle,
+		IssuedOn:    cert.IssuedAt.UTC().Format("January 2, 2006"),
+		Code:        cert.Code,
+		VerifyURL:   certificateVerifyURL(cert.Code),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buildPDF(cert.CourseTitle+" - "+cert.UserName, content.Bytes()), nil
+}
+
+// pdfCenteredText is the template's center: text centered across the page
+// at height y, shrunk until it fits between the margins
+func pdfCenteredText(size, y float64, font, text string) string {
+	width := courierAdvance * size * float64(len([]rune(text)))
+	if maxWidth := float64(certificatePageWidth - 2*certificateMargin); width > maxWidth {
+		size *= maxWidth / width
+		width = maxWidth
+	}
+	x := (certificatePageWidth - width) / 2
+	return fmt.Sprintf("BT /%s %.2f Tf %.2f %.2f Td %s Tj ET", font, size, x, y, pdfString(text))
+}
+
+// pdfString quotes text as a PDF string in WinAnsiEncoding. Latin-1
+// characters come through; anything else becomes "?".
+func pdfString(text string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ':
+			b.WriteByte(' ')
+		case r < 0x7f || (r >= 0xa0 && r <= 0xff):
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// buildPDF wraps one page of content operators in a minimal PDF that uses
+// the Courier fonts as F1 (regular), F2 (bold) and F3 (oblique)
+func buildPDF(title string, content []byte) []byte {
+	font := func(name string) string {
+		return "<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>"
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 5 0 R /F2 6 0 R /F3 7 0 R >> >> /Contents 4 0 R >>",
+			certificatePageWidth, certificatePageHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		font("Courier"),
+		font("Courier-Bold"),
+		font("Courier-Oblique"),
+		"<< /Title " + pdfString(title) + " /Producer (Resume Learning) >>",
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(objects)+1, len(objects), xref)
+	return pdf.Bytes()
+}
@@ -0,0 +1,298 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// CERTIFICATE MODELS
+// ============================================================================
+
+// Completing the last chapter of a course issues a certificate for it, once
+// per user and course. The learner's name and the course title are copied
+// onto the certificate when it is issued, so renaming either later doesn't
+// change what was certified. Anyone holding the verification code can check
+// a certificate, without signing in.
+
+// Certificate is a user's proof of completing a course
+type Certificate struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID    string             `bson:"public_id,omitempty" json:"id"`
+	OrgID       string             `bson:"org_id,omitempty" json:"-"`
+	UserID      string             `bson:"user_id" json:"userId"`
+	UserName    string             `bson:"user_name" json:"userName"`
+	CourseID    string             `bson:"course_id" json:"courseId"`
+	CourseTitle string             `bson:"course_title" json:"courseTitle"`
+	Code        string             `bson:"code" json:"code"` // verification code, e.g. "7KQ2-M9XD-4HVA"
+	IssuedAt    time.Time          `bson:"issued_at" json:"issuedAt"`
+}
+
+// CertificateVerification is what the public verification endpoint tells
+type CertificateVerification struct {
+	Valid       bool      `json:"valid"`
+	Code        string    `json:"code"`
+	UserName    string    `json:"userName"`
+	CourseTitle string    `json:"courseTitle"`
+	IssuedAt    time.Time `json:"issuedAt"`
+}
+
+// certificateCodeAlphabet is Crockford's base32: no I, L, O or U, so codes
+// read aloud or typed from paper come out right
+const certificateCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// certificateCodeGroups and certificateCodeGroupLen shape a code as
+// XXXX-XXXX-XXXX, 60 random bits
+const (
+	certificateCodeGroups   = 3
+	certificateCodeGroupLen = 4
+)
+
+// ============================================================================
+// CERTIFICATE HANDLERS
+// ============================================================================
+
+// GetUserCertificates lists a user's certificates, newest first
+func GetUserCertificates(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	certificates, err := certificatesForUser(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch certificates")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Certificates fetched successfully",
+		Data:    certificates,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetCertificatePDF downloads one of a user's certificates as a PDF
+func GetCertificatePDF(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	filter, ok := idFilter(vars["certificateId"])
+	if !ok {
+		sendError(w, http.StatusNotFound, "Certificate not found")
+		return
+	}
+	filter["user_id"] = userID
+
+	ctx := r.Context()
+
+	var cert Certificate
+	err := certificatesCol.FindOne(ctx, tenantFilter(ctx, filter)).Decode(&cert)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Certificate not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	pdf, err := renderCertificatePDF(cert)
+	if err != nil {
+		log.Printf("❌ Error rendering certificate %s: %v", cert.Code, err)
+		sendError(w, http.StatusInternalServerError, "Failed to render certificate")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="certificate-%s.pdf"`, cert.CourseID))
+	w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(pdf)
+}
+
+// VerifyCertificate checks a verification code. It is public: employers and
+// others who were shown a certificate don't have an account.
+func VerifyCertificate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	code := normalizeCertificateCode(vars["code"])
+
+	ctx := r.Context()
+
+	var cert Certificate
+	err := certificatesCol.FindOne(ctx, bson.M{"code": code}).Decode(&cert)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Certificate not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Certificate is valid",
+		Data: CertificateVerification{
+			Valid:       true,
+			Code:        cert.Code,
+			UserName:    cert.UserName,
+			CourseTitle: cert.CourseTitle,
+			IssuedAt:    cert.IssuedAt,
+		},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// CERTIFICATE HELPERS
+// ============================================================================
+
+// certificatesForUser lists a user's certificates, newest first
+func certificatesForUser(ctx context.Context, userID string) ([]Certificate, error) {
+	cursor, err := certificatesCol.Find(ctx, tenantFilter(ctx, bson.M{"user_id": userID}),
+		options.Find().SetSort(bson.D{{Key: "issued_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	certificates := []Certificate{}
+	if err := cursor.All(ctx, &certificates); err != nil {
+		return nil, err
+	}
+	return certificates, nil
+}
+
+// issueCertificates issues a certificate for each course of the chapter
+// that the user has now completed every chapter of. Failures are logged;
+// certificates never fail a progress write.
+func issueCertificates(ctx context.Context, userID, chapterID string) {
+	courseIDs, err := courseIDsForChapter(ctx, chapterID)
+	if err != nil {
+		log.Printf("❌ Error loading courses of chapter %s: %v", chapterID, err)
+		return
+	}
+	for _, courseID := range courseIDs {
+		if err := issueCertificate(ctx, userID, courseID); err != nil {
+			log.Printf("❌ Error issuing certificate: user=%s, course=%s: %v", userID, courseID, err)
+		}
+	}
+}
+
+// issueCertificate issues the user's certificate for a course if they have
+// completed all of its chapters and don't have one yet, and marks their
+// enrollment in it completed
+func issueCertificate(ctx context.Context, userID, courseID string) error {
+	course, err := findCourse(ctx, courseID)
+	if err != nil {
+		return err
+	}
+	if len(course.ChapterIDs) == 0 {
+		return nil
+	}
+
+	completed := true
+	_, count, err := progressStore.List(ctx, ProgressQuery{
+		UserID:     userID,
+		ChapterIDs: course.ChapterIDs,
+		Completed:  &completed,
+	})
+	if err != nil {
+		return err
+	}
+	if count < int64(len(uniqueStrings(course.ChapterIDs))) {
+		return nil
+	}
+	if err := markEnrollmentCompleted(ctx, userID, courseID); err != nil {
+		log.Printf("❌ Error marking enrollment completed: user=%s, course=%s: %v", userID, courseID, err)
+	}
+
+	user, err := userStore.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	cert := Certificate{
+		PublicID:    newPublicID(),
+		OrgID:       orgID(ctx),
+		UserID:      userID,
+		UserName:    user.Name,
+		CourseID:    courseID,
+		CourseTitle: course.Title,
+		IssuedAt:    time.Now(),
+	}
+	// A taken code is vanishingly rare; a certificate the user already has
+	// is the common duplicate, and ends the retries
+	for attempt := 0; attempt < 3; attempt++ {
+		cert.Code = newCertificateCode()
+		_, err = certificatesCol.InsertOne(ctx, cert)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+		if n, cerr := certificatesCol.CountDocuments(ctx, bson.M{"user_id": userID, "course_id": courseID}); cerr == nil && n > 0 {
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🎓 Certificate issued: user=%s, course=%s, code=%s", userID, courseID, cert.Code)
+	fireWebhook(ctx, cert.OrgID, WebhookCourseCompleted, map[string]interface{}{
+		"userId":          userID,
+		"courseId":        courseID,
+		"certificateCode": cert.Code,
+		"completedAt":     cert.IssuedAt,
+	})
+	return nil
+}
+
+// newCertificateCode returns a random verification code
+func newCertificateCode() string {
+	b := make([]byte, certificateCodeGroups*certificateCodeGroupLen)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	groups := make([]string, certificateCodeGroups)
+	for g := range groups {
+		group := make([]byte, certificateCodeGroupLen)
+		for i := range group {
+			group[i] = certificateCodeAlphabet[b[g*certificateCodeGroupLen+i]%32]
+		}
+		groups[g] = string(group)
+	}
+	return strings.Join(groups, "-")
+}
+
+// normalizeCertificateCode forgives lowercase, missing dashes, and the
+// letters Crockford's base32 reads as digits
+func normalizeCertificateCode(code string) string {
+	code = strings.NewReplacer("-", "", " ", "", "O", "0", "I", "1", "L", "1").Replace(strings.ToUpper(code))
+	if len(code) != certificateCodeGroups*certificateCodeGroupLen {
+		return code
+	}
+	groups := make([]string, certificateCodeGroups)
+	for g := range groups {
+		groups[g] = code[g*certificateCodeGroupLen : (g+1)*certificateCodeGroupLen]
+	}
+	return strings.Join(groups, "-")
+}
+
+// certificateVerifyURL is where a certificate's code can be checked, on
+// config.CertificateVerifyBaseURL when set
+func certificateVerifyURL(code string) string {
+	base := strings.TrimSuffix(config.CertificateVerifyBaseURL, "/")
+	return base + "/api/certificates/verify/" + code
+}
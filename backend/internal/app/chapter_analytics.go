@@ -0,0 +1,426 @@
+package app
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// CHAPTER ANALYTICS MODELS
+// ============================================================================
+
+// Chapter analytics show admins where learners fall out of a chapter: how
+// many of those enrolled start and finish its video and quiz, which wrong
+// answers each question draws, and where in the video viewers stop. They
+// cover the learners actively enrolled in a course of the chapter, in the
+// admin's organization, and are computed on request from progress and quiz
+// attempts.
+
+// dropOffBuckets is how many slices of the video the drop-off histogram
+// has at most
+const dropOffBuckets = 10
+
+// ChapterAnalytics is the completion funnel and drop-off points of a chapter
+type ChapterAnalytics struct {
+	ChapterID string              `json:"chapterId"`
+	Title     string              `json:"title"`
+	Enrolled  int                 `json:"enrolled"`
+	Funnel    ChapterFunnel       `json:"funnel"`
+	Questions []QuestionAnalytics `json:"questions"`
+	DropOff   []DropOffBucket     `json:"dropOff"`
+}
+
+// ChapterFunnel counts enrolled learners at each step of a chapter. Rates
+// are percentages with one decimal.
+type ChapterFunnel struct {
+	Enrolled            int     `bson:"-" json:"enrolled"`
+	VideoStarted        int     `bson:"video_started" json:"videoStarted"`
+	VideoCompleted      int     `bson:"video_completed" json:"videoCompleted"`
+	QuizCompleted       int     `bson:"quiz_completed" json:"quizCompleted"`
+	QuizPassed          int     `bson:"quiz_passed" json:"quizPassed"`
+	ChapterCompleted    int     `bson:"chapter_completed" json:"chapterCompleted"`
+	VideoStartRate      float64 `bson:"-" json:"videoStartRate"`      // of those enrolled
+	VideoCompletionRate float64 `bson:"-" json:"videoCompletionRate"` // of those who started
+}
+
+// QuestionAnalytics is how a question was answered across finished
+// attempts. It reflects the question as it is now.
+type QuestionAnalytics struct {
+	QuestionID   string        `json:"questionId"`
+	Type         string        `json:"type"`
+	QuestionText string        `json:"questionText"`
+	Attempts     int           `json:"attempts"` // attempts that included the question
+	Correct      int           `json:"correct"`
+	Wrong        int           `json:"wrong"`
+	Skipped      int           `json:"skipped"`
+	WrongAnswers []AnswerCount `json:"wrongAnswers"` // most picked first
+}
+
+// AnswerCount is how many attempts gave one answer
+type AnswerCount struct {
+	Answer Answer `json:"answer"`
+	Count  int    `json:"count"`
+}
+
+// DropOffBucket counts viewers who stopped watching between From and To
+// seconds into the video without finishing it
+type DropOffBucket struct {
+	From    int `json:"from"`
+	To      int `json:"to"`
+	Viewers int `json:"viewers"`
+}
+
+// ============================================================================
+// CHAPTER ANALYTICS HANDLERS
+// ============================================================================
+
+// GetChapterAnalytics reports a chapter's completion funnel, per-question
+// wrong-answer distribution and video drop-off histogram
+func GetChapterAnalytics(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	chapter, err := chapterStore.Get(ctx, chapterID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	analytics, err := chapterAnalytics(ctx, chapter)
+	if err != nil {
+		log.Printf("❌ Error computing analytics for chapter %s: %v", chapterID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to compute analytics")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Analytics fetched successfully",
+		Data:    analytics,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// CHAPTER ANALYTICS HELPERS
+// ============================================================================
+
+// chapterAnalytics computes a chapter's analytics
+func chapterAnalytics(ctx context.Context, chapter Chapter) (*ChapterAnalytics, error) {
+	learners, err := chapterLearners(ctx, chapter.ChapterID)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := ChapterAnalytics{
+		ChapterID: chapter.ChapterID,
+		Title:     chapter.Title,
+		Enrolled:  len(learners),
+	}
+	if analytics.Funnel, err = chapterFunnel(ctx, chapter.ChapterID, learners); err != nil {
+		return nil, err
+	}
+	if analytics.Questions, err = questionAnalytics(ctx, chapter, learners); err != nil {
+		return nil, err
+	}
+	if analytics.DropOff, err = videoDropOff(ctx, chapter, learners); err != nil {
+		return nil, err
+	}
+	return &analytics, nil
+}
+
+// chapterLearners lists the users actively enrolled in a course of the
+// chapter, expired access included: they still went through the funnel
+func chapterLearners(ctx context.Context, chapterID string) ([]string, error) {
+	courseIDs, err := courseIDsForChapter(ctx, chapterID)
+	if err != nil {
+		return nil, err
+	}
+	if len(courseIDs) == 0 {
+		return []string{}, nil
+	}
+
+	ids, err := enrollmentsCol.Distinct(ctx, "user_id", tenantFilter(ctx, bson.M{
+		"course_id": bson.M{"$in": courseIDs},
+		"status":    EnrollmentActive,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	learners := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			learners = append(learners, s)
+		}
+	}
+	return learners, nil
+}
+
+// chapterFunnel counts the learners at each step of the chapter
+func chapterFunnel(ctx context.Context, chapterID string, learners []string) (ChapterFunnel, error) {
+	count := func(condition interface{}) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{condition, 1, 0}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: tenantFilter(ctx, bson.M{
+			"chapter_id": chapterID,
+			"user_id":    bson.M{"$in": learners},
+		})}},
+		{{Key: "$group", Value: bson.M{
+			"_id": nil,
+			"video_started": count(bson.M{"$or": bson.A{
+				bson.M{"$gt": bson.A{"$video_progress", 0}},
+				"$video_completed",
+			}}),
+			"video_completed":   count("$video_completed"),
+			"quiz_completed":    count("$quiz_completed"),
+			"quiz_passed":       count("$quiz_passed"),
+			"chapter_completed": count("$chapter_completed"),
+		}}},
+	}
+
+	cursor, err := progressCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return ChapterFunnel{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var funnel ChapterFunnel
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&funnel); err != nil {
+			return ChapterFunnel{}, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return ChapterFunnel{}, err
+	}
+	funnel.Enrolled = len(learners)
+	funnel.VideoStartRate = ratePercent(funnel.VideoStarted, funnel.Enrolled)
+	funnel.VideoCompletionRate = ratePercent(funnel.VideoCompleted, funnel.VideoStarted)
+	return funnel, nil
+}
+
+// questionAnalytics tallies the learners' finished attempts by question and
+// answer, then grades each answer against the question's key. Attempts at
+// a bank-drawn quiz are tallied by the question drawn, the others by the
+// question's place in the quiz.
+func questionAnalytics(ctx context.Context, chapter Chapter, learners []string) ([]QuestionAnalytics, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: tenantFilter(ctx, bson.M{
+			"chapter_id": chapter.ChapterID,
+			"user_id":    bson.M{"$in": learners},
+		})}},
+		{{Key: "$unwind", Value: bson.M{"path": "$answers", "includeArrayIndex": "index"}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"question_id": bson.M{"$arrayElemAt": bson.A{"$question_ids", "$index"}},
+				"index":       "$index",
+				"answer":      "$answers",
+			},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := quizAttemptsCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var tallies []struct {
+		ID struct {
+			QuestionID string `bson:"question_id"`
+			Index      int    `bson:"index"`
+			Answer     Answer `bson:"answer"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &tallies); err != nil {
+		return nil, err
+	}
+
+	// The quiz's own questions come first, in order, then bank questions
+	// as they were first seen
+	questions := map[string]Question{}
+	var order, bankIDs []string
+	for _, q := range chapter.Quiz.Questions {
+		questions[q.ID] = q
+		order = append(order, q.ID)
+	}
+	for _, t := range tallies {
+		if id := t.ID.QuestionID; id != "" {
+			if _, ok := questions[id]; !ok {
+				questions[id] = Question{}
+				bankIDs = append(bankIDs, id)
+			}
+		}
+	}
+	sort.Strings(bankIDs)
+	if len(bankIDs) > 0 {
+		drawn, err := withBankQuestions(ctx, chapter, bankIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range drawn.Quiz.Questions {
+			questions[q.ID] = q
+		}
+		order = append(order, bankIDs...)
+	}
+
+	stats := make(map[string]*QuestionAnalytics, len(order))
+	for _, id := range order {
+		q := questions[id]
+		stats[id] = &QuestionAnalytics{
+			QuestionID:   q.ID,
+			Type:         q.kind(),
+			QuestionText: q.QuestionText,
+			WrongAnswers: []AnswerCount{},
+		}
+	}
+	for _, t := range tallies {
+		id := t.ID.QuestionID
+		if id == "" {
+			// Answers to questions since removed from the quiz have
+			// nothing to be graded against
+			if t.ID.Index >= len(chapter.Quiz.Questions) {
+				continue
+			}
+			id = chapter.Quiz.Questions[t.ID.Index].ID
+		}
+		s := stats[id]
+		s.Attempts += t.Count
+		switch answer := t.ID.Answer; {
+		case !answer.answered():
+			s.Skipped += t.Count
+		case questions[id].isCorrect(answer):
+			s.Correct += t.Count
+		default:
+			s.Wrong += t.Count
+			s.WrongAnswers = addAnswerCount(s.WrongAnswers, answer, t.Count)
+		}
+	}
+
+	analytics := make([]QuestionAnalytics, 0, len(order))
+	for _, id := range order {
+		s := stats[id]
+		sort.SliceStable(s.WrongAnswers, func(i, j int) bool { return s.WrongAnswers[i].Count > s.WrongAnswers[j].Count })
+		analytics = append(analytics, *s)
+	}
+	return analytics, nil
+}
+
+// addAnswerCount adds count to the answer's tally. Multi-select answers
+// picking the same options in another order are the same answer.
+func addAnswerCount(counts []AnswerCount, answer Answer, count int) []AnswerCount {
+	for i := range counts {
+		if counts[i].Answer.Equal(answer) {
+			counts[i].Count += count
+			return counts
+		}
+	}
+	return append(counts, AnswerCount{Answer: answer, Count: count})
+}
+
+// videoDropOff buckets the positions where learners who started the video
+// but didn't finish it last were. With the video's length known the
+// buckets are equal slices of it, the last one open-ended; otherwise
+// MongoDB picks dropOffBuckets buckets of about as many viewers each.
+func videoDropOff(ctx context.Context, chapter Chapter, learners []string) ([]DropOffBucket, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: tenantFilter(ctx, bson.M{
+			"chapter_id":      chapter.ChapterID,
+			"user_id":         bson.M{"$in": learners},
+			"video_progress":  bson.M{"$gt": 0},
+			"video_completed": false,
+		})}},
+	}
+
+	// Short videos get fewer buckets, so none starts past the end
+	width := int(math.Ceil(float64(chapter.Duration) / dropOffBuckets))
+	n := 0
+	if width > 0 {
+		n = (chapter.Duration + width - 1) / width
+		boundaries := bson.A{}
+		for i := 0; i < n; i++ {
+			boundaries = append(boundaries, i*width)
+		}
+		last := (n - 1) * width
+		pipeline = append(pipeline, bson.D{{Key: "$bucket", Value: bson.M{
+			"groupBy":    "$video_progress",
+			"boundaries": boundaries,
+			"default":    last, // past the last bound, e.g. after the video was shortened
+			"output":     bson.M{"viewers": bson.M{"$sum": 1}},
+		}}})
+	} else {
+		pipeline = append(pipeline, bson.D{{Key: "$bucketAuto", Value: bson.M{
+			"groupBy": "$video_progress",
+			"buckets": dropOffBuckets,
+			"output":  bson.M{"viewers": bson.M{"$sum": 1}},
+		}}})
+	}
+
+	cursor, err := progressCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	buckets := []DropOffBucket{}
+	if width == 0 {
+		var counts []struct {
+			ID struct {
+				Min int `bson:"min"`
+				Max int `bson:"max"`
+			} `bson:"_id"`
+			Viewers int `bson:"viewers"`
+		}
+		if err := cursor.All(ctx, &counts); err != nil {
+			return nil, err
+		}
+		for _, c := range counts {
+			buckets = append(buckets, DropOffBucket{From: c.ID.Min, To: c.ID.Max, Viewers: c.Viewers})
+		}
+		return buckets, nil
+	}
+
+	var counts []struct {
+		From    int `bson:"_id"`
+		Viewers int `bson:"viewers"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+	byFrom := map[int]int{}
+	for _, c := range counts {
+		byFrom[c.From] = c.Viewers
+	}
+	for i := 0; i < n; i++ {
+		from := i * width
+		buckets = append(buckets, DropOffBucket{
+			From:    from,
+			To:      min(from+width, chapter.Duration),
+			Viewers: byFrom[from],
+		})
+	}
+	return buckets, nil
+}
+
+// ratePercent is n as a percentage of of, with one decimal, 0 when of is 0
+func ratePercent(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return math.Round(float64(n)*1000/float64(of)) / 10
+}
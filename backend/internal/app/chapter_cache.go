@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================================================
+// CHAPTER CACHE
+// ============================================================================
+
+// Every app launch reads the chapter catalog. With CHAPTER_CACHE_BACKEND set
+// to redis, chapter reads go through Redis first and only reach the store
+// on a miss. Writes keep it current: the admin endpoints store the chapter
+// they wrote and drop the cached listings, which could include it. Entries
+// also expire after CHAPTER_CACHE_TTL, which bounds how long a listing read
+// from the store just before a write can outlive it. When Redis fails,
+// reads fall through to the store.
+
+// Chapter cache backends, chosen with CHAPTER_CACHE_BACKEND
+const (
+	ChapterCacheNone  = "none"
+	ChapterCacheRedis = "redis"
+)
+
+const (
+	// defaultChapterCacheTTL is how long entries are kept
+	defaultChapterCacheTTL = 5 * time.Minute
+	// chapterCacheTimeout bounds one cache operation, so a slow Redis
+	// doesn't hold up reads the store could answer
+	chapterCacheTimeout = 500 * time.Millisecond
+	// chapterListsKey is the set of cached listing keys, all dropped when a
+	// chapter changes
+	chapterListsKey = "chapters:lists"
+)
+
+// cachedChapterStore serves chapters from Redis, reading the store behind
+// it on a miss
+type cachedChapterStore struct {
+	ChapterStore
+	redis *redisClient
+	ttl   time.Duration
+}
+
+// cachedChapterList is how a listing is stored; BSON needs a document
+type cachedChapterList struct {
+	Chapters []Chapter `bson:"chapters"`
+}
+
+// chapterCache is the cache in use, nil without one
+var chapterCache *cachedChapterStore
+
+// storeChapterListScript caches a listing and records its key in the set
+// of listings, atomically so an invalidation can't miss it. KEYS are the
+// listing and the set; ARGV the listing and the TTL in seconds.
+const storeChapterListScript = `
+redis.call('SET', KEYS[1], ARGV[1], 'EX', ARGV[2])
+redis.call('SADD', KEYS[2], KEYS[1])
+redis.call('EXPIRE', KEYS[2], ARGV[2])
+return 1
+`
+
+// dropChaptersScript deletes the listings recorded in the set KEYS[1], the
+// set itself and the chapters KEYS[2:]
+const dropChaptersScript = `
+for _, key in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+  redis.call('DEL', key)
+end
+for i = 1, #KEYS do
+  redis.call('DEL', KEYS[i])
+end
+return 1
+`
+
+// setupChapterCache puts the cache CHAPTER_CACHE_BACKEND names, if any, in
+// front of store. Redis needs REDIS_URL; without it chapters aren't cached.
+func setupChapterCache(store ChapterStore) ChapterStore {
+	switch backend := config.ChapterCacheBackend; backend {
+	case "", ChapterCacheNone:
+		return store
+	case ChapterCacheRedis:
+		client, err := newRedisClient(config.RedisURL)
+		if err != nil {
+			log.Printf("⚠️ %v; not caching chapters", err)
+			return store
+		}
+		ttl := time.Duration(config.ChapterCacheTTLSeconds) * time.Second
+		chapterCache = &cachedChapterStore{ChapterStore: store, redis: client, ttl: ttl}
+		log.Println("✅ Caching chapters in Redis")
+		return chapterCache
+	default:
+		log.Printf("⚠️ Unknown CHAPTER_CACHE_BACKEND %q, not caching chapters", backend)
+		return store
+	}
+}
+
+func (c *cachedChapterStore) Get(ctx context.Context, chapterID string) (Chapter, error) {
+	key := chapterCacheKey(chapterID)
+	var chapter Chapter
+	if c.load(ctx, key, &chapter) {
+		if !sharedOrOwnChapter(ctx, chapter) {
+			return Chapter{}, ErrNotFound
+		}
+		return chapter, nil
+	}
+
+	chapter, err := c.ChapterStore.Get(ctx, chapterID)
+	if err != nil {
+		return chapter, err
+	}
+	c.save(ctx, chapter)
+	return chapter, nil
+}
+
+func (c *cachedChapterStore) List(ctx context.Context, query ChapterQuery) ([]Chapter, error) {
+	key := chapterListKey(tenantOrgFilter(ctx), query)
+	var cached cachedChapterList
+	if c.load(ctx, key, &cached) {
+		if cached.Chapters == nil {
+			cached.Chapters = []Chapter{}
+		}
+		return cached.Chapters, nil
+	}
+
+	chapters, err := c.ChapterStore.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, err := bson.Marshal(cachedChapterList{Chapters: chapters}); err == nil {
+		ctx, cancel := context.WithTimeout(ctx, chapterCacheTimeout)
+		defer cancel()
+		_, err = c.redis.Do(ctx, "EVAL", storeChapterListScript, "2", key, chapterListsKey,
+			string(encoded), strconv.Itoa(int(c.ttl.Seconds())))
+		if err != nil {
+			log.Printf("⚠️ Error caching chapters: %v", err)
+		}
+	}
+	return chapters, nil
+}
+
+// load decodes the entry at key into v, reporting false on a miss or when
+// Redis fails
+func (c *cachedChapterStore) load(ctx context.Context, key string, v interface{}) bool {
+	ctx, cancel := context.WithTimeout(ctx, chapterCacheTimeout)
+	defer cancel()
+	reply, err := c.redis.Do(ctx, "GET", key)
+	if err != nil {
+		log.Printf("⚠️ Error reading chapter cache: %v", err)
+		return false
+	}
+	raw, _ := reply.([]byte)
+	if raw == nil {
+		return false
+	}
+	if err := bson.Unmarshal(raw, v); err != nil {
+		log.Printf("⚠️ Error decoding cached %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// save caches a chapter
+func (c *cachedChapterStore) save(ctx context.Context, chapter Chapter) {
+	encoded, err := bson.Marshal(chapter)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, chapterCacheTimeout)
+	defer cancel()
+	_, err = c.redis.Do(ctx, "SET", chapterCacheKey(chapter.ChapterID), string(encoded),
+		"EX", strconv.Itoa(int(c.ttl.Seconds())))
+	if err != nil {
+		log.Printf("⚠️ Error caching chapter %s: %v", chapter.ChapterID, err)
+	}
+}
+
+// drop deletes the cached chapters and every cached listing
+func (c *cachedChapterStore) drop(ctx context.Context, chapterIDs ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, chapterCacheTimeout)
+	defer cancel()
+	args := []string{"EVAL", dropChaptersScript, strconv.Itoa(len(chapterIDs) + 1), chapterListsKey}
+	for _, id := range chapterIDs {
+		args = append(args, chapterCacheKey(id))
+	}
+	_, err := c.redis.Do(ctx, args...)
+	return err
+}
+
+// chapterWritten updates the cache after an admin wrote chapter: it caches
+// the chapter as written and drops the listings
+func chapterWritten(ctx context.Context, chapter Chapter) {
+	if chapterCache == nil {
+		return
+	}
+	chapter.AccessibilityIssues, chapter.Lock, chapter.Draft = nil, nil, nil
+	if err := chapterCache.drop(ctx, chapter.ChapterID); err != nil {
+		log.Printf("⚠️ Error invalidating cached chapters: %v", err)
+		return
+	}
+	if chapter.Archived {
+		return
+	}
+	chapterCache.save(ctx, chapter)
+}
+
+// chaptersChanged drops the cached chapters, and the listings, after a write
+// that didn't return them
+func chaptersChanged(ctx context.Context, chapterIDs ...string) {
+	if chapterCache == nil {
+		return
+	}
+	if err := chapterCache.drop(ctx, chapterIDs...); err != nil {
+		log.Printf("⚠️ Error invalidating cached chapters %v: %v", chapterIDs, err)
+	}
+}
+
+// chapterCacheKey is the key of a cached chapter
+func chapterCacheKey(chapterID string) string {
+	return "chapters:id:" + chapterID
+}
+
+// chapterListKey is the key of a cached listing: a hash of the organization
+// it was read for, the chapters it is limited to and its order
+func chapterListKey(orgID string, query ChapterQuery) string {
+	selection := "*"
+	if query.ChapterIDs != nil {
+		selection = "[" + strings.Join(query.ChapterIDs, ",") + "]"
+	}
+	order := make([]string, 0, len(query.Sort))
+	for _, e := range query.Sort {
+		order = append(order, fmt.Sprintf("%s:%v", e.Key, e.Value))
+	}
+	sum := sha256.Sum256([]byte(orgID + "|" + selection + "|" + strings.Join(order, ",")))
+	return "chapters:list:" + hex.EncodeToString(sum[:16])
+}
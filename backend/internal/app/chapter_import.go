@@ -0,0 +1,401 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// CHAPTER IMPORT MODELS
+// ============================================================================
+
+// Course content can be written as files and imported in one go, over the
+// API or with `main seed --seed-file`. A file holds chapters in the create
+// body's format, either as {"chapters": [...]} or as a bare list, in JSON
+// or YAML. The whole file is validated before anything is written, with
+// prerequisites and orders checked against the file as well as the
+// database, so chapters may refer to each other. Each chapter is then
+// upserted by chapter_id: new ones are created, existing ones that differ
+// are updated and published, and the rest are left alone, so importing the
+// same file twice changes nothing.
+
+// Chapter file formats
+const (
+	ChapterFileJSON = "json"
+	ChapterFileYAML = "yaml"
+)
+
+// Chapter import actions
+const (
+	ChapterImportCreated   = "created"
+	ChapterImportUpdated   = "updated"
+	ChapterImportUnchanged = "unchanged"
+)
+
+const (
+	// maxImportChapters bounds the chapters of one import
+	maxImportChapters = 500
+	// maxChapterFileBytes bounds an imported file
+	maxChapterFileBytes = 10 << 20
+)
+
+// ImportChaptersRequest is the body of a chapter import
+type ImportChaptersRequest struct {
+	Chapters []SaveChapterRequest `json:"chapters"`
+}
+
+// ChapterImportResult reports what an import did, or would do on a dry run
+type ChapterImportResult struct {
+	DryRun    bool                  `json:"dryRun"`
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Chapters  []ChapterImportChange `json:"chapters"` // in file order
+}
+
+// ChapterImportChange is the action taken for one chapter
+type ChapterImportChange struct {
+	ChapterID string   `json:"chapterId"`
+	Action    string   `json:"action"`
+	Fields    []string `json:"fields,omitempty"` // what an update changes
+}
+
+// ============================================================================
+// CHAPTER IMPORT HANDLERS
+// ============================================================================
+
+// ImportChapters upserts the chapters of a JSON or YAML file, the request
+// body. ?dryRun=true validates it and reports the plan without writing.
+func ImportChapters(w http.ResponseWriter, r *http.Request) {
+	var errs fieldErrors
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			errs.add("dryRun", CodeInvalidType, "must be a boolean")
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format := ""
+	switch {
+	case isJSONContentType(r.Header.Get("Content-Type")):
+		format = ChapterFileJSON
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" || mediaType == "text/yaml":
+		format = ChapterFileYAML
+	default:
+		sendErrorCode(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType,
+			"Content-Type must be application/json or application/yaml")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChapterFileBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Chapter files can be at most %d MB", maxChapterFileBytes>>20))
+		return
+	} else if err != nil {
+		sendError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	chapters, err := parseChapterFile(body, format)
+	if err != nil {
+		sendValidationErrors(w, []FieldError{chapterFileError(err)})
+		return
+	}
+
+	ctx := r.Context()
+
+	result, errs, err := importChapters(ctx, chapters, dryRun)
+	if err != nil {
+		log.Printf("❌ Error importing chapters: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to import chapters")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	message := "Chapters imported successfully"
+	if dryRun {
+		message = "Import checked, nothing was written"
+	}
+	response := ApiResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// CHAPTER IMPORT HELPERS
+// ============================================================================
+
+// chapterFileFormat picks a file's format from its extension
+func chapterFileFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ChapterFileJSON, nil
+	case ".yaml", ".yml":
+		return ChapterFileYAML, nil
+	}
+	return "", fmt.Errorf("%s: chapter files must end in .json, .yaml or .yml", path)
+}
+
+// parseChapterFile reads the chapters of a file in the given format
+func parseChapterFile(data []byte, format string) ([]SaveChapterRequest, error) {
+	if format == ChapterFileYAML {
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("is not valid YAML: %w", err)
+		}
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var chapters []SaveChapterRequest
+		if err := json.Unmarshal(data, &chapters); err != nil {
+			return nil, err
+		}
+		return chapters, nil
+	}
+	var file ImportChaptersRequest
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Chapters, nil
+}
+
+// chapterFileError describes an error from parseChapterFile as a field
+// error, the way request bodies' are described
+func chapterFileError(err error) FieldError {
+	fe := decodeError(err)
+	if fe.Code == CodeInvalid {
+		fe.Message = err.Error()
+	}
+	return fe
+}
+
+// importChapters validates chapters as a whole and, unless dryRun, upserts
+// them by chapter_id. Validation problems are returned as field errors
+// named after the chapter, e.g. "chapters[2].title", and nothing is
+// written; the error is only for database failures.
+func importChapters(ctx context.Context, chapters []SaveChapterRequest, dryRun bool) (ChapterImportResult, fieldErrors, error) {
+	result := ChapterImportResult{DryRun: dryRun, Chapters: []ChapterImportChange{}}
+
+	var errs fieldErrors
+	if len(chapters) == 0 {
+		errs.add("chapters", CodeRequired, "must have at least one chapter")
+	} else if len(chapters) > maxImportChapters {
+		errs.add("chapters", CodeOutOfRange, fmt.Sprintf("must have at most %d chapters", maxImportChapters))
+	}
+	if len(errs) > 0 {
+		return result, errs, nil
+	}
+
+	ids := make([]string, len(chapters))
+	for i := range chapters {
+		chapters[i].ChapterID = strings.TrimSpace(chapters[i].ChapterID)
+		ids[i] = chapters[i].ChapterID
+	}
+	existing := map[string]Chapter{}
+	cursor, err := chaptersCol.Find(ctx, bson.M{"chapter_id": bson.M{"$in": ids}})
+	if err != nil {
+		return result, nil, err
+	}
+	var found []Chapter
+	if err := cursor.All(ctx, &found); err != nil {
+		return result, nil, err
+	}
+	for _, chapter := range found {
+		existing[chapter.ChapterID] = chapter
+	}
+
+	// Existing chapters keep what the file leaves out, as on update, so
+	// the batch sees the prerequisites they will end up with
+	batch := chapterBatch{}
+	for i := range chapters {
+		req := &chapters[i]
+		field := fmt.Sprintf("chapters[%d].chapterId", i)
+		switch {
+		case req.ChapterID == "":
+			errs.add(field, CodeRequired, "is required")
+			continue
+		case !chapterIDPattern.MatchString(req.ChapterID):
+			errs.add(field, CodeInvalid, "must be 2-50 lowercase letters, digits, dashes or underscores")
+			continue
+		case batch[req.ChapterID].ChapterID != "":
+			errs.add(field, CodeInvalid, "appears more than once in the file")
+			continue
+		}
+		if chapter, ok := existing[req.ChapterID]; ok {
+			if !ownsChapter(ctx, chapter) {
+				errs.add(field, CodeInvalid, "is a chapter this organization can't edit")
+				continue
+			}
+			if chapter.Archived {
+				errs.add(field, CodeInvalid, "is an archived chapter; restore it before importing")
+				continue
+			}
+			if req.Prerequisites == nil {
+				req.Prerequisites = chapter.Prerequisites
+			}
+		} else if len(req.CourseIDs) == 0 {
+			req.CourseIDs = []string{defaultCourseFor(orgID(ctx))}
+		}
+		batch[req.ChapterID] = *req
+	}
+	if len(errs) > 0 {
+		return result, errs, nil
+	}
+
+	for i := range chapters {
+		req := &chapters[i]
+		others := make(chapterBatch, len(batch)-1)
+		for id, other := range batch {
+			if id != req.ChapterID {
+				others[id] = other
+			}
+		}
+
+		var chapterErrs fieldErrors
+		chapter, exists := existing[req.ChapterID]
+		if exists {
+			if chapterErrs, err = prepareChapterUpdate(ctx, req, chapter, others); err != nil {
+				return result, nil, err
+			}
+		} else {
+			if err := validateChapter(ctx, &chapterErrs, req, nil, others); err != nil {
+				return result, nil, err
+			}
+			for j, courseID := range req.CourseIDs {
+				if _, err := findCourse(ctx, courseID); err == mongo.ErrNoDocuments {
+					chapterErrs.add(fmt.Sprintf("courseIds[%d]", j), CodeUnknownValue, "is not a known course")
+				} else if err != nil {
+					return result, nil, err
+				}
+			}
+		}
+		for _, e := range chapterErrs {
+			e.Field = fmt.Sprintf("chapters[%d].%s", i, e.Field)
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		return result, errs, nil
+	}
+
+	for _, req := range chapters {
+		change := ChapterImportChange{ChapterID: req.ChapterID, Action: ChapterImportCreated}
+		chapter, exists := existing[req.ChapterID]
+		if exists {
+			if change.Fields, err = chapterContentDiff(chapter, req); err != nil {
+				return result, nil, err
+			}
+			change.Action = ChapterImportUpdated
+			if len(change.Fields) == 0 {
+				change.Action = ChapterImportUnchanged
+			}
+		}
+
+		switch change.Action {
+		case ChapterImportCreated:
+			result.Created++
+			if !dryRun {
+				if _, err := createChapter(ctx, req); err != nil {
+					return result, nil, fmt.Errorf("failed to create %s: %w", req.ChapterID, err)
+				}
+			}
+		case ChapterImportUpdated:
+			result.Updated++
+			if !dryRun {
+				updated, err := publishChapterContent(ctx, chapter, req, false)
+				if err != nil {
+					return result, nil, fmt.Errorf("failed to update %s: %w", req.ChapterID, err)
+				}
+				recordAudit(ctx, AuditEntry{
+					Action:  AuditChapterUpdated,
+					Target:  AuditTarget{Type: "chapter", ID: req.ChapterID},
+					Changes: auditDiff(chapter, updated),
+				})
+			}
+		default:
+			result.Unchanged++
+		}
+		result.Chapters = append(result.Chapters, change)
+	}
+
+	if !dryRun {
+		log.Printf("✅ Chapters imported: created=%d, updated=%d, unchanged=%d", result.Created, result.Updated, result.Unchanged)
+	}
+	return result, nil, nil
+}
+
+// chapterContentDiff lists the fields, by their JSON names, where a
+// validated update differs from the chapter's published content. Both are
+// compared as they would be stored, so a list left out and an empty one
+// are the same.
+func chapterContentDiff(existing Chapter, req SaveChapterRequest) ([]string, error) {
+	current := SaveChapterRequest{
+		Title:         existing.Title,
+		Description:   existing.Description,
+		VideoURL:      existing.VideoURL,
+		ThumbnailURL:  existing.ThumbnailURL,
+		Thumbnail:     existing.Thumbnail,
+		Duration:      existing.Duration,
+		Order:         existing.Order,
+		Quiz:          existing.Quiz,
+		Prerequisites: existing.Prerequisites,
+		Skills:        existing.Skills,
+		Accessibility: &existing.Accessibility,
+	}
+	// A pass score left out keeps the current one
+	if req.PassScore != nil {
+		current.PassScore = &existing.PassScore
+	}
+
+	from, err := bson.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	to, err := bson.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	t := reflect.TypeOf(req)
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("bson"), ",")
+		if key == "-" {
+			continue
+		}
+		a, errA := bson.Raw(from).LookupErr(key)
+		b, errB := bson.Raw(to).LookupErr(key)
+		if (errA == nil) != (errB == nil) || (errA == nil && !a.Equal(b)) {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			fields = append(fields, name)
+		}
+	}
+	return fields, nil
+}
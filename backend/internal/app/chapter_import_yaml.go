@@ -0,0 +1,17 @@
+package app
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlToJSON converts a YAML document to JSON, so YAML chapter and config
+// files go through the same decoding as JSON ones
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,1084 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// CHAPTER ADMIN MODELS
+// ============================================================================
+
+// Content authors manage chapters here, or import them from files (see
+// chapter_import.go), instead of editing seed/chapters.json.
+// The seeder still owns its own chapters: a forced reseed overwrites admin
+// edits to them, but never touches chapters created through the API.
+
+// chapterIDPattern keeps chapter IDs slug-shaped, like the seed's "chapter_1"
+var chapterIDPattern = regexp.MustCompile(`^[a-z0-9_\-]{2,50}$`)
+
+// SaveChapterRequest is the body of chapter create and update. Update
+// replaces the content fields; prerequisites, skills, accessibility and the
+// pass score keep their current values when left out. A saved draft is
+// stored as the update it will make.
+type SaveChapterRequest struct {
+	ChapterID     string         `bson:"-" json:"chapterId,omitempty"` // create only, generated if empty
+	Title         string         `bson:"title" json:"title"`
+	Description   string         `bson:"description" json:"description"`
+	VideoURL      string         `bson:"video_url" json:"videoUrl"`
+	ThumbnailURL  string         `bson:"thumbnail_url" json:"thumbnailUrl"`
+	Thumbnail     *ImageRef      `bson:"thumbnail,omitempty" json:"thumbnail,omitempty"`
+	Duration      int            `bson:"duration" json:"duration"` // in seconds
+	Order         int            `bson:"order" json:"order"`
+	Quiz          Quiz           `bson:"quiz" json:"quiz"`
+	PassScore     *int           `bson:"pass_score,omitempty" json:"passScore,omitempty"` // percent; defaultPassScore if never set
+	Prerequisites []string       `bson:"prerequisites,omitempty" json:"prerequisites,omitempty"`
+	Skills        []string       `bson:"skills,omitempty" json:"skills,omitempty"`
+	Accessibility *Accessibility `bson:"accessibility,omitempty" json:"accessibility,omitempty"`
+	CourseIDs     []string       `bson:"-" json:"courseIds,omitempty"` // create only, courses to add the chapter to; the default course if empty
+}
+
+// ChapterDraft is unpublished chapter content. Learners keep seeing the
+// published content until PublishChapter replaces it with the draft.
+type ChapterDraft struct {
+	SaveChapterRequest `bson:",inline"`
+	SavedAt            time.Time `bson:"saved_at" json:"savedAt"`
+	BaseVersion        int       `bson:"base_version" json:"baseVersion"` // published version the draft was saved over
+}
+
+// ============================================================================
+// CHAPTER ADMIN HANDLERS
+// ============================================================================
+
+// CreateChapter adds a chapter and appends it to courses, by default the
+// organization's starter course
+func CreateChapter(w http.ResponseWriter, r *http.Request) {
+	var req SaveChapterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.ChapterID = strings.TrimSpace(req.ChapterID)
+	if req.ChapterID == "" {
+		req.ChapterID = "chapter_" + primitive.NewObjectID().Hex()
+	}
+
+	ctx := r.Context()
+
+	if len(req.CourseIDs) == 0 {
+		req.CourseIDs = []string{defaultCourseFor(orgID(ctx))}
+	}
+
+	var errs fieldErrors
+	if !chapterIDPattern.MatchString(req.ChapterID) {
+		errs.add("chapterId", CodeInvalid, "must be 2-50 lowercase letters, digits, dashes or underscores")
+	}
+	if err := validateChapter(ctx, &errs, &req, nil, nil); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	for i, courseID := range req.CourseIDs {
+		if _, err := findCourse(ctx, courseID); err == mongo.ErrNoDocuments {
+			errs.add(fmt.Sprintf("courseIds[%d]", i), CodeUnknownValue, "is not a known course")
+		} else if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	chapter, err := createChapter(ctx, req)
+	if mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "A chapter with this ID already exists")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create chapter")
+		return
+	}
+
+	log.Printf("✅ Chapter created: %s", chapter.ChapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter created successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// UpdateChapter replaces a chapter's content, publishing it straight away.
+// Skill tags on questions whose ID is kept survive unless the request sets
+// new ones. Learners' saved answers and scores are left as they are.
+func UpdateChapter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var req SaveChapterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.ChapterID = chapterID
+
+	ctx := r.Context()
+
+	var existing Chapter
+	err := chaptersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID})).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	errs, err := prepareChapterUpdate(ctx, &req, existing, nil)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	chapter, err := publishChapterContent(ctx, existing, req, false)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update chapter")
+		return
+	}
+
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditChapterUpdated,
+		Target:  AuditTarget{Type: "chapter", ID: chapterID},
+		Changes: auditDiff(existing, chapter),
+	})
+	log.Printf("✅ Chapter updated: %s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter updated successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetChapterDraft returns a chapter's unpublished draft
+func GetChapterDraft(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var chapter Chapter
+	err := chaptersCol.FindOne(r.Context(), tenantFilter(r.Context(), bson.M{"chapter_id": chapterID}),
+		options.FindOne().SetProjection(bson.M{"draft": 1})).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if chapter.Draft == nil {
+		sendError(w, http.StatusNotFound, "Chapter has no draft")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter draft fetched successfully",
+		Data:    chapter.Draft,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// SaveChapterDraft stores new chapter content without showing it to
+// learners, replacing any earlier draft. It takes the update body and is
+// validated the same way, so a draft that saves will publish unless the
+// chapters or skills around it change first.
+func SaveChapterDraft(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var req SaveChapterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.ChapterID = chapterID
+
+	ctx := r.Context()
+
+	var existing Chapter
+	err := chaptersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID})).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	errs, err := prepareChapterUpdate(ctx, &req, existing, nil)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	draft := ChapterDraft{
+		SaveChapterRequest: req,
+		SavedAt:            time.Now(),
+		BaseVersion:        existing.Version,
+	}
+	result, err := chaptersCol.UpdateOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}),
+		bson.M{"$set": bson.M{"draft": draft}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to save draft")
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	}
+	log.Printf("✅ Chapter draft saved: %s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter draft saved successfully",
+		Data:    draft,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// DiscardChapterDraft throws away a chapter's draft
+func DiscardChapterDraft(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	result, err := chaptersCol.UpdateOne(ctx,
+		tenantFilter(ctx, bson.M{"chapter_id": chapterID, "draft": bson.M{"$exists": true}}),
+		bson.M{"$unset": bson.M{"draft": ""}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to discard draft")
+		return
+	}
+	if result.MatchedCount == 0 {
+		count, err := chaptersCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}))
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+		} else if count > 0 {
+			sendError(w, http.StatusNotFound, "Chapter has no draft")
+		} else {
+			sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		}
+		return
+	}
+	log.Printf("🗑️ Chapter draft discarded: %s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter draft discarded successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// PublishChapter replaces a chapter's published content with its draft.
+// The draft is validated again against the current chapters and skills.
+// It is a 409 when the chapter was updated directly after the draft was
+// saved, unless ?force=true, since publishing would undo that update.
+func PublishChapter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+	force := r.URL.Query().Get("force") == "true"
+
+	ctx := r.Context()
+
+	var existing Chapter
+	err := chaptersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID})).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if existing.Draft == nil {
+		sendError(w, http.StatusConflict, "Chapter has no draft")
+		return
+	}
+	if existing.Draft.BaseVersion != existing.Version && !force {
+		sendError(w, http.StatusConflict, "Chapter was updated after the draft was saved")
+		return
+	}
+
+	req := existing.Draft.SaveChapterRequest
+	req.ChapterID = chapterID
+	errs, err := prepareChapterUpdate(ctx, &req, existing, nil)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	chapter, err := publishChapterContent(ctx, existing, req, true)
+	if err == mongo.ErrNoDocuments {
+		// Published or discarded by someone else in the meantime
+		sendError(w, http.StatusConflict, "Chapter has no draft")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to publish chapter")
+		return
+	}
+
+	existing.Draft = nil
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditChapterPublished,
+		Target:  AuditTarget{Type: "chapter", ID: chapterID},
+		Changes: auditDiff(existing, chapter),
+	})
+	log.Printf("✅ Chapter published: %s (version %d)", chapterID, chapter.Version)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter published successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetArchivedChapters lists archived chapters, most recently archived first
+func GetArchivedChapters(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cursor, err := chaptersCol.Find(ctx, tenantFilter(ctx, bson.M{"archived": true}),
+		options.Find().SetSort(bson.D{{Key: "archived_at", Value: -1}, {Key: "chapter_id", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch chapters")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	chapters := []Chapter{}
+	if err := cursor.All(ctx, &chapters); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode chapters")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Archived chapters fetched successfully",
+		Data:    chapters,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ArchiveChapter hides a chapter from learners and takes it out of every
+// course, remembering where it was so RestoreChapter can put it back. It is
+// refused while other chapters or learning paths depend on it. Learners'
+// progress and the chapter's subtitles are kept.
+func ArchiveChapter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	var existing Chapter
+	err := chaptersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID})).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if existing.Archived {
+		sendError(w, http.StatusConflict, "Chapter is already archived")
+		return
+	}
+
+	count, err := chaptersCol.CountDocuments(ctx, liveChapters(ctx, bson.M{"prerequisites": chapterID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count > 0 {
+		sendError(w, http.StatusConflict, "Chapter is a prerequisite of other chapters")
+		return
+	}
+	count, err = pathsCol.CountDocuments(ctx, bson.M{"chapter_ids": chapterID})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count > 0 {
+		sendError(w, http.StatusConflict, "Chapter is part of a learning path")
+		return
+	}
+
+	// Every organization's courses, not just the caller's
+	cursor, err := coursesCol.Find(ctx, bson.M{"chapter_ids": chapterID},
+		options.Find().SetProjection(bson.M{"course_id": 1, "chapter_ids": 1}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	var courses []Course
+	err = cursor.All(ctx, &courses)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	archivedFrom := make([]ArchivedCourse, 0, len(courses))
+	for _, course := range courses {
+		archivedFrom = append(archivedFrom, ArchivedCourse{
+			CourseID: course.CourseID,
+			Position: slices.Index(course.ChapterIDs, chapterID),
+		})
+	}
+
+	now := time.Now()
+	var chapter Chapter
+	err = chaptersCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"chapter_id": chapterID, "archived": bson.M{"$ne": true}}),
+		bson.M{"$set": bson.M{
+			"archived":      true,
+			"archived_at":   now,
+			"archived_from": archivedFrom,
+			"updated_at":    now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusConflict, "Chapter is already archived")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to archive chapter")
+		return
+	}
+
+	_, err = coursesCol.UpdateMany(ctx, bson.M{"chapter_ids": chapterID}, bson.M{
+		"$pull": bson.M{"chapter_ids": chapterID},
+		"$set":  bson.M{"updated_at": now},
+	})
+	if err != nil {
+		log.Printf("❌ Error removing chapter %s from courses: %v", chapterID, err)
+	}
+
+	chaptersChanged(ctx, chapterID)
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditChapterArchived,
+		Target:  AuditTarget{Type: "chapter", ID: chapterID},
+		Changes: auditDiff(existing, chapter),
+	})
+	log.Printf("✅ Chapter archived: %s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter archived successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RestoreChapter shows an archived chapter to learners again and puts it
+// back where it was in the courses it was archived from, those that still
+// exist. Its prerequisites must not be archived.
+func RestoreChapter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	var existing Chapter
+	err := chaptersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID})).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !existing.Archived {
+		sendError(w, http.StatusConflict, "Chapter isn't archived")
+		return
+	}
+
+	if len(existing.Prerequisites) > 0 {
+		count, err := chaptersCol.CountDocuments(ctx, liveChapters(ctx, bson.M{"chapter_id": bson.M{"$in": existing.Prerequisites}}))
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if int(count) < len(uniqueStrings(existing.Prerequisites)) {
+			sendError(w, http.StatusConflict, "Restore the chapter's prerequisites first")
+			return
+		}
+	}
+
+	now := time.Now()
+	var chapter Chapter
+	err = chaptersCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"chapter_id": chapterID, "archived": true}),
+		bson.M{
+			"$set":   bson.M{"updated_at": now},
+			"$unset": bson.M{"archived": "", "archived_at": "", "archived_from": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusConflict, "Chapter isn't archived")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to restore chapter")
+		return
+	}
+
+	for _, from := range existing.ArchivedFrom {
+		// $position past the end appends
+		_, err := coursesCol.UpdateOne(ctx,
+			bson.M{"course_id": from.CourseID, "chapter_ids": bson.M{"$ne": chapterID}},
+			bson.M{
+				"$push": bson.M{"chapter_ids": bson.M{"$each": bson.A{chapterID}, "$position": max(from.Position, 0)}},
+				"$set":  bson.M{"updated_at": now},
+			})
+		if err != nil {
+			log.Printf("❌ Error restoring chapter %s to course %s: %v", chapterID, from.CourseID, err)
+		}
+	}
+
+	chapterWritten(ctx, chapter)
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditChapterRestored,
+		Target:  AuditTarget{Type: "chapter", ID: chapterID},
+		Changes: auditDiff(existing, chapter),
+	})
+	log.Printf("✅ Chapter restored: %s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter restored successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// PurgeChapter deletes an archived chapter and its subtitles for good.
+// Learners' progress is kept; remove it with the chapter progress bulk
+// delete.
+func PurgeChapter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	var chapter Chapter
+	err := chaptersCol.FindOneAndDelete(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID, "archived": true})).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		count, err := chaptersCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}))
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+		} else if count > 0 {
+			sendError(w, http.StatusConflict, "Archive the chapter before purging it")
+		} else {
+			sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		}
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to purge chapter")
+		return
+	}
+
+	if _, err := transcriptsCol.DeleteMany(ctx, bson.M{"chapter_id": chapterID}); err != nil {
+		log.Printf("❌ Error deleting subtitles of chapter %s: %v", chapterID, err)
+	}
+
+	chaptersChanged(ctx, chapterID)
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditChapterPurged,
+		Target:  AuditTarget{Type: "chapter", ID: chapterID},
+		Changes: auditDiff(chapter, nil),
+	})
+	log.Printf("✅ Chapter purged: %s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter purged successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// CHAPTER VALIDATION
+// ============================================================================
+
+// liveChapters limits a chapter filter to the chapters learners of the
+// request's organization see: those that aren't archived, from the shared
+// catalog or the organization's own
+func liveChapters(ctx context.Context, filter bson.M) bson.M {
+	filter["archived"] = bson.M{"$ne": true}
+	return sharedOrOwn(ctx, filter)
+}
+
+// sharedOrOwn scopes a query on content organizations may add to, chapters
+// and bank questions, to the shared catalog and the request's organization's
+// own. Background jobs and platform admins without X-Org-ID see all of it.
+func sharedOrOwn(ctx context.Context, filter bson.M) bson.M {
+	return sharedOrOrg(tenantOrgFilter(ctx), filter)
+}
+
+// sharedOrOrg scopes filter to the shared catalog and orgID's own content,
+// or to everything when orgID is empty
+func sharedOrOrg(orgID string, filter bson.M) bson.M {
+	if orgID != "" {
+		filter["org_id"] = bson.M{"$in": bson.A{orgID, nil}}
+	}
+	return filter
+}
+
+// sharedOrOwnChapter is sharedOrOwn for a chapter already loaded
+func sharedOrOwnChapter(ctx context.Context, chapter Chapter) bool {
+	org := tenantOrgFilter(ctx)
+	return org == "" || chapter.OrgID == "" || chapter.OrgID == org
+}
+
+// checkOwnChapter sends a 404 and returns false unless the chapter exists
+// and the request's organization may edit it
+func checkOwnChapter(ctx context.Context, w http.ResponseWriter, chapterID string) bool {
+	count, err := chaptersCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if count == 0 {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return false
+	}
+	return true
+}
+
+// ownsChapter reports whether the request's organization may edit chapter.
+// Org admins and instructors edit their organization's chapters; the shared
+// catalog is left to platform admins without X-Org-ID.
+func ownsChapter(ctx context.Context, chapter Chapter) bool {
+	org := tenantOrgFilter(ctx)
+	return org == "" || chapter.OrgID == org
+}
+
+// prepareChapterUpdate fills in the fields an update leaves out from the
+// existing chapter and validates the result. Skill tags on questions whose
+// ID is kept carry over unless req sets new ones. others is passed on to
+// validateChapter.
+func prepareChapterUpdate(ctx context.Context, req *SaveChapterRequest, existing Chapter, others chapterBatch) (fieldErrors, error) {
+	if req.Prerequisites == nil {
+		req.Prerequisites = existing.Prerequisites
+	}
+	if req.Skills == nil {
+		req.Skills = existing.Skills
+	}
+	if req.Accessibility == nil {
+		req.Accessibility = &existing.Accessibility
+	}
+
+	var errs fieldErrors
+	if err := validateChapter(ctx, &errs, req, &existing, others); err != nil {
+		return nil, err
+	}
+
+	questionSkills := map[string][]string{}
+	for _, q := range existing.Quiz.Questions {
+		questionSkills[q.ID] = q.Skills
+	}
+	for i, q := range req.Quiz.Questions {
+		if q.Skills == nil {
+			req.Quiz.Questions[i].Skills = questionSkills[q.ID]
+		}
+	}
+	return errs, nil
+}
+
+// createChapter inserts a validated new chapter and appends it to the
+// request's courses
+func createChapter(ctx context.Context, req SaveChapterRequest) (Chapter, error) {
+	now := time.Now()
+	chapter := Chapter{
+		PublicID:      newPublicID(),
+		OrgID:         tenantOrgFilter(ctx),
+		ChapterID:     req.ChapterID,
+		Title:         req.Title,
+		Description:   req.Description,
+		VideoURL:      req.VideoURL,
+		ThumbnailURL:  req.ThumbnailURL,
+		Thumbnail:     req.Thumbnail,
+		Duration:      req.Duration,
+		Quiz:          req.Quiz,
+		Order:         req.Order,
+		Prerequisites: req.Prerequisites,
+		Skills:        req.Skills,
+		Accessibility: Accessibility{CaptionLanguages: []string{}, ContentWarnings: []string{}},
+		UpdatedAt:     &now,
+		Version:       1,
+		PublishedAt:   &now,
+	}
+	if req.Accessibility != nil {
+		chapter.Accessibility = *req.Accessibility
+	}
+	chapter.PassScore = defaultPassScore
+	if req.PassScore != nil {
+		chapter.PassScore = *req.PassScore
+	}
+
+	if _, err := chaptersCol.InsertOne(ctx, chapter); err != nil {
+		return chapter, err
+	}
+
+	_, err := coursesCol.UpdateMany(ctx,
+		tenantFilter(ctx, bson.M{"course_id": bson.M{"$in": req.CourseIDs}}),
+		bson.M{
+			"$addToSet": bson.M{"chapter_ids": chapter.ChapterID},
+			"$set":      bson.M{"updated_at": time.Now()},
+		})
+	if err != nil {
+		log.Printf("❌ Error adding chapter %s to courses: %v", chapter.ChapterID, err)
+	}
+
+	chapterWritten(ctx, chapter)
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditChapterCreated,
+		Target:  AuditTarget{Type: "chapter", ID: chapter.ChapterID},
+		Changes: auditDiff(nil, chapter),
+	})
+	return chapter, nil
+}
+
+// publishChapterContent writes a validated update as the chapter's published
+// content and bumps its version. fromDraft also clears the draft, and only
+// matches while the chapter still has one.
+func publishChapterContent(ctx context.Context, existing Chapter, req SaveChapterRequest, fromDraft bool) (Chapter, error) {
+	now := time.Now()
+	set := bson.M{
+		"title":         req.Title,
+		"description":   req.Description,
+		"video_url":     req.VideoURL,
+		"thumbnail_url": req.ThumbnailURL,
+		"duration":      req.Duration,
+		"order":         req.Order,
+		"quiz":          req.Quiz,
+		"prerequisites": req.Prerequisites,
+		"skills":        req.Skills,
+		"accessibility": req.Accessibility,
+		"published_at":  now,
+		"updated_at":    now,
+	}
+	if req.PassScore != nil {
+		set["pass_score"] = *req.PassScore
+	}
+	unset := bson.M{}
+	if req.Thumbnail != nil {
+		set["thumbnail"] = req.Thumbnail
+	} else {
+		unset["thumbnail"] = ""
+	}
+	filter := tenantFilter(ctx, bson.M{"chapter_id": existing.ChapterID})
+	if fromDraft {
+		filter["draft"] = bson.M{"$exists": true}
+		unset["draft"] = ""
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var chapter Chapter
+	err := chaptersCol.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&chapter)
+	if err != nil {
+		return chapter, err
+	}
+	chapterWritten(ctx, chapter)
+	return chapter, nil
+}
+
+// chapterBatch is the other chapters saved along with one in an import, by
+// chapter ID, with the order and prerequisites they are being given
+type chapterBatch map[string]SaveChapterRequest
+
+// validateChapter normalizes a chapter request and adds a field error for
+// every problem. existing is the chapter being updated, or nil on create.
+// others are checked as already saved, so orders and prerequisites may
+// refer to them. It only returns an error when the database can't be
+// checked.
+func validateChapter(ctx context.Context, errs *fieldErrors, req *SaveChapterRequest, existing *Chapter, others chapterBatch) error {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Description = strings.TrimSpace(req.Description)
+	req.VideoURL = strings.TrimSpace(req.VideoURL)
+	req.ThumbnailURL = strings.TrimSpace(req.ThumbnailURL)
+	if req.Prerequisites == nil {
+		req.Prerequisites = []string{}
+	}
+	if req.Skills == nil {
+		req.Skills = []string{}
+	}
+
+	errs.required("title", req.Title)
+	errs.required("videoUrl", req.VideoURL)
+	if req.VideoURL != "" && !isMediaURL(req.VideoURL) {
+		errs.add("videoUrl", CodeInvalid, "must be an absolute http or https URL")
+	}
+	if req.ThumbnailURL != "" && !isMediaURL(req.ThumbnailURL) {
+		errs.add("thumbnailUrl", CodeInvalid, "must be an absolute http or https URL")
+	}
+	if req.Duration <= 0 {
+		errs.add("duration", CodeOutOfRange, "must be a positive number of seconds")
+	}
+	if req.Order <= 0 {
+		errs.add("order", CodeOutOfRange, "must be a positive integer")
+	}
+	validateQuiz(errs, req.Quiz)
+	if req.PassScore != nil && (*req.PassScore < 1 || *req.PassScore > 100) {
+		errs.add("passScore", CodeOutOfRange, "must be a percent between 1 and 100")
+	}
+	if req.Accessibility != nil {
+		if req.Accessibility.CaptionLanguages == nil {
+			req.Accessibility.CaptionLanguages = []string{}
+		}
+		if req.Accessibility.ContentWarnings == nil {
+			req.Accessibility.ContentWarnings = []string{}
+		}
+		if err := validateAccessibility(*req.Accessibility); err != nil {
+			errs.add("accessibility", CodeInvalid, err.Error())
+		}
+	}
+
+	// The organization the chapter belongs to, "" for the shared catalog
+	owner := tenantOrgFilter(ctx)
+	if existing != nil {
+		owner = existing.OrgID
+	}
+
+	// Order is unique among the chapters any one organization sees, so its
+	// chapter list has one sequence
+	if req.Order > 0 {
+		var saved []string
+		if existing != nil {
+			saved = append(saved, existing.ChapterID)
+		}
+		for _, id := range others.ids() {
+			saved = append(saved, id)
+			if others[id].Order == req.Order {
+				errs.add("order", CodeInvalid, "is already used by chapter "+id)
+			}
+		}
+		filter := bson.M{"order": req.Order}
+		if len(saved) > 0 {
+			filter["chapter_id"] = bson.M{"$nin": saved}
+		}
+		var clash Chapter
+		err := chaptersCol.FindOne(ctx, sharedOrOrg(owner, filter)).Decode(&clash)
+		if err == nil {
+			errs.add("order", CodeInvalid, "is already used by chapter "+clash.ChapterID)
+		} else if err != mongo.ErrNoDocuments {
+			return err
+		}
+	}
+
+	deps, err := chapterDependencies(ctx, owner)
+	if err != nil {
+		return err
+	}
+	for id, other := range others {
+		deps[id] = other.Prerequisites
+	}
+	for i, prereq := range req.Prerequisites {
+		if _, ok := deps[prereq]; !ok || prereq == req.ChapterID {
+			errs.add(fmt.Sprintf("prerequisites[%d]", i), CodeUnknownValue, "is not another known chapter")
+		}
+	}
+	deps[req.ChapterID] = req.Prerequisites
+	if cycle := findCycle(deps); cycle != nil {
+		errs.add("prerequisites", CodeInvalid, "would create a cycle: "+strings.Join(cycle, " -> "))
+	}
+
+	skills, err := allSkills(ctx)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(skills))
+	for _, skill := range skills {
+		known[skill.SkillID] = true
+	}
+	for i, id := range req.Skills {
+		if !known[id] {
+			errs.add(fmt.Sprintf("skills[%d]", i), CodeUnknownValue, "is not a known skill")
+		}
+	}
+	for i, q := range req.Quiz.Questions {
+		for j, id := range q.Skills {
+			if !known[id] {
+				errs.add(fmt.Sprintf("quiz.questions[%d].skills[%d]", i, j), CodeUnknownValue, "is not a known skill")
+			}
+		}
+	}
+
+	if err := checkImageRef(ctx, errs, "thumbnail.uploadId", req.Thumbnail, UploadChapterThumbnail, ""); err != nil {
+		return err
+	}
+	for i := range req.Quiz.Questions {
+		field := fmt.Sprintf("quiz.questions[%d].image.uploadId", i)
+		if err := checkImageRef(ctx, errs, field, req.Quiz.Questions[i].Image, UploadQuestionImage, ""); err != nil {
+			return err
+		}
+	}
+
+	// A draw needs enough questions in the bank to fill it
+	if draw := req.Quiz.Draw; draw != nil && bankIDPattern.MatchString(draw.BankID) && draw.Count > 0 {
+		available, err := questionBankCol.CountDocuments(ctx, chapterDrawFilter(owner, *draw))
+		if err != nil {
+			return err
+		}
+		if available < int64(draw.Count) {
+			errs.add("quiz.draw.count", CodeOutOfRange, fmt.Sprintf("is more than the %d matching questions in bank %s", available, draw.BankID))
+		}
+	}
+
+	return nil
+}
+
+// ids lists the batch's chapter IDs in order
+func (b chapterBatch) ids() []string {
+	ids := make([]string, 0, len(b))
+	for id := range b {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// maxExplanationLength bounds a question's explanation, in characters
+const maxExplanationLength = 2000
+
+// validateQuiz checks a quiz's structure: either a draw from a question bank,
+// or at least one question with unique question IDs, each valid on its own
+func validateQuiz(errs *fieldErrors, quiz Quiz) {
+	if quiz.Draw != nil {
+		if len(quiz.Questions) > 0 {
+			errs.add("quiz.questions", CodeInvalid, "must be empty when the quiz draws from a question bank")
+		}
+		validateQuizDraw(errs, *quiz.Draw)
+		return
+	}
+	if len(quiz.Questions) == 0 {
+		errs.add("quiz.questions", CodeRequired, "must have at least one question")
+		return
+	}
+
+	ids := map[string]bool{}
+	for i := range quiz.Questions {
+		field := fmt.Sprintf("quiz.questions[%d]", i)
+		validateQuestion(errs, field, &quiz.Questions[i])
+		if id := quiz.Questions[i].ID; id != "" {
+			if ids[id] {
+				errs.add(field+".id", CodeInvalid, "must be unique within the quiz")
+			}
+			ids[id] = true
+		}
+	}
+}
+
+// validateQuestion checks and normalizes one question: a known type with an
+// answer key that fits it, meaning distinct options and correct answers that
+// point at them, or for fill-in questions at least one accepted answer.
+// field names the question, e.g. "quiz.questions[2]".
+func validateQuestion(errs *fieldErrors, field string, q *Question) {
+	q.ID = strings.TrimSpace(q.ID)
+	errs.required(field+".id", q.ID)
+	errs.required(field+".questionText", q.QuestionText)
+	if len([]rune(q.Explanation)) > maxExplanationLength {
+		errs.add(field+".explanation", CodeInvalid, fmt.Sprintf("must be at most %d characters", maxExplanationLength))
+	}
+
+	switch q.Type {
+	case "", QuestionSingleChoice, QuestionMultiSelect, QuestionFillIn:
+	case QuestionTrueFalse:
+		// The options are always the same, so authors may leave them out
+		if len(q.Options) == 0 {
+			q.Options = append([]string{}, trueFalseOptions...)
+		}
+	default:
+		errs.add(field+".type", CodeUnknownValue, "must be one of single_choice, multi_select, true_false, fill_in")
+		return
+	}
+
+	if q.kind() == QuestionFillIn {
+		if len(q.Options) > 0 {
+			errs.add(field+".options", CodeInvalid, "must be empty for a fill-in question")
+		}
+		q.Options = []string{}
+		accepted := 0
+		for _, answer := range q.AcceptedAnswers {
+			if normalizeAnswerText(answer) != "" {
+				accepted++
+			}
+		}
+		if accepted == 0 {
+			errs.add(field+".acceptedAnswers", CodeRequired, "must have at least one answer")
+		}
+		return
+	}
+
+	if q.kind() == QuestionTrueFalse && (len(q.Options) != 2 || q.Options[0] != trueFalseOptions[0] || q.Options[1] != trueFalseOptions[1]) {
+		errs.add(field+".options", CodeInvalid, `must be "True" and "False"`)
+	}
+	if len(q.Options) < 2 {
+		errs.add(field+".options", CodeInvalid, "must have at least two options")
+	}
+	options := map[string]bool{}
+	for j, option := range q.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			errs.add(fmt.Sprintf("%s.options[%d]", field, j), CodeRequired, "is required")
+		} else if options[option] {
+			errs.add(fmt.Sprintf("%s.options[%d]", field, j), CodeInvalid, "duplicates another option")
+		}
+		options[option] = true
+	}
+
+	if q.kind() == QuestionMultiSelect {
+		if len(q.CorrectAnswers) == 0 {
+			errs.add(field+".correctAnswers", CodeRequired, "must have at least one correct option")
+		}
+		correct := map[int]bool{}
+		for j, answer := range q.CorrectAnswers {
+			if answer < 0 || answer >= len(q.Options) || correct[answer] {
+				errs.add(fmt.Sprintf("%s.correctAnswers[%d]", field, j), CodeOutOfRange, "must be the index of a different option")
+			}
+			correct[answer] = true
+		}
+		return
+	}
+	if q.CorrectAnswer < 0 || q.CorrectAnswer >= len(q.Options) {
+		errs.add(field+".correctAnswer", CodeOutOfRange, "must be the index of one of the options")
+	}
+}
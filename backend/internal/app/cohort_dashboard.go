@@ -0,0 +1,291 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// COHORT DASHBOARD MODELS
+// ============================================================================
+
+// The dashboard is a grid of a cohort's learners against its course's
+// chapters, for teachers to see at a glance where each learner is. Rows are
+// paginated by learner, and ?format=csv downloads the grid for a
+// spreadsheet.
+
+// ExportFormatCSV downloads a listing as a CSV file
+const ExportFormatCSV = "csv"
+
+// Cell statuses of the progress matrix
+const (
+	CellNotStarted = "not_started"
+	CellInProgress = "in_progress"
+	CellCompleted  = "completed"
+)
+
+// ProgressMatrix is a cohort's learners × chapters
+type ProgressMatrix struct {
+	Cohort   Cohort          `json:"cohort"`
+	Chapters []MatrixChapter `json:"chapters"` // the columns, in course order
+	Learners []MatrixLearner `json:"learners"` // the rows, by name
+}
+
+// MatrixChapter is a column of the progress matrix
+type MatrixChapter struct {
+	ChapterID string `json:"chapterId"`
+	Title     string `json:"title"`
+}
+
+// MatrixLearner is a row of the progress matrix
+type MatrixLearner struct {
+	UserID            string       `json:"userId"`
+	Name              string       `json:"name"`
+	CompletedChapters int          `json:"completedChapters"`
+	Percent           int          `json:"percent"`
+	LastActiveAt      *time.Time   `json:"lastActiveAt,omitempty"`
+	Cells             []MatrixCell `json:"cells"` // one per chapter, in column order
+}
+
+// MatrixCell is a learner's progress in one chapter
+type MatrixCell struct {
+	Status         string     `json:"status"`              // see the Cell constants
+	QuizScore      *int       `json:"quizScore,omitempty"` // percent, of the last finished attempt
+	QuizPassed     bool       `json:"quizPassed"`
+	LastAccessedAt *time.Time `json:"lastAccessedAt,omitempty"`
+}
+
+// ============================================================================
+// COHORT DASHBOARD HANDLERS
+// ============================================================================
+
+// GetCohortProgressMatrix returns a cohort's progress matrix. The cohort is
+// named by its id, so dashboards can link to it without the course.
+// ?limit= and ?offset= page through the learners; ?format=csv downloads
+// the page as CSV.
+func GetCohortProgressMatrix(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	publicID := strings.ToLower(vars["cohortId"])
+
+	var errs fieldErrors
+	page := parseOffsetPage(r, &errs)
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportFormatJSON
+	}
+	if format != ExportFormatJSON && format != ExportFormatCSV {
+		errs.add("format", CodeInvalid, "must be json or csv")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	var cohort Cohort
+	err := cohortsCol.FindOne(ctx, tenantFilter(ctx, bson.M{"public_id": publicID})).Decode(&cohort)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Cohort not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	members, err := cohortMembers(ctx, cohort)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch cohort members")
+		return
+	}
+	total := len(members)
+	start, end := page.bounds(total)
+	members = members[start:end]
+
+	matrix, err := progressMatrix(ctx, cohort, members)
+	if err != nil {
+		log.Printf("❌ Error building progress matrix for cohort %s: %v", cohort.PublicID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to build cohort report")
+		return
+	}
+
+	if format == ExportFormatCSV {
+		body, err := matrixCSV(matrix)
+		if err != nil {
+			log.Printf("❌ Error writing CSV for cohort %s: %v", cohort.PublicID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to build cohort report")
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s-progress.csv"`, cohort.CourseID, cohort.CohortID))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort progress fetched successfully",
+		Data:    matrix,
+		Meta:    page.meta(int64(total)),
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// COHORT DASHBOARD HELPERS
+// ============================================================================
+
+// progressMatrix builds the matrix rows of the given members of a cohort
+func progressMatrix(ctx context.Context, cohort Cohort, members []CohortMember) (ProgressMatrix, error) {
+	matrix := ProgressMatrix{Cohort: cohort, Chapters: []MatrixChapter{}, Learners: []MatrixLearner{}}
+
+	course, err := findCourse(ctx, cohort.CourseID)
+	if err != nil {
+		return matrix, err
+	}
+	chapterIDs := uniqueStrings(course.ChapterIDs)
+
+	cursor, err := chaptersCol.Find(ctx, liveChapters(ctx, bson.M{"chapter_id": bson.M{"$in": chapterIDs}}),
+		options.Find().SetProjection(bson.M{"chapter_id": 1, "title": 1}))
+	if err != nil {
+		return matrix, err
+	}
+	var chapters []Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return matrix, err
+	}
+	titles := make(map[string]string, len(chapters))
+	for _, chapter := range chapters {
+		titles[chapter.ChapterID] = chapter.Title
+	}
+	column := make(map[string]int, len(chapterIDs))
+	for _, id := range chapterIDs {
+		if _, ok := titles[id]; !ok {
+			continue
+		}
+		column[id] = len(matrix.Chapters)
+		matrix.Chapters = append(matrix.Chapters, MatrixChapter{ChapterID: id, Title: titles[id]})
+	}
+
+	if len(members) == 0 {
+		return matrix, nil
+	}
+	userIDs := make([]string, len(members))
+	row := make(map[string]int, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+		row[member.UserID] = i
+		cells := make([]MatrixCell, len(matrix.Chapters))
+		for j := range cells {
+			cells[j].Status = CellNotStarted
+		}
+		matrix.Learners = append(matrix.Learners, MatrixLearner{UserID: member.UserID, Name: member.Name, Cells: cells})
+	}
+
+	progress, err := cohortProgress(ctx, userIDs, chapterIDs)
+	if err != nil {
+		return matrix, err
+	}
+	for _, p := range progress {
+		j, ok := column[p.ChapterID]
+		if !ok {
+			continue
+		}
+		learner := &matrix.Learners[row[p.UserID]]
+		accessed := p.LastAccessedAt
+		learner.Cells[j] = MatrixCell{
+			Status:         cellStatus(p),
+			QuizScore:      p.QuizScore,
+			QuizPassed:     p.QuizPassed,
+			LastAccessedAt: &accessed,
+		}
+		if p.ChapterCompleted {
+			learner.CompletedChapters++
+		}
+		if learner.LastActiveAt == nil || accessed.After(*learner.LastActiveAt) {
+			learner.LastActiveAt = &accessed
+		}
+	}
+	if n := len(matrix.Chapters); n > 0 {
+		for i := range matrix.Learners {
+			matrix.Learners[i].Percent = matrix.Learners[i].CompletedChapters * 100 / n
+		}
+	}
+	return matrix, nil
+}
+
+// cellStatus sums up a learner's progress in a chapter
+func cellStatus(p Progress) string {
+	switch {
+	case p.ChapterCompleted:
+		return CellCompleted
+	case p.VideoProgress > 0 || p.QuizProgress > 0 || p.QuizCompleted:
+		return CellInProgress
+	default:
+		return CellNotStarted
+	}
+}
+
+// matrixCSV renders a progress matrix with one line per learner and a
+// status and quiz score column per chapter
+func matrixCSV(matrix ProgressMatrix) ([]byte, error) {
+	var buf bytes.Buffer
+	out := csv.NewWriter(&buf)
+
+	header := []string{"user_id", "name", "completed_chapters", "percent", "last_active_at"}
+	for _, chapter := range matrix.Chapters {
+		header = append(header, chapter.ChapterID+" status", chapter.ChapterID+" quiz_score")
+	}
+	if err := out.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, learner := range matrix.Learners {
+		line := []string{
+			learner.UserID,
+			csvText(learner.Name),
+			strconv.Itoa(learner.CompletedChapters),
+			strconv.Itoa(learner.Percent),
+			"",
+		}
+		if learner.LastActiveAt != nil {
+			line[4] = learner.LastActiveAt.UTC().Format(time.RFC3339)
+		}
+		for _, cell := range learner.Cells {
+			score := ""
+			if cell.QuizScore != nil {
+				score = strconv.Itoa(*cell.QuizScore)
+			}
+			line = append(line, cell.Status, score)
+		}
+		if err := out.Write(line); err != nil {
+			return nil, err
+		}
+	}
+
+	out.Flush()
+	return buf.Bytes(), out.Error()
+}
+
+// csvText keeps a learner-written value from being run as a formula when the
+// CSV is opened in a spreadsheet
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,742 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// COHORT MODELS
+// ============================================================================
+
+// A cohort is a class taking a course together: a name, the dates it runs,
+// the instructors teaching it and its learners. A learner's membership is
+// the cohort on their enrollment in the course, so they are in at most one
+// cohort per course, and it also picks the course's release dates for them
+// (see release.go).
+
+// cohortPattern keeps cohort IDs slug-shaped, like "2026-autumn"
+var cohortPattern = regexp.MustCompile(`^[a-z0-9_\-]{1,50}$`)
+
+// maxCohortMembersPerRequest bounds how many learners one request adds
+const maxCohortMembersPerRequest = 500
+
+// Cohort is a class of a course's learners
+type Cohort struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID      string             `bson:"public_id" json:"id"`
+	OrgID         string             `bson:"org_id" json:"-"`
+	CourseID      string             `bson:"course_id" json:"courseId"`
+	CohortID      string             `bson:"cohort_id" json:"cohortId"` // unique within the course
+	Name          string             `bson:"name" json:"name"`
+	StartDate     *time.Time         `bson:"start_date,omitempty" json:"startDate,omitempty"`
+	EndDate       *time.Time         `bson:"end_date,omitempty" json:"endDate,omitempty"`
+	InstructorIDs []string           `bson:"instructor_ids" json:"instructorIds"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
+
+	// Computed for responses
+	Members *int64 `bson:"-" json:"members,omitempty"`
+}
+
+// SaveCohortRequest is the body of cohort create and update
+type SaveCohortRequest struct {
+	CohortID      string     `json:"cohortId,omitempty"` // create only, generated if empty
+	Name          string     `json:"name"`
+	StartDate     *time.Time `json:"startDate"`
+	EndDate       *time.Time `json:"endDate"`
+	InstructorIDs []string   `json:"instructorIds"` // the instructor creating it if left out
+}
+
+type AddCohortMembersRequest struct {
+	UserIDs []string `json:"userIds"`
+}
+
+// CohortMember is a learner in a cohort
+type CohortMember struct {
+	UserID     string    `json:"userId"`
+	Name       string    `json:"name"`
+	EnrolledAt time.Time `json:"enrolledAt"`
+}
+
+// CohortReport is a cohort's progress through its course
+type CohortReport struct {
+	CohortID         string                  `json:"cohortId"`
+	CourseID         string                  `json:"courseId"`
+	TotalChapters    int                     `json:"totalChapters"`
+	Members          int                     `json:"members"`
+	CompletedMembers int                     `json:"completedMembers"` // members who completed every chapter
+	AveragePercent   int                     `json:"averagePercent"`
+	Learners         []CohortLearnerProgress `json:"learners"` // least progress first
+}
+
+// CohortLearnerProgress is one member's row in a cohort report
+type CohortLearnerProgress struct {
+	UserID            string     `json:"userId"`
+	Name              string     `json:"name"`
+	CompletedChapters int        `json:"completedChapters"`
+	Percent           int        `json:"percent"`
+	CompletedAt       *time.Time `json:"completedAt,omitempty"`
+	LastActiveAt      *time.Time `json:"lastActiveAt,omitempty"` // last progress in the course
+}
+
+// ============================================================================
+// COHORT HANDLERS
+// ============================================================================
+
+// GetCohorts lists a course's cohorts by start date, with their member
+// counts. ?instructorId= keeps those an instructor teaches.
+func GetCohorts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+
+	ctx := r.Context()
+
+	filter := tenantFilter(ctx, bson.M{"course_id": courseID})
+	if instructorID := r.URL.Query().Get("instructorId"); instructorID != "" {
+		filter["instructor_ids"] = resolveUserKey(ctx, instructorID)
+	}
+	cursor, err := cohortsCol.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "start_date", Value: 1}, {Key: "cohort_id", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch cohorts")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	cohorts := []Cohort{}
+	if err := cursor.All(ctx, &cohorts); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode cohorts")
+		return
+	}
+	for i := range cohorts {
+		count, err := enrollmentsCol.CountDocuments(ctx, cohortMembersFilter(ctx, cohorts[i]))
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch cohorts")
+			return
+		}
+		cohorts[i].Members = &count
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohorts fetched successfully",
+		Data:    cohorts,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateCohort adds a cohort to a course
+func CreateCohort(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+
+	var req SaveCohortRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.CohortID = strings.TrimSpace(req.CohortID)
+	if req.CohortID == "" {
+		req.CohortID = "cohort_" + primitive.NewObjectID().Hex()
+	}
+
+	ctx := r.Context()
+
+	if _, err := findCourse(ctx, courseID); err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if req.InstructorIDs == nil {
+		if claims, ok := authClaims(ctx); ok && claims.Role == RoleInstructor {
+			req.InstructorIDs = []string{claims.Subject}
+		}
+	}
+
+	var errs fieldErrors
+	if !cohortPattern.MatchString(req.CohortID) {
+		errs.add("cohortId", CodeInvalid, "must be 1-50 lowercase letters, digits, dashes or underscores")
+	}
+	if err := validateCohort(ctx, &errs, &req); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	now := time.Now()
+	cohort := Cohort{
+		PublicID:      newPublicID(),
+		OrgID:         orgID(ctx),
+		CourseID:      courseID,
+		CohortID:      req.CohortID,
+		Name:          req.Name,
+		StartDate:     req.StartDate,
+		EndDate:       req.EndDate,
+		InstructorIDs: req.InstructorIDs,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if _, err := cohortsCol.InsertOne(ctx, cohort); mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "A cohort with this ID already exists in the course")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create cohort")
+		return
+	}
+
+	log.Printf("✅ Cohort created: course=%s, cohort=%s", courseID, cohort.CohortID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort created successfully",
+		Data:    cohort,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// UpdateCohort replaces a cohort's name, dates and instructors
+func UpdateCohort(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	cohortID := vars["cohortId"]
+
+	var req SaveCohortRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.InstructorIDs == nil {
+		req.InstructorIDs = []string{}
+	}
+
+	ctx := r.Context()
+
+	var errs fieldErrors
+	if err := validateCohort(ctx, &errs, &req); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	set := bson.M{
+		"name":           req.Name,
+		"instructor_ids": req.InstructorIDs,
+		"updated_at":     time.Now(),
+	}
+	unset := bson.M{}
+	for field, value := range map[string]*time.Time{"start_date": req.StartDate, "end_date": req.EndDate} {
+		if value != nil {
+			set[field] = *value
+		} else {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var cohort Cohort
+	err := cohortsCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"course_id": courseID, "cohort_id": cohortID}), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&cohort)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Cohort not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update cohort")
+		return
+	}
+
+	log.Printf("✅ Cohort updated: course=%s, cohort=%s", courseID, cohortID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort updated successfully",
+		Data:    cohort,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// DeleteCohort removes a cohort. Its learners stay enrolled in the course
+// without a cohort, and the course's release dates for it are dropped.
+func DeleteCohort(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	cohortID := vars["cohortId"]
+
+	ctx := r.Context()
+
+	var cohort Cohort
+	err := cohortsCol.FindOneAndDelete(ctx,
+		tenantFilter(ctx, bson.M{"course_id": courseID, "cohort_id": cohortID})).Decode(&cohort)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Cohort not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to delete cohort")
+		return
+	}
+
+	// Every enrollment that names it, active or not
+	result, err := enrollmentsCol.UpdateMany(ctx,
+		tenantFilter(ctx, bson.M{"course_id": courseID, "cohort": cohortID}),
+		bson.M{"$unset": bson.M{"cohort": ""}})
+	if err != nil {
+		log.Printf("❌ Error removing learners from cohort %s/%s: %v", courseID, cohortID, err)
+	}
+	_, err = coursesCol.UpdateOne(ctx, tenantFilter(ctx, bson.M{"course_id": courseID}), bson.M{
+		"$pull": bson.M{"releases": bson.M{"cohort": cohortID}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		log.Printf("❌ Error dropping release dates of cohort %s/%s: %v", courseID, cohortID, err)
+	}
+
+	var removed int64
+	if result != nil {
+		removed = result.ModifiedCount
+	}
+	log.Printf("🗑️ Cohort deleted: course=%s, cohort=%s, members=%d", courseID, cohortID, removed)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort deleted successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetCohortMembers lists a cohort's learners by name
+func GetCohortMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cohort, ok := loadCohort(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := cohortMembers(ctx, *cohort)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch cohort members")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort members fetched successfully",
+		Data:    members,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// AddCohortMembers puts learners in a cohort, enrolling those who aren't
+// enrolled in the course yet. Learners in another cohort of the course move
+// to this one.
+func AddCohortMembers(w http.ResponseWriter, r *http.Request) {
+	var req AddCohortMembersRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	if len(req.UserIDs) == 0 {
+		errs.add("userIds", CodeRequired, "must have at least one user")
+	} else if len(req.UserIDs) > maxCohortMembersPerRequest {
+		errs.add("userIds", CodeOutOfRange, fmt.Sprintf("must have at most %d users", maxCohortMembersPerRequest))
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	cohort, ok := loadCohort(w, r)
+	if !ok {
+		return
+	}
+	course, err := findCourse(ctx, cohort.CourseID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	userIDs := make([]string, len(req.UserIDs))
+	for i, id := range req.UserIDs {
+		userIDs[i] = resolveUserKey(ctx, strings.TrimSpace(id))
+	}
+	known, err := usersCol.Distinct(ctx, "user_id", tenantFilter(ctx, bson.M{"user_id": bson.M{"$in": userIDs}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	exists := make(map[string]bool, len(known))
+	for _, id := range known {
+		if s, ok := id.(string); ok {
+			exists[s] = true
+		}
+	}
+	for i, id := range userIDs {
+		if !exists[id] {
+			errs.add(fmt.Sprintf("userIds[%d]", i), CodeUnknownValue, "is not a user of this organization")
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	userIDs = uniqueStrings(userIDs)
+	for _, userID := range userIDs {
+		if _, err := enrollUser(ctx, userID, course.CourseID, EnrollmentSourceAdmin, course.AccessDays); err != nil {
+			log.Printf("❌ Error enrolling user %s for cohort %s: %v", userID, cohort.CohortID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to enroll")
+			return
+		}
+	}
+	_, err = enrollmentsCol.UpdateMany(ctx,
+		tenantFilter(ctx, bson.M{"course_id": course.CourseID, "user_id": bson.M{"$in": userIDs}}),
+		bson.M{"$set": bson.M{"cohort": cohort.CohortID}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to add cohort members")
+		return
+	}
+
+	log.Printf("✅ Cohort members added: course=%s, cohort=%s, users=%d", course.CourseID, cohort.CohortID, len(userIDs))
+
+	members, err := cohortMembers(ctx, *cohort)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch cohort members")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort members added successfully",
+		Data:    members,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RemoveCohortMember takes a learner out of a cohort. They stay enrolled in
+// the course.
+func RemoveCohortMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	cohortID := vars["cohortId"]
+
+	ctx := r.Context()
+
+	userID := resolveUserKey(ctx, vars["userId"])
+	result, err := enrollmentsCol.UpdateOne(ctx,
+		tenantFilter(ctx, bson.M{"course_id": courseID, "cohort": cohortID, "user_id": userID}),
+		bson.M{"$unset": bson.M{"cohort": ""}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to remove cohort member")
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendError(w, http.StatusNotFound, "Learner is not in this cohort")
+		return
+	}
+
+	log.Printf("✅ Cohort member removed: course=%s, cohort=%s, user=%s", courseID, cohortID, userID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort member removed successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetCohortProgress reports how far each learner of a cohort is through the
+// course, least progress first, so a teacher can see who needs a nudge
+func GetCohortProgress(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cohort, ok := loadCohort(w, r)
+	if !ok {
+		return
+	}
+	course, err := findCourse(ctx, cohort.CourseID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	report, err := cohortReport(ctx, *cohort, uniqueStrings(course.ChapterIDs))
+	if err != nil {
+		log.Printf("❌ Error building report for cohort %s/%s: %v", cohort.CourseID, cohort.CohortID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to build cohort report")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort progress fetched successfully",
+		Data:    report,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// COHORT HELPERS
+// ============================================================================
+
+// validateCohort checks a cohort's name, dates and instructors, who must be
+// instructors or admins of the organization
+func validateCohort(ctx context.Context, errs *fieldErrors, req *SaveCohortRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	errs.required("name", req.Name)
+	if req.StartDate != nil && req.EndDate != nil && !req.EndDate.After(*req.StartDate) {
+		errs.add("endDate", CodeInvalid, "must be after startDate")
+	}
+
+	if req.InstructorIDs == nil {
+		req.InstructorIDs = []string{}
+	}
+	for i, id := range req.InstructorIDs {
+		req.InstructorIDs[i] = resolveUserKey(ctx, strings.TrimSpace(id))
+	}
+	req.InstructorIDs = uniqueStrings(req.InstructorIDs)
+	if len(req.InstructorIDs) == 0 {
+		return nil
+	}
+	staff, err := usersCol.Distinct(ctx, "user_id", tenantFilter(ctx, bson.M{
+		"user_id": bson.M{"$in": req.InstructorIDs},
+		"role":    bson.M{"$in": bson.A{RoleInstructor, RoleAdmin}},
+	}))
+	if err != nil {
+		return err
+	}
+	isStaff := make(map[string]bool, len(staff))
+	for _, id := range staff {
+		if s, ok := id.(string); ok {
+			isStaff[s] = true
+		}
+	}
+	for i, id := range req.InstructorIDs {
+		if !isStaff[id] {
+			errs.add(fmt.Sprintf("instructorIds[%d]", i), CodeUnknownValue, "is not an instructor or admin of this organization")
+		}
+	}
+	return nil
+}
+
+// loadCohort finds the cohort the route names, sending a 404 and returning
+// false if there is none
+func loadCohort(w http.ResponseWriter, r *http.Request) (*Cohort, bool) {
+	vars := mux.Vars(r)
+	cohort, err := findCohort(r.Context(), vars["courseId"], vars["cohortId"])
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Cohort not found")
+		return nil, false
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	return cohort, true
+}
+
+func findCohort(ctx context.Context, courseID, cohortID string) (*Cohort, error) {
+	var cohort Cohort
+	err := cohortsCol.FindOne(ctx, tenantFilter(ctx, bson.M{"course_id": courseID, "cohort_id": cohortID})).Decode(&cohort)
+	if err != nil {
+		return nil, err
+	}
+	return &cohort, nil
+}
+
+// cohortMembersFilter selects the active enrollments of a cohort's learners
+func cohortMembersFilter(ctx context.Context, cohort Cohort) bson.M {
+	return tenantFilter(ctx, bson.M{
+		"course_id": cohort.CourseID,
+		"cohort":    cohort.CohortID,
+		"status":    EnrollmentActive,
+	})
+}
+
+// cohortEnrollments loads the active enrollments of a cohort's learners
+func cohortEnrollments(ctx context.Context, cohort Cohort) ([]Enrollment, error) {
+	cursor, err := enrollmentsCol.Find(ctx, cohortMembersFilter(ctx, cohort))
+	if err != nil {
+		return nil, err
+	}
+	var enrollments []Enrollment
+	if err := cursor.All(ctx, &enrollments); err != nil {
+		return nil, err
+	}
+	return enrollments, nil
+}
+
+// userNames maps user IDs to display names
+func userNames(ctx context.Context, userIDs []string) (map[string]string, error) {
+	names := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return names, nil
+	}
+	cursor, err := usersCol.Find(ctx, tenantFilter(ctx, bson.M{"user_id": bson.M{"$in": userIDs}}),
+		options.Find().SetProjection(bson.M{"user_id": 1, "name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		names[user.UserID] = user.Name
+	}
+	return names, nil
+}
+
+// cohortMembers lists a cohort's learners, by name
+func cohortMembers(ctx context.Context, cohort Cohort) ([]CohortMember, error) {
+	enrollments, err := cohortEnrollments(ctx, cohort)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]string, len(enrollments))
+	for i, e := range enrollments {
+		userIDs[i] = e.UserID
+	}
+	names, err := userNames(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]CohortMember, 0, len(enrollments))
+	for _, e := range enrollments {
+		members = append(members, CohortMember{UserID: e.UserID, Name: names[e.UserID], EnrolledAt: e.EnrolledAt})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Name != members[j].Name {
+			return members[i].Name < members[j].Name
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+// cohortProgress loads the progress of a cohort's learners in the course's
+// chapters
+func cohortProgress(ctx context.Context, userIDs, chapterIDs []string) ([]Progress, error) {
+	cursor, err := progressCol.Find(ctx,
+		tenantFilter(ctx, bson.M{"user_id": bson.M{"$in": userIDs}, "chapter_id": bson.M{"$in": chapterIDs}}),
+		options.Find().SetProjection(bson.M{
+			"user_id": 1, "chapter_id": 1, "video_progress": 1, "quiz_progress": 1, "quiz_completed": 1,
+			"quiz_score": 1, "quiz_passed": 1, "chapter_completed": 1, "last_accessed_at": 1,
+		}))
+	if err != nil {
+		return nil, err
+	}
+	var progress []Progress
+	if err := cursor.All(ctx, &progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// cohortReport builds a cohort's progress report over the course's chapters
+func cohortReport(ctx context.Context, cohort Cohort, chapterIDs []string) (CohortReport, error) {
+	report := CohortReport{
+		CohortID:      cohort.CohortID,
+		CourseID:      cohort.CourseID,
+		TotalChapters: len(chapterIDs),
+		Learners:      []CohortLearnerProgress{},
+	}
+
+	enrollments, err := cohortEnrollments(ctx, cohort)
+	if err != nil {
+		return report, err
+	}
+	if len(enrollments) == 0 {
+		return report, nil
+	}
+	userIDs := make([]string, len(enrollments))
+	for i, e := range enrollments {
+		userIDs[i] = e.UserID
+	}
+	names, err := userNames(ctx, userIDs)
+	if err != nil {
+		return report, err
+	}
+
+	rows := make(map[string]*CohortLearnerProgress, len(enrollments))
+	for _, e := range enrollments {
+		rows[e.UserID] = &CohortLearnerProgress{UserID: e.UserID, Name: names[e.UserID], CompletedAt: e.CompletedAt}
+	}
+
+	progress, err := cohortProgress(ctx, userIDs, chapterIDs)
+	if err != nil {
+		return report, err
+	}
+	for _, p := range progress {
+		row := rows[p.UserID]
+		if p.ChapterCompleted {
+			row.CompletedChapters++
+		}
+		if row.LastActiveAt == nil || p.LastAccessedAt.After(*row.LastActiveAt) {
+			last := p.LastAccessedAt
+			row.LastActiveAt = &last
+		}
+	}
+
+	total := 0
+	for _, row := range rows {
+		if report.TotalChapters > 0 {
+			row.Percent = row.CompletedChapters * 100 / report.TotalChapters
+		}
+		if report.TotalChapters > 0 && row.CompletedChapters == report.TotalChapters {
+			report.CompletedMembers++
+		}
+		total += row.Percent
+		report.Learners = append(report.Learners, *row)
+	}
+	report.Members = len(report.Learners)
+	report.AveragePercent = total / report.Members
+	sort.Slice(report.Learners, func(i, j int) bool {
+		a, b := report.Learners[i], report.Learners[j]
+		if a.Percent != b.Percent {
+			return a.Percent < b.Percent
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// courseCohortIDs returns the IDs of a course's cohorts
+func courseCohortIDs(ctx context.Context, courseID string) (map[string]bool, error) {
+	ids, err := cohortsCol.Distinct(ctx, "cohort_id", tenantFilter(ctx, bson.M{"course_id": courseID}))
+	if err != nil {
+		return nil, err
+	}
+	cohorts := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			cohorts[s] = true
+		}
+	}
+	return cohorts, nil
+}
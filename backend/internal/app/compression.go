@@ -0,0 +1,230 @@
+package app
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// RESPONSE COMPRESSION
+// ============================================================================
+
+// Chapter payloads with full quizzes are large for learners on cellular, so
+// responses are compressed when the client accepts it: Brotli in builds with
+// -tags brotli, gzip otherwise. A response is held back until it is big
+// enough to be worth compressing; smaller ones, media types that are already
+// compressed (PDFs) and the streaming routes (WebSocket, Server-Sent Events,
+// report downloads) go out as they are.
+
+// Content codings, as named in Accept-Encoding
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+// compressMinSize is the smallest body compressed; below about a packet
+// compression saves nothing
+const compressMinSize = 1024
+
+// brotliEncoder returns a Brotli writer. Only builds with -tags brotli set
+// it; see compression_brotli.go.
+var brotliEncoder func(w io.Writer) io.WriteCloser
+
+// gzipWriters recycles gzip writers, which are costly to set up
+var gzipWriters = sync.Pool{New: func() interface{} {
+	return gzip.NewWriter(io.Discard)
+}}
+
+// pooledGzipWriter returns its gzip writer to the pool when closed
+type pooledGzipWriter struct {
+	*gzip.Writer
+}
+
+func (w pooledGzipWriter) Close() error {
+	err := w.Writer.Close()
+	gzipWriters.Put(w.Writer)
+	return err
+}
+
+// gzipEncoder returns a pooled gzip writer onto w
+func gzipEncoder(w io.Writer) io.WriteCloser {
+	gz := gzipWriters.Get().(*gzip.Writer)
+	gz.Reset(w)
+	return pooledGzipWriter{gz}
+}
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	encoder  func(io.Writer) io.WriteCloser
+
+	status  int
+	buf     []byte
+	decided bool
+	out     io.Writer      // where the body goes once decided
+	closer  io.WriteCloser // the encoder, when compressing
+}
+
+// CompressionMiddleware compresses responses with the best coding the
+// client accepts
+func CompressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || streamingRoutes[routeKey(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, encoder: gzipEncoder}
+		if encoding == EncodingBrotli {
+			cw.encoder = brotliEncoder
+		}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) >= compressMinSize {
+			if err := w.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+	return w.out.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// start sends the headers, compressing if the body is big enough and the
+// response allows it, and then what was buffered
+func (w *compressWriter) start(bigEnough bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	header := w.Header()
+	w.out = w.ResponseWriter
+	if bigEnough && compressible(w.status, header) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// The compressed bytes differ, so a strong validator would be wrong;
+		// If-None-Match compares weakly, so revalidation still matches
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.closer = w.encoder(w.ResponseWriter)
+		w.out = w.closer
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.out.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// close sends a response still held back and finishes the compressed
+// stream
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return // nothing was written; net/http sends its own 200
+		}
+		w.start(false)
+	}
+	if w.closer != nil {
+		w.closer.Close()
+	}
+}
+
+// compressible reports whether a response may be compressed: it has a body,
+// isn't encoded already and is of a media type that compresses well
+func compressible(status int, header http.Header) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(header.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/javascript",
+		mediaType == "application/xml", mediaType == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// negotiateEncoding picks the coding to use from an Accept-Encoding header:
+// the one the client weighs highest, Brotli over gzip on a tie. It is empty
+// when the client accepts neither.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == "*" {
+			wildcard = q
+		} else {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{EncodingBrotli, EncodingGzip} {
+		if encoding == EncodingBrotli && brotliEncoder == nil {
+			continue
+		}
+		q, ok := weights[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
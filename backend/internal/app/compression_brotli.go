@@ -0,0 +1,22 @@
+//go:build brotli
+
+package app
+
+// Brotli response compression, in builds with -tags brotli. The module is
+// in go.mod, so the tag is all it takes.
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// brotliLevel trades ratio for speed; 4 compresses JSON better than gzip
+// at similar cost
+const brotliLevel = 4
+
+func init() {
+	brotliEncoder = func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotliLevel)
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// CONDITIONAL REQUESTS
+// ============================================================================
+
+// Apps fetch chapters on every launch although they rarely change, so chapter
+// reads can be revalidated instead of downloaded again. Responses carry an
+// ETag, a hash of the body, and where it is known a Last-Modified; a request
+// whose If-None-Match or If-Modified-Since still matches gets an empty 304.
+// The ETag covers everything in the body, including the message's language
+// and a learner's locks, so If-None-Match wins when both are sent.
+// Last-Modified only follows chapter (and course) edits: it is left off
+// learners' views, and a deleted chapter only changes the listing's ETag.
+
+// chapterCacheMaxAge is how long clients may reuse a chapter response
+// without revalidating it
+const chapterCacheMaxAge = 5 * time.Minute
+
+// cachePolicy says how clients may cache a response
+type cachePolicy struct {
+	MaxAge       time.Duration // zero: revalidate before every use
+	LastModified time.Time     // zero when unknown
+}
+
+// sendCacheableJSON sends a 200 response like sendJSON, with an ETag and the
+// policy's caching headers, or an empty 304 when the request's validators
+// show the client already has it
+func sendCacheableJSON(w http.ResponseWriter, r *http.Request, data interface{}, policy cachePolicy) {
+	body, err := json.Marshal(prepareResponse(w, data))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+	body = append(body, '\n') // as json.Encoder writes it
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", policy.cacheControl())
+	header.Add("Vary", "Accept-Language, X-Org-ID")
+	lastModified := policy.LastModified.UTC().Truncate(time.Second) // HTTP dates have no fractions
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// cacheControl is the policy's Cache-Control header. Responses are private:
+// they depend on the caller's organization and token.
+func (p cachePolicy) cacheControl() string {
+	if p.MaxAge <= 0 {
+		return "private, no-cache"
+	}
+	return "private, max-age=" + strconv.Itoa(int(p.MaxAge.Seconds()))
+}
+
+// notModified reports whether the request's validators match the response.
+// If-Modified-Since only counts without If-None-Match.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Values("If-None-Match"); len(match) > 0 {
+		return etagListed(strings.Join(match, ","), etag)
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+	return !lastModified.After(since)
+}
+
+// etagListed reports whether an If-None-Match list names etag. The
+// comparison is weak, as it is for GETs: W/"x" matches "x".
+func etagListed(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// chaptersLastModified is when the most recently written of chapters was
+// written. Chapters without an updated_at were last written before it was
+// recorded, so they can't be the latest; when none has one it is zero.
+func chaptersLastModified(chapters []Chapter) time.Time {
+	var latest time.Time
+	for _, chapter := range chapters {
+		if chapter.UpdatedAt != nil && chapter.UpdatedAt.After(latest) {
+			latest = *chapter.UpdatedAt
+		}
+	}
+	return latest
+}
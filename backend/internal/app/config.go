@@ -0,0 +1,592 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// ============================================================================
+// CONFIGURATION
+// ============================================================================
+
+// The server's settings are read once at startup into config, from, in
+// increasing precedence: their defaults, a JSON or YAML file named by
+// CONFIG_FILE or --config-file, the environment, and flags. Each setting
+// has a flag and an environment variable named after it, --mongodb-uri and
+// MONGODB_URI; file keys are the flag names. An invalid value stops the
+// server before it connects to anything, naming every setting that is
+// wrong. Features read their settings from config rather than the
+// environment.
+
+// Config holds the server's settings
+type Config struct {
+	ConfigFile            string
+	Port                  string
+	AppEnv                string
+	AppBaseURL            string
+	MongoDBURI            string
+	MongoDBDatabase       string
+	CollectionPrefix      string // put before every MongoDB collection name
+	CORSOrigins           []string
+	JWTSecret             string
+	AdminAPIKey           string
+	StorageBackend        string
+	DatabaseURL           string
+	RedisURL              string
+	RequestTimeout        time.Duration
+	ShutdownTimeout       time.Duration
+	TrustProxyHeaders     bool
+	SkipStartupMigrations bool
+	SchemaValidation      string
+	OTLPEndpoint          string // traces are exported here when set
+	OTELServiceName       string
+
+	// Accounts and sign-in
+	AccessTokenTTL           time.Duration
+	RefreshTokenTTL          time.Duration
+	RequireEmailVerification bool
+	EmailVerificationTTL     time.Duration
+	PasswordResetTTL         time.Duration
+	LoginDedupWindowSeconds  int // 0 records every login
+	GoogleClientIDs          []string
+	AppleClientIDs           []string
+
+	// Email
+	SMTPHost     string // emails are logged instead of sent when unset
+	SMTPPort     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// Media storage
+	MediaStorage       string
+	MediaDir           string
+	MediaBaseURL       string
+	MediaURLTTL        time.Duration
+	S3Endpoint         string
+	S3Region           string
+	S3Bucket           string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	GCSBucket          string
+	GCSHMACAccessID    string
+	GCSHMACSecret      string
+
+	// Caches and rate limits
+	ChapterCacheBackend    string
+	ChapterCacheTTLSeconds int
+	LeaderboardCacheTTL    time.Duration // 0 turns the cache off
+	RateLimitBackend       string
+	RateLimitPerIP         int // per minute; 0 turns the limit off
+	RateLimitPerUser       int
+	RateLimitVideoProgress int
+
+	// Events
+	EventBus                    string // nats or kafka; events aren't published when unset
+	EventBusURL                 string
+	EventBusTopicPrefix         string
+	AnalyticsEventRetentionDays int
+
+	// Learning features
+	XPVideoCompleted          int // 0 turns the award off
+	XPQuizPassed              int
+	XPPerfectScore            int
+	ModerationReportThreshold int
+	UserPathsEnabled          bool
+	CertificateTemplate       string // the embedded template when unset
+	CertificateVerifyBaseURL  string
+	LocalesDir                string
+}
+
+// config is the configuration in use, set up by loadConfig
+var config = defaultConfig()
+
+// secretSettings are logged as set or unset, never by value
+var secretSettings = map[string]bool{
+	"jwt-secret":            true,
+	"admin-api-key":         true,
+	"smtp-password":         true,
+	"aws-secret-access-key": true,
+	"gcs-hmac-secret":       true,
+}
+
+// urlSettings are logged with any password in them hidden
+var urlSettings = map[string]bool{
+	"mongodb-uri":                 true,
+	"database-url":                true,
+	"redis-url":                   true,
+	"otel-exporter-otlp-endpoint": true,
+	"event-bus-url":               true,
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:             "8080",
+		MongoDBURI:       "mongodb://localhost:27017",
+		MongoDBDatabase:  "resume_learning",
+		CORSOrigins:      []string{"*"},
+		StorageBackend:   StorageMongo,
+		RequestTimeout:   defaultRequestTimeout,
+		ShutdownTimeout:  defaultShutdownTimeout,
+		SchemaValidation: SchemaValidationError,
+		OTELServiceName:  defaultServiceName,
+
+		AccessTokenTTL:          defaultAccessTokenTTL,
+		RefreshTokenTTL:         defaultRefreshTokenTTL,
+		EmailVerificationTTL:    defaultEmailVerificationTTL,
+		PasswordResetTTL:        defaultPasswordResetTTL,
+		LoginDedupWindowSeconds: int(defaultLoginDedupWindow / time.Second),
+		SMTPPort:                "587",
+		SMTPFrom:                "no-reply@resume-learning.local",
+		MediaStorage:            MediaStorageLocal,
+		MediaDir:                defaultMediaDir,
+		MediaURLTTL:             defaultMediaURLTTL,
+
+		ChapterCacheBackend:    ChapterCacheNone,
+		ChapterCacheTTLSeconds: int(defaultChapterCacheTTL / time.Second),
+		LeaderboardCacheTTL:    defaultLeaderboardCacheTTL,
+		RateLimitBackend:       RateLimitMemory,
+		RateLimitPerIP:         defaultRateLimitPerIP,
+		RateLimitPerUser:       defaultRateLimitPerUser,
+		RateLimitVideoProgress: defaultRateLimitVideoProgress,
+
+		EventBusTopicPrefix:         defaultEventTopicPrefix,
+		AnalyticsEventRetentionDays: defaultEventRetentionDays,
+
+		XPVideoCompleted:          defaultXP[XPVideoCompleted],
+		XPQuizPassed:              defaultXP[XPQuizPassed],
+		XPPerfectScore:            defaultXP[XPPerfectScore],
+		ModerationReportThreshold: defaultReportThreshold,
+	}
+}
+
+// flagSet binds a flag for each setting to c, with c's values as defaults
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&c.ConfigFile, "config-file", c.ConfigFile, "JSON or YAML file of settings, keyed by flag name")
+	fs.StringVar(&c.Port, "port", c.Port, "port to listen on")
+	fs.StringVar(&c.AppEnv, "app-env", c.AppEnv, `deployment environment, e.g. "production"`)
+	fs.StringVar(&c.AppBaseURL, "app-base-url", c.AppBaseURL, "base URL of the web app, for links in emails")
+	fs.StringVar(&c.MongoDBURI, "mongodb-uri", c.MongoDBURI, "MongoDB connection string")
+	fs.StringVar(&c.MongoDBDatabase, "mongodb-database", c.MongoDBDatabase, "MongoDB database name")
+	fs.StringVar(&c.CollectionPrefix, "collection-prefix", c.CollectionPrefix, "prefix of every MongoDB collection name")
+	fs.Var((*listValue)(&c.CORSOrigins), "cors-origins", `comma-separated origins allowed by CORS, or "*"`)
+	fs.StringVar(&c.JWTSecret, "jwt-secret", c.JWTSecret, "key signing access tokens; random if unset")
+	fs.StringVar(&c.AdminAPIKey, "admin-api-key", c.AdminAPIKey, "platform admin key; the admin API is off if unset")
+	fs.StringVar(&c.StorageBackend, "storage-backend", c.StorageBackend, "mongo, memory or postgres")
+	fs.StringVar(&c.DatabaseURL, "database-url", c.DatabaseURL, "Postgres connection string")
+	fs.StringVar(&c.RedisURL, "redis-url", c.RedisURL, "Redis URL for shared rate limits and caches")
+	fs.DurationVar(&c.RequestTimeout, "request-timeout", c.RequestTimeout, "budget of routes without their own")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "how long requests may drain on shutdown")
+	fs.BoolVar(&c.TrustProxyHeaders, "trust-proxy-headers", c.TrustProxyHeaders, "read client IPs from X-Forwarded-For")
+	fs.BoolVar(&c.SkipStartupMigrations, "skip-startup-migrations", c.SkipStartupMigrations, "leave migrations to the admin migrate command")
+	fs.StringVar(&c.SchemaValidation, "schema-validation", c.SchemaValidation, "error, warn or off")
+	fs.StringVar(&c.OTLPEndpoint, "otel-exporter-otlp-endpoint", c.OTLPEndpoint, "OTLP/HTTP collector URL to export traces to; tracing is off if unset")
+	fs.StringVar(&c.OTELServiceName, "otel-service-name", c.OTELServiceName, "service name on exported traces")
+
+	fs.DurationVar(&c.AccessTokenTTL, "access-token-ttl", c.AccessTokenTTL, "how long access tokens last")
+	fs.DurationVar(&c.RefreshTokenTTL, "refresh-token-ttl", c.RefreshTokenTTL, "how long an idle sign-in lasts")
+	fs.BoolVar(&c.RequireEmailVerification, "require-email-verification", c.RequireEmailVerification, "make password accounts verify their email before logging in")
+	fs.DurationVar(&c.EmailVerificationTTL, "email-verification-ttl", c.EmailVerificationTTL, "how long email verification links last")
+	fs.DurationVar(&c.PasswordResetTTL, "password-reset-ttl", c.PasswordResetTTL, "how long password reset links last")
+	fs.IntVar(&c.LoginDedupWindowSeconds, "login-dedup-window-seconds", c.LoginDedupWindowSeconds, "logins from one device this close together are recorded once; 0 records every one")
+	fs.Var((*listValue)(&c.GoogleClientIDs), "google-client-ids", "comma-separated client IDs Google sign-in tokens may be issued to")
+	fs.Var((*listValue)(&c.AppleClientIDs), "apple-client-ids", "comma-separated client IDs Apple sign-in tokens may be issued to")
+
+	fs.StringVar(&c.SMTPHost, "smtp-host", c.SMTPHost, "SMTP server for emails; emails are logged if unset")
+	fs.StringVar(&c.SMTPPort, "smtp-port", c.SMTPPort, "SMTP server port")
+	fs.StringVar(&c.SMTPFrom, "smtp-from", c.SMTPFrom, "sender of emails")
+	fs.StringVar(&c.SMTPUsername, "smtp-username", c.SMTPUsername, "SMTP user; no authentication if unset")
+	fs.StringVar(&c.SMTPPassword, "smtp-password", c.SMTPPassword, "SMTP password")
+
+	fs.StringVar(&c.MediaStorage, "media-storage", c.MediaStorage, "local, s3 or gcs")
+	fs.StringVar(&c.MediaDir, "media-dir", c.MediaDir, "directory of uploads with local media storage")
+	fs.StringVar(&c.MediaBaseURL, "media-base-url", c.MediaBaseURL, "URL local uploads are served from; the API's if unset")
+	fs.DurationVar(&c.MediaURLTTL, "media-url-ttl", c.MediaURLTTL, "how long signed media URLs last, at least a minute")
+	fs.StringVar(&c.S3Endpoint, "s3-endpoint", c.S3Endpoint, "S3 endpoint; AWS's if unset")
+	fs.StringVar(&c.S3Region, "s3-region", c.S3Region, "S3 region")
+	fs.StringVar(&c.S3Bucket, "s3-bucket", c.S3Bucket, "S3 bucket of uploads")
+	fs.StringVar(&c.AWSAccessKeyID, "aws-access-key-id", c.AWSAccessKeyID, "S3 access key ID")
+	fs.StringVar(&c.AWSSecretAccessKey, "aws-secret-access-key", c.AWSSecretAccessKey, "S3 secret access key")
+	fs.StringVar(&c.GCSBucket, "gcs-bucket", c.GCSBucket, "Cloud Storage bucket of uploads")
+	fs.StringVar(&c.GCSHMACAccessID, "gcs-hmac-access-id", c.GCSHMACAccessID, "Cloud Storage HMAC key ID")
+	fs.StringVar(&c.GCSHMACSecret, "gcs-hmac-secret", c.GCSHMACSecret, "Cloud Storage HMAC secret")
+
+	fs.StringVar(&c.ChapterCacheBackend, "chapter-cache-backend", c.ChapterCacheBackend, "none or redis")
+	fs.IntVar(&c.ChapterCacheTTLSeconds, "chapter-cache-ttl", c.ChapterCacheTTLSeconds, "seconds chapters stay cached")
+	fs.DurationVar(&c.LeaderboardCacheTTL, "leaderboard-cache-ttl", c.LeaderboardCacheTTL, "how long leaderboards are cached; 0 turns the cache off")
+	fs.StringVar(&c.RateLimitBackend, "rate-limit-backend", c.RateLimitBackend, "memory or redis")
+	fs.IntVar(&c.RateLimitPerIP, "rate-limit-per-ip", c.RateLimitPerIP, "writes a minute per client IP; 0 turns the limit off")
+	fs.IntVar(&c.RateLimitPerUser, "rate-limit-per-user", c.RateLimitPerUser, "writes a minute per user; 0 turns the limit off")
+	fs.IntVar(&c.RateLimitVideoProgress, "rate-limit-video-progress", c.RateLimitVideoProgress, "video progress reports a minute per user; 0 turns the limit off")
+
+	fs.StringVar(&c.EventBus, "event-bus", c.EventBus, "nats or kafka to publish domain events; off if unset")
+	fs.StringVar(&c.EventBusURL, "event-bus-url", c.EventBusURL, "NATS URL or comma-separated Kafka brokers")
+	fs.StringVar(&c.EventBusTopicPrefix, "event-bus-topic-prefix", c.EventBusTopicPrefix, "put before event types to name topics")
+	fs.IntVar(&c.AnalyticsEventRetentionDays, "analytics-event-retention-days", c.AnalyticsEventRetentionDays, "days analytics events are kept")
+
+	fs.IntVar(&c.XPVideoCompleted, "xp-video-completed", c.XPVideoCompleted, "XP for completing a video; 0 turns it off")
+	fs.IntVar(&c.XPQuizPassed, "xp-quiz-passed", c.XPQuizPassed, "XP for passing a quiz; 0 turns it off")
+	fs.IntVar(&c.XPPerfectScore, "xp-perfect-score", c.XPPerfectScore, "XP for a perfect quiz score; 0 turns it off")
+	fs.IntVar(&c.ModerationReportThreshold, "moderation-report-threshold", c.ModerationReportThreshold, "reports that hide a comment until it is reviewed")
+	fs.BoolVar(&c.UserPathsEnabled, "user-paths-enabled", c.UserPathsEnabled, "let learners build their own learning paths")
+	fs.StringVar(&c.CertificateTemplate, "certificate-template", c.CertificateTemplate, "certificate template file; the built-in one if unset")
+	fs.StringVar(&c.CertificateVerifyBaseURL, "certificate-verify-base-url", c.CertificateVerifyBaseURL, "base URL of certificate verification links")
+	fs.StringVar(&c.LocalesDir, "locales-dir", c.LocalesDir, "directory of translation bundles overriding the built-in ones")
+	return fs
+}
+
+// development reports whether the server runs with APP_ENV=development,
+// which relaxes checks meant for deployed servers
+func (c Config) development() bool {
+	return c.AppEnv == "development"
+}
+
+// settingEnv names the environment variable of a setting's flag
+func settingEnv(flagName string) string {
+	return strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig reads the configuration into config. args are the server's
+// command-line flags; subcommands, which have flags of their own, pass nil.
+func loadConfig(args []string) error {
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️ No .env file found, using system environment variables")
+	}
+
+	// Flags are applied last but can name the file, so they are parsed
+	// first and kept as they were given
+	scratch := defaultConfig()
+	fs := scratch.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	flagged := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { flagged[f.Name] = f.Value.String() })
+
+	c := defaultConfig()
+	fs = c.flagSet()
+	var errs []error
+
+	path := os.Getenv("CONFIG_FILE")
+	if v, ok := flagged["config-file"]; ok {
+		path = v
+	}
+	if path != "" {
+		if err := applyConfigFile(fs, path); err != nil {
+			return err
+		}
+	}
+
+	fs.VisitAll(func(f *flag.Flag) {
+		env := settingEnv(f.Name)
+		if v := os.Getenv(env); v != "" {
+			if err := f.Value.Set(v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", env, err))
+			}
+		}
+	})
+	// DATABASE_DRIVER is the older name of STORAGE_BACKEND
+	if v := os.Getenv("DATABASE_DRIVER"); v != "" && os.Getenv("STORAGE_BACKEND") == "" {
+		c.StorageBackend = v
+	}
+	// Topics may go without a prefix, so an empty one counts
+	if v, ok := os.LookupEnv("EVENT_BUS_TOPIC_PREFIX"); ok && v == "" {
+		c.EventBusTopicPrefix = ""
+	}
+
+	for name, v := range flagged {
+		if err := fs.Lookup(name).Value.Set(v); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", name, err))
+		}
+	}
+
+	errs = append(errs, c.validate()...)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	config = c
+	return nil
+}
+
+// applyConfigFile sets the settings in a JSON or YAML file. Keys are flag
+// names; lists may be arrays or comma-separated strings.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	var errs []error
+	for name, value := range settings {
+		f := fs.Lookup(name)
+		if f == nil || name == "config-file" {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, name))
+			continue
+		}
+		if err := f.Value.Set(configFileValue(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configFileValue turns a value decoded from a config file into a flag's
+// text
+func configFileValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = configFileValue(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// databaseNamePattern is what MongoDB accepts in a database name that is
+// also safe in a file name
+var databaseNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}$`)
+
+// collectionPrefixPattern leaves out "$", which MongoDB reserves
+var collectionPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]*$`)
+
+// validate checks the settings, returning an error for each one that is
+// wrong
+func (c Config) validate() []error {
+	var errs []error
+	invalid := func(name, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]interface{}{settingEnv(name)}, args...)...))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		invalid("port", "%q is not a port number", c.Port)
+	}
+	if !strings.HasPrefix(c.MongoDBURI, "mongodb://") && !strings.HasPrefix(c.MongoDBURI, "mongodb+srv://") {
+		invalid("mongodb-uri", "must start with mongodb:// or mongodb+srv://")
+	}
+	if !databaseNamePattern.MatchString(c.MongoDBDatabase) {
+		invalid("mongodb-database", "%q must be 1 to 63 letters, digits, dashes or underscores", c.MongoDBDatabase)
+	}
+	if !collectionPrefixPattern.MatchString(c.CollectionPrefix) || strings.HasPrefix(c.CollectionPrefix, "system.") {
+		invalid("collection-prefix", "%q must be letters, digits, dots, dashes or underscores, not starting with system.", c.CollectionPrefix)
+	}
+	if len(c.CORSOrigins) == 0 {
+		invalid("cors-origins", `needs at least one origin, or "*"`)
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			invalid("cors-origins", "%q is not an origin such as https://app.example.com", origin)
+		}
+	}
+	if c.AppBaseURL != "" {
+		if u, err := url.Parse(c.AppBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("app-base-url", "%q is not an http or https URL", c.AppBaseURL)
+		}
+	}
+	if c.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("otel-exporter-otlp-endpoint", "%q is not an http or https URL", c.OTLPEndpoint)
+		}
+	}
+	if c.OTELServiceName == "" {
+		invalid("otel-service-name", "can't be empty")
+	}
+
+	switch c.StorageBackend {
+	case StorageMongo, StorageMemory:
+	case StoragePostgres:
+		if c.DatabaseURL == "" {
+			invalid("database-url", "is required for the postgres storage backend")
+		}
+	default:
+		invalid("storage-backend", "unknown backend %q", c.StorageBackend)
+	}
+	switch c.SchemaValidation {
+	case SchemaValidationError, SchemaValidationWarn, SchemaValidationOff:
+	default:
+		invalid("schema-validation", "%q must be error, warn or off", c.SchemaValidation)
+	}
+	if c.RequestTimeout <= 0 {
+		invalid("request-timeout", "must be positive")
+	}
+	if c.ShutdownTimeout <= 0 {
+		invalid("shutdown-timeout", "must be positive")
+	}
+
+	// Listed rather than mapped so errors come out in a stable order
+	positive := []struct {
+		name  string
+		value int64
+	}{
+		{"access-token-ttl", int64(c.AccessTokenTTL)},
+		{"refresh-token-ttl", int64(c.RefreshTokenTTL)},
+		{"email-verification-ttl", int64(c.EmailVerificationTTL)},
+		{"password-reset-ttl", int64(c.PasswordResetTTL)},
+		{"chapter-cache-ttl", int64(c.ChapterCacheTTLSeconds)},
+		{"analytics-event-retention-days", int64(c.AnalyticsEventRetentionDays)},
+		{"moderation-report-threshold", int64(c.ModerationReportThreshold)},
+	}
+	for _, s := range positive {
+		if s.value <= 0 {
+			invalid(s.name, "must be positive")
+		}
+	}
+	notNegative := []struct {
+		name  string
+		value int64
+	}{
+		{"login-dedup-window-seconds", int64(c.LoginDedupWindowSeconds)},
+		{"leaderboard-cache-ttl", int64(c.LeaderboardCacheTTL)},
+		{"rate-limit-per-ip", int64(c.RateLimitPerIP)},
+		{"rate-limit-per-user", int64(c.RateLimitPerUser)},
+		{"rate-limit-video-progress", int64(c.RateLimitVideoProgress)},
+		{"xp-video-completed", int64(c.XPVideoCompleted)},
+		{"xp-quiz-passed", int64(c.XPQuizPassed)},
+		{"xp-perfect-score", int64(c.XPPerfectScore)},
+	}
+	for _, s := range notNegative {
+		if s.value < 0 {
+			invalid(s.name, "can't be negative")
+		}
+	}
+	if c.MediaURLTTL < time.Minute {
+		invalid("media-url-ttl", "must be at least a minute")
+	}
+	if port, err := strconv.Atoi(c.SMTPPort); err != nil || port < 1 || port > 65535 {
+		invalid("smtp-port", "%q is not a port number", c.SMTPPort)
+	}
+	if c.CertificateVerifyBaseURL != "" {
+		if u, err := url.Parse(c.CertificateVerifyBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("certificate-verify-base-url", "%q is not an http or https URL", c.CertificateVerifyBaseURL)
+		}
+	}
+
+	switch c.MediaStorage {
+	case "", MediaStorageLocal:
+		if c.MediaDir == "" {
+			invalid("media-dir", "can't be empty")
+		}
+	case MediaStorageS3:
+		if c.S3Bucket == "" {
+			invalid("s3-bucket", "is required for s3 media storage")
+		}
+	case MediaStorageGCS:
+		if c.GCSBucket == "" {
+			invalid("gcs-bucket", "is required for gcs media storage")
+		}
+	default:
+		invalid("media-storage", "%q must be local, s3 or gcs", c.MediaStorage)
+	}
+	switch c.ChapterCacheBackend {
+	case "", ChapterCacheNone, ChapterCacheRedis:
+	default:
+		invalid("chapter-cache-backend", "%q must be none or redis", c.ChapterCacheBackend)
+	}
+	switch c.RateLimitBackend {
+	case "", RateLimitMemory, RateLimitRedis:
+	default:
+		invalid("rate-limit-backend", "%q must be memory or redis", c.RateLimitBackend)
+	}
+	switch c.EventBus {
+	case "":
+	case EventBusNATS:
+		if !validNATSSubject(c.EventBusTopicPrefix + DomainProgressUpdated) {
+			invalid("event-bus-topic-prefix", "%q doesn't make valid NATS subjects", c.EventBusTopicPrefix)
+		}
+		fallthrough
+	case EventBusKafka:
+		if c.EventBusURL == "" {
+			invalid("event-bus-url", "is required with an event bus")
+		}
+	default:
+		invalid("event-bus", "%q must be nats or kafka", c.EventBus)
+	}
+	return errs
+}
+
+// logConfig logs the settings in use, with secrets and the passwords in
+// connection strings hidden
+func logConfig() {
+	log.Println("⚙️ Configuration:")
+	c := config
+	c.flagSet().VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch {
+		case secretSettings[f.Name]:
+			if value != "" {
+				value = "(set)"
+			}
+		case urlSettings[f.Name]:
+			value = redactURL(value)
+		}
+		if value == "" {
+			value = "(unset)"
+		}
+		log.Printf("   %s=%s", settingEnv(f.Name), value)
+	})
+}
+
+// redactURL hides the password of a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		// Don't risk logging a password the parser couldn't find
+		return "(unreadable)"
+	}
+	return u.Redacted()
+}
+
+// listValue is a flag of comma-separated strings
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(v string) error {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*l = items
+	return nil
+}
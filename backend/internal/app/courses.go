@@ -0,0 +1,539 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// COURSE MODELS
+// ============================================================================
+
+// defaultCourseID holds the seed chapters and every user of the default
+// organization is enrolled in it. Other organizations get their own copy,
+// see defaultCourseFor.
+const defaultCourseID = "default"
+
+// Course is a group of chapters learners enroll in
+type Course struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID    string             `bson:"public_id,omitempty" json:"id"`
+	OrgID       string             `bson:"org_id,omitempty" json:"orgId"`
+	CourseID    string             `bson:"course_id" json:"courseId"`
+	Title       string             `bson:"title" json:"title"`
+	Description string             `bson:"description" json:"description"`
+	ChapterIDs  []string           `bson:"chapter_ids" json:"chapterIds"`                     // in the order learners take them
+	Order       int                `bson:"order" json:"order"`                                // position in the course catalog
+	AccessDays  int                `bson:"access_days,omitempty" json:"accessDays,omitempty"` // access window per enrollment, 0 for lifetime
+	Drip        []DripRule         `bson:"drip,omitempty" json:"drip,omitempty"`
+	Releases    []ChapterRelease   `bson:"releases,omitempty" json:"releases,omitempty"` // per-cohort release dates
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// courseIDPattern keeps course IDs slug-shaped
+var courseIDPattern = regexp.MustCompile(`^[a-z0-9_\-]{2,50}$`)
+
+// SaveCourseRequest is the body of course create and update
+type SaveCourseRequest struct {
+	CourseID    string   `json:"courseId,omitempty"` // create only, generated if empty
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	ChapterIDs  []string `json:"chapterIds"`
+	Order       int      `json:"order"`
+	AccessDays  int      `json:"accessDays"`
+}
+
+// ============================================================================
+// COURSE HANDLERS
+// ============================================================================
+
+// GetCourses lists the organization's courses in catalog order
+func GetCourses(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cursor, err := coursesCol.Find(ctx, tenantFilter(ctx, bson.M{}),
+		options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "title", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch courses")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	courses := []Course{}
+	if err := cursor.All(ctx, &courses); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode courses")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Courses fetched successfully",
+		Data:    courses,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetCourseByID returns one course of the organization
+func GetCourseByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+
+	ctx := r.Context()
+
+	course, err := findCourse(ctx, courseID)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Course fetched successfully",
+		Data:    course,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetCourseChapters returns a course's chapters in course order. With
+// ?userId= the learner must have access to the course, withdrawn chapters
+// are left out, and the rest carry their drip and release locks and
+// accessibility preferences, as in GetChapters.
+func GetCourseChapters(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	userID := r.URL.Query().Get("userId")
+
+	ctx := r.Context()
+
+	course, err := findCourse(ctx, courseID)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if userID != "" && !checkCourseAccess(ctx, w, userID, courseID) {
+		return
+	}
+
+	cursor, err := chaptersCol.Find(ctx, bson.M{"chapter_id": bson.M{"$in": course.ChapterIDs}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch chapters")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var found []Chapter
+	if err := cursor.All(ctx, &found); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode chapters")
+		return
+	}
+	byID := make(map[string]Chapter, len(found))
+	for _, chapter := range found {
+		byID[chapter.ChapterID] = chapter
+	}
+	chapters := make([]Chapter, 0, len(course.ChapterIDs))
+	for _, id := range course.ChapterIDs {
+		if chapter, ok := byID[id]; ok {
+			chapters = append(chapters, chapter)
+		}
+	}
+
+	if userID != "" {
+		windows, err := chapterWindows(ctx, userID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+			return
+		}
+		now := time.Now()
+		visible := chapters[:0]
+		for _, chapter := range chapters {
+			if !windows[chapter.ChapterID].closed(now) {
+				chapter.Lock = chapterLock(windows[chapter.ChapterID], now)
+				visible = append(visible, chapter)
+			}
+		}
+		chapters = visible
+		if err := lockForPrerequisites(ctx, userID, chapters); err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		var user User
+		if err := usersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"user_id": userID})).Decode(&user); err == nil {
+			chapters = applyAccessibilityPreferences(chapters, user.Accessibility)
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapters fetched successfully",
+		Data:    chapters,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateCourse adds a course to the organization
+func CreateCourse(w http.ResponseWriter, r *http.Request) {
+	var req SaveCourseRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.CourseID = strings.TrimSpace(req.CourseID)
+	if req.CourseID == "" {
+		req.CourseID = "course_" + primitive.NewObjectID().Hex()
+	}
+
+	ctx := r.Context()
+
+	var errs fieldErrors
+	if !courseIDPattern.MatchString(req.CourseID) {
+		errs.add("courseId", CodeInvalid, "must be 2-50 lowercase letters, digits, dashes or underscores")
+	}
+	if err := validateCourse(ctx, &errs, &req); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	course := Course{
+		PublicID:    newPublicID(),
+		OrgID:       orgID(ctx),
+		CourseID:    req.CourseID,
+		Title:       req.Title,
+		Description: req.Description,
+		ChapterIDs:  req.ChapterIDs,
+		Order:       req.Order,
+		AccessDays:  req.AccessDays,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if _, err := coursesCol.InsertOne(ctx, course); mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "A course with this ID already exists")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create course")
+		return
+	}
+
+	log.Printf("✅ Course created: org=%s, course=%s", course.OrgID, course.CourseID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Course created successfully",
+		Data:    course,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// UpdateCourse replaces a course's details and chapter list. Drip rules and
+// release dates for chapters no longer in the course are dropped.
+func UpdateCourse(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+
+	var req SaveCourseRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.CourseID = courseID
+
+	ctx := r.Context()
+
+	course, err := findCourse(ctx, courseID)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var errs fieldErrors
+	if err := validateCourse(ctx, &errs, &req); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	inCourse := make(map[string]bool, len(req.ChapterIDs))
+	for _, id := range req.ChapterIDs {
+		inCourse[id] = true
+	}
+	drip := []DripRule{}
+	for _, rule := range course.Drip {
+		if inCourse[rule.ChapterID] {
+			drip = append(drip, rule)
+		}
+	}
+	releases := []ChapterRelease{}
+	for _, release := range course.Releases {
+		if inCourse[release.ChapterID] {
+			releases = append(releases, release)
+		}
+	}
+
+	err = coursesCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"course_id": courseID}), bson.M{"$set": bson.M{
+		"title":       req.Title,
+		"description": req.Description,
+		"chapter_ids": req.ChapterIDs,
+		"order":       req.Order,
+		"access_days": req.AccessDays,
+		"drip":        drip,
+		"releases":    releases,
+		"updated_at":  time.Now(),
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(course)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update course")
+		return
+	}
+
+	log.Printf("✅ Course updated: %s", courseID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Course updated successfully",
+		Data:    course,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// COURSE HELPERS
+// ============================================================================
+
+// validateCourse normalizes a course request and adds a field error for
+// every problem. It only returns an error when the database can't be checked.
+func validateCourse(ctx context.Context, errs *fieldErrors, req *SaveCourseRequest) error {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.ChapterIDs == nil {
+		req.ChapterIDs = []string{}
+	}
+
+	errs.required("title", req.Title)
+	if req.Order < 0 {
+		errs.add("order", CodeOutOfRange, "must not be negative")
+	}
+	if req.AccessDays < 0 {
+		errs.add("accessDays", CodeOutOfRange, "must not be negative")
+	}
+
+	seen := map[string]bool{}
+	for i, id := range req.ChapterIDs {
+		if seen[id] {
+			errs.add(fmt.Sprintf("chapterIds[%d]", i), CodeInvalid, "appears more than once")
+		}
+		seen[id] = true
+	}
+	if len(req.ChapterIDs) == 0 {
+		return nil
+	}
+
+	ids, err := chaptersCol.Distinct(ctx, "chapter_id", liveChapters(ctx, bson.M{"chapter_id": bson.M{"$in": req.ChapterIDs}}))
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			known[s] = true
+		}
+	}
+	for i, id := range req.ChapterIDs {
+		if !known[id] {
+			errs.add(fmt.Sprintf("chapterIds[%d]", i), CodeUnknownValue, "is not a known chapter")
+		}
+	}
+	return nil
+}
+
+// checkCourseAccess sends a 403 and returns false unless the user has access
+// to the course
+func checkCourseAccess(ctx context.Context, w http.ResponseWriter, userID, courseID string) bool {
+	var enrollment Enrollment
+	err := enrollmentsCol.FindOne(ctx, tenantFilter(ctx, bson.M{
+		"user_id":   userID,
+		"course_id": courseID,
+		"status":    EnrollmentActive,
+	})).Decode(&enrollment)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeNotEnrolled, "Enroll in the course to see its chapters")
+		return false
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if !enrollment.hasAccess(time.Now()) {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeAccessExpired, "Your access to this course has expired")
+		return false
+	}
+	return true
+}
+
+// defaultCourseFor returns the ID of an organization's starter course. Course
+// IDs are unique across organizations.
+func defaultCourseFor(orgID string) string {
+	if orgID == defaultOrgID {
+		return defaultCourseID
+	}
+	return orgID + "-" + defaultCourseID
+}
+
+// ensureDefaultCourse creates an organization's starter course if needed and
+// adds the seed chapters to it. Chapters already in the course keep their
+// position.
+func ensureDefaultCourse(ctx context.Context, orgID string) error {
+	seed := seedChapters()
+	chapterIDs := make([]string, 0, len(seed))
+	for _, chapter := range seed {
+		chapterIDs = append(chapterIDs, chapter.ChapterID)
+	}
+
+	_, err := coursesCol.UpdateOne(ctx,
+		bson.M{"course_id": defaultCourseFor(orgID)},
+		bson.M{
+			"$setOnInsert": bson.M{
+				"public_id":   newPublicID(),
+				"org_id":      orgID,
+				"title":       "Getting Started",
+				"description": "The starter course every learner is enrolled in.",
+				"created_at":  time.Now(),
+			},
+			"$addToSet": bson.M{"chapter_ids": bson.M{"$each": chapterIDs}},
+			"$set":      bson.M{"updated_at": time.Now()},
+		},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// ensureDefaultCourses runs ensureDefaultCourse for every organization, so
+// new seed chapters reach all starter courses
+func ensureDefaultCourses(ctx context.Context) error {
+	ids, err := organizationsCol.Distinct(ctx, "org_id", bson.M{})
+	if err != nil {
+		return err
+	}
+	orgIDs := []string{defaultOrgID}
+	for _, id := range ids {
+		if s, ok := id.(string); ok && s != defaultOrgID {
+			orgIDs = append(orgIDs, s)
+		}
+	}
+	for _, id := range orgIDs {
+		if err := ensureDefaultCourse(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// courseIDsForChapter lists the courses a chapter belongs to
+func courseIDsForChapter(ctx context.Context, chapterID string) ([]string, error) {
+	ids, err := coursesCol.Distinct(ctx, "course_id", tenantFilter(ctx, bson.M{"chapter_ids": chapterID}))
+	if err != nil {
+		return nil, err
+	}
+	courseIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if s, ok := id.(string); ok {
+			courseIDs = append(courseIDs, s)
+		}
+	}
+	return courseIDs, nil
+}
+
+// findCourse loads a course by course_id, within the request's organization
+func findCourse(ctx context.Context, courseID string) (*Course, error) {
+	// Courses are kept in MongoDB only
+	if !mongoConnected() {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var course Course
+	if err := coursesCol.FindOne(ctx, tenantFilter(ctx, bson.M{"course_id": courseID})).Decode(&course); err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+// adoptOrphanChapters moves shared and default organization chapters that
+// belong to no course, such as those from before courses existed, into the
+// default course
+func adoptOrphanChapters(ctx context.Context) error {
+	inCourses, err := coursesCol.Distinct(ctx, "chapter_ids", bson.M{})
+	if err != nil {
+		return err
+	}
+	cursor, err := chaptersCol.Find(ctx, liveChapters(ctx, bson.M{
+		"chapter_id": bson.M{"$nin": inCourses},
+		"org_id":     bson.M{"$in": bson.A{defaultOrgID, nil}},
+	}),
+		options.Find().SetSort(bson.D{{Key: "order", Value: 1}}).SetProjection(bson.M{"chapter_id": 1}))
+	if err != nil {
+		return err
+	}
+	var orphans []Chapter
+	if err := cursor.All(ctx, &orphans); err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	chapterIDs := make([]string, 0, len(orphans))
+	for _, chapter := range orphans {
+		chapterIDs = append(chapterIDs, chapter.ChapterID)
+	}
+	_, err = coursesCol.UpdateOne(ctx, bson.M{"course_id": defaultCourseID}, bson.M{
+		"$addToSet": bson.M{"chapter_ids": bson.M{"$each": chapterIDs}},
+		"$set":      bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("✅ Moved %d chapters without a course into the default course", len(chapterIDs))
+	return nil
+}
+
+// seedDefaultCourse is run at startup after the chapter seed
+func seedDefaultCourse() {
+	ctx := context.Background()
+	if err := ensureDefaultCourses(ctx); err != nil {
+		log.Printf("❌ Error seeding default course: %v", err)
+		return
+	}
+	if err := adoptOrphanChapters(ctx); err != nil {
+		log.Printf("❌ Error moving chapters into the default course: %v", err)
+	}
+	if err := backfillDefaultEnrollments(ctx); err != nil {
+		log.Printf("❌ Error enrolling existing users: %v", err)
+	}
+}
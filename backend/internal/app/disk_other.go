@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package app
+
+// diskSpace is only implemented for Linux and macOS
+func diskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, errDiskSpaceUnsupported
+}
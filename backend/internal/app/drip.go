@@ -0,0 +1,324 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// DRIP MODELS
+// ============================================================================
+
+// ErrCodeChapterLocked is returned for progress on a chapter that hasn't opened yet
+const ErrCodeChapterLocked = "chapter_locked"
+
+// Lock reasons
+const (
+	LockReasonDrip          = "drip"          // opens some days after the learner enrolled
+	LockReasonScheduled     = "scheduled"     // opens on its release date, see release.go
+	LockReasonPrerequisites = "prerequisites" // opens once its prerequisite chapters are completed
+)
+
+// DripRule opens a chapter of a course some days after each learner enrolls.
+// Days are counted in the learner's timezone from the start of the day they
+// enrolled, so "7 days" opens at midnight a week later.
+type DripRule struct {
+	ChapterID string `bson:"chapter_id" json:"chapterId"`
+	Days      int    `bson:"days" json:"days"`
+}
+
+// ChapterLock tells a learner when a chapter opens
+type ChapterLock struct {
+	Locked             bool       `json:"locked"`
+	Reason             string     `json:"reason"`
+	UnlocksAt          *time.Time `json:"unlocksAt,omitempty"`          // drip and scheduled locks
+	UnmetPrerequisites []string   `json:"unmetPrerequisites,omitempty"` // prerequisite locks, chapter IDs
+}
+
+type UpdateCourseDripRequest struct {
+	Rules []DripRule `json:"rules"`
+}
+
+// ============================================================================
+// DRIP HANDLERS
+// ============================================================================
+
+// UpdateCourseDrip replaces a course's drip schedule
+func UpdateCourseDrip(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+
+	var req UpdateCourseDripRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	course, err := findCourse(ctx, courseID)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	inCourse := make(map[string]bool, len(course.ChapterIDs))
+	for _, id := range course.ChapterIDs {
+		inCourse[id] = true
+	}
+
+	var errs fieldErrors
+	seen := map[string]bool{}
+	rules := []DripRule{}
+	for i, rule := range req.Rules {
+		field := "rules[" + strconv.Itoa(i) + "]"
+		switch {
+		case !inCourse[rule.ChapterID]:
+			errs.add(field+".chapterId", CodeInvalid, "is not a chapter of this course")
+		case seen[rule.ChapterID]:
+			errs.add(field+".chapterId", CodeInvalid, "appears more than once")
+		case rule.Days < 0:
+			errs.add(field+".days", CodeOutOfRange, "must not be negative")
+		}
+		seen[rule.ChapterID] = true
+		if rule.Days > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	err = coursesCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"course_id": courseID}),
+		bson.M{"$set": bson.M{"drip": rules, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(course)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update drip schedule")
+		return
+	}
+
+	log.Printf("✅ Drip schedule updated: course=%s, rules=%d", courseID, len(rules))
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Drip schedule updated successfully",
+		Data:    course,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// DRIP HELPERS
+// ============================================================================
+
+// chapterWindow is when a chapter is open to a learner
+type chapterWindow struct {
+	opens  time.Time  // zero if it has always been open
+	reason string     // why it isn't open before then, a LockReason
+	closes *time.Time // nil if it never closes
+}
+
+// closed reports whether the chapter's release has ended
+func (cw chapterWindow) closed(now time.Time) bool {
+	return cw.closes != nil && !now.Before(*cw.closes)
+}
+
+// better reports whether cw gives the learner more access than other: open
+// beats opening later beats closed, then the earlier opening or the later
+// closing wins
+func (cw chapterWindow) better(other chapterWindow, now time.Time) bool {
+	state := func(w chapterWindow) int {
+		switch {
+		case w.closed(now):
+			return 2
+		case now.Before(w.opens):
+			return 1
+		}
+		return 0
+	}
+	a, b := state(cw), state(other)
+	switch {
+	case a != b:
+		return a < b
+	case a == 1:
+		return cw.opens.Before(other.opens)
+	case a == 0:
+		return other.closes != nil && (cw.closes == nil || cw.closes.After(*other.closes))
+	}
+	return false
+}
+
+// chapterWindows returns, per chapter the user has access to, when it is
+// open to them: from the later of its drip opening and its release date
+// (the cohort's, if the course moves it for the learner's cohort) until its
+// release ends. Chapters with neither are always open. A chapter in several
+// of the user's courses gets whichever window gives the most access.
+// Without MongoDB there are no courses, and every chapter is always open.
+func chapterWindows(ctx context.Context, userID string) (map[string]chapterWindow, error) {
+	if !mongoConnected() {
+		return map[string]chapterWindow{}, nil
+	}
+
+	cursor, err := enrollmentsCol.Find(ctx, tenantFilter(ctx, bson.M{"user_id": userID, "status": EnrollmentActive}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var enrollments []Enrollment
+	if err := cursor.All(ctx, &enrollments); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	enrolledAt := map[string]time.Time{}
+	cohorts := map[string]string{}
+	courseIDs := []string{}
+	for _, e := range enrollments {
+		if e.hasAccess(now) {
+			enrolledAt[e.CourseID] = e.EnrolledAt
+			cohorts[e.CourseID] = e.Cohort
+			courseIDs = append(courseIDs, e.CourseID)
+		}
+	}
+
+	windows := map[string]chapterWindow{}
+	if len(courseIDs) == 0 {
+		return windows, nil
+	}
+
+	courseCursor, err := coursesCol.Find(ctx, tenantFilter(ctx, bson.M{"course_id": bson.M{"$in": courseIDs}}))
+	if err != nil {
+		return nil, err
+	}
+	defer courseCursor.Close(ctx)
+
+	var courses []Course
+	if err := courseCursor.All(ctx, &courses); err != nil {
+		return nil, err
+	}
+
+	var chapterIDs []string
+	for _, course := range courses {
+		chapterIDs = append(chapterIDs, course.ChapterIDs...)
+	}
+	scheduled, err := chapterAvailabilities(ctx, chapterIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := userLocation(ctx, userID)
+	for _, course := range courses {
+		days := make(map[string]int, len(course.Drip))
+		for _, rule := range course.Drip {
+			days[rule.ChapterID] = rule.Days
+		}
+		overrides := map[string]ChapterAvailability{}
+		if cohort := cohorts[course.CourseID]; cohort != "" {
+			for _, release := range course.Releases {
+				if release.Cohort == cohort {
+					overrides[release.ChapterID] = release.ChapterAvailability
+				}
+			}
+		}
+		enrolledDay := startOfDay(enrolledAt[course.CourseID], loc)
+		for _, chapterID := range course.ChapterIDs {
+			var window chapterWindow
+			if d := days[chapterID]; d > 0 {
+				window = chapterWindow{opens: enrolledDay.AddDate(0, 0, d), reason: LockReasonDrip}
+			}
+			availability, ok := overrides[chapterID]
+			if !ok {
+				availability = scheduled[chapterID]
+			}
+			if from := availability.AvailableFrom; from != nil && from.After(window.opens) {
+				window.opens, window.reason = *from, LockReasonScheduled
+			}
+			window.closes = availability.AvailableUntil
+
+			if current, ok := windows[chapterID]; !ok || window.better(current, now) {
+				windows[chapterID] = window
+			}
+		}
+	}
+	return windows, nil
+}
+
+// chapterAvailabilities loads the release dates of those of chapterIDs that
+// have them
+func chapterAvailabilities(ctx context.Context, chapterIDs []string) (map[string]ChapterAvailability, error) {
+	availabilities := map[string]ChapterAvailability{}
+	if len(chapterIDs) == 0 {
+		return availabilities, nil
+	}
+	cursor, err := chaptersCol.Find(ctx,
+		bson.M{
+			"chapter_id": bson.M{"$in": uniqueStrings(chapterIDs)},
+			"$or": bson.A{
+				bson.M{"available_from": bson.M{"$exists": true}},
+				bson.M{"available_until": bson.M{"$exists": true}},
+			},
+		},
+		options.Find().SetProjection(bson.M{"chapter_id": 1, "available_from": 1, "available_until": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var chapters []Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return nil, err
+	}
+	for _, chapter := range chapters {
+		availabilities[chapter.ChapterID] = ChapterAvailability{
+			AvailableFrom:  chapter.AvailableFrom,
+			AvailableUntil: chapter.AvailableUntil,
+		}
+	}
+	return availabilities, nil
+}
+
+// chapterLock returns the lock for a chapter that opens later in window, or
+// nil if it is already open
+func chapterLock(window chapterWindow, now time.Time) *ChapterLock {
+	if !now.Before(window.opens) {
+		return nil
+	}
+	unlocksAt := window.opens
+	return &ChapterLock{Locked: true, Reason: window.reason, UnlocksAt: &unlocksAt}
+}
+
+// checkUnlocked sends a 403 and returns false if the chapter hasn't opened
+// for the user yet, or its release has ended
+func checkUnlocked(ctx context.Context, w http.ResponseWriter, userID, chapterID string) bool {
+	windows, err := chapterWindows(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	now := time.Now()
+	if windows[chapterID].closed(now) {
+		sendChapterClosed(w)
+		return false
+	}
+	if lock := chapterLock(windows[chapterID], now); lock != nil {
+		response := ApiResponse{
+			Success: false,
+			Code:    ErrCodeChapterLocked,
+			Message: "This chapter is not open yet",
+			Data:    lock,
+		}
+		sendJSON(w, http.StatusForbidden, response)
+		return false
+	}
+	return true
+}
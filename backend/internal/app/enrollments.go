@@ -0,0 +1,612 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ENROLLMENT MODELS
+// ============================================================================
+
+// Enrollment states
+const (
+	EnrollmentActive     = "active"
+	EnrollmentUnenrolled = "unenrolled"
+	EnrollmentRevoked    = "revoked" // access withdrawn by an admin
+)
+
+// Enrollment sources
+const (
+	EnrollmentSourceSelf    = "self"    // the learner joined
+	EnrollmentSourceAdmin   = "admin"   // enrolled by an admin
+	EnrollmentSourceDefault = "default" // automatic enrollment in the default course
+)
+
+// Error codes for chapters outside the learner's courses
+const (
+	ErrCodeNotEnrolled   = "not_enrolled"
+	ErrCodeAccessExpired = "access_expired"
+)
+
+// Enrollment links a user to a course
+type Enrollment struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID     string             `bson:"public_id,omitempty" json:"id"`
+	OrgID        string             `bson:"org_id,omitempty" json:"-"`
+	UserID       string             `bson:"user_id" json:"userId"`
+	CourseID     string             `bson:"course_id" json:"courseId"`
+	Status       string             `bson:"status" json:"status"`
+	Source       string             `bson:"source" json:"source"`
+	EnrolledAt   time.Time          `bson:"enrolled_at" json:"enrolledAt"`
+	ExpiresAt    *time.Time         `bson:"expires_at,omitempty" json:"expiresAt,omitempty"` // unset for lifetime access
+	UnenrolledAt *time.Time         `bson:"unenrolled_at,omitempty" json:"unenrolledAt,omitempty"`
+	Cohort       string             `bson:"cohort,omitempty" json:"cohort,omitempty"`            // picks the course's release dates for this cohort
+	CompletedAt  *time.Time         `bson:"completed_at,omitempty" json:"completedAt,omitempty"` // when the learner first completed every chapter
+
+	// Computed for responses
+	Expired       bool                `bson:"-" json:"expired"`
+	RemainingDays *int                `bson:"-" json:"remainingDays,omitempty"` // whole days left, rounded up
+	Progress      *EnrollmentProgress `bson:"-" json:"progress,omitempty"`
+}
+
+// EnrollmentProgress is how far a learner is through one course. Chapter
+// progress is shared between courses, so a chapter completed in one course
+// counts in every course that has it.
+type EnrollmentProgress struct {
+	CompletedChapters int `json:"completedChapters"`
+	TotalChapters     int `json:"totalChapters"`
+	Percent           int `json:"percent"` // rounded down
+}
+
+type EnrollRequest struct {
+	CourseID   string `json:"courseId,omitempty"` // POST /api/enrollments only
+	UserID     string `json:"userId"`
+	AccessDays int    `json:"accessDays"` // admin only, overrides the course's access window
+}
+
+type ExtendAccessRequest struct {
+	Days      int        `json:"days"`      // add to the current expiry (or to now, if already expired)
+	ExpiresAt *time.Time `json:"expiresAt"` // or set it outright
+	Lifetime  bool       `json:"lifetime"`  // or remove the expiry
+}
+
+// ============================================================================
+// ENROLLMENT HANDLERS
+// ============================================================================
+
+// EnrollInCourse lets a learner join a course
+func EnrollInCourse(w http.ResponseWriter, r *http.Request) {
+	enrollHandler(w, r, EnrollmentSourceSelf)
+}
+
+// AdminEnrollInCourse enrolls a learner on their behalf
+func AdminEnrollInCourse(w http.ResponseWriter, r *http.Request) {
+	enrollHandler(w, r, EnrollmentSourceAdmin)
+}
+
+// CreateEnrollment lets a learner join the course named in the body
+func CreateEnrollment(w http.ResponseWriter, r *http.Request) {
+	enrollHandler(w, r, EnrollmentSourceSelf)
+}
+
+func enrollHandler(w http.ResponseWriter, r *http.Request, source string) {
+	vars := mux.Vars(r)
+	courseID, inPath := vars["courseId"]
+
+	var req EnrollRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	if !inPath {
+		courseID = req.CourseID
+		errs.required("courseId", courseID)
+	}
+	if req.AccessDays != 0 && source != EnrollmentSourceAdmin {
+		errs.add("accessDays", CodeInvalid, "can only be set by an admin")
+	} else if req.AccessDays < 0 {
+		errs.add("accessDays", CodeOutOfRange, "must be positive")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	if !checkUserActive(ctx, w, req.UserID) {
+		return
+	}
+
+	count, err := usersCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"user_id": req.UserID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count == 0 {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	}
+
+	course, err := findCourse(ctx, courseID)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	accessDays := course.AccessDays
+	if req.AccessDays > 0 {
+		accessDays = req.AccessDays
+	}
+
+	enrollment, err := enrollUser(ctx, req.UserID, courseID, source, accessDays)
+	if err != nil {
+		log.Printf("❌ Error enrolling user: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to enroll")
+		return
+	}
+
+	enrollments := []Enrollment{enrollment.withAccess(time.Now())}
+	if err := withCourseProgress(ctx, req.UserID, enrollments); err != nil {
+		log.Printf("⚠️ Error loading course progress: user=%s, course=%s: %v", req.UserID, courseID, err)
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Enrolled successfully",
+		Data:    enrollments[0],
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UnenrollFromCourse ends a learner's enrollment. Progress is kept so
+// re-enrolling picks up where they left off.
+func UnenrollFromCourse(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	now := time.Now()
+	var enrollment Enrollment
+	err := enrollmentsCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"user_id": userID, "course_id": courseID, "status": EnrollmentActive}),
+		bson.M{"$set": bson.M{"status": EnrollmentUnenrolled, "unenrolled_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&enrollment)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Enrollment not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to unenroll")
+		return
+	}
+
+	log.Printf("✅ User unenrolled: user=%s, course=%s", userID, courseID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Unenrolled successfully",
+		Data:    enrollment.withAccess(time.Now()),
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetUserEnrollments lists a user's enrollments, active first
+func GetUserEnrollments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	cursor, err := enrollmentsCol.Find(ctx, tenantFilter(ctx, bson.M{"user_id": userID}),
+		options.Find().SetSort(bson.D{{Key: "status", Value: 1}, {Key: "enrolled_at", Value: -1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	enrollments := []Enrollment{}
+	if err := cursor.All(ctx, &enrollments); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode enrollments")
+		return
+	}
+	now := time.Now()
+	for i := range enrollments {
+		enrollments[i] = enrollments[i].withAccess(now)
+	}
+	if err := withCourseProgress(ctx, userID, enrollments); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch progress")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Enrollments fetched successfully",
+		Data:    enrollments,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ExtendEnrollmentAccess moves the expiry of a learner's access to a course
+func ExtendEnrollmentAccess(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	userID := vars["userId"]
+
+	var req ExtendAccessRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	choices := 0
+	for _, set := range []bool{req.Days != 0, req.ExpiresAt != nil, req.Lifetime} {
+		if set {
+			choices++
+		}
+	}
+	var errs fieldErrors
+	if choices != 1 {
+		errs.add("days", CodeInvalid, "exactly one of days, expiresAt or lifetime is required")
+	} else if req.Days < 0 {
+		errs.add("days", CodeOutOfRange, "must be positive")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	var enrollment Enrollment
+	filter := tenantFilter(ctx, bson.M{"user_id": userID, "course_id": courseID, "status": EnrollmentActive})
+	err := enrollmentsCol.FindOne(ctx, filter).Decode(&enrollment)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Enrollment not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	now := time.Now()
+	update := bson.M{}
+	switch {
+	case req.Lifetime:
+		update["$unset"] = bson.M{"expires_at": ""}
+	case req.ExpiresAt != nil:
+		update["$set"] = bson.M{"expires_at": *req.ExpiresAt}
+	default:
+		// Extending lifetime access is meaningless; extending expired access
+		// starts from now rather than from the past expiry
+		if enrollment.ExpiresAt == nil {
+			sendError(w, http.StatusConflict, "Enrollment already has lifetime access")
+			return
+		}
+		from := *enrollment.ExpiresAt
+		if from.Before(now) {
+			from = now
+		}
+		update["$set"] = bson.M{"expires_at": from.AddDate(0, 0, req.Days)}
+	}
+
+	err = enrollmentsCol.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&enrollment)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update access")
+		return
+	}
+
+	log.Printf("✅ Access updated: user=%s, course=%s, expires=%v", userID, courseID, enrollment.ExpiresAt)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Access updated successfully",
+		Data:    enrollment.withAccess(now),
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RevokeEnrollmentAccess withdraws a learner's access to a course. Unlike
+// unenrolling, the learner can't undo it by enrolling again.
+func RevokeEnrollmentAccess(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	now := time.Now()
+	var enrollment Enrollment
+	err := enrollmentsCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"user_id": userID, "course_id": courseID, "status": EnrollmentActive}),
+		bson.M{"$set": bson.M{"status": EnrollmentRevoked, "unenrolled_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&enrollment)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Enrollment not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to revoke access")
+		return
+	}
+
+	log.Printf("✅ Access revoked: user=%s, course=%s", userID, courseID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Access revoked successfully",
+		Data:    enrollment.withAccess(now),
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// ENROLLMENT HELPERS
+// ============================================================================
+
+// withAccess fills in the computed access fields as of now
+func (e Enrollment) withAccess(now time.Time) Enrollment {
+	if e.ExpiresAt == nil {
+		return e
+	}
+	e.Expired = !now.Before(*e.ExpiresAt)
+	days := 0
+	if !e.Expired {
+		days = int((e.ExpiresAt.Sub(now) + 24*time.Hour - 1) / (24 * time.Hour))
+	}
+	e.RemainingDays = &days
+	return e
+}
+
+// withCourseProgress fills in each of a user's enrollments with how many of
+// its course's chapters they have completed
+func withCourseProgress(ctx context.Context, userID string, enrollments []Enrollment) error {
+	if len(enrollments) == 0 {
+		return nil
+	}
+	courseIDs := make([]string, 0, len(enrollments))
+	for _, e := range enrollments {
+		courseIDs = append(courseIDs, e.CourseID)
+	}
+	cursor, err := coursesCol.Find(ctx, tenantFilter(ctx, bson.M{"course_id": bson.M{"$in": courseIDs}}),
+		options.Find().SetProjection(bson.M{"course_id": 1, "chapter_ids": 1}))
+	if err != nil {
+		return err
+	}
+	var courses []Course
+	if err := cursor.All(ctx, &courses); err != nil {
+		return err
+	}
+
+	courseChapters := make(map[string][]string, len(courses))
+	var chapterIDs []string
+	for _, course := range courses {
+		courseChapters[course.CourseID] = uniqueStrings(course.ChapterIDs)
+		chapterIDs = append(chapterIDs, course.ChapterIDs...)
+	}
+	done := true
+	completed, _, err := progressStore.List(ctx, ProgressQuery{
+		UserID:     userID,
+		ChapterIDs: uniqueStrings(chapterIDs),
+		Completed:  &done,
+	})
+	if err != nil {
+		return err
+	}
+	isCompleted := make(map[string]bool, len(completed))
+	for _, p := range completed {
+		isCompleted[p.ChapterID] = true
+	}
+
+	for i, e := range enrollments {
+		progress := EnrollmentProgress{TotalChapters: len(courseChapters[e.CourseID])}
+		for _, chapterID := range courseChapters[e.CourseID] {
+			if isCompleted[chapterID] {
+				progress.CompletedChapters++
+			}
+		}
+		if progress.TotalChapters > 0 {
+			progress.Percent = progress.CompletedChapters * 100 / progress.TotalChapters
+		}
+		enrollments[i].Progress = &progress
+	}
+	return nil
+}
+
+// markEnrollmentCompleted records when the user first completed every
+// chapter of a course they are enrolled in
+func markEnrollmentCompleted(ctx context.Context, userID, courseID string) error {
+	_, err := enrollmentsCol.UpdateOne(ctx,
+		tenantFilter(ctx, bson.M{"user_id": userID, "course_id": courseID, "completed_at": bson.M{"$exists": false}}),
+		bson.M{"$set": bson.M{"completed_at": time.Now()}})
+	return err
+}
+
+// hasAccess reports whether an enrollment currently grants access
+func (e Enrollment) hasAccess(now time.Time) bool {
+	return e.Status == EnrollmentActive && (e.ExpiresAt == nil || now.Before(*e.ExpiresAt))
+}
+
+// enrollUser creates or reactivates an enrollment, with access for
+// accessDays (0 for lifetime access). Enrolling twice is a no-op that
+// returns the existing enrollment, even if its access has expired, and
+// revoked enrollments can only be restored by an admin.
+func enrollUser(ctx context.Context, userID, courseID, source string, accessDays int) (*Enrollment, error) {
+	var enrollment Enrollment
+	filter := tenantFilter(ctx, bson.M{"user_id": userID, "course_id": courseID})
+	err := enrollmentsCol.FindOne(ctx, filter).Decode(&enrollment)
+	if err == nil && (enrollment.Status == EnrollmentActive ||
+		(enrollment.Status == EnrollmentRevoked && source != EnrollmentSourceAdmin)) {
+		return &enrollment, nil
+	} else if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"status":      EnrollmentActive,
+		"source":      source,
+		"enrolled_at": now,
+	}
+	unset := bson.M{"unenrolled_at": ""}
+	switch {
+	case enrollment.ExpiresAt != nil && source != EnrollmentSourceAdmin:
+		// Leaving and rejoining must not reset the access window
+	case accessDays > 0:
+		set["expires_at"] = now.AddDate(0, 0, accessDays)
+	default:
+		unset["expires_at"] = ""
+	}
+
+	err = retryOnDuplicateKey(func() error {
+		return enrollmentsCol.FindOneAndUpdate(ctx, filter,
+			bson.M{
+				"$set":         set,
+				"$unset":       unset,
+				"$setOnInsert": bson.M{"public_id": newPublicID(), "org_id": orgID(ctx)},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&enrollment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ User enrolled: user=%s, course=%s, source=%s", userID, courseID, source)
+	return &enrollment, nil
+}
+
+// enrolledChapterIDs returns the chapters of every course the user currently
+// has access to. Without MongoDB there are no courses, and the user has
+// access to every chapter.
+func enrolledChapterIDs(ctx context.Context, userID string) (map[string]bool, error) {
+	if !mongoConnected() {
+		chapters, err := chapterStore.List(ctx, ChapterQuery{})
+		if err != nil {
+			return nil, err
+		}
+		chapterIDs := make(map[string]bool, len(chapters))
+		for _, chapter := range chapters {
+			chapterIDs[chapter.ChapterID] = true
+		}
+		return chapterIDs, nil
+	}
+
+	courseIDs, err := enrollmentsCol.Distinct(ctx, "course_id", tenantFilter(ctx, bson.M{
+		"user_id": userID,
+		"status":  EnrollmentActive,
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": time.Now()}},
+		},
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	chapterIDs := map[string]bool{}
+	if len(courseIDs) == 0 {
+		return chapterIDs, nil
+	}
+
+	cursor, err := coursesCol.Find(ctx, tenantFilter(ctx, bson.M{"course_id": bson.M{"$in": courseIDs}}),
+		options.Find().SetProjection(bson.M{"chapter_ids": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var courses []Course
+	if err := cursor.All(ctx, &courses); err != nil {
+		return nil, err
+	}
+	for _, course := range courses {
+		for _, id := range course.ChapterIDs {
+			chapterIDs[id] = true
+		}
+	}
+	return chapterIDs, nil
+}
+
+// checkEnrolled sends a 403 and returns false unless the user has access to
+// a course containing the chapter
+func checkEnrolled(ctx context.Context, w http.ResponseWriter, userID, chapterID string) bool {
+	chapterIDs, err := enrolledChapterIDs(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if chapterIDs[chapterID] {
+		return true
+	}
+
+	// Tell expired learners apart from ones who never enrolled, which
+	// without MongoDB are all of them
+	if !mongoConnected() {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeNotEnrolled, "Enroll in the course to access this chapter")
+		return false
+	}
+	courseIDs, err := courseIDsForChapter(ctx, chapterID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	expired, err := enrollmentsCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{
+		"user_id":    userID,
+		"course_id":  bson.M{"$in": courseIDs},
+		"status":     EnrollmentActive,
+		"expires_at": bson.M{"$lte": time.Now()},
+	}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if expired > 0 {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeAccessExpired, "Your access to this course has expired")
+		return false
+	}
+	sendErrorCode(w, http.StatusForbidden, ErrCodeNotEnrolled, "Enroll in the course to access this chapter")
+	return false
+}
+
+// backfillDefaultEnrollments enrolls users who predate enrollments in the
+// default course, so they keep seeing the chapters they always had. Those
+// users all predate organizations too, so it is the default organization's.
+func backfillDefaultEnrollments(ctx context.Context) error {
+	enrolled, err := enrollmentsCol.Distinct(ctx, "user_id", bson.M{})
+	if err != nil {
+		return err
+	}
+
+	userIDs, err := usersCol.Distinct(ctx, "user_id", bson.M{"user_id": bson.M{"$nin": enrolled}, "org_id": defaultOrgID})
+	if err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		if userID, ok := id.(string); ok {
+			if _, err := enrollUser(ctx, userID, defaultCourseID, EnrollmentSourceDefault, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// RESPONSE ENVELOPE
+// ============================================================================
+
+// Every response is an ApiResponse: "success" and "message" always, "data"
+// always (never null on success), "code" and "errors" only when they apply.
+// Inside data, nil slices and maps are sent as [] and {} rather than null.
+
+// Clients that don't want the envelope ask for raw responses with
+// ?envelope=false or an Accept of application/json;profile=raw. They get the
+// data alone on success, and only code, message and errors on failure; the
+// HTTP status is the only success signal.
+
+// emptyObject is the data of a successful response that has nothing to return
+var emptyObject = struct{}{}
+
+// normalizeEnvelope translates the message and fills in empty data before a
+// response is encoded
+func normalizeEnvelope(w http.ResponseWriter, data interface{}) interface{} {
+	switch resp := data.(type) {
+	case ApiResponse:
+		return resp.normalized(w)
+	case LoginResponse:
+		resp.ApiResponse = resp.ApiResponse.normalized(w)
+		return resp
+	case GetProgressResponse:
+		resp.ApiResponse = resp.ApiResponse.normalized(w)
+		if resp.Progress == nil {
+			resp.Progress = []Progress{}
+		}
+		return resp
+	}
+	return nonNil(reflect.ValueOf(data)).Interface()
+}
+
+func (resp ApiResponse) normalized(w http.ResponseWriter) ApiResponse {
+	resp.Message = T(responseLocale(w), resp.Message)
+	if resp.Data == nil {
+		if resp.Success {
+			resp.Data = emptyObject
+		}
+		return resp
+	}
+	resp.Data = nonNil(reflect.ValueOf(resp.Data)).Interface()
+	return resp
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// nonNil returns a copy of v in which every nil slice or map reachable
+// through exported fields is replaced by an empty one. Values that marshal
+// themselves (time.Time, ObjectIDs) are left alone.
+func nonNil(v reflect.Value) reflect.Value {
+	if !v.IsValid() || v.Type().Implements(jsonMarshalerType) {
+		return v
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(nonNil(v.Elem()))
+		return out
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(nonNil(v.Elem()))
+		return out
+
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := out.Field(i); field.CanSet() {
+				field.Set(nonNil(v.Field(i)))
+			}
+		}
+		return out
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.MakeSlice(v.Type(), 0, 0)
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v // []byte encodes as a base64 string
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(nonNil(v.Index(i)))
+		}
+		return out
+
+	case reflect.Map:
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), nonNil(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
+
+// RawError is the body of a failed raw response
+type RawError struct {
+	Code    string       `json:"code,omitempty"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	Data    interface{}  `json:"data,omitempty"` // details some errors carry, e.g. a chapter lock
+}
+
+// responseMeta returns the page meta of an enveloped response, if any
+func responseMeta(data interface{}) *PageMeta {
+	switch v := data.(type) {
+	case ApiResponse:
+		return v.Meta
+	case GetProgressResponse:
+		return v.Meta
+	}
+	return nil
+}
+
+// unwrapEnvelope turns a normalized response into its raw form
+func unwrapEnvelope(data interface{}) interface{} {
+	var resp ApiResponse
+	switch v := data.(type) {
+	case ApiResponse:
+		resp = v
+	case LoginResponse:
+		resp = v.ApiResponse
+	case GetProgressResponse:
+		resp = v.ApiResponse
+	default:
+		return data
+	}
+	if !resp.Success {
+		return RawError{Code: resp.Code, Message: resp.Message, Errors: resp.Errors, Data: resp.Data}
+	}
+	return resp.Data
+}
+
+// ============================================================================
+// ENVELOPE MIDDLEWARE
+// ============================================================================
+
+// envelopeResponseWriter marks a response as raw for sendJSON
+type envelopeResponseWriter struct {
+	http.ResponseWriter
+	raw bool
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *envelopeResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// EnvelopeMiddleware picks raw or enveloped responses for the request
+func EnvelopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		next.ServeHTTP(&envelopeResponseWriter{ResponseWriter: w, raw: wantsRaw(r)}, r)
+	})
+}
+
+// wantsRaw reports whether the client opted out of the envelope
+func wantsRaw(r *http.Request) bool {
+	if v := r.URL.Query().Get("envelope"); v != "" {
+		enveloped, err := strconv.ParseBool(v)
+		return err == nil && !enveloped
+	}
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == "application/json" && params["profile"] == "raw" {
+			return true
+		}
+	}
+	return false
+}
+
+// rawResponse reports whether a response should be sent without the
+// envelope, looking through any writers other middleware wrapped around it
+func rawResponse(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *envelopeResponseWriter:
+			return rw.raw
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
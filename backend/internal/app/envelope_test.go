@@ -0,0 +1,231 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The contract tests hold the API to the envelope envelope.go describes:
+// every JSON response has "success", "message" and "data", a failure has a
+// "code", and a success never has null where a list or object goes.
+
+// pathVariable matches the variables of a route template
+var pathVariable = regexp.MustCompile(`\{[^}]+\}`)
+
+// checkEnvelope fails t unless body is an envelope fit for status
+func checkEnvelope(t *testing.T, status int, body []byte) {
+	t.Helper()
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		t.Fatalf("not a JSON object: %v\n%s", err, body)
+	}
+	var success bool
+	if err := json.Unmarshal(envelope["success"], &success); err != nil {
+		t.Fatalf(`"success" isn't a boolean: %s`, body)
+	}
+	var message string
+	if err := json.Unmarshal(envelope["message"], &message); err != nil {
+		t.Fatalf(`"message" isn't a string: %s`, body)
+	}
+	data, ok := envelope["data"]
+	if !ok {
+		t.Fatalf(`no "data": %s`, body)
+	}
+	if success != (status < 400) {
+		t.Fatalf("success is %v with status %d: %s", success, status, body)
+	}
+
+	if !success {
+		var code string
+		if err := json.Unmarshal(envelope["code"], &code); err != nil || code == "" {
+			t.Fatalf(`a failure without a "code": %s`, body)
+		}
+		return
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	checkNoNulls(t, "data", v)
+}
+
+// checkNoNulls fails t if v, decoded JSON, holds a null
+func checkNoNulls(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	switch v := v.(type) {
+	case nil:
+		t.Fatalf("%s is null", path)
+	case []interface{}:
+		for i, item := range v {
+			checkNoNulls(t, path+"["+strconv.Itoa(i)+"]", item)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			checkNoNulls(t, path+"."+key, value)
+		}
+	}
+}
+
+// TestDocumentedRoutesAnswerWithEnvelope sends a request to every route the
+// OpenAPI document lists, with made-up path variables and no body, and
+// checks that whatever comes back is an envelope
+func TestDocumentedRoutesAnswerWithEnvelope(t *testing.T) {
+	token := login(t, testUserID("contract")).AccessToken
+
+	keys := make([]string, 0, len(routeDocs))
+	for key := range routeDocs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if routeDocs[key].Content != "" {
+			continue // documented as not the envelope
+		}
+		method, template, _ := strings.Cut(key, " ")
+		path := pathVariable.ReplaceAllString(template, "x")
+		t.Run(key, func(t *testing.T) {
+			for _, bearer := range []string{"", token} {
+				req := httptest.NewRequest(method, path, nil)
+				if bearer != "" {
+					req.Header.Set("Authorization", "Bearer "+bearer)
+				}
+				rec := httptest.NewRecorder()
+				testServer.ServeHTTP(rec, req)
+
+				if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+					t.Fatalf("%s %s: Content-Type %q", method, path, contentType)
+				}
+				checkEnvelope(t, rec.Code, rec.Body.Bytes())
+			}
+		})
+	}
+}
+
+func TestSuccessEnvelopes(t *testing.T) {
+	userID := testUserID("contract")
+	res := do(t, "POST", "/api/login", "", LoginRequest{UserID: userID})
+	checkEnvelope(t, res.Status, res.Body)
+	var user AuthenticatedUser
+	res.data(t, &user)
+	token := user.AccessToken
+
+	tests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"GET", "/api/health", nil},
+		{"GET", "/api/health/deep", nil},
+		{"GET", "/livez", nil},
+		{"GET", "/readyz", nil},
+		{"GET", "/api/chapters", nil},
+		{"GET", "/api/chapters/chapter_1", nil},
+		{"GET", "/api/progress/" + userID, nil},
+		{"GET", "/api/progress/" + userID + "/chapter_1", nil},
+		{"POST", "/api/progress/video", UpdateVideoProgressRequest{UserID: userID, ChapterID: "chapter_1", Progress: 30}},
+		{"POST", "/api/progress/quiz", UpdateQuizProgressRequest{UserID: userID, ChapterID: "chapter_1", Answer: choiceAnswer(0)}},
+		{"GET", "/api/progress/" + userID, nil},
+		{"GET", "/api/sessions", nil},
+	}
+	for _, test := range tests {
+		res := do(t, test.method, test.path, token, test.body)
+		if res.Status >= 400 {
+			t.Fatalf("%s %s: %d %s", test.method, test.path, res.Status, res.Body)
+		}
+		checkEnvelope(t, res.Status, res.Body)
+	}
+}
+
+// TestEmptyListsAreArrays checks the listings of a user with nothing in
+// them send [] rather than null
+func TestEmptyListsAreArrays(t *testing.T) {
+	userID := testUserID("contract")
+	token := login(t, userID).AccessToken
+
+	res := do(t, "GET", "/api/progress/"+userID, token, nil)
+	var body struct {
+		Data     json.RawMessage `json:"data"`
+		Progress json.RawMessage `json:"progress"`
+	}
+	if err := json.Unmarshal(res.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if string(body.Data) != "[]" || string(body.Progress) != "[]" {
+		t.Fatalf("got data %s and progress %s, want []", body.Data, body.Progress)
+	}
+}
+
+func TestErrorEnvelopes(t *testing.T) {
+	token := login(t, testUserID("contract")).AccessToken
+
+	tests := []struct {
+		name         string
+		method, path string
+		token        string
+		body         interface{}
+		status       int
+		code         string
+	}{
+		{"unknown route", "GET", "/api/nothing-here", token, nil, http.StatusNotFound, ErrCodeNotFound},
+		{"wrong method", "DELETE", "/api/chapters", token, nil, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed},
+		{"no token", "GET", "/api/sessions", "", nil, http.StatusUnauthorized, ErrCodeUnauthorized},
+		{"bad token", "GET", "/api/sessions", "not-a-token", nil, http.StatusUnauthorized, ErrCodeUnauthorized},
+		{"missing field", "POST", "/api/login", "", LoginRequest{}, http.StatusBadRequest, ErrCodeValidationFailed},
+		{"unknown chapter", "GET", "/api/chapters/no_such_chapter", token, nil, http.StatusNotFound, ErrCodeChapterNotFound},
+		{"refresh token", "POST", "/api/token/refresh", "", RefreshTokenRequest{RefreshToken: "nope"}, http.StatusUnauthorized, ErrCodeInvalidRefreshToken},
+		{"no MongoDB", "GET", "/api/courses", token, nil, http.StatusNotImplemented, ErrCodeMongoRequired},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(t, test.method, test.path, test.token, test.body)
+			if res.Status != test.status || res.Code != test.code {
+				t.Fatalf("got %d %q, want %d %q: %s", res.Status, res.Code, test.status, test.code, res.Body)
+			}
+			checkEnvelope(t, res.Status, res.Body)
+		})
+	}
+
+	// A wrong method lists the right ones
+	res := do(t, "DELETE", "/api/chapters", token, nil)
+	if allow := res.Header.Get("Allow"); allow != "GET" {
+		t.Fatalf("got Allow %q, want GET", allow)
+	}
+
+	// A validation failure names the field
+	res = do(t, "POST", "/api/login", "", LoginRequest{})
+	if len(res.Errors) != 1 || res.Errors[0].Field != "userId" || res.Errors[0].Code != CodeRequired {
+		t.Fatalf("got errors %+v, want userId required", res.Errors)
+	}
+}
+
+// TestRawResponses checks ?envelope=false sends the data alone, and a
+// failure's code and message without "success"
+func TestRawResponses(t *testing.T) {
+	token := login(t, testUserID("contract")).AccessToken
+
+	req := httptest.NewRequest("GET", "/api/chapters?envelope=false", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	testServer.ServeHTTP(rec, req)
+	var chapters []Chapter
+	if err := json.Unmarshal(rec.Body.Bytes(), &chapters); err != nil || len(chapters) == 0 {
+		t.Fatalf("raw chapters: %v\n%s", err, rec.Body)
+	}
+
+	req = httptest.NewRequest("GET", "/api/chapters/no_such_chapter?envelope=false", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec = httptest.NewRecorder()
+	testServer.ServeHTTP(rec, req)
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["success"]; ok || string(raw["code"]) != `"`+ErrCodeChapterNotFound+`"` {
+		t.Fatalf("raw error: %s", rec.Body)
+	}
+}
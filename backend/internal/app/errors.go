@@ -0,0 +1,112 @@
+package app
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================================================
+// ERROR CODES
+// ============================================================================
+
+// Every failed response carries a machine-readable code, so clients can
+// branch on it instead of on the message, which is translated and may be
+// reworded. Failures particular to a feature have their own code, defined
+// next to it (ErrCodeRateLimited, ErrCodeVersionConflict, ...); the rest get
+// the code for their status from statusErrorCodes. Codes are never renamed
+// once released.
+
+// Error codes shared by many routes
+const (
+	ErrCodeBadRequest       = "bad_request"
+	ErrCodeValidationFailed = "validation_failed"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeUserNotFound     = "user_not_found"
+	ErrCodeChapterNotFound  = "chapter_not_found"
+	ErrCodeCourseNotFound   = "course_not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeConflict         = "conflict"
+	ErrCodeGone             = "gone"
+	ErrCodePayloadTooLarge  = "payload_too_large"
+	ErrCodeUnprocessable    = "unprocessable"
+	ErrCodeInternal         = "internal_error"
+	ErrCodeNotImplemented   = "not_implemented"
+	ErrCodeUnavailable      = "unavailable"
+	ErrCodeTimeout          = "timeout"
+)
+
+// statusErrorCodes is the code of a failure that has none of its own
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            ErrCodeBadRequest,
+	http.StatusUnauthorized:          ErrCodeUnauthorized,
+	http.StatusForbidden:             ErrCodeForbidden,
+	http.StatusNotFound:              ErrCodeNotFound,
+	http.StatusMethodNotAllowed:      ErrCodeMethodNotAllowed,
+	http.StatusConflict:              ErrCodeConflict,
+	http.StatusGone:                  ErrCodeGone,
+	http.StatusRequestEntityTooLarge: ErrCodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  ErrCodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   ErrCodeUnprocessable,
+	http.StatusTooManyRequests:       ErrCodeRateLimited,
+	http.StatusInternalServerError:   ErrCodeInternal,
+	http.StatusNotImplemented:        ErrCodeNotImplemented,
+	http.StatusServiceUnavailable:    ErrCodeUnavailable,
+	http.StatusGatewayTimeout:        ErrCodeTimeout,
+}
+
+// errorCodeForStatus returns the code for a failure with the given status
+func errorCodeForStatus(status int) string {
+	if code, ok := statusErrorCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return ErrCodeInternal
+	}
+	return ErrCodeBadRequest
+}
+
+// routeMethods are the methods the routes use
+var routeMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// routeNotFound answers requests no route matches, in the usual envelope.
+// gorilla/mux reports a wrong method inside a subrouter as not found once a
+// later route of the subrouter is tried, so a path that another method
+// serves is answered as not allowed here.
+func routeNotFound(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if allowed := allowedMethods(router, r); len(allowed) > 0 {
+			sendMethodNotAllowed(w, allowed)
+			return
+		}
+		sendErrorCode(w, http.StatusNotFound, ErrCodeNotFound, "Route not found")
+	}
+}
+
+// methodNotAllowed answers requests for a route with another method
+func methodNotAllowed(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sendMethodNotAllowed(w, allowedMethods(router, r))
+	}
+}
+
+// allowedMethods returns the methods router has a route for at r's path
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	allowed := []string{}
+	for _, method := range routeMethods {
+		other := r.Clone(r.Context())
+		other.Method = method
+		var match mux.RouteMatch
+		if router.Match(other, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+// sendMethodNotAllowed answers 405, listing the allowed methods in Allow
+func sendMethodNotAllowed(w http.ResponseWriter, allowed []string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	sendErrorCode(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "Method not allowed")
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// EVENT BUS
+// ============================================================================
+
+// With EVENT_BUS set to nats or kafka, domain events are published to
+// topics on EVENT_BUS_URL so downstream analytics and recommendation
+// services can consume them instead of polling MongoDB. Events are queued
+// in memory and published in batches off the request path; when the bus is
+// down or the queue is full they are logged and dropped. Kafka support is
+// only in builds with -tags kafka.
+
+// Event buses, chosen with EVENT_BUS
+const (
+	EventBusNATS  = "nats"
+	EventBusKafka = "kafka"
+)
+
+// Kinds of domain event; with the topic prefix they are the topic names
+const (
+	DomainProgressUpdated = "progress.updated"
+	DomainQuizSubmitted   = "quiz.submitted"
+	DomainUserCreated     = "user.created"
+	DomainUserDeleted     = "user.deleted"
+)
+
+const (
+	// defaultEventTopicPrefix is put before event types to name topics
+	defaultEventTopicPrefix = "learning."
+	// eventBusQueueSize bounds the events waiting to be published
+	eventBusQueueSize = 10000
+	// eventBusBatchSize bounds the events published at once
+	eventBusBatchSize = 100
+	// eventBusTimeout bounds publishing one batch
+	eventBusTimeout = 5 * time.Second
+)
+
+// DomainEvent is the message published for each event
+type DomainEvent struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OrgID      string      `json:"orgId"`
+	UserID     string      `json:"userId"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// busMessage is an encoded event on its way to a topic. Kafka partitions
+// by key, so each user's events stay in order; JetStream drops a second
+// message with the same ID.
+type busMessage struct {
+	ID      string
+	Topic   string
+	Key     string
+	Payload []byte
+}
+
+// eventPublisher sends messages to a message bus
+type eventPublisher interface {
+	Publish(ctx context.Context, messages []busMessage) error
+	Close() error
+}
+
+// kafkaPublisher connects to Kafka brokers. Only builds with -tags kafka set
+// it; see event_bus_kafka.go.
+var kafkaPublisher func(brokers []string) (eventPublisher, error)
+
+// eventBus publishes the queued events; nil when EVENT_BUS is unset
+type eventBus struct {
+	publisher eventPublisher
+	prefix    string
+	queue     chan busMessage
+	done      chan struct{}
+}
+
+var domainEvents *eventBus
+
+// setupEventBus connects to the bus EVENT_BUS names, if any, and starts
+// publishing. Without a usable bus events aren't published.
+func setupEventBus(c Config) {
+	kind := c.EventBus
+	if kind == "" {
+		return
+	}
+	busURL := c.EventBusURL
+
+	var publisher eventPublisher
+	var err error
+	switch kind {
+	case EventBusNATS:
+		publisher, err = newNATSPublisher(busURL)
+	case EventBusKafka:
+		if kafkaPublisher == nil {
+			log.Println("⚠️ EVENT_BUS is kafka but this build has no Kafka support; build with -tags kafka")
+			return
+		}
+		publisher, err = kafkaPublisher(strings.Split(busURL, ","))
+	default:
+		err = fmt.Errorf("unknown EVENT_BUS %q, expected nats or kafka", kind)
+	}
+	if err != nil {
+		log.Printf("❌ Error setting up the event bus, continuing without it: %v", err)
+		return
+	}
+
+	domainEvents = &eventBus{
+		publisher: publisher,
+		prefix:    c.EventBusTopicPrefix,
+		queue:     make(chan busMessage, eventBusQueueSize),
+		done:      make(chan struct{}),
+	}
+	go domainEvents.run()
+	log.Printf("📣 Publishing domain events to %s", kind)
+}
+
+// publishDomainEvent queues an event for the bus, stamped with the request's
+// organization unless it names one. It never blocks: with the queue full
+// the event is dropped.
+func publishDomainEvent(ctx context.Context, event DomainEvent) {
+	if domainEvents == nil {
+		return
+	}
+	if event.OrgID == "" {
+		event.OrgID = orgID(ctx)
+	}
+	event.ID = newPublicID()
+	event.OccurredAt = time.Now()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("❌ Error encoding %s event: %v", event.Type, err)
+		return
+	}
+
+	select {
+	case domainEvents.queue <- busMessage{ID: event.ID, Topic: domainEvents.prefix + event.Type, Key: event.UserID, Payload: payload}:
+	default:
+		log.Printf("❌ Event bus queue full, dropping %s event for %s", event.Type, event.UserID)
+	}
+}
+
+// publishProgressEvent publishes a chapter's progress after a write
+func publishProgressEvent(ctx context.Context, progress Progress) {
+	publishDomainEvent(ctx, DomainEvent{Type: DomainProgressUpdated, OrgID: progress.OrgID, UserID: progress.UserID, Data: progress})
+}
+
+// run publishes queued events in batches until the queue is closed
+func (b *eventBus) run() {
+	defer close(b.done)
+	for message := range b.queue {
+		batch := []busMessage{message}
+	fill:
+		for len(batch) < eventBusBatchSize {
+			select {
+			case next, ok := <-b.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), eventBusTimeout)
+		if err := b.publisher.Publish(ctx, batch); err != nil {
+			log.Printf("❌ Error publishing %d domain events: %v", len(batch), err)
+		}
+		cancel()
+	}
+}
+
+// stopEventBus publishes the events still queued, waiting until ctx is
+// done at most, and disconnects
+func stopEventBus(ctx context.Context) {
+	if domainEvents == nil {
+		return
+	}
+	close(domainEvents.queue)
+	select {
+	case <-domainEvents.done:
+	case <-ctx.Done():
+		log.Println("⚠️ Gave up publishing the last domain events")
+	}
+	if err := domainEvents.publisher.Close(); err != nil {
+		log.Printf("❌ Error closing the event bus: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build kafka
+
+package app
+
+// Kafka publishing of domain events, in builds with -tags kafka. The
+// module is in go.mod, so the tag is all it takes.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func init() {
+	kafkaPublisher = newKafkaPublisher
+}
+
+type kafkaEventPublisher struct {
+	writer *kafka.Writer
+}
+
+// newKafkaPublisher writes to the brokers, a message's topic coming from
+// the message. Keys are hashed to partitions, so each user's events keep
+// their order.
+func newKafkaPublisher(brokers []string) (eventPublisher, error) {
+	addrs := []string{}
+	for _, b := range brokers {
+		if b = strings.TrimSpace(b); b != "" {
+			addrs = append(addrs, b)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("EVENT_BUS_URL must list the Kafka brokers, e.g. kafka-1:9092,kafka-2:9092")
+	}
+	return &kafkaEventPublisher{writer: &kafka.Writer{
+		Addr:                   kafka.TCP(addrs...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		BatchTimeout:           10 * time.Millisecond,
+		AllowAutoTopicCreation: true,
+	}}, nil
+}
+
+func (p *kafkaEventPublisher) Publish(ctx context.Context, messages []busMessage) error {
+	batch := make([]kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		batch = append(batch, kafka.Message{Topic: m.Topic, Key: []byte(m.Key), Value: m.Payload})
+	}
+	return p.writer.WriteMessages(ctx, batch...)
+}
+
+func (p *kafkaEventPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ============================================================================
+// NATS PUBLISHER
+// ============================================================================
+
+// natsPublisher publishes to JetStream, whose server must have a stream
+// capturing the topics. A message counts as published once the stream
+// acknowledges storing it. Each carries its event's ID as Nats-Msg-Id, so
+// the stream drops the copies of a batch sent again after a lost
+// acknowledgement. nats.go reconnects by itself, buffering what is published
+// meanwhile.
+type natsPublisher struct {
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+const natsDefaultTimeout = 2 * time.Second
+
+// newNATSPublisher connects to the servers of a nats:// or tls:// URL,
+// several separated by commas. A server that is down is retried in the
+// background rather than failing startup.
+func newNATSPublisher(rawURL string) (*natsPublisher, error) {
+	conn, err := nats.Connect(rawURL,
+		nats.Name("resume-learning-backend"),
+		nats.Timeout(natsDefaultTimeout),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := conn.JetStream(nats.PublishAsyncMaxPending(eventBusBatchSize))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &natsPublisher{conn: conn, js: js}, nil
+}
+
+// Publish sends a batch and waits for the stream to acknowledge every
+// message. A batch that fails is sent once more; messages already stored
+// are dropped by the stream as duplicates.
+func (p *natsPublisher) Publish(ctx context.Context, messages []busMessage) error {
+	err := p.publish(ctx, messages)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	return p.publish(ctx, messages)
+}
+
+func (p *natsPublisher) publish(ctx context.Context, messages []busMessage) error {
+	acks := make([]nats.PubAckFuture, 0, len(messages))
+	for _, m := range messages {
+		ack, err := p.js.PublishAsync(m.Topic, m.Payload, nats.MsgId(m.ID))
+		if err != nil {
+			return err
+		}
+		acks = append(acks, ack)
+	}
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close sends what is buffered and disconnects
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
+
+// validNATSSubject reports whether the server accepts subject for
+// publishing: dot-separated tokens without spaces or wildcards
+func validNATSSubject(subject string) bool {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n*>") {
+		return false
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return false
+		}
+	}
+	return true
+}
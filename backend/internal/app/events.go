@@ -0,0 +1,322 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// EVENT MODELS
+// ============================================================================
+
+// Clients report what learners do in the player and the app as analytics
+// events, so product analytics don't have to be pieced together from
+// progress documents. Events arrive in batches, are buffered in memory and
+// written in bulk to analytics_events, a time-series collection that
+// MongoDB partitions by time and expires after ANALYTICS_EVENT_RETENTION_DAYS.
+// A crash loses the events still buffered; analytics can afford that.
+
+// Kinds of analytics event
+const (
+	EventVideoPlay  = "video_play"
+	EventVideoPause = "video_pause"
+	EventSeek       = "seek"
+	EventQuizOpen   = "quiz_open"
+	EventAppOpen    = "app_open"
+)
+
+var knownEventTypes = map[string]bool{
+	EventVideoPlay: true, EventVideoPause: true, EventSeek: true,
+	EventQuizOpen: true, EventAppOpen: true,
+}
+
+const (
+	// maxEventsPerRequest bounds one batch from a client
+	maxEventsPerRequest = 100
+	// maxEventProperties bounds an event's free-form properties
+	maxEventProperties = 20
+	// maxEventAge is how long a client may hold on to events, e.g. while
+	// offline, before they are refused
+	maxEventAge = 7 * 24 * time.Hour
+	// maxEventClockSkew is how far in the future an event may claim to be
+	maxEventClockSkew = 5 * time.Minute
+	// eventFlushInterval is how often buffered events are written
+	eventFlushInterval = 5 * time.Second
+	// eventFlushSize writes the buffer early once this many events wait
+	eventFlushSize = 500
+	// maxBufferedEvents refuses new events while the database is behind
+	maxBufferedEvents = 10000
+	// defaultEventRetentionDays is how long events are kept
+	defaultEventRetentionDays = 90
+)
+
+// AnalyticsEvent is one thing a learner did in a client. Clients send the
+// type, time and details; the server fills in who and where from.
+type AnalyticsEvent struct {
+	At         *time.Time             `bson:"ts" json:"at"` // defaults to when the server received it
+	Meta       EventMeta              `bson:"meta" json:"-"`
+	Type       string                 `bson:"type" json:"type"`
+	ChapterID  string                 `bson:"chapter_id,omitempty" json:"chapterId,omitempty"`
+	Position   *int                   `bson:"position,omitempty" json:"position,omitempty"` // video_play and video_pause, seconds into the video
+	From       *int                   `bson:"from,omitempty" json:"from,omitempty"`         // seek, seconds
+	To         *int                   `bson:"to,omitempty" json:"to,omitempty"`             // seek, seconds
+	Properties map[string]interface{} `bson:"properties,omitempty" json:"properties,omitempty"`
+	ReceivedAt time.Time              `bson:"received_at" json:"-"`
+}
+
+// EventMeta is who sent an event. It is the time-series meta field, so
+// MongoDB buckets each user's and device's events together.
+type EventMeta struct {
+	OrgID    string `bson:"org_id,omitempty"`
+	UserID   string `bson:"user_id,omitempty"` // unset before sign-in
+	Device   string `bson:"device"`
+	Platform string `bson:"platform"`
+}
+
+type TrackEventsRequest struct {
+	UserID   string           `json:"userId"`
+	DeviceID string           `json:"deviceId"`
+	Events   []AnalyticsEvent `json:"events"`
+}
+
+// TrackEventsResponse tells how many events were accepted
+type TrackEventsResponse struct {
+	Accepted int `json:"accepted"`
+}
+
+// eventBuffer holds accepted events until the writer flushes them
+type eventBuffer struct {
+	mu      sync.Mutex
+	pending []interface{}
+	full    chan struct{} // signalled when pending reaches eventFlushSize
+}
+
+var eventQueue = &eventBuffer{full: make(chan struct{}, 1)}
+
+// ============================================================================
+// EVENT HANDLERS
+// ============================================================================
+
+// TrackEvents accepts a batch of analytics events. Events may be sent before
+// sign-in, without a userId.
+func TrackEvents(w http.ResponseWriter, r *http.Request) {
+	var req TrackEventsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	// Validate input
+	var errs fieldErrors
+	if len(req.Events) == 0 {
+		errs.add("events", CodeRequired, "is required")
+	} else if len(req.Events) > maxEventsPerRequest {
+		errs.add("events", CodeOutOfRange, fmt.Sprintf("must have at most %d events", maxEventsPerRequest))
+	}
+	now := time.Now()
+	for i := range req.Events {
+		validateEvent(&errs, fmt.Sprintf("events[%d]", i), &req.Events[i], now)
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	meta := EventMeta{
+		OrgID:    orgID(ctx),
+		UserID:   userID,
+		Device:   requestDevice(r, req.DeviceID),
+		Platform: requestPlatform(r),
+	}
+	events := make([]interface{}, 0, len(req.Events))
+	for _, event := range req.Events {
+		if event.At == nil {
+			event.At = &now
+		}
+		if event.ChapterID != "" {
+			// Clients may send public IDs instead of business keys
+			event.ChapterID = resolveChapterKey(ctx, event.ChapterID)
+		}
+		event.Meta = meta
+		event.ReceivedAt = now
+		events = append(events, event)
+	}
+	if !eventQueue.add(events) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(eventFlushInterval.Seconds())))
+		sendError(w, http.StatusServiceUnavailable, "Too many events pending, try again later")
+		return
+	}
+
+	if userID != "" {
+		touchSession(ctx, r, userID, req.DeviceID)
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Events accepted",
+		Data:    TrackEventsResponse{Accepted: len(events)},
+	}
+	sendJSON(w, http.StatusAccepted, response)
+}
+
+// ============================================================================
+// EVENT HELPERS
+// ============================================================================
+
+// validateEvent checks one event of a batch, reporting errors under prefix
+func validateEvent(errs *fieldErrors, prefix string, event *AnalyticsEvent, now time.Time) {
+	if event.Type == "" {
+		errs.add(prefix+".type", CodeRequired, "is required")
+	} else if !knownEventTypes[event.Type] {
+		errs.add(prefix+".type", CodeUnknownValue, "must be one of video_play, video_pause, seek, quiz_open or app_open")
+	}
+	if event.At != nil {
+		if event.At.After(now.Add(maxEventClockSkew)) {
+			errs.add(prefix+".at", CodeOutOfRange, "must not be in the future")
+		} else if event.At.Before(now.Add(-maxEventAge)) {
+			errs.add(prefix+".at", CodeOutOfRange, "must be within the last 7 days")
+		}
+	}
+	if len(event.Properties) > maxEventProperties {
+		errs.add(prefix+".properties", CodeOutOfRange, fmt.Sprintf("must have at most %d properties", maxEventProperties))
+	}
+
+	notNegative := func(field string, v *int) {
+		if v == nil {
+			errs.add(prefix+"."+field, CodeRequired, "is required")
+		} else if *v < 0 {
+			errs.add(prefix+"."+field, CodeOutOfRange, "must not be negative")
+		}
+	}
+	switch event.Type {
+	case EventVideoPlay, EventVideoPause:
+		errs.required(prefix+".chapterId", event.ChapterID)
+		notNegative("position", event.Position)
+	case EventSeek:
+		errs.required(prefix+".chapterId", event.ChapterID)
+		notNegative("from", event.From)
+		notNegative("to", event.To)
+	case EventQuizOpen:
+		errs.required(prefix+".chapterId", event.ChapterID)
+	}
+}
+
+// add queues events for the writer. It reports false, queueing nothing,
+// when the buffer is full.
+func (b *eventBuffer) add(events []interface{}) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.pending)+len(events) > maxBufferedEvents {
+		return false
+	}
+	b.pending = append(b.pending, events...)
+	if len(b.pending) >= eventFlushSize {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// flush writes the buffered events. Events that fail to write are put back
+// for the next flush, as long as there is room.
+func (b *eventBuffer) flush(ctx context.Context) error {
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+
+	_, err := analyticsEventsCol.InsertMany(ctx, events, options.InsertMany().SetOrdered(false))
+	if err == nil {
+		return nil
+	}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		// Only the rejected events failed; retrying them won't help
+		return err
+	}
+
+	b.mu.Lock()
+	if len(b.pending)+len(events) <= maxBufferedEvents {
+		b.pending = append(events, b.pending...)
+	}
+	b.mu.Unlock()
+	return err
+}
+
+// startEventWriter flushes buffered events every eventFlushInterval, or
+// sooner when enough are waiting. It stops when ctx is cancelled; call
+// flushEvents afterwards to write what is left.
+func startEventWriter(ctx context.Context) {
+	ticker := time.NewTicker(eventFlushInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-eventQueue.full:
+			}
+			if err := eventQueue.flush(ctx); err != nil {
+				log.Printf("❌ Error writing analytics events: %v", err)
+			}
+		}
+	}()
+}
+
+// flushEvents writes the events still buffered, on shutdown
+func flushEvents(ctx context.Context) {
+	if err := eventQueue.flush(ctx); err != nil {
+		log.Printf("❌ Error writing analytics events: %v", err)
+	}
+}
+
+// createEventsCollection creates analytics_events as a time-series
+// collection, or updates its retention when it already exists. Time-series
+// collections need MongoDB 5.0; on older servers events go to a plain
+// collection, kept forever.
+func createEventsCollection(ctx context.Context) {
+	retention := int64(config.AnalyticsEventRetentionDays) * 24 * 60 * 60
+	err := database.CreateCollection(ctx, analyticsEventsCol.Name(), options.CreateCollection().
+		SetTimeSeriesOptions(options.TimeSeries().
+			SetTimeField("ts").
+			SetMetaField("meta").
+			SetGranularity("seconds")).
+		SetExpireAfterSeconds(retention))
+
+	var cmdErr mongo.CommandError
+	switch {
+	case err == nil:
+		log.Println("✅ Created analytics_events time-series collection")
+	case errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceExists":
+		err = database.RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: analyticsEventsCol.Name()},
+			{Key: "expireAfterSeconds", Value: retention},
+		}).Err()
+		if err != nil {
+			log.Printf("⚠️ Could not update analytics event retention: %v", err)
+		}
+	default:
+		log.Printf("⚠️ Could not create analytics_events as a time-series collection: %v", err)
+	}
+}
@@ -0,0 +1,436 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// PREREQUISITE GRAPH MODELS
+// ============================================================================
+
+// Graph node types
+const (
+	NodeChapter = "chapter"
+	NodePath    = "path"
+)
+
+// Per-user unlock states
+const (
+	UnlockLocked    = "locked"
+	UnlockUnlocked  = "unlocked"
+	UnlockCompleted = "completed"
+)
+
+// GraphNode is a chapter or learning path in the prerequisite graph
+type GraphNode struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status string `json:"status,omitempty"` // only with ?userId=
+}
+
+// GraphEdge points from a prerequisite to the node that depends on it
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// PrerequisiteGraph is the skill-tree view of the catalog
+type PrerequisiteGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type UpdatePrerequisitesRequest struct {
+	Prerequisites []string `json:"prerequisites"`
+}
+
+// ============================================================================
+// CYCLE DETECTION
+// ============================================================================
+
+// findCycle returns the nodes of a cycle in a dependency map (node -> its
+// prerequisites), or nil when the graph is acyclic
+func findCycle(deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(deps))
+	var stack []string
+
+	var visit func(node string) []string
+	visit = func(node string) []string {
+		state[node] = visiting
+		stack = append(stack, node)
+		for _, dep := range deps[node] {
+			switch state[dep] {
+			case visiting:
+				for i, n := range stack {
+					if n == dep {
+						return append(append([]string{}, stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[node] = visited
+		return nil
+	}
+
+	for node := range deps {
+		if state[node] == unvisited {
+			if cycle := visit(node); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// chapterDependencies loads chapter_id -> prerequisites for the chapters
+// a chapter of orgID may depend on: the shared catalog and the
+// organization's own. A shared chapter, with an empty orgID, may only depend
+// on other shared chapters.
+func chapterDependencies(ctx context.Context, orgID string) (map[string][]string, error) {
+	cursor, err := chaptersCol.Find(ctx, bson.M{"org_id": bson.M{"$in": bson.A{orgID, nil}}},
+		options.Find().SetProjection(bson.M{"chapter_id": 1, "prerequisites": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var chapters []Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return nil, err
+	}
+
+	deps := make(map[string][]string, len(chapters))
+	for _, chapter := range chapters {
+		deps[chapter.ChapterID] = chapter.Prerequisites
+	}
+	return deps, nil
+}
+
+// pathDependencies loads path_id -> prerequisites for every path of the
+// request's organization, which is where prerequisites come from
+func pathDependencies(ctx context.Context) (map[string][]string, error) {
+	cursor, err := pathsCol.Find(ctx, tenantFilter(ctx, bson.M{}),
+		options.Find().SetProjection(bson.M{"path_id": 1, "prerequisites": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var paths []LearningPath
+	if err := cursor.All(ctx, &paths); err != nil {
+		return nil, err
+	}
+
+	deps := make(map[string][]string, len(paths))
+	for _, path := range paths {
+		deps[path.PathID] = path.Prerequisites
+	}
+	return deps, nil
+}
+
+// ============================================================================
+// GRAPH HANDLERS
+// ============================================================================
+
+// GetPrerequisiteGraph returns chapters and paths as a graph, with per-user
+// unlock status when ?userId= is given
+func GetPrerequisiteGraph(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("userId")
+
+	ctx := r.Context()
+
+	cursor, err := chaptersCol.Find(ctx, liveChapters(ctx, bson.M{}), options.Find().SetSort(bson.D{{Key: "order", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch chapters")
+		return
+	}
+	var chapters []Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode chapters")
+		return
+	}
+
+	pathFilter := bson.M{"visibility": PathPublic}
+	if userID != "" {
+		pathFilter = bson.M{"$or": bson.A{bson.M{"visibility": PathPublic}, bson.M{"owner_id": userID}}}
+	}
+	cursor, err = pathsCol.Find(ctx, tenantFilter(ctx, pathFilter))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch paths")
+		return
+	}
+	var paths []LearningPath
+	if err := cursor.All(ctx, &paths); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode paths")
+		return
+	}
+
+	completedChapters := map[string]bool{}
+	completedPaths := map[string]bool{}
+	if userID != "" {
+		completedChapters, completedPaths, err = completedNodes(ctx, userID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch progress")
+			return
+		}
+	}
+
+	graph := PrerequisiteGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, chapter := range chapters {
+		node := GraphNode{ID: chapter.ChapterID, Type: NodeChapter, Title: chapter.Title}
+		if userID != "" {
+			node.Status = unlockStatus(chapter.ChapterID, chapter.Prerequisites, completedChapters)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		for _, prereq := range chapter.Prerequisites {
+			graph.Edges = append(graph.Edges, GraphEdge{From: prereq, To: chapter.ChapterID, Type: NodeChapter})
+		}
+	}
+	for _, path := range paths {
+		node := GraphNode{ID: path.PathID, Type: NodePath, Title: path.Title}
+		if userID != "" {
+			node.Status = unlockStatus(path.PathID, path.Prerequisites, completedPaths)
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		for _, prereq := range path.Prerequisites {
+			graph.Edges = append(graph.Edges, GraphEdge{From: prereq, To: path.PathID, Type: NodePath})
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Prerequisite graph fetched successfully",
+		Data:    graph,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateChapterPrerequisites replaces a chapter's prerequisites, rejecting
+// unknown chapters and cycles
+func UpdateChapterPrerequisites(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var req UpdatePrerequisitesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Prerequisites == nil {
+		req.Prerequisites = []string{}
+	}
+
+	ctx := r.Context()
+
+	var existing Chapter
+	err := chaptersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}),
+		options.FindOne().SetProjection(bson.M{"org_id": 1})).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	deps, err := chapterDependencies(ctx, existing.OrgID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	for _, prereq := range req.Prerequisites {
+		if _, ok := deps[prereq]; !ok {
+			sendError(w, http.StatusBadRequest, "Unknown prerequisite chapter: "+prereq)
+			return
+		}
+	}
+
+	deps[chapterID] = req.Prerequisites
+	if cycle := findCycle(deps); cycle != nil {
+		sendError(w, http.StatusConflict, "Prerequisites would create a cycle: "+strings.Join(cycle, " -> "))
+		return
+	}
+
+	var chapter Chapter
+	err = chaptersCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}),
+		bson.M{"$set": bson.M{"prerequisites": req.Prerequisites, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update prerequisites")
+		return
+	}
+
+	chapterWritten(ctx, chapter)
+	log.Printf("✅ Prerequisites updated: chapter=%s, prerequisites=%v", chapterID, req.Prerequisites)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Prerequisites updated successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// GRAPH HELPERS
+// ============================================================================
+
+// completedNodes returns the chapters and paths a user has completed.
+// Without MongoDB there are no paths, and the chapters come from the stores.
+func completedNodes(ctx context.Context, userID string) (map[string]bool, map[string]bool, error) {
+	chapters := map[string]bool{}
+	paths := map[string]bool{}
+
+	if !mongoConnected() {
+		catalog, err := chapterStore.List(ctx, ChapterQuery{})
+		if err != nil {
+			return nil, nil, err
+		}
+		completed := true
+		query := ProgressQuery{UserID: userID, ChapterIDs: []string{}, Completed: &completed}
+		for _, chapter := range catalog {
+			query.ChapterIDs = append(query.ChapterIDs, chapter.ChapterID)
+		}
+		progress, _, err := progressStore.List(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range progress {
+			chapters[p.ChapterID] = true
+		}
+		return chapters, paths, nil
+	}
+
+	cursor, err := progressCol.Find(ctx, bson.M{"user_id": userID, "chapter_completed": true})
+	if err != nil {
+		return nil, nil, err
+	}
+	var progress []Progress
+	if err := cursor.All(ctx, &progress); err != nil {
+		return nil, nil, err
+	}
+	for _, p := range progress {
+		chapters[p.ChapterID] = true
+	}
+
+	cursor, err = pathEnrollmentsCol.Find(ctx, bson.M{"user_id": userID, "completed_at": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var enrollments []PathEnrollment
+	if err := cursor.All(ctx, &enrollments); err != nil {
+		return nil, nil, err
+	}
+	for _, e := range enrollments {
+		paths[e.PathID] = true
+	}
+
+	return chapters, paths, nil
+}
+
+// unlockStatus reports a node as completed, unlocked (all prerequisites
+// complete) or locked
+func unlockStatus(id string, prerequisites []string, completed map[string]bool) string {
+	if completed[id] {
+		return UnlockCompleted
+	}
+	for _, prereq := range prerequisites {
+		if !completed[prereq] {
+			return UnlockLocked
+		}
+	}
+	return UnlockUnlocked
+}
+
+// ============================================================================
+// PREREQUISITE LOCKS
+// ============================================================================
+
+// ErrCodePrerequisitesUnmet is returned for a chapter whose prerequisites the
+// learner hasn't completed
+const ErrCodePrerequisitesUnmet = "prerequisites_unmet"
+
+// prerequisiteLock returns the lock for a chapter with prerequisites the
+// learner hasn't completed, or nil. A chapter the learner already completed
+// stays open even if prerequisites were added later.
+func prerequisiteLock(chapter Chapter, completed map[string]bool) *ChapterLock {
+	if completed[chapter.ChapterID] {
+		return nil
+	}
+	var unmet []string
+	for _, prereq := range chapter.Prerequisites {
+		if !completed[prereq] {
+			unmet = append(unmet, prereq)
+		}
+	}
+	if len(unmet) == 0 {
+		return nil
+	}
+	return &ChapterLock{Locked: true, Reason: LockReasonPrerequisites, UnmetPrerequisites: unmet}
+}
+
+// lockForPrerequisites sets the prerequisite lock on chapters that aren't
+// already locked for another reason
+func lockForPrerequisites(ctx context.Context, userID string, chapters []Chapter) error {
+	completed, _, err := completedNodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range chapters {
+		if chapters[i].Lock == nil {
+			chapters[i].Lock = prerequisiteLock(chapters[i], completed)
+		}
+	}
+	return nil
+}
+
+// checkPrerequisitesMet sends a 403 listing the unmet prerequisites and
+// returns false if the user can't start the chapter yet
+func checkPrerequisitesMet(ctx context.Context, w http.ResponseWriter, userID, chapterID string) bool {
+	chapter, err := chapterStore.Get(ctx, chapterID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return false
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if len(chapter.Prerequisites) == 0 {
+		return true
+	}
+
+	completed, _, err := completedNodes(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if lock := prerequisiteLock(chapter, completed); lock != nil {
+		response := ApiResponse{
+			Success: false,
+			Code:    ErrCodePrerequisitesUnmet,
+			Message: "Complete the prerequisite chapters first",
+			Data:    lock,
+		}
+		sendJSON(w, http.StatusForbidden, response)
+		return false
+	}
+	return true
+}
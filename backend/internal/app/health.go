@@ -0,0 +1,533 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// HEALTH MODELS
+// ============================================================================
+
+// Check statuses. Any failing check fails the whole report (503); warnings
+// only degrade it.
+const (
+	HealthOK   = "ok"
+	HealthWarn = "warn"
+	HealthFail = "fail"
+)
+
+// errDiskSpaceUnsupported is returned by diskSpace where it isn't implemented
+var errDiskSpaceUnsupported = errors.New("disk space is not supported on this platform")
+
+// Free disk space below these is a warning or a failure
+const (
+	diskWarnBytes = 1 << 30   // 1 GiB
+	diskFailBytes = 100 << 20 // 100 MiB
+)
+
+// DeepHealth is the per-check report of GET /api/health/deep
+type DeepHealth struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks"`
+	CheckedAt time.Time              `json:"checkedAt"`
+}
+
+// CheckResult is the outcome of one health check
+type CheckResult struct {
+	Status    string      `json:"status"`
+	Message   string      `json:"message,omitempty"`
+	LatencyMs int64       `json:"latencyMs"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// ============================================================================
+// HEALTH HANDLERS
+// ============================================================================
+
+// DeepHealthCheck verifies the database, indexes, content, background jobs
+// and disk. It responds 503 when any check fails so monitors can alert on
+// the status code alone. Without MongoDB only the stores' database, jobs
+// and disk are checked.
+func DeepHealthCheck(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(context.Context) CheckResult{
+		"jobs": checkJobs,
+		"disk": checkDisk,
+	}
+	if mongoConnected() {
+		checks["mongodb"] = checkMongo
+		checks["indexes"] = checkIndexes
+		checks["migrations"] = checkMigrations
+		checks["chapters"] = checkChapters
+		checks["default_course"] = checkDefaultCourse
+	}
+	if postgresDB != nil {
+		checks["postgres"] = checkPostgres
+	}
+	report := runHealthChecks(r.Context(), checks, 0)
+
+	status, message := http.StatusOK, "All health checks passed"
+	switch report.Status {
+	case HealthWarn:
+		message = "Some health checks reported warnings"
+	case HealthFail:
+		status, message = http.StatusServiceUnavailable, "Some health checks failed"
+	}
+
+	response := ApiResponse{
+		Success: report.Status != HealthFail,
+		Message: message,
+		Data:    report,
+	}
+	sendJSON(w, status, response)
+}
+
+// Liveness answers the liveness probe. It only says the process is serving
+// requests, so an orchestrator restarts it when it hangs, and never looks
+// at the database: an outage there shouldn't restart every instance.
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	response := ApiResponse{
+		Success: true,
+		Message: "Server is running",
+		Data: map[string]string{
+			"status": HealthOK,
+			"time":   time.Now().Format(time.RFC3339),
+		},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// Readiness answers the readiness probe. It responds 503 while the instance
+// can't serve traffic: the database doesn't answer a ping, Redis doesn't
+// when rate limiting or the chapter cache use it, or migrations are
+// pending. Each check gets readinessTimeout, so a hung connection fails the
+// probe instead of outlasting it.
+func Readiness(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(context.Context) CheckResult{}
+	if mongoConnected() {
+		checks["mongodb"] = checkMongo
+		checks["migrations"] = checkMigrationsApplied
+	}
+	if postgresDB != nil {
+		checks["postgres"] = checkPostgres
+	}
+	if redis := redisInUse(); redis != nil {
+		checks["redis"] = func(ctx context.Context) CheckResult { return checkRedis(ctx, redis) }
+	}
+	report := runHealthChecks(r.Context(), checks, readinessTimeout)
+
+	// Warnings don't take an instance out of rotation
+	if report.Status != HealthFail {
+		report.Status = HealthOK
+		sendJSON(w, http.StatusOK, ApiResponse{Success: true, Message: "Ready", Data: report})
+		return
+	}
+	for name, result := range report.Checks {
+		if result.Status == HealthFail {
+			log.Printf("⚠️ Not ready: %s: %s", name, result.Message)
+		}
+	}
+	sendJSON(w, http.StatusServiceUnavailable, ApiResponse{Success: false, Message: "Not ready", Data: report})
+}
+
+// readinessTimeout bounds each readiness check; probes usually give up
+// after a few seconds
+const readinessTimeout = 2 * time.Second
+
+// runHealthChecks runs checks concurrently, each within timeout unless it
+// is 0, and reports the worst status
+func runHealthChecks(ctx context.Context, checks map[string]func(context.Context) CheckResult, timeout time.Duration) DeepHealth {
+	report := DeepHealth{Status: HealthOK, Checks: map[string]CheckResult{}, CheckedAt: time.Now()}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) CheckResult) {
+			defer wg.Done()
+			checkCtx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				checkCtx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			start := time.Now()
+			result := check(checkCtx)
+			result.LatencyMs = time.Since(start).Milliseconds()
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status == HealthFail || (result.Status == HealthWarn && report.Status == HealthOK) {
+				report.Status = result.Status
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// ============================================================================
+// HEALTH CHECKS
+// ============================================================================
+
+func checkMongo(ctx context.Context) CheckResult {
+	if err := client.Ping(ctx, nil); err != nil {
+		return CheckResult{Status: HealthFail, Message: err.Error()}
+	}
+	return CheckResult{Status: HealthOK}
+}
+
+// checkPostgres pings the database of STORAGE_BACKEND=postgres
+func checkPostgres(ctx context.Context) CheckResult {
+	if err := postgresDB.PingContext(ctx); err != nil {
+		return CheckResult{Status: HealthFail, Message: err.Error()}
+	}
+	return CheckResult{Status: HealthOK}
+}
+
+// checkIndexes compares requiredIndexes with what each collection has
+func checkIndexes(ctx context.Context) CheckResult {
+	existing := map[string]map[string]bool{} // collection -> index signatures
+	missing := []string{}
+
+	for _, idx := range requiredIndexes() {
+		name := idx.col.Name()
+		if existing[name] == nil {
+			signatures, err := indexSignatures(ctx, idx.col)
+			if err != nil {
+				return CheckResult{Status: HealthFail, Message: err.Error()}
+			}
+			existing[name] = signatures
+		}
+
+		keys, _ := idx.model.Keys.(bson.D)
+		unique := idx.model.Options != nil && idx.model.Options.Unique != nil && *idx.model.Options.Unique
+		signature := indexSignature(keys, unique)
+		if isTextIndex(keys) && idx.model.Options != nil && idx.model.Options.Name != nil {
+			signature = textIndexSignature(*idx.model.Options.Name)
+		}
+		if !existing[name][signature] {
+			missing = append(missing, name+" "+signature)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return CheckResult{Status: HealthFail, Message: "Required indexes are missing", Details: missing}
+	}
+	return CheckResult{Status: HealthOK}
+}
+
+// checkMigrations warns about migrations that haven't been applied, which
+// only happens when SKIP_STARTUP_MIGRATIONS leaves them to the CLI
+func checkMigrations(ctx context.Context) CheckResult {
+	pending, err := pendingMigrations(ctx)
+	if err != nil {
+		return CheckResult{Status: HealthFail, Message: err.Error()}
+	}
+	if len(pending) > 0 {
+		versions := make([]string, len(pending))
+		for i, m := range pending {
+			versions[i] = m.Version
+		}
+		return CheckResult{Status: HealthWarn, Message: "Migrations are pending", Details: versions}
+	}
+	return CheckResult{Status: HealthOK}
+}
+
+// checkMigrationsApplied fails while migrations are pending; an instance
+// isn't ready to serve until the data is in the shape its code expects
+func checkMigrationsApplied(ctx context.Context) CheckResult {
+	result := checkMigrations(ctx)
+	if result.Status == HealthWarn {
+		result.Status = HealthFail
+	}
+	return result
+}
+
+// redisInUse returns the Redis client rate limiting or the chapter cache
+// uses, nil when neither does
+func redisInUse() *redisClient {
+	if limiter, ok := rateLimiter.(redisRateLimiter); ok {
+		return limiter.redis
+	}
+	if chapterCache != nil {
+		return chapterCache.redis
+	}
+	return nil
+}
+
+func checkRedis(ctx context.Context, redis *redisClient) CheckResult {
+	if _, err := redis.Do(ctx, "PING"); err != nil {
+		return CheckResult{Status: HealthFail, Message: err.Error()}
+	}
+	return CheckResult{Status: HealthOK}
+}
+
+// indexSignatures lists a collection's indexes in indexSignature form
+func indexSignatures(ctx context.Context, col *mongo.Collection) (map[string]bool, error) {
+	cursor, err := col.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var specs []struct {
+		Name   string `bson:"name"`
+		Key    bson.D `bson:"key"`
+		Unique bool   `bson:"unique"`
+	}
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	signatures := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		// Text indexes are listed by their internal _fts key, so they are
+		// matched by name
+		for _, k := range spec.Key {
+			if k.Key == "_fts" {
+				signatures[textIndexSignature(spec.Name)] = true
+			}
+		}
+		signatures[indexSignature(spec.Key, spec.Unique)] = true
+	}
+	return signatures, nil
+}
+
+// isTextIndex reports whether keys make a text index
+func isTextIndex(keys bson.D) bool {
+	for _, k := range keys {
+		if k.Value == "text" {
+			return true
+		}
+	}
+	return false
+}
+
+// textIndexSignature renders a text index like "text chapter_search"
+func textIndexSignature(name string) string {
+	return "text " + name
+}
+
+// indexSignature renders index keys like "(user_id:1, chapter_id:1) unique"
+func indexSignature(keys bson.D, unique bool) string {
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s:%v", k.Key, k.Value))
+	}
+	signature := "(" + strings.Join(parts, ", ") + ")"
+	if unique {
+		signature += " unique"
+	}
+	return signature
+}
+
+// checkChapters verifies the seed chapters exist and every chapter has a
+// video and a well-formed quiz
+func checkChapters(ctx context.Context) CheckResult {
+	cursor, err := chaptersCol.Find(ctx, bson.M{})
+	if err != nil {
+		return CheckResult{Status: HealthFail, Message: err.Error()}
+	}
+	defer cursor.Close(ctx)
+
+	var chapters []Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return CheckResult{Status: HealthFail, Message: err.Error()}
+	}
+	if len(chapters) == 0 {
+		return CheckResult{Status: HealthFail, Message: "No chapters; run the seed"}
+	}
+
+	present := make(map[string]bool, len(chapters))
+	problems := []string{}
+	for _, chapter := range chapters {
+		present[chapter.ChapterID] = true
+		if problem := chapterProblem(chapter); problem != "" {
+			problems = append(problems, chapter.ChapterID+": "+problem)
+		}
+	}
+	for _, seed := range seedChapters() {
+		if !present[seed.ChapterID] {
+			problems = append(problems, seed.ChapterID+": seed chapter is missing")
+		}
+	}
+
+	if len(problems) > 0 {
+		return CheckResult{Status: HealthWarn, Message: "Some chapters are incomplete", Details: problems}
+	}
+	return CheckResult{Status: HealthOK, Details: map[string]int{"chapters": len(chapters)}}
+}
+
+// chapterProblem describes what's wrong with a chapter's content, if anything
+func chapterProblem(chapter Chapter) string {
+	switch {
+	case strings.TrimSpace(chapter.Title) == "":
+		return "has no title"
+	case strings.TrimSpace(chapter.VideoURL) == "":
+		return "has no video"
+	case len(chapter.Quiz.Questions) == 0 && chapter.Quiz.Draw == nil:
+		return "has no quiz questions"
+	}
+	for i, q := range chapter.Quiz.Questions {
+		if q.kind() == QuestionFillIn {
+			if len(q.AcceptedAnswers) == 0 {
+				return fmt.Sprintf("question %d has no accepted answers", i)
+			}
+			continue
+		}
+		if len(q.Options) < 2 {
+			return fmt.Sprintf("question %d has fewer than two options", i)
+		}
+		if q.kind() == QuestionMultiSelect {
+			for _, answer := range q.CorrectAnswers {
+				if answer < 0 || answer >= len(q.Options) {
+					return fmt.Sprintf("question %d has no valid correct answer", i)
+				}
+			}
+			if len(q.CorrectAnswers) == 0 {
+				return fmt.Sprintf("question %d has no valid correct answer", i)
+			}
+			continue
+		}
+		if q.CorrectAnswer < 0 || q.CorrectAnswer >= len(q.Options) {
+			return fmt.Sprintf("question %d has no valid correct answer", i)
+		}
+	}
+	return ""
+}
+
+// checkDefaultCourse verifies the default course exists and points only at
+// chapters that exist
+func checkDefaultCourse(ctx context.Context) CheckResult {
+	course, err := findCourse(ctx, defaultCourseID)
+	if err != nil {
+		return CheckResult{Status: HealthFail, Message: "Default course is missing: " + err.Error()}
+	}
+
+	count, err := chaptersCol.CountDocuments(ctx, bson.M{"chapter_id": bson.M{"$in": course.ChapterIDs}})
+	if err != nil {
+		return CheckResult{Status: HealthFail, Message: err.Error()}
+	}
+	if int(count) < len(course.ChapterIDs) {
+		return CheckResult{Status: HealthWarn, Message: "Default course lists chapters that don't exist"}
+	}
+	return CheckResult{Status: HealthOK}
+}
+
+// checkJobs verifies each background job ran recently and without errors
+func checkJobs(ctx context.Context) CheckResult {
+	jobs := jobStatuses()
+	status := HealthOK
+	for _, job := range jobs {
+		switch {
+		case job.Stale:
+			status = HealthFail
+		case job.LastError != "" && status == HealthOK:
+			status = HealthWarn
+		}
+	}
+	return CheckResult{Status: status, Details: jobs}
+}
+
+// checkDisk reports free space where the server runs
+func checkDisk(ctx context.Context) CheckResult {
+	dir, err := os.Getwd()
+	if err != nil {
+		return CheckResult{Status: HealthWarn, Message: err.Error()}
+	}
+	free, total, err := diskSpace(dir)
+	if err == errDiskSpaceUnsupported {
+		return CheckResult{Status: HealthOK, Message: "Not supported on this platform"}
+	} else if err != nil {
+		return CheckResult{Status: HealthWarn, Message: err.Error()}
+	}
+
+	result := CheckResult{Status: HealthOK, Details: map[string]uint64{"freeBytes": free, "totalBytes": total}}
+	switch {
+	case free < diskFailBytes:
+		result.Status, result.Message = HealthFail, "Disk is almost full"
+	case free < diskWarnBytes:
+		result.Status, result.Message = HealthWarn, "Disk space is low"
+	}
+	return result
+}
+
+// ============================================================================
+// JOB HEARTBEATS
+// ============================================================================
+
+// JobStatus is what the health check knows about a background job
+type JobStatus struct {
+	Name      string     `json:"name"`
+	Interval  string     `json:"interval"`
+	LastRunAt *time.Time `json:"lastRunAt"`
+	LastError string     `json:"lastError,omitempty"`
+	Stale     bool       `json:"stale"` // hasn't run for two intervals
+}
+
+type jobRun struct {
+	interval time.Duration
+	started  time.Time
+	lastRun  time.Time
+	lastErr  error
+}
+
+var (
+	jobRunsMu sync.Mutex
+	jobRuns   = map[string]*jobRun{}
+)
+
+// registerJob starts tracking a background job that runs every interval
+func registerJob(name string, interval time.Duration) {
+	jobRunsMu.Lock()
+	defer jobRunsMu.Unlock()
+	jobRuns[name] = &jobRun{interval: interval, started: time.Now()}
+}
+
+// recordJobRun notes that a job finished a run, with its error if any
+func recordJobRun(name string, err error) {
+	jobRunsMu.Lock()
+	defer jobRunsMu.Unlock()
+	if run, ok := jobRuns[name]; ok {
+		run.lastRun = time.Now()
+		run.lastErr = err
+	}
+}
+
+// jobStatuses reports every registered job, sorted by name
+func jobStatuses() []JobStatus {
+	jobRunsMu.Lock()
+	defer jobRunsMu.Unlock()
+
+	now := time.Now()
+	statuses := make([]JobStatus, 0, len(jobRuns))
+	for name, run := range jobRuns {
+		status := JobStatus{Name: name, Interval: run.interval.String()}
+		since := run.started
+		if !run.lastRun.IsZero() {
+			lastRun := run.lastRun
+			status.LastRunAt = &lastRun
+			since = lastRun
+		}
+		if run.lastErr != nil {
+			status.LastError = run.lastErr.Error()
+		}
+		status.Stale = now.Sub(since) > 2*run.interval
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
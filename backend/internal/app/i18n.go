@@ -0,0 +1,253 @@
+package app
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================================================
+// I18N
+// ============================================================================
+//
+// Server-generated strings are written in English in the code and the
+// English text doubles as the translation key, gettext style. Each bundle in
+// locales/ maps those English strings to a translation. A string without a
+// translation falls back along the chain "pt-br" -> "pt" -> default locale,
+// and finally to the English source text itself.
+
+// defaultLocale is the language the source strings are written in
+const defaultLocale = "en"
+
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+var (
+	translationsMu sync.RWMutex
+	translations   = map[string]map[string]string{}
+)
+
+// LoadTranslations loads the embedded bundles, then any bundles found in
+// config.LocalesDir, which override embedded entries key by key
+func LoadTranslations() error {
+	bundles := map[string]map[string]string{}
+
+	if err := loadBundles(embeddedLocales, "locales", bundles); err != nil {
+		return fmt.Errorf("failed to load embedded translations: %w", err)
+	}
+
+	if dir := config.LocalesDir; dir != "" {
+		if err := loadBundles(os.DirFS(dir), ".", bundles); err != nil {
+			return fmt.Errorf("failed to load translations from %s: %w", dir, err)
+		}
+	}
+
+	translationsMu.Lock()
+	translations = bundles
+	translationsMu.Unlock()
+
+	locales := make([]string, 0, len(bundles))
+	for locale := range bundles {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	log.Printf("🌐 Translations loaded: %s", strings.Join(locales, ", "))
+
+	return nil
+}
+
+// loadBundles reads every <locale>.json file in dir into bundles
+func loadBundles(fsys fs.FS, dir string, bundles map[string]map[string]string) error {
+	matches, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range matches {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		var bundle map[string]string
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		locale := normalizeLocale(strings.TrimSuffix(path.Base(file), ".json"))
+		if bundles[locale] == nil {
+			bundles[locale] = map[string]string{}
+		}
+		for key, value := range bundle {
+			bundles[locale][key] = value
+		}
+	}
+	return nil
+}
+
+// normalizeLocale turns tags like "pt_BR" into "pt-br"
+func normalizeLocale(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+// localeChain lists the locales to try for a tag, most specific first
+func localeChain(locale string) []string {
+	var chain []string
+	for locale = normalizeLocale(locale); locale != ""; {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	if len(chain) == 0 || chain[len(chain)-1] != defaultLocale {
+		chain = append(chain, defaultLocale)
+	}
+	return chain
+}
+
+// T translates msg into locale and formats it with args
+func T(locale, msg string, args ...interface{}) string {
+	translated := msg
+
+	translationsMu.RLock()
+	for _, candidate := range localeChain(locale) {
+		if value, ok := translations[candidate][msg]; ok {
+			translated = value
+			break
+		}
+	}
+	translationsMu.RUnlock()
+
+	if len(args) > 0 {
+		return fmt.Sprintf(translated, args...)
+	}
+	return translated
+}
+
+// isSupportedLocale reports whether a tag is the source language or has a
+// bundle somewhere in its fallback chain
+func isSupportedLocale(locale string) bool {
+	locale = normalizeLocale(locale)
+	if locale == defaultLocale || strings.HasPrefix(locale, defaultLocale+"-") {
+		return true
+	}
+
+	translationsMu.RLock()
+	defer translationsMu.RUnlock()
+
+	for _, candidate := range localeChain(locale) {
+		if _, ok := translations[candidate]; ok && candidate != defaultLocale {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAcceptLanguage returns the tags of an Accept-Language header ordered by quality
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		tags = append(tags, weighted{tag, q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		result = append(result, t.tag)
+	}
+	return result
+}
+
+// userLocale returns a user's preferred locale, or the default locale
+func userLocale(ctx context.Context, userID string) string {
+	var user User
+	if err := usersCol.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user); err != nil || user.Locale == "" {
+		return defaultLocale
+	}
+	return user.Locale
+}
+
+// ============================================================================
+// LOCALE MIDDLEWARE
+// ============================================================================
+
+// localeResponseWriter carries the negotiated locale down to sendJSON/sendError
+type localeResponseWriter struct {
+	http.ResponseWriter
+	locale string
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *localeResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// LocaleMiddleware negotiates the response locale from the ?lang= query
+// parameter or the Accept-Language header
+func LocaleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := defaultLocale
+
+		candidates := parseAcceptLanguage(r.Header.Get("Accept-Language"))
+		if lang := r.URL.Query().Get("lang"); lang != "" {
+			candidates = append([]string{lang}, candidates...)
+		}
+		for _, candidate := range candidates {
+			if isSupportedLocale(candidate) {
+				locale = normalizeLocale(candidate)
+				break
+			}
+		}
+
+		w.Header().Set("Content-Language", locale)
+		next.ServeHTTP(&localeResponseWriter{ResponseWriter: w, locale: locale}, r)
+	})
+}
+
+// responseLocale returns the locale negotiated for a response, looking
+// through any writers other middleware wrapped around it
+func responseLocale(w http.ResponseWriter) string {
+	for {
+		switch rw := w.(type) {
+		case *localeResponseWriter:
+			return rw.locale
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return defaultLocale
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// IDEMPOTENCY KEYS
+// ============================================================================
+
+// Progress and quiz writes accept an Idempotency-Key header, so a mobile
+// client retrying on a flaky network doesn't apply the same write twice.
+// The first request with a key runs and its response is kept for 24 hours;
+// a retry with the same key and body gets that response again, marked with
+// Idempotent-Replayed, without running. Keys belong to the signed-in user
+// and the route. A key sent with a different body is a 422, and one whose
+// first request is still running a 409. Server errors aren't kept, so the
+// retry of a request that failed runs again.
+
+// Error codes of requests whose Idempotency-Key can't be used
+const (
+	ErrCodeIdempotencyKeyReused = "idempotency_key_reused"
+	ErrCodeIdempotencyKeyInUse  = "idempotency_key_in_use"
+)
+
+const (
+	// idempotencyKeyTTL is how long a response is kept for retries
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyClaimTimeout is when a key whose first request never
+	// finished, because the server stopped, can be used again
+	idempotencyClaimTimeout = time.Minute
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodyBytes  = 2 << 20
+)
+
+// idempotentRoutes take an Idempotency-Key, keyed like routeTimeouts
+var idempotentRoutes = map[string]bool{
+	"POST /api/progress/video":                          true,
+	"POST /api/progress/video/batch":                    true,
+	"POST /api/sync":                                    true,
+	"POST /api/progress/quiz":                           true,
+	"POST /api/progress/{userId}/{chapterId}/bookmarks": true,
+	"POST /api/quiz/{userId}/{chapterId}/retake":        true,
+}
+
+// IdempotencyRecord is an idempotency_keys document. Status is 0 while the
+// first request runs.
+type IdempotencyRecord struct {
+	ID          string    `bson:"_id"` // hash of the key, its user and route
+	RequestHash string    `bson:"request_hash"`
+	Status      int       `bson:"status"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// IdempotencyMiddleware runs a write with an Idempotency-Key once and
+// replays its response to retries. If the key can't be claimed because the
+// database fails, the request runs anyway; the key only guards retries.
+func IdempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		route := routeKey(r)
+		if key == "" || !idempotentRoutes[route] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			sendError(w, http.StatusBadRequest, "Idempotency-Key can be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			sendError(w, http.StatusRequestEntityTooLarge, "Request bodies can be at most 2 MB")
+			return
+		} else if err != nil {
+			sendError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		id := hashParts(orgID(ctx), authUserID(ctx), route, key)
+		requestHash := hashParts(r.URL.RequestURI(), string(body))
+
+		record, claimed, err := claimIdempotencyKey(ctx, id, requestHash)
+		if err != nil {
+			log.Printf("❌ Error claiming idempotency key: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !claimed {
+			replayIdempotent(w, record, requestHash)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		finishIdempotencyKey(id, rec)
+	})
+}
+
+// claimIdempotencyKey records that the request with the key is running,
+// unless an earlier one holds it. It returns the earlier one's record when
+// it does. A key whose first request never finished, because the server
+// stopped, is free to take after idempotencyClaimTimeout.
+func claimIdempotencyKey(ctx context.Context, id, requestHash string) (IdempotencyRecord, bool, error) {
+	now := time.Now()
+	claim := IdempotencyRecord{ID: id, RequestHash: requestHash, CreatedAt: now, ExpiresAt: now.Add(idempotencyKeyTTL)}
+	return idempotencyStore.Claim(ctx, claim, now.Add(-idempotencyClaimTimeout))
+}
+
+// replayIdempotent answers a retry from the record of its key's first
+// request
+func replayIdempotent(w http.ResponseWriter, record IdempotencyRecord, requestHash string) {
+	switch {
+	case record.RequestHash != requestHash:
+		sendErrorCode(w, http.StatusUnprocessableEntity, ErrCodeIdempotencyKeyReused,
+			"This Idempotency-Key was used with a different request")
+	case record.Status == 0:
+		w.Header().Set("Retry-After", "1")
+		sendErrorCode(w, http.StatusConflict, ErrCodeIdempotencyKeyInUse,
+			"A request with this Idempotency-Key is still running")
+	default:
+		if record.ContentType != "" {
+			w.Header().Set("Content-Type", record.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(record.Status)
+		w.Write(record.Body)
+	}
+}
+
+// finishIdempotencyKey keeps the response of the key's first request, or
+// frees the key after a server error so a retry runs again
+func finishIdempotencyKey(id string, rec *idempotencyRecorder) {
+	// The request's context may have timed out while the write went through
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	var err error
+	if status >= http.StatusInternalServerError {
+		err = idempotencyStore.Release(ctx, id)
+	} else {
+		err = idempotencyStore.Finish(ctx, id, status, rec.Header().Get("Content-Type"), rec.body.Bytes())
+	}
+	if err != nil {
+		log.Printf("❌ Error saving idempotency key: %v", err)
+	}
+}
+
+// hashParts hashes strings joined with a separator none of them contain
+func hashParts(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyRecorder passes a response through while keeping a copy
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets helpers such as responseLocale see the wrapped writer
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
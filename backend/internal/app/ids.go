@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// PUBLIC IDS
+// ============================================================================
+
+// Every stored entity gets a server-generated UUID in public_id, returned to
+// clients as "id". Business keys (userId, chapterId, pathId, skillId) stay
+// as aliases, and the Mongo _id is never exposed.
+
+// entityCollections lists every collection whose documents carry a public ID
+func entityCollections() []*mongo.Collection {
+	return []*mongo.Collection{
+		usersCol, chaptersCol, progressCol, commentsCol, reportsCol, viewerGrantsCol,
+		pathsCol, pathEnrollmentsCol, skillsCol, quizAttemptsCol, profileChangesCol,
+		coursesCol, enrollmentsCol, answerChangesCol, activityCol, sessionsCol, analyticsRollupsCol,
+		organizationsCol, authSessionsCol, accountMergesCol, accountDeletionsCol, cohortsCol,
+	}
+}
+
+// publicIDPattern matches a lowercase or uppercase canonical UUID
+var publicIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// newPublicID returns a random (version 4) UUID
+func newPublicID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// isPublicID reports whether s looks like a UUID
+func isPublicID(s string) bool {
+	return publicIDPattern.MatchString(s)
+}
+
+// idFilter matches a document by public ID, or by the legacy ObjectID hex
+// that responses carried before public IDs existed
+func idFilter(raw string) (bson.M, bool) {
+	if isPublicID(raw) {
+		return bson.M{"public_id": strings.ToLower(raw)}, true
+	}
+	if oid, err := primitive.ObjectIDFromHex(raw); err == nil {
+		return bson.M{"_id": oid}, true
+	}
+	return nil, false
+}
+
+// idsFilter is idFilter for a list of IDs in either form
+func idsFilter(raws []string) (bson.M, bool) {
+	publicIDs := []string{}
+	oids := []primitive.ObjectID{}
+	for _, raw := range raws {
+		if isPublicID(raw) {
+			publicIDs = append(publicIDs, strings.ToLower(raw))
+		} else if oid, err := primitive.ObjectIDFromHex(raw); err == nil {
+			oids = append(oids, oid)
+		} else {
+			return nil, false
+		}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{"public_id": bson.M{"$in": publicIDs}},
+		bson.M{"_id": bson.M{"$in": oids}},
+	}}, true
+}
+
+// ============================================================================
+// BUSINESS KEY RESOLUTION
+// ============================================================================
+
+// businessKey says where to find the business key for an entity
+type businessKey struct {
+	col   func() *mongo.Collection
+	field string
+}
+
+var (
+	userKey    = businessKey{func() *mongo.Collection { return usersCol }, "user_id"}
+	chapterKey = businessKey{func() *mongo.Collection { return chaptersCol }, "chapter_id"}
+	pathKey    = businessKey{func() *mongo.Collection { return pathsCol }, "path_id"}
+	courseKey  = businessKey{func() *mongo.Collection { return coursesCol }, "course_id"}
+)
+
+// resolvableParams maps route variables and query parameters that name an
+// entity to the entity they name
+var resolvableParams = map[string]businessKey{
+	"userId":    userKey,
+	"learnerId": userKey,
+	"viewerId":  userKey,
+	"chapterId": chapterKey,
+	"pathId":    pathKey,
+	"courseId":  courseKey,
+}
+
+// resolve turns a public ID into the entity's business key. Anything else,
+// including unknown public IDs, is returned unchanged so handlers report it
+// as not found the same way they always have. Without MongoDB public IDs
+// aren't looked up, and only business keys name entities.
+func (k businessKey) resolve(ctx context.Context, raw string) string {
+	if !isPublicID(raw) || !mongoConnected() {
+		return raw
+	}
+
+	var doc bson.M
+	err := k.col().FindOne(ctx, bson.M{"public_id": strings.ToLower(raw)},
+		options.FindOne().SetProjection(bson.M{k.field: 1})).Decode(&doc)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("❌ Error resolving public ID %s: %v", raw, err)
+		}
+		return raw
+	}
+	if key, ok := doc[k.field].(string); ok {
+		return key
+	}
+	return raw
+}
+
+// resolveUserKey accepts a user ID or a user's public ID
+func resolveUserKey(ctx context.Context, raw string) string {
+	return userKey.resolve(ctx, raw)
+}
+
+// resolveChapterKey accepts a chapter ID or a chapter's public ID
+func resolveChapterKey(ctx context.Context, raw string) string {
+	return chapterKey.resolve(ctx, raw)
+}
+
+// IDResolutionMiddleware rewrites public IDs in route variables and query
+// parameters to business keys, so handlers only ever see business keys
+func IDResolutionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		vars := mux.Vars(r)
+		changed := false
+		for name, value := range vars {
+			if k, ok := resolvableParams[name]; ok && isPublicID(value) {
+				vars[name] = k.resolve(ctx, value)
+				changed = true
+			}
+		}
+		if changed {
+			r = mux.SetURLVars(r, vars)
+		}
+
+		query := r.URL.Query()
+		changed = false
+		for name, k := range resolvableParams {
+			if value := query.Get(name); isPublicID(value) {
+				query.Set(name, k.resolve(ctx, value))
+				changed = true
+			}
+		}
+		if changed {
+			r.URL.RawQuery = query.Encode()
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ============================================================================
+// BACKFILL
+// ============================================================================
+
+// backfillPublicIDs assigns public IDs to documents stored before they
+// existed. It only touches documents without one, so it is cheap to run on
+// every start.
+func backfillPublicIDs(ctx context.Context) {
+	for _, col := range entityCollections() {
+		cursor, err := col.Find(ctx, bson.M{"public_id": bson.M{"$exists": false}},
+			options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			log.Printf("❌ Error finding %s without public IDs: %v", col.Name(), err)
+			continue
+		}
+
+		var docs []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		err = cursor.All(ctx, &docs)
+		cursor.Close(ctx)
+		if err != nil {
+			log.Printf("❌ Error decoding %s without public IDs: %v", col.Name(), err)
+			continue
+		}
+
+		for _, doc := range docs {
+			_, err := col.UpdateOne(ctx,
+				bson.M{"_id": doc.ID, "public_id": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"public_id": newPublicID()}})
+			if err != nil {
+				log.Printf("❌ Error assigning public ID in %s: %v", col.Name(), err)
+			}
+		}
+		if len(docs) > 0 {
+			log.Printf("🆔 Assigned public IDs to %d %s", len(docs), col.Name())
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// LEADERBOARD MODELS
+// ============================================================================
+
+// Leaderboards rank an organization's active learners by the XP they earned
+// (from xp_awards) or the chapters they completed (from the activity log),
+// this week or all time. Weeks start Monday 00:00 UTC. Building a board
+// aggregates over every learner, so each board is cached for
+// LEADERBOARD_CACHE_TTL and a new XP award or completion shows up when the
+// cached board expires. Like live progress, the cache is kept per server
+// instance.
+
+// Leaderboard periods
+const (
+	LeaderboardWeekly  = "weekly"
+	LeaderboardAllTime = "all_time"
+)
+
+// Leaderboard metrics
+const (
+	LeaderboardXP       = "xp"
+	LeaderboardChapters = "chapters"
+)
+
+const (
+	// defaultLeaderboardCacheTTL is how long a board is served from the
+	// cache unless LEADERBOARD_CACHE_TTL says otherwise
+	defaultLeaderboardCacheTTL = time.Minute
+	// defaultLeaderboardLimit is how many leaders GetLeaderboard lists
+	// without ?limit=
+	defaultLeaderboardLimit = 10
+	// defaultLeaderboardRadius and maxLeaderboardRadius bound how many
+	// neighbors above and below GetLeaderboardAround lists
+	defaultLeaderboardRadius = 5
+	maxLeaderboardRadius     = 25
+)
+
+// LeaderboardEntry is one learner's place on a board. Learners with the
+// same score share a rank, and the next rank skips past them.
+type LeaderboardEntry struct {
+	Rank      int    `bson:"-" json:"rank"`
+	UserID    string `bson:"user_id" json:"userId"`
+	Name      string `bson:"name" json:"name"`
+	AvatarURL string `bson:"avatar_url,omitempty" json:"avatarUrl,omitempty"`
+	Score     int    `bson:"score" json:"score"` // XP or chapters completed
+}
+
+// Leaderboard is a slice of a ranking
+type Leaderboard struct {
+	Period      string             `json:"period"`
+	Metric      string             `json:"metric"`
+	Since       *time.Time         `json:"since,omitempty"` // start of the week, for weekly boards
+	GeneratedAt time.Time          `json:"generatedAt"`     // when the cached ranking was built
+	Ranked      int                `json:"ranked"`          // learners with a score
+	User        *LeaderboardEntry  `json:"user,omitempty"`  // around: the user's own entry, unless unranked
+	Entries     []LeaderboardEntry `json:"entries"`
+}
+
+// leaderboardKey identifies a cached ranking
+type leaderboardKey struct {
+	orgID  string // "" for every organization
+	period string
+	metric string
+}
+
+// rankedBoard is a whole ranking, best first
+type rankedBoard struct {
+	since   *time.Time
+	builtAt time.Time
+	entries []LeaderboardEntry
+}
+
+// leaderboardCache keeps the rankings built in the last TTL
+type leaderboardCache struct {
+	mu     sync.Mutex
+	boards map[leaderboardKey]*rankedBoard
+}
+
+var leaderboards = &leaderboardCache{boards: map[leaderboardKey]*rankedBoard{}}
+
+// ============================================================================
+// LEADERBOARD HANDLERS
+// ============================================================================
+
+// GetLeaderboard lists the top learners of ?period= (weekly or all_time)
+// by ?metric= (xp or chapters), limited by ?limit=
+func GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	var errs fieldErrors
+	period, metric := parseLeaderboard(r, &errs)
+	limit := defaultLeaderboardLimit
+	if r.URL.Query().Get("limit") != "" {
+		limit = parsePageLimit(r, &errs)
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	board, err := leaderboards.get(ctx, period, metric)
+	if err != nil {
+		log.Printf("❌ Error building leaderboard: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to fetch leaderboard")
+		return
+	}
+
+	result := board.view(period, metric)
+	result.Entries = board.entries[:min(limit, len(board.entries))]
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Leaderboard fetched successfully",
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetLeaderboardAround lists the user's place on a board with up to
+// ?radius= learners above and below them. An unranked user gets no entries.
+func GetLeaderboardAround(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	var errs fieldErrors
+	period, metric := parseLeaderboard(r, &errs)
+	radius := defaultLeaderboardRadius
+	if v := r.URL.Query().Get("radius"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxLeaderboardRadius {
+			errs.add("radius", CodeOutOfRange, "must be between 0 and 25")
+		} else {
+			radius = n
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := userStore.Get(ctx, userID); err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	board, err := leaderboards.get(ctx, period, metric)
+	if err != nil {
+		log.Printf("❌ Error building leaderboard: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to fetch leaderboard")
+		return
+	}
+
+	result := board.view(period, metric)
+	result.Entries = []LeaderboardEntry{}
+	for i, entry := range board.entries {
+		if entry.UserID == userID {
+			own := entry
+			result.User = &own
+			result.Entries = board.entries[max(0, i-radius):min(len(board.entries), i+radius+1)]
+			break
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Leaderboard fetched successfully",
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// LEADERBOARD HELPERS
+// ============================================================================
+
+// parseLeaderboard reads ?period= (weekly by default) and ?metric= (xp by
+// default)
+func parseLeaderboard(r *http.Request, errs *fieldErrors) (string, string) {
+	query := r.URL.Query()
+	period := query.Get("period")
+	switch period {
+	case "":
+		period = LeaderboardWeekly
+	case LeaderboardWeekly, LeaderboardAllTime:
+	default:
+		errs.add("period", CodeUnknownValue, "must be weekly or all_time")
+	}
+	metric := query.Get("metric")
+	switch metric {
+	case "":
+		metric = LeaderboardXP
+	case LeaderboardXP, LeaderboardChapters:
+	default:
+		errs.add("metric", CodeUnknownValue, "must be xp or chapters")
+	}
+	return period, metric
+}
+
+// view is the board's description, without entries
+func (b *rankedBoard) view(period, metric string) Leaderboard {
+	return Leaderboard{
+		Period:      period,
+		Metric:      metric,
+		Since:       b.since,
+		GeneratedAt: b.builtAt,
+		Ranked:      len(b.entries),
+	}
+}
+
+// get returns the request organization's ranking, from the cache while it
+// is fresh. Concurrent misses may each build it; the last one is kept.
+func (c *leaderboardCache) get(ctx context.Context, period, metric string) (*rankedBoard, error) {
+	key := leaderboardKey{orgID: tenantOrgFilter(ctx), period: period, metric: metric}
+	ttl := config.LeaderboardCacheTTL
+
+	c.mu.Lock()
+	board, ok := c.boards[key]
+	c.mu.Unlock()
+	if ok && time.Since(board.builtAt) < ttl {
+		return board, nil
+	}
+
+	board, err := buildLeaderboard(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.boards[key] = board
+	c.mu.Unlock()
+	return board, nil
+}
+
+// buildLeaderboard aggregates a whole ranking. XP is summed from
+// xp_awards; chapters count the distinct chapters completed in the
+// activity log, so one completed again after a reset counts once.
+func buildLeaderboard(ctx context.Context, key leaderboardKey) (*rankedBoard, error) {
+	now := time.Now()
+	board := &rankedBoard{builtAt: now, entries: []LeaderboardEntry{}}
+
+	match := bson.M{}
+	if key.orgID != "" {
+		match["org_id"] = key.orgID
+	}
+	if key.period == LeaderboardWeekly {
+		since := startOfWeek(now)
+		board.since = &since
+	}
+
+	var col *mongo.Collection
+	var group bson.M
+	switch key.metric {
+	case LeaderboardXP:
+		col = xpAwardsCol
+		if board.since != nil {
+			match["awarded_at"] = bson.M{"$gte": *board.since}
+		}
+		group = bson.M{"_id": "$user_id", "score": bson.M{"$sum": "$xp"}}
+	case LeaderboardChapters:
+		col = activityCol
+		match["type"] = ActivityChapterCompleted
+		if board.since != nil {
+			match["occurred_at"] = bson.M{"$gte": *board.since}
+		}
+		group = bson.M{"_id": "$user_id", "chapters": bson.M{"$addToSet": "$chapter_id"}}
+	}
+
+	// Only active learners of the organization are ranked
+	users := bson.M{"user.status": bson.M{"$nin": bson.A{UserSuspended, UserDeactivated}}}
+	if key.orgID != "" {
+		users["user.org_id"] = key.orgID
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: group}},
+	}
+	if key.metric == LeaderboardChapters {
+		pipeline = append(pipeline, bson.D{{Key: "$set", Value: bson.M{"score": bson.M{"$size": "$chapters"}}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$match", Value: bson.M{"score": bson.M{"$gt": 0}}}},
+		bson.D{{Key: "$lookup", Value: bson.M{
+			"from":         usersCol.Name(),
+			"localField":   "_id",
+			"foreignField": "user_id",
+			"as":           "user",
+		}}},
+		bson.D{{Key: "$unwind", Value: "$user"}},
+		bson.D{{Key: "$match", Value: users}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$project", Value: bson.M{
+			"_id":        0,
+			"user_id":    "$_id",
+			"name":       "$user.name",
+			"avatar_url": "$user.avatar_url",
+			"score":      1,
+		}}},
+	)
+
+	cursor, err := col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if err := cursor.All(ctx, &board.entries); err != nil {
+		return nil, err
+	}
+	for i := range board.entries {
+		if i > 0 && board.entries[i].Score == board.entries[i-1].Score {
+			board.entries[i].Rank = board.entries[i-1].Rank
+		} else {
+			board.entries[i].Rank = i + 1
+		}
+	}
+	return board, nil
+}
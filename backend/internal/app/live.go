@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// ============================================================================
+// LIVE PROGRESS
+// ============================================================================
+
+// Apps open a WebSocket to /api/ws and get every progress write of their
+// user pushed to them as it happens, so a video started on the phone
+// resumes at the right spot on the web without polling. Subscribers are
+// kept per server instance: a write reaches the sockets connected to the
+// instance that handled it.
+
+// maxLiveConnsPerUser bounds the sockets one user can hold open
+const maxLiveConnsPerUser = 10
+
+// Kinds of live message
+const (
+	LiveProgress    = "progress"    // Data is the chapter's Progress
+	LiveReset       = "reset"       // all of the user's progress was deleted
+	LiveAchievement = "achievement" // Data is the Achievement just unlocked
+)
+
+// LiveMessage is what a socket receives
+type LiveMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// liveHub tracks the open sockets of each user
+type liveHub struct {
+	mu    sync.Mutex
+	conns map[string]map[*wsConn]bool
+}
+
+var liveProgress = &liveHub{conns: map[string]map[*wsConn]bool{}}
+
+// subscribe adds c to userID's sockets, unless the user has too many
+func (h *liveHub) subscribe(userID string, c *wsConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.conns[userID]) >= maxLiveConnsPerUser {
+		return false
+	}
+	if h.conns[userID] == nil {
+		h.conns[userID] = map[*wsConn]bool{}
+	}
+	h.conns[userID][c] = true
+	return true
+}
+
+func (h *liveHub) unsubscribe(userID string, c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns[userID], c)
+	if len(h.conns[userID]) == 0 {
+		delete(h.conns, userID)
+	}
+}
+
+// watched reports whether userID has a socket open, so writers can skip
+// building messages nobody will get
+func (h *liveHub) watched(userID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns[userID]) > 0
+}
+
+// publish sends msg to each of userID's sockets
+func (h *liveHub) publish(userID string, msg LiveMessage) {
+	h.mu.Lock()
+	conns := make([]*wsConn, 0, len(h.conns[userID]))
+	for c := range h.conns[userID] {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+	if len(conns) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("❌ Error encoding live message: %v", err)
+		return
+	}
+	for _, c := range conns {
+		c.Send(body)
+	}
+}
+
+// closeAll says goodbye to every socket; the server calls it on shutdown,
+// which doesn't wait for hijacked connections
+func (h *liveHub) closeAll() {
+	h.mu.Lock()
+	conns := []*wsConn{}
+	for _, userConns := range h.conns {
+		for c := range userConns {
+			conns = append(conns, c)
+		}
+	}
+	h.mu.Unlock()
+	for _, c := range conns {
+		c.Close(wsCloseGoingAway, "server shutting down")
+	}
+}
+
+// publishProgress pushes a chapter's stored progress to the user's sockets
+// and the event bus
+func publishProgress(ctx context.Context, userID, chapterID string) {
+	if !liveProgress.watched(userID) && domainEvents == nil {
+		return
+	}
+	progress, err := progressStore.Get(ctx, userID, chapterID)
+	if err != nil {
+		log.Printf("❌ Error loading progress to publish for %s: %v", userID, err)
+		return
+	}
+	liveProgress.publish(userID, LiveMessage{Type: LiveProgress, Data: progress})
+	publishProgressEvent(ctx, progress)
+}
+
+// LiveProgressSocket upgrades to a WebSocket that receives the signed-in
+// user's progress writes
+func LiveProgressSocket(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID := authUserID(ctx)
+	if userID == "" {
+		sendErrorCode(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+		return
+	}
+	if !checkUserActive(ctx, w, userID) {
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	if !liveProgress.subscribe(userID, conn) {
+		conn.Close(wsClosePolicy, "too many connections")
+		return
+	}
+	defer liveProgress.unsubscribe(userID, conn)
+
+	log.Printf("🔌 Live progress connected: user=%s", userID)
+	conn.Serve()
+	log.Printf("🔌 Live progress disconnected: user=%s", userID)
+}
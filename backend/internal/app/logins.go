@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// LOGIN TRACKING
+// ============================================================================
+
+// maxRecentLogins is how many logins are kept on the user
+const maxRecentLogins = 10
+
+// defaultLoginDedupWindow is how long a repeated login from the same device
+// is treated as the same login
+const defaultLoginDedupWindow = 10 * time.Second
+
+// LoginRecord is one entry of a user's login history
+type LoginRecord struct {
+	At        time.Time `bson:"at" json:"at"`
+	Device    string    `bson:"device" json:"device"` // device ID, or the user agent without one
+	IP        string    `bson:"ip" json:"ip"`
+	UserAgent string    `bson:"user_agent" json:"userAgent"`
+}
+
+// loginDedupWindow is config.LoginDedupWindowSeconds; 0 disables
+// deduplication
+func loginDedupWindow() time.Duration {
+	return time.Duration(config.LoginDedupWindowSeconds) * time.Second
+}
+
+// requestDevice identifies the caller's device: the device ID from the body,
+// else the X-Device-ID header, else the user agent
+func requestDevice(r *http.Request, deviceID string) string {
+	if device := strings.TrimSpace(deviceID); device != "" {
+		return device
+	}
+	if device := strings.TrimSpace(r.Header.Get("X-Device-ID")); device != "" {
+		return device
+	}
+	return r.UserAgent()
+}
+
+// newLoginRecord describes the login in r
+func newLoginRecord(r *http.Request, deviceID string) LoginRecord {
+	return LoginRecord{
+		At:        time.Now(),
+		Device:    requestDevice(r, deviceID),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// recordLogin stamps last_login_at and adds the login to the user's history,
+// along with any other fields in set, and returns the updated user. It
+// returns nil without writing anything when the same device logged in
+// within the dedup window; the check and the write are one update, so
+// concurrent duplicates can't both get through. Without MongoDB the login
+// history isn't kept and nothing is written.
+func recordLogin(ctx context.Context, userID string, login LoginRecord, set bson.M) (*User, error) {
+	if !mongoConnected() {
+		return nil, nil
+	}
+
+	filter := bson.M{"user_id": userID}
+	if window := loginDedupWindow(); window > 0 {
+		filter["recent_logins"] = bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"device": login.Device,
+			"at":     bson.M{"$gt": login.At.Add(-window)},
+		}}}
+	}
+
+	set["last_login_at"] = login.At
+	var user User
+	err := usersCol.FindOneAndUpdate(ctx, filter, bson.M{
+		"$set": set,
+		"$push": bson.M{"recent_logins": bson.M{
+			"$each":  []LoginRecord{login},
+			"$slice": -maxRecentLogins,
+		}},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// clientIP returns the caller's address. X-Forwarded-For is only trusted
+// when TRUST_PROXY_HEADERS=true, since clients can set it to anything.
+func clientIP(r *http.Request) string {
+	if config.TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"strings"
+)
+
+// ============================================================================
+// EMAIL
+// ============================================================================
+
+// Mailer delivers plain-text emails. The SMTP mailer is used when SMTP_HOST
+// is configured; otherwise messages are logged, which keeps local
+// development working without a mail server. Tests and other transports
+// can swap in their own.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// mailer, when set, replaces the mailer the environment configures
+var mailer Mailer
+
+// sendEmail delivers a plain-text email, through SMTP when config.SMTPHost
+// is set
+func sendEmail(to, subject, body string) error {
+	if mailer != nil {
+		return mailer.Send(to, subject, body)
+	}
+	if config.SMTPHost != "" {
+		return smtpMailer{host: config.SMTPHost, port: config.SMTPPort, from: config.SMTPFrom,
+			username: config.SMTPUsername, password: config.SMTPPassword}.Send(to, subject, body)
+	}
+	return logMailer{}.Send(to, subject, body)
+}
+
+// logMailer writes emails to the log instead of sending them
+type logMailer struct{}
+
+func (logMailer) Send(to, subject, body string) error {
+	log.Printf("✉️ (SMTP not configured) to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
+
+// smtpMailer sends emails through the SMTP server at host:port, logging in
+// when username is set
+type smtpMailer struct {
+	host     string
+	port     string
+	from     string
+	username string
+	password string
+}
+
+func (m smtpMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send email to %s: %w", to, err)
+	}
+	return nil
+}
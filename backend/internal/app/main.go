@@ -0,0 +1,1588 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// MODELS
+// ============================================================================
+
+// User represents a user in the system
+type User struct {
+	ID            primitive.ObjectID       `bson:"_id,omitempty" json:"-"`
+	PublicID      string                   `bson:"public_id,omitempty" json:"id"`
+	UserID        string                   `bson:"user_id" json:"userId"`
+	OrgID         string                   `bson:"org_id,omitempty" json:"orgId"`
+	Name          string                   `bson:"name" json:"name"`
+	Email         string                   `bson:"email,omitempty" json:"email,omitempty"`
+	AvatarURL     string                   `bson:"avatar_url,omitempty" json:"avatarUrl,omitempty"`
+	Avatar        *ImageRef                `bson:"avatar,omitempty" json:"avatar,omitempty"` // an uploaded avatar
+	Bio           string                   `bson:"bio,omitempty" json:"bio,omitempty"`
+	LearningGoals []string                 `bson:"learning_goals,omitempty" json:"learningGoals,omitempty"`
+	Handle        string                   `bson:"handle,omitempty" json:"handle,omitempty"`
+	Role          string                   `bson:"role,omitempty" json:"role,omitempty"` // see roles.go
+	Status        string                   `bson:"status" json:"status"`
+	StatusReason  string                   `bson:"status_reason,omitempty" json:"statusReason,omitempty"`
+	Locale        string                   `bson:"locale,omitempty" json:"locale,omitempty"`
+	Timezone      string                   `bson:"timezone,omitempty" json:"timezone,omitempty"` // IANA name, e.g. "Europe/Berlin"
+	Notifications NotificationPreferences  `bson:"notifications" json:"notifications"`
+	Privacy       PrivacySettings          `bson:"privacy" json:"privacy"`
+	LastLoginAt   *time.Time               `bson:"last_login_at,omitempty" json:"lastLoginAt,omitempty"`
+	RecentLogins  []LoginRecord            `bson:"recent_logins,omitempty" json:"recentLogins,omitempty"` // newest last
+	Accessibility AccessibilityPreferences `bson:"accessibility" json:"accessibility"`
+	XP            int                      `bson:"xp" json:"xp"` // see xp.go
+	CreatedAt     time.Time                `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time                `bson:"updated_at" json:"updatedAt"`
+	// How accounts sign in besides by user ID; see accounts.go and oauth.go
+	PasswordHash    string           `bson:"password_hash,omitempty" json:"-"`
+	GuestSecretHash string           `bson:"guest_secret_hash,omitempty" json:"-"` // a guest's, see Login
+	EmailVerifiedAt *time.Time       `bson:"email_verified_at,omitempty" json:"emailVerifiedAt,omitempty"`
+	Identities      []LinkedIdentity `bson:"identities,omitempty" json:"identities,omitempty"` // Google and Apple accounts
+}
+
+// Chapter represents a learning chapter
+type Chapter struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID            string             `bson:"public_id,omitempty" json:"id"`
+	OrgID               string             `bson:"org_id,omitempty" json:"-"` // empty for the shared catalog
+	ChapterID           string             `bson:"chapter_id" json:"chapterId"`
+	Title               string             `bson:"title" json:"title"`
+	Description         string             `bson:"description" json:"description"`
+	VideoURL            string             `bson:"video_url" json:"videoUrl"`
+	ThumbnailURL        string             `bson:"thumbnail_url" json:"thumbnailUrl"`
+	Duration            int                `bson:"duration" json:"duration"` // in seconds
+	Quiz                Quiz               `bson:"quiz" json:"quiz"`
+	PassScore           int                `bson:"pass_score,omitempty" json:"passScore"` // percent of questions to get right; 0 (older chapters) means defaultPassScore
+	Order               int                `bson:"order" json:"order"`
+	Prerequisites       []string           `bson:"prerequisites" json:"prerequisites"` // chapter IDs
+	Skills              []string           `bson:"skills" json:"skills"`               // skill IDs
+	Accessibility       Accessibility      `bson:"accessibility" json:"accessibility"`
+	AccessibilityIssues []string           `bson:"-" json:"accessibilityIssues,omitempty"` // per-learner, never stored
+	Lock                *ChapterLock       `bson:"-" json:"lock,omitempty"`                // per-learner, never stored
+	UpdatedAt           *time.Time         `bson:"updated_at,omitempty" json:"updatedAt,omitempty"`
+	Thumbnail           *ImageRef          `bson:"thumbnail,omitempty" json:"thumbnail,omitempty"`
+	// Archived chapters are hidden from learners; see ArchiveChapter
+	Archived     bool             `bson:"archived,omitempty" json:"archived,omitempty"`
+	ArchivedAt   *time.Time       `bson:"archived_at,omitempty" json:"archivedAt,omitempty"`
+	ArchivedFrom []ArchivedCourse `bson:"archived_from,omitempty" json:"archivedFrom,omitempty"` // where to put it back on restore
+	// Learners see the published content; edits can wait in a draft
+	Version     int           `bson:"version,omitempty" json:"version,omitempty"` // bumped by every update or publish
+	PublishedAt *time.Time    `bson:"published_at,omitempty" json:"publishedAt,omitempty"`
+	Draft       *ChapterDraft `bson:"draft,omitempty" json:"-"` // admins only, see GetChapterDraft
+	// Release dates; courses can move them per cohort, see release.go
+	AvailableFrom  *time.Time `bson:"available_from,omitempty" json:"availableFrom,omitempty"`
+	AvailableUntil *time.Time `bson:"available_until,omitempty" json:"availableUntil,omitempty"`
+}
+
+// ArchivedCourse is a course an archived chapter was taken out of
+type ArchivedCourse struct {
+	CourseID string `bson:"course_id" json:"courseId"`
+	Position int    `bson:"position" json:"position"` // index in the course's chapter IDs
+}
+
+// Quiz represents a quiz for a chapter
+type Quiz struct {
+	Questions []Question `bson:"questions" json:"questions"`
+	Shuffle   bool       `bson:"shuffle,omitempty" json:"shuffle"`     // each learner gets their own order; see quiz_shuffle.go
+	Draw      *QuizDraw  `bson:"draw,omitempty" json:"draw,omitempty"` // questions come from a bank instead; see question_bank.go
+}
+
+// Question represents a single quiz question. Which answer key applies
+// depends on Type; see answers.go.
+type Question struct {
+	ID              string   `bson:"id" json:"id"`
+	Type            string   `bson:"type,omitempty" json:"type,omitempty"` // single_choice if empty
+	QuestionText    string   `bson:"question_text" json:"questionText"`
+	Options         []string `bson:"options" json:"options"`
+	CorrectAnswer   int      `bson:"correct_answer" json:"correctAnswer"`                         // single choice and true/false
+	CorrectAnswers  []int    `bson:"correct_answers,omitempty" json:"correctAnswers,omitempty"`   // multi-select
+	AcceptedAnswers []string `bson:"accepted_answers,omitempty" json:"acceptedAnswers,omitempty"` // fill-in
+	Skills          []string `bson:"skills,omitempty" json:"skills,omitempty"`                    // skill IDs
+	Explanation     string   `bson:"explanation,omitempty" json:"explanation,omitempty"`          // shown in the review once the quiz is done
+	// Image is an uploaded picture shown with the question
+	Image *ImageRef `bson:"image,omitempty" json:"image,omitempty"`
+}
+
+// Progress represents user's learning progress
+type Progress struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID         string             `bson:"public_id,omitempty" json:"id"`
+	OrgID            string             `bson:"org_id,omitempty" json:"-"`
+	UserID           string             `bson:"user_id" json:"userId"`
+	ChapterID        string             `bson:"chapter_id" json:"chapterId"`
+	VideoProgress    int                `bson:"video_progress" json:"videoProgress"` // in seconds
+	VideoCompleted   bool               `bson:"video_completed" json:"videoCompleted"`
+	QuizProgress     int                `bson:"quiz_progress" json:"quizProgress"` // current question index
+	QuizAnswers      []Answer           `bson:"quiz_answers" json:"quizAnswers"`   // user's answers
+	QuizCompleted    bool               `bson:"quiz_completed" json:"quizCompleted"`
+	QuizStartedAt    *time.Time         `bson:"quiz_started_at,omitempty" json:"quizStartedAt,omitempty"`     // start of the current attempt
+	QuizSeed         int64              `bson:"quiz_seed,omitempty" json:"-"`                                 // orders a shuffled quiz for the current attempt
+	QuizQuestionIDs  []string           `bson:"quiz_question_ids,omitempty" json:"quizQuestionIds,omitempty"` // bank questions drawn for the current attempt
+	QuizScore        *int               `bson:"quiz_score,omitempty" json:"quizScore,omitempty"`              // percent, of the last finished attempt
+	QuizPassed       bool               `bson:"quiz_passed" json:"quizPassed"`                                // whether it reached the chapter's pass score
+	ChapterCompleted bool               `bson:"chapter_completed" json:"chapterCompleted"`
+	LastAccessedAt   time.Time          `bson:"last_accessed_at" json:"lastAccessedAt"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updatedAt"`
+	// Bumped by every write of the video or quiz fields; clients send it
+	// back so a write over a newer one is refused (see ErrVersionConflict)
+	Version int `bson:"version" json:"version"`
+	// When each field was last written, by field name ("quizAnswers[2]"
+	// for one answer), so offline sync can tell which write is newer
+	FieldUpdatedAt map[string]time.Time `bson:"field_updated_at,omitempty" json:"fieldUpdatedAt,omitempty"`
+	// Where each of the user's devices left the video, by device ID, and
+	// the user's named bookmarks in it; see bookmarks.go
+	DevicePositions map[string]DevicePosition `bson:"device_positions,omitempty" json:"devicePositions,omitempty"`
+	Bookmarks       []Bookmark                `bson:"bookmarks,omitempty" json:"bookmarks,omitempty"`
+}
+
+// ============================================================================
+// REQUEST/RESPONSE MODELS
+// ============================================================================
+
+type LoginRequest struct {
+	UserID   string `json:"userId"`
+	Name     string `json:"name"`
+	Locale   string `json:"locale"`   // optional, used for emails and notifications
+	Timezone string `json:"timezone"` // optional IANA name for day boundaries
+	DeviceID string `json:"deviceId"` // optional, used to spot repeated logins
+	// The secret a guest was given when it was created, required on every
+	// later login
+	GuestSecret string `json:"guestSecret"`
+}
+
+// LoginResponse carries the user and access token in data and, for older
+// clients, the user alone in user
+type LoginResponse struct {
+	ApiResponse
+	User User `json:"user"` // deprecated, same as data
+}
+
+type UpdateVideoProgressRequest struct {
+	UserID    string `json:"userId"`
+	ChapterID string `json:"chapterId"`
+	Progress  int    `json:"progress"` // in seconds
+	Completed bool   `json:"completed"`
+	DeviceID  string `json:"deviceId"` // optional, else X-Device-ID; keeps this device's resume position
+	Version   int    `json:"version"`  // optional, the progress version the client last saw
+}
+
+type UpdateQuizProgressRequest struct {
+	UserID        string `json:"userId"`
+	ChapterID     string `json:"chapterId"`
+	QuestionIndex int    `json:"questionIndex"`
+	Answer        Answer `json:"answer"` // shaped by the question's type
+	Completed     bool   `json:"completed"`
+	SessionID     string `json:"sessionId"` // optional, falls back to the X-Session-ID header
+	Version       int    `json:"version"`   // optional, the progress version the client last saw
+}
+
+// chapterSortFields are the ?sort= options of GetChapters
+var chapterSortFields = map[string]string{
+	"order":    "order",
+	"title":    "title",
+	"duration": "duration",
+}
+
+// progressSortFields are the ?sort= options of GetUserProgress
+var progressSortFields = map[string]string{
+	"chapterId":      "chapter_id",
+	"lastAccessedAt": "last_accessed_at",
+	"updatedAt":      "updated_at",
+	"videoProgress":  "video_progress",
+}
+
+// GetProgressResponse carries the progress list in data and, for older
+// clients, in progress
+type GetProgressResponse struct {
+	ApiResponse
+	Progress []Progress `json:"progress"` // deprecated, same as data
+}
+
+type ApiResponse struct {
+	Success bool         `json:"success"`
+	Code    string       `json:"code,omitempty"` // machine-readable error code
+	Message string       `json:"message"`
+	Data    interface{}  `json:"data"`
+	Meta    *PageMeta    `json:"meta,omitempty"` // offset-paginated listings only
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// ============================================================================
+// DATABASE CONNECTION
+// ============================================================================
+
+var (
+	client      *mongo.Client
+	database    *mongo.Database
+	usersCol    *mongo.Collection
+	chaptersCol *mongo.Collection
+	progressCol *mongo.Collection
+	commentsCol *mongo.Collection
+	reportsCol  *mongo.Collection
+
+	viewerGrantsCol *mongo.Collection
+
+	pathsCol           *mongo.Collection
+	pathEnrollmentsCol *mongo.Collection
+	skillsCol          *mongo.Collection
+	quizAttemptsCol    *mongo.Collection
+	profileChangesCol  *mongo.Collection
+	coursesCol         *mongo.Collection
+	enrollmentsCol     *mongo.Collection
+	answerChangesCol   *mongo.Collection
+	activityCol        *mongo.Collection
+
+	sessionsCol          *mongo.Collection
+	analyticsRollupsCol  *mongo.Collection
+	organizationsCol     *mongo.Collection
+	authSessionsCol      *mongo.Collection
+	questionBankCol      *mongo.Collection
+	dailyActivityCol     *mongo.Collection
+	xpAwardsCol          *mongo.Collection
+	achievementsCol      *mongo.Collection
+	certificatesCol      *mongo.Collection
+	notesCol             *mongo.Collection
+	transcriptsCol       *mongo.Collection
+	analyticsEventsCol   *mongo.Collection
+	webhooksCol          *mongo.Collection
+	webhookDeliveriesCol *mongo.Collection
+	uploadsCol           *mongo.Collection
+	accountTokensCol     *mongo.Collection
+	accountMergesCol     *mongo.Collection
+	accountDeletionsCol  *mongo.Collection
+	auditLogCol          *mongo.Collection
+	cohortsCol           *mongo.Collection
+	schemaVersionCol     *mongo.Collection
+	idempotencyKeysCol   *mongo.Collection
+)
+
+// collection returns a collection of the database, its name prefixed with
+// COLLECTION_PREFIX so several deployments can share one database
+func collection(name string) *mongo.Collection {
+	return database.Collection(config.CollectionPrefix + name)
+}
+
+// InitDB initializes the MongoDB connection and the stores. The memory and
+// Postgres backends run without MongoDB; see mongoConnected.
+func InitDB() error {
+	if !backendNeedsMongo() {
+		return setupStores()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var err error
+	client, err = mongo.Connect(ctx, options.Client().ApplyURI(config.MongoDBURI).SetMonitor(mongoMonitor()))
+	if err != nil {
+		return fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	// Ping the database
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to ping MongoDB: %w", err)
+	}
+
+	detectTransactions(ctx)
+
+	database = client.Database(config.MongoDBDatabase)
+	usersCol = collection("users")
+	chaptersCol = collection("chapters")
+	progressCol = collection("progress")
+	commentsCol = collection("comments")
+	reportsCol = collection("reports")
+	viewerGrantsCol = collection("viewer_grants")
+	pathsCol = collection("learning_paths")
+	pathEnrollmentsCol = collection("path_enrollments")
+	skillsCol = collection("skills")
+	quizAttemptsCol = collection("quiz_attempts")
+	profileChangesCol = collection("profile_changes")
+	coursesCol = collection("courses")
+	enrollmentsCol = collection("enrollments")
+	answerChangesCol = collection("answer_changes")
+	activityCol = collection("activity")
+	sessionsCol = collection("sessions")
+	analyticsRollupsCol = collection("analytics_rollups")
+	organizationsCol = collection("organizations")
+	authSessionsCol = collection("auth_sessions")
+	questionBankCol = collection("question_bank")
+	dailyActivityCol = collection("daily_activity")
+	xpAwardsCol = collection("xp_awards")
+	achievementsCol = collection("achievements")
+	certificatesCol = collection("certificates")
+	notesCol = collection("notes")
+	transcriptsCol = collection("transcripts")
+	analyticsEventsCol = collection("analytics_events")
+	webhooksCol = collection("webhooks")
+	webhookDeliveriesCol = collection("webhook_deliveries")
+	uploadsCol = collection("uploads")
+	accountTokensCol = collection("account_tokens")
+	accountMergesCol = collection("account_merges")
+	accountDeletionsCol = collection("account_deletions")
+	auditLogCol = collection("audit_log")
+	cohortsCol = collection("cohorts")
+	schemaVersionCol = collection("schema_version")
+	idempotencyKeysCol = collection("idempotency_keys")
+
+	if err := setupStores(); err != nil {
+		return err
+	}
+
+	log.Println("✅ Connected to MongoDB successfully")
+
+	// Create indexes
+	createEventsCollection(ctx)
+	applySchemaValidators(ctx)
+	createIndexes()
+
+	// Give documents stored before public IDs existed one
+	backfillPublicIDs(context.Background())
+
+	return nil
+}
+
+// collectionIndex is an index the server relies on
+type collectionIndex struct {
+	col   *mongo.Collection
+	model mongo.IndexModel
+}
+
+// requiredIndexes lists every index the server relies on. createIndexes
+// creates them at startup and the deep health check verifies them.
+func requiredIndexes() []collectionIndex {
+	indexes := []collectionIndex{
+		// User indexes
+		{usersCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{usersCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "handle", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		}},
+		{usersCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		}},
+		// Organization members, newest first
+		{usersCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		}},
+
+		// Chapter indexes
+		{chaptersCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "chapter_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		// An organization's own chapters beside the shared catalog
+		{chaptersCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "order", Value: 1}},
+		}},
+		// Search, weighted toward titles
+		{chaptersCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "title", Value: "text"},
+				{Key: "description", Value: "text"},
+				{Key: "quiz.questions.question_text", Value: "text"},
+			},
+			Options: options.Index().SetName("chapter_search").SetWeights(bson.M{
+				"title":                        10,
+				"description":                  4,
+				"quiz.questions.question_text": 2,
+			}),
+		}},
+
+		// Progress indexes
+		{progressCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "chapter_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+		{progressCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "user_id", Value: 1},
+				{Key: "chapter_id", Value: 1},
+			},
+		}},
+		// Continue watching shelf
+		{progressCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "chapter_completed", Value: 1},
+				{Key: "last_accessed_at", Value: -1},
+			},
+		}},
+
+		// Comment indexes
+		{commentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "chapter_id", Value: 1},
+				{Key: "status", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+		}},
+		{commentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "status", Value: 1},
+				{Key: "report_count", Value: -1},
+			},
+		}},
+		{commentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "chapter_id", Value: 1},
+				{Key: "status", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+		}},
+		// Moderation queue
+		{commentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "status", Value: 1},
+				{Key: "report_count", Value: -1},
+			},
+		}},
+
+		// Report indexes - one report per user per comment
+		{reportsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "comment_id", Value: 1},
+				{Key: "user_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+		{reportsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "comment_id", Value: 1}},
+		}},
+
+		// Viewer grant indexes - one grant per viewer/learner pair
+		{viewerGrantsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "learner_id", Value: 1},
+				{Key: "viewer_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+		{viewerGrantsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "viewer_id", Value: 1}},
+		}},
+		{viewerGrantsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "learner_id", Value: 1}},
+		}},
+		{viewerGrantsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "viewer_id", Value: 1}},
+		}},
+
+		// Learning path indexes
+		{pathsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "path_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{pathsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "visibility", Value: 1}, {Key: "title", Value: 1}},
+		}},
+		{pathEnrollmentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "path_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Skill indexes
+		{skillsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "skill_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Question bank indexes - question IDs are unique across banks
+		{questionBankCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "question.id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{questionBankCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "bank_id", Value: 1},
+				{Key: "topic", Value: 1},
+				{Key: "difficulty", Value: 1},
+			},
+		}},
+		{questionBankCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "bank_id", Value: 1},
+				{Key: "topic", Value: 1},
+				{Key: "difficulty", Value: 1},
+			},
+		}},
+
+		// Profile change indexes
+		{profileChangesCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "changed_at", Value: -1},
+			},
+		}},
+
+		// Course indexes
+		{coursesCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "course_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{coursesCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "course_id", Value: 1}},
+		}},
+		{coursesCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "chapter_ids", Value: 1}},
+		}},
+		{coursesCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "chapter_ids", Value: 1}},
+		}},
+		{coursesCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "order", Value: 1}},
+		}},
+
+		// Enrollment indexes - one enrollment per user per course
+		{enrollmentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "course_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+		{enrollmentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "user_id", Value: 1},
+				{Key: "status", Value: 1},
+			},
+		}},
+
+		// Answer change indexes
+		{answerChangesCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "chapter_id", Value: 1},
+				{Key: "changed_at", Value: 1},
+			},
+		}},
+
+		// Activity indexes - the feed, optionally filtered by type
+		{activityCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}},
+		}},
+		{activityCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "type", Value: 1}, {Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}},
+		}},
+		// Chapters leaderboard
+		{activityCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "type", Value: 1}, {Key: "occurred_at", Value: -1}},
+		}},
+		{activityCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "occurred_at", Value: -1}, {Key: "_id", Value: -1}},
+		}},
+		{activityCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "type", Value: 1}, {Key: "occurred_at", Value: -1}},
+		}},
+
+		// Daily activity indexes - one document per user per day
+		{dailyActivityCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// XP award indexes - each reason once per user per chapter
+		{xpAwardsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "chapter_id", Value: 1},
+				{Key: "reason", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+		{xpAwardsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "awarded_at", Value: -1}},
+		}},
+		// Weekly XP leaderboard
+		{xpAwardsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "awarded_at", Value: -1}},
+		}},
+		{xpAwardsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "awarded_at", Value: -1}},
+		}},
+		{xpAwardsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "awarded_at", Value: -1}},
+		}},
+
+		// Achievement indexes - each achievement once per user
+		{achievementsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "achievement", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Certificate indexes - one per user and course, found by code
+		{certificatesCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "course_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{certificatesCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Note indexes - a user's notes by chapter, in video order
+		{notesCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "chapter_id", Value: 1},
+				{Key: "position", Value: 1},
+			},
+		}},
+		{notesCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "user_id", Value: 1},
+				{Key: "chapter_id", Value: 1},
+				{Key: "position", Value: 1},
+			},
+		}},
+		{notesCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "public_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Transcript indexes - one per chapter and language
+		{transcriptsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "chapter_id", Value: 1},
+				{Key: "lang", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+		// Search over subtitle text. Transcripts come in many languages, so
+		// words are matched as written, without English stemming.
+		{transcriptsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "segments.text", Value: "text"}},
+			Options: options.Index().SetName("transcript_search").SetDefaultLanguage("none"),
+		}},
+
+		// Analytics events of a user over time
+		{analyticsEventsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "meta.user_id", Value: 1}, {Key: "ts", Value: 1}},
+		}},
+
+		// Webhook indexes - the subscribers of an event, the dispatcher's
+		// due deliveries and each webhook's log. The log expires after 30
+		// days.
+		{webhooksCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "public_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{webhooksCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "events", Value: 1}},
+		}},
+		{webhookDeliveriesCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "public_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{webhookDeliveriesCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+		}},
+		{webhookDeliveriesCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		}},
+		{webhookDeliveriesCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(webhookDeliveryRetention.Seconds())),
+		}},
+
+		// Session indexes - current session lookup, then the rollup ranges
+		{sessionsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "device", Value: 1},
+				{Key: "last_seen_at", Value: -1},
+			},
+		}},
+		{sessionsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "started_at", Value: 1}},
+		}},
+		{sessionsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "started_at", Value: 1}},
+		}},
+
+		// Analytics rollup indexes - one rollup per organization per day
+		{analyticsRollupsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Organization indexes - admin keys are looked up by hash
+		{organizationsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "org_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{organizationsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "admin_key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		}},
+
+		// Auth session indexes - refresh tokens (current and just rotated) are
+		// looked up by hash, and expired sessions are cleaned up by TTL
+		{authSessionsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "refresh_token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{authSessionsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "previous_token_hash", Value: 1}},
+		}},
+		{authSessionsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "device", Value: 1},
+				{Key: "revoked_at", Value: 1},
+			},
+		}},
+		{authSessionsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}},
+
+		// Upload indexes
+		{uploadsCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "public_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Idempotency keys are kept for a day, then removed by TTL
+		{idempotencyKeysCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}},
+
+		// Account token indexes - tokens are redeemed by hash, replaced per
+		// user and purpose, and cleaned up by TTL once expired
+		{accountTokensCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+		{accountTokensCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}},
+		}},
+		{accountTokensCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}},
+
+		// Account merges - each guest is merged at most once
+		{accountMergesCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "guest_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		}},
+
+		// Account deletions, looked up by the erased user
+		{accountDeletionsCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		}},
+
+		// Audit log indexes, one per filter the listing is usually narrowed by
+		{auditLogCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "at", Value: -1}, {Key: "_id", Value: -1}},
+		}},
+		{auditLogCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "target.type", Value: 1}, {Key: "target.id", Value: 1}, {Key: "at", Value: -1}},
+		}},
+		{auditLogCol, mongo.IndexModel{
+			Keys: bson.D{{Key: "actor.user_id", Value: 1}, {Key: "at", Value: -1}},
+		}},
+
+		// Cohort indexes - cohort IDs are unique within a course, and a
+		// cohort's members are its course's enrollments naming it
+		{cohortsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "course_id", Value: 1},
+				{Key: "cohort_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		}},
+		{enrollmentsCol, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "org_id", Value: 1},
+				{Key: "course_id", Value: 1},
+				{Key: "cohort", Value: 1},
+			},
+		}},
+
+		// Linked identities - one user per provider account
+		{usersCol, mongo.IndexModel{
+			Keys:    bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		}},
+	}
+
+	// Public ID indexes - sparse until backfillPublicIDs has run
+	for _, col := range entityCollections() {
+		indexes = append(indexes, collectionIndex{col, mongo.IndexModel{
+			Keys:    bson.D{{Key: "public_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		}})
+	}
+
+	// Quiz attempt indexes, one per filter shape of the history listing
+	for _, keys := range []bson.D{
+		{{Key: "user_id", Value: 1}, {Key: "completed_at", Value: -1}, {Key: "_id", Value: -1}},
+		{{Key: "user_id", Value: 1}, {Key: "chapter_id", Value: 1}, {Key: "completed_at", Value: -1}, {Key: "_id", Value: -1}},
+		{{Key: "user_id", Value: 1}, {Key: "passed", Value: 1}, {Key: "completed_at", Value: -1}, {Key: "_id", Value: -1}},
+		{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "completed_at", Value: -1}, {Key: "_id", Value: -1}},
+	} {
+		indexes = append(indexes, collectionIndex{quizAttemptsCol, mongo.IndexModel{Keys: keys}})
+	}
+
+	return indexes
+}
+
+// createIndexes creates necessary database indexes
+func createIndexes() {
+	ctx := context.Background()
+
+	for _, idx := range requiredIndexes() {
+		if _, err := idx.col.Indexes().CreateOne(ctx, idx.model); err != nil {
+			log.Printf("❌ Error creating index on %s: %v", idx.col.Name(), err)
+		}
+	}
+
+	log.Println("✅ Database indexes created")
+}
+
+// CloseDB closes the MongoDB connection
+func CloseDB() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if postgresDB != nil {
+		if err := postgresDB.Close(); err != nil {
+			log.Printf("❌ Error closing Postgres: %v", err)
+		}
+	}
+	if client == nil {
+		return nil
+	}
+	return client.Disconnect(ctx)
+}
+
+// ============================================================================
+// API HANDLERS
+// ============================================================================
+
+// HealthCheck handler
+func HealthCheck(w http.ResponseWriter, r *http.Request) {
+	response := ApiResponse{
+		Success: true,
+		Message: "Server is running",
+		Data: map[string]string{
+			"status": "healthy",
+			"time":   time.Now().Format(time.RFC3339),
+		},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// Login handler - creates or retrieves user
+func Login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	if _, ok := loadTimezone(req.Timezone); req.Timezone != "" && !ok {
+		errs.add("timezone", CodeInvalid, "must be an IANA timezone such as 'Europe/Berlin'")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		req.Name = req.UserID // Use userID as name if not provided
+	}
+	req.Locale = normalizeLocale(req.Locale)
+
+	ctx := r.Context()
+
+	// Accounts with a password or a linked provider can't be entered by
+	// user ID alone
+	if signIn, err := accountSignIn(ctx, req.UserID); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if signIn == "password" {
+		sendErrorCode(w, http.StatusForbidden, ErrCodePasswordRequired, "This account signs in with email and password")
+		return
+	} else if signIn != "" {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeProviderSignInRequired, "This account signs in with Google or Apple")
+		return
+	}
+
+	// Create the user if they don't exist yet, with a secret that only
+	// this client is given
+	login := newLoginRecord(r, req.DeviceID)
+	secret, secretHash := newGuestSecret()
+	newUser := User{
+		PublicID:     newPublicID(),
+		UserID:       req.UserID,
+		OrgID:        orgID(ctx),
+		Name:         req.Name,
+		Locale:       req.Locale,
+		Timezone:     req.Timezone,
+		Role:         RoleLearner,
+		Status:       UserActive,
+		LastLoginAt:  &login.At,
+		RecentLogins: []LoginRecord{login},
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+
+		GuestSecretHash: secretHash,
+	}
+
+	user, created, err := userStore.FindOrCreate(ctx, newUser)
+	if err == ErrDuplicate {
+		// User IDs are unique across organizations
+		sendErrorCode(w, http.StatusConflict, ErrCodeWrongOrganization, "This user ID belongs to another organization")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	// Staff tokens open the admin routes, so a staff account never signs in
+	// by user ID, even one made staff before that was refused
+	if !created && staffRole(userRole(user)) {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeStaffSignInRequired, "Staff accounts sign in with email and password or with Google or Apple")
+		return
+	}
+
+	// User IDs are chosen by clients and easily guessed, so an existing
+	// guest is only entered with its secret. Guests created before they
+	// had one keep their sessions through refresh tokens.
+	if !created && !guestSecretMatches(user, req.GuestSecret) {
+		sendErrorCode(w, http.StatusUnauthorized, ErrCodeInvalidGuestSecret, "guestSecret isn't the secret this user was given")
+		return
+	}
+
+	if created {
+		log.Printf("✅ New user created: %s", req.UserID)
+		welcomeUser(ctx, user)
+	} else if status := accountStatus(user); status != UserActive {
+		sendAccountBlocked(w, status)
+		return
+	} else {
+		// Record the login, and the locale and timezone if the client sent them
+		set := bson.M{"updated_at": time.Now()}
+		if req.Locale != "" {
+			set["locale"] = req.Locale
+		}
+		if req.Timezone != "" {
+			set["timezone"] = req.Timezone
+		}
+		updated, err := recordLogin(ctx, req.UserID, login, set)
+		if err != nil {
+			log.Printf("❌ Error recording login for %s: %v", req.UserID, err)
+		} else if updated != nil {
+			user = *updated
+			log.Printf("✅ User logged in: %s", req.UserID)
+		}
+		// Otherwise a repeat of a login moments ago; answer it the same way
+	}
+
+	if !created {
+		secret = ""
+	}
+	sendLogin(w, r, AuthenticatedUser{User: user, GuestSecret: secret}, req.DeviceID, http.StatusOK)
+}
+
+// completeLogin opens a session for a user who has just logged in or signed
+// up and sends them their tokens
+func completeLogin(w http.ResponseWriter, r *http.Request, user User, deviceID string, status int) {
+	sendLogin(w, r, AuthenticatedUser{User: user}, deviceID, status)
+}
+
+// sendLogin is completeLogin for a login that may carry a new guest's secret
+func sendLogin(w http.ResponseWriter, r *http.Request, login AuthenticatedUser, deviceID string, status int) {
+	user := login.User
+	ctx := r.Context()
+	touchSession(ctx, r, user.UserID, deviceID)
+
+	token, err := startAuthSession(ctx, r, user, deviceID)
+	if err != nil {
+		log.Printf("❌ Error starting session for %s: %v", user.UserID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to start session")
+		return
+	}
+
+	publishAdminEvent(ctx, AdminEvent{Type: AdminEventLogin, OrgID: userOrgID(user), UserID: user.UserID})
+
+	login.AuthToken = token
+	response := LoginResponse{
+		ApiResponse: ApiResponse{
+			Success: true,
+			Message: "Login successful",
+			Data:    login,
+		},
+		User: user,
+	}
+	sendJSON(w, status, response)
+}
+
+// welcomeUser announces a new user and enrolls them in their organization's
+// starter course
+func welcomeUser(ctx context.Context, user User) {
+	fireWebhook(ctx, userOrgID(user), WebhookUserRegistered, map[string]interface{}{
+		"userId":       user.UserID,
+		"name":         user.Name,
+		"registeredAt": user.CreatedAt,
+	})
+	publishDomainEvent(ctx, DomainEvent{Type: DomainUserCreated, OrgID: userOrgID(user), UserID: user.UserID, Data: map[string]interface{}{
+		"name":      user.Name,
+		"locale":    user.Locale,
+		"timezone":  user.Timezone,
+		"createdAt": user.CreatedAt,
+	}})
+
+	// Courses are kept in MongoDB only
+	if !mongoConnected() {
+		return
+	}
+	if _, err := enrollUser(ctx, user.UserID, defaultCourseFor(userOrgID(user)), EnrollmentSourceDefault, 0); err != nil {
+		log.Printf("❌ Error enrolling new user %s: %v", user.UserID, err)
+	}
+}
+
+// GetChapters returns chapters sorted by ?sort= (order by default), limited
+// to a course with ?courseId= and paged with ?limit= and ?offset=
+func GetChapters(w http.ResponseWriter, r *http.Request) {
+	var errs fieldErrors
+	page := parseOffsetPage(r, &errs)
+	sort := parseSort(r, &errs, chapterSortFields, bson.D{{Key: "order", Value: 1}, {Key: "chapter_id", Value: 1}})
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	query := ChapterQuery{Sort: sort}
+	var courseUpdatedAt time.Time
+	if courseID := r.URL.Query().Get("courseId"); courseID != "" {
+		course, err := findCourse(ctx, courseID)
+		if err == mongo.ErrNoDocuments {
+			sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+			return
+		} else if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		query.ChapterIDs = append([]string{}, course.ChapterIDs...)
+		courseUpdatedAt = course.UpdatedAt
+	}
+
+	chapters, err := chapterStore.List(ctx, query)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch chapters")
+		return
+	}
+
+	// A course's listing also changes when chapters join or leave it
+	policy := cachePolicy{MaxAge: chapterCacheMaxAge, LastModified: chaptersLastModified(chapters)}
+	if courseUpdatedAt.After(policy.LastModified) {
+		policy.LastModified = courseUpdatedAt
+	}
+
+	// Learners only see chapters of courses they're enrolled in, flagged or
+	// filtered against their accessibility needs
+	if userID := r.URL.Query().Get("userId"); userID != "" {
+		// Enrollments, unlocks and prerequisites change the learner's view,
+		// so it is revalidated every time
+		policy = cachePolicy{}
+
+		enrolled, err := enrolledChapterIDs(ctx, userID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+			return
+		}
+		windows, err := chapterWindows(ctx, userID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+			return
+		}
+		now := time.Now()
+		visible := []Chapter{}
+		for _, chapter := range chapters {
+			if enrolled[chapter.ChapterID] && !windows[chapter.ChapterID].closed(now) {
+				chapter.Lock = chapterLock(windows[chapter.ChapterID], now)
+				visible = append(visible, chapter)
+			}
+		}
+		chapters = visible
+		if err := lockForPrerequisites(ctx, userID, chapters); err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		if user, err := userStore.Get(ctx, userID); err == nil {
+			chapters = applyAccessibilityPreferences(chapters, user.Accessibility)
+		}
+	}
+
+	// The learner's view is filtered in memory, so page it last
+	start, end := page.bounds(len(chapters))
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapters fetched successfully",
+		Data:    chapters[start:end],
+		Meta:    page.meta(int64(len(chapters))),
+	}
+	sendCacheableJSON(w, r, response, policy)
+}
+
+// GetChapterByID returns a specific chapter
+func GetChapterByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	chapter, err := chapterStore.Get(ctx, chapterID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	policy := cachePolicy{MaxAge: chapterCacheMaxAge}
+	if chapter.UpdatedAt != nil {
+		policy.LastModified = *chapter.UpdatedAt
+	}
+
+	if userID := r.URL.Query().Get("userId"); userID != "" {
+		policy = cachePolicy{} // the learner's view is revalidated every time
+		if !checkEnrolled(ctx, w, userID, chapterID) ||
+			!checkPrerequisitesMet(ctx, w, userID, chapterID) {
+			return
+		}
+
+		windows, err := chapterWindows(ctx, userID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+			return
+		}
+		now := time.Now()
+		if windows[chapterID].closed(now) {
+			sendChapterClosed(w)
+			return
+		}
+		chapter.Lock = chapterLock(windows[chapterID], now)
+
+		if user, err := userStore.Get(ctx, userID); err == nil {
+			chapter.AccessibilityIssues = accessibilityIssues(chapter.Accessibility, user.Accessibility)
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter fetched successfully",
+		Data:    chapter,
+	}
+	sendCacheableJSON(w, r, response, policy)
+}
+
+// ============================================================================
+// UTILITY FUNCTIONS
+// ============================================================================
+
+func sendJSON(w http.ResponseWriter, status int, data interface{}) {
+	data = prepareResponse(w, data)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// prepareResponse turns data into what sendJSON encodes, setting the
+// headers that go with it
+func prepareResponse(w http.ResponseWriter, data interface{}) interface{} {
+	data = normalizeEnvelope(w, data)
+	if meta := responseMeta(data); meta != nil {
+		w.Header().Set("X-Total-Count", strconv.FormatInt(meta.Total, 10))
+	}
+	if rawResponse(w) {
+		data = unwrapEnvelope(data)
+	}
+	return data
+}
+
+// sendError sends a failure with the code for its status; sendErrorCode
+// sends one with a code of its own
+func sendError(w http.ResponseWriter, status int, message string) {
+	sendErrorCode(w, status, errorCodeForStatus(status), message)
+}
+
+func sendErrorCode(w http.ResponseWriter, status int, code, message string) {
+	response := ApiResponse{
+		Success: false,
+		Code:    code,
+		Message: message,
+	}
+	sendJSON(w, status, response)
+}
+
+// ============================================================================
+// MAIN
+// ============================================================================
+
+// Main runs the server, or the subcommand named by the arguments, and exits
+// when it is done. The resume-learning-backend command is a call to it.
+func Main() {
+	// Subcommands have flags of their own, so only the server reads
+	// configuration flags
+	configArgs := os.Args[1:]
+	if len(configArgs) > 0 && configArgs[0] == "seed" {
+		configArgs = nil
+	}
+	if err := loadConfig(configArgs); errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		if err := runSeedCommand(os.Args[2:]); err != nil {
+			log.Fatal("Seed failed: ", err)
+		}
+		return
+	}
+
+	logConfig()
+
+	// Initialize database
+	if err := InitDB(); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	shutdownTracing := setupTracing()
+	setupRateLimiting()
+	setupEventBus(config)
+	if err := setupMediaStore(config); err != nil {
+		log.Fatal("Failed to set up media storage: ", err)
+	}
+
+	// Seed initial data and start the background jobs, which all work on
+	// MongoDB's collections
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if mongoConnected() {
+		seedData()
+		seedDefaultOrg()
+		seedDefaultCourse()
+		runStartupMigrations()
+
+		startDigestScheduler(ctx)
+		startAnalyticsScheduler(ctx)
+		startEventWriter(ctx)
+		startWebhookDispatcher(ctx)
+	}
+
+	// Load translation bundles
+	if err := LoadTranslations(); err != nil {
+		log.Fatal("Failed to load translations:", err)
+	}
+	if err := LoadCertificateTemplate(); err != nil {
+		log.Fatal("Failed to load certificate template:", err)
+	}
+
+	router, err := newRouter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// CORS configuration
+	corsHandler := handlers.CORS(
+		handlers.AllowedOrigins(config.CORSOrigins),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Admin-Key", "X-Org-ID", "Accept-Language", "X-Session-ID", "X-Device-ID", "X-Platform", "If-None-Match", "If-Modified-Since", "Idempotency-Key", "X-Request-ID"}),
+		handlers.ExposedHeaders([]string{"X-Total-Count", "Retry-After", "ETag", "Last-Modified", "Idempotent-Replayed", "X-Request-ID"}),
+	)(router)
+
+	// Start server
+	port := config.Port
+	log.Printf("🚀 Server starting on port %s", port)
+	log.Printf("📡 API available at http://localhost:%s/api", port)
+	serveErr := runServer(newServer(":"+port, corsHandler))
+	if serveErr != nil {
+		log.Printf("❌ Server failed: %v", serveErr)
+	}
+
+	// Stop the background jobs before the database goes away, writing the
+	// analytics events still buffered and publishing the queued domain events
+	cancel()
+	eventsCtx, eventsCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	flushEvents(eventsCtx)
+	stopEventBus(eventsCtx)
+	eventsCancel()
+	if err := CloseDB(); err != nil {
+		log.Printf("❌ Error closing database: %v", err)
+	} else {
+		log.Println("✅ Database connection closed")
+	}
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Printf("❌ Error flushing traces: %v", err)
+	}
+	flushCancel()
+	if serveErr != nil {
+		os.Exit(1)
+	}
+}
+
+// newRouter registers the API's routes and middleware
+func newRouter() (*mux.Router, error) {
+	router := mux.NewRouter()
+	router.NotFoundHandler = RequestIDMiddleware(routeNotFound(router))
+	router.MethodNotAllowedHandler = RequestIDMiddleware(methodNotAllowed(router))
+	router.Use(RequestIDMiddleware)
+	router.Use(TracingMiddleware)
+	router.Use(CompressionMiddleware)
+	router.Use(LocaleMiddleware)
+	router.Use(EnvelopeMiddleware)
+	router.Use(RecoveryMiddleware)
+	router.Use(TimeoutMiddleware)
+	router.Use(StoreRoutesMiddleware)
+	router.Use(AuthMiddleware)
+	router.Use(RateLimitMiddleware)
+	router.Use(TenantMiddleware)
+	router.Use(IDResolutionMiddleware)
+	router.Use(SelfOnlyMiddleware)
+	router.Use(ActiveUserMiddleware)
+	router.Use(IdempotencyMiddleware)
+
+	// Kubernetes probes
+	router.HandleFunc("/livez", Liveness).Methods("GET")
+	router.HandleFunc("/readyz", Readiness).Methods("GET")
+
+	// API routes
+	api := router.PathPrefix("/api").Subrouter()
+
+	api.HandleFunc("/health", HealthCheck).Methods("GET")
+	api.HandleFunc("/health/deep", DeepHealthCheck).Methods("GET")
+	api.HandleFunc("/openapi.json", GetOpenAPIDocument).Methods("GET")
+	api.HandleFunc("/docs", GetAPIDocs).Methods("GET")
+	api.HandleFunc("/org", GetCurrentOrganization).Methods("GET")
+	api.HandleFunc("/login", Login).Methods("POST")
+	api.HandleFunc("/login/password", PasswordLogin).Methods("POST")
+	api.HandleFunc("/register", Register).Methods("POST")
+	api.HandleFunc("/auth/google", GoogleSignIn).Methods("POST")
+	api.HandleFunc("/auth/apple", AppleSignIn).Methods("POST")
+	api.HandleFunc("/email-verification", RequestEmailVerification).Methods("POST")
+	api.HandleFunc("/email-verification/confirm", ConfirmEmailVerification).Methods("POST")
+	api.HandleFunc("/password-reset", RequestPasswordReset).Methods("POST")
+	api.HandleFunc("/password-reset/confirm", ResetPassword).Methods("POST")
+	api.HandleFunc("/token/refresh", RefreshAccessToken).Methods("POST")
+	api.HandleFunc("/sessions", GetAuthSessions).Methods("GET")
+	api.HandleFunc("/sessions/{sessionId}", RevokeAuthSession).Methods("DELETE")
+	api.HandleFunc("/chapters", GetChapters).Methods("GET")
+	api.HandleFunc("/chapters/{chapterId}", GetChapterByID).Methods("GET")
+	api.HandleFunc("/progress/{userId}", GetUserProgress).Methods("GET")
+	api.HandleFunc("/progress/{userId}/{chapterId}", GetChapterProgress).Methods("GET")
+	api.HandleFunc("/progress/video", UpdateVideoProgress).Methods("POST")
+	api.HandleFunc("/progress/video/batch", UpdateVideoProgressBatch).Methods("POST")
+	api.HandleFunc("/sync", SyncProgress).Methods("POST")
+	api.HandleFunc("/ws", LiveProgressSocket).Methods("GET")
+	api.HandleFunc("/progress/quiz", UpdateQuizProgress).Methods("POST")
+	api.HandleFunc("/progress/{userId}/reset", ResetProgress).Methods("DELETE")
+	api.HandleFunc("/progress/{userId}/{chapterId}/resume", GetResumePoints).Methods("GET")
+	api.HandleFunc("/progress/{userId}/{chapterId}/bookmarks", GetBookmarks).Methods("GET")
+	api.HandleFunc("/progress/{userId}/{chapterId}/bookmarks", CreateBookmark).Methods("POST")
+	api.HandleFunc("/progress/{userId}/{chapterId}/bookmarks/{bookmarkId}", DeleteBookmark).Methods("DELETE")
+	api.HandleFunc("/users/merge", MergeAccounts).Methods("POST")
+	api.HandleFunc("/users/{userId}", DeleteUser).Methods("DELETE")
+	api.HandleFunc("/users/{userId}/export", ExportUserData).Methods("GET")
+	api.HandleFunc("/users/{userId}/profile", GetUserProfile).Methods("GET")
+	api.HandleFunc("/users/{userId}/profile", UpdateUserProfile).Methods("PUT", "PATCH")
+	api.HandleFunc("/uploads", CreateUpload).Methods("POST")
+	api.HandleFunc("/media/uploads/{uploadId}", GetMedia).Methods("GET")
+	api.HandleFunc("/users/{userId}/privacy", UpdateProfilePrivacy).Methods("PUT")
+	api.HandleFunc("/users/{userId}/enrollments", GetUserEnrollments).Methods("GET")
+	api.HandleFunc("/enrollments", CreateEnrollment).Methods("POST")
+	api.HandleFunc("/courses", GetCourses).Methods("GET")
+	api.HandleFunc("/courses/{courseId}", GetCourseByID).Methods("GET")
+	api.HandleFunc("/courses/{courseId}/chapters", GetCourseChapters).Methods("GET")
+	api.HandleFunc("/courses/{courseId}/enrollments", EnrollInCourse).Methods("POST")
+	api.HandleFunc("/courses/{courseId}/enrollments/{userId}", UnenrollFromCourse).Methods("DELETE")
+	api.HandleFunc("/public/profiles/{handle}", GetPublicProfile).Methods("GET")
+	api.HandleFunc("/chapters/{chapterId}/subtitles", GetChapterSubtitles).Methods("GET")
+	api.HandleFunc("/search", Search).Methods("GET")
+	api.HandleFunc("/events", TrackEvents).Methods("POST")
+	api.HandleFunc("/chapters/{chapterId}/comments", GetChapterComments).Methods("GET")
+	api.HandleFunc("/chapters/{chapterId}/comments", CreateComment).Methods("POST")
+	api.HandleFunc("/comments/{commentId}/report", ReportComment).Methods("POST")
+	api.HandleFunc("/users/{userId}/attempts", GetQuizAttempts).Methods("GET")
+	api.HandleFunc("/quiz/{userId}/{chapterId}", GetQuiz).Methods("GET")
+	api.HandleFunc("/quiz/{userId}/{chapterId}/review", GetQuizReview).Methods("GET")
+	api.HandleFunc("/quiz/{userId}/{chapterId}/attempts", GetChapterQuizAttempts).Methods("GET")
+	api.HandleFunc("/quiz/{userId}/{chapterId}/retake", RetakeQuiz).Methods("POST")
+	api.HandleFunc("/users/{userId}/continue-watching", GetContinueWatching).Methods("GET")
+	api.HandleFunc("/users/{userId}/activity", GetActivityFeed).Methods("GET")
+	api.HandleFunc("/users/{userId}/streak", GetUserStreak).Methods("GET")
+	api.HandleFunc("/users/{userId}/xp", GetUserXP).Methods("GET")
+	api.HandleFunc("/users/{userId}/achievements", GetUserAchievements).Methods("GET")
+	api.HandleFunc("/leaderboard", GetLeaderboard).Methods("GET")
+	api.HandleFunc("/leaderboard/around/{userId}", GetLeaderboardAround).Methods("GET")
+	api.HandleFunc("/certificates/verify/{code}", VerifyCertificate).Methods("GET")
+	api.HandleFunc("/certificates/{userId}", GetUserCertificates).Methods("GET")
+	api.HandleFunc("/certificates/{userId}/{certificateId}/pdf", GetCertificatePDF).Methods("GET")
+	api.HandleFunc("/notes", GetNotes).Methods("GET")
+	api.HandleFunc("/notes", CreateNote).Methods("POST")
+	api.HandleFunc("/notes/{noteId}", UpdateNote).Methods("PUT")
+	api.HandleFunc("/notes/{noteId}", DeleteNote).Methods("DELETE")
+	api.HandleFunc("/users/{userId}/accessibility", UpdateAccessibilityPreferences).Methods("PUT")
+	api.HandleFunc("/graph", GetPrerequisiteGraph).Methods("GET")
+	api.HandleFunc("/skills", GetSkills).Methods("GET")
+	api.HandleFunc("/users/{userId}/skills", GetSkillsRadar).Methods("GET")
+	api.HandleFunc("/users/{userId}/recommendations", GetRecommendations).Methods("GET")
+	api.HandleFunc("/paths", GetPaths).Methods("GET")
+	api.HandleFunc("/paths", CreateUserPath).Methods("POST")
+	api.HandleFunc("/paths/{pathId}", GetPathByID).Methods("GET")
+	api.HandleFunc("/paths/{pathId}/start", StartPath).Methods("POST")
+	api.HandleFunc("/paths/{pathId}/progress/{userId}", GetPathProgress).Methods("GET")
+	api.HandleFunc("/viewers/requests", RequestViewerAccess).Methods("POST")
+	api.HandleFunc("/users/{userId}/viewers", GetLearnerViewerGrants).Methods("GET")
+	api.HandleFunc("/users/{userId}/viewers/{grantId}", RespondViewerGrant).Methods("PUT")
+	api.HandleFunc("/viewers/{viewerId}/learners", GetViewerLearners).Methods("GET")
+	api.HandleFunc("/viewers/{viewerId}/learners/{learnerId}/progress", GetViewerLearnerProgress).Methods("GET")
+	api.HandleFunc("/viewers/{viewerId}/grants/{grantId}", RevokeViewerAccess).Methods("DELETE")
+	api.HandleFunc("/viewers/{viewerId}/grants/{grantId}/digest", UpdateViewerDigest).Methods("PUT")
+
+	// Instructor routes - courses, enrollments, chapter analytics and chapter
+	// drafts, for org admins and instructors
+	instructor := api.PathPrefix("/admin").Subrouter()
+	instructor.Use(requireInstructor)
+
+	// Teacher-facing dashboards, for the same staff
+	dashboards := api.PathPrefix("/instructor").Subrouter()
+	dashboards.Use(requireInstructor)
+	dashboards.HandleFunc("/cohorts/{cohortId}/progress", GetCohortProgressMatrix).Methods("GET")
+
+	instructor.HandleFunc("/courses", CreateCourse).Methods("POST")
+	instructor.HandleFunc("/courses/{courseId}", UpdateCourse).Methods("PUT")
+	instructor.HandleFunc("/courses/{courseId}/enrollments", AdminEnrollInCourse).Methods("POST")
+	instructor.HandleFunc("/courses/{courseId}/drip", UpdateCourseDrip).Methods("PUT")
+	instructor.HandleFunc("/courses/{courseId}/releases", UpdateCourseReleases).Methods("PUT")
+	instructor.HandleFunc("/courses/{courseId}/enrollments/{userId}/cohort", UpdateEnrollmentCohort).Methods("PUT")
+	instructor.HandleFunc("/courses/{courseId}/cohorts", GetCohorts).Methods("GET")
+	instructor.HandleFunc("/courses/{courseId}/cohorts", CreateCohort).Methods("POST")
+	instructor.HandleFunc("/courses/{courseId}/cohorts/{cohortId}", UpdateCohort).Methods("PUT")
+	instructor.HandleFunc("/courses/{courseId}/cohorts/{cohortId}", DeleteCohort).Methods("DELETE")
+	instructor.HandleFunc("/courses/{courseId}/cohorts/{cohortId}/members", GetCohortMembers).Methods("GET")
+	instructor.HandleFunc("/courses/{courseId}/cohorts/{cohortId}/members", AddCohortMembers).Methods("POST")
+	instructor.HandleFunc("/courses/{courseId}/cohorts/{cohortId}/members/{userId}", RemoveCohortMember).Methods("DELETE")
+	instructor.HandleFunc("/courses/{courseId}/cohorts/{cohortId}/progress", GetCohortProgress).Methods("GET")
+	instructor.HandleFunc("/courses/{courseId}/enrollments/{userId}/access", ExtendEnrollmentAccess).Methods("PUT")
+	instructor.HandleFunc("/courses/{courseId}/enrollments/{userId}", RevokeEnrollmentAccess).Methods("DELETE")
+	instructor.HandleFunc("/analytics/chapters/{chapterId}", GetChapterAnalytics).Methods("GET")
+	instructor.HandleFunc("/chapters/{chapterId}/draft", GetChapterDraft).Methods("GET")
+	instructor.HandleFunc("/chapters/{chapterId}/draft", SaveChapterDraft).Methods("PUT")
+	instructor.HandleFunc("/chapters/{chapterId}/draft", DiscardChapterDraft).Methods("DELETE")
+	instructor.HandleFunc("/chapters/{chapterId}/publish", PublishChapter).Methods("POST")
+
+	// Admin routes - org admins manage their own organization, its members
+	// and its content. Platform admins without X-Org-ID edit the shared
+	// catalog through the same routes.
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(requireAdmin)
+
+	admin.HandleFunc("/org", UpdateCurrentOrganization).Methods("PUT")
+	admin.HandleFunc("/org/members", GetOrganizationMembers).Methods("GET")
+	admin.HandleFunc("/events", StreamAdminEvents).Methods("GET")
+	admin.HandleFunc("/audit", GetAuditLog).Methods("GET")
+	admin.HandleFunc("/reports/progress.csv", ExportProgressReport).Methods("GET")
+	admin.HandleFunc("/users/import", ImportUsers).Methods("POST")
+	admin.HandleFunc("/users/{userId}", DeleteUser).Methods("DELETE")
+	admin.HandleFunc("/users/{userId}/status", UpdateUserStatus).Methods("PUT")
+	admin.HandleFunc("/users/{userId}/role", UpdateUserRole).Methods("PUT")
+	admin.HandleFunc("/webhooks", GetWebhooks).Methods("GET")
+	admin.HandleFunc("/webhooks", CreateWebhook).Methods("POST")
+	admin.HandleFunc("/webhooks/{webhookId}", UpdateWebhook).Methods("PUT")
+	admin.HandleFunc("/webhooks/{webhookId}", DeleteWebhook).Methods("DELETE")
+	admin.HandleFunc("/webhooks/{webhookId}/deliveries", GetWebhookDeliveries).Methods("GET")
+	admin.HandleFunc("/chapters", CreateChapter).Methods("POST")
+	admin.HandleFunc("/chapters/archived", GetArchivedChapters).Methods("GET")
+	admin.HandleFunc("/chapters/import", ImportChapters).Methods("POST")
+	admin.HandleFunc("/chapters/{chapterId}", UpdateChapter).Methods("PUT")
+	admin.HandleFunc("/chapters/{chapterId}", ArchiveChapter).Methods("DELETE")
+	admin.HandleFunc("/chapters/{chapterId}/restore", RestoreChapter).Methods("POST")
+	admin.HandleFunc("/chapters/{chapterId}/purge", PurgeChapter).Methods("DELETE")
+	admin.HandleFunc("/chapters/{chapterId}/accessibility", UpdateChapterAccessibility).Methods("PUT")
+	admin.HandleFunc("/chapters/{chapterId}/availability", UpdateChapterAvailability).Methods("PUT")
+	admin.HandleFunc("/chapters/{chapterId}/subtitles/{lang}", UploadChapterSubtitles).Methods("PUT")
+	admin.HandleFunc("/uploads", CreateContentUpload).Methods("POST")
+	admin.HandleFunc("/chapters/{chapterId}/subtitles/{lang}", DeleteChapterSubtitles).Methods("DELETE")
+	admin.HandleFunc("/chapters/{chapterId}/prerequisites", UpdateChapterPrerequisites).Methods("PUT")
+	admin.HandleFunc("/chapters/{chapterId}/skills", TagChapterSkills).Methods("PUT")
+	admin.HandleFunc("/question-banks/{bankId}/questions", GetBankQuestions).Methods("GET")
+	admin.HandleFunc("/question-banks/{bankId}/questions", CreateBankQuestion).Methods("POST")
+	admin.HandleFunc("/question-banks/{bankId}/questions/{questionId}", UpdateBankQuestion).Methods("PUT")
+	admin.HandleFunc("/question-banks/{bankId}/questions/{questionId}", RetireBankQuestion).Methods("DELETE")
+	admin.HandleFunc("/paths", AdminCreatePath).Methods("POST")
+	admin.HandleFunc("/paths/{pathId}", AdminUpdatePath).Methods("PUT")
+	admin.HandleFunc("/paths/{pathId}", AdminDeletePath).Methods("DELETE")
+	admin.HandleFunc("/moderation", GetModerationQueue).Methods("GET")
+	admin.HandleFunc("/moderation/bulk", BulkModerate).Methods("POST")
+	admin.HandleFunc("/analytics", GetAnalyticsRollups).Methods("GET")
+	admin.HandleFunc("/analytics/rollup", RunAnalyticsRollup).Methods("POST")
+
+	// Platform routes - organizations, skills and cross-organization tools,
+	// for ADMIN_API_KEY only
+	platform := api.PathPrefix("/admin").Subrouter()
+	platform.Use(requireSuperAdmin)
+
+	platform.HandleFunc("/organizations", GetOrganizations).Methods("GET")
+	platform.HandleFunc("/organizations", CreateOrganization).Methods("POST")
+	platform.HandleFunc("/organizations/{orgId}/admin-key", RotateOrganizationAdminKey).Methods("POST")
+	platform.HandleFunc("/attempts/{attemptId}/answer-changes", GetAttemptAnswerChanges).Methods("GET")
+	platform.HandleFunc("/skills", CreateSkill).Methods("POST")
+	platform.HandleFunc("/bulk-delete/chapter-progress", BulkDeleteChapterProgress).Methods("POST")
+	platform.HandleFunc("/bulk-delete/users", BulkDeleteUsers).Methods("POST")
+	platform.HandleFunc("/repair/progress", RepairProgress).Methods("POST")
+
+	// The OpenAPI document describes the routes registered above
+	if err := buildOpenAPIDocument(router); err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI document: %w", err)
+	}
+	return router, nil
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// The tests run the API on the memory backend, which needs no database:
+// testServer serves newRouter's routes to httptest requests.
+
+var testServer http.Handler
+
+func TestMain(m *testing.M) {
+	if os.Getenv("TEST_LOG") == "" {
+		log.SetOutput(io.Discard)
+	}
+
+	config = defaultConfig()
+	config.StorageBackend = StorageMemory
+	if err := InitDB(); err != nil {
+		log.Fatal(err)
+	}
+	if err := LoadTranslations(); err != nil {
+		log.Fatal(err)
+	}
+	router, err := newRouter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	testServer = router
+
+	os.Exit(m.Run())
+}
+
+// testResponse is a response of testServer with its envelope decoded
+type testResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	ApiResponse
+}
+
+// do sends a request to testServer. body, unless nil, is sent as JSON, and
+// token, unless empty, as the bearer token.
+func do(t *testing.T, method, path, token string, body interface{}) testResponse {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	testServer.ServeHTTP(rec, req)
+
+	res := testResponse{Status: rec.Code, Header: rec.Header(), Body: rec.Body.Bytes()}
+	if err := json.Unmarshal(res.Body, &res.ApiResponse); err != nil {
+		t.Fatalf("%s %s: response isn't JSON: %v\n%s", method, path, err, res.Body)
+	}
+	return res
+}
+
+// data decodes the response's data into v
+func (res testResponse) data(t *testing.T, v interface{}) {
+	t.Helper()
+	b, err := json.Marshal(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("decoding data: %v\n%s", err, b)
+	}
+}
+
+// login logs userID in by user ID, creating the guest, and returns it with
+// its tokens and secret
+func login(t *testing.T, userID string) AuthenticatedUser {
+	t.Helper()
+	res := do(t, "POST", "/api/login", "", LoginRequest{UserID: userID})
+	if res.Status != http.StatusOK && res.Status != http.StatusCreated {
+		t.Fatalf("login %s: %d %s", userID, res.Status, res.Body)
+	}
+	var user AuthenticatedUser
+	res.data(t, &user)
+	return user
+}
+
+func TestGuestLogin(t *testing.T) {
+	userID := testUserID("guest")
+	secret := login(t, userID).GuestSecret
+	if secret == "" {
+		t.Fatal("a new guest wasn't given a secret")
+	}
+
+	for _, guess := range []string{"", "not-the-secret"} {
+		res := do(t, "POST", "/api/login", "", LoginRequest{UserID: userID, GuestSecret: guess})
+		if res.Status != http.StatusUnauthorized || res.Code != ErrCodeInvalidGuestSecret {
+			t.Fatalf("login with secret %q: got %d %q, want 401 %q", guess, res.Status, res.Code, ErrCodeInvalidGuestSecret)
+		}
+	}
+
+	res := do(t, "POST", "/api/login", "", LoginRequest{UserID: userID, GuestSecret: secret})
+	if res.Status != http.StatusOK {
+		t.Fatalf("login with the secret: %d %s", res.Status, res.Body)
+	}
+	var user AuthenticatedUser
+	res.data(t, &user)
+	if user.AccessToken == "" || user.GuestSecret != "" {
+		t.Fatalf("got token %q and secret %q, want a token and no secret", user.AccessToken, user.GuestSecret)
+	}
+}
@@ -0,0 +1,336 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// MEDIA STORAGE
+// ============================================================================
+
+// Uploaded images are kept in a MediaStore: a local directory, an S3 bucket
+// or a Google Cloud Storage bucket, picked with MEDIA_STORAGE. Objects are
+// private; clients read them through signed URLs that expire. A URL's
+// expiry is rounded to a window of the TTL, so reading the same image twice
+// gives the same URL and responses that include it keep their ETag.
+
+// Media storage backends, chosen with MEDIA_STORAGE
+const (
+	MediaStorageLocal = "local"
+	MediaStorageS3    = "s3"
+	MediaStorageGCS   = "gcs"
+)
+
+const (
+	defaultMediaDir    = "./uploads"
+	defaultMediaURLTTL = time.Hour
+	// mediaStoreTimeout bounds writing one object to a bucket
+	mediaStoreTimeout = 30 * time.Second
+)
+
+// MediaStore keeps uploaded files
+type MediaStore interface {
+	// Put stores body under key
+	Put(ctx context.Context, key, contentType string, body []byte) error
+	// Delete removes key; a key that isn't stored is not an error
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL, signed at issued, that reads key until
+	// expires
+	SignedURL(key string, issued, expires time.Time) string
+}
+
+// mediaStore is the store in use, set up at startup
+var mediaStore MediaStore
+
+// setupMediaStore opens the store MEDIA_STORAGE names
+func setupMediaStore(c Config) error {
+	switch backend := c.MediaStorage; backend {
+	case "", MediaStorageLocal:
+		dir := c.MediaDir
+		if dir == "" {
+			dir = defaultMediaDir
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating MEDIA_DIR: %w", err)
+		}
+		mediaStore = localMediaStore{dir: dir, baseURL: strings.TrimSuffix(c.MediaBaseURL, "/")}
+		log.Printf("✅ Storing uploads in %s", dir)
+	case MediaStorageS3:
+		store, err := newBucketMediaStore(c.S3Endpoint, c.S3Region, c.S3Bucket, c.AWSAccessKeyID, c.AWSSecretAccessKey)
+		if err != nil {
+			return err
+		}
+		mediaStore = store
+		log.Printf("✅ Storing uploads in S3 bucket %s", store.bucket)
+	case MediaStorageGCS:
+		// Cloud Storage's XML API speaks the S3 protocol with HMAC keys
+		store, err := newBucketMediaStore("https://storage.googleapis.com", "auto", c.GCSBucket,
+			c.GCSHMACAccessID, c.GCSHMACSecret)
+		if err != nil {
+			return err
+		}
+		mediaStore = store
+		log.Printf("✅ Storing uploads in Cloud Storage bucket %s", store.bucket)
+	default:
+		return fmt.Errorf("unknown MEDIA_STORAGE %q", backend)
+	}
+	return nil
+}
+
+// mediaURLWindow returns when a URL signed now starts and stops working.
+// Windows start every half TTL, so a URL is good for at least half the TTL.
+func mediaURLWindow(now time.Time) (start, expires time.Time) {
+	ttl := config.MediaURLTTL
+	start = now.Truncate(ttl / 2)
+	return start, start.Add(ttl)
+}
+
+// signedMediaURL returns the current signed URL of key, and when it expires
+func signedMediaURL(key string) (string, time.Time) {
+	if mediaStore == nil {
+		return "", time.Time{}
+	}
+	issued, expires := mediaURLWindow(time.Now())
+	return mediaStore.SignedURL(key, issued, expires), expires
+}
+
+// ============================================================================
+// LOCAL MEDIA STORE
+// ============================================================================
+
+// localMediaStore keeps files in a directory, served by GetMedia
+type localMediaStore struct {
+	dir     string
+	baseURL string // MEDIA_BASE_URL; links are relative without it
+}
+
+func (s localMediaStore) Put(ctx context.Context, key, contentType string, body []byte) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write aside and rename, so a reader never sees half a file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s localMediaStore) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s localMediaStore) SignedURL(key string, _, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{"expires": {exp}, "sig": {localMediaSignature(key, exp)}}
+	return s.baseURL + "/api/media/" + key + "?" + query.Encode()
+}
+
+// path is where key is kept. Keys are generated, never taken from clients.
+func (s localMediaStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// localMediaSignature signs a key and its expiry with the token signing key
+func localMediaSignature(key, expires string) string {
+	mac := hmac.New(sha256.New, signingKey())
+	mac.Write([]byte("media\n" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ============================================================================
+// BUCKET MEDIA STORE
+// ============================================================================
+
+// bucketMediaStore keeps files in an S3-compatible bucket, signing requests
+// with AWS Signature Version 4. It addresses objects by path
+// (endpoint/bucket/key), which S3, Cloud Storage and MinIO all accept.
+type bucketMediaStore struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newBucketMediaStore(endpoint, region, bucket, accessKey, secretKey string) (*bucketMediaStore, error) {
+	if bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("media storage needs a bucket and access keys")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	return &bucketMediaStore{
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: mediaStoreTimeout},
+	}, nil
+}
+
+func (s *bucketMediaStore) Put(ctx context.Context, key, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	s.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("storing %s: %s: %s", key, resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+func (s *bucketMediaStore) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(nil)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	s.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 answers 204 whether or not the object was there, Cloud Storage 404
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("deleting %s: %s: %s", key, resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// SignedURL presigns a GET of key
+func (s *bucketMediaStore) SignedURL(key string, issued, expires time.Time) string {
+	u, _ := url.Parse(s.objectURL(key))
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {s.accessKey + "/" + s.scope(issued)},
+		"X-Amz-Date":          {issued.UTC().Format("20060102T150405Z")},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires.Sub(issued).Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	query.Set("X-Amz-Signature", s.signature(issued, canonical))
+	u.RawQuery = canonicalQuery(query)
+	return u.String()
+}
+
+// objectURL is the path-style URL of key
+func (s *bucketMediaStore) objectURL(key string) string {
+	return s.endpoint + "/" + s.bucket + "/" + key
+}
+
+// sign adds a Signature Version 4 Authorization header to req, covering its
+// host, content type and the X-Amz headers
+func (s *bucketMediaStore) sign(req *http.Request, now time.Time) {
+	req.Header.Set("X-Amz-Date", now.UTC().Format("20060102T150405Z"))
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), signedHeaders, s.signature(now, canonical)))
+}
+
+// scope is the credential scope of a signature made at t
+func (s *bucketMediaStore) scope(t time.Time) string {
+	return t.UTC().Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request made at t
+func (s *bucketMediaStore) signature(t time.Time, canonical string) string {
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		t.UTC().Format("20060102T150405Z"),
+		s.scope(t),
+		hex.EncodeToString(hashed[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.UTC().Format("20060102"))
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by name, with spaces as %20 as
+// Signature Version 4 requires
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
@@ -0,0 +1,492 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ACCOUNT MERGE MODELS
+// ============================================================================
+
+// Learners often start as guests and register later. POST /api/users/merge
+// moves a guest's progress, XP, notes and quiz attempts into the account
+// making the request. The guest is closed before anything moves, so it
+// can't write while its data is in flight. Every step can be run again
+// without changing the outcome, and the merge is recorded in
+// account_merges; if one fails partway, sending it again finishes it.
+
+// Merge statuses
+const (
+	MergeRunning   = "running"
+	MergeCompleted = "completed"
+)
+
+// ErrCodeAlreadyMerged is the error code for a guest merged before
+const ErrCodeAlreadyMerged = "already_merged"
+
+// AccountMerge records a guest merged into an account
+type AccountMerge struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID    string             `bson:"public_id" json:"id"`
+	OrgID       string             `bson:"org_id" json:"-"`
+	GuestID     string             `bson:"guest_id" json:"guestUserId"`
+	UserID      string             `bson:"user_id" json:"userId"`
+	Status      string             `bson:"status" json:"status"`
+	Report      MergeReport        `bson:"report" json:"report"`
+	StartedAt   time.Time          `bson:"started_at" json:"startedAt"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completedAt,omitempty"`
+}
+
+// MergeReport counts what a merge moved. A merge finished on a retry only
+// counts what the retry moved.
+type MergeReport struct {
+	ProgressMoved    int `bson:"progress_moved" json:"progressMoved"`
+	ProgressMerged   int `bson:"progress_merged" json:"progressMerged"` // chapters both had progress on
+	NotesMoved       int `bson:"notes_moved" json:"notesMoved"`
+	AttemptsMoved    int `bson:"attempts_moved" json:"attemptsMoved"`
+	XPAwardsMoved    int `bson:"xp_awards_moved" json:"xpAwardsMoved"`
+	EnrollmentsMoved int `bson:"enrollments_moved" json:"enrollmentsMoved"`
+	XP               int `bson:"xp" json:"xp"` // the account's total afterwards
+}
+
+type MergeAccountsRequest struct {
+	GuestUserID string `json:"guestUserId"`
+	GuestSecret string `json:"guestSecret"` // the secret the guest was given when it was created, proving the client holds it
+}
+
+// ============================================================================
+// ACCOUNT MERGE HANDLERS
+// ============================================================================
+
+// MergeAccounts moves a guest's data into the account in the access token.
+// The account must sign in with a password or a provider, and the guest
+// must not, so a merge can never empty a real account.
+func MergeAccounts(w http.ResponseWriter, r *http.Request) {
+	var req MergeAccountsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+	self := authUserID(ctx)
+
+	var errs fieldErrors
+	errs.required("guestUserId", req.GuestUserID)
+	errs.required("guestSecret", req.GuestSecret)
+	if req.GuestUserID != "" && req.GuestUserID == self {
+		errs.add("guestUserId", CodeInvalid, "must not be your own user ID")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	if signIn, err := accountSignIn(ctx, self); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if signIn == "" {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeForbidden, "Register or sign in with Google or Apple before merging a guest")
+		return
+	}
+
+	var guest User
+	err := usersCol.FindOne(ctx, bson.M{"user_id": req.GuestUserID}).Decode(&guest)
+	if err == mongo.ErrNoDocuments || (err == nil && userOrgID(guest) != orgID(ctx)) {
+		sendError(w, http.StatusNotFound, "Guest not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if guest.PasswordHash != "" || len(guest.Identities) > 0 {
+		sendError(w, http.StatusConflict, "Only guest accounts can be merged")
+		return
+	}
+	// Anyone can learn a guest's user ID, and get a token for it wherever
+	// guests were entered by user ID alone; only the client that created
+	// the guest has its secret. The secret outlives the sessions a merge
+	// revokes, so it also finishes a merge that failed partway.
+	if !guestSecretMatches(guest, req.GuestSecret) {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeInvalidGuestSecret, "guestSecret isn't the secret the guest was given")
+		return
+	}
+	if status := accountStatus(guest); status != UserActive {
+		// Closed by an unfinished merge, which startMerge picks up, or
+		// blocked by an admin, which a merge mustn't get around
+		count, err := accountMergesCol.CountDocuments(ctx, bson.M{"guest_id": guest.UserID})
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		} else if count == 0 {
+			sendAccountBlocked(w, status)
+			return
+		}
+	}
+
+	merge, err := startMerge(ctx, guest, self)
+	if err == errAlreadyMerged {
+		sendErrorCode(w, http.StatusConflict, ErrCodeAlreadyMerged, "This guest has already been merged")
+		return
+	} else if err != nil {
+		log.Printf("❌ Error starting merge of %s into %s: %v", guest.UserID, self, err)
+		sendError(w, http.StatusInternalServerError, "Failed to merge accounts")
+		return
+	}
+
+	report, err := runMerge(ctx, guest.UserID, self)
+	if err != nil {
+		// The guest stays closed and the merge running; a retry finishes it
+		log.Printf("❌ Error merging %s into %s: %v", guest.UserID, self, err)
+		sendError(w, http.StatusInternalServerError, "Failed to merge accounts, try again to finish")
+		return
+	}
+
+	now := time.Now()
+	merge.Status, merge.Report, merge.CompletedAt = MergeCompleted, report, &now
+	_, err = accountMergesCol.UpdateOne(ctx, bson.M{"_id": merge.ID}, bson.M{"$set": bson.M{
+		"status":       MergeCompleted,
+		"report":       report,
+		"completed_at": now,
+	}})
+	if err != nil {
+		log.Printf("❌ Error recording merge of %s into %s: %v", guest.UserID, self, err)
+	}
+
+	log.Printf("✅ Guest merged: guest=%s, user=%s, progress=%d+%d, notes=%d, attempts=%d, xp=%d",
+		guest.UserID, self, report.ProgressMoved, report.ProgressMerged, report.NotesMoved, report.AttemptsMoved, report.XP)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Accounts merged successfully",
+		Data:    merge,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// ACCOUNT MERGE HELPERS
+// ============================================================================
+
+var errAlreadyMerged = errors.New("guest already merged")
+
+// startMerge records the merge, or picks up an unfinished one into the same
+// account, and closes the guest: its status blocks its tokens and logins,
+// and its sessions are revoked
+func startMerge(ctx context.Context, guest User, userID string) (AccountMerge, error) {
+	now := time.Now()
+	merge := AccountMerge{
+		PublicID:  newPublicID(),
+		OrgID:     userOrgID(guest),
+		GuestID:   guest.UserID,
+		UserID:    userID,
+		Status:    MergeRunning,
+		StartedAt: now,
+	}
+	if _, err := accountMergesCol.InsertOne(ctx, merge); mongo.IsDuplicateKeyError(err) {
+		err := accountMergesCol.FindOne(ctx, bson.M{"guest_id": guest.UserID}).Decode(&merge)
+		if err != nil {
+			return merge, err
+		}
+		if merge.Status != MergeRunning || merge.UserID != userID {
+			return merge, errAlreadyMerged
+		}
+		log.Printf("⚠️ Resuming merge of %s into %s", guest.UserID, userID)
+	} else if err != nil {
+		return merge, err
+	} else if err := accountMergesCol.FindOne(ctx, bson.M{"guest_id": guest.UserID}).Decode(&merge); err != nil {
+		return merge, err
+	}
+
+	_, err := usersCol.UpdateOne(ctx, bson.M{"user_id": guest.UserID}, bson.M{"$set": bson.M{
+		"status":            UserDeactivated,
+		"status_reason":     "Merged into another account",
+		"status_changed_at": now,
+		"merged_into":       userID,
+		"updated_at":        now,
+	}})
+	if err != nil {
+		return merge, err
+	}
+	return merge, sessionStore.RevokeForUser(ctx, guest.UserID, now)
+}
+
+// runMerge moves the guest's data into the account, one collection at a
+// time
+func runMerge(ctx context.Context, guestID, userID string) (MergeReport, error) {
+	var report MergeReport
+	var err error
+
+	if report.ProgressMoved, report.ProgressMerged, err = mergeProgress(ctx, guestID, userID); err != nil {
+		return report, fmt.Errorf("progress: %w", err)
+	}
+	if report.NotesMoved, err = moveOwned(ctx, notesCol, guestID, userID); err != nil {
+		return report, fmt.Errorf("notes: %w", err)
+	}
+	if _, err = moveOwned(ctx, answerChangesCol, guestID, userID); err != nil {
+		return report, fmt.Errorf("answer changes: %w", err)
+	}
+	if report.AttemptsMoved, err = mergeAttempts(ctx, guestID, userID); err != nil {
+		return report, fmt.Errorf("quiz attempts: %w", err)
+	}
+	if report.XPAwardsMoved, report.XP, err = mergeXP(ctx, guestID, userID); err != nil {
+		return report, fmt.Errorf("xp: %w", err)
+	}
+	if report.EnrollmentsMoved, err = moveUnique(ctx, enrollmentsCol, "course_id", guestID, userID); err != nil {
+		return report, fmt.Errorf("enrollments: %w", err)
+	}
+	return report, nil
+}
+
+// moveOwned gives every document of the guest in col to the account
+func moveOwned(ctx context.Context, col *mongo.Collection, guestID, userID string) (int, error) {
+	result, err := col.UpdateMany(ctx, bson.M{"user_id": guestID}, bson.M{"$set": bson.M{"user_id": userID}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+// moveUnique gives the guest's documents in col to the account, except where
+// the account has its own with the same key, which it keeps. The guest's
+// duplicates are deleted.
+func moveUnique(ctx context.Context, col *mongo.Collection, key, guestID, userID string) (int, error) {
+	taken, err := col.Distinct(ctx, key, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	result, err := col.UpdateMany(ctx,
+		bson.M{"user_id": guestID, key: bson.M{"$nin": taken}},
+		bson.M{"$set": bson.M{"user_id": userID}})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := col.DeleteMany(ctx, bson.M{"user_id": guestID}); err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+// mergeProgress moves the guest's progress on chapters the account hasn't
+// started, and merges it into the account's on chapters both have. It
+// returns how many chapters it moved and merged.
+func mergeProgress(ctx context.Context, guestID, userID string) (moved, merged int, err error) {
+	cursor, err := progressCol.Find(ctx, bson.M{"user_id": guestID})
+	if err != nil {
+		return 0, 0, err
+	}
+	var guestProgress []Progress
+	if err := cursor.All(ctx, &guestProgress); err != nil {
+		return 0, 0, err
+	}
+
+	for _, theirs := range guestProgress {
+		var ours Progress
+		err := progressCol.FindOne(ctx, bson.M{"user_id": userID, "chapter_id": theirs.ChapterID}).Decode(&ours)
+		if err == mongo.ErrNoDocuments {
+			_, err = progressCol.UpdateOne(ctx, bson.M{"_id": theirs.ID}, bson.M{"$set": bson.M{"user_id": userID}})
+			if err == nil {
+				moved++
+				continue
+			} else if !mongo.IsDuplicateKeyError(err) {
+				return moved, merged, err
+			}
+			// The account just started the chapter; merge into that
+			err = progressCol.FindOne(ctx, bson.M{"user_id": userID, "chapter_id": theirs.ChapterID}).Decode(&ours)
+		}
+		if err != nil {
+			return moved, merged, err
+		}
+
+		combined := mergedProgress(ours, theirs, time.Now())
+		combined.Version++
+		if _, err := progressCol.ReplaceOne(ctx, bson.M{"_id": ours.ID}, combined); err != nil {
+			return moved, merged, err
+		}
+		if _, err := progressCol.DeleteOne(ctx, bson.M{"_id": theirs.ID}); err != nil {
+			return moved, merged, err
+		}
+		merged++
+	}
+	return moved, merged, nil
+}
+
+// mergedProgress combines the account's and the guest's progress on a
+// chapter, keeping the furthest of each: the longer video position, any
+// completion, and the better quiz attempt as a whole. Bookmarks and device
+// positions are pooled. Merging the result with either side again changes
+// nothing, so a retried merge is safe.
+func mergedProgress(ours, theirs Progress, now time.Time) Progress {
+	merged := ours
+	merged.VideoProgress = max(ours.VideoProgress, theirs.VideoProgress)
+	merged.VideoCompleted = ours.VideoCompleted || theirs.VideoCompleted
+	merged.ChapterCompleted = ours.ChapterCompleted || theirs.ChapterCompleted
+
+	// The quiz state moves together, so answers match their seed and draw
+	if quizAhead(theirs, ours) {
+		merged.QuizProgress = theirs.QuizProgress
+		merged.QuizAnswers = theirs.QuizAnswers
+		merged.QuizCompleted = theirs.QuizCompleted
+		merged.QuizStartedAt = theirs.QuizStartedAt
+		merged.QuizSeed = theirs.QuizSeed
+		merged.QuizQuestionIDs = theirs.QuizQuestionIDs
+		merged.QuizScore = theirs.QuizScore
+		merged.QuizPassed = theirs.QuizPassed
+	}
+
+	if theirs.LastAccessedAt.After(merged.LastAccessedAt) {
+		merged.LastAccessedAt = theirs.LastAccessedAt
+	}
+	merged.UpdatedAt = now
+
+	if len(theirs.FieldUpdatedAt) > 0 {
+		fields := make(map[string]time.Time, len(ours.FieldUpdatedAt)+len(theirs.FieldUpdatedAt))
+		for field, at := range ours.FieldUpdatedAt {
+			fields[field] = at
+		}
+		for field, at := range theirs.FieldUpdatedAt {
+			if at.After(fields[field]) {
+				fields[field] = at
+			}
+		}
+		merged.FieldUpdatedAt = fields
+	}
+
+	if len(theirs.DevicePositions) > 0 {
+		positions := make(map[string]DevicePosition, len(ours.DevicePositions)+len(theirs.DevicePositions))
+		for device, p := range ours.DevicePositions {
+			positions[device] = p
+		}
+		for device, p := range theirs.DevicePositions {
+			if p.UpdatedAt.After(positions[device].UpdatedAt) {
+				positions[device] = p
+			}
+		}
+		merged.DevicePositions = positions
+	}
+
+	seen := make(map[string]bool, len(ours.Bookmarks))
+	for _, b := range ours.Bookmarks {
+		seen[b.ID] = true
+	}
+	for _, b := range theirs.Bookmarks {
+		if !seen[b.ID] && len(merged.Bookmarks) < maxBookmarksPerChapter {
+			merged.Bookmarks = append(merged.Bookmarks, b)
+			seen[b.ID] = true
+		}
+	}
+	return merged
+}
+
+// quizAhead reports whether a's quiz is further along than b's: passed
+// beats failed, finished beats unfinished, then the higher score, then more
+// questions answered
+func quizAhead(a, b Progress) bool {
+	if a.QuizPassed != b.QuizPassed {
+		return a.QuizPassed
+	}
+	if a.QuizCompleted != b.QuizCompleted {
+		return a.QuizCompleted
+	}
+	if scoreA, scoreB := quizScoreOf(a), quizScoreOf(b); scoreA != scoreB {
+		return scoreA > scoreB
+	}
+	return a.QuizProgress > b.QuizProgress
+}
+
+func quizScoreOf(p Progress) int {
+	if p.QuizScore == nil {
+		return -1
+	}
+	return *p.QuizScore
+}
+
+// mergeAttempts moves the guest's quiz attempts, then renumbers the
+// account's attempts at those chapters by when they were finished
+func mergeAttempts(ctx context.Context, guestID, userID string) (int, error) {
+	chapters, err := quizAttemptsCol.Distinct(ctx, "chapter_id", bson.M{"user_id": guestID})
+	if err != nil {
+		return 0, err
+	}
+	moved, err := moveOwned(ctx, quizAttemptsCol, guestID, userID)
+	if err != nil {
+		return moved, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "completed_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"number": 1})
+	for _, chapterID := range chapters {
+		cursor, err := quizAttemptsCol.Find(ctx, bson.M{"user_id": userID, "chapter_id": chapterID}, opts)
+		if err != nil {
+			return moved, err
+		}
+		var attempts []QuizAttempt
+		if err := cursor.All(ctx, &attempts); err != nil {
+			return moved, err
+		}
+		for i, attempt := range attempts {
+			if attempt.Number == i+1 {
+				continue
+			}
+			if _, err := quizAttemptsCol.UpdateOne(ctx, bson.M{"_id": attempt.ID}, bson.M{"$set": bson.M{"number": i + 1}}); err != nil {
+				return moved, err
+			}
+		}
+	}
+	return moved, nil
+}
+
+// mergeXP moves the guest's XP awards the account hasn't earned itself,
+// then sets the account's total to the sum of its awards, which is right
+// however many times it runs
+func mergeXP(ctx context.Context, guestID, userID string) (moved, total int, err error) {
+	cursor, err := xpAwardsCol.Find(ctx, bson.M{"user_id": guestID})
+	if err != nil {
+		return 0, 0, err
+	}
+	var awards []XPAwardRecord
+	if err := cursor.All(ctx, &awards); err != nil {
+		return 0, 0, err
+	}
+	for _, award := range awards {
+		_, err := xpAwardsCol.UpdateOne(ctx, bson.M{"_id": award.ID}, bson.M{"$set": bson.M{"user_id": userID}})
+		if mongo.IsDuplicateKeyError(err) {
+			// Earned on both; the account's award stands
+			_, err = xpAwardsCol.DeleteOne(ctx, bson.M{"_id": award.ID})
+		} else if err == nil {
+			moved++
+		}
+		if err != nil {
+			return moved, 0, err
+		}
+	}
+
+	sums, err := xpAwardsCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "xp": bson.M{"$sum": "$xp"}}}},
+	})
+	if err != nil {
+		return moved, 0, err
+	}
+	var sum []struct {
+		XP int `bson:"xp"`
+	}
+	if err := sums.All(ctx, &sum); err != nil {
+		return moved, 0, err
+	}
+	if len(sum) > 0 {
+		total = sum[0].XP
+	}
+	_, err = usersCol.UpdateOne(ctx, bson.M{"user_id": userID}, bson.M{"$set": bson.M{"xp": total, "updated_at": time.Now()}})
+	return moved, total, err
+}
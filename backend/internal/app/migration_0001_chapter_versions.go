@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Chapters stored before versioning have no version. They become version
+// 1, as are new chapters, along with the base version of any draft saved
+// over them, so publishing the draft doesn't look like a conflict.
+func init() {
+	registerMigration(Migration{
+		Version:     "0001_chapter_versions",
+		Description: "Give chapters stored before versioning version 1",
+		Up: func(ctx context.Context) error {
+			unversioned := bson.M{"$not": bson.M{"$gt": 0}}
+			_, err := chaptersCol.UpdateMany(ctx,
+				bson.M{"version": unversioned, "draft": bson.M{"$exists": true}, "draft.base_version": unversioned},
+				bson.M{"$set": bson.M{"version": 1, "draft.base_version": 1}})
+			if err != nil {
+				return err
+			}
+			_, err = chaptersCol.UpdateMany(ctx,
+				bson.M{"version": unversioned},
+				bson.M{"$set": bson.M{"version": 1}})
+			return err
+		},
+	})
+}
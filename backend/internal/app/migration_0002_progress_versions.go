@@ -0,0 +1,23 @@
+package app
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Progress stored before versioning has no version, which clients would
+// send back as 0 and so skip the conflict check. It becomes version 1, as
+// new progress is.
+func init() {
+	registerMigration(Migration{
+		Version:     "0002_progress_versions",
+		Description: "Give progress stored before versioning version 1",
+		Up: func(ctx context.Context) error {
+			_, err := progressCol.UpdateMany(ctx,
+				bson.M{"version": bson.M{"$not": bson.M{"$gt": 0}}},
+				bson.M{"$set": bson.M{"version": 1}})
+			return err
+		},
+	})
+}
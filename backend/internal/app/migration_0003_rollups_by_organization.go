@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Rollups used to cover every organization, one per day. They are now one
+// per organization per day, so the old unique index on the date alone goes.
+// The old rollups can't be split by organization and are dropped; an admin
+// recomputes a past day with POST /api/admin/analytics/rollup?date=.
+func init() {
+	registerMigration(Migration{
+		Version:     "0003_rollups_by_organization",
+		Description: "Drop analytics rollups that span every organization",
+		Up: func(ctx context.Context) error {
+			_, err := analyticsRollupsCol.Indexes().DropOne(ctx, "date_1")
+			var cmdErr mongo.CommandError
+			// 27 is IndexNotFound, for databases created after the change
+			if err != nil && (!errors.As(err, &cmdErr) || cmdErr.Code != 27) {
+				return err
+			}
+			_, err = analyticsRollupsCol.DeleteMany(ctx, bson.M{"org_id": bson.M{"$exists": false}})
+			return err
+		},
+	})
+}
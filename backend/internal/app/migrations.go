@@ -0,0 +1,201 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// MIGRATIONS
+// ============================================================================
+
+// Changes to stored MongoDB data, such as renaming a field or backfilling a
+// new one, are written as migrations: one file per migration, named
+// migration_<version>_<name>.go, that registers itself with
+// registerMigration. Versions sort in the order migrations run, and each is
+// recorded in the schema_version collection once applied, so it runs once
+// per database. The server applies pending migrations at startup before it
+// serves requests, unless SKIP_STARTUP_MIGRATIONS=true leaves that to
+// `admin migrate`. A failed migration stops the run; it is retried
+// next time, so migrations must be safe to run again after a partial
+// failure. Postgres has its own SQL migrations (see store_postgres.go).
+
+// Migration is one versioned change to stored data
+type Migration struct {
+	Version     string // e.g. "0001_chapter_versions"
+	Description string
+	Up          func(ctx context.Context) error
+}
+
+// AppliedMigration is a schema_version record
+type AppliedMigration struct {
+	Version     string    `bson:"_id" json:"version"`
+	Description string    `bson:"description" json:"description"`
+	AppliedAt   time.Time `bson:"applied_at" json:"appliedAt"`
+	DurationMs  int64     `bson:"duration_ms" json:"durationMs"`
+}
+
+const (
+	// migrationLockID is the schema_version document held while migrating,
+	// so servers starting together don't run the same migration twice
+	migrationLockID = "lock"
+	// migrationLockTTL is when a lock is taken to be left by a crashed run
+	migrationLockTTL = 15 * time.Minute
+	// migrationLockWait is how long a run waits for another to finish
+	migrationLockWait = 5 * time.Minute
+)
+
+// migrations are the registered migrations, in version order once
+// sortedMigrations has run
+var migrations []Migration
+
+// registerMigration adds a migration; migration files call it from init
+func registerMigration(m Migration) {
+	for _, other := range migrations {
+		if other.Version == m.Version {
+			panic("duplicate migration version " + m.Version)
+		}
+	}
+	migrations = append(migrations, m)
+}
+
+// sortedMigrations returns the registered migrations in version order
+func sortedMigrations() []Migration {
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+// ============================================================================
+// MIGRATION RUNNER
+// ============================================================================
+
+// runStartupMigrations applies pending migrations as the server starts
+func runStartupMigrations() {
+	ctx := context.Background()
+	if config.SkipStartupMigrations {
+		pending, err := pendingMigrations(ctx)
+		if err != nil {
+			log.Printf("❌ Error checking migrations: %v", err)
+		} else if len(pending) > 0 {
+			log.Printf("⚠️ %d migrations are pending; run `admin migrate`", len(pending))
+		}
+		return
+	}
+	if _, err := runMigrations(ctx); err != nil {
+		log.Fatal("Migrations failed: ", err)
+	}
+}
+
+// runMigrations applies the pending migrations in order and returns the
+// ones it applied. It holds the migration lock while it runs.
+func runMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	if err := acquireMigrationLock(ctx); err != nil {
+		return nil, err
+	}
+	defer releaseMigrationLock()
+
+	pending, err := pendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []AppliedMigration
+	for _, m := range pending {
+		start := time.Now()
+		if err := m.Up(ctx); err != nil {
+			return applied, fmt.Errorf("migration %s failed: %w", m.Version, err)
+		}
+		record := AppliedMigration{
+			Version:     m.Version,
+			Description: m.Description,
+			AppliedAt:   time.Now(),
+			DurationMs:  time.Since(start).Milliseconds(),
+		}
+		if _, err := schemaVersionCol.InsertOne(ctx, record); err != nil {
+			return applied, fmt.Errorf("failed to record migration %s: %w", m.Version, err)
+		}
+		applied = append(applied, record)
+		log.Printf("✅ Applied migration %s (%dms)", m.Version, record.DurationMs)
+	}
+	return applied, nil
+}
+
+// pendingMigrations returns the migrations not yet recorded, in order
+func pendingMigrations(ctx context.Context) ([]Migration, error) {
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+
+	var pending []Migration
+	for _, m := range sortedMigrations() {
+		if !done[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// appliedMigrations returns the schema_version records, oldest first
+func appliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	cursor, err := schemaVersionCol.Find(ctx, bson.M{"_id": bson.M{"$ne": migrationLockID}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var applied []AppliedMigration
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// acquireMigrationLock takes the migration lock, waiting while another
+// run holds it. A lock older than migrationLockTTL is taken over.
+func acquireMigrationLock(ctx context.Context) error {
+	host, _ := os.Hostname()
+	deadline := time.Now().Add(migrationLockWait)
+	for {
+		now := time.Now()
+		_, err := schemaVersionCol.UpdateOne(ctx,
+			bson.M{"_id": migrationLockID, "locked_at": bson.M{"$lt": now.Add(-migrationLockTTL)}},
+			bson.M{"$set": bson.M{"locked_at": now, "host": host}},
+			options.Update().SetUpsert(true))
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to take the migration lock: %w", err)
+		}
+		if now.After(deadline) {
+			return fmt.Errorf("another run has held the migration lock for over %s", migrationLockWait)
+		}
+		log.Println("⚠️ Waiting for another server to finish migrating")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// releaseMigrationLock lets other runs migrate
+func releaseMigrationLock() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := schemaVersionCol.DeleteOne(ctx, bson.M{"_id": migrationLockID}); err != nil {
+		log.Printf("❌ Error releasing the migration lock: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+-- Users, chapters, quiz questions and progress: the entities behind the
+-- store interfaces. Nested values that are only ever read whole are JSONB.
+
+CREATE TABLE users (
+    user_id    TEXT PRIMARY KEY,
+    public_id  TEXT NOT NULL UNIQUE,
+    org_id     TEXT NOT NULL,
+    data       JSONB NOT NULL, -- the rest of the user, as the API returns it
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX users_org_created ON users (org_id, created_at DESC);
+
+CREATE TABLE chapters (
+    chapter_id    TEXT PRIMARY KEY,
+    public_id     TEXT NOT NULL UNIQUE,
+    title         TEXT NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    video_url     TEXT NOT NULL,
+    thumbnail_url TEXT NOT NULL DEFAULT '',
+    duration      INTEGER NOT NULL,
+    "order"       INTEGER NOT NULL,
+    prerequisites JSONB NOT NULL DEFAULT '[]',
+    skills        JSONB NOT NULL DEFAULT '[]',
+    accessibility JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE TABLE quizzes (
+    chapter_id     TEXT NOT NULL REFERENCES chapters (chapter_id) ON DELETE CASCADE,
+    position       INTEGER NOT NULL,
+    question_id    TEXT NOT NULL,
+    question_text  TEXT NOT NULL,
+    options        JSONB NOT NULL,
+    correct_answer INTEGER NOT NULL,
+    skills         JSONB NOT NULL DEFAULT '[]',
+    PRIMARY KEY (chapter_id, position)
+);
+
+CREATE TABLE progress (
+    id                BIGSERIAL PRIMARY KEY,
+    public_id         TEXT NOT NULL UNIQUE,
+    org_id            TEXT NOT NULL,
+    user_id           TEXT NOT NULL,
+    chapter_id        TEXT NOT NULL,
+    video_progress    INTEGER NOT NULL DEFAULT 0,
+    video_completed   BOOLEAN NOT NULL DEFAULT FALSE,
+    quiz_progress     INTEGER NOT NULL DEFAULT 0,
+    quiz_answers      JSONB NOT NULL DEFAULT '[]',
+    quiz_completed    BOOLEAN NOT NULL DEFAULT FALSE,
+    quiz_started_at   TIMESTAMPTZ,
+    chapter_completed BOOLEAN NOT NULL DEFAULT FALSE,
+    last_accessed_at  TIMESTAMPTZ NOT NULL,
+    updated_at        TIMESTAMPTZ NOT NULL,
+    UNIQUE (user_id, chapter_id)
+);
+
+CREATE INDEX progress_org_user ON progress (org_id, user_id);
+CREATE INDEX progress_user_completed ON progress (user_id, chapter_completed);
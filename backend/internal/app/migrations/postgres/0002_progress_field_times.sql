@@ -0,0 +1,5 @@
+-- When each progress field was last written, for offline sync's
+-- last-write-wins merge. Keys are field names, e.g. "videoProgress" or
+-- "quizAnswers[2]".
+
+ALTER TABLE progress ADD COLUMN field_updated_at JSONB NOT NULL DEFAULT '{}';
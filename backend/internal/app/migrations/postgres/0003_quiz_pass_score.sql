@@ -0,0 +1,8 @@
+-- Pass scores: the percent of a chapter's quiz a learner must get right,
+-- and the score and result of each learner's last finished attempt, which
+-- decide whether the chapter is completed. 0 means the server's default.
+
+ALTER TABLE chapters ADD COLUMN pass_score INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE progress ADD COLUMN quiz_score INTEGER;
+ALTER TABLE progress ADD COLUMN quiz_passed BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,7 @@
+-- Question types beyond single choice. Existing questions keep an empty
+-- type, which means single choice. Multi-select keys are option indexes;
+-- fill-in keys are the accepted texts.
+
+ALTER TABLE quizzes ADD COLUMN type TEXT NOT NULL DEFAULT '';
+ALTER TABLE quizzes ADD COLUMN correct_answers JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE quizzes ADD COLUMN accepted_answers JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,6 @@
+-- Shuffled quizzes: a flag per chapter's quiz, and the seed that orders it
+-- for each learner's current attempt (0 until one is picked).
+
+ALTER TABLE chapters ADD COLUMN quiz_shuffle BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE progress ADD COLUMN quiz_seed BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Explanations shown next to each question when a learner reviews a
+-- finished quiz. Existing questions have none.
+
+ALTER TABLE quizzes ADD COLUMN explanation TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- Quizzes that draw their questions from a question bank, and the bank
+-- questions each learner's current attempt drew. The bank itself lives in
+-- MongoDB with the rest of the shared content tools.
+
+ALTER TABLE chapters ADD COLUMN quiz_draw JSONB;
+
+ALTER TABLE progress ADD COLUMN quiz_question_ids JSONB NOT NULL DEFAULT '[]';
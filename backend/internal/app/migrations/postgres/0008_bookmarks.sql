@@ -0,0 +1,6 @@
+-- Resume points: where each of a learner's devices left a chapter's video,
+-- keyed by device ID, and their named bookmarks in it.
+
+ALTER TABLE progress ADD COLUMN device_positions JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE progress ADD COLUMN bookmarks JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,4 @@
+-- Progress versions: bumped by every write of the video or quiz fields, so
+-- a client writing over a version it didn't see gets a conflict.
+
+ALTER TABLE progress ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
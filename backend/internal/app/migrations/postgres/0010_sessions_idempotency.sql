@@ -0,0 +1,33 @@
+-- Login sessions and the responses kept for Idempotency-Key retries, so the
+-- Postgres backend runs without MongoDB.
+
+CREATE TABLE auth_sessions (
+    public_id           TEXT PRIMARY KEY,
+    user_id             TEXT NOT NULL,
+    org_id              TEXT NOT NULL,
+    role                TEXT NOT NULL DEFAULT '',
+    device              TEXT NOT NULL,
+    platform            TEXT NOT NULL DEFAULT '',
+    user_agent          TEXT NOT NULL DEFAULT '',
+    ip                  TEXT NOT NULL DEFAULT '',
+    refresh_token_hash  TEXT NOT NULL,
+    previous_token_hash TEXT NOT NULL DEFAULT '', -- spots reuse of a rotated token
+    created_at          TIMESTAMPTZ NOT NULL,
+    last_used_at        TIMESTAMPTZ NOT NULL,
+    expires_at          TIMESTAMPTZ NOT NULL,
+    revoked_at          TIMESTAMPTZ
+);
+
+CREATE INDEX auth_sessions_user_device ON auth_sessions (user_id, device);
+CREATE INDEX auth_sessions_refresh_token ON auth_sessions (refresh_token_hash);
+CREATE INDEX auth_sessions_previous_token ON auth_sessions (previous_token_hash);
+
+CREATE TABLE idempotency_keys (
+    id           TEXT PRIMARY KEY, -- hash of the key, its user and route
+    request_hash TEXT NOT NULL,
+    status       INTEGER NOT NULL DEFAULT 0, -- 0 until the request finishes
+    content_type TEXT NOT NULL DEFAULT '',
+    body         BYTEA,
+    created_at   TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,4 @@
+-- Guest secrets: the hash of the secret a guest is given when it is
+-- created, kept out of data because data is what the API returns.
+
+ALTER TABLE users ADD COLUMN guest_secret_hash TEXT;
@@ -0,0 +1,389 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// COMMENT & MODERATION MODELS
+// ============================================================================
+
+// Comment kinds
+const (
+	CommentKindComment = "comment"
+	CommentKindReview  = "review"
+)
+
+// Moderation states. Content is published as approved, moves to pending
+// when it collects enough reports, and an admin either approves it again
+// or removes it. Removed is terminal.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRemoved  = "removed"
+)
+
+// defaultReportThreshold is how many reports hide content pending review
+const defaultReportThreshold = 3
+
+// Comment is a learner comment or review on a chapter
+type Comment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID    string             `bson:"public_id,omitempty" json:"id"`
+	OrgID       string             `bson:"org_id,omitempty" json:"-"`
+	ChapterID   string             `bson:"chapter_id" json:"chapterId"`
+	UserID      string             `bson:"user_id" json:"userId"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Body        string             `bson:"body" json:"body"`
+	Rating      int                `bson:"rating,omitempty" json:"rating,omitempty"` // reviews only, 1-5
+	Status      string             `bson:"status" json:"status"`
+	ReportCount int                `bson:"report_count" json:"reportCount"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// Report is a single user's report against a comment
+type Report struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID  string             `bson:"public_id,omitempty" json:"id"`
+	OrgID     string             `bson:"org_id,omitempty" json:"-"`
+	CommentID primitive.ObjectID `bson:"comment_id" json:"commentId"`
+	UserID    string             `bson:"user_id" json:"userId"`
+	Reason    string             `bson:"reason" json:"reason"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+type CreateCommentRequest struct {
+	UserID string `json:"userId"`
+	Kind   string `json:"kind"`
+	Body   string `json:"body"`
+	Rating int    `json:"rating"`
+}
+
+type ReportCommentRequest struct {
+	UserID string `json:"userId"`
+	Reason string `json:"reason"`
+}
+
+type BulkModerationRequest struct {
+	IDs    []string `json:"ids"`
+	Action string   `json:"action"` // "approve" or "remove"
+}
+
+// ============================================================================
+// COMMENT HANDLERS
+// ============================================================================
+
+// CreateComment posts a comment or review on a chapter
+func CreateComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var req CreateCommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	errs.required("body", req.Body)
+	if req.Kind == "" {
+		req.Kind = CommentKindComment
+	}
+	switch req.Kind {
+	case CommentKindComment:
+		req.Rating = 0
+	case CommentKindReview:
+		if req.Rating < 1 || req.Rating > 5 {
+			errs.add("rating", CodeOutOfRange, "must be between 1 and 5")
+		}
+	default:
+		errs.add("kind", CodeUnknownValue, "must be 'comment' or 'review'")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	count, err := chaptersCol.CountDocuments(ctx, liveChapters(ctx, bson.M{"chapter_id": chapterID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count == 0 {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	}
+
+	comment := Comment{
+		PublicID:  newPublicID(),
+		OrgID:     orgID(ctx),
+		ChapterID: chapterID,
+		UserID:    req.UserID,
+		Kind:      req.Kind,
+		Body:      strings.TrimSpace(req.Body),
+		Rating:    req.Rating,
+		Status:    ModerationApproved,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	result, err := commentsCol.InsertOne(ctx, comment)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create comment")
+		return
+	}
+	comment.ID = result.InsertedID.(primitive.ObjectID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Comment created successfully",
+		Data:    comment,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// GetChapterComments returns the visible comments for a chapter, newest first
+func GetChapterComments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	filter := bson.M{"chapter_id": chapterID, "status": ModerationApproved}
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		filter["kind"] = kind
+	}
+
+	ctx := r.Context()
+
+	cursor, err := commentsCol.Find(ctx, tenantFilter(ctx, filter), options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	comments := []Comment{}
+	if err := cursor.All(ctx, &comments); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode comments")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Comments fetched successfully",
+		Data:    comments,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ReportComment records a user's report and hides the comment for review
+// once it reaches the report threshold
+func ReportComment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	commentFilter, ok := idFilter(vars["commentId"])
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	var req ReportCommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	var comment Comment
+	err := commentsCol.FindOne(ctx, tenantFilter(ctx, commentFilter)).Decode(&comment)
+	if err == mongo.ErrNoDocuments || (err == nil && comment.Status == ModerationRemoved) {
+		sendError(w, http.StatusNotFound, "Comment not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	commentID := comment.ID
+
+	// One report per user per comment, enforced by a unique index
+	_, err = reportsCol.InsertOne(ctx, Report{
+		PublicID:  newPublicID(),
+		OrgID:     comment.OrgID,
+		CommentID: commentID,
+		UserID:    req.UserID,
+		Reason:    strings.TrimSpace(req.Reason),
+		CreatedAt: time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "You have already reported this comment")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to report comment")
+		return
+	}
+
+	err = commentsCol.FindOneAndUpdate(ctx, bson.M{"_id": commentID},
+		bson.M{"$inc": bson.M{"report_count": 1}, "$set": bson.M{"updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&comment)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to report comment")
+		return
+	}
+
+	// Auto-hide only approved content; pending items are already queued
+	if comment.Status == ModerationApproved && comment.ReportCount >= config.ModerationReportThreshold {
+		_, err = commentsCol.UpdateOne(ctx,
+			bson.M{"_id": commentID, "status": ModerationApproved},
+			bson.M{"$set": bson.M{"status": ModerationPending, "updated_at": time.Now()}})
+		if err != nil {
+			log.Printf("❌ Error hiding reported comment %s: %v", commentID.Hex(), err)
+		} else {
+			log.Printf("🚩 Comment %s hidden pending review after %d reports", commentID.Hex(), comment.ReportCount)
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Comment reported successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// ADMIN MODERATION HANDLERS
+// ============================================================================
+
+// GetModerationQueue lists comments by moderation status (pending by default),
+// most reported first
+func GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = ModerationPending
+	}
+	if status != ModerationPending && status != ModerationApproved && status != ModerationRemoved {
+		sendError(w, http.StatusBadRequest, "Status must be 'pending', 'approved' or 'removed'")
+		return
+	}
+
+	ctx := r.Context()
+
+	opts := options.Find().SetSort(bson.D{
+		{Key: "report_count", Value: -1},
+		{Key: "updated_at", Value: 1},
+	})
+	cursor, err := commentsCol.Find(ctx, tenantFilter(ctx, bson.M{"status": status}), opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch moderation queue")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	comments := []Comment{}
+	if err := cursor.All(ctx, &comments); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode moderation queue")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Moderation queue fetched successfully",
+		Data:    comments,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// BulkModerate approves or removes a batch of comments
+func BulkModerate(w http.ResponseWriter, r *http.Request) {
+	var req BulkModerationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		sendError(w, http.StatusBadRequest, "At least one comment ID is required")
+		return
+	}
+
+	match, ok := idsFilter(req.IDs)
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	// Only the organization's comments, and removed content can't be
+	// brought back
+	match = tenantFilter(r.Context(), match)
+	filter := bson.M{"$and": bson.A{match, bson.M{"status": bson.M{"$ne": ModerationRemoved}}}}
+	set := bson.M{"updated_at": time.Now()}
+	switch req.Action {
+	case "approve":
+		// Approval clears the report count so the item isn't re-hidden immediately
+		set["status"] = ModerationApproved
+		set["report_count"] = 0
+	case "remove":
+		set["status"] = ModerationRemoved
+	default:
+		sendError(w, http.StatusBadRequest, "Action must be 'approve' or 'remove'")
+		return
+	}
+
+	ctx := r.Context()
+
+	result, err := commentsCol.UpdateMany(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to apply moderation action")
+		return
+	}
+
+	if req.Action == "approve" {
+		// Let users report approved content again
+		ids, err := commentsCol.Distinct(ctx, "_id", match)
+		if err == nil {
+			_, err = reportsCol.DeleteMany(ctx, bson.M{"comment_id": bson.M{"$in": ids}})
+		}
+		if err != nil {
+			log.Printf("❌ Error clearing reports after approval: %v", err)
+		}
+	}
+
+	log.Printf("✅ Moderation %s applied to %d comments", req.Action, result.ModifiedCount)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Moderation action applied successfully",
+		Data: map[string]interface{}{
+			"matched":  result.MatchedCount,
+			"modified": result.ModifiedCount,
+		},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
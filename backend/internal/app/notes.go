@@ -0,0 +1,326 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// NOTE MODELS
+// ============================================================================
+
+// Notes are a learner's private notes on a chapter, each pinned to a
+// position in its video so the player can mark them on the seek bar and
+// jump to them. Only their author can read or change them.
+
+const (
+	// maxNoteLength bounds a note's text, in characters
+	maxNoteLength = 5000
+	// maxNotesPerChapter bounds how many notes a user keeps on one chapter
+	maxNotesPerChapter = 500
+)
+
+// Note is a learner's note at a point in a chapter's video
+type Note struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID  string             `bson:"public_id,omitempty" json:"id"`
+	OrgID     string             `bson:"org_id,omitempty" json:"-"`
+	UserID    string             `bson:"user_id" json:"userId"`
+	ChapterID string             `bson:"chapter_id" json:"chapterId"`
+	Position  int                `bson:"position" json:"position"` // in seconds into the video
+	Text      string             `bson:"text" json:"text"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+type CreateNoteRequest struct {
+	UserID    string `json:"userId"`
+	ChapterID string `json:"chapterId"`
+	Position  *int   `json:"position"`
+	Text      string `json:"text"`
+}
+
+type UpdateNoteRequest struct {
+	UserID   string `json:"userId"`
+	Position *int   `json:"position"`
+	Text     string `json:"text"`
+}
+
+// ============================================================================
+// NOTE HANDLERS
+// ============================================================================
+
+// CreateNote adds a note at a position in a chapter's video
+func CreateNote(w http.ResponseWriter, r *http.Request) {
+	var req CreateNoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	errs.required("chapterId", req.ChapterID)
+	validateNote(&errs, req.Position, req.Text)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Clients may send public IDs instead of business keys
+	req.ChapterID = resolveChapterKey(ctx, req.ChapterID)
+
+	chapter, err := chapterStore.Get(ctx, req.ChapterID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !checkUserActive(ctx, w, req.UserID) || !checkEnrolled(ctx, w, req.UserID, req.ChapterID) {
+		return
+	}
+	if errs := notePositionErrors(chapter, *req.Position); len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	count, err := notesCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"user_id": req.UserID, "chapter_id": req.ChapterID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count >= maxNotesPerChapter {
+		sendError(w, http.StatusConflict, fmt.Sprintf("A chapter can have at most %d notes", maxNotesPerChapter))
+		return
+	}
+
+	now := time.Now()
+	note := Note{
+		PublicID:  newPublicID(),
+		OrgID:     orgID(ctx),
+		UserID:    req.UserID,
+		ChapterID: req.ChapterID,
+		Position:  *req.Position,
+		Text:      strings.TrimSpace(req.Text),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	result, err := notesCol.InsertOne(ctx, note)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create note")
+		return
+	}
+	note.ID = result.InsertedID.(primitive.ObjectID)
+
+	log.Printf("📝 Note created: user=%s, chapter=%s, position=%d", note.UserID, note.ChapterID, note.Position)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Note created successfully",
+		Data:    note,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// GetNotes lists the user's notes, limited to one chapter with ?chapterId=,
+// by chapter and then by position in the video
+func GetNotes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userID, ok := actingUserID(w, r, query.Get("userId"))
+	if !ok {
+		return
+	}
+
+	var errs fieldErrors
+	errs.required("userId", userID)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	filter := bson.M{"user_id": userID}
+	if chapterID := query.Get("chapterId"); chapterID != "" {
+		filter["chapter_id"] = chapterID
+	}
+
+	opts := options.Find().SetSort(bson.D{
+		{Key: "chapter_id", Value: 1},
+		{Key: "position", Value: 1},
+		{Key: "created_at", Value: 1},
+	})
+	cursor, err := notesCol.Find(ctx, tenantFilter(ctx, filter), opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch notes")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	notes := []Note{}
+	if err := cursor.All(ctx, &notes); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode notes")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Notes fetched successfully",
+		Data:    notes,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateNote replaces a note's position and text
+func UpdateNote(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req UpdateNoteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+
+	var errs fieldErrors
+	errs.required("userId", userID)
+	validateNote(&errs, req.Position, req.Text)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	note, ok := findNote(ctx, w, userID, vars["noteId"])
+	if !ok {
+		return
+	}
+	chapter, err := chapterStore.Get(ctx, note.ChapterID)
+	if err != nil && err != ErrNotFound {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if errs := notePositionErrors(chapter, *req.Position); len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	err = notesCol.FindOneAndUpdate(ctx, bson.M{"_id": note.ID}, bson.M{"$set": bson.M{
+		"position":   *req.Position,
+		"text":       strings.TrimSpace(req.Text),
+		"updated_at": time.Now(),
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&note)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Note not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update note")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Note updated successfully",
+		Data:    note,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// DeleteNote removes one of the user's notes
+func DeleteNote(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, ok := actingUserID(w, r, r.URL.Query().Get("userId"))
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	note, ok := findNote(ctx, w, userID, vars["noteId"])
+	if !ok {
+		return
+	}
+	if _, err := notesCol.DeleteOne(ctx, bson.M{"_id": note.ID}); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to delete note")
+		return
+	}
+
+	log.Printf("📝 Note deleted: user=%s, chapter=%s", note.UserID, note.ChapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Note deleted successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// NOTE HELPERS
+// ============================================================================
+
+// validateNote checks the fields a note is written with
+func validateNote(errs *fieldErrors, position *int, text string) {
+	if position == nil {
+		errs.add("position", CodeRequired, "is required")
+	} else if *position < 0 {
+		errs.add("position", CodeOutOfRange, "must not be negative")
+	}
+	errs.required("text", strings.TrimSpace(text))
+	if len([]rune(strings.TrimSpace(text))) > maxNoteLength {
+		errs.add("text", CodeInvalid, fmt.Sprintf("must be at most %d characters", maxNoteLength))
+	}
+}
+
+// notePositionErrors checks a note's position against the chapter's video,
+// when its length is known
+func notePositionErrors(chapter Chapter, position int) fieldErrors {
+	var errs fieldErrors
+	if chapter.Duration > 0 && position > chapter.Duration {
+		errs.add("position", CodeOutOfRange, fmt.Sprintf("must be at most the video's length, %d seconds", chapter.Duration))
+	}
+	return errs
+}
+
+// findNote loads one of the user's notes by ID, sending a 404 when there is
+// none. Notes of other users are indistinguishable from missing ones.
+func findNote(ctx context.Context, w http.ResponseWriter, userID, noteID string) (Note, bool) {
+	filter, ok := idFilter(noteID)
+	if !ok || userID == "" {
+		sendError(w, http.StatusNotFound, "Note not found")
+		return Note{}, false
+	}
+	filter["user_id"] = userID
+
+	var note Note
+	err := notesCol.FindOne(ctx, tenantFilter(ctx, filter)).Decode(&note)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Note not found")
+		return Note{}, false
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return Note{}, false
+	}
+	return note, true
+}
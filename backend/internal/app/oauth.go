@@ -0,0 +1,494 @@
+package app
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// OAUTH MODELS
+// ============================================================================
+
+// The mobile app signs in with Google or Apple on the device and sends us
+// the ID token the provider gave it. We check the token's signature against
+// the provider's published keys, and its issuer, audience and expiry, then
+// log in the user linked to that provider account. A first sign-in links the
+// account to the user with the same verified email, or creates a new user.
+
+const (
+	// oauthKeysTTL is how long provider keys are cached when the provider
+	// doesn't say
+	oauthKeysTTL = time.Hour
+	// oauthKeysRefetchInterval bounds refetching keys for an unknown key ID
+	oauthKeysRefetchInterval = time.Minute
+	// oauthClockSkew is how far a token's times may be off from ours
+	oauthClockSkew = time.Minute
+)
+
+// Sign-in providers
+const (
+	ProviderGoogle = "google"
+	ProviderApple  = "apple"
+)
+
+// Error codes for provider sign-in
+const (
+	ErrCodeInvalidIDToken         = "invalid_id_token"
+	ErrCodeAccountExists          = "account_exists"
+	ErrCodeProviderSignInRequired = "provider_sign_in_required"
+)
+
+var errProviderUnavailable = errors.New("provider keys unavailable")
+
+// LinkedIdentity is a provider account a user signs in with
+type LinkedIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"-"` // the provider's user ID
+	Email    string    `bson:"email,omitempty" json:"email,omitempty"`
+	LinkedAt time.Time `bson:"linked_at" json:"linkedAt"`
+}
+
+type OAuthSignInRequest struct {
+	IDToken  string `json:"idToken"`
+	Nonce    string `json:"nonce"`    // optional, must match the token's nonce when sent
+	Name     string `json:"name"`     // optional; Apple gives the app the name, not the token
+	Locale   string `json:"locale"`   // optional, used for emails and notifications
+	Timezone string `json:"timezone"` // optional IANA name for day boundaries
+	DeviceID string `json:"deviceId"`
+}
+
+// IDTokenClaims are the claims of a provider ID token we use
+type IDTokenClaims struct {
+	Issuer        string      `json:"iss"`
+	Subject       string      `json:"sub"`
+	Audience      idTokenAud  `json:"aud"`
+	IssuedAt      int64       `json:"iat"`
+	ExpiresAt     int64       `json:"exp"`
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified idTokenBool `json:"email_verified"`
+	Name          string      `json:"name"`
+	Locale        string      `json:"locale"`
+}
+
+// idTokenAud is an audience claim, a string or a list of them
+type idTokenAud []string
+
+func (a *idTokenAud) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = idTokenAud{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// idTokenBool is a boolean claim; Apple sends some as "true" or "false"
+type idTokenBool bool
+
+func (b *idTokenBool) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseBool(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*b = idTokenBool(v)
+	return nil
+}
+
+// oauthProvider verifies the ID tokens of one provider
+type oauthProvider struct {
+	name    string
+	issuers []string
+	keysURL string // JWKS
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey // by key ID
+	expires   time.Time
+	fetchedAt time.Time
+}
+
+var oauthProviders = map[string]*oauthProvider{
+	ProviderGoogle: {
+		name:    ProviderGoogle,
+		issuers: []string{"https://accounts.google.com", "accounts.google.com"},
+		keysURL: "https://www.googleapis.com/oauth2/v3/certs",
+	},
+	ProviderApple: {
+		name:    ProviderApple,
+		issuers: []string{"https://appleid.apple.com"},
+		keysURL: "https://appleid.apple.com/auth/keys",
+	},
+}
+
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// ============================================================================
+// OAUTH HANDLERS
+// ============================================================================
+
+// GoogleSignIn logs in with a Google ID token
+func GoogleSignIn(w http.ResponseWriter, r *http.Request) {
+	oauthSignIn(w, r, oauthProviders[ProviderGoogle])
+}
+
+// AppleSignIn logs in with a Sign in with Apple ID token
+func AppleSignIn(w http.ResponseWriter, r *http.Request) {
+	oauthSignIn(w, r, oauthProviders[ProviderApple])
+}
+
+// oauthSignIn logs in the user linked to the provider account of an ID
+// token, linking or creating one on the first sign-in. It answers like
+// Login, with a 201 when it created the user.
+func oauthSignIn(w http.ResponseWriter, r *http.Request, provider *oauthProvider) {
+	var req OAuthSignInRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	errs.required("idToken", req.IDToken)
+	req.Name = strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(req.Name) > maxDisplayNameLength {
+		errs.add("name", CodeOutOfRange, "must be at most 80 characters")
+	}
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	if _, ok := loadTimezone(req.Timezone); req.Timezone != "" && !ok {
+		errs.add("timezone", CodeInvalid, "must be an IANA timezone such as 'Europe/Berlin'")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	clientIDs := provider.clientIDs()
+	if len(clientIDs) == 0 {
+		sendError(w, http.StatusServiceUnavailable, "This sign-in method isn't configured")
+		return
+	}
+
+	ctx := r.Context()
+	claims, err := provider.verify(ctx, req.IDToken, clientIDs, time.Now())
+	if err == errProviderUnavailable {
+		sendError(w, http.StatusBadGateway, "Couldn't check the token with the provider, try again later")
+		return
+	} else if err != nil || (req.Nonce != "" && !nonceMatches(claims.Nonce, req.Nonce)) {
+		sendErrorCode(w, http.StatusUnauthorized, ErrCodeInvalidIDToken, "The ID token is invalid or has expired")
+		return
+	}
+	email := strings.ToLower(claims.Email)
+	emailVerified := bool(claims.EmailVerified) && email != ""
+
+	user, created, err := findOrCreateOAuthUser(ctx, provider.name, claims, email, emailVerified, req)
+	if errors.Is(err, errAccountExists) {
+		sendErrorCode(w, http.StatusConflict, ErrCodeAccountExists,
+			"An account already uses this email; verify the email on it, then sign in again to link it")
+		return
+	} else if err != nil {
+		log.Printf("❌ Error signing in with %s: %v", provider.name, err)
+		sendError(w, http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+
+	if created {
+		log.Printf("✅ New user created with %s: %s", provider.name, user.UserID)
+		welcomeUser(ctx, user)
+	} else if userOrgID(user) != orgID(ctx) {
+		sendErrorCode(w, http.StatusConflict, ErrCodeWrongOrganization, "This account belongs to another organization")
+		return
+	} else if status := accountStatus(user); status != UserActive {
+		sendAccountBlocked(w, status)
+		return
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	updated, err := recordLogin(ctx, user.UserID, newLoginRecord(r, req.DeviceID), set)
+	if err != nil {
+		log.Printf("❌ Error recording login for %s: %v", user.UserID, err)
+	} else if updated != nil {
+		user = *updated
+		log.Printf("✅ User logged in with %s: %s", provider.name, user.UserID)
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	completeLogin(w, r, user, req.DeviceID, status)
+}
+
+// ============================================================================
+// OAUTH HELPERS
+// ============================================================================
+
+var errAccountExists = errors.New("an unlinked account has the email")
+
+// findOrCreateOAuthUser returns the user linked to the token's provider
+// account. Otherwise it links the user whose verified email the provider
+// also verified, or creates a user, and reports whether it did. A user whose
+// email isn't verified is not linked, since anyone can type an address into
+// a profile; that is errAccountExists.
+func findOrCreateOAuthUser(ctx context.Context, provider string, claims IDTokenClaims, email string, emailVerified bool, req OAuthSignInRequest) (User, bool, error) {
+	var user User
+	identity := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": claims.Subject}}}
+	err := usersCol.FindOne(ctx, identity).Decode(&user)
+	if err == nil {
+		return user, false, nil
+	} else if err != mongo.ErrNoDocuments {
+		return User{}, false, err
+	}
+
+	now := time.Now()
+	linked := LinkedIdentity{Provider: provider, Subject: claims.Subject, Email: email, LinkedAt: now}
+
+	if email != "" {
+		err := usersCol.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+		if err == nil {
+			if userOrgID(user) != orgID(ctx) {
+				return user, false, nil // the caller turns it away
+			}
+			if !emailVerified || user.EmailVerifiedAt == nil {
+				return User{}, false, errAccountExists
+			}
+			err = usersCol.FindOneAndUpdate(ctx, bson.M{"user_id": user.UserID}, bson.M{
+				"$push": bson.M{"identities": linked},
+				"$set":  bson.M{"updated_at": now},
+			}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+			if err != nil {
+				return User{}, false, err
+			}
+			log.Printf("✅ Linked %s sign-in to user %s", provider, user.UserID)
+			return user, false, nil
+		} else if err != mongo.ErrNoDocuments {
+			return User{}, false, err
+		}
+	}
+
+	name := req.Name
+	if name == "" {
+		name = claims.Name
+	}
+	if name == "" && email != "" {
+		name = email[:strings.Index(email, "@")]
+	}
+	if name == "" {
+		name = "Learner"
+	}
+	locale := normalizeLocale(req.Locale)
+	if locale == "" {
+		locale = normalizeLocale(claims.Locale)
+	}
+
+	user = User{
+		PublicID:   newPublicID(),
+		UserID:     newPublicID(),
+		OrgID:      orgID(ctx),
+		Name:       name,
+		Locale:     locale,
+		Timezone:   req.Timezone,
+		Role:       RoleLearner,
+		Status:     UserActive,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Identities: []LinkedIdentity{linked},
+	}
+	// An unverified address is left off, so it can't claim an email
+	if emailVerified {
+		user.Email = email
+		user.EmailVerifiedAt = &now
+	}
+
+	if _, err := usersCol.InsertOne(ctx, user); mongo.IsDuplicateKeyError(err) {
+		// A sign-in on another device just created the user
+		if err := usersCol.FindOne(ctx, identity).Decode(&user); err == nil {
+			return user, false, nil
+		}
+		return User{}, false, errAccountExists
+	} else if err != nil {
+		return User{}, false, err
+	}
+	return user, true, nil
+}
+
+// nonceMatches accepts the nonce the app sent, or its SHA-256 in hex, which
+// is what Sign in with Apple puts in the token
+func nonceMatches(claim, nonce string) bool {
+	if claim == nonce {
+		return true
+	}
+	sum := sha256.Sum256([]byte(nonce))
+	return claim == fmt.Sprintf("%x", sum)
+}
+
+// clientIDs are the client IDs the provider's tokens may be issued to
+func (p *oauthProvider) clientIDs() []string {
+	switch p.name {
+	case ProviderGoogle:
+		return config.GoogleClientIDs
+	case ProviderApple:
+		return config.AppleClientIDs
+	}
+	return nil
+}
+
+// verify checks an ID token's RS256 signature, issuer, audience and times,
+// and returns its claims
+func (p *oauthProvider) verify(ctx context.Context, token string, clientIDs []string, now time.Time) (IDTokenClaims, error) {
+	var claims IDTokenClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, errInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Alg != "RS256" {
+		return claims, errInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, errInvalidToken
+	}
+
+	key, err := p.key(ctx, header.Kid, now)
+	if err != nil {
+		return claims, err
+	}
+	hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature) != nil {
+		return claims, errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil || claims.Subject == "" {
+		return claims, errInvalidToken
+	}
+	if !slices.Contains(p.issuers, claims.Issuer) || !audienceAllowed(claims.Audience, clientIDs) {
+		return claims, errInvalidToken
+	}
+	skew := int64(oauthClockSkew.Seconds())
+	if now.Unix() >= claims.ExpiresAt+skew || claims.IssuedAt > now.Unix()+skew {
+		return claims, errInvalidToken
+	}
+	return claims, nil
+}
+
+// audienceAllowed reports whether a token was issued to one of clientIDs
+func audienceAllowed(aud idTokenAud, clientIDs []string) bool {
+	for _, a := range aud {
+		if slices.Contains(clientIDs, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the provider's public key with ID kid. Keys are cached for as
+// long as the provider allows, and refetched early when a token names a key
+// we don't have, since providers rotate them.
+func (p *oauthProvider) key(ctx context.Context, kid string, now time.Time) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok && now.Before(p.expires) {
+		return key, nil
+	}
+	if p.keys == nil || now.After(p.expires) || now.Sub(p.fetchedAt) >= oauthKeysRefetchInterval {
+		if err := p.fetchKeys(ctx, now); err != nil {
+			log.Printf("❌ Error fetching %s sign-in keys: %v", p.name, err)
+			if p.keys == nil {
+				return nil, errProviderUnavailable
+			}
+			// Keep using the keys we have until the provider is back
+		}
+	}
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errInvalidToken
+}
+
+// fetchKeys loads the provider's JWKS. The caller holds p.mu.
+func (p *oauthProvider) fetchKeys(ctx context.Context, now time.Time) error {
+	p.fetchedAt = now
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.keysURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", p.keysURL, resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if k.Kty != "RSA" || errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%s: no RSA keys", p.keysURL)
+	}
+
+	p.keys = keys
+	p.expires = now.Add(cacheMaxAge(resp.Header.Get("Cache-Control"), oauthKeysTTL))
+	return nil
+}
+
+// cacheMaxAge reads max-age from a Cache-Control header, or returns fallback
+func cacheMaxAge(header string, fallback time.Duration) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,1178 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ============================================================================
+// OPENAPI MODELS
+// ============================================================================
+
+// The API describes itself as an OpenAPI 3 document at /api/openapi.json,
+// browsable with Swagger UI at /api/docs. Paths, methods and path parameters
+// come from the router, so a route can't be left out; request bodies and
+// response data are generated from the Go types listed in routeDocs, so the
+// schemas follow the structs and their json tags. A route missing from
+// routeDocs is still described, without a summary or schemas.
+
+// routeDoc describes a route beyond what the router knows
+type routeDoc struct {
+	Summary string
+	Query   []string    // query parameters
+	Request interface{} // JSON request body, a value of its type
+	Status  int         // of a successful response, http.StatusOK when unset
+	Data    interface{} // the envelope's data on success, a value of its type
+	// Content is the media type of a response that isn't the JSON envelope
+	Content string
+	// RawBody is the media type of a request body that isn't JSON
+	RawBody string
+}
+
+// pagedData is the data of a cursor-paginated listing: a Page of items
+type pagedData struct {
+	Items interface{}
+}
+
+// pageOf documents a Page of items, a slice value
+func pageOf(items interface{}) pagedData {
+	return pagedData{Items: items}
+}
+
+// routeDocs documents the routes, keyed by method and path template
+var routeDocs = map[string]routeDoc{
+	"GET /api/openapi.json": {
+		Summary: "This OpenAPI document",
+		Content: "application/json",
+	},
+	"GET /api/docs": {
+		Summary: "Swagger UI for this OpenAPI document",
+		Content: "text/html",
+	},
+	"GET /livez": {
+		Summary: "Liveness probe (the process is serving)",
+		Data:    map[string]string{},
+	},
+	"GET /readyz": {
+		Summary: "Readiness probe (database, Redis, migrations); 503 when not ready",
+		Data:    DeepHealth{},
+	},
+	"GET /api/health": {
+		Summary: "Health check",
+		Data:    map[string]string{},
+	},
+	"GET /api/health/deep": {
+		Summary: "Deep health check (database, indexes, content, jobs, disk)",
+		Data:    DeepHealth{},
+	},
+	"GET /api/org": {
+		Summary: "The requesting app's organization and branding (X-Org-ID)",
+		Data:    Organization{},
+	},
+	"POST /api/login": {
+		Summary: "User login/register, returns an access token and a refresh token (optional deviceId for login history)",
+		Request: LoginRequest{},
+		Data:    AuthenticatedUser{},
+	},
+	"POST /api/login/password": {
+		Summary: "Log a password account in with its email and password",
+		Request: PasswordLoginRequest{},
+		Data:    AuthenticatedUser{},
+	},
+	"POST /api/register": {
+		Summary: "Create a password account and mail a link to verify its email; logs it in unless REQUIRE_EMAIL_VERIFICATION is set",
+		Request: RegisterRequest{},
+		Status:  http.StatusCreated,
+		Data:    AuthenticatedUser{},
+	},
+	"POST /api/auth/google": {
+		Summary: "Sign in with a Google ID token, linking or creating the user on the first sign-in (201 when created)",
+		Request: OAuthSignInRequest{},
+		Data:    AuthenticatedUser{},
+	},
+	"POST /api/auth/apple": {
+		Summary: "Sign in with a Sign in with Apple ID token, linking or creating the user on the first sign-in (201 when created)",
+		Request: OAuthSignInRequest{},
+		Data:    AuthenticatedUser{},
+	},
+	"POST /api/email-verification": {
+		Summary: "Mail the signed-in user a new link to verify their email",
+		Status:  http.StatusAccepted,
+	},
+	"POST /api/email-verification/confirm": {
+		Summary: "Verify an email with the token from its verification link",
+		Request: AccountTokenRequest{},
+		Data:    map[string]interface{}{},
+	},
+	"POST /api/password-reset": {
+		Summary: "Mail a password reset link to the account with an email",
+		Request: PasswordResetRequest{},
+		Status:  http.StatusAccepted,
+	},
+	"POST /api/password-reset/confirm": {
+		Summary: "Set a new password with the token from a reset link, signing out every session",
+		Request: ResetPasswordRequest{},
+	},
+	"POST /api/token/refresh": {
+		Summary: "Trade a refresh token for a new access token and refresh token",
+		Request: RefreshTokenRequest{},
+		Data:    AuthToken{},
+	},
+	"GET /api/sessions": {
+		Summary: "The signed-in user's active sessions (devices)",
+		Data:    []AuthSession{},
+	},
+	"DELETE /api/sessions/{sessionId}": {
+		Summary: "Sign a device out by revoking its session",
+		Data:    AuthSession{},
+	},
+	"GET /api/chapters": {
+		Summary: "Get chapters (?userId= limits to enrolled courses and applies accessibility preferences; ?courseId=&sort=&limit=&offset=)",
+		Query:   []string{"userId", "courseId", "sort", "limit", "offset"},
+		Data:    []Chapter{},
+	},
+	"GET /api/chapters/{chapterId}": {
+		Summary: "Get specific chapter (?userId= checks enrollment and prerequisites and flags accessibility issues)",
+		Query:   []string{"userId"},
+		Data:    Chapter{},
+	},
+	"GET /api/progress/{userId}": {
+		Summary: "Get user's progress (?completed=&courseId=&sort=&limit=&offset=)",
+		Query:   []string{"completed", "courseId", "sort", "limit", "offset"},
+		Data:    []Progress{},
+	},
+	"GET /api/progress/{userId}/{chapterId}": {
+		Summary: "Get specific chapter progress",
+		Data:    Progress{},
+	},
+	"POST /api/progress/video": {
+		Summary: "Update video progress (optional deviceId keeps this device's resume position, version refuses a stale write)",
+		Request: UpdateVideoProgressRequest{},
+		Data:    VideoSubmission{},
+	},
+	"POST /api/progress/video/batch": {
+		Summary: "Update video progress from a batch of position reports",
+		Request: BatchVideoProgressRequest{},
+		Data:    BatchVideoProgressResult{},
+	},
+	"POST /api/sync": {
+		Summary: "Merge progress recorded offline and return the result",
+		Request: SyncRequest{},
+		Data:    SyncResult{},
+	},
+	"GET /api/ws": {
+		Summary: "WebSocket pushing the user's progress writes as they happen",
+		Status:  http.StatusSwitchingProtocols,
+	},
+	"POST /api/progress/quiz": {
+		Summary: "Update quiz progress (optional sessionId is kept in the answer log, version refuses a stale write)",
+		Request: UpdateQuizProgressRequest{},
+		Data:    QuizSubmission{},
+	},
+	"DELETE /api/progress/{userId}/reset": {
+		Summary: "Reset user progress",
+	},
+	"GET /api/progress/{userId}/{chapterId}/resume": {
+		Summary: "Where this device should resume the video, with every device's position and the bookmarks (?deviceId=)",
+		Query:   []string{"deviceId"},
+		Data:    ResumePoints{},
+	},
+	"GET /api/progress/{userId}/{chapterId}/bookmarks": {
+		Summary: "The user's bookmarks in a chapter, by position",
+		Data:    []Bookmark{},
+	},
+	"POST /api/progress/{userId}/{chapterId}/bookmarks": {
+		Summary: "Add a named bookmark at a position in the video",
+		Request: CreateBookmarkRequest{},
+		Status:  http.StatusCreated,
+		Data:    Bookmark{},
+	},
+	"DELETE /api/progress/{userId}/{chapterId}/bookmarks/{bookmarkId}": {
+		Summary: "Delete a bookmark",
+	},
+	"POST /api/uploads": {
+		Summary: "Upload an avatar image (multipart: kind, file)",
+		RawBody: "multipart/form-data",
+		Status:  http.StatusCreated,
+		Data:    Upload{},
+	},
+	"GET /api/media/uploads/{uploadId}": {
+		Summary: "An image in the local media store, through a signed URL (?expires=&sig=)",
+		Query:   []string{"expires", "sig"},
+		Content: "image/*",
+	},
+	"POST /api/users/merge": {
+		Summary: "Merge a guest's progress, XP, notes and quiz attempts into the signed-in account",
+		Request: MergeAccountsRequest{},
+		Data:    AccountMerge{},
+	},
+	"DELETE /api/users/{userId}": {
+		Summary: "Erase your account and everything stored about you (password accounts send the password)",
+		Request: DeleteUserRequest{},
+		Data:    AccountDeletion{},
+	},
+	"GET /api/users/{userId}/export": {
+		Summary: "Everything stored about the user, as JSON or as a ZIP of JSON files with ?format=zip",
+		Query:   []string{"format"},
+		Data:    UserDataExport{},
+	},
+	"GET /api/users/{userId}/profile": {
+		Summary: "Get own profile, preferences and recent logins",
+		Data:    User{},
+	},
+	"PUT /api/users/{userId}/profile": {
+		Summary: "Replace the profile: name, email, avatar, bio, timezone and learning goals",
+		Request: UpdateProfileRequest{},
+		Data:    User{},
+	},
+	"PATCH /api/users/{userId}/profile": {
+		Summary: "Update profile fields, locale, notifications or privacy flags",
+		Request: UpdateProfileRequest{},
+		Data:    User{},
+	},
+	"PUT /api/users/{userId}/privacy": {
+		Summary: "Set handle and public profile visibility",
+		Request: UpdateProfilePrivacyRequest{},
+		Data:    User{},
+	},
+	"GET /api/users/{userId}/enrollments": {
+		Summary: "List a user's course enrollments with their progress through each course",
+		Data:    []Enrollment{},
+	},
+	"POST /api/enrollments": {
+		Summary: "Enroll in a course ({\"userId\": ..., \"courseId\": ...})",
+		Request: EnrollRequest{},
+		Data:    Enrollment{},
+	},
+	"GET /api/courses": {
+		Summary: "List the organization's courses in catalog order",
+		Data:    []Course{},
+	},
+	"GET /api/courses/{courseId}": {
+		Summary: "Get a course",
+		Data:    Course{},
+	},
+	"GET /api/courses/{courseId}/chapters": {
+		Summary: "A course's chapters in course order (?userId= requires access and adds drip and release locks)",
+		Query:   []string{"userId"},
+		Data:    []Chapter{},
+	},
+	"POST /api/courses/{courseId}/enrollments": {
+		Summary: "Enroll in a course ({\"userId\": ...})",
+		Request: EnrollRequest{},
+		Data:    Enrollment{},
+	},
+	"DELETE /api/courses/{courseId}/enrollments/{userId}": {
+		Summary: "Unenroll from a course",
+		Data:    Enrollment{},
+	},
+	"GET /api/public/profiles/{handle}": {
+		Summary: "Get a user's opt-in public profile (completed courses, badges and certificates, as its privacy flags allow)",
+		Data:    PublicProfile{},
+	},
+	"GET /api/chapters/{chapterId}/subtitles": {
+		Summary: "A chapter's subtitles as timed segments, or a WebVTT/SRT file (?lang=&format=)",
+		Query:   []string{"lang", "format"},
+		Data:    Subtitles{},
+	},
+	"GET /api/search": {
+		Summary: "Search chapters, quiz questions and subtitles (?q=&userId=&limit=)",
+		Query:   []string{"q", "userId", "limit"},
+		Data:    SearchResults{},
+	},
+	"POST /api/events": {
+		Summary: "Record a batch of client analytics events (play, pause, seek, quiz open, app open)",
+		Request: TrackEventsRequest{},
+		Status:  http.StatusAccepted,
+		Data:    TrackEventsResponse{},
+	},
+	"GET /api/chapters/{chapterId}/comments": {
+		Summary: "Get visible comments/reviews (?kind=)",
+		Query:   []string{"kind"},
+		Data:    []Comment{},
+	},
+	"POST /api/chapters/{chapterId}/comments": {
+		Summary: "Post a comment or review",
+		Request: CreateCommentRequest{},
+		Status:  http.StatusCreated,
+		Data:    Comment{},
+	},
+	"POST /api/comments/{commentId}/report": {
+		Summary: "Report a comment or review",
+		Request: ReportCommentRequest{},
+	},
+	"GET /api/users/{userId}/attempts": {
+		Summary: "Quiz attempt history (?chapterId=&passed=&from=&to=&limit=&cursor=)",
+		Query:   []string{"chapterId", "passed", "from", "to", "limit", "cursor"},
+		Data:    pageOf([]QuizAttempt{}),
+	},
+	"GET /api/quiz/{userId}/{chapterId}": {
+		Summary: "A chapter's quiz as the learner takes it, shuffled if the quiz is",
+		Data:    QuizView{},
+	},
+	"GET /api/quiz/{userId}/{chapterId}/review": {
+		Summary: "A finished quiz's answers with the answer key and explanations",
+		Data:    QuizReview{},
+	},
+	"GET /api/quiz/{userId}/{chapterId}/attempts": {
+		Summary: "A learner's attempts at one quiz, with a question-by-question review",
+		Data:    pageOf([]QuizAttempt{}),
+	},
+	"POST /api/quiz/{userId}/{chapterId}/retake": {
+		Summary: "Clear a finished quiz's answers to take it again",
+		Data:    Progress{},
+	},
+	"GET /api/users/{userId}/continue-watching": {
+		Summary: "Recently accessed, incomplete chapters (?limit=)",
+		Query:   []string{"limit"},
+		Data:    []ContinueWatchingItem{},
+	},
+	"GET /api/users/{userId}/activity": {
+		Summary: "Activity feed, newest first (?type=&limit=&cursor=)",
+		Query:   []string{"type", "limit", "cursor"},
+		Data:    pageOf([]ActivityEvent{}),
+	},
+	"GET /api/users/{userId}/streak": {
+		Summary: "Current and longest streak with a heatmap of active days (?from=&to=)",
+		Query:   []string{"from", "to"},
+		Data:    Streak{},
+	},
+	"GET /api/users/{userId}/xp": {
+		Summary: "XP total and the latest awards",
+		Data:    XPSummary{},
+	},
+	"GET /api/users/{userId}/achievements": {
+		Summary: "Every achievement, with the ones the user unlocked",
+		Data:    UserAchievements{},
+	},
+	"GET /api/leaderboard": {
+		Summary: "Top learners by XP or chapters completed (?period=&metric=&limit=)",
+		Query:   []string{"period", "metric", "limit"},
+		Data:    Leaderboard{},
+	},
+	"GET /api/leaderboard/around/{userId}": {
+		Summary: "The user's rank with their neighbors (?period=&metric=&radius=)",
+		Query:   []string{"period", "metric", "radius"},
+		Data:    Leaderboard{},
+	},
+	"GET /api/certificates/verify/{code}": {
+		Summary: "Check a certificate's verification code (no token needed)",
+		Data:    CertificateVerification{},
+	},
+	"GET /api/certificates/{userId}": {
+		Summary: "The user's course certificates, newest first",
+		Data:    []Certificate{},
+	},
+	"GET /api/certificates/{userId}/{certificateId}/pdf": {
+		Summary: "Download a certificate as a PDF",
+		Content: "application/pdf",
+	},
+	"GET /api/notes": {
+		Summary: "The user's notes, in video order (?userId=&chapterId=)",
+		Query:   []string{"userId", "chapterId"},
+		Data:    []Note{},
+	},
+	"POST /api/notes": {
+		Summary: "Add a note at a position in a chapter's video",
+		Request: CreateNoteRequest{},
+		Status:  http.StatusCreated,
+		Data:    Note{},
+	},
+	"PUT /api/notes/{noteId}": {
+		Summary: "Change a note's position and text",
+		Request: UpdateNoteRequest{},
+		Data:    Note{},
+	},
+	"DELETE /api/notes/{noteId}": {
+		Summary: "Delete a note",
+	},
+	"PUT /api/users/{userId}/accessibility": {
+		Summary: "Set accessibility preferences",
+		Request: AccessibilityPreferences{},
+		Data:    AccessibilityPreferences{},
+	},
+	"GET /api/graph": {
+		Summary: "Chapter and path prerequisite graph (?userId= adds unlock status)",
+		Query:   []string{"userId"},
+		Data:    PrerequisiteGraph{},
+	},
+	"GET /api/skills": {
+		Summary: "Skill taxonomy",
+		Data:    []Skill{},
+	},
+	"GET /api/users/{userId}/skills": {
+		Summary: "Per-skill mastery (radar chart data)",
+		Data:    []SkillMastery{},
+	},
+	"GET /api/users/{userId}/recommendations": {
+		Summary: "Chapters covering the user's skill gaps",
+		Data:    []Recommendation{},
+	},
+	"GET /api/paths": {
+		Summary: "Learning path catalog (?userId= adds the user's private paths)",
+		Query:   []string{"userId"},
+		Data:    []LearningPath{},
+	},
+	"POST /api/paths": {
+		Summary: "Build a personal path (when USER_PATHS_ENABLED=true)",
+		Request: SavePathRequest{},
+		Status:  http.StatusCreated,
+		Data:    LearningPath{},
+	},
+	"GET /api/paths/{pathId}": {
+		Summary: "Get a learning path",
+		Data:    LearningPath{},
+	},
+	"POST /api/paths/{pathId}/start": {
+		Summary: "Start a path once its prerequisite paths are complete",
+		Request: StartPathRequest{},
+	},
+	"GET /api/paths/{pathId}/progress/{userId}": {
+		Summary: "Progress through a path",
+		Data:    PathProgress{},
+	},
+	"POST /api/viewers/requests": {
+		Summary: "Manager/parent requests read-only access to a learner",
+		Request: RequestViewerAccessRequest{},
+		Status:  http.StatusCreated,
+		Data:    ViewerGrant{},
+	},
+	"GET /api/users/{userId}/viewers": {
+		Summary: "List a learner's viewer grants",
+		Data:    []ViewerGrant{},
+	},
+	"PUT /api/users/{userId}/viewers/{grantId}": {
+		Summary: "Learner approves, declines or revokes a grant",
+		Request: RespondViewerGrantRequest{},
+		Data:    ViewerGrant{},
+	},
+	"GET /api/viewers/{viewerId}/learners": {
+		Summary: "List a viewer's grants",
+		Data:    []ViewerGrant{},
+	},
+	"GET /api/viewers/{viewerId}/learners/{learnerId}/progress": {
+		Summary: "Read a learner's progress (active grant required)",
+		Data:    LearnerProgressReport{},
+	},
+	"DELETE /api/viewers/{viewerId}/grants/{grantId}": {
+		Summary: "Viewer drops their access",
+		Data:    ViewerGrant{},
+	},
+	"PUT /api/viewers/{viewerId}/grants/{grantId}/digest": {
+		Summary: "Configure the daily/weekly email digest",
+		Request: UpdateDigestRequest{},
+		Data:    ViewerGrant{},
+	},
+	"PUT /api/admin/org": {
+		Summary: "Rename the organization or change its branding",
+		Request: UpdateOrganizationRequest{},
+		Data:    Organization{},
+	},
+	"GET /api/admin/org/members": {
+		Summary: "The organization's users, newest first (?limit=&cursor=)",
+		Query:   []string{"limit", "cursor"},
+		Data:    pageOf([]User{}),
+	},
+	"GET /api/admin/audit": {
+		Summary: "Audit log of admin and destructive actions, newest first (?action=&actor=&targetType=&targetId=&from=&to=&limit=&cursor=)",
+		Query:   []string{"action", "actor", "targetType", "targetId", "from", "to", "limit", "cursor"},
+		Data:    pageOf([]AuditEntry{}),
+	},
+	"GET /api/admin/reports/progress.csv": {
+		Summary: "Stream a CSV of every learner's progress per chapter (?courseId=&cohort=&from=&to=)",
+		Query:   []string{"courseId", "cohort", "from", "to"},
+		Content: "text/csv",
+	},
+	"GET /api/admin/events": {
+		Summary: "Server-Sent Events stream of logins, chapter completions and quiz submissions",
+		Content: "text/event-stream",
+	},
+	"POST /api/admin/courses": {
+		Summary: "Create a course (title, description, ordered chapterIds, order, accessDays)",
+		Request: SaveCourseRequest{},
+		Status:  http.StatusCreated,
+		Data:    Course{},
+	},
+	"PUT /api/admin/courses/{courseId}": {
+		Summary: "Replace a course's details and chapter list",
+		Request: SaveCourseRequest{},
+		Data:    Course{},
+	},
+	"POST /api/admin/courses/{courseId}/enrollments": {
+		Summary: "Enroll a learner in a course (accessDays overrides the course window)",
+		Request: EnrollRequest{},
+		Data:    Enrollment{},
+	},
+	"PUT /api/admin/courses/{courseId}/drip": {
+		Summary: "Set when chapters open relative to each learner's enrollment",
+		Request: UpdateCourseDripRequest{},
+		Data:    Course{},
+	},
+	"PUT /api/admin/courses/{courseId}/releases": {
+		Summary: "Set per-cohort release dates for a course's chapters",
+		Request: UpdateCourseReleasesRequest{},
+		Data:    Course{},
+	},
+	"PUT /api/admin/courses/{courseId}/enrollments/{userId}/cohort": {
+		Summary: "Put an enrolled learner in a cohort (empty to leave it)",
+		Request: UpdateCohortRequest{},
+		Data:    Enrollment{},
+	},
+	"GET /api/admin/courses/{courseId}/cohorts": {
+		Summary: "List a course's cohorts with member counts (?instructorId=)",
+		Data:    []Cohort{},
+	},
+	"POST /api/admin/courses/{courseId}/cohorts": {
+		Summary: "Create a cohort",
+		Request: SaveCohortRequest{},
+		Status:  http.StatusCreated,
+		Data:    Cohort{},
+	},
+	"PUT /api/admin/courses/{courseId}/cohorts/{cohortId}": {
+		Summary: "Update a cohort's name, dates and instructors",
+		Request: SaveCohortRequest{},
+		Data:    Cohort{},
+	},
+	"DELETE /api/admin/courses/{courseId}/cohorts/{cohortId}": {
+		Summary: "Delete a cohort (its learners stay enrolled)",
+	},
+	"GET /api/admin/courses/{courseId}/cohorts/{cohortId}/members": {
+		Summary: "List a cohort's learners",
+		Data:    []CohortMember{},
+	},
+	"POST /api/admin/courses/{courseId}/cohorts/{cohortId}/members": {
+		Summary: "Add learners to a cohort, enrolling them if needed",
+		Request: AddCohortMembersRequest{},
+		Data:    []CohortMember{},
+	},
+	"DELETE /api/admin/courses/{courseId}/cohorts/{cohortId}/members/{userId}": {
+		Summary: "Take a learner out of a cohort",
+	},
+	"GET /api/admin/courses/{courseId}/cohorts/{cohortId}/progress": {
+		Summary: "Progress report of a cohort's learners",
+		Data:    CohortReport{},
+	},
+	"GET /api/instructor/cohorts/{cohortId}/progress": {
+		Summary: "Learners × chapters progress matrix of a cohort, by the cohort's id, as JSON or CSV with ?format=csv",
+		Query:   []string{"limit", "offset", "format"},
+		Data:    ProgressMatrix{},
+	},
+	"PUT /api/admin/courses/{courseId}/enrollments/{userId}/access": {
+		Summary: "Extend access (days, expiresAt or lifetime)",
+		Request: ExtendAccessRequest{},
+		Data:    Enrollment{},
+	},
+	"DELETE /api/admin/courses/{courseId}/enrollments/{userId}": {
+		Summary: "Revoke a learner's access",
+		Data:    Enrollment{},
+	},
+	"POST /api/admin/users/import": {
+		Summary: "Import users from CSV (text/csv) or JSON, with a per-row report (?dryRun=&onDuplicate=skip|update&courseId=)",
+		Query:   []string{"dryRun", "onDuplicate", "courseId"},
+		Request: ImportUsersRequest{},
+		Data:    ImportResult{},
+	},
+	"DELETE /api/admin/users/{userId}": {
+		Summary: "Erase a user and everything stored about them",
+		Request: DeleteUserRequest{},
+		Data:    AccountDeletion{},
+	},
+	"PUT /api/admin/users/{userId}/status": {
+		Summary: "Suspend, deactivate or reactivate a user",
+		Request: UpdateUserStatusRequest{},
+		Data:    User{},
+	},
+	"PUT /api/admin/users/{userId}/role": {
+		Summary: "Make a user a learner, instructor or admin",
+		Request: UpdateUserRoleRequest{},
+		Data:    User{},
+	},
+	"GET /api/admin/analytics/chapters/{chapterId}": {
+		Summary: "A chapter's completion funnel, wrong answers per question and video drop-off points",
+		Data:    ChapterAnalytics{},
+	},
+	"GET /api/admin/webhooks": {
+		Summary: "The organization's webhooks",
+		Data:    []Webhook{},
+	},
+	"POST /api/admin/webhooks": {
+		Summary: "Register a webhook (url, secret, events)",
+		Request: CreateWebhookRequest{},
+		Status:  http.StatusCreated,
+		Data:    Webhook{},
+	},
+	"PUT /api/admin/webhooks/{webhookId}": {
+		Summary: "Change a webhook's URL, events, secret or active flag",
+		Request: UpdateWebhookRequest{},
+		Data:    Webhook{},
+	},
+	"DELETE /api/admin/webhooks/{webhookId}": {
+		Summary: "Delete a webhook and its delivery log",
+	},
+	"GET /api/admin/webhooks/{webhookId}/deliveries": {
+		Summary: "A webhook's deliveries and their attempts, newest first (?status=&limit=&cursor=)",
+		Query:   []string{"status", "limit", "cursor"},
+		Data:    pageOf([]WebhookDelivery{}),
+	},
+	"GET /api/admin/organizations": {
+		Summary: "List organizations",
+		Data:    []Organization{},
+	},
+	"POST /api/admin/organizations": {
+		Summary: "Create an organization with its starter course and admin key",
+		Request: CreateOrganizationRequest{},
+		Status:  http.StatusCreated,
+		Data:    OrganizationWithKey{},
+	},
+	"POST /api/admin/organizations/{orgId}/admin-key": {
+		Summary: "Rotate an organization's admin key",
+		Data:    OrganizationWithKey{},
+	},
+	"POST /api/admin/chapters": {
+		Summary: "Create a chapter with its quiz (optional courseIds to add it to)",
+		Request: SaveChapterRequest{},
+		Status:  http.StatusCreated,
+		Data:    Chapter{},
+	},
+	"POST /api/admin/chapters/import": {
+		Summary: "Upsert chapters and quizzes from a JSON or YAML file by chapterId",
+		Query:   []string{"dryRun"},
+		Request: ImportChaptersRequest{},
+		Data:    ChapterImportResult{},
+	},
+	"PUT /api/admin/chapters/{chapterId}": {
+		Summary: "Replace a chapter's content and quiz, publishing it straight away",
+		Request: SaveChapterRequest{},
+		Data:    Chapter{},
+	},
+	"GET /api/admin/chapters/{chapterId}/draft": {
+		Summary: "A chapter's unpublished draft",
+		Data:    ChapterDraft{},
+	},
+	"PUT /api/admin/chapters/{chapterId}/draft": {
+		Summary: "Save new chapter content as a draft learners don't see",
+		Request: SaveChapterRequest{},
+		Data:    ChapterDraft{},
+	},
+	"DELETE /api/admin/chapters/{chapterId}/draft": {
+		Summary: "Discard a chapter's draft",
+	},
+	"POST /api/admin/chapters/{chapterId}/publish": {
+		Summary: "Publish a chapter's draft to learners (?force=true over a newer update)",
+		Data:    Chapter{},
+	},
+	"GET /api/admin/chapters/archived": {
+		Summary: "List archived chapters, most recently archived first",
+		Data:    []Chapter{},
+	},
+	"DELETE /api/admin/chapters/{chapterId}": {
+		Summary: "Archive a chapter and remove it from its courses",
+		Data:    Chapter{},
+	},
+	"POST /api/admin/chapters/{chapterId}/restore": {
+		Summary: "Restore an archived chapter to the courses it was in",
+		Data:    Chapter{},
+	},
+	"DELETE /api/admin/chapters/{chapterId}/purge": {
+		Summary: "Permanently delete an archived chapter and its subtitles",
+	},
+	"PUT /api/admin/chapters/{chapterId}/accessibility": {
+		Summary: "Validate and publish chapter accessibility metadata",
+		Request: Accessibility{},
+		Data:    Chapter{},
+	},
+	"PUT /api/admin/chapters/{chapterId}/availability": {
+		Summary: "Set when a chapter is released to learners and withdrawn",
+		Request: ChapterAvailability{},
+		Data:    Chapter{},
+	},
+	"POST /api/admin/uploads": {
+		Summary: "Upload a question image or chapter thumbnail (multipart: kind, file)",
+		RawBody: "multipart/form-data",
+		Status:  http.StatusCreated,
+		Data:    Upload{},
+	},
+	"PUT /api/admin/chapters/{chapterId}/subtitles/{lang}": {
+		Summary: "Upload a WebVTT or SRT file as a chapter's subtitles in a language",
+		RawBody: "text/plain",
+		Data:    Subtitles{},
+	},
+	"DELETE /api/admin/chapters/{chapterId}/subtitles/{lang}": {
+		Summary: "Delete a chapter's subtitles in a language",
+	},
+	"GET /api/admin/attempts/{attemptId}/answer-changes": {
+		Summary: "Every answer change leading up to a quiz attempt",
+		Data:    AttemptAnswerChanges{},
+	},
+	"PUT /api/admin/chapters/{chapterId}/prerequisites": {
+		Summary: "Set chapter prerequisites (cycles are rejected)",
+		Request: UpdatePrerequisitesRequest{},
+		Data:    Chapter{},
+	},
+	"PUT /api/admin/chapters/{chapterId}/skills": {
+		Summary: "Tag a chapter and its questions with skills",
+		Request: TagChapterSkillsRequest{},
+		Data:    Chapter{},
+	},
+	"POST /api/admin/skills": {
+		Summary: "Add a skill to the taxonomy",
+		Request: CreateSkillRequest{},
+		Status:  http.StatusCreated,
+		Data:    Skill{},
+	},
+	"GET /api/admin/question-banks/{bankId}/questions": {
+		Summary: "List a question bank's questions (?topic=, ?difficulty=, ?retired=true)",
+		Query:   []string{"topic", "difficulty", "retired"},
+		Data:    []BankQuestion{},
+	},
+	"POST /api/admin/question-banks/{bankId}/questions": {
+		Summary: "Add a question to a bank",
+		Request: SaveBankQuestionRequest{},
+		Status:  http.StatusCreated,
+		Data:    BankQuestion{},
+	},
+	"PUT /api/admin/question-banks/{bankId}/questions/{questionId}": {
+		Summary: "Update a bank question",
+		Request: SaveBankQuestionRequest{},
+		Data:    BankQuestion{},
+	},
+	"DELETE /api/admin/question-banks/{bankId}/questions/{questionId}": {
+		Summary: "Retire a bank question so it is no longer drawn",
+	},
+	"POST /api/admin/paths": {
+		Summary: "Create a curated learning path",
+		Request: SavePathRequest{},
+		Status:  http.StatusCreated,
+		Data:    LearningPath{},
+	},
+	"PUT /api/admin/paths/{pathId}": {
+		Summary: "Update a learning path",
+		Request: SavePathRequest{},
+		Data:    LearningPath{},
+	},
+	"DELETE /api/admin/paths/{pathId}": {
+		Summary: "Delete a learning path",
+	},
+	"GET /api/admin/moderation": {
+		Summary: "Moderation queue (?status=pending)",
+		Query:   []string{"status"},
+		Data:    []Comment{},
+	},
+	"POST /api/admin/moderation/bulk": {
+		Summary: "Approve or remove comments in bulk",
+		Request: BulkModerationRequest{},
+		Data:    map[string]int64{},
+	},
+	"GET /api/admin/analytics": {
+		Summary: "Daily device, session and retention rollups (?from=&to=)",
+		Query:   []string{"from", "to"},
+		Data:    []AnalyticsRollup{},
+	},
+	"POST /api/admin/analytics/rollup": {
+		Summary: "Recompute one day's rollup now (?date=)",
+		Query:   []string{"date"},
+		Data:    AnalyticsRollup{},
+	},
+	"POST /api/admin/bulk-delete/chapter-progress": {
+		Summary: "Delete all progress on a chapter (?dryRun=true first)",
+		Query:   []string{"dryRun"},
+		Request: BulkDeleteChapterProgressRequest{},
+		Data:    BulkDeleteResult{},
+	},
+	"POST /api/admin/bulk-delete/users": {
+		Summary: "Delete a cohort of users and their data (?dryRun=true first)",
+		Query:   []string{"dryRun"},
+		Request: BulkDeleteUsersRequest{},
+		Data:    BulkDeleteResult{},
+	},
+	"POST /api/admin/repair/progress": {
+		Summary: "Recompute derived progress fields (?dryRun=true to preview)",
+		Query:   []string{"dryRun"},
+		Request: RepairRequest{},
+		Data:    RepairReport{},
+	},
+}
+
+// openAPIDocument is the encoded document, built once the routes are
+// registered
+var openAPIDocument []byte
+
+// ============================================================================
+// OPENAPI HANDLERS
+// ============================================================================
+
+// GetOpenAPIDocument serves the OpenAPI document
+func GetOpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// GetAPIDocs serves Swagger UI, loaded from a CDN, showing the OpenAPI
+// document
+func GetAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Resume Learning API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// ============================================================================
+// OPENAPI HELPERS
+// ============================================================================
+
+// pathParamPattern matches a path template's variables, with any pattern
+var pathParamPattern = regexp.MustCompile(`\{(\w+)(?::[^}]*)?\}`)
+
+// buildOpenAPIDocument describes every route registered on router and keeps
+// the result for GetOpenAPIDocument
+func buildOpenAPIDocument(router *mux.Router) error {
+	schemas := &schemaBuilder{components: map[string]interface{}{}}
+	paths := map[string]map[string]interface{}{}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // a subrouter
+		}
+		path := pathParamPattern.ReplaceAllString(template, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		for _, method := range methods {
+			paths[path][strings.ToLower(method)] = schemas.operation(method, template, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Resume Learning API",
+			"version": "1.0",
+			"description": "Every JSON response is an envelope: success, message and, on success, data. " +
+				"Learner routes take an access token from POST /api/login as a bearer token; " +
+				"admin routes take an admin key in X-Admin-Key. X-Org-ID picks the organization.",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "The request failed; code says why for programs, message for people",
+					"content":     jsonContent(schemaRef("ApiResponse")),
+				},
+				"ValidationError": map[string]interface{}{
+					"description": "The request is invalid; errors lists each invalid field",
+					"content":     jsonContent(schemaRef("ApiResponse")),
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"adminKey":   map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-Admin-Key"},
+			},
+		},
+	}
+	schemas.schema(reflect.TypeOf(ApiResponse{}))
+
+	openAPIDocument, err = json.MarshalIndent(document, "", "  ")
+	return err
+}
+
+// schemaBuilder generates JSON schemas from Go types, collecting named
+// structs as components
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+// operation describes one method of a route
+func (b *schemaBuilder) operation(method, template, path string) map[string]interface{} {
+	doc := routeDocs[method+" "+template]
+	op := map[string]interface{}{
+		"operationId": strings.ToLower(method) + operationName(path),
+		"tags":        []string{routeTag(path)},
+	}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+
+	switch {
+	case publicRoutes[template]:
+		op["security"] = []interface{}{}
+	case isAdminTemplate(template):
+		// Staff may use their token instead, where their role allows it
+		op["security"] = []interface{}{
+			map[string]interface{}{"adminKey": []string{}},
+			map[string]interface{}{"bearerAuth": []string{}},
+		}
+	default:
+		op["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+
+	params := []interface{}{}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(template, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true,
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, name := range doc.Query {
+		params = append(params, map[string]interface{}{
+			"name": name, "in": "query",
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch {
+	case doc.RawBody == "multipart/form-data":
+		// The upload form, the only multipart body
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				doc.RawBody: map[string]interface{}{"schema": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"kind", "file"},
+					"properties": map[string]interface{}{
+						"kind": map[string]interface{}{"type": "string", "enum": []string{UploadAvatar, UploadQuestionImage, UploadChapterThumbnail}},
+						"file": map[string]interface{}{"type": "string", "format": "binary"},
+					},
+				}},
+			},
+		}
+	case doc.RawBody != "":
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				doc.RawBody: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+		}
+	case doc.Request != nil:
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(b.schema(reflect.TypeOf(doc.Request))),
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case doc.Content != "":
+		success["content"] = map[string]interface{}{doc.Content: map[string]interface{}{}}
+	case status == http.StatusSwitchingProtocols:
+	case doc.Data != nil:
+		success["content"] = jsonContent(map[string]interface{}{
+			"allOf": []interface{}{
+				schemaRef("ApiResponse"),
+				map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"data": b.data(doc.Data)},
+				},
+			},
+		})
+	default:
+		success["content"] = jsonContent(schemaRef("ApiResponse"))
+	}
+	responses := map[string]interface{}{
+		fmt.Sprint(status): success,
+		"default":          map[string]interface{}{"$ref": "#/components/responses/Error"},
+	}
+	if doc.Request != nil || doc.RawBody != "" || len(doc.Query) > 0 {
+		responses["400"] = map[string]interface{}{"$ref": "#/components/responses/ValidationError"}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// data is the schema of a response's data
+func (b *schemaBuilder) data(v interface{}) map[string]interface{} {
+	if page, ok := v.(pagedData); ok {
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"items":      b.schema(reflect.TypeOf(page.Items)),
+				"nextCursor": map[string]interface{}{"type": "string", "description": "Pass as ?cursor= for the next page; unset on the last page"},
+			},
+		}
+	}
+	return b.schema(reflect.TypeOf(v))
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	answerType   = reflect.TypeOf(Answer{})
+)
+
+// schema is the JSON schema of values of type t as encoding/json writes them
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return map[string]interface{}{"type": "string"}
+	case answerType:
+		// See Answer.MarshalJSON
+		return map[string]interface{}{
+			"description": "An option index, an array of option indexes for multi-select, or the text of a fill-in answer",
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "integer"},
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+				map[string]interface{}{"type": "string"},
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := b.schema(t.Elem())
+		if _, ok := s["$ref"]; ok {
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			b.components[t.Name()] = map[string]interface{}{} // placeholder while recursing
+			b.components[t.Name()] = b.object(t)
+		}
+		return schemaRef(t.Name())
+	}
+	return map[string]interface{}{} // interface{}: any value
+}
+
+// object is the schema of a struct's JSON object
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	b.addFields(properties, t)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// addFields adds the JSON properties of a struct's fields, including those
+// of embedded structs
+func (b *schemaBuilder) addFields(properties map[string]interface{}, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				b.addFields(properties, embedded)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if _, ok := properties[name]; !ok { // outer fields hide embedded ones
+			properties[name] = b.schema(field.Type)
+		}
+	}
+}
+
+// schemaRef points to a component schema
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonContent is the content of a JSON body with the given schema
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// routeTag groups a route in the docs by its first path segment after /api
+// or /api/admin. The probes outside /api go with the health checks.
+func routeTag(path string) string {
+	if path == "/livez" || path == "/readyz" {
+		return "health"
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	if segments[0] == "admin" && len(segments) > 1 {
+		return "admin " + segments[1]
+	}
+	return segments[0]
+}
+
+// operationName turns a path into a camel-case name, e.g.
+// /api/chapters/{chapterId} into ChaptersByChapterId
+func operationName(path string) string {
+	var name strings.Builder
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api"), "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, "{") {
+			name.WriteString("By")
+			segment = strings.Trim(segment, "{}")
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '.' || r == '_' }) {
+			name.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return name.String()
+}
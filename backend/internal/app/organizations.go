@@ -0,0 +1,655 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ORGANIZATION MODELS
+// ============================================================================
+
+// Every user, course, enrollment and progress record belongs to an
+// organization. Learners pick theirs with the X-Org-ID header at login (a
+// white-label app sends its own) and their access token carries it from then
+// on, org admins are bound to theirs by their admin key, and requests without
+// any of these belong to the default organization.
+
+// defaultOrgID owns everything stored before organizations existed
+const defaultOrgID = "default"
+
+// Error codes for organization access
+const (
+	ErrCodeUnknownOrganization = "unknown_organization"
+	ErrCodeWrongOrganization   = "wrong_organization"
+)
+
+// orgIDPattern matches the slugs used as organization IDs
+var orgIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,38}[a-z0-9]$`)
+
+// colorPattern matches a #rrggbb color
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Organization is a tenant: a school, company or white-label app
+type Organization struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID     string             `bson:"public_id,omitempty" json:"id"`
+	OrgID        string             `bson:"org_id" json:"orgId"`
+	Name         string             `bson:"name" json:"name"`
+	Branding     OrgBranding        `bson:"branding" json:"branding"`
+	AdminKeyHash string             `bson:"admin_key_hash,omitempty" json:"-"` // SHA-256 of the org admin key
+	CreatedAt    time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// OrgBranding is what a white-label client shows instead of the defaults
+type OrgBranding struct {
+	AppName      string `bson:"app_name,omitempty" json:"appName,omitempty"`
+	LogoURL      string `bson:"logo_url,omitempty" json:"logoUrl,omitempty"`
+	PrimaryColor string `bson:"primary_color,omitempty" json:"primaryColor,omitempty"` // #rrggbb
+	SupportEmail string `bson:"support_email,omitempty" json:"supportEmail,omitempty"`
+}
+
+type CreateOrganizationRequest struct {
+	OrgID    string      `json:"orgId"`
+	Name     string      `json:"name"`
+	Branding OrgBranding `json:"branding"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name     *string      `json:"name"`
+	Branding *OrgBranding `json:"branding"`
+}
+
+// OrganizationWithKey is returned when an admin key is issued; the key is
+// never shown again
+type OrganizationWithKey struct {
+	Organization
+	AdminKey string `json:"adminKey"`
+}
+
+// ============================================================================
+// TENANT CONTEXT
+// ============================================================================
+
+type tenantContextKey struct{}
+
+// tenant is who a request acts for
+type tenant struct {
+	orgID      string // empty for a platform admin acting across organizations
+	admin      bool   // authenticated with an admin key, or an admin's token
+	superAdmin bool   // with ADMIN_API_KEY rather than an org admin key
+	role       string // the token's role; empty with an admin key
+	cli        bool   // the admin command, with the platform admin's access
+}
+
+// requestTenant returns the tenant TenantMiddleware attached to ctx.
+// Contexts that didn't come from a request (background jobs) have none.
+func requestTenant(ctx context.Context) (tenant, bool) {
+	t, ok := ctx.Value(tenantContextKey{}).(tenant)
+	return t, ok
+}
+
+// orgID returns the organization a request acts for, or the default
+// organization where there is none
+func orgID(ctx context.Context) string {
+	if t, ok := requestTenant(ctx); ok && t.orgID != "" {
+		return t.orgID
+	}
+	return defaultOrgID
+}
+
+// tenantFilter scopes a query on a tenant-owned collection to the request's
+// organization. Background jobs and platform admins without X-Org-ID see
+// every organization.
+func tenantFilter(ctx context.Context, filter bson.M) bson.M {
+	if t, ok := requestTenant(ctx); ok && t.orgID != "" {
+		filter["org_id"] = t.orgID
+	}
+	return filter
+}
+
+// tenantOrgFilter is the organization tenantFilter scopes to, "" for none
+func tenantOrgFilter(ctx context.Context) string {
+	org, _ := tenantFilter(ctx, bson.M{})["org_id"].(string)
+	return org
+}
+
+// TenantMiddleware works out which organization a request acts for. An
+// access token or an org admin key fixes it to their organization; otherwise
+// it comes from the X-Org-ID header, defaulting to the default organization
+// for learners and to every organization for platform admins.
+func TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		requested := strings.TrimSpace(r.Header.Get("X-Org-ID"))
+
+		t := tenant{orgID: requested}
+		if claims, ok := authClaims(ctx); ok {
+			if requested != "" && requested != claims.OrgID {
+				sendErrorCode(w, http.StatusForbidden, ErrCodeWrongOrganization, "This access token belongs to another organization")
+				return
+			}
+			t.orgID, t.role = claims.OrgID, claims.Role
+			t.admin = claims.Role == RoleAdmin
+		} else if key := adminKey(r); key != "" {
+			if superKey := config.AdminAPIKey; superKey != "" &&
+				subtle.ConstantTimeCompare([]byte(key), []byte(superKey)) == 1 {
+				t.admin, t.superAdmin = true, true
+			} else {
+				org, err := findOrganizationByAdminKey(ctx, key)
+				if err != nil && err != mongo.ErrNoDocuments {
+					log.Printf("❌ Error checking org admin key: %v", err)
+					sendError(w, http.StatusInternalServerError, "Database error")
+					return
+				}
+				if org != nil {
+					if requested != "" && requested != org.OrgID {
+						sendErrorCode(w, http.StatusForbidden, ErrCodeWrongOrganization, "This admin key belongs to another organization")
+						return
+					}
+					t.orgID, t.admin = org.OrgID, true
+				}
+				// An invalid key is left for requireAdmin to reject
+			}
+		}
+		if t.orgID == "" && !t.superAdmin {
+			t.orgID = defaultOrgID
+		}
+
+		if requested != "" && requested != defaultOrgID {
+			exists, err := organizationExists(ctx, requested)
+			if err != nil {
+				sendError(w, http.StatusInternalServerError, "Database error")
+				return
+			} else if !exists {
+				sendErrorCode(w, http.StatusNotFound, ErrCodeUnknownOrganization, "Organization not found")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, tenantContextKey{}, t)))
+	})
+}
+
+// organizationExists reports whether an organization other than the default
+// one exists. Without MongoDB none does.
+func organizationExists(ctx context.Context, orgID string) (bool, error) {
+	if !mongoConnected() {
+		return false, nil
+	}
+	count, err := organizationsCol.CountDocuments(ctx, bson.M{"org_id": orgID})
+	return count > 0, err
+}
+
+// checkUserInOrg sends a 404 and returns false when the user exists in
+// another organization. Users are never visible across organizations.
+func checkUserInOrg(ctx context.Context, w http.ResponseWriter, user User) bool {
+	t, ok := requestTenant(ctx)
+	if !ok || t.orgID == "" || userOrgID(user) == t.orgID {
+		return true
+	}
+	sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+	return false
+}
+
+// userOrgID treats users stored before organizations existed as members of
+// the default organization
+func userOrgID(user User) string {
+	if user.OrgID == "" {
+		return defaultOrgID
+	}
+	return user.OrgID
+}
+
+// ============================================================================
+// ORGANIZATION HANDLERS
+// ============================================================================
+
+// GetCurrentOrganization returns the requesting app's organization and
+// branding. It is public so white-label clients can theme the login screen.
+func GetCurrentOrganization(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	org, err := findOrganization(ctx, orgID(ctx))
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUnknownOrganization, "Organization not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Organization fetched successfully",
+		Data:    org,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateCurrentOrganization lets an org admin rename their organization and
+// change its branding
+func UpdateCurrentOrganization(w http.ResponseWriter, r *http.Request) {
+	var req UpdateOrganizationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	now := time.Now()
+	set := bson.M{"updated_at": now}
+	if req.Name != nil {
+		errs.required("name", strings.TrimSpace(*req.Name))
+		set["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Branding != nil {
+		validateBranding(&errs, *req.Branding)
+		set["branding"] = *req.Branding
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+	if t, _ := requestTenant(ctx); t.orgID == "" {
+		sendError(w, http.StatusBadRequest, "X-Org-ID is required")
+		return
+	}
+
+	var before Organization
+	err := organizationsCol.FindOneAndUpdate(ctx, bson.M{"org_id": orgID(ctx)}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUnknownOrganization, "Organization not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update organization")
+		return
+	}
+
+	org := before
+	org.UpdatedAt = now
+	if req.Name != nil {
+		org.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Branding != nil {
+		org.Branding = *req.Branding
+	}
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditOrganizationUpdated,
+		Target:  AuditTarget{Type: "organization", ID: org.OrgID},
+		Changes: auditDiff(before, org),
+	})
+
+	log.Printf("✅ Organization updated: %s", org.OrgID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Organization updated successfully",
+		Data:    org,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetOrganizationMembers lists the organization's users, newest first,
+// paginated with ?limit= and ?cursor=
+func GetOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	var errs fieldErrors
+	limit := parsePageLimit(r, &errs)
+
+	ctx := r.Context()
+	filter := tenantFilter(ctx, bson.M{})
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		cursor, err := decodeTimeCursor(token)
+		if err != nil {
+			errs.add("cursor", CodeInvalid, "is not a valid cursor")
+		} else {
+			filter = bson.M{"$and": bson.A{filter, cursor.after("created_at")}}
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	// Fetch one extra to know whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1)).
+		SetProjection(bson.M{"recent_logins": 0})
+	cursor, err := usersCol.Find(ctx, filter, opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch members")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	users := []User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode members")
+		return
+	}
+
+	page := Page{}
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[limit-1]
+		page.NextCursor = timeCursor{At: last.CreatedAt, ID: last.ID}.encode()
+	}
+	page.Items = users
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Members fetched successfully",
+		Data:    page,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateOrganization creates an organization with its starter course and
+// returns its admin key
+func CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrganizationRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	req.OrgID = strings.ToLower(strings.TrimSpace(req.OrgID))
+	req.Name = strings.TrimSpace(req.Name)
+	errs.required("orgId", req.OrgID)
+	if req.OrgID != "" && !orgIDPattern.MatchString(req.OrgID) {
+		errs.add("orgId", CodeInvalid, "must be 3-40 lowercase letters, digits or dashes")
+	}
+	errs.required("name", req.Name)
+	validateBranding(&errs, req.Branding)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	key, hash := newOrgAdminKey()
+	org := Organization{
+		PublicID:     newPublicID(),
+		OrgID:        req.OrgID,
+		Name:         req.Name,
+		Branding:     req.Branding,
+		AdminKeyHash: hash,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if _, err := organizationsCol.InsertOne(ctx, org); mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "Organization already exists")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create organization")
+		return
+	}
+
+	if err := ensureDefaultCourse(ctx, org.OrgID); err != nil {
+		log.Printf("❌ Error creating starter course for %s: %v", org.OrgID, err)
+	}
+
+	recordAudit(ctx, AuditEntry{
+		OrgID:   org.OrgID,
+		Action:  AuditOrganizationCreated,
+		Target:  AuditTarget{Type: "organization", ID: org.OrgID},
+		Changes: auditDiff(nil, org),
+	})
+	log.Printf("✅ Organization created: %s", org.OrgID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Organization created successfully",
+		Data:    OrganizationWithKey{Organization: org, AdminKey: key},
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// GetOrganizations lists every organization
+func GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cursor, err := organizationsCol.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "org_id", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch organizations")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	orgs := []Organization{}
+	if err := cursor.All(ctx, &orgs); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode organizations")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Organizations fetched successfully",
+		Data:    orgs,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RotateOrganizationAdminKey issues a new admin key; the old one stops
+// working immediately
+func RotateOrganizationAdminKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["orgId"]
+
+	ctx := r.Context()
+
+	key, hash := newOrgAdminKey()
+	var org Organization
+	err := organizationsCol.FindOneAndUpdate(ctx, bson.M{"org_id": id},
+		bson.M{"$set": bson.M{"admin_key_hash": hash, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&org)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUnknownOrganization, "Organization not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to rotate admin key")
+		return
+	}
+
+	recordAudit(ctx, AuditEntry{
+		OrgID:   org.OrgID,
+		Action:  AuditAdminKeyRotated,
+		Target:  AuditTarget{Type: "organization", ID: org.OrgID},
+		Changes: []FieldChange{{Field: "admin_key_hash", From: auditRedacted, To: auditRedacted}},
+	})
+	log.Printf("✅ Admin key rotated for organization %s", org.OrgID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Admin key rotated successfully",
+		Data:    OrganizationWithKey{Organization: org, AdminKey: key},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// ORGANIZATION HELPERS
+// ============================================================================
+
+func validateBranding(errs *fieldErrors, b OrgBranding) {
+	if b.PrimaryColor != "" && !colorPattern.MatchString(b.PrimaryColor) {
+		errs.add("branding.primaryColor", CodeInvalid, "must be a #rrggbb color")
+	}
+	if b.LogoURL != "" && !strings.HasPrefix(b.LogoURL, "https://") {
+		errs.add("branding.logoUrl", CodeInvalid, "must be an https URL")
+	}
+	if b.SupportEmail != "" && !strings.Contains(b.SupportEmail, "@") {
+		errs.add("branding.supportEmail", CodeInvalid, "must be an email address")
+	}
+}
+
+// findOrganization loads an organization by org_id
+func findOrganization(ctx context.Context, id string) (*Organization, error) {
+	var org Organization
+	if err := organizationsCol.FindOne(ctx, bson.M{"org_id": id}).Decode(&org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// findOrganizationByAdminKey returns the organization an org admin key
+// belongs to, or nil if it belongs to none
+func findOrganizationByAdminKey(ctx context.Context, key string) (*Organization, error) {
+	// Without MongoDB only the default organization exists, and it has no key
+	if !mongoConnected() {
+		return nil, nil
+	}
+
+	var org Organization
+	err := organizationsCol.FindOne(ctx, bson.M{"admin_key_hash": hashOrgAdminKey(key)}).Decode(&org)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// newOrgAdminKey returns a random admin key and the hash that is stored
+func newOrgAdminKey() (key, hash string) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	key = "org_" + base64.RawURLEncoding.EncodeToString(b[:])
+	return key, hashOrgAdminKey(key)
+}
+
+func hashOrgAdminKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ensureDefaultOrg creates the default organization if needed
+func ensureDefaultOrg(ctx context.Context) error {
+	_, err := organizationsCol.UpdateOne(ctx,
+		bson.M{"org_id": defaultOrgID},
+		bson.M{"$setOnInsert": bson.M{
+			"public_id":  newPublicID(),
+			"name":       "Default",
+			"branding":   OrgBranding{},
+			"created_at": time.Now(),
+			"updated_at": time.Now(),
+		}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// backfillOrgIDs assigns documents stored before organizations existed to
+// the default organization. Documents of a user-owned collection first join
+// their user's organization, since some were stored after it existed. It
+// only touches documents without one, so it is cheap to run on every start.
+func backfillOrgIDs(ctx context.Context) {
+	for _, owned := range userOwnedCollections() {
+		if err := backfillOwnerOrgIDs(ctx, owned.col, owned.userField); err != nil {
+			log.Printf("❌ Error assigning %s to their users' organizations: %v", owned.col.Name(), err)
+		}
+	}
+	for _, col := range tenantCollections() {
+		result, err := col.UpdateMany(ctx, bson.M{"org_id": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"org_id": defaultOrgID}})
+		if err != nil {
+			log.Printf("❌ Error assigning %s to the default organization: %v", col.Name(), err)
+			continue
+		}
+		if result.ModifiedCount > 0 {
+			log.Printf("✅ Assigned %d %s to the default organization", result.ModifiedCount, col.Name())
+		}
+	}
+}
+
+// tenantCollections lists the collections whose documents carry an org_id
+func tenantCollections() []*mongo.Collection {
+	return []*mongo.Collection{
+		usersCol, coursesCol, enrollmentsCol, progressCol,
+		commentsCol, reportsCol, viewerGrantsCol, pathsCol,
+		quizAttemptsCol, notesCol, activityCol, xpAwardsCol, sessionsCol,
+	}
+}
+
+// userOwnedCollection is a tenant-owned collection whose documents belong
+// to the user named in userField
+type userOwnedCollection struct {
+	col       *mongo.Collection
+	userField string
+}
+
+// userOwnedCollections lists the tenant-owned collections that were scoped
+// to organizations after organizations existed. Curated paths have no
+// owner and stay with the default organization.
+func userOwnedCollections() []userOwnedCollection {
+	return []userOwnedCollection{
+		{commentsCol, "user_id"}, {reportsCol, "user_id"}, {viewerGrantsCol, "learner_id"},
+		{pathsCol, "owner_id"}, {quizAttemptsCol, "user_id"}, {notesCol, "user_id"},
+		{activityCol, "user_id"}, {xpAwardsCol, "user_id"}, {sessionsCol, "user_id"},
+	}
+}
+
+// backfillOwnerOrgIDs gives the documents of col without an org_id the
+// organization of the user userField names, for users outside the default
+// organization
+func backfillOwnerOrgIDs(ctx context.Context, col *mongo.Collection, userField string) error {
+	unassigned := bson.M{"org_id": bson.M{"$exists": false}}
+	if err := col.FindOne(ctx, unassigned).Err(); err == mongo.ErrNoDocuments {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	orgs, err := usersCol.Distinct(ctx, "org_id", bson.M{"org_id": bson.M{"$nin": bson.A{defaultOrgID, nil}}})
+	if err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		userIDs, err := usersCol.Distinct(ctx, "user_id", bson.M{"org_id": org})
+		if err != nil {
+			return err
+		}
+		result, err := col.UpdateMany(ctx,
+			bson.M{userField: bson.M{"$in": userIDs}, "org_id": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"org_id": org}})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount > 0 {
+			log.Printf("✅ Assigned %d %s to organization %v", result.ModifiedCount, col.Name(), org)
+		}
+	}
+	return nil
+}
+
+// seedDefaultOrg is run at startup before the course seed
+func seedDefaultOrg() {
+	ctx := context.Background()
+	if err := ensureDefaultOrg(ctx); err != nil {
+		log.Printf("❌ Error seeding default organization: %v", err)
+		return
+	}
+	backfillOrgIDs(ctx)
+}
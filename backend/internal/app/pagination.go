@@ -0,0 +1,188 @@
+package app
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ============================================================================
+// CURSOR PAGINATION
+// ============================================================================
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// Page is the response body of a cursor-paginated listing
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+}
+
+// timeCursor is a position in a listing sorted by (time desc, _id desc).
+// The _id breaks ties between documents sharing a timestamp.
+type timeCursor struct {
+	At time.Time
+	ID primitive.ObjectID
+}
+
+// encode renders the cursor as an opaque URL-safe token
+func (c timeCursor) encode() string {
+	raw := strconv.FormatInt(c.At.UnixNano(), 10) + ":" + c.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeTimeCursor parses a token produced by timeCursor.encode
+func decodeTimeCursor(token string) (timeCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return timeCursor{}, fmt.Errorf("malformed cursor")
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return timeCursor{}, fmt.Errorf("malformed cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return timeCursor{}, fmt.Errorf("malformed cursor")
+	}
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return timeCursor{}, fmt.Errorf("malformed cursor")
+	}
+	return timeCursor{At: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// after returns the filter selecting documents that sort after the cursor
+// in (field desc, _id desc) order
+func (c timeCursor) after(field string) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$lt": c.At}},
+		bson.M{field: c.At, "_id": bson.M{"$lt": c.ID}},
+	}}
+}
+
+// parsePageLimit reads ?limit=, clamped to maxPageLimit
+func parsePageLimit(r *http.Request, errs *fieldErrors) int {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultPageLimit
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 1 {
+		errs.add("limit", CodeInvalid, "must be a positive integer")
+		return defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return limit
+}
+
+// ============================================================================
+// OFFSET PAGINATION
+// ============================================================================
+
+// Short, ordered listings (chapters, progress) page with ?limit= and
+// ?offset= instead of a cursor, so clients can jump to a page. Without
+// ?limit= they return everything, as they always have. The total goes in the
+// envelope's meta and in the X-Total-Count header.
+
+// PageMeta describes the page of an offset-paginated listing
+type PageMeta struct {
+	Total  int64 `json:"total"`           // matching items across all pages
+	Offset int   `json:"offset"`          // items skipped
+	Limit  int   `json:"limit,omitempty"` // page size, unset when everything was returned
+}
+
+// offsetPage is a requested ?limit= and ?offset=. A zero limit means no limit.
+type offsetPage struct {
+	Limit  int
+	Offset int
+}
+
+// parseOffsetPage reads ?limit= (optional, clamped to maxPageLimit) and
+// ?offset=
+func parseOffsetPage(r *http.Request, errs *fieldErrors) offsetPage {
+	var page offsetPage
+	query := r.URL.Query()
+	if query.Get("limit") != "" {
+		page.Limit = parsePageLimit(r, errs)
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			errs.add("offset", CodeInvalid, "must be a non-negative integer")
+		} else {
+			page.Offset = offset
+		}
+	}
+	return page
+}
+
+// bounds returns the slice of n items the page covers
+func (p offsetPage) bounds(n int) (start, end int) {
+	start = p.Offset
+	if start > n {
+		start = n
+	}
+	end = n
+	if p.Limit > 0 && start+p.Limit < n {
+		end = start + p.Limit
+	}
+	return start, end
+}
+
+// meta describes the page given the total number of matching items
+func (p offsetPage) meta(total int64) *PageMeta {
+	return &PageMeta{Total: total, Offset: p.Offset, Limit: p.Limit}
+}
+
+// parseSort reads ?sort= as comma-separated fields, each optionally prefixed
+// with "-" for descending. fields maps the names clients may sort by to
+// document fields. Ties fall back to def.
+func parseSort(r *http.Request, errs *fieldErrors, fields map[string]string, def bson.D) bson.D {
+	v := r.URL.Query().Get("sort")
+	if v == "" {
+		return def
+	}
+
+	order := bson.D{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		direction := 1
+		if strings.HasPrefix(name, "-") {
+			name, direction = name[1:], -1
+		}
+		field, ok := fields[name]
+		if !ok {
+			names := make([]string, 0, len(fields))
+			for n := range fields {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			errs.add("sort", CodeUnknownValue, "must be one of "+strings.Join(names, ", "))
+			return def
+		}
+		if !seen[field] {
+			order = append(order, bson.E{Key: field, Value: direction})
+			seen[field] = true
+		}
+	}
+	for _, e := range def {
+		if !seen[e.Key] {
+			order = append(order, e)
+		}
+	}
+	return order
+}
@@ -0,0 +1,581 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// LEARNING PATH MODELS
+// ============================================================================
+
+// Path visibility
+const (
+	PathPublic  = "public"
+	PathPrivate = "private"
+)
+
+// LearningPath is an ordered sequence of chapters. Admin-curated paths have
+// no owner; user-built paths are owned by their creator. Either kind belongs
+// to an organization.
+type LearningPath struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID      string             `bson:"public_id,omitempty" json:"id"`
+	OrgID         string             `bson:"org_id,omitempty" json:"-"`
+	PathID        string             `bson:"path_id" json:"pathId"`
+	Title         string             `bson:"title" json:"title"`
+	Description   string             `bson:"description" json:"description"`
+	ChapterIDs    []string           `bson:"chapter_ids" json:"chapterIds"`
+	Prerequisites []string           `bson:"prerequisites" json:"prerequisites"` // path IDs to complete first
+	OwnerID       string             `bson:"owner_id,omitempty" json:"ownerId,omitempty"`
+	Visibility    string             `bson:"visibility" json:"visibility"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// PathEnrollment records that a user started (and possibly finished) a path
+type PathEnrollment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID    string             `bson:"public_id,omitempty" json:"id"`
+	UserID      string             `bson:"user_id" json:"userId"`
+	PathID      string             `bson:"path_id" json:"pathId"`
+	StartedAt   time.Time          `bson:"started_at" json:"startedAt"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completedAt,omitempty"`
+}
+
+type SavePathRequest struct {
+	UserID        string   `json:"userId"` // user-built paths only
+	PathID        string   `json:"pathId"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	ChapterIDs    []string `json:"chapterIds"`
+	Prerequisites []string `json:"prerequisites"`
+	Visibility    string   `json:"visibility"`
+}
+
+type StartPathRequest struct {
+	UserID string `json:"userId"`
+}
+
+// PathChapterStatus is one step of a path as seen by a learner
+type PathChapterStatus struct {
+	ChapterID string `json:"chapterId"`
+	Title     string `json:"title"`
+	Completed bool   `json:"completed"`
+}
+
+// PathProgress is a learner's progress through a path
+type PathProgress struct {
+	PathID          string              `json:"pathId"`
+	UserID          string              `json:"userId"`
+	Started         bool                `json:"started"`
+	StartedAt       *time.Time          `json:"startedAt,omitempty"`
+	CompletedAt     *time.Time          `json:"completedAt,omitempty"`
+	PercentComplete int                 `json:"percentComplete"`
+	NextChapterID   string              `json:"nextChapterId,omitempty"`
+	Chapters        []PathChapterStatus `json:"chapters"`
+}
+
+// userPathsEnabled reports whether learners may build their own paths
+func userPathsEnabled() bool {
+	return config.UserPathsEnabled
+}
+
+// ============================================================================
+// PATH CATALOG HANDLERS
+// ============================================================================
+
+// GetPaths returns the path catalog: public paths plus, with ?userId=, the
+// user's own private paths
+func GetPaths(w http.ResponseWriter, r *http.Request) {
+	filter := bson.M{"visibility": PathPublic}
+	if userID := r.URL.Query().Get("userId"); userID != "" {
+		filter = bson.M{"$or": bson.A{
+			bson.M{"visibility": PathPublic},
+			bson.M{"owner_id": userID},
+		}}
+	}
+
+	ctx := r.Context()
+
+	cursor, err := pathsCol.Find(ctx, tenantFilter(ctx, filter), options.Find().SetSort(bson.D{{Key: "title", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch paths")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	paths := []LearningPath{}
+	if err := cursor.All(ctx, &paths); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode paths")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Paths fetched successfully",
+		Data:    paths,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetPathByID returns a single path
+func GetPathByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pathID := vars["pathId"]
+
+	ctx := r.Context()
+
+	var path LearningPath
+	err := pathsCol.FindOne(ctx, tenantFilter(ctx, bson.M{"path_id": pathID})).Decode(&path)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Path not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Path fetched successfully",
+		Data:    path,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateUserPath lets a learner build their own path when enabled
+func CreateUserPath(w http.ResponseWriter, r *http.Request) {
+	if !userPathsEnabled() {
+		sendError(w, http.StatusForbidden, "User-created paths are disabled")
+		return
+	}
+
+	var req SavePathRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+	if req.UserID == "" {
+		sendError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	// User paths get generated IDs so they can't squat curated slugs
+	req.PathID = "user_" + primitive.NewObjectID().Hex()
+	createPath(r.Context(), w, req, req.UserID)
+}
+
+// ============================================================================
+// ADMIN PATH HANDLERS
+// ============================================================================
+
+// AdminCreatePath creates a curated path
+func AdminCreatePath(w http.ResponseWriter, r *http.Request) {
+	var req SavePathRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.PathID = strings.TrimSpace(req.PathID)
+	if req.PathID == "" {
+		req.PathID = "path_" + primitive.NewObjectID().Hex()
+	}
+	createPath(r.Context(), w, req, "")
+}
+
+// AdminUpdatePath replaces a path's content
+func AdminUpdatePath(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pathID := vars["pathId"]
+
+	var req SavePathRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.PathID = pathID
+
+	ctx := r.Context()
+
+	if msg := validatePath(ctx, &req); msg != "" {
+		sendError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	var path LearningPath
+	err := pathsCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"path_id": pathID}), bson.M{"$set": bson.M{
+		"title":         req.Title,
+		"description":   req.Description,
+		"chapter_ids":   req.ChapterIDs,
+		"prerequisites": req.Prerequisites,
+		"visibility":    req.Visibility,
+		"updated_at":    time.Now(),
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&path)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Path not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update path")
+		return
+	}
+
+	log.Printf("✅ Path updated: %s", pathID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Path updated successfully",
+		Data:    path,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// AdminDeletePath removes a path and its enrollments
+func AdminDeletePath(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pathID := vars["pathId"]
+
+	ctx := r.Context()
+
+	count, err := pathsCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"prerequisites": pathID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count > 0 {
+		sendError(w, http.StatusConflict, "Path is a prerequisite of other paths")
+		return
+	}
+
+	result, err := pathsCol.DeleteOne(ctx, tenantFilter(ctx, bson.M{"path_id": pathID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to delete path")
+		return
+	}
+	if result.DeletedCount == 0 {
+		sendError(w, http.StatusNotFound, "Path not found")
+		return
+	}
+
+	if _, err := pathEnrollmentsCol.DeleteMany(ctx, bson.M{"path_id": pathID}); err != nil {
+		log.Printf("❌ Error deleting enrollments for path %s: %v", pathID, err)
+	}
+
+	log.Printf("✅ Path deleted: %s", pathID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Path deleted successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// PATH PROGRESS HANDLERS
+// ============================================================================
+
+// StartPath enrolls a user in a path once its prerequisites are complete
+func StartPath(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pathID := vars["pathId"]
+
+	var req StartPathRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+	if req.UserID == "" {
+		sendError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	ctx := r.Context()
+
+	var path LearningPath
+	err := pathsCol.FindOne(ctx, tenantFilter(ctx, bson.M{"path_id": pathID})).Decode(&path)
+	if err == mongo.ErrNoDocuments || (err == nil && path.Visibility != PathPublic && path.OwnerID != req.UserID) {
+		sendError(w, http.StatusNotFound, "Path not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if len(path.Prerequisites) > 0 {
+		completed, err := pathEnrollmentsCol.CountDocuments(ctx, bson.M{
+			"user_id":      req.UserID,
+			"path_id":      bson.M{"$in": path.Prerequisites},
+			"completed_at": bson.M{"$exists": true},
+		})
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if int(completed) < len(path.Prerequisites) {
+			sendError(w, http.StatusForbidden, "Complete the prerequisite paths first")
+			return
+		}
+	}
+
+	enrollment := PathEnrollment{
+		PublicID:  newPublicID(),
+		UserID:    req.UserID,
+		PathID:    pathID,
+		StartedAt: time.Now(),
+	}
+	err = retryOnDuplicateKey(func() error {
+		_, err := pathEnrollmentsCol.UpdateOne(ctx,
+			bson.M{"user_id": req.UserID, "path_id": pathID},
+			bson.M{"$setOnInsert": enrollment},
+			options.Update().SetUpsert(true))
+		return err
+	})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to start path")
+		return
+	}
+
+	log.Printf("✅ Path started: user=%s, path=%s", req.UserID, pathID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Path started successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetPathProgress computes a user's progress through a path from their
+// chapter progress, recording completion the first time it is reached
+func GetPathProgress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pathID := vars["pathId"]
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	var path LearningPath
+	err := pathsCol.FindOne(ctx, tenantFilter(ctx, bson.M{"path_id": pathID})).Decode(&path)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Path not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	progress, err := computePathProgress(ctx, path, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to compute path progress")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Path progress fetched successfully",
+		Data:    progress,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// PATH HELPERS
+// ============================================================================
+
+func createPath(ctx context.Context, w http.ResponseWriter, req SavePathRequest, ownerID string) {
+	if msg := validatePath(ctx, &req); msg != "" {
+		sendError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	path := LearningPath{
+		PublicID:      newPublicID(),
+		OrgID:         orgID(ctx),
+		PathID:        req.PathID,
+		Title:         req.Title,
+		Description:   req.Description,
+		ChapterIDs:    req.ChapterIDs,
+		Prerequisites: req.Prerequisites,
+		OwnerID:       ownerID,
+		Visibility:    req.Visibility,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	result, err := pathsCol.InsertOne(ctx, path)
+	if mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "A path with this ID already exists")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create path")
+		return
+	}
+	path.ID = result.InsertedID.(primitive.ObjectID)
+
+	log.Printf("✅ Path created: %s", path.PathID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Path created successfully",
+		Data:    path,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// validatePath normalizes a path request and returns a user-facing error
+// message, or "" when it is valid
+func validatePath(ctx context.Context, req *SavePathRequest) string {
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		return "Title is required"
+	}
+	if len(req.ChapterIDs) == 0 {
+		return "A path needs at least one chapter"
+	}
+	if req.Visibility == "" {
+		req.Visibility = PathPublic
+	}
+	if req.Visibility != PathPublic && req.Visibility != PathPrivate {
+		return "Visibility must be 'public' or 'private'"
+	}
+	if req.Prerequisites == nil {
+		req.Prerequisites = []string{}
+	}
+
+	seen := map[string]bool{}
+	for _, id := range req.ChapterIDs {
+		if seen[id] {
+			return "A chapter can only appear once in a path"
+		}
+		seen[id] = true
+	}
+
+	count, err := chaptersCol.CountDocuments(ctx, liveChapters(ctx, bson.M{"chapter_id": bson.M{"$in": req.ChapterIDs}}))
+	if err != nil {
+		return "Database error"
+	}
+	if int(count) != len(req.ChapterIDs) {
+		return "One or more chapters do not exist"
+	}
+
+	for _, prereq := range req.Prerequisites {
+		if prereq == req.PathID {
+			return "A path cannot be its own prerequisite"
+		}
+	}
+	if len(req.Prerequisites) > 0 {
+		count, err := pathsCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"path_id": bson.M{"$in": req.Prerequisites}}))
+		if err != nil {
+			return "Database error"
+		}
+		if int(count) != len(req.Prerequisites) {
+			return "One or more prerequisite paths do not exist"
+		}
+
+		deps, err := pathDependencies(ctx)
+		if err != nil {
+			return "Database error"
+		}
+		deps[req.PathID] = req.Prerequisites
+		if cycle := findCycle(deps); cycle != nil {
+			return "Prerequisites would create a cycle: " + strings.Join(cycle, " -> ")
+		}
+	}
+
+	return ""
+}
+
+func computePathProgress(ctx context.Context, path LearningPath, userID string) (*PathProgress, error) {
+	cursor, err := chaptersCol.Find(ctx, bson.M{"chapter_id": bson.M{"$in": path.ChapterIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var chapters []Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return nil, err
+	}
+	titles := make(map[string]string, len(chapters))
+	for _, chapter := range chapters {
+		titles[chapter.ChapterID] = chapter.Title
+	}
+
+	cursor, err = progressCol.Find(ctx, bson.M{
+		"user_id":           userID,
+		"chapter_id":        bson.M{"$in": path.ChapterIDs},
+		"chapter_completed": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var completedProgress []Progress
+	if err := cursor.All(ctx, &completedProgress); err != nil {
+		return nil, err
+	}
+	completed := make(map[string]bool, len(completedProgress))
+	for _, p := range completedProgress {
+		completed[p.ChapterID] = true
+	}
+
+	progress := &PathProgress{
+		PathID:   path.PathID,
+		UserID:   userID,
+		Chapters: make([]PathChapterStatus, 0, len(path.ChapterIDs)),
+	}
+	done := 0
+	for _, chapterID := range path.ChapterIDs {
+		if completed[chapterID] {
+			done++
+		} else if progress.NextChapterID == "" {
+			progress.NextChapterID = chapterID
+		}
+		progress.Chapters = append(progress.Chapters, PathChapterStatus{
+			ChapterID: chapterID,
+			Title:     titles[chapterID],
+			Completed: completed[chapterID],
+		})
+	}
+	if len(path.ChapterIDs) > 0 {
+		progress.PercentComplete = done * 100 / len(path.ChapterIDs)
+	}
+
+	var enrollment PathEnrollment
+	err = pathEnrollmentsCol.FindOne(ctx, bson.M{"user_id": userID, "path_id": path.PathID}).Decode(&enrollment)
+	if err == mongo.ErrNoDocuments {
+		return progress, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	progress.Started = true
+	progress.StartedAt = &enrollment.StartedAt
+	progress.CompletedAt = enrollment.CompletedAt
+
+	if enrollment.CompletedAt == nil && done == len(path.ChapterIDs) {
+		now := time.Now()
+		_, err := pathEnrollmentsCol.UpdateOne(ctx,
+			bson.M{"_id": enrollment.ID, "completed_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"completed_at": now}})
+		if err != nil {
+			return nil, err
+		}
+		progress.CompletedAt = &now
+		log.Printf("🎓 Path completed: user=%s, path=%s", userID, path.PathID)
+	}
+
+	return progress, nil
+}
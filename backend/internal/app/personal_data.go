@@ -0,0 +1,379 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ============================================================================
+// PERSONAL DATA MODELS
+// ============================================================================
+
+// Learners can take away everything stored about them, and have it erased.
+// An export is the stored documents as they are, less secrets such as
+// password and token hashes. An erasure deletes what the user owns,
+// anonymizes the answer change log, which is append-only, and strips the
+// user document down to a deactivated tombstone, so tokens already issued
+// stop working and the user ID can't be signed in to again. Each erasure
+// leaves an audit record in account_deletions.
+
+// Export formats
+const (
+	ExportFormatJSON = "json"
+	ExportFormatZip  = "zip"
+)
+
+// deletedUserPrefix starts the IDs that stand in for erased users in the
+// answer change log
+const deletedUserPrefix = "deleted-"
+
+// UserDataExport is everything stored about a user, by collection
+type UserDataExport struct {
+	UserID      string              `json:"userId"`
+	ExportedAt  time.Time           `json:"exportedAt"`
+	Collections map[string][]bson.M `json:"collections"`
+}
+
+type DeleteUserRequest struct {
+	Password string `json:"password,omitempty"` // required to erase your own password account
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AccountDeletion is the audit record of an erased account. It keeps the
+// user ID and what was erased, nothing else about the person.
+type AccountDeletion struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID    string             `bson:"public_id" json:"id"`
+	OrgID       string             `bson:"org_id" json:"-"`
+	UserID      string             `bson:"user_id" json:"userId"`
+	RequestedBy string             `bson:"requested_by" json:"requestedBy"` // the user, or "admin" for an admin key
+	Reason      string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Collections []ErasedCollection `bson:"collections" json:"collections"`
+	DeletedAt   time.Time          `bson:"deleted_at" json:"deletedAt"`
+}
+
+// ErasedCollection is one collection's share of an erasure
+type ErasedCollection struct {
+	Collection string `bson:"collection" json:"collection"`
+	Deleted    int64  `bson:"deleted" json:"deleted"`
+	Anonymized int64  `bson:"anonymized,omitempty" json:"anonymized,omitempty"`
+}
+
+// exportSource is the documents an export reads from one collection
+type exportSource struct {
+	col    *mongo.Collection
+	filter bson.M
+	omit   []string // secrets, never exported
+}
+
+// ============================================================================
+// PERSONAL DATA HANDLERS
+// ============================================================================
+
+// ExportUserData returns everything stored about a user, as JSON or, with
+// ?format=zip, as a ZIP archive with one JSON file per collection
+func ExportUserData(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportFormatJSON
+	}
+	if format != ExportFormatJSON && format != ExportFormatZip {
+		var errs fieldErrors
+		errs.add("format", CodeInvalid, "must be json or zip")
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	count, err := usersCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"user_id": userID}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	} else if count == 0 {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	}
+
+	export := UserDataExport{UserID: userID, ExportedAt: time.Now(), Collections: map[string][]bson.M{}}
+	for _, source := range exportSources(userID) {
+		docs, err := readExportSource(ctx, source)
+		if err != nil {
+			log.Printf("❌ Error exporting %s for %s: %v", source.col.Name(), userID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to export data")
+			return
+		}
+		export.Collections[source.col.Name()] = docs
+	}
+
+	log.Printf("📦 Data exported: user=%s, format=%s", userID, format)
+
+	if format == ExportFormatZip {
+		archive, err := zipExport(export)
+		if err != nil {
+			log.Printf("❌ Error archiving export for %s: %v", userID, err)
+			sendError(w, http.StatusInternalServerError, "Failed to export data")
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="export-%s.zip"`, export.ExportedAt.UTC().Format("20060102")))
+		w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(archive)
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Data exported successfully",
+		Data:    export,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// DeleteUser erases a user, at their own request or an admin's. Erasing
+// your own password account takes the password, so a stolen access token
+// alone can't.
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	// The body is optional
+	var req DeleteUserRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+	self := authUserID(ctx)
+
+	var user User
+	err := usersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"user_id": userID})).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var errs fieldErrors
+	if self == userID && user.PasswordHash != "" {
+		if req.Password == "" {
+			errs.add("password", CodeRequired, "is required")
+		} else if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+			errs.add("password", CodeInvalid, "is incorrect")
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	requestedBy := self
+	if requestedBy == "" {
+		requestedBy = "admin"
+	}
+	deletion := AccountDeletion{
+		PublicID:    newPublicID(),
+		OrgID:       userOrgID(user),
+		UserID:      userID,
+		RequestedBy: requestedBy,
+		Reason:      req.Reason,
+		DeletedAt:   time.Now(),
+	}
+	if deletion.Collections, err = eraseUser(ctx, user, deletion.DeletedAt); err != nil {
+		// Nothing is lost by a retry, which erases whatever is left
+		log.Printf("❌ Error erasing user %s: %v", userID, err)
+		sendError(w, http.StatusInternalServerError, "Failed to delete account, try again to finish")
+		return
+	}
+
+	if _, err := accountDeletionsCol.InsertOne(ctx, deletion); err != nil {
+		log.Printf("❌ Error recording deletion of %s: %v", userID, err)
+	}
+	// Only what was erased: the person's data mustn't live on in the log
+	recordAudit(ctx, AuditEntry{
+		OrgID:  deletion.OrgID,
+		Action: AuditUserDeleted,
+		Target: AuditTarget{Type: "user", ID: userID},
+		Details: bson.M{
+			"reason":      req.Reason,
+			"collections": deletion.Collections,
+		},
+	})
+	publishDomainEvent(ctx, DomainEvent{Type: DomainUserDeleted, OrgID: deletion.OrgID, UserID: userID, Data: map[string]interface{}{
+		"deletedAt": deletion.DeletedAt,
+	}})
+
+	log.Printf("🗑️ Account deleted: user=%s, by=%s", userID, requestedBy)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Account deleted successfully",
+		Data:    deletion,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// PERSONAL DATA HELPERS
+// ============================================================================
+
+// exportSources is everything an export reads. Secrets are left out, such
+// as the hashes of account tokens, though not the addresses they went to.
+func exportSources(userID string) []exportSource {
+	owned := bson.M{"user_id": userID}
+	return []exportSource{
+		{usersCol, owned, []string{"password_hash", "guest_secret_hash"}},
+		{progressCol, owned, nil},
+		{quizAttemptsCol, owned, nil},
+		{answerChangesCol, owned, nil},
+		{enrollmentsCol, owned, nil},
+		{pathEnrollmentsCol, owned, nil},
+		{pathsCol, bson.M{"owner_id": userID}, nil},
+		{profileChangesCol, owned, nil},
+		{activityCol, owned, nil},
+		{dailyActivityCol, owned, nil},
+		{xpAwardsCol, owned, nil},
+		{achievementsCol, owned, nil},
+		{certificatesCol, owned, nil},
+		{notesCol, owned, nil},
+		{sessionsCol, owned, nil},
+		{analyticsEventsCol, bson.M{"meta.user_id": userID}, nil},
+		{uploadsCol, owned, nil},
+		{authSessionsCol, owned, []string{"refresh_token_hash", "previous_token_hash"}},
+		{accountTokensCol, owned, []string{"token_hash"}},
+		{accountMergesCol, bson.M{"$or": bson.A{owned, bson.M{"guest_id": userID}}}, nil},
+		{commentsCol, owned, nil},
+		{reportsCol, owned, nil},
+		{viewerGrantsCol, bson.M{"$or": bson.A{bson.M{"learner_id": userID}, bson.M{"viewer_id": userID}}}, nil},
+	}
+}
+
+// readExportSource reads a source's documents, without their Mongo _id
+func readExportSource(ctx context.Context, source exportSource) ([]bson.M, error) {
+	projection := bson.M{"_id": 0}
+	for _, field := range source.omit {
+		projection[field] = 0
+	}
+	cursor, err := source.col.Find(ctx, source.filter, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []bson.M{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// zipExport packs an export as one JSON file per collection
+func zipExport(export UserDataExport) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	names := make([]string, 0, len(export.Collections))
+	for name := range export.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		docs := export.Collections[name]
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: name + ".json", Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(docs); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// eraseUser deletes what user owns, anonymizes their answer changes and
+// leaves a tombstone of their user document. Every step can be run again.
+func eraseUser(ctx context.Context, user User, now time.Time) ([]ErasedCollection, error) {
+	// Stored images go before the uploads that point at them
+	cursor, err := uploadsCol.Find(ctx, bson.M{"user_id": user.UserID}, options.Find().SetProjection(bson.M{"public_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var uploads []Upload
+	err = cursor.All(ctx, &uploads)
+	cursor.Close(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, upload := range uploads {
+		if mediaStore == nil {
+			break
+		}
+		if err := mediaStore.Delete(ctx, uploadKey(upload.PublicID)); err != nil {
+			return nil, fmt.Errorf("media %s: %w", upload.PublicID, err)
+		}
+	}
+
+	targets, err := userDataTargets(ctx, []interface{}{user.UserID})
+	if err != nil {
+		return nil, err
+	}
+	erased := []ErasedCollection{}
+	for _, target := range targets {
+		deleted, err := target.col.DeleteMany(ctx, target.filter)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", target.col.Name(), err)
+		}
+		erased = append(erased, ErasedCollection{Collection: target.col.Name(), Deleted: deleted.DeletedCount})
+	}
+
+	// Answer changes feed answer statistics, so they stay under an ID that
+	// leads nowhere
+	anonymized, err := answerChangesCol.UpdateMany(ctx, bson.M{"user_id": user.UserID},
+		bson.M{"$set": bson.M{"user_id": deletedUserPrefix + newPublicID()}})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", answerChangesCol.Name(), err)
+	}
+	erased = append(erased, ErasedCollection{Collection: answerChangesCol.Name(), Anonymized: anonymized.ModifiedCount})
+
+	_, err = usersCol.ReplaceOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"public_id":         user.PublicID,
+		"user_id":           user.UserID,
+		"org_id":            userOrgID(user),
+		"status":            UserDeactivated,
+		"status_reason":     "Account deleted",
+		"status_changed_at": now,
+		"deleted_at":        now,
+		"created_at":        user.CreatedAt,
+		"updated_at":        now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", usersCol.Name(), err)
+	}
+	erased = append(erased, ErasedCollection{Collection: usersCol.Name(), Anonymized: 1})
+	return erased, nil
+}
@@ -0,0 +1,405 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// PROFILE MODELS
+// ============================================================================
+
+// NotificationPreferences are the channels a user agreed to be contacted on
+type NotificationPreferences struct {
+	Email     bool `bson:"email" json:"email"`
+	Push      bool `bson:"push" json:"push"`
+	Reminders bool `bson:"reminders" json:"reminders"` // study reminders
+}
+
+// ProfileChange is an audit record of one profile update
+type ProfileChange struct {
+	PublicID  string        `bson:"public_id,omitempty" json:"id"`
+	UserID    string        `bson:"user_id" json:"userId"`
+	Changes   []FieldChange `bson:"changes" json:"changes"`
+	ChangedAt time.Time     `bson:"changed_at" json:"changedAt"`
+}
+
+// FieldChange is a single field's old and new value
+type FieldChange struct {
+	Field string      `bson:"field" json:"field"`
+	From  interface{} `bson:"from" json:"from"`
+	To    interface{} `bson:"to" json:"to"`
+}
+
+// UpdateProfileRequest is a partial update: omitted fields are left alone.
+// Sent with PUT, omitted profile fields are cleared; see replaceProfile.
+type UpdateProfileRequest struct {
+	Name           *string   `json:"name"`
+	Email          *string   `json:"email"`
+	AvatarURL      *string   `json:"avatarUrl"`
+	AvatarUploadID *string   `json:"avatarUploadId"` // an avatar from POST /api/uploads
+	Bio            *string   `json:"bio"`
+	LearningGoals  *[]string `json:"learningGoals"`
+	Locale         *string   `json:"locale"`
+	Timezone       *string   `json:"timezone"`
+	Notifications  *struct {
+		Email     *bool `json:"email"`
+		Push      *bool `json:"push"`
+		Reminders *bool `json:"reminders"`
+	} `json:"notifications"`
+	Privacy *struct {
+		PublicProfile        *bool `json:"publicProfile"`
+		ShowBadges           *bool `json:"showBadges"`
+		ShowCompletedCourses *bool `json:"showCompletedCourses"`
+		ShowCertificates     *bool `json:"showCertificates"`
+	} `json:"privacy"`
+}
+
+// boolField pairs a stored flag with its requested value, if any
+type boolField struct {
+	field   string
+	current bool
+	next    *bool
+}
+
+const (
+	maxDisplayNameLength  = 80
+	maxBioLength          = 500
+	maxLearningGoals      = 10
+	maxLearningGoalLength = 100
+)
+
+// localeTagPattern accepts BCP 47 style tags such as "en", "pt-br", "zh-hant-tw"
+var localeTagPattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// ============================================================================
+// PROFILE HANDLERS
+// ============================================================================
+
+// GetUserProfile returns the user's own profile and preferences
+func GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	var user User
+	err := usersCol.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Profile fetched successfully",
+		Data:    user,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateUserProfile applies a partial profile update and records what changed
+func UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	var req UpdateProfileRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	var user User
+	err := usersCol.FindOne(ctx, bson.M{"user_id": userID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var errs fieldErrors
+	set := bson.M{}
+	unset := bson.M{}
+	var changes []FieldChange
+
+	// change stages field for update when value differs from the stored one
+	change := func(field string, from, to interface{}) {
+		if reflect.DeepEqual(from, to) {
+			return
+		}
+		changes = append(changes, FieldChange{Field: field, From: from, To: to})
+		if clearsField(to) {
+			unset[field] = ""
+		} else {
+			set[field] = to
+		}
+	}
+
+	if r.Method == http.MethodPut {
+		if req.Name == nil {
+			errs.add("name", CodeRequired, "is required")
+		}
+		req.replaceProfile()
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			errs.add("name", CodeRequired, "must not be empty")
+		} else if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			errs.add("name", CodeOutOfRange, "must be at most 80 characters")
+		} else {
+			change("name", user.Name, name)
+		}
+	}
+	if req.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*req.Email))
+		if email != "" && !isEmailAddress(email) {
+			errs.add("email", CodeInvalid, "must be an email address")
+		} else if email == "" && user.PasswordHash != "" {
+			errs.add("email", CodeRequired, "is required for accounts with a password")
+		} else if inUse, err := emailInUse(ctx, email, userID); err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		} else if inUse {
+			errs.add("email", CodeTaken, "is already in use")
+		} else if email != user.Email {
+			// A new address has to be verified again
+			change("email", user.Email, email)
+			if user.EmailVerifiedAt != nil {
+				unset["email_verified_at"] = ""
+			}
+		}
+	}
+	if req.AvatarURL != nil {
+		avatar := strings.TrimSpace(*req.AvatarURL)
+		if avatar != "" && !isMediaURL(avatar) {
+			errs.add("avatarUrl", CodeInvalid, "must be an http(s) URL")
+		} else {
+			change("avatar_url", user.AvatarURL, avatar)
+		}
+	}
+	if req.AvatarUploadID != nil {
+		var avatar *ImageRef
+		if id := strings.TrimSpace(*req.AvatarUploadID); id != "" {
+			avatar = &ImageRef{UploadID: id}
+			if err := checkImageRef(ctx, &errs, "avatarUploadId", avatar, UploadAvatar, userID); err != nil {
+				sendError(w, http.StatusInternalServerError, "Database error")
+				return
+			}
+		}
+		change("avatar", user.Avatar, avatar)
+	}
+	if req.Bio != nil {
+		bio := strings.TrimSpace(*req.Bio)
+		if utf8.RuneCountInString(bio) > maxBioLength {
+			errs.add("bio", CodeOutOfRange, "must be at most 500 characters")
+		} else {
+			change("bio", user.Bio, bio)
+		}
+	}
+	if req.LearningGoals != nil {
+		if goals, ok := learningGoals(*req.LearningGoals, &errs); ok {
+			change("learning_goals", user.LearningGoals, goals)
+		}
+	}
+	if req.Locale != nil {
+		locale := normalizeLocale(*req.Locale)
+		if locale != "" && !localeTagPattern.MatchString(locale) {
+			errs.add("locale", CodeInvalid, "must be a language tag such as 'en' or 'pt-br'")
+		} else {
+			change("locale", user.Locale, locale)
+		}
+	}
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if _, ok := loadTimezone(timezone); timezone != "" && !ok {
+			errs.add("timezone", CodeInvalid, "must be an IANA timezone such as 'Europe/Berlin'")
+		} else {
+			change("timezone", user.Timezone, timezone)
+		}
+	}
+	if n := req.Notifications; n != nil {
+		for _, f := range []boolField{
+			{"notifications.email", user.Notifications.Email, n.Email},
+			{"notifications.push", user.Notifications.Push, n.Push},
+			{"notifications.reminders", user.Notifications.Reminders, n.Reminders},
+		} {
+			if f.next != nil {
+				change(f.field, f.current, *f.next)
+			}
+		}
+	}
+	if p := req.Privacy; p != nil {
+		if p.PublicProfile != nil && *p.PublicProfile && user.Handle == "" {
+			errs.add("privacy.publicProfile", CodeInvalid, "requires a handle, set one with PUT /privacy first")
+		}
+		for _, f := range []boolField{
+			{"privacy.public_profile", user.Privacy.PublicProfile, p.PublicProfile},
+			{"privacy.show_badges", user.Privacy.ShowBadges, p.ShowBadges},
+			{"privacy.show_completed_courses", user.Privacy.ShowCompletedCourses, p.ShowCompletedCourses},
+			{"privacy.show_certificates", user.Privacy.ShowCertificates, p.ShowCertificates},
+		} {
+			if f.next != nil {
+				change(f.field, f.current, *f.next)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	if len(changes) > 0 {
+		previousEmail := user.Email
+		set["updated_at"] = time.Now()
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		err = usersCol.FindOneAndUpdate(ctx, bson.M{"user_id": userID}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+		if mongo.IsDuplicateKeyError(err) {
+			// Another user took the email since it was checked
+			sendError(w, http.StatusConflict, "Email is already in use")
+			return
+		} else if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to update profile")
+			return
+		}
+
+		recordProfileChange(ctx, userID, changes)
+		if user.Email != "" && user.Email != previousEmail {
+			mailAccountToken(ctx, user, TokenVerifyEmail)
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Profile updated successfully",
+		Data:    user,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// PROFILE HELPERS
+// ============================================================================
+
+// replaceProfile fills in the profile fields a PUT left out as empty, so
+// they are cleared. Preferences (locale, notifications, privacy) are only
+// changed when sent.
+func (req *UpdateProfileRequest) replaceProfile() {
+	for _, field := range []**string{&req.Email, &req.AvatarURL, &req.AvatarUploadID, &req.Bio, &req.Timezone} {
+		if *field == nil {
+			empty := ""
+			*field = &empty
+		}
+	}
+	if req.LearningGoals == nil {
+		req.LearningGoals = &[]string{}
+	}
+}
+
+// clearsField reports whether a staged value empties its field, which is
+// then unset rather than stored
+func clearsField(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case []string:
+		return len(v) == 0
+	case *ImageRef:
+		return v == nil
+	}
+	return false
+}
+
+// learningGoals trims the goals and drops blank and repeated ones, adding
+// to errs when there are too many or one is too long. No goals is nil.
+func learningGoals(requested []string, errs *fieldErrors) ([]string, bool) {
+	var goals []string
+	seen := map[string]bool{}
+	ok := true
+	for i, goal := range requested {
+		goal = strings.TrimSpace(goal)
+		key := strings.ToLower(goal)
+		if goal == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if utf8.RuneCountInString(goal) > maxLearningGoalLength {
+			errs.add(fmt.Sprintf("learningGoals[%d]", i), CodeOutOfRange, "must be at most 100 characters")
+			ok = false
+		}
+		goals = append(goals, goal)
+	}
+	if len(goals) > maxLearningGoals {
+		errs.add("learningGoals", CodeOutOfRange, "must have at most 10 goals")
+		ok = false
+	}
+	return goals, ok
+}
+
+// isEmailAddress accepts a bare address such as "ana@example.com", without
+// a display name or angle brackets
+func isEmailAddress(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email && strings.Contains(email[strings.LastIndex(email, "@"):], ".")
+}
+
+// emailInUse reports whether a user other than userID has email. Emails
+// are unique across organizations, like handles.
+func emailInUse(ctx context.Context, email, userID string) (bool, error) {
+	if email == "" {
+		return false, nil
+	}
+	count, err := usersCol.CountDocuments(ctx, bson.M{"email": email, "user_id": bson.M{"$ne": userID}})
+	return count > 0, err
+}
+
+// isMediaURL accepts absolute http(s) URLs, as returned by media uploads
+func isMediaURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// recordProfileChange writes the audit trail for a profile update. A failed
+// audit write is logged but doesn't fail the update.
+func recordProfileChange(ctx context.Context, userID string, changes []FieldChange) {
+	fields := make([]string, 0, len(changes))
+	for _, c := range changes {
+		fields = append(fields, c.Field)
+	}
+	log.Printf("✅ Profile updated: user=%s, fields=%s", userID, strings.Join(fields, ","))
+
+	_, err := profileChangesCol.InsertOne(ctx, ProfileChange{
+		PublicID:  newPublicID(),
+		UserID:    userID,
+		Changes:   changes,
+		ChangedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("❌ Error recording profile change for %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,257 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// PUBLIC PROFILE MODELS
+// ============================================================================
+
+// PrivacySettings controls what a user exposes on their public profile.
+// Everything is private until the user opts in.
+type PrivacySettings struct {
+	PublicProfile        bool `bson:"public_profile" json:"publicProfile"`
+	ShowBadges           bool `bson:"show_badges" json:"showBadges"`
+	ShowCompletedCourses bool `bson:"show_completed_courses" json:"showCompletedCourses"`
+	ShowCertificates     bool `bson:"show_certificates" json:"showCertificates"`
+}
+
+type UpdateProfilePrivacyRequest struct {
+	Handle               string `json:"handle"`
+	PublicProfile        bool   `json:"publicProfile"`
+	ShowBadges           bool   `json:"showBadges"`
+	ShowCompletedCourses bool   `json:"showCompletedCourses"`
+	ShowCertificates     bool   `json:"showCertificates"`
+}
+
+// CompletedCourse is a course listed on a public profile
+type CompletedCourse struct {
+	CourseID    string    `json:"courseId"`
+	Title       string    `json:"title"`
+	Chapters    int       `json:"chapters"`
+	CompletedAt time.Time `json:"completedAt"` // when its last chapter was completed
+}
+
+// PublicProfile is the shareable view of a user
+type PublicProfile struct {
+	Handle           string            `json:"handle"`
+	Name             string            `json:"name"`
+	MemberSince      time.Time         `json:"memberSince"`
+	CompletedCourses []CompletedCourse `json:"completedCourses,omitempty"`
+	Badges           []Achievement     `json:"badges,omitempty"` // unlocked achievements
+	Certificates     []Certificate     `json:"certificates,omitempty"`
+}
+
+// handlePattern restricts handles to URL-safe lowercase names
+var handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// ============================================================================
+// PUBLIC PROFILE HANDLERS
+// ============================================================================
+
+// UpdateProfilePrivacy sets the user's handle and public profile visibility
+func UpdateProfilePrivacy(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	var req UpdateProfilePrivacyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.Handle = strings.ToLower(strings.TrimSpace(req.Handle))
+	var errs fieldErrors
+	if req.PublicProfile && req.Handle == "" {
+		errs.add("handle", CodeRequired, "is required for a public profile")
+	}
+	if req.Handle != "" && !handlePattern.MatchString(req.Handle) {
+		errs.add("handle", CodeInvalid, "must be 3-30 characters of lowercase letters, digits or underscores")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	set := bson.M{
+		"privacy": PrivacySettings{
+			PublicProfile:        req.PublicProfile,
+			ShowBadges:           req.ShowBadges,
+			ShowCompletedCourses: req.ShowCompletedCourses,
+			ShowCertificates:     req.ShowCertificates,
+		},
+		"updated_at": time.Now(),
+	}
+	update := bson.M{"$set": set}
+	if req.Handle != "" {
+		set["handle"] = req.Handle
+	} else {
+		update["$unset"] = bson.M{"handle": ""}
+	}
+
+	var user User
+	err := usersCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"user_id": userID}), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "Handle is already taken")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update profile privacy")
+		return
+	}
+
+	log.Printf("✅ Profile privacy updated: user=%s, handle=%s, public=%v", userID, req.Handle, req.PublicProfile)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Profile privacy updated successfully",
+		Data:    user,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetPublicProfile returns the opt-in public view of a user by handle
+func GetPublicProfile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	handle := strings.ToLower(vars["handle"])
+
+	ctx := r.Context()
+
+	var user User
+	err := usersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"handle": handle})).Decode(&user)
+	// Private profiles are indistinguishable from missing ones
+	if err == mongo.ErrNoDocuments || (err == nil && !user.Privacy.PublicProfile) {
+		sendError(w, http.StatusNotFound, "Profile not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	profile := PublicProfile{
+		Handle:      user.Handle,
+		Name:        user.Name,
+		MemberSince: user.CreatedAt,
+	}
+
+	if user.Privacy.ShowCompletedCourses {
+		completed, err := completedCoursesForUser(ctx, user.UserID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch completed courses")
+			return
+		}
+		profile.CompletedCourses = completed
+	}
+
+	if user.Privacy.ShowCertificates {
+		certificates, err := certificatesForUser(ctx, user.UserID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch certificates")
+			return
+		}
+		profile.Certificates = certificates
+	}
+
+	if user.Privacy.ShowBadges {
+		unlocked, err := unlockedAchievements(ctx, user.UserID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch achievements")
+			return
+		}
+		profile.Badges = []Achievement{}
+		for _, badge := range achievementViews(unlocked, responseLocale(w)) {
+			if badge.Unlocked {
+				profile.Badges = append(profile.Badges, badge)
+			}
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Profile fetched successfully",
+		Data:    profile,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// completedCoursesForUser lists the organization's courses whose chapters
+// the user has all completed, in catalog order
+func completedCoursesForUser(ctx context.Context, userID string) ([]CompletedCourse, error) {
+	cursor, err := progressCol.Find(ctx, tenantFilter(ctx, bson.M{"user_id": userID, "chapter_completed": true}),
+		options.Find().SetProjection(bson.M{"chapter_id": 1, "updated_at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var progress []Progress
+	if err := cursor.All(ctx, &progress); err != nil {
+		return nil, err
+	}
+	if len(progress) == 0 {
+		return []CompletedCourse{}, nil
+	}
+
+	// A chapter counts from when it was first completed; progress from
+	// before the activity log only has its last write
+	firstCompleted, err := chapterCompletionTimes(ctx, []string{userID})
+	if err != nil {
+		return nil, err
+	}
+	completedAt := make(map[string]time.Time, len(progress))
+	for _, p := range progress {
+		completedAt[p.ChapterID] = p.UpdatedAt
+		if at, ok := firstCompleted[userID+"/"+p.ChapterID]; ok {
+			completedAt[p.ChapterID] = at
+		}
+	}
+
+	cursor, err = coursesCol.Find(ctx, tenantFilter(ctx, bson.M{"chapter_ids.0": bson.M{"$exists": true}}),
+		options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "title", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var courses []Course
+	if err := cursor.All(ctx, &courses); err != nil {
+		return nil, err
+	}
+
+	completed := []CompletedCourse{}
+	for _, course := range courses {
+		chapterIDs := uniqueStrings(course.ChapterIDs)
+		var last time.Time
+		done := true
+		for _, chapterID := range chapterIDs {
+			at, ok := completedAt[chapterID]
+			if !ok {
+				done = false
+				break
+			}
+			if at.After(last) {
+				last = at
+			}
+		}
+		if done {
+			completed = append(completed, CompletedCourse{
+				CourseID:    course.CourseID,
+				Title:       course.Title,
+				Chapters:    len(chapterIDs),
+				CompletedAt: last,
+			})
+		}
+	}
+	return completed, nil
+}
@@ -0,0 +1,308 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// PROGRESS HANDLERS
+// ============================================================================
+
+// ErrCodeVersionConflict is returned for a progress write over a version
+// the client hadn't seen; the response carries the stored progress
+const ErrCodeVersionConflict = "version_conflict"
+
+// GetUserProgress returns all progress for a user
+func GetUserProgress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	query := r.URL.Query()
+
+	var errs fieldErrors
+	page := parseOffsetPage(r, &errs)
+	sort := parseSort(r, &errs, progressSortFields, bson.D{{Key: "_id", Value: 1}})
+	progressQuery := ProgressQuery{UserID: userID, Sort: sort, Page: page}
+	if v := query.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			errs.add("completed", CodeInvalidType, "must be a boolean")
+		} else {
+			progressQuery.Completed = &completed
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Only progress in courses the user is still enrolled in, and in the
+	// ?courseId= course if given
+	enrolled, err := enrolledChapterIDs(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+		return
+	}
+	if courseID := query.Get("courseId"); courseID != "" {
+		course, err := findCourse(ctx, courseID)
+		if err == mongo.ErrNoDocuments {
+			sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+			return
+		} else if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		inCourse := make(map[string]bool, len(course.ChapterIDs))
+		for _, id := range course.ChapterIDs {
+			inCourse[id] = enrolled[id]
+		}
+		enrolled = inCourse
+	}
+	progressQuery.ChapterIDs = make([]string, 0, len(enrolled))
+	for id, ok := range enrolled {
+		if ok {
+			progressQuery.ChapterIDs = append(progressQuery.ChapterIDs, id)
+		}
+	}
+
+	progress, total, err := progressStore.List(ctx, progressQuery)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch progress")
+		return
+	}
+
+	response := GetProgressResponse{
+		ApiResponse: ApiResponse{
+			Success: true,
+			Message: "Progress fetched successfully",
+			Data:    progress,
+			Meta:    page.meta(total),
+		},
+		Progress: progress,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetChapterProgress returns progress for a specific chapter
+func GetChapterProgress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	chapterID := vars["chapterId"]
+
+	ctx := r.Context()
+
+	if !checkEnrolled(ctx, w, userID, chapterID) {
+		return
+	}
+
+	progress, err := progressStore.Get(ctx, userID, chapterID)
+	if err == ErrNotFound {
+		// No progress yet - return empty progress
+		progress = Progress{
+			UserID:         userID,
+			ChapterID:      chapterID,
+			VideoProgress:  0,
+			QuizProgress:   0,
+			QuizAnswers:    []Answer{},
+			LastAccessedAt: time.Now(),
+			UpdatedAt:      time.Now(),
+		}
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Progress fetched successfully",
+		Data:    progress,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateVideoProgress updates video watching progress
+func UpdateVideoProgress(w http.ResponseWriter, r *http.Request) {
+	var req UpdateVideoProgressRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	errs.required("chapterId", req.ChapterID)
+	req.DeviceID = resumeDevice(r, req.DeviceID, &errs)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Clients may send public IDs instead of business keys
+	req.ChapterID = resolveChapterKey(ctx, req.ChapterID)
+
+	if !checkUserActive(ctx, w, req.UserID) {
+		return
+	}
+	if !checkEnrolled(ctx, w, req.UserID, req.ChapterID) ||
+		!checkUnlocked(ctx, w, req.UserID, req.ChapterID) ||
+		!checkPrerequisitesMet(ctx, w, req.UserID, req.ChapterID) {
+		return
+	}
+
+	result, err := progressService.RecordVideo(ctx, req)
+	if errors.Is(err, ErrVersionConflict) {
+		sendVersionConflict(ctx, w, req.UserID, req.ChapterID)
+		return
+	} else if err != nil {
+		log.Printf("❌ Error updating video progress: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to update progress")
+		return
+	}
+
+	touchSession(ctx, r, req.UserID, "")
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Video progress updated successfully",
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateQuizProgress updates quiz progress
+func UpdateQuizProgress(w http.ResponseWriter, r *http.Request) {
+	var req UpdateQuizProgressRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	errs.required("chapterId", req.ChapterID)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Clients may send public IDs instead of business keys
+	req.ChapterID = resolveChapterKey(ctx, req.ChapterID)
+
+	if !checkUserActive(ctx, w, req.UserID) {
+		return
+	}
+	if !checkEnrolled(ctx, w, req.UserID, req.ChapterID) ||
+		!checkUnlocked(ctx, w, req.UserID, req.ChapterID) ||
+		!checkPrerequisitesMet(ctx, w, req.UserID, req.ChapterID) {
+		return
+	}
+
+	source := QuizAnswerContext{SessionID: req.SessionID, UserAgent: r.UserAgent()}
+	if source.SessionID == "" {
+		source.SessionID = r.Header.Get("X-Session-ID")
+	}
+	result, err := progressService.RecordQuizAnswer(ctx, req, source)
+	var invalid fieldErrors
+	if errors.As(err, &invalid) {
+		sendValidationErrors(w, invalid)
+		return
+	} else if errors.Is(err, ErrVersionConflict) {
+		sendVersionConflict(ctx, w, req.UserID, req.ChapterID)
+		return
+	} else if err != nil {
+		log.Printf("❌ Error updating quiz progress: %v", err)
+		sendError(w, http.StatusInternalServerError, "Failed to update progress")
+		return
+	}
+
+	touchSession(ctx, r, req.UserID, "")
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Quiz progress updated successfully",
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// sendVersionConflict answers a write over a newer version with 409 and the
+// progress as stored, so the client can merge its change into it and retry
+func sendVersionConflict(ctx context.Context, w http.ResponseWriter, userID, chapterID string) {
+	var current interface{}
+	if progress, err := progressStore.Get(ctx, userID, chapterID); err == nil {
+		current = progress
+	} else if err != ErrNotFound {
+		log.Printf("❌ Error fetching progress after a version conflict: %v", err)
+	}
+	response := ApiResponse{
+		Success: false,
+		Code:    ErrCodeVersionConflict,
+		Message: "Progress was updated on another device",
+		Data:    current,
+	}
+	sendJSON(w, http.StatusConflict, response)
+}
+
+// ResetProgress resets all progress for a user (useful for testing)
+func ResetProgress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	deleted, err := resetUserProgress(r.Context(), userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to reset progress")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: T(responseLocale(w), "Progress reset successfully. Deleted %d records", deleted),
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// resetUserProgress deletes a user's progress, reopens their completed
+// courses and records the reset
+func resetUserProgress(ctx context.Context, userID string) (int64, error) {
+	deleted, err := progressService.Reset(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	// Courses count as completed again once the chapters are
+	_, err = enrollmentsCol.UpdateMany(ctx,
+		tenantFilter(ctx, bson.M{"user_id": userID, "completed_at": bson.M{"$exists": true}}),
+		bson.M{"$unset": bson.M{"completed_at": ""}})
+	if err != nil {
+		log.Printf("❌ Error clearing course completion for user %s: %v", userID, err)
+	}
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditProgressReset,
+		Target:  AuditTarget{Type: "user", ID: userID},
+		Details: bson.M{"deleted": deleted},
+	})
+	return deleted, nil
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ============================================================================
+// BATCHED VIDEO PROGRESS
+// ============================================================================
+
+// Players that report their position every few seconds can queue the
+// reports and send them together. Only the latest report per chapter is
+// written, so a batch costs one write per chapter however many reports it
+// carries.
+
+// maxVideoProgressEvents bounds the reports in one batch
+const maxVideoProgressEvents = 500
+
+// Outcomes of a chapter in a batch
+const (
+	BatchSaved    = "saved"
+	BatchRejected = "rejected"
+)
+
+// VideoProgressEvent is one position report
+type VideoProgressEvent struct {
+	ChapterID string    `json:"chapterId"`
+	Progress  int       `json:"progress"` // in seconds
+	Completed bool      `json:"completed"`
+	Timestamp time.Time `json:"timestamp"` // when the client recorded it
+}
+
+type BatchVideoProgressRequest struct {
+	UserID   string               `json:"userId"`
+	DeviceID string               `json:"deviceId"` // optional, else X-Device-ID
+	Events   []VideoProgressEvent `json:"events"`
+}
+
+// BatchVideoProgressResult is what happened to a batch
+type BatchVideoProgressResult struct {
+	Received int                   `json:"received"` // reports in the batch
+	Written  int                   `json:"written"`  // chapters saved
+	Chapters []BatchChapterOutcome `json:"chapters"`
+}
+
+// BatchChapterOutcome is what happened to one chapter's reports
+type BatchChapterOutcome struct {
+	ChapterID string           `json:"chapterId"`
+	Status    string           `json:"status"`
+	Events    int              `json:"events"`            // reports merged into this one
+	Progress  int              `json:"progress"`          // the position written
+	Completed bool             `json:"completed"`         // any report completed the video
+	Timestamp time.Time        `json:"timestamp"`         // of the report written
+	Code      string           `json:"code,omitempty"`    // why it was rejected
+	Message   string           `json:"message,omitempty"` // why it was rejected
+	Result    *VideoSubmission `json:"result,omitempty"`  // saved chapters only
+	Lock      *ChapterLock     `json:"lock,omitempty"`    // locked chapters only
+}
+
+// UpdateVideoProgressBatch writes the latest of a batch of position reports
+// for each chapter. A chapter the user can't write to is rejected on its own
+// without failing the rest of the batch.
+func UpdateVideoProgressBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchVideoProgressRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	req.DeviceID = resumeDevice(r, req.DeviceID, &errs)
+	if len(req.Events) == 0 {
+		errs.add("events", CodeRequired, "must contain at least one event")
+	} else if len(req.Events) > maxVideoProgressEvents {
+		errs.add("events", CodeInvalid, fmt.Sprintf("must contain at most %d events", maxVideoProgressEvents))
+	}
+	for i, e := range req.Events {
+		field := fmt.Sprintf("events[%d]", i)
+		errs.required(field+".chapterId", e.ChapterID)
+		if e.Timestamp.IsZero() {
+			errs.add(field+".timestamp", CodeRequired, "is required")
+		}
+		if e.Progress < 0 {
+			errs.add(field+".progress", CodeInvalid, "must not be negative")
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	if !checkUserActive(ctx, w, req.UserID) {
+		return
+	}
+
+	latest := latestVideoEvents(ctx, req.Events)
+	access, err := loadChapterAccess(ctx, req.UserID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	result := BatchVideoProgressResult{Received: len(req.Events), Chapters: []BatchChapterOutcome{}}
+	for _, outcome := range latest {
+		block, err := access.check(ctx, outcome.ChapterID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if block != nil {
+			outcome.Status = BatchRejected
+			outcome.Code, outcome.Message, outcome.Lock = block.code, T(responseLocale(w), block.message), block.lock
+			result.Chapters = append(result.Chapters, outcome)
+			continue
+		}
+
+		saved, err := progressService.RecordVideo(ctx, UpdateVideoProgressRequest{
+			UserID:    req.UserID,
+			ChapterID: outcome.ChapterID,
+			Progress:  outcome.Progress,
+			Completed: outcome.Completed,
+			DeviceID:  req.DeviceID,
+		})
+		if err != nil {
+			log.Printf("❌ Error updating video progress in batch: %v", err)
+			sendError(w, http.StatusInternalServerError, "Failed to update progress")
+			return
+		}
+		outcome.Status = BatchSaved
+		outcome.Result = &saved
+		result.Written++
+		result.Chapters = append(result.Chapters, outcome)
+	}
+
+	touchSession(ctx, r, req.UserID, "")
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Video progress updated successfully",
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// latestVideoEvents picks the latest report for each chapter, in the order
+// the chapters first appear. A chapter counts as completed if any of its
+// reports completed it, so a later seek back doesn't undo it.
+func latestVideoEvents(ctx context.Context, events []VideoProgressEvent) []BatchChapterOutcome {
+	index := map[string]int{}
+	outcomes := []BatchChapterOutcome{}
+	for _, e := range events {
+		// Clients may send public IDs instead of business keys
+		chapterID := resolveChapterKey(ctx, e.ChapterID)
+
+		i, seen := index[chapterID]
+		if !seen {
+			index[chapterID] = len(outcomes)
+			outcomes = append(outcomes, BatchChapterOutcome{ChapterID: chapterID, Progress: e.Progress, Timestamp: e.Timestamp})
+			i = len(outcomes) - 1
+		} else if !e.Timestamp.Before(outcomes[i].Timestamp) {
+			outcomes[i].Progress = e.Progress
+			outcomes[i].Timestamp = e.Timestamp
+		}
+		outcomes[i].Events++
+		outcomes[i].Completed = outcomes[i].Completed || e.Completed
+	}
+	return outcomes
+}
+
+// ============================================================================
+// CHAPTER ACCESS
+// ============================================================================
+
+// chapterAccess answers the checks of checkEnrolled, checkUnlocked and
+// checkPrerequisitesMet for many chapters from one load of the user's state
+type chapterAccess struct {
+	enrolled  map[string]bool
+	windows   map[string]chapterWindow
+	completed map[string]bool
+}
+
+// accessBlock is why a user can't write to a chapter
+type accessBlock struct {
+	code    string
+	message string
+	lock    *ChapterLock
+}
+
+func loadChapterAccess(ctx context.Context, userID string) (*chapterAccess, error) {
+	enrolled, err := enrolledChapterIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	windows, err := chapterWindows(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	completed, _, err := completedNodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &chapterAccess{enrolled: enrolled, windows: windows, completed: completed}, nil
+}
+
+// check returns why the user can't write to chapterID, or nil if they can
+func (a *chapterAccess) check(ctx context.Context, chapterID string) (*accessBlock, error) {
+	chapter, err := chapterStore.Get(ctx, chapterID)
+	if err == ErrNotFound {
+		return &accessBlock{code: ErrCodeChapterNotFound, message: "Chapter not found"}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !a.enrolled[chapterID] {
+		return &accessBlock{code: ErrCodeNotEnrolled, message: "Enroll in the course to access this chapter"}, nil
+	}
+	now := time.Now()
+	if a.windows[chapterID].closed(now) {
+		return &accessBlock{code: ErrCodeChapterClosed, message: "This chapter is no longer available"}, nil
+	}
+	if lock := chapterLock(a.windows[chapterID], now); lock != nil {
+		return &accessBlock{code: ErrCodeChapterLocked, message: "This chapter is not open yet", lock: lock}, nil
+	}
+	if lock := prerequisiteLock(chapter, a.completed); lock != nil {
+		return &accessBlock{code: ErrCodePrerequisitesUnmet, message: "Complete the prerequisite chapters first", lock: lock}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,294 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// PROGRESS REPORT
+// ============================================================================
+
+// The progress report is a CSV of every learner's progress in every chapter,
+// one line each, for admins to pull into a spreadsheet. It is streamed in
+// batches, so a large organization's report never sits in memory.
+
+// progressReportBatch is how many progress documents are written per batch
+const progressReportBatch = 500
+
+// progressReportHeader names the report's columns
+var progressReportHeader = []string{
+	"user_id", "name", "chapter_id", "chapter_title", "watch_percent",
+	"quiz_score", "quiz_passed", "completed", "completed_at", "last_accessed_at",
+}
+
+// reportChapter is what the report shows of a chapter
+type reportChapter struct {
+	Title    string
+	Duration int
+}
+
+// ExportProgressReport streams the progress report as CSV. ?courseId=
+// keeps the chapters of a course and its enrolled learners, ?cohort= (with
+// a course) one cohort of them, and ?from= and ?to= (dates or RFC 3339
+// times, to exclusive) the progress last accessed in that range.
+func ExportProgressReport(w http.ResponseWriter, r *http.Request) {
+	// A large report takes longer to write than the server's write timeout,
+	// which is for ordinary responses
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		sendError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	query := r.URL.Query()
+	courseID := query.Get("courseId")
+	cohortID := query.Get("cohort")
+
+	var errs fieldErrors
+	accessed := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+		if v := query.Get(param); v != "" {
+			t, err := parseDateOrTime(v, time.UTC)
+			if err != nil {
+				errs.add(param, CodeInvalid, "must be a YYYY-MM-DD date or an RFC 3339 timestamp")
+				continue
+			}
+			accessed[op] = t
+		}
+	}
+	if cohortID != "" && courseID == "" {
+		errs.add("cohort", CodeInvalid, "needs a courseId")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	filter := bson.M{}
+	if len(accessed) > 0 {
+		filter["last_accessed_at"] = accessed
+	}
+	if courseID != "" {
+		course, err := findCourse(ctx, courseID)
+		if err == mongo.ErrNoDocuments {
+			sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+			return
+		} else if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+
+		enrolled := tenantFilter(ctx, bson.M{"course_id": courseID, "status": EnrollmentActive})
+		if cohortID != "" {
+			if _, err := findCohort(ctx, courseID, cohortID); err == mongo.ErrNoDocuments {
+				sendError(w, http.StatusNotFound, "Cohort not found")
+				return
+			} else if err != nil {
+				sendError(w, http.StatusInternalServerError, "Database error")
+				return
+			}
+			enrolled["cohort"] = cohortID
+		}
+		userIDs, err := enrollmentsCol.Distinct(ctx, "user_id", enrolled)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if userIDs == nil {
+			userIDs = bson.A{}
+		}
+		filter["user_id"] = bson.M{"$in": userIDs}
+		filter["chapter_id"] = bson.M{"$in": uniqueStrings(course.ChapterIDs)}
+	}
+
+	cursor, err := progressCol.Find(ctx, tenantFilter(ctx, filter),
+		options.Find().
+			SetSort(bson.D{{Key: "user_id", Value: 1}, {Key: "chapter_id", Value: 1}}).
+			SetBatchSize(progressReportBatch))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch progress")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	name := "progress"
+	if courseID != "" {
+		name += "-" + courseID
+	}
+	if cohortID != "" {
+		name += "-" + cohortID
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, name, time.Now().UTC().Format("20060102")))
+	w.WriteHeader(http.StatusOK)
+
+	// The status is sent, so an error from here on can only cut the file
+	// short; it is logged for the admin to retry
+	out := csv.NewWriter(w)
+	out.Write(progressReportHeader)
+
+	chapters := map[string]reportChapter{}
+	rows := 0
+	batch := make([]Progress, 0, progressReportBatch)
+	flush := func() error {
+		if err := writeProgressReportBatch(ctx, out, batch, chapters); err != nil {
+			return err
+		}
+		rows += len(batch)
+		batch = batch[:0]
+		out.Flush()
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return out.Error()
+	}
+	for cursor.Next(ctx) {
+		var p Progress
+		if err := cursor.Decode(&p); err != nil {
+			log.Printf("❌ Error decoding progress for report: %v", err)
+			return
+		}
+		batch = append(batch, p)
+		if len(batch) == progressReportBatch {
+			if err := flush(); err != nil {
+				log.Printf("❌ Error writing progress report after %d rows: %v", rows, err)
+				return
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		log.Printf("❌ Error reading progress for report after %d rows: %v", rows, err)
+		return
+	}
+	if err := flush(); err != nil {
+		log.Printf("❌ Error writing progress report after %d rows: %v", rows, err)
+		return
+	}
+
+	log.Printf("📦 Progress report exported: course=%q, cohort=%q, rows=%d", courseID, cohortID, rows)
+}
+
+// writeProgressReportBatch writes one batch of progress as report lines.
+// chapters caches chapter titles and durations across batches.
+func writeProgressReportBatch(ctx context.Context, out *csv.Writer, batch []Progress, chapters map[string]reportChapter) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var userIDs, missing []string
+	for _, p := range batch {
+		userIDs = append(userIDs, p.UserID)
+		if _, ok := chapters[p.ChapterID]; !ok {
+			missing = append(missing, p.ChapterID)
+		}
+	}
+	userIDs = uniqueStrings(userIDs)
+	names, err := userNames(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	if err := loadReportChapters(ctx, uniqueStrings(missing), chapters); err != nil {
+		return err
+	}
+	completedAt, err := chapterCompletionTimes(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range batch {
+		chapter := chapters[p.ChapterID]
+		line := []string{
+			p.UserID,
+			csvText(names[p.UserID]),
+			p.ChapterID,
+			csvText(chapter.Title),
+			"",
+			"",
+			strconv.FormatBool(p.QuizPassed),
+			strconv.FormatBool(p.ChapterCompleted),
+			"",
+			p.LastAccessedAt.UTC().Format(time.RFC3339),
+		}
+		switch {
+		case p.VideoCompleted:
+			line[4] = "100"
+		case chapter.Duration > 0:
+			line[4] = strconv.Itoa(min(p.VideoProgress*100/chapter.Duration, 100))
+		}
+		if p.QuizScore != nil {
+			line[5] = strconv.Itoa(*p.QuizScore)
+		}
+		if t, ok := completedAt[p.UserID+"/"+p.ChapterID]; ok && p.ChapterCompleted {
+			line[8] = t.UTC().Format(time.RFC3339)
+		}
+		if err := out.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadReportChapters adds the titles and durations of chapters to the
+// cache. Archived chapters are included, since their progress is kept.
+func loadReportChapters(ctx context.Context, chapterIDs []string, chapters map[string]reportChapter) error {
+	if len(chapterIDs) == 0 {
+		return nil
+	}
+	cursor, err := chaptersCol.Find(ctx, bson.M{"chapter_id": bson.M{"$in": chapterIDs}},
+		options.Find().SetProjection(bson.M{"chapter_id": 1, "title": 1, "duration": 1}))
+	if err != nil {
+		return err
+	}
+	var found []Chapter
+	if err := cursor.All(ctx, &found); err != nil {
+		return err
+	}
+	for _, id := range chapterIDs {
+		chapters[id] = reportChapter{} // purged chapters stay blank
+	}
+	for _, chapter := range found {
+		chapters[chapter.ChapterID] = reportChapter{Title: chapter.Title, Duration: chapter.Duration}
+	}
+	return nil
+}
+
+// chapterCompletionTimes returns when users first completed each chapter,
+// keyed by "userID/chapterID", from their chapter_completed activity
+func chapterCompletionTimes(ctx context.Context, userIDs []string) (map[string]time.Time, error) {
+	cursor, err := activityCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: tenantFilter(ctx, bson.M{"user_id": bson.M{"$in": userIDs}, "type": ActivityChapterCompleted})}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"user_id": "$user_id", "chapter_id": "$chapter_id"},
+			"at":  bson.M{"$min": "$occurred_at"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		ID struct {
+			UserID    string `bson:"user_id"`
+			ChapterID string `bson:"chapter_id"`
+		} `bson:"_id"`
+		At time.Time `bson:"at"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	times := make(map[string]time.Time, len(results))
+	for _, result := range results {
+		times[result.ID.UserID+"/"+result.ID.ChapterID] = result.At
+	}
+	return times, nil
+}
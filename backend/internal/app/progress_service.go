@@ -0,0 +1,291 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ============================================================================
+// PROGRESS SERVICE
+// ============================================================================
+
+// ProgressService holds the rules for recording progress: when a chapter
+// counts as completed, when a quiz attempt starts and ends, and what goes
+// into the activity log. A chapter is completed once its video is and a
+// finished quiz attempt reaches the chapter's pass score; the client's
+// Completed flags only say what the learner finished, never that they
+// passed. Handlers validate the request and check access
+// before calling it; quiz answers are checked against the chapter's quiz
+// here, and come back as fieldErrors when they don't fit it.
+type ProgressService struct {
+	Progress ProgressStore
+	Chapters ChapterStore
+}
+
+// progressService is the service the handlers use, set up by setupStores
+var progressService *ProgressService
+
+// Progress fields whose write times are kept in Progress.FieldUpdatedAt;
+// each quiz answer has its own, named by quizAnswerField
+const (
+	FieldVideoProgress  = "videoProgress"
+	FieldVideoCompleted = "videoCompleted"
+	FieldQuizProgress   = "quizProgress"
+	FieldQuizCompleted  = "quizCompleted"
+)
+
+func quizAnswerField(questionIndex int) string {
+	return fmt.Sprintf("quizAnswers[%d]", questionIndex)
+}
+
+// QuizAnswerContext identifies where a quiz answer came from, for the
+// answer change log
+type QuizAnswerContext struct {
+	SessionID string
+	UserAgent string
+}
+
+// VideoSubmission is what recording a video position did. XP is set when
+// finishing the video earned some.
+type VideoSubmission struct {
+	SaveResult
+	XP *XPAward `json:"xp,omitempty"`
+}
+
+// RecordVideo saves a learner's position in a chapter's video. It returns
+// ErrVersionConflict when req.Version is set and isn't the stored version.
+func (s *ProgressService) RecordVideo(ctx context.Context, req UpdateVideoProgressRequest) (VideoSubmission, error) {
+	if req.Progress < 0 {
+		req.Progress = 0
+	}
+
+	// The previous position feeds the activity log, and the quiz result
+	// decides whether the chapter is complete
+	previous, err := s.Progress.Get(ctx, req.UserID, req.ChapterID)
+	if err != nil && err != ErrNotFound {
+		return VideoSubmission{}, err
+	}
+
+	now := time.Now()
+	video := Progress{
+		UserID:         req.UserID,
+		ChapterID:      req.ChapterID,
+		VideoProgress:  req.Progress,
+		VideoCompleted: req.Completed,
+		// Finishing the video after passing the quiz completes the chapter
+		ChapterCompleted: previous.ChapterCompleted || (req.Completed && previous.QuizPassed),
+		FieldUpdatedAt:   map[string]time.Time{FieldVideoProgress: now, FieldVideoCompleted: now},
+		Version:          req.Version,
+	}
+	// The device keeps its own resume position, which another device
+	// writing VideoProgress doesn't move
+	if req.DeviceID != "" {
+		video.DevicePositions = map[string]DevicePosition{req.DeviceID: {Position: req.Progress, UpdatedAt: now}}
+	}
+	result, err := s.Progress.SaveVideo(ctx, video)
+	if err != nil {
+		return VideoSubmission{}, err
+	}
+	submission := VideoSubmission{SaveResult: result}
+
+	log.Printf("✅ Video progress updated: user=%s, chapter=%s, progress=%d, completed=%v",
+		req.UserID, req.ChapterID, req.Progress, req.Completed)
+	publishProgress(ctx, req.UserID, req.ChapterID)
+	recordDailyActivity(ctx, req.UserID, now)
+
+	recordVideoWatched(ctx, req.UserID, req.ChapterID, previous.VideoProgress, req.Progress)
+	if req.Completed && !previous.VideoCompleted {
+		recordActivity(ctx, ActivityEvent{UserID: req.UserID, Type: ActivityVideoCompleted, ChapterID: req.ChapterID})
+		if previous.QuizPassed && !previous.ChapterCompleted {
+			recordActivity(ctx, ActivityEvent{UserID: req.UserID, Type: ActivityChapterCompleted, ChapterID: req.ChapterID})
+		}
+		submission.XP = awardXP(ctx, req.UserID, req.ChapterID, XPVideoCompleted)
+	}
+	evaluateAchievements(ctx, req.UserID)
+	return submission, nil
+}
+
+// QuizSubmission is what recording a quiz answer did. Quiz is set when the
+// answer finished the quiz, scored by the server against the answer key,
+// and XP when finishing it earned some.
+type QuizSubmission struct {
+	SaveResult
+	Quiz             *QuizResult `json:"quiz,omitempty"`
+	ChapterCompleted bool        `json:"chapterCompleted"`
+	XP               *XPAward    `json:"xp,omitempty"`
+}
+
+// RecordQuizAnswer saves a learner's answer to a quiz question, and scores
+// the attempt when it finishes the quiz. It returns ErrVersionConflict when
+// req.Version is set and isn't the stored version.
+func (s *ProgressService) RecordQuizAnswer(ctx context.Context, req UpdateQuizProgressRequest, source QuizAnswerContext) (QuizSubmission, error) {
+	chapter, err := s.Chapters.Get(ctx, req.ChapterID)
+	if err != nil {
+		return QuizSubmission{}, err
+	}
+	if chapter, err = s.attemptChapter(ctx, req.UserID, chapter); err != nil {
+		return QuizSubmission{}, err
+	}
+	// Answers to a shuffled quiz come in the order the learner was shown
+	order, err := s.quizOrderFor(ctx, req.UserID, chapter)
+	if err != nil {
+		return QuizSubmission{}, err
+	}
+	req.QuestionIndex, req.Answer = order.stored(req.QuestionIndex, req.Answer)
+	if errs := quizAnswerErrors(chapter.Quiz, "", req.QuestionIndex, req.Answer); len(errs) > 0 {
+		return QuizSubmission{}, errs
+	}
+
+	// One atomic write, so answers submitted at the same time all stick;
+	// the store computes the attempt clock from what it holds
+	previous, result, err := s.Progress.SaveQuizAnswer(ctx, QuizAnswer{
+		UserID:        req.UserID,
+		ChapterID:     req.ChapterID,
+		QuestionIndex: req.QuestionIndex,
+		Answer:        req.Answer,
+		Completed:     req.Completed,
+		Questions:     len(chapter.Quiz.Questions),
+		At:            time.Now(),
+		Version:       req.Version,
+	})
+	if err != nil {
+		return QuizSubmission{}, err
+	}
+	previousAnswer := noAnswer
+	if req.QuestionIndex < len(previous.QuizAnswers) {
+		previousAnswer = previous.QuizAnswers[req.QuestionIndex]
+	}
+	submission := QuizSubmission{SaveResult: result, ChapterCompleted: previous.ChapterCompleted}
+
+	log.Printf("✅ Quiz progress updated: user=%s, chapter=%s, question=%d, completed=%v",
+		req.UserID, req.ChapterID, req.QuestionIndex, req.Completed)
+
+	if !previousAnswer.Equal(req.Answer) {
+		recordAnswerChange(ctx, AnswerChange{
+			UserID:        req.UserID,
+			ChapterID:     req.ChapterID,
+			QuestionIndex: req.QuestionIndex,
+			OldAnswer:     previousAnswer,
+			NewAnswer:     req.Answer,
+			SessionID:     source.SessionID,
+			UserAgent:     source.UserAgent,
+		})
+	}
+
+	recordDailyActivity(ctx, req.UserID, time.Now())
+
+	// Finishing the quiz scores the answers as stored, which include any
+	// sent alongside this one
+	if req.Completed && !previous.QuizCompleted {
+		score, completed, xp, err := s.finishQuiz(ctx, req.UserID, chapter)
+		if err != nil {
+			return QuizSubmission{}, err
+		}
+		submission.Quiz = &score
+		submission.ChapterCompleted = completed
+		submission.XP = xp
+	}
+	publishProgress(ctx, req.UserID, req.ChapterID)
+	evaluateAchievements(ctx, req.UserID)
+	return submission, nil
+}
+
+// finishQuiz scores a user's stored answers to the chapter's quiz, saves the
+// result, and completes the chapter if it passed and the video is done. The
+// chapter's quiz is the attempt's, as attemptChapter gives it. It returns
+// the score, whether the chapter is completed now, and the XP it earned.
+// The result, the attempt and the XP are written in one transaction, so
+// none is kept without the others.
+func (s *ProgressService) finishQuiz(ctx context.Context, userID string, chapter Chapter) (QuizResult, bool, *XPAward, error) {
+	var (
+		score    QuizResult
+		previous Progress
+		attempt  *QuizAttempt
+		xp       *XPAward
+	)
+	err := withTransaction(ctx, func(ctx context.Context) error {
+		stored, err := s.Progress.Get(ctx, userID, chapter.ChapterID)
+		if err != nil {
+			return err
+		}
+		score = scoreQuiz(chapter, stored.QuizAnswers)
+
+		if previous, err = s.Progress.SaveQuizResult(ctx, userID, chapter.ChapterID, score.Percent, score.Passed); err != nil {
+			return err
+		}
+		if attempt, err = recordQuizAttempt(ctx, chapter, stored); err != nil {
+			return err
+		}
+		xp, err = grantXP(ctx, userID, chapter.ChapterID, quizXPReasons(score)...)
+		return err
+	})
+	if err != nil {
+		return QuizResult{}, false, nil, err
+	}
+	completed := previous.ChapterCompleted || (previous.VideoCompleted && score.Passed)
+
+	log.Printf("✅ Quiz finished: user=%s, chapter=%s, score=%d/%d, passed=%v",
+		userID, chapter.ChapterID, score.Score, score.Total, score.Passed)
+
+	announceQuizAttempt(ctx, attempt)
+	if completed && !previous.ChapterCompleted {
+		recordActivity(ctx, ActivityEvent{UserID: userID, Type: ActivityChapterCompleted, ChapterID: chapter.ChapterID})
+	}
+	logXPAward(userID, chapter.ChapterID, xp)
+	return score, completed, xp, nil
+}
+
+// quizAnswerErrors checks a question index and answer against a quiz.
+// prefix goes before the field names, e.g. "events[3]." in a sync.
+func quizAnswerErrors(quiz Quiz, prefix string, questionIndex int, answer Answer) fieldErrors {
+	if questionIndex < 0 || questionIndex >= len(quiz.Questions) {
+		var errs fieldErrors
+		errs.add(prefix+"questionIndex", CodeOutOfRange, "is not a question of this quiz")
+		return errs
+	}
+	return quiz.Questions[questionIndex].answerErrors(prefix+"answer", answer)
+}
+
+// sizeQuizAnswers fits stored answers to a quiz of n questions, returning a
+// copy. Questions without an answer yet are noAnswer. Answers saved when
+// every quiz was assumed to have 5 questions are padded or cut to fit.
+func sizeQuizAnswers(answers []Answer, n int) []Answer {
+	sized := make([]Answer, n)
+	for i := range sized {
+		sized[i] = noAnswer
+		if i < len(answers) {
+			sized[i] = answers[i]
+		}
+	}
+	return sized
+}
+
+// announceQuizAttempt tells the admin event stream, the event bus and the
+// activity log about a recorded attempt
+func announceQuizAttempt(ctx context.Context, attempt *QuizAttempt) {
+	userID, chapterID := attempt.UserID, attempt.ChapterID
+	log.Printf("📝 Quiz attempt recorded: user=%s, chapter=%s, score=%d/%d", userID, chapterID, attempt.Score, attempt.Total)
+	publishAdminEvent(ctx, AdminEvent{Type: AdminEventQuizSubmitted, UserID: userID, ChapterID: chapterID,
+		Score: attempt.Score, Total: attempt.Total, Passed: &attempt.Passed})
+	publishDomainEvent(ctx, DomainEvent{Type: DomainQuizSubmitted, UserID: userID, Data: attempt})
+
+	event := ActivityEvent{UserID: userID, Type: ActivityQuizFailed, ChapterID: chapterID,
+		Score: attempt.Score, Total: attempt.Total}
+	if attempt.Passed {
+		event.Type = ActivityQuizPassed
+	}
+	recordActivity(ctx, event)
+}
+
+// Reset deletes all of a user's progress and returns the count
+func (s *ProgressService) Reset(ctx context.Context, userID string) (int64, error) {
+	deleted, err := s.Progress.DeleteForUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("✅ Progress reset for user: %s (deleted %d records)", userID, deleted)
+	liveProgress.publish(userID, LiveMessage{Type: LiveReset})
+	return deleted, nil
+}
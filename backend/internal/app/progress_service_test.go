@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"testing"
+)
+
+// testChapter is a two-question chapter that passes with one right answer
+var testChapter = Chapter{
+	ChapterID: "test_chapter",
+	Title:     "Test chapter",
+	Duration:  60,
+	PassScore: 50,
+	Quiz: Quiz{Questions: []Question{
+		{ID: "q1", QuestionText: "One?", Options: []string{"a", "b"}, CorrectAnswer: 1},
+		{ID: "q2", QuestionText: "Two?", Options: []string{"a", "b"}, CorrectAnswer: 0},
+	}},
+}
+
+// newTestProgressService returns a ProgressService on empty memory stores
+// holding testChapter
+func newTestProgressService() *ProgressService {
+	return &ProgressService{
+		Progress: newMemProgressStore(),
+		Chapters: newMemChapterStore([]Chapter{testChapter}),
+	}
+}
+
+// answerQuiz answers the questions of testChapter in order, finishing the
+// quiz with the last, and returns the last submission
+func answerQuiz(t *testing.T, s *ProgressService, userID string, answers ...int) QuizSubmission {
+	t.Helper()
+	var submission QuizSubmission
+	for i, answer := range answers {
+		var err error
+		submission, err = s.RecordQuizAnswer(context.Background(), UpdateQuizProgressRequest{
+			UserID: userID, ChapterID: testChapter.ChapterID, QuestionIndex: i,
+			Answer: choiceAnswer(answer), Completed: i == len(answers)-1,
+		}, QuizAnswerContext{})
+		if err != nil {
+			t.Fatalf("answer %d: %v", i, err)
+		}
+	}
+	return submission
+}
+
+func watchVideo(t *testing.T, s *ProgressService, userID string) VideoSubmission {
+	t.Helper()
+	submission, err := s.RecordVideo(context.Background(), UpdateVideoProgressRequest{
+		UserID: userID, ChapterID: testChapter.ChapterID, Progress: testChapter.Duration, Completed: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return submission
+}
+
+func TestProgressServiceCompletesChapter(t *testing.T) {
+	s := newTestProgressService()
+	watchVideo(t, s, "ada")
+
+	submission := answerQuiz(t, s, "ada", 1, 1)
+	if submission.Quiz == nil || submission.Quiz.Score != 1 || submission.Quiz.Percent != 50 || !submission.Quiz.Passed {
+		t.Fatalf("got quiz result %+v, want 1 of 2 and passed", submission.Quiz)
+	}
+	if !submission.ChapterCompleted {
+		t.Fatal("a passed quiz after the video should complete the chapter")
+	}
+
+	p, err := s.Progress.Get(context.Background(), "ada", testChapter.ChapterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.ChapterCompleted || !p.QuizPassed || p.QuizScore == nil || *p.QuizScore != 50 {
+		t.Fatalf("stored %+v", p)
+	}
+}
+
+func TestProgressServiceFailedQuiz(t *testing.T) {
+	s := newTestProgressService()
+	watchVideo(t, s, "ada")
+
+	// The client saying the quiz is completed doesn't complete the chapter
+	submission := answerQuiz(t, s, "ada", 0, 1)
+	if submission.Quiz == nil || submission.Quiz.Passed || submission.ChapterCompleted {
+		t.Fatalf("got %+v, quiz %+v; want a failed quiz and the chapter not completed", submission, submission.Quiz)
+	}
+}
+
+func TestProgressServiceVideoAfterQuiz(t *testing.T) {
+	s := newTestProgressService()
+
+	submission := answerQuiz(t, s, "ada", 1, 0)
+	if submission.Quiz == nil || !submission.Quiz.Passed || submission.ChapterCompleted {
+		t.Fatalf("got %+v; want a passed quiz and the chapter waiting for the video", submission)
+	}
+
+	watchVideo(t, s, "ada")
+	p, err := s.Progress.Get(context.Background(), "ada", testChapter.ChapterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.ChapterCompleted {
+		t.Fatal("finishing the video after passing the quiz should complete the chapter")
+	}
+}
+
+func TestProgressServiceRejectsBadAnswers(t *testing.T) {
+	s := newTestProgressService()
+	for _, req := range []UpdateQuizProgressRequest{
+		{QuestionIndex: 2, Answer: choiceAnswer(0)},
+		{QuestionIndex: 0, Answer: choiceAnswer(5)},
+	} {
+		req.UserID, req.ChapterID = "ada", testChapter.ChapterID
+		_, err := s.RecordQuizAnswer(context.Background(), req, QuizAnswerContext{})
+		if _, ok := err.(fieldErrors); !ok {
+			t.Errorf("question %d, answer %d: got %v, want field errors", req.QuestionIndex, req.Answer.Choice, err)
+		}
+	}
+	if _, err := s.Progress.Get(context.Background(), "ada", testChapter.ChapterID); err != ErrNotFound {
+		t.Fatalf("a refused answer was stored: %v", err)
+	}
+}
+
+func TestProgressServiceVersionConflict(t *testing.T) {
+	s := newTestProgressService()
+	first := watchVideo(t, s, "ada")
+	watchVideo(t, s, "ada")
+
+	_, err := s.RecordVideo(context.Background(), UpdateVideoProgressRequest{
+		UserID: "ada", ChapterID: testChapter.ChapterID, Progress: 10, Version: first.Version,
+	})
+	if err != ErrVersionConflict {
+		t.Fatalf("writing over version %d: got %v, want ErrVersionConflict", first.Version, err)
+	}
+}
@@ -0,0 +1,441 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// QUESTION BANK MODELS
+// ============================================================================
+
+// A chapter's quiz can draw its questions from a question bank instead of
+// listing them: "5 random questions from bank X", optionally only of one
+// topic or difficulty. Each attempt draws its own set when the learner first
+// opens or answers the quiz, and the drawn question IDs are kept on their
+// progress, so every answer, the grading and the review of that attempt use
+// the same questions. A retake draws again.
+
+// Difficulties of a bank question
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// maxQuizDraw bounds how many questions one quiz may draw
+const maxQuizDraw = 50
+
+// bankIDPattern keeps bank IDs slug-shaped, like chapter IDs
+var bankIDPattern = regexp.MustCompile(`^[a-z0-9_\-]{2,50}$`)
+
+// QuizDraw makes a quiz pick its questions at random from a bank
+type QuizDraw struct {
+	BankID     string `bson:"bank_id" json:"bankId"`
+	Count      int    `bson:"count" json:"count"`
+	Topic      string `bson:"topic,omitempty" json:"topic,omitempty"`           // any topic if empty
+	Difficulty string `bson:"difficulty,omitempty" json:"difficulty,omitempty"` // any difficulty if empty
+}
+
+// BankQuestion is a question in a question bank. Question IDs are unique
+// across all banks, since attempts refer to drawn questions by ID alone.
+// Retired questions are no longer drawn, but attempts that drew them still
+// grade and review against them. Questions platform admins add are shared;
+// an organization's are only drawn for its own chapters.
+type BankQuestion struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID   string             `bson:"public_id,omitempty" json:"id"`
+	OrgID      string             `bson:"org_id,omitempty" json:"-"`
+	BankID     string             `bson:"bank_id" json:"bankId"`
+	Topic      string             `bson:"topic" json:"topic"`
+	Difficulty string             `bson:"difficulty" json:"difficulty"`
+	Question   Question           `bson:"question" json:"question"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updatedAt"`
+	RetiredAt  *time.Time         `bson:"retired_at,omitempty" json:"retiredAt,omitempty"`
+}
+
+// SaveBankQuestionRequest is the body of bank question create and update
+type SaveBankQuestionRequest struct {
+	Topic      string   `json:"topic"`
+	Difficulty string   `json:"difficulty"`
+	Question   Question `json:"question"` // on create, the ID is generated if empty
+}
+
+// ============================================================================
+// QUESTION BANK HANDLERS
+// ============================================================================
+
+// GetBankQuestions lists a bank's shared questions and the organization's
+// own, filtered by ?topic= and ?difficulty=. Retired questions are left out
+// unless ?retired=true.
+func GetBankQuestions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	filter := bankQuestionFilter(QuizDraw{
+		BankID:     vars["bankId"],
+		Topic:      query.Get("topic"),
+		Difficulty: query.Get("difficulty"),
+	})
+	if v := query.Get("retired"); v != "" {
+		retired, err := strconv.ParseBool(v)
+		if err != nil {
+			var errs fieldErrors
+			errs.add("retired", CodeInvalidType, "must be a boolean")
+			sendValidationErrors(w, errs)
+			return
+		}
+		if retired {
+			delete(filter, "retired_at")
+		}
+	}
+
+	ctx := r.Context()
+
+	opts := options.Find().SetSort(bson.D{{Key: "topic", Value: 1}, {Key: "question.id", Value: 1}})
+	cursor, err := questionBankCol.Find(ctx, sharedOrOwn(ctx, filter), opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch bank questions")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	questions := []BankQuestion{}
+	if err := cursor.All(ctx, &questions); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode bank questions")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Bank questions fetched successfully",
+		Data:    questions,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateBankQuestion adds a question to a bank, creating the bank with its
+// first question
+func CreateBankQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bankID := vars["bankId"]
+
+	var req SaveBankQuestionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if strings.TrimSpace(req.Question.ID) == "" {
+		req.Question.ID = "q_" + primitive.NewObjectID().Hex()
+	}
+
+	ctx := r.Context()
+
+	var errs fieldErrors
+	if !bankIDPattern.MatchString(bankID) {
+		errs.add("bankId", CodeInvalid, "must be 2-50 lowercase letters, digits, dashes or underscores")
+	}
+	if err := validateBankQuestion(ctx, &errs, &req); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	now := time.Now()
+	question := BankQuestion{
+		PublicID:   newPublicID(),
+		OrgID:      tenantOrgFilter(ctx),
+		BankID:     bankID,
+		Topic:      req.Topic,
+		Difficulty: req.Difficulty,
+		Question:   req.Question,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	result, err := questionBankCol.InsertOne(ctx, question)
+	if mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "A bank question with this ID already exists")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create bank question")
+		return
+	}
+	question.ID = result.InsertedID.(primitive.ObjectID)
+
+	log.Printf("✅ Bank question created: bank=%s, question=%s", bankID, question.Question.ID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Bank question created successfully",
+		Data:    question,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// UpdateBankQuestion replaces a bank question's content. Attempts that drew
+// it grade against the new version, as they do when a chapter's quiz is
+// edited.
+func UpdateBankQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bankID := vars["bankId"]
+	questionID := vars["questionId"]
+
+	var req SaveBankQuestionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Question.ID = questionID
+
+	ctx := r.Context()
+
+	var errs fieldErrors
+	if err := validateBankQuestion(ctx, &errs, &req); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	var question BankQuestion
+	err := questionBankCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"bank_id": bankID, "question.id": questionID}),
+		bson.M{"$set": bson.M{
+			"topic":      req.Topic,
+			"difficulty": req.Difficulty,
+			"question":   req.Question,
+			"updated_at": time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&question)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Bank question not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update bank question")
+		return
+	}
+
+	log.Printf("✅ Bank question updated: bank=%s, question=%s", bankID, questionID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Bank question updated successfully",
+		Data:    question,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RetireBankQuestion stops a question from being drawn. It stays in the bank
+// for the attempts that already drew it.
+func RetireBankQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bankID := vars["bankId"]
+	questionID := vars["questionId"]
+
+	ctx := r.Context()
+
+	now := time.Now()
+	result, err := questionBankCol.UpdateOne(ctx,
+		tenantFilter(ctx, bson.M{"bank_id": bankID, "question.id": questionID, "retired_at": bson.M{"$exists": false}}),
+		bson.M{"$set": bson.M{"retired_at": now, "updated_at": now}})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to retire bank question")
+		return
+	}
+	if result.MatchedCount == 0 {
+		sendError(w, http.StatusNotFound, "Bank question not found")
+		return
+	}
+
+	log.Printf("✅ Bank question retired: bank=%s, question=%s", bankID, questionID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Bank question retired successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// QUESTION BANK HELPERS
+// ============================================================================
+
+// validateQuizDraw checks a quiz's draw on its own; validateChapter checks
+// that the bank has the questions for it
+func validateQuizDraw(errs *fieldErrors, draw QuizDraw) {
+	if !bankIDPattern.MatchString(draw.BankID) {
+		errs.add("quiz.draw.bankId", CodeInvalid, "must be 2-50 lowercase letters, digits, dashes or underscores")
+	}
+	if draw.Count < 1 || draw.Count > maxQuizDraw {
+		errs.add("quiz.draw.count", CodeOutOfRange, fmt.Sprintf("must be between 1 and %d", maxQuizDraw))
+	}
+	if draw.Difficulty != "" && !validDifficulty(draw.Difficulty) {
+		errs.add("quiz.draw.difficulty", CodeUnknownValue, "must be one of easy, medium, hard")
+	}
+}
+
+// validateBankQuestion normalizes a bank question request and adds a field
+// error for every problem. It only returns an error when the skills can't be
+// checked.
+func validateBankQuestion(ctx context.Context, errs *fieldErrors, req *SaveBankQuestionRequest) error {
+	req.Topic = strings.TrimSpace(req.Topic)
+	errs.required("topic", req.Topic)
+	if !validDifficulty(req.Difficulty) {
+		errs.add("difficulty", CodeUnknownValue, "must be one of easy, medium, hard")
+	}
+	validateQuestion(errs, "question", &req.Question)
+	if err := checkImageRef(ctx, errs, "question.image.uploadId", req.Question.Image, UploadQuestionImage, ""); err != nil {
+		return err
+	}
+
+	if len(req.Question.Skills) == 0 {
+		return nil
+	}
+	skills, err := allSkills(ctx)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(skills))
+	for _, skill := range skills {
+		known[skill.SkillID] = true
+	}
+	for i, id := range req.Question.Skills {
+		if !known[id] {
+			errs.add(fmt.Sprintf("question.skills[%d]", i), CodeUnknownValue, "is not a known skill")
+		}
+	}
+	return nil
+}
+
+func validDifficulty(difficulty string) bool {
+	switch difficulty {
+	case DifficultyEasy, DifficultyMedium, DifficultyHard:
+		return true
+	}
+	return false
+}
+
+// bankQuestionFilter matches the questions a draw picks from
+func bankQuestionFilter(draw QuizDraw) bson.M {
+	filter := bson.M{"bank_id": draw.BankID, "retired_at": bson.M{"$exists": false}}
+	if draw.Topic != "" {
+		filter["topic"] = draw.Topic
+	}
+	if draw.Difficulty != "" {
+		filter["difficulty"] = draw.Difficulty
+	}
+	return filter
+}
+
+// chapterDrawFilter matches the questions a draw of orgID's chapter picks
+// from: the shared ones and the organization's own. A shared chapter, with
+// an empty orgID, only draws shared questions.
+func chapterDrawFilter(orgID string, draw QuizDraw) bson.M {
+	filter := bankQuestionFilter(draw)
+	filter["org_id"] = bson.M{"$in": bson.A{orgID, nil}}
+	return filter
+}
+
+// drawBankQuestions picks the questions of a draw of orgID's chapter at
+// random and returns their IDs. A bank that has shrunk since the chapter
+// was saved gives fewer.
+func drawBankQuestions(ctx context.Context, orgID string, draw QuizDraw) ([]string, error) {
+	cursor, err := questionBankCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: chapterDrawFilter(orgID, draw)}},
+		{{Key: "$sample", Value: bson.M{"size": draw.Count}}},
+		{{Key: "$project", Value: bson.M{"question.id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var drawn []BankQuestion
+	if err := cursor.All(ctx, &drawn); err != nil {
+		return nil, err
+	}
+	if len(drawn) == 0 {
+		return nil, fmt.Errorf("question bank %s has no questions to draw", draw.BankID)
+	}
+	ids := make([]string, len(drawn))
+	for i, q := range drawn {
+		ids[i] = q.Question.ID
+	}
+	return ids, nil
+}
+
+// withBankQuestions returns the chapter with its quiz made of the given bank
+// questions, in that order. Without IDs the chapter is returned as it is.
+func withBankQuestions(ctx context.Context, chapter Chapter, ids []string) (Chapter, error) {
+	if len(ids) == 0 {
+		return chapter, nil
+	}
+	cursor, err := questionBankCol.Find(ctx, bson.M{"question.id": bson.M{"$in": ids}})
+	if err != nil {
+		return Chapter{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []BankQuestion
+	if err := cursor.All(ctx, &found); err != nil {
+		return Chapter{}, err
+	}
+	byID := make(map[string]Question, len(found))
+	for _, q := range found {
+		byID[q.Question.ID] = q.Question
+	}
+
+	questions := make([]Question, 0, len(ids))
+	for _, id := range ids {
+		q, ok := byID[id]
+		if !ok {
+			return Chapter{}, fmt.Errorf("bank question %s is missing", id)
+		}
+		questions = append(questions, q)
+	}
+	chapter.Quiz.Questions = questions
+	return chapter, nil
+}
+
+// attemptChapter returns the chapter with the quiz of the user's current
+// attempt. For a quiz that draws from a bank, that is the attempt's drawn
+// questions, drawing them first if the attempt has none yet.
+func (s *ProgressService) attemptChapter(ctx context.Context, userID string, chapter Chapter) (Chapter, error) {
+	if chapter.Quiz.Draw == nil {
+		return chapter, nil
+	}
+	progress, err := s.Progress.Get(ctx, userID, chapter.ChapterID)
+	if err != nil && err != ErrNotFound {
+		return Chapter{}, err
+	}
+	if len(progress.QuizQuestionIDs) == 0 {
+		ids, err := drawBankQuestions(ctx, chapter.OrgID, *chapter.Quiz.Draw)
+		if err != nil {
+			return Chapter{}, err
+		}
+		// A concurrent draw may have been stored first; the stored one counts
+		if progress, err = s.Progress.SaveQuizDraw(ctx, userID, chapter.ChapterID, ids); err != nil {
+			return Chapter{}, err
+		}
+		log.Printf("🎲 Quiz questions drawn: user=%s, chapter=%s, bank=%s, questions=%d",
+			userID, chapter.ChapterID, chapter.Quiz.Draw.BankID, len(progress.QuizQuestionIDs))
+	}
+	return withBankQuestions(ctx, chapter, progress.QuizQuestionIDs)
+}
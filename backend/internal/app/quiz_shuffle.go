@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================================================
+// SHUFFLED QUIZZES
+// ============================================================================
+
+// A quiz with Shuffle set is shown to each learner in their own order of
+// questions and options, so answers can't be passed around as "B, D, A".
+// The order comes from a seed kept on the learner's progress for the
+// attempt. Clients send question indexes and options in the order they were
+// shown; the service maps them back before saving, so stored answers,
+// grading and analytics always use the quiz's own order. True/false options
+// keep their order.
+
+// QuizView is a quiz as one learner takes it, without the answer key
+type QuizView struct {
+	ChapterID     string             `json:"chapterId"`
+	Shuffled      bool               `json:"shuffled"`
+	Questions     []QuizQuestionView `json:"questions"`
+	Answers       []Answer           `json:"answers"`      // in the order shown, -1 if unanswered
+	QuizProgress  int                `json:"quizProgress"` // in the order shown
+	QuizCompleted bool               `json:"quizCompleted"`
+	PassScore     int                `json:"passScore"`
+}
+
+// QuizQuestionView is a question as shown, options in the order shown
+type QuizQuestionView struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	QuestionText string    `json:"questionText"`
+	Options      []string  `json:"options"`
+	Image        *ImageRef `json:"image,omitempty"`
+}
+
+// quizOrder maps between the order a learner sees and the quiz's own
+type quizOrder struct {
+	questions []int   // shown position -> question index
+	positions []int   // question index -> shown position
+	options   [][]int // per question index: shown option -> option index
+}
+
+// newQuizOrder is the order a seed gives a quiz. Without Shuffle, or before
+// the attempt has a seed, it is the quiz's own order.
+func newQuizOrder(quiz Quiz, seed int64) quizOrder {
+	n := len(quiz.Questions)
+	var rng *mathrand.Rand
+	if quiz.Shuffle && seed != 0 {
+		rng = mathrand.New(mathrand.NewSource(seed))
+	}
+
+	o := quizOrder{questions: identityOrder(n), positions: make([]int, n), options: make([][]int, n)}
+	if rng != nil {
+		o.questions = rng.Perm(n)
+	}
+	for pos, i := range o.questions {
+		o.positions[i] = pos
+	}
+	for i, q := range quiz.Questions {
+		o.options[i] = identityOrder(len(q.Options))
+		if rng != nil && q.kind() != QuestionTrueFalse {
+			o.options[i] = rng.Perm(len(q.Options))
+		}
+	}
+	return o
+}
+
+func identityOrder(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// stored maps a question position and answer as shown to the quiz's own
+// order. Positions and options that don't exist are left for validation
+// to reject.
+func (o quizOrder) stored(pos int, a Answer) (int, Answer) {
+	if pos < 0 || pos >= len(o.questions) {
+		return pos, a
+	}
+	i := o.questions[pos]
+	options := o.options[i]
+	toStored := func(shown int) int {
+		if shown < 0 || shown >= len(options) {
+			return shown
+		}
+		return options[shown]
+	}
+
+	mapped := a
+	switch {
+	case a.Text != nil:
+	case a.Choices != nil:
+		mapped.Choices = make([]int, len(a.Choices))
+		for j, choice := range a.Choices {
+			mapped.Choices[j] = toStored(choice)
+		}
+	default:
+		mapped.Choice = toStored(a.Choice)
+	}
+	return i, mapped
+}
+
+// shown maps a stored answer to question i into the order shown
+func (o quizOrder) shown(i int, a Answer) Answer {
+	toShown := func(stored int) int {
+		for shown, option := range o.options[i] {
+			if option == stored {
+				return shown
+			}
+		}
+		return stored
+	}
+
+	mapped := a
+	switch {
+	case a.Text != nil:
+	case a.Choices != nil:
+		mapped.Choices = make([]int, len(a.Choices))
+		for j, choice := range a.Choices {
+			mapped.Choices[j] = toShown(choice)
+		}
+	default:
+		mapped.Choice = toShown(a.Choice)
+	}
+	return mapped
+}
+
+// newQuizSeed picks a seed for an attempt; 0 means none, so it never is
+func newQuizSeed() int64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return int64(binary.BigEndian.Uint64(b[:])>>1) | 1
+}
+
+// QuizForAttempt returns the chapter's quiz as the user takes it in their
+// current attempt, giving the attempt its bank questions if the quiz draws
+// them and a seed if it is shuffled, when it has none yet
+func (s *ProgressService) QuizForAttempt(ctx context.Context, userID string, chapter Chapter) (QuizView, error) {
+	chapter, err := s.attemptChapter(ctx, userID, chapter)
+	if err != nil {
+		return QuizView{}, err
+	}
+	progress, err := s.Progress.Get(ctx, userID, chapter.ChapterID)
+	if err != nil && err != ErrNotFound {
+		return QuizView{}, err
+	}
+	if chapter.Quiz.Shuffle && progress.QuizSeed == 0 {
+		if progress, err = s.Progress.SaveQuizSeed(ctx, userID, chapter.ChapterID, newQuizSeed()); err != nil {
+			return QuizView{}, err
+		}
+		log.Printf("🔀 Quiz shuffled: user=%s, chapter=%s", userID, chapter.ChapterID)
+	}
+
+	order := newQuizOrder(chapter.Quiz, progress.QuizSeed)
+	answers := sizeQuizAnswers(progress.QuizAnswers, len(chapter.Quiz.Questions))
+	view := QuizView{
+		ChapterID:     chapter.ChapterID,
+		Shuffled:      chapter.Quiz.Shuffle,
+		Questions:     make([]QuizQuestionView, 0, len(order.questions)),
+		Answers:       make([]Answer, 0, len(order.questions)),
+		QuizCompleted: progress.QuizCompleted,
+		PassScore:     chapter.passScore(),
+	}
+	for _, i := range order.questions {
+		q := chapter.Quiz.Questions[i]
+		options := make([]string, len(q.Options))
+		for shown, option := range order.options[i] {
+			options[shown] = q.Options[option]
+		}
+		view.Questions = append(view.Questions, QuizQuestionView{
+			ID:           q.ID,
+			Type:         q.kind(),
+			QuestionText: q.QuestionText,
+			Options:      options,
+			Image:        q.Image,
+		})
+		view.Answers = append(view.Answers, order.shown(i, answers[i]))
+	}
+	if progress.QuizProgress >= 0 && progress.QuizProgress < len(order.positions) {
+		view.QuizProgress = order.positions[progress.QuizProgress]
+	}
+	return view, nil
+}
+
+// quizOrderFor is the order the user's current attempt shows the quiz in,
+// for mapping what they send back. chapter has the attempt's quiz.
+func (s *ProgressService) quizOrderFor(ctx context.Context, userID string, chapter Chapter) (quizOrder, error) {
+	if !chapter.Quiz.Shuffle {
+		return newQuizOrder(chapter.Quiz, 0), nil
+	}
+	progress, err := s.Progress.Get(ctx, userID, chapter.ChapterID)
+	if err != nil && err != ErrNotFound {
+		return quizOrder{}, err
+	}
+	return newQuizOrder(chapter.Quiz, progress.QuizSeed), nil
+}
+
+// ============================================================================
+// SHUFFLED QUIZ HANDLERS
+// ============================================================================
+
+// GetQuiz returns a chapter's quiz as the learner takes it: in their own
+// order if the quiz is shuffled, with their answers so far and without the
+// answer key
+func GetQuiz(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	// Clients may send public IDs instead of business keys
+	chapterID := resolveChapterKey(ctx, vars["chapterId"])
+
+	if !checkUserActive(ctx, w, userID) {
+		return
+	}
+	if !checkEnrolled(ctx, w, userID, chapterID) ||
+		!checkUnlocked(ctx, w, userID, chapterID) ||
+		!checkPrerequisitesMet(ctx, w, userID, chapterID) {
+		return
+	}
+
+	chapter, err := chapterStore.Get(ctx, chapterID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	view, err := progressService.QuizForAttempt(ctx, userID, chapter)
+	if err != nil {
+		log.Printf("❌ Error loading quiz for %s: %v", userID, err)
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Quiz fetched successfully",
+		Data:    view,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,272 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================================================
+// RATE LIMITING
+// ============================================================================
+
+// Writes (POST, PUT, PATCH, DELETE) are rate limited with token buckets, per
+// client IP and per signed-in user, so a misbehaving client can't hammer the
+// database. A bucket holds a minute's worth of requests and refills
+// steadily; an empty bucket answers 429 with Retry-After. Buckets live in
+// process memory, or in Redis so every instance shares them.
+
+const ErrCodeRateLimited = "rate_limited"
+
+// Default limits, in requests per minute. Players report video progress
+// every few seconds, so 30 a minute leaves room for a 2s heartbeat.
+const (
+	defaultRateLimitPerIP         = 300
+	defaultRateLimitPerUser       = 120
+	defaultRateLimitVideoProgress = 30
+)
+
+// Rate limiter backends, chosen with RATE_LIMIT_BACKEND
+const (
+	RateLimitMemory = "memory"
+	RateLimitRedis  = "redis"
+)
+
+// routeRateLimits are per-user limits for busy routes, keyed like
+// routeTimeouts. They replace the general per-user limit on those routes.
+var routeRateLimits = map[string]func(Config) int{
+	"POST /api/progress/video": func(c Config) int { return c.RateLimitVideoProgress },
+}
+
+// RateLimiter takes tokens from named buckets
+type RateLimiter interface {
+	// Take takes a token from key's bucket, which holds perMinute tokens
+	// and refills perMinute a minute. When the bucket is empty it returns
+	// false and how long until the next token.
+	Take(ctx context.Context, key string, perMinute int) (bool, time.Duration, error)
+}
+
+// rateLimiter is the limiter in use, set up by setupRateLimiting; nil means
+// no rate limiting
+var rateLimiter RateLimiter
+
+// ============================================================================
+// MEMORY BUCKETS
+// ============================================================================
+
+// memRateLimiter keeps buckets in process memory, so each instance limits
+// on its own
+type memRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newMemRateLimiter() *memRateLimiter {
+	return &memRateLimiter{buckets: map[string]*tokenBucket{}, lastSweep: time.Now()}
+}
+
+func (l *memRateLimiter) Take(ctx context.Context, key string, perMinute int) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	capacity := float64(perMinute)
+	perSecond := capacity / 60
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	return false, wait, nil
+}
+
+// sweep drops buckets idle long enough to have refilled, at most once a
+// minute, so one-off clients don't pile up
+func (l *memRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// ============================================================================
+// REDIS BUCKETS
+// ============================================================================
+
+// redisRateLimiter keeps buckets in Redis hashes, shared by every instance
+type redisRateLimiter struct {
+	redis *redisClient
+}
+
+// tokenBucketScript refills and takes from a bucket atomically. ARGV is the
+// capacity, the refill rate in tokens per millisecond and the time in
+// milliseconds; it returns {taken, milliseconds until the next token}.
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1]) or capacity
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated) * rate)
+local taken, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  taken = 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {taken, wait}
+`
+
+func (l redisRateLimiter) Take(ctx context.Context, key string, perMinute int) (bool, time.Duration, error) {
+	capacity := float64(perMinute)
+	perMs := capacity / 60000
+	reply, err := l.redis.Do(ctx, "EVAL", tokenBucketScript, "1", "ratelimit:"+key,
+		strconv.Itoa(perMinute),
+		strconv.FormatFloat(perMs, 'g', -1, 64),
+		strconv.FormatInt(time.Now().UnixMilli(), 10))
+	if err != nil {
+		return false, 0, err
+	}
+
+	result, ok := reply.([]interface{})
+	if !ok || len(result) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	taken, _ := result[0].(int64)
+	wait, _ := result[1].(int64)
+	return taken == 1, time.Duration(wait) * time.Millisecond, nil
+}
+
+// ============================================================================
+// RATE LIMIT MIDDLEWARE
+// ============================================================================
+
+// setupRateLimiting picks the limiter for RATE_LIMIT_BACKEND, memory by
+// default. Redis needs REDIS_URL; without it the limiter falls back to
+// memory.
+func setupRateLimiting() {
+	switch backend := config.RateLimitBackend; backend {
+	case "", RateLimitMemory:
+		rateLimiter = newMemRateLimiter()
+	case RateLimitRedis:
+		client, err := newRedisClient(config.RedisURL)
+		if err != nil {
+			log.Printf("⚠️ %v; rate limiting in memory instead", err)
+			rateLimiter = newMemRateLimiter()
+			return
+		}
+		rateLimiter = redisRateLimiter{client}
+		log.Println("✅ Rate limiting with Redis")
+	default:
+		log.Printf("⚠️ Unknown RATE_LIMIT_BACKEND %q, rate limiting in memory", backend)
+		rateLimiter = newMemRateLimiter()
+	}
+}
+
+// RateLimitMiddleware applies the per-IP and per-user limits to writes. If
+// the limiter fails the request goes through; an outage of Redis shouldn't
+// take the API down with it.
+func RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimiter == nil || !isWrite(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		type bucket struct {
+			key       string
+			perMinute int
+		}
+		buckets := []bucket{{"ip:" + clientIP(r), config.RateLimitPerIP}}
+		if userID := authUserID(ctx); userID != "" {
+			route := routeKey(r)
+			if limit, ok := routeRateLimits[route]; ok {
+				buckets = append(buckets, bucket{"user:" + userID + ":" + route, limit(config)})
+			} else {
+				buckets = append(buckets, bucket{"user:" + userID, config.RateLimitPerUser})
+			}
+		}
+
+		for _, b := range buckets {
+			if b.perMinute == 0 {
+				continue
+			}
+			ok, wait, err := rateLimiter.Take(ctx, b.key, b.perMinute)
+			if err != nil {
+				log.Printf("❌ Error checking rate limit %s: %v", b.key, err)
+				continue
+			}
+			if !ok {
+				sendRateLimited(w, wait)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendRateLimited sends a 429 telling the client when to retry
+func sendRateLimited(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	response := ApiResponse{
+		Success: false,
+		Code:    ErrCodeRateLimited,
+		Message: "Too many requests, try again later",
+		Data: map[string]interface{}{
+			"retryAfterMs": wait.Milliseconds(),
+		},
+	}
+	sendJSON(w, http.StatusTooManyRequests, response)
+}
+
+// isWrite reports whether a method changes state
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// routeKey names the matched route "METHOD /path/template"
+func routeKey(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + template
+		}
+	}
+	return r.Method + " " + r.URL.Path
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+)
+
+// ============================================================================
+// REQUEST IDS
+// ============================================================================
+
+// Every response carries an X-Request-ID: the one the client or a proxy
+// sent, when it looks like an ID, or a new UUID. A 500 includes it in data
+// and the server log carries it, so a user's report can be matched with the
+// log line.
+
+type requestIDContextKey struct{}
+
+// requestIDPattern is what an incoming X-Request-ID must look like to be
+// kept; anything else could forge log lines
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDMiddleware gives the request its ID and echoes it in the response
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = newPublicID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id)))
+	})
+}
+
+// requestID returns the request's ID, "" outside a request
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// ============================================================================
+// PANIC RECOVERY
+// ============================================================================
+
+// handlerPanic is a panic TimeoutMiddleware caught on the handler's
+// goroutine and raised again on the serving one, with the stack of where it
+// happened
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// RecoveryMiddleware turns a panicking handler into a 500 with the usual
+// envelope and the request ID, and logs the panic with its stack. A
+// response that had already started can't be replaced, so its connection is
+// cut instead. http.ErrAbortHandler is left to net/http, which cuts the
+// connection quietly.
+func RecoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			value, stack := p, []byte(nil)
+			if hp, ok := p.(handlerPanic); ok {
+				value, stack = hp.value, hp.stack
+			} else {
+				stack = debug.Stack()
+			}
+
+			id := requestID(r.Context())
+			log.Printf("❌ Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, id, value, stack)
+			if rec.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			response := ApiResponse{
+				Success: false,
+				Code:    ErrCodeInternal,
+				Message: "Internal server error",
+				Data:    map[string]string{"requestId": id},
+			}
+			sendJSON(rec, http.StatusInternalServerError, response)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,206 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// REDIS CLIENT
+// ============================================================================
+
+// redisClient is a minimal Redis client speaking RESP2: enough to run
+// commands and scripts over a small pool of connections. It understands
+// redis://[:password@]host[:port][/db] URLs.
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// RedisError is an error reply from the server
+type RedisError string
+
+func (e RedisError) Error() string { return string(e) }
+
+const (
+	redisMaxIdle        = 8
+	redisDefaultTimeout = 2 * time.Second
+)
+
+// newRedisClient parses a redis:// URL; connections are opened on first use
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q", rawURL)
+	}
+	c := &redisClient{addr: u.Host, idle: make(chan *redisConn, redisMaxIdle)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if password, ok := u.User.Password(); ok {
+		c.password = password
+	}
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		if c.db, err = strconv.Atoi(path); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", path)
+		}
+	}
+	return c, nil
+}
+
+// Do runs a command and returns its reply: a string, an int64, a []byte (nil
+// for a null reply), a []interface{} of replies, or a RedisError
+func (c *redisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisDefaultTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	reply, err := conn.do(args...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+	if e, ok := reply.(RedisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// Close closes the idle connections
+func (c *redisClient) Close() {
+	for {
+		select {
+		case conn := <-c.idle:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// conn takes an idle connection or dials a new one
+func (c *redisClient) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	var d net.Dialer
+	dialCtx, cancel := context.WithTimeout(ctx, redisDefaultTimeout)
+	defer cancel()
+	nc, err := d.DialContext(dialCtx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	conn.SetDeadline(time.Now().Add(redisDefaultTimeout))
+
+	if c.password != "" {
+		if err := conn.expectOK("AUTH", c.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if err := conn.expectOK("SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (conn *redisConn) expectOK(args ...string) error {
+	reply, err := conn.do(args...)
+	if err != nil {
+		return err
+	}
+	if e, ok := reply.(RedisError); ok {
+		return e
+	}
+	return nil
+}
+
+// do writes a command as an array of bulk strings and reads the reply
+func (conn *redisConn) do(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return nil, err
+	}
+	return conn.readReply()
+}
+
+func (conn *redisConn) readReply() (interface{}, error) {
+	line, err := conn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []byte(nil), err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(conn.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return []interface{}(nil), err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = conn.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// SCHEDULED RELEASE MODELS
+// ============================================================================
+
+// A chapter can be released on a date and withdrawn on another, for weekly
+// content drops. Each course can move those dates for a cohort of its
+// learners, such as a class that started later. Before its release a
+// chapter is locked with a countdown; after it is withdrawn learners no
+// longer see it. See chapterWindows for how this combines with drip rules.
+
+// ErrCodeChapterClosed is returned for a chapter whose release has ended
+const ErrCodeChapterClosed = "chapter_closed"
+
+// ChapterAvailability is when a chapter is available to learners. Either
+// end may be left open.
+type ChapterAvailability struct {
+	AvailableFrom  *time.Time `bson:"available_from,omitempty" json:"availableFrom,omitempty"`
+	AvailableUntil *time.Time `bson:"available_until,omitempty" json:"availableUntil,omitempty"`
+}
+
+// ChapterRelease overrides a chapter's availability for one cohort of a
+// course. Leaving both dates out makes the chapter always available to it.
+type ChapterRelease struct {
+	Cohort              string `bson:"cohort" json:"cohort"`
+	ChapterID           string `bson:"chapter_id" json:"chapterId"`
+	ChapterAvailability `bson:",inline"`
+}
+
+type UpdateCourseReleasesRequest struct {
+	Releases []ChapterRelease `json:"releases"`
+}
+
+type UpdateCohortRequest struct {
+	Cohort string `json:"cohort"` // empty to leave the cohort
+}
+
+// ============================================================================
+// SCHEDULED RELEASE HANDLERS
+// ============================================================================
+
+// UpdateChapterAvailability sets when a chapter is available to learners
+func UpdateChapterAvailability(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var req ChapterAvailability
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	validateAvailability(&errs, "", req)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	set := bson.M{"updated_at": time.Now()}
+	unset := bson.M{}
+	for field, value := range map[string]*time.Time{
+		"available_from":  req.AvailableFrom,
+		"available_until": req.AvailableUntil,
+	} {
+		if value != nil {
+			set[field] = *value
+		} else {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var existing Chapter
+	err := chaptersCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update chapter availability")
+		return
+	}
+	chapter := existing
+	chapter.AvailableFrom, chapter.AvailableUntil = req.AvailableFrom, req.AvailableUntil
+
+	chapterWritten(ctx, chapter)
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditChapterUpdated,
+		Target:  AuditTarget{Type: "chapter", ID: chapterID},
+		Changes: auditDiff(existing, chapter),
+	})
+	log.Printf("✅ Chapter availability updated: %s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter availability updated successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateCourseReleases replaces a course's per-cohort release dates
+func UpdateCourseReleases(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+
+	var req UpdateCourseReleasesRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	course, err := findCourse(ctx, courseID)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	inCourse := make(map[string]bool, len(course.ChapterIDs))
+	for _, id := range course.ChapterIDs {
+		inCourse[id] = true
+	}
+
+	cohorts, err := courseCohortIDs(ctx, courseID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	var errs fieldErrors
+	seen := map[string]bool{}
+	releases := []ChapterRelease{}
+	for i, release := range req.Releases {
+		field := fmt.Sprintf("releases[%d]", i)
+		switch {
+		case !cohorts[release.Cohort]:
+			errs.add(field+".cohort", CodeUnknownValue, "is not a cohort of this course")
+		case !inCourse[release.ChapterID]:
+			errs.add(field+".chapterId", CodeInvalid, "is not a chapter of this course")
+		case seen[release.Cohort+"/"+release.ChapterID]:
+			errs.add(field+".chapterId", CodeInvalid, "appears more than once for this cohort")
+		}
+		seen[release.Cohort+"/"+release.ChapterID] = true
+		validateAvailability(&errs, field+".", release.ChapterAvailability)
+		releases = append(releases, release)
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	err = coursesCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"course_id": courseID}),
+		bson.M{"$set": bson.M{"releases": releases, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(course)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update release schedule")
+		return
+	}
+
+	log.Printf("✅ Release schedule updated: course=%s, releases=%d", courseID, len(releases))
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Release schedule updated successfully",
+		Data:    course,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateEnrollmentCohort puts an enrolled learner in a cohort of the course,
+// or takes them out of it
+func UpdateEnrollmentCohort(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	courseID := vars["courseId"]
+	userID := vars["userId"]
+
+	var req UpdateCohortRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	ctx := r.Context()
+
+	if req.Cohort != "" {
+		if _, err := findCohort(ctx, courseID, req.Cohort); err == mongo.ErrNoDocuments {
+			var errs fieldErrors
+			errs.add("cohort", CodeUnknownValue, "is not a cohort of this course")
+			sendValidationErrors(w, errs)
+			return
+		} else if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	update := bson.M{"$set": bson.M{"cohort": req.Cohort}}
+	if req.Cohort == "" {
+		update = bson.M{"$unset": bson.M{"cohort": ""}}
+	}
+
+	var enrollment Enrollment
+	err := enrollmentsCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"user_id": userID, "course_id": courseID, "status": EnrollmentActive}),
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&enrollment)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Enrollment not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update cohort")
+		return
+	}
+
+	log.Printf("✅ Cohort updated: user=%s, course=%s, cohort=%q", userID, courseID, req.Cohort)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Cohort updated successfully",
+		Data:    enrollment.withAccess(time.Now()),
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// SCHEDULED RELEASE HELPERS
+// ============================================================================
+
+// validateAvailability checks that a release ends after it starts. prefix
+// names the object holding the dates, e.g. "releases[0]."
+func validateAvailability(errs *fieldErrors, prefix string, a ChapterAvailability) {
+	if a.AvailableFrom != nil && a.AvailableUntil != nil && !a.AvailableUntil.After(*a.AvailableFrom) {
+		errs.add(prefix+"availableUntil", CodeInvalid, "must be after availableFrom")
+	}
+}
+
+// sendChapterClosed sends the 403 for a chapter whose release has ended
+func sendChapterClosed(w http.ResponseWriter) {
+	response := ApiResponse{
+		Success: false,
+		Code:    ErrCodeChapterClosed,
+		Message: "This chapter is no longer available",
+	}
+	sendJSON(w, http.StatusForbidden, response)
+}
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================================================
+// REPAIR MODELS
+// ============================================================================
+
+// maxListedRepairs caps the changes listed in a repair report; the counts
+// always cover everything
+const maxListedRepairs = 500
+
+// RepairRequest scopes a repair to a user, a chapter, both, or (empty) the
+// whole database
+type RepairRequest struct {
+	UserID    string `json:"userId"`
+	ChapterID string `json:"chapterId"`
+}
+
+// RepairReport lists what a repair changed, or would change on a dry run
+type RepairReport struct {
+	DryRun    bool           `json:"dryRun"`
+	Scanned   int            `json:"scanned"`
+	Changed   int            `json:"changed"`
+	Changes   []RepairChange `json:"changes"`
+	Truncated bool           `json:"truncated"` // more changes than listed
+}
+
+// RepairChange is one derived field that disagreed with its inputs
+type RepairChange struct {
+	Collection string      `json:"collection"`
+	ID         string      `json:"id"`
+	UserID     string      `json:"userId"`
+	Key        string      `json:"key"` // chapter ID or path ID
+	Field      string      `json:"field"`
+	From       interface{} `json:"from"`
+	To         interface{} `json:"to"`
+}
+
+// add records a change, listing it while there's room
+func (report *RepairReport) add(change RepairChange) {
+	report.Changed++
+	if len(report.Changes) < maxListedRepairs {
+		report.Changes = append(report.Changes, change)
+	} else {
+		report.Truncated = true
+	}
+}
+
+// ============================================================================
+// REPAIR HANDLERS
+// ============================================================================
+
+// RepairProgress recomputes derived progress fields from their inputs:
+// chapter_completed from the video flag and quiz result, then path
+// completion from the chapters. ?dryRun=true reports without writing.
+func RepairProgress(w http.ResponseWriter, r *http.Request) {
+	var req RepairRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			var errs fieldErrors
+			errs.add("dryRun", CodeInvalidType, "must be a boolean")
+			sendValidationErrors(w, errs)
+			return
+		}
+	}
+
+	// A whole-database repair can take a while; the route has a long budget
+	ctx := r.Context()
+
+	if req.UserID != "" {
+		req.UserID = resolveUserKey(ctx, req.UserID)
+	}
+	if req.ChapterID != "" {
+		req.ChapterID = resolveChapterKey(ctx, req.ChapterID)
+	}
+
+	report := &RepairReport{DryRun: dryRun, Changes: []RepairChange{}}
+	if err := repairChapterCompletion(ctx, req, report); err != nil {
+		log.Printf("❌ Error repairing chapter completion: %v", err)
+		sendError(w, http.StatusInternalServerError, "Repair failed")
+		return
+	}
+	if err := repairPathCompletion(ctx, req, report); err != nil {
+		log.Printf("❌ Error repairing path completion: %v", err)
+		sendError(w, http.StatusInternalServerError, "Repair failed")
+		return
+	}
+
+	if !dryRun {
+		log.Printf("🔧 Repair finished: user=%q, chapter=%q, scanned=%d, changed=%d",
+			req.UserID, req.ChapterID, report.Scanned, report.Changed)
+		recordAudit(ctx, AuditEntry{
+			Action: AuditProgressRepaired,
+			Target: AuditTarget{Type: "progress"},
+			Details: bson.M{
+				"user_id":    req.UserID,
+				"chapter_id": req.ChapterID,
+				"scanned":    report.Scanned,
+				"changed":    report.Changed,
+			},
+		})
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Repair completed successfully",
+		Data:    report,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// REPAIR HELPERS
+// ============================================================================
+
+// repairChapterCompletion sets chapter_completed to video_completed &&
+// quiz_passed wherever they disagree. Progress from before pass scores were
+// kept counts as passed if any attempt in the history passed.
+func repairChapterCompletion(ctx context.Context, req RepairRequest, report *RepairReport) error {
+	filter := bson.M{}
+	if req.UserID != "" {
+		filter["user_id"] = req.UserID
+	}
+	if req.ChapterID != "" {
+		filter["chapter_id"] = req.ChapterID
+	}
+
+	cursor, err := progressCol.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var p Progress
+		if err := cursor.Decode(&p); err != nil {
+			return err
+		}
+		report.Scanned++
+
+		want := p.VideoCompleted && p.QuizPassed
+		if want == p.ChapterCompleted {
+			continue
+		}
+		if p.VideoCompleted && !p.QuizPassed {
+			passed, err := quizAttemptsCol.CountDocuments(ctx, bson.M{"user_id": p.UserID, "chapter_id": p.ChapterID, "passed": true})
+			if err != nil {
+				return err
+			}
+			if want = passed > 0; want == p.ChapterCompleted {
+				continue
+			}
+		}
+		report.add(RepairChange{
+			Collection: progressCol.Name(),
+			ID:         p.PublicID,
+			UserID:     p.UserID,
+			Key:        p.ChapterID,
+			Field:      "chapter_completed",
+			From:       p.ChapterCompleted,
+			To:         want,
+		})
+		if report.DryRun {
+			continue
+		}
+
+		// Only if nothing changed it since we read it
+		_, err := progressCol.UpdateOne(ctx,
+			bson.M{"_id": p.ID, "chapter_completed": p.ChapterCompleted},
+			bson.M{"$set": bson.M{"chapter_completed": want, "updated_at": time.Now()}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// repairPathCompletion stamps completed_at on path enrollments whose chapters
+// are all complete. Completion is judged from the video and quiz flags, so a
+// dry run sees the same result as a real one. Completed paths stay completed.
+func repairPathCompletion(ctx context.Context, req RepairRequest, report *RepairReport) error {
+	pathFilter := bson.M{}
+	if req.ChapterID != "" {
+		pathFilter["chapter_ids"] = req.ChapterID
+	}
+	paths, err := findPaths(ctx, pathFilter)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if len(path.ChapterIDs) == 0 {
+			continue
+		}
+		filter := bson.M{"path_id": path.PathID, "completed_at": bson.M{"$exists": false}}
+		if req.UserID != "" {
+			filter["user_id"] = req.UserID
+		}
+		cursor, err := pathEnrollmentsCol.Find(ctx, filter)
+		if err != nil {
+			return err
+		}
+		var enrollments []PathEnrollment
+		if err := cursor.All(ctx, &enrollments); err != nil {
+			return err
+		}
+
+		for _, enrollment := range enrollments {
+			report.Scanned++
+			done, err := progressCol.CountDocuments(ctx, bson.M{
+				"user_id":         enrollment.UserID,
+				"chapter_id":      bson.M{"$in": path.ChapterIDs},
+				"video_completed": true,
+				"quiz_completed":  true,
+			})
+			if err != nil {
+				return err
+			}
+			if int(done) < len(path.ChapterIDs) {
+				continue
+			}
+
+			now := time.Now()
+			report.add(RepairChange{
+				Collection: pathEnrollmentsCol.Name(),
+				ID:         enrollment.PublicID,
+				UserID:     enrollment.UserID,
+				Key:        path.PathID,
+				Field:      "completed_at",
+				From:       nil,
+				To:         now,
+			})
+			if report.DryRun {
+				continue
+			}
+			_, err = pathEnrollmentsCol.UpdateOne(ctx,
+				bson.M{"_id": enrollment.ID, "completed_at": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"completed_at": now}})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findPaths loads the learning paths matching filter
+func findPaths(ctx context.Context, filter bson.M) ([]LearningPath, error) {
+	cursor, err := pathsCol.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	paths := []LearningPath{}
+	if err := cursor.All(ctx, &paths); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// ROLES
+// ============================================================================
+
+// Besides admin keys, staff reach the admin routes with their own access
+// token. Instructors manage courses and enrollments and read chapter
+// analytics; admins can also use the rest of the org admin routes, managing
+// users and webhooks. Both may read the data of their organization's
+// learners. Learners only ever see their own data. The platform routes,
+// including chapter editing, stay behind ADMIN_API_KEY.
+
+// User roles
+const (
+	RoleLearner    = "learner"
+	RoleInstructor = "instructor"
+	RoleAdmin      = "admin"
+)
+
+// ErrCodeStaffSignInRequired is the code of a staff role given to a guest
+// account, which anyone could enter by its user ID
+const ErrCodeStaffSignInRequired = "staff_sign_in_required"
+
+type UpdateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// userRole treats users created before roles existed as learners
+func userRole(user User) string {
+	if user.Role == "" {
+		return RoleLearner
+	}
+	return user.Role
+}
+
+// staffRole reports whether a role may read other learners' data
+func staffRole(role string) bool {
+	return role == RoleInstructor || role == RoleAdmin
+}
+
+// hasSignIn reports whether the user signs in with a password or a linked
+// provider rather than by user ID alone, as staff must
+func hasSignIn(user User) bool {
+	return user.PasswordHash != "" || len(user.Identities) > 0
+}
+
+// withSignIn narrows a users filter to the accounts hasSignIn accepts
+func withSignIn(filter bson.M) bson.M {
+	filter["$or"] = bson.A{
+		bson.M{"password_hash": bson.M{"$exists": true, "$ne": ""}},
+		bson.M{"identities.0": bson.M{"$exists": true}},
+	}
+	return filter
+}
+
+// requireInstructor guards the admin routes instructors may use: an admin
+// key or an instructor's or admin's token opens them
+func requireInstructor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, _ := requestTenant(r.Context())
+		if !t.admin && !staffRole(t.role) {
+			sendRoleForbidden(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendRoleForbidden rejects a request whose token's role can't use an admin
+// route, or without a valid admin key
+func sendRoleForbidden(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authClaims(r.Context()); ok {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeForbidden, "Your role doesn't allow this")
+		return
+	}
+	sendAdminUnauthorized(w, r)
+}
+
+// ============================================================================
+// ROLE HANDLERS
+// ============================================================================
+
+// UpdateUserRole makes a user a learner, instructor or admin. Only accounts
+// with a password or a linked provider can be made staff, since a guest is
+// entered by user ID alone. The user's sessions are revoked, since their
+// tokens carry the old role; they log in again to get the new one.
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["userId"]
+
+	var req UpdateUserRoleRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	switch req.Role {
+	case RoleLearner, RoleInstructor, RoleAdmin:
+	default:
+		errs.add("role", CodeUnknownValue, "must be 'learner', 'instructor' or 'admin'")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+	now := time.Now()
+
+	// The sign-in is part of the filter rather than checked first, so an
+	// account can't lose it between the check and the update
+	filter := tenantFilter(ctx, bson.M{"user_id": userID})
+	if staffRole(req.Role) {
+		filter = withSignIn(filter)
+	}
+
+	var user User
+	err := usersCol.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{
+		"role":       req.Role,
+		"updated_at": now,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		// Either there is no such user, or it can't be staff
+		count, err := usersCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"user_id": userID}))
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to update user role")
+		} else if count == 0 {
+			sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		} else {
+			sendErrorCode(w, http.StatusConflict, ErrCodeStaffSignInRequired, "Only accounts with a password or a linked Google or Apple account can be staff")
+		}
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update user role")
+		return
+	}
+
+	if err := sessionStore.RevokeForUser(ctx, userID, now); err != nil {
+		log.Printf("❌ Error revoking sessions of user %s after a role change: %v", userID, err)
+	}
+
+	recordAudit(ctx, AuditEntry{
+		OrgID:   userOrgID(user),
+		Action:  AuditUserRoleChanged,
+		Target:  AuditTarget{Type: "user", ID: userID},
+		Changes: auditDiff(bson.M{"role": userRole(user)}, bson.M{"role": req.Role}),
+	})
+	user.Role, user.UpdatedAt = req.Role, now
+
+	log.Printf("✅ User role changed: user=%s, role=%s", userID, req.Role)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "User role updated successfully",
+		Data:    user,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
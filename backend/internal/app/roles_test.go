@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoginRefusesStaffByUserID(t *testing.T) {
+	ctx := context.Background()
+	for _, role := range []string{RoleInstructor, RoleAdmin} {
+		userID := testUserID(role)
+		_, _, err := userStore.FindOrCreate(ctx, User{
+			PublicID: newPublicID(), UserID: userID, OrgID: defaultOrgID, Role: role,
+			Status: UserActive, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := do(t, "POST", "/api/login", "", LoginRequest{UserID: userID})
+		if res.Status != http.StatusForbidden || res.Code != ErrCodeStaffSignInRequired {
+			t.Fatalf("login as %s: got %d %q, want 403 %q", role, res.Status, res.Code, ErrCodeStaffSignInRequired)
+		}
+		if res.Data != nil {
+			t.Fatalf("login as %s: got data %v", role, res.Data)
+		}
+	}
+
+	// Learners still log in by user ID, the first time and, with their
+	// secret, after
+	userID := testUserID("learner")
+	secret := login(t, userID).GuestSecret
+	res := do(t, "POST", "/api/login", "", LoginRequest{UserID: userID, GuestSecret: secret})
+	if res.Status != http.StatusOK {
+		t.Fatalf("learner login: %d %s", res.Status, res.Body)
+	}
+}
+
+func TestHasSignIn(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"guest", User{UserID: "guest"}, false},
+		{"password", User{UserID: "ana", PasswordHash: "$2a$10$hash"}, true},
+		{"provider", User{UserID: "bo", Identities: []LinkedIdentity{{Provider: "google"}}}, true},
+	}
+	for _, test := range tests {
+		if got := hasSignIn(test.user); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// SCHEMA VALIDATION
+// ============================================================================
+
+// Users, chapters and progress carry $jsonSchema validators, so a document
+// missing its key or with an impossible value, such as a negative duration,
+// is rejected by MongoDB however it is written: by a handler bug, a
+// migration or a hand-run shell command. The schemas only hold what every
+// write path already guarantees; the handlers' validation stays the source
+// of field errors. Validation is "moderate": documents stored before a
+// rule existed can still be updated, but not into new violations.
+// SCHEMA_VALIDATION=warn only logs violations in the MongoDB log, and off
+// turns the validators off.
+
+// Schema validation modes, from SCHEMA_VALIDATION
+const (
+	SchemaValidationError = "error"
+	SchemaValidationWarn  = "warn"
+	SchemaValidationOff   = "off"
+)
+
+// collectionSchema is the validator of one collection
+type collectionSchema struct {
+	col    *mongo.Collection
+	schema bson.M
+}
+
+// bsonNumber matches every numeric BSON type; Go ints are stored as int or
+// long depending on their size
+var bsonNumber = bson.A{"int", "long", "double", "decimal"}
+
+// collectionSchemas lists the collections with validators
+func collectionSchemas() []collectionSchema {
+	key := bson.M{"bsonType": "string", "minLength": 1}
+	return []collectionSchema{
+		{usersCol, bson.M{
+			"bsonType": "object",
+			"required": bson.A{"user_id"},
+			"properties": bson.M{
+				"user_id": key,
+				"name":    bson.M{"bsonType": "string"},
+				"email":   bson.M{"bsonType": "string"},
+				"role":    bson.M{"enum": bson.A{RoleLearner, RoleInstructor, RoleAdmin}},
+				"status":  bson.M{"enum": bson.A{UserActive, UserSuspended, UserDeactivated}},
+				"xp":      bson.M{"bsonType": bsonNumber},
+			},
+		}},
+		{chaptersCol, bson.M{
+			"bsonType": "object",
+			"required": bson.A{"chapter_id", "title", "video_url", "duration", "order", "quiz"},
+			"properties": bson.M{
+				"chapter_id": key,
+				"title":      key,
+				"video_url":  key,
+				"duration":   bson.M{"bsonType": bsonNumber, "minimum": 1},
+				"order":      bson.M{"bsonType": bsonNumber, "minimum": 1},
+				"pass_score": bson.M{"bsonType": bsonNumber, "minimum": 1, "maximum": 100},
+				"quiz": bson.M{
+					"bsonType": "object",
+					"required": bson.A{"questions"},
+					"properties": bson.M{
+						"questions": bson.M{"bsonType": "array"},
+					},
+				},
+			},
+		}},
+		{progressCol, bson.M{
+			"bsonType": "object",
+			"required": bson.A{"user_id", "chapter_id"},
+			"properties": bson.M{
+				"user_id":        key,
+				"chapter_id":     key,
+				"video_progress": bson.M{"bsonType": bsonNumber, "minimum": 0},
+				"quiz_progress":  bson.M{"bsonType": bsonNumber, "minimum": 0},
+				"quiz_score":     bson.M{"bsonType": bsonNumber, "minimum": 0, "maximum": 100},
+				"version":        bson.M{"bsonType": bsonNumber, "minimum": 1},
+			},
+		}},
+	}
+}
+
+// applySchemaValidators creates the validated collections with their
+// validators, or updates the validators of existing ones. A failure, such
+// as a user without collMod rights, is logged and leaves the collection
+// as it was.
+func applySchemaValidators(ctx context.Context) {
+	mode := config.SchemaValidation
+	level, action := "moderate", mode
+	if mode == SchemaValidationOff {
+		level, action = "off", SchemaValidationError
+	}
+
+	for _, s := range collectionSchemas() {
+		validator := bson.M{"$jsonSchema": s.schema}
+		err := database.CreateCollection(ctx, s.col.Name(), options.CreateCollection().
+			SetValidator(validator).
+			SetValidationLevel(level).
+			SetValidationAction(action))
+
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceExists" {
+			err = database.RunCommand(ctx, bson.D{
+				{Key: "collMod", Value: s.col.Name()},
+				{Key: "validator", Value: validator},
+				{Key: "validationLevel", Value: level},
+				{Key: "validationAction", Value: action},
+			}).Err()
+		}
+		if err != nil {
+			log.Printf("⚠️ Could not set the schema validator of %s: %v", s.col.Name(), err)
+		}
+	}
+
+	log.Printf("✅ Schema validators applied (%s)", mode)
+}
@@ -0,0 +1,365 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// SEARCH MODELS
+// ============================================================================
+
+// Search runs a MongoDB text search over chapters (title, description and
+// quiz questions) and their subtitles, and groups the hits by chapter. Each
+// result says where the words were found, with a snippet that marks them,
+// and a subtitle hit links to the moment of the video it is spoken.
+// Relevance is MongoDB's text score, summed across a chapter's chapter and
+// subtitle hits.
+
+// Where a search match was found
+const (
+	SearchTitle       = "title"
+	SearchDescription = "description"
+	SearchQuestion    = "question"
+	SearchTranscript  = "transcript"
+)
+
+const (
+	// maxSearchQueryLength bounds ?q=, in characters
+	maxSearchQueryLength = 200
+	// maxTranscriptMatches bounds the subtitle lines listed per chapter
+	// and language
+	maxTranscriptMatches = 3
+	// snippetRadius is how many characters of context a snippet keeps on
+	// each side of the first match
+	snippetRadius = 60
+)
+
+// SearchResult is a chapter that matched a search
+type SearchResult struct {
+	ChapterID string        `json:"chapterId"`
+	PublicID  string        `json:"id"`
+	Title     string        `json:"title"`
+	Score     float64       `json:"score"`
+	Matches   []SearchMatch `json:"matches"`
+}
+
+// SearchMatch is one place in a chapter where the search words appear.
+// Snippet is HTML-escaped with the words wrapped in <mark>.
+type SearchMatch struct {
+	Field      string   `json:"field"`
+	Snippet    string   `json:"snippet"`
+	QuestionID string   `json:"questionId,omitempty"` // question matches
+	Lang       string   `json:"lang,omitempty"`       // transcript matches
+	Start      *float64 `json:"start,omitempty"`      // transcript matches, seconds into the video
+	Link       string   `json:"link"`                 // app path to open the chapter there
+}
+
+// SearchResults are the chapters matching a query, best first
+type SearchResults struct {
+	Query   string         `json:"query"`
+	Total   int            `json:"total"`
+	Results []SearchResult `json:"results"`
+}
+
+// chapterSearchHit is a chapter with its text score
+type chapterSearchHit struct {
+	Chapter `bson:",inline"`
+	Score   float64 `bson:"score"`
+}
+
+// transcriptSearchHit is a transcript with its text score
+type transcriptSearchHit struct {
+	Transcript `bson:",inline"`
+	Score      float64 `bson:"score"`
+}
+
+// ============================================================================
+// SEARCH HANDLERS
+// ============================================================================
+
+// Search finds the chapters whose text or subtitles match ?q=. With
+// ?userId= only chapters of the learner's courses are searched.
+func Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var errs fieldErrors
+	q := strings.TrimSpace(query.Get("q"))
+	errs.required("q", q)
+	if utf8.RuneCountInString(q) > maxSearchQueryLength {
+		errs.add("q", CodeInvalid, fmt.Sprintf("must be at most %d characters", maxSearchQueryLength))
+	}
+	limit := parsePageLimit(r, &errs)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	var visible map[string]bool
+	if userID := query.Get("userId"); userID != "" {
+		enrolled, err := enrolledChapterIDs(ctx, userID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to fetch enrollments")
+			return
+		}
+		visible = enrolled
+	}
+
+	results, err := searchChapters(ctx, q, visible)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Search failed")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Search completed successfully",
+		Data: SearchResults{
+			Query:   q,
+			Total:   len(results),
+			Results: results[:min(limit, len(results))],
+		},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// SEARCH HELPERS
+// ============================================================================
+
+// searchChapters runs q against chapters and transcripts and merges the
+// hits by chapter, best first. visible, when not nil, limits the chapters.
+func searchChapters(ctx context.Context, q string, visible map[string]bool) ([]SearchResult, error) {
+	highlight := searchPattern(q)
+	textSearch := bson.M{"$text": bson.M{"$search": q}}
+	scored := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}})
+
+	byChapter := map[string]*SearchResult{}
+	var order []string
+	result := func(chapter Chapter) *SearchResult {
+		if res, ok := byChapter[chapter.ChapterID]; ok {
+			return res
+		}
+		res := &SearchResult{
+			ChapterID: chapter.ChapterID,
+			PublicID:  chapter.PublicID,
+			Title:     chapter.Title,
+			Matches:   []SearchMatch{},
+		}
+		byChapter[chapter.ChapterID] = res
+		order = append(order, chapter.ChapterID)
+		return res
+	}
+
+	cursor, err := chaptersCol.Find(ctx, liveChapters(ctx, bson.M{"$text": bson.M{"$search": q}}), scored)
+	if err != nil {
+		return nil, err
+	}
+	var chapterHits []chapterSearchHit
+	if err := cursor.All(ctx, &chapterHits); err != nil {
+		return nil, err
+	}
+	for _, hit := range chapterHits {
+		if visible != nil && !visible[hit.ChapterID] {
+			continue
+		}
+		res := result(hit.Chapter)
+		res.Score += hit.Score
+		link := chapterLink(hit.Chapter, nil)
+		if snippet, ok := searchSnippet(hit.Title, highlight); ok {
+			res.Matches = append(res.Matches, SearchMatch{Field: SearchTitle, Snippet: snippet, Link: link})
+		}
+		if snippet, ok := searchSnippet(hit.Description, highlight); ok {
+			res.Matches = append(res.Matches, SearchMatch{Field: SearchDescription, Snippet: snippet, Link: link})
+		}
+		for _, question := range hit.Quiz.Questions {
+			if snippet, ok := searchSnippet(question.QuestionText, highlight); ok {
+				res.Matches = append(res.Matches, SearchMatch{
+					Field:      SearchQuestion,
+					Snippet:    snippet,
+					QuestionID: question.ID,
+					Link:       link,
+				})
+			}
+		}
+	}
+
+	cursor, err = transcriptsCol.Find(ctx, textSearch, scored)
+	if err != nil {
+		return nil, err
+	}
+	var transcriptHits []transcriptSearchHit
+	if err := cursor.All(ctx, &transcriptHits); err != nil {
+		return nil, err
+	}
+	chapters, err := searchHitChapters(ctx, transcriptHits, byChapter, visible)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range transcriptHits {
+		chapter, ok := chapters[hit.ChapterID]
+		if !ok {
+			continue
+		}
+		res := result(chapter)
+		res.Score += hit.Score
+		found := 0
+		for _, segment := range hit.Segments {
+			snippet, ok := searchSnippet(segment.Text, highlight)
+			if !ok {
+				continue
+			}
+			start := segment.Start
+			res.Matches = append(res.Matches, SearchMatch{
+				Field:   SearchTranscript,
+				Snippet: snippet,
+				Lang:    hit.Lang,
+				Start:   &start,
+				Link:    chapterLink(chapter, &start),
+			})
+			if found++; found == maxTranscriptMatches {
+				break
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(order))
+	for _, chapterID := range order {
+		results = append(results, *byChapter[chapterID])
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results, nil
+}
+
+// searchHitChapters loads the chapters of transcript hits that are
+// visible, reusing the ones the chapter search already found
+func searchHitChapters(ctx context.Context, hits []transcriptSearchHit, found map[string]*SearchResult, visible map[string]bool) (map[string]Chapter, error) {
+	chapters := map[string]Chapter{}
+	var missing []string
+	for _, hit := range hits {
+		if visible != nil && !visible[hit.ChapterID] {
+			continue
+		}
+		if res, ok := found[hit.ChapterID]; ok {
+			chapters[hit.ChapterID] = Chapter{ChapterID: res.ChapterID, PublicID: res.PublicID, Title: res.Title}
+		} else {
+			missing = append(missing, hit.ChapterID)
+		}
+	}
+	if len(missing) == 0 {
+		return chapters, nil
+	}
+
+	cursor, err := chaptersCol.Find(ctx, liveChapters(ctx, bson.M{"chapter_id": bson.M{"$in": uniqueStrings(missing)}}),
+		options.Find().SetProjection(bson.M{"chapter_id": 1, "public_id": 1, "title": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var loaded []Chapter
+	if err := cursor.All(ctx, &loaded); err != nil {
+		return nil, err
+	}
+	for _, chapter := range loaded {
+		chapters[chapter.ChapterID] = chapter
+	}
+	return chapters, nil
+}
+
+// searchPattern matches the words of q, ignoring case, as whole words or
+// the start of longer ones, since the text index matches stems. Negated
+// words ("-word") aren't highlighted.
+func searchPattern(q string) *regexp.Regexp {
+	var words []string
+	for _, field := range strings.Fields(q) {
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			// \b only knows ASCII letters, so words starting with another
+			// letter go without it
+			if first, _ := utf8.DecodeRuneInString(word); first < utf8.RuneSelf {
+				words = append(words, `\b`+regexp.QuoteMeta(word))
+			} else {
+				words = append(words, regexp.QuoteMeta(word))
+			}
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	// Longer words first, so "learning" wins over "learn"
+	sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+	return regexp.MustCompile(`(?i)(?:` + strings.Join(words, "|") + `)[\pL\pN]*`)
+}
+
+// searchSnippet cuts text down to the context around its first match and
+// marks every match in it. It reports false when nothing matches.
+func searchSnippet(text string, pattern *regexp.Regexp) (string, bool) {
+	if pattern == nil {
+		return "", false
+	}
+	first := pattern.FindStringIndex(text)
+	if first == nil {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+	prefix, suffix := "", ""
+	if before := text[:first[0]]; utf8.RuneCountInString(before) > snippetRadius {
+		start = first[0] - len(string([]rune(before)[utf8.RuneCountInString(before)-snippetRadius:]))
+		if i := strings.IndexByte(text[start:first[0]], ' '); i >= 0 {
+			start += i + 1
+		}
+		prefix = "…"
+	}
+	if after := text[first[1]:]; utf8.RuneCountInString(after) > snippetRadius {
+		end = first[1] + len(string([]rune(after)[:snippetRadius]))
+		if i := strings.LastIndexByte(text[first[1]:end], ' '); i >= 0 {
+			end = first[1] + i
+		}
+		suffix = "…"
+	}
+	window := text[start:end]
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	last := 0
+	for _, m := range pattern.FindAllStringIndex(window, -1) {
+		b.WriteString(html.EscapeString(window[last:m[0]]))
+		b.WriteString("<mark>" + html.EscapeString(window[m[0]:m[1]]) + "</mark>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(window[last:]))
+	b.WriteString(suffix)
+	return strings.Join(strings.Fields(b.String()), " "), true
+}
+
+// chapterLink is the app path that opens a chapter, at a moment of its
+// video when at is set
+func chapterLink(chapter Chapter, at *float64) string {
+	id := chapter.PublicID
+	if id == "" {
+		id = chapter.ChapterID
+	}
+	link := "/chapters/" + id
+	if at != nil {
+		link += fmt.Sprintf("?t=%d", int(*at))
+	}
+	return link
+}
@@ -0,0 +1,335 @@
+package app
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// SEED DATA
+// ============================================================================
+
+// The canonical seed content is seed/chapters.json, in the chapter import
+// format (see chapter_import.go), so it doubles as an example content file.
+
+//go:embed seed/chapters.json
+var embeddedSeed []byte
+
+// seedChapters is the canonical seed content, keyed by chapter_id
+func seedChapters() []Chapter {
+	reqs, err := parseChapterFile(embeddedSeed, ChapterFileJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid seed/chapters.json: %v", err))
+	}
+	chapters := make([]Chapter, len(reqs))
+	for i, req := range reqs {
+		chapters[i] = Chapter{
+			ChapterID:    req.ChapterID,
+			Title:        req.Title,
+			Description:  req.Description,
+			VideoURL:     req.VideoURL,
+			ThumbnailURL: req.ThumbnailURL,
+			Duration:     req.Duration,
+			Order:        req.Order,
+			Quiz:         req.Quiz,
+		}
+	}
+	return chapters
+}
+
+// ============================================================================
+// SEEDER
+// ============================================================================
+
+// Seed actions, as reported by a reseed plan
+const (
+	SeedCreate    = "create"
+	SeedUpdate    = "update"
+	SeedUnchanged = "unchanged"
+	SeedSkipped   = "skipped" // differs, but --force was not given
+)
+
+// SeedOptions controls a reseed run
+type SeedOptions struct {
+	DryRun bool // report the plan without writing
+	Force  bool // overwrite chapters that already exist and differ
+}
+
+// SeedChange is the planned action for one seed chapter
+type SeedChange struct {
+	ChapterID string
+	Action    string
+	Fields    []string // changed fields for updates and skips
+}
+
+// seedData seeds initial chapter data if not exists
+func seedData() {
+	ctx := context.Background()
+
+	// Check if chapters already exist
+	count, _ := chaptersCol.CountDocuments(ctx, bson.M{})
+	if count > 0 {
+		log.Println("📚 Chapters already exist, skipping seed")
+		return
+	}
+
+	if _, err := reseedChapters(ctx, SeedOptions{}); err != nil {
+		log.Printf("❌ Error seeding chapters: %v", err)
+		return
+	}
+
+	log.Println("✅ Initial chapters seeded successfully")
+}
+
+// reseedChapters upserts the seed content by chapter_id. Missing chapters are
+// always created; existing ones that differ are only overwritten with Force.
+// Only seed-owned fields are written, so admin-managed metadata such as
+// prerequisites, skills and accessibility survives a reseed.
+func reseedChapters(ctx context.Context, opts SeedOptions) ([]SeedChange, error) {
+	var changes []SeedChange
+
+	for _, chapter := range seedChapters() {
+		if err := validateAccessibility(chapter.Accessibility); err != nil {
+			return nil, fmt.Errorf("invalid seed chapter %s: %w", chapter.ChapterID, err)
+		}
+
+		var existing Chapter
+		err := chaptersCol.FindOne(ctx, bson.M{"chapter_id": chapter.ChapterID}).Decode(&existing)
+		if err == mongo.ErrNoDocuments {
+			changes = append(changes, SeedChange{ChapterID: chapter.ChapterID, Action: SeedCreate})
+			if opts.DryRun {
+				continue
+			}
+			if _, err := chaptersCol.InsertOne(ctx, newSeedChapter(chapter)); err != nil {
+				return changes, fmt.Errorf("failed to create %s: %w", chapter.ChapterID, err)
+			}
+			chaptersChanged(ctx, chapter.ChapterID)
+			continue
+		} else if err != nil {
+			return changes, err
+		}
+
+		fields := seedDiff(existing, chapter)
+		switch {
+		case len(fields) == 0:
+			changes = append(changes, SeedChange{ChapterID: chapter.ChapterID, Action: SeedUnchanged})
+			continue
+		case !opts.Force:
+			changes = append(changes, SeedChange{ChapterID: chapter.ChapterID, Action: SeedSkipped, Fields: fields})
+			continue
+		}
+
+		changes = append(changes, SeedChange{ChapterID: chapter.ChapterID, Action: SeedUpdate, Fields: fields})
+		if opts.DryRun {
+			continue
+		}
+
+		// Keep skill tags admins attached to questions that still exist
+		questionSkills := map[string][]string{}
+		for _, q := range existing.Quiz.Questions {
+			questionSkills[q.ID] = q.Skills
+		}
+		for i, q := range chapter.Quiz.Questions {
+			chapter.Quiz.Questions[i].Skills = questionSkills[q.ID]
+		}
+
+		_, err = chaptersCol.UpdateOne(ctx, bson.M{"chapter_id": chapter.ChapterID}, bson.M{"$set": bson.M{
+			"title":         chapter.Title,
+			"description":   chapter.Description,
+			"video_url":     chapter.VideoURL,
+			"thumbnail_url": chapter.ThumbnailURL,
+			"duration":      chapter.Duration,
+			"order":         chapter.Order,
+			"quiz":          chapter.Quiz,
+			"updated_at":    time.Now(),
+		}})
+		if err != nil {
+			return changes, fmt.Errorf("failed to update %s: %w", chapter.ChapterID, err)
+		}
+		chaptersChanged(ctx, chapter.ChapterID)
+	}
+
+	return changes, nil
+}
+
+// newSeedChapter gives a new chapter a public ID and fills its non-seed
+// fields with empty values
+func newSeedChapter(chapter Chapter) Chapter {
+	chapter.PublicID = newPublicID()
+	now := time.Now()
+	chapter.UpdatedAt = &now
+	chapter.Version = 1
+	if chapter.Prerequisites == nil {
+		chapter.Prerequisites = []string{}
+	}
+	if chapter.Skills == nil {
+		chapter.Skills = []string{}
+	}
+	if chapter.Accessibility.CaptionLanguages == nil {
+		chapter.Accessibility.CaptionLanguages = []string{}
+	}
+	if chapter.Accessibility.ContentWarnings == nil {
+		chapter.Accessibility.ContentWarnings = []string{}
+	}
+	return chapter
+}
+
+// seedDiff lists the seed-owned fields where existing differs from seed
+func seedDiff(existing, seed Chapter) []string {
+	var fields []string
+	if existing.Title != seed.Title {
+		fields = append(fields, "title")
+	}
+	if existing.Description != seed.Description {
+		fields = append(fields, "description")
+	}
+	if existing.VideoURL != seed.VideoURL {
+		fields = append(fields, "video_url")
+	}
+	if existing.ThumbnailURL != seed.ThumbnailURL {
+		fields = append(fields, "thumbnail_url")
+	}
+	if existing.Duration != seed.Duration {
+		fields = append(fields, "duration")
+	}
+	if existing.Order != seed.Order {
+		fields = append(fields, "order")
+	}
+	if !reflect.DeepEqual(quizContent(existing.Quiz), quizContent(seed.Quiz)) {
+		fields = append(fields, "quiz")
+	}
+	return fields
+}
+
+// quizContent strips admin-managed tags so only seed content is compared
+func quizContent(quiz Quiz) []Question {
+	questions := make([]Question, len(quiz.Questions))
+	for i, q := range quiz.Questions {
+		q.Skills = nil
+		questions[i] = q
+	}
+	return questions
+}
+
+// ============================================================================
+// SEED COMMAND
+// ============================================================================
+
+// runSeedCommand implements `main seed [--dry-run] [--force] [--allow-production]`
+// and `main seed --seed-file path [--dry-run]`
+func runSeedCommand(args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "show the changes without writing them")
+	force := fs.Bool("force", false, "overwrite existing chapters that differ from the seed")
+	allowProduction := fs.Bool("allow-production", false, "permit --force when APP_ENV=production")
+	seedFile := fs.String("seed-file", "", "import chapters from a .json, .yaml or .yml file instead of the built-in seed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// The memory and Postgres backends seed their own chapters at startup
+	if !backendNeedsMongo() {
+		return fmt.Errorf("nothing to seed in MongoDB; STORAGE_BACKEND=%s seeds its own chapters at startup", config.StorageBackend)
+	}
+	if *seedFile != "" && *force {
+		return fmt.Errorf("--force only applies to the built-in seed; --seed-file always updates")
+	}
+
+	// The file is read before connecting, so a bad one fails fast
+	var chapters []SaveChapterRequest
+	if *seedFile != "" {
+		format, err := chapterFileFormat(*seedFile)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(*seedFile)
+		if err != nil {
+			return err
+		}
+		if chapters, err = parseChapterFile(data, format); err != nil {
+			fe := chapterFileError(err)
+			return fmt.Errorf("%s: %s %s %s", *seedFile, fe.Field, fe.Message, fe.Detail)
+		}
+	}
+
+	if err := InitDB(); err != nil {
+		return err
+	}
+	defer CloseDB()
+
+	// Overwriting learner-visible content in production needs a second, explicit flag
+	if *force && !*dryRun && config.AppEnv == "production" && !*allowProduction {
+		return fmt.Errorf("refusing to force reseed in production without --allow-production")
+	}
+
+	prefix := ""
+	if *dryRun {
+		prefix = "(dry run) "
+	}
+
+	if *seedFile != "" {
+		return runSeedFile(chapters, *dryRun, prefix)
+	}
+
+	changes, err := reseedChapters(context.Background(), SeedOptions{DryRun: *dryRun, Force: *force})
+	if err == nil && !*dryRun {
+		err = ensureDefaultCourses(context.Background())
+	}
+
+	for _, change := range changes {
+		switch change.Action {
+		case SeedCreate:
+			fmt.Printf("%s+ %s: create\n", prefix, change.ChapterID)
+		case SeedUpdate:
+			fmt.Printf("%s~ %s: update %s\n", prefix, change.ChapterID, strings.Join(change.Fields, ", "))
+		case SeedSkipped:
+			fmt.Printf("%s! %s: differs in %s (use --force to overwrite)\n", prefix, change.ChapterID, strings.Join(change.Fields, ", "))
+		case SeedUnchanged:
+			fmt.Printf("%s= %s: unchanged\n", prefix, change.ChapterID)
+		}
+	}
+	return err
+}
+
+// runSeedFile imports the chapters of a --seed-file and prints what changed
+func runSeedFile(chapters []SaveChapterRequest, dryRun bool, prefix string) error {
+	ctx := cliContext("")
+
+	// New chapters go to the default course, which must exist first
+	if !dryRun {
+		if err := ensureDefaultCourses(ctx); err != nil {
+			return err
+		}
+	}
+	result, errs, err := importChapters(ctx, chapters, dryRun)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Printf("%s! %s %s\n", prefix, e.Field, e.Message)
+		}
+		return fmt.Errorf("the file has %d problems; nothing was imported", len(errs))
+	}
+
+	for _, change := range result.Chapters {
+		switch change.Action {
+		case ChapterImportCreated:
+			fmt.Printf("%s+ %s: create\n", prefix, change.ChapterID)
+		case ChapterImportUpdated:
+			fmt.Printf("%s~ %s: update %s\n", prefix, change.ChapterID, strings.Join(change.Fields, ", "))
+		case ChapterImportUnchanged:
+			fmt.Printf("%s= %s: unchanged\n", prefix, change.ChapterID)
+		}
+	}
+	return nil
+}
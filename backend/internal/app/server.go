@@ -0,0 +1,96 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ============================================================================
+// HTTP SERVER
+// ============================================================================
+
+// Connection-level limits. They guard against slow clients; the per-route
+// handler budgets live in timeout.go.
+const (
+	serverReadHeaderTimeout = 10 * time.Second
+	serverReadTimeout       = 30 * time.Second
+	serverIdleTimeout       = 2 * time.Minute
+	defaultShutdownTimeout  = 30 * time.Second
+)
+
+// newServer returns the HTTP server for handler. The write timeout leaves
+// room past the longest route budget, so a slow handler gets its 504 out
+// before the connection is cut.
+func newServer(addr string, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+		ReadTimeout:       serverReadTimeout,
+		WriteTimeout:      longestRouteTimeout() + 10*time.Second,
+		IdleTimeout:       serverIdleTimeout,
+	}
+	// Shutdown doesn't wait for WebSockets, so tell them to reconnect
+	// elsewhere, and it would wait for event streams forever, so end them
+	srv.RegisterOnShutdown(liveProgress.closeAll)
+	srv.RegisterOnShutdown(adminEvents.closeAll)
+	return srv
+}
+
+// runServer serves until SIGINT or SIGTERM, then stops taking connections
+// and waits up to SHUTDOWN_TIMEOUT for in-flight requests to finish. It
+// returns once the server has stopped; the caller closes the database.
+func runServer(srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The listener failed before any signal, e.g. the port is taken
+		return err
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process the usual way
+
+	timeout := config.ShutdownTimeout
+	log.Printf("🛑 Shutting down, draining in-flight requests (up to %v)", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("⚠️ Requests still running after %v, closing their connections: %v", timeout, err)
+		srv.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("✅ Server stopped")
+	return nil
+}
+
+// longestRouteTimeout returns the largest handler budget of any route
+func longestRouteTimeout() time.Duration {
+	longest := config.RequestTimeout
+	for _, timeout := range routeTimeouts {
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+	for _, p := range prefixTimeouts {
+		if p.timeout > longest {
+			longest = p.timeout
+		}
+	}
+	return longest
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// CONTINUE WATCHING
+// ============================================================================
+
+const (
+	defaultShelfLimit = 10
+	maxShelfLimit     = 50
+)
+
+// ContinueWatchingItem is a started but unfinished chapter on the shelf
+type ContinueWatchingItem struct {
+	ChapterID       string    `bson:"chapter_id" json:"chapterId"`
+	Title           string    `bson:"title" json:"title"`
+	ThumbnailURL    string    `bson:"thumbnail_url" json:"thumbnailUrl"`
+	Duration        int       `bson:"duration" json:"duration"`                // in seconds
+	ResumePosition  int       `bson:"resume_position" json:"resumePosition"`   // in seconds
+	PercentComplete int       `bson:"percent_complete" json:"percentComplete"` // of the video
+	VideoCompleted  bool      `bson:"video_completed" json:"videoCompleted"`
+	QuizProgress    int       `bson:"quiz_progress" json:"quizProgress"`
+	LastAccessedAt  time.Time `bson:"last_accessed_at" json:"lastAccessedAt"`
+}
+
+// GetContinueWatching returns the user's incomplete chapters, most recently
+// accessed first, joined with chapter details in a single aggregation
+func GetContinueWatching(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	limit := defaultShelfLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			sendError(w, http.StatusBadRequest, "Limit must be a positive integer")
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxShelfLimit {
+		limit = maxShelfLimit
+	}
+
+	ctx := r.Context()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "chapter_completed": false}}},
+		{{Key: "$sort", Value: bson.D{{Key: "last_accessed_at", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         chaptersCol.Name(),
+			"localField":   "chapter_id",
+			"foreignField": "chapter_id",
+			"as":           "chapter",
+		}}},
+		// Drops progress for chapters that no longer exist
+		{{Key: "$unwind", Value: "$chapter"}},
+		{{Key: "$match", Value: bson.M{"chapter.archived": bson.M{"$ne": true}}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":              0,
+			"chapter_id":       1,
+			"title":            "$chapter.title",
+			"thumbnail_url":    "$chapter.thumbnail_url",
+			"duration":         "$chapter.duration",
+			"resume_position":  "$video_progress",
+			"video_completed":  1,
+			"quiz_progress":    1,
+			"last_accessed_at": 1,
+			"percent_complete": bson.M{"$cond": bson.A{
+				"$video_completed",
+				100,
+				bson.M{"$cond": bson.A{
+					bson.M{"$gt": bson.A{"$chapter.duration", 0}},
+					bson.M{"$min": bson.A{100, bson.M{"$floor": bson.M{"$multiply": bson.A{
+						bson.M{"$divide": bson.A{"$video_progress", "$chapter.duration"}},
+						100,
+					}}}}},
+					0,
+				}},
+			}},
+		}}},
+	}
+
+	cursor, err := progressCol.Aggregate(ctx, pipeline)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch continue watching")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	items := []ContinueWatchingItem{}
+	if err := cursor.All(ctx, &items); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode continue watching")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Continue watching fetched successfully",
+		Data:    items,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
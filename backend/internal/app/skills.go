@@ -0,0 +1,431 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// SKILL MODELS
+// ============================================================================
+
+// masteryThreshold is the mastery below which a skill counts as a gap
+const masteryThreshold = 0.7
+
+// Skill is a node in the skill taxonomy
+type Skill struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID    string             `bson:"public_id,omitempty" json:"id"`
+	SkillID     string             `bson:"skill_id" json:"skillId"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	ParentID    string             `bson:"parent_id,omitempty" json:"parentId,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+type CreateSkillRequest struct {
+	SkillID     string `json:"skillId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ParentID    string `json:"parentId"`
+}
+
+type TagChapterSkillsRequest struct {
+	Skills         []string            `json:"skills"`
+	QuestionSkills map[string][]string `json:"questionSkills"` // question ID -> skill IDs
+}
+
+// SkillMastery is a user's performance on one skill
+type SkillMastery struct {
+	SkillID  string  `json:"skillId"`
+	Name     string  `json:"name"`
+	Answered int     `json:"answered"`
+	Correct  int     `json:"correct"`
+	Mastery  float64 `json:"mastery"` // 0..1, 0 when nothing answered
+}
+
+// Recommendation suggests a chapter that exercises weak skills
+type Recommendation struct {
+	ChapterID string   `json:"chapterId"`
+	Title     string   `json:"title"`
+	Skills    []string `json:"skills"` // the gaps this chapter covers
+	Score     float64  `json:"score"`
+}
+
+// skillIDPattern keeps skill IDs slug-shaped
+var skillIDPattern = regexp.MustCompile(`^[a-z0-9_\-]{2,50}$`)
+
+// ============================================================================
+// SKILL TAXONOMY HANDLERS
+// ============================================================================
+
+// GetSkills returns the skill taxonomy
+func GetSkills(w http.ResponseWriter, r *http.Request) {
+	skills, err := allSkills(r.Context())
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch skills")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Skills fetched successfully",
+		Data:    skills,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateSkill adds a skill to the taxonomy
+func CreateSkill(w http.ResponseWriter, r *http.Request) {
+	var req CreateSkillRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	req.SkillID = strings.TrimSpace(req.SkillID)
+	var errs fieldErrors
+	if !skillIDPattern.MatchString(req.SkillID) {
+		errs.add("skillId", CodeInvalid, "must be 2-50 lowercase letters, digits, dashes or underscores")
+	}
+	errs.required("name", req.Name)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	if req.ParentID != "" {
+		count, err := skillsCol.CountDocuments(ctx, bson.M{"skill_id": req.ParentID})
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if count == 0 {
+			sendError(w, http.StatusBadRequest, "Parent skill does not exist")
+			return
+		}
+	}
+
+	skill := Skill{
+		PublicID:    newPublicID(),
+		SkillID:     req.SkillID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		ParentID:    req.ParentID,
+		CreatedAt:   time.Now(),
+	}
+
+	result, err := skillsCol.InsertOne(ctx, skill)
+	if mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "A skill with this ID already exists")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create skill")
+		return
+	}
+	skill.ID = result.InsertedID.(primitive.ObjectID)
+
+	log.Printf("✅ Skill created: %s", skill.SkillID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Skill created successfully",
+		Data:    skill,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// TagChapterSkills sets the skills a chapter and its questions exercise
+func TagChapterSkills(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+
+	var req TagChapterSkillsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Skills == nil {
+		req.Skills = []string{}
+	}
+
+	ctx := r.Context()
+
+	var chapter Chapter
+	err := chaptersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID})).Decode(&chapter)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	skills, err := allSkills(ctx)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	known := make(map[string]bool, len(skills))
+	for _, skill := range skills {
+		known[skill.SkillID] = true
+	}
+
+	for _, id := range req.Skills {
+		if !known[id] {
+			sendError(w, http.StatusBadRequest, "Unknown skill: "+id)
+			return
+		}
+	}
+
+	set := bson.M{"skills": req.Skills, "updated_at": time.Now()}
+	for questionID, questionSkills := range req.QuestionSkills {
+		index := -1
+		for i, q := range chapter.Quiz.Questions {
+			if q.ID == questionID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			sendError(w, http.StatusBadRequest, "Unknown question: "+questionID)
+			return
+		}
+		for _, id := range questionSkills {
+			if !known[id] {
+				sendError(w, http.StatusBadRequest, "Unknown skill: "+id)
+				return
+			}
+		}
+		if questionSkills == nil {
+			questionSkills = []string{}
+		}
+		set["quiz.questions."+strconv.Itoa(index)+".skills"] = questionSkills
+	}
+
+	err = chaptersCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}), bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&chapter)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to tag chapter skills")
+		return
+	}
+
+	chapterWritten(ctx, chapter)
+	log.Printf("✅ Skills tagged: chapter=%s", chapterID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Chapter skills updated successfully",
+		Data:    chapter,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// MASTERY HANDLERS
+// ============================================================================
+
+// GetSkillsRadar returns the user's mastery of every skill in the taxonomy
+func GetSkillsRadar(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	mastery, _, err := computeSkillMastery(r.Context(), userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to compute skill mastery")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Skill mastery fetched successfully",
+		Data:    mastery,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetRecommendations suggests incomplete chapters that cover the user's
+// weakest skills, strongest gap coverage first
+func GetRecommendations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	mastery, chapters, err := computeSkillMastery(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to compute skill mastery")
+		return
+	}
+
+	// Gap size per skill: how far below the threshold the user is
+	gaps := map[string]float64{}
+	for _, m := range mastery {
+		if m.Mastery < masteryThreshold {
+			gaps[m.SkillID] = masteryThreshold - m.Mastery
+		}
+	}
+
+	cursor, err := progressCol.Find(ctx, bson.M{"user_id": userID, "chapter_completed": true})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch progress")
+		return
+	}
+	var completedProgress []Progress
+	if err := cursor.All(ctx, &completedProgress); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode progress")
+		return
+	}
+	completed := map[string]bool{}
+	for _, p := range completedProgress {
+		completed[p.ChapterID] = true
+	}
+
+	recommendations := []Recommendation{}
+	for _, chapter := range chapters {
+		if completed[chapter.ChapterID] {
+			continue
+		}
+		rec := Recommendation{ChapterID: chapter.ChapterID, Title: chapter.Title, Skills: []string{}}
+		for _, skillID := range chapterSkillSet(chapter) {
+			if gap, ok := gaps[skillID]; ok {
+				rec.Skills = append(rec.Skills, skillID)
+				rec.Score += gap
+			}
+		}
+		if len(rec.Skills) > 0 {
+			recommendations = append(recommendations, rec)
+		}
+	}
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Recommendations fetched successfully",
+		Data:    recommendations,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// SKILL HELPERS
+// ============================================================================
+
+func allSkills(ctx context.Context) ([]Skill, error) {
+	cursor, err := skillsCol.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	skills := []Skill{}
+	if err := cursor.All(ctx, &skills); err != nil {
+		return nil, err
+	}
+	return skills, nil
+}
+
+// chapterSkillSet is the union of a chapter's skills and its questions' skills
+func chapterSkillSet(chapter Chapter) []string {
+	seen := map[string]bool{}
+	var skills []string
+	add := func(ids []string) {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				skills = append(skills, id)
+			}
+		}
+	}
+	add(chapter.Skills)
+	for _, q := range chapter.Quiz.Questions {
+		add(q.Skills)
+	}
+	return skills
+}
+
+// computeSkillMastery scores each skill by the share of the user's answers
+// to questions tagged with it that were correct. Questions without their own
+// tags count toward the chapter's skills. Also returns all chapters.
+func computeSkillMastery(ctx context.Context, userID string) ([]SkillMastery, []Chapter, error) {
+	skills, err := allSkills(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cursor, err := chaptersCol.Find(ctx, liveChapters(ctx, bson.M{}), options.Find().SetSort(bson.D{{Key: "order", Value: 1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	var chapters []Chapter
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return nil, nil, err
+	}
+	chapterByID := make(map[string]Chapter, len(chapters))
+	for _, chapter := range chapters {
+		chapterByID[chapter.ChapterID] = chapter
+	}
+
+	cursor, err = progressCol.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, nil, err
+	}
+	var progress []Progress
+	if err := cursor.All(ctx, &progress); err != nil {
+		return nil, nil, err
+	}
+
+	answered := map[string]int{}
+	correct := map[string]int{}
+	for _, p := range progress {
+		chapter, ok := chapterByID[p.ChapterID]
+		if !ok {
+			continue
+		}
+		for i, answer := range p.QuizAnswers {
+			if !answer.answered() || i >= len(chapter.Quiz.Questions) {
+				continue
+			}
+			question := chapter.Quiz.Questions[i]
+			tags := question.Skills
+			if len(tags) == 0 {
+				tags = chapter.Skills
+			}
+			for _, skillID := range tags {
+				answered[skillID]++
+				if question.isCorrect(answer) {
+					correct[skillID]++
+				}
+			}
+		}
+	}
+
+	mastery := make([]SkillMastery, 0, len(skills))
+	for _, skill := range skills {
+		m := SkillMastery{
+			SkillID:  skill.SkillID,
+			Name:     skill.Name,
+			Answered: answered[skill.SkillID],
+			Correct:  correct[skill.SkillID],
+		}
+		if m.Answered > 0 {
+			m.Mastery = float64(m.Correct) / float64(m.Answered)
+		}
+		mastery = append(mastery, m)
+	}
+	return mastery, chapters, nil
+}
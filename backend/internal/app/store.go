@@ -0,0 +1,319 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================================================
+// STORES
+// ============================================================================
+
+// The core entities (users, chapters, progress), login sessions and
+// idempotency keys are read and written through these interfaces rather
+// than through their collections, so the handlers and services don't
+// depend on how they are stored. Stores scope reads and writes to the
+// request's organization the way tenantFilter does.
+
+// Errors every store returns in place of its backend's own
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("duplicate key")
+	// ErrVersionConflict is returned by a progress write that expected a
+	// version other than the stored one: another device wrote in between
+	ErrVersionConflict = errors.New("version conflict")
+)
+
+// UserStore stores learner accounts
+type UserStore interface {
+	// Get returns a user of the request's organization
+	Get(ctx context.Context, userID string) (User, error)
+	// FindOrCreate returns the user with user.UserID, inserting user if
+	// there is none, and reports whether it was inserted. It returns
+	// ErrDuplicate when the user ID belongs to another organization.
+	FindOrCreate(ctx context.Context, user User) (User, bool, error)
+	// AddXP adds xp to the user's total in one atomic step and returns the
+	// new total
+	AddXP(ctx context.Context, userID string, xp int) (int, error)
+}
+
+// ChapterStore stores the chapter catalog. Both methods only see the
+// chapters the request's organization does: the shared catalog and its own
+// (see liveChapters).
+type ChapterStore interface {
+	Get(ctx context.Context, chapterID string) (Chapter, error)
+	// List returns the chapters in query.ChapterIDs, or all of them when
+	// it is nil, in query.Sort order
+	List(ctx context.Context, query ChapterQuery) ([]Chapter, error)
+}
+
+// ProgressStore stores learners' per-chapter progress. SaveVideo, SaveQuiz
+// and SaveQuizAnswer bump the record's Version; when given an expected
+// version other than 0 they write only over that version, and otherwise
+// return ErrVersionConflict without writing. Writes the server derives,
+// such as the quiz result, seed and draw, and bookmarks, which only add,
+// leave the version alone.
+type ProgressStore interface {
+	Get(ctx context.Context, userID, chapterID string) (Progress, error)
+	// List returns one page of a user's progress and the total across pages
+	List(ctx context.Context, query ProgressQuery) ([]Progress, int64, error)
+	// SaveVideo writes the video fields and ChapterCompleted of p, creating
+	// the record if needed. Entries of p.FieldUpdatedAt and
+	// p.DevicePositions are merged into the stored ones. p.Version is the
+	// expected version.
+	SaveVideo(ctx context.Context, p Progress) (SaveResult, error)
+	// SaveQuiz writes the quiz fields and ChapterCompleted of p, creating
+	// the record if needed, merging p.FieldUpdatedAt like SaveVideo
+	SaveQuiz(ctx context.Context, p Progress) (SaveResult, error)
+	// SaveQuizAnswer records one quiz answer in a single atomic step, so
+	// concurrent answers can't overwrite each other: it fits the stored
+	// answers to the quiz, sets this one, the quiz position and completion,
+	// and starts a new attempt clock when there is none or the last attempt
+	// was finished. It returns the progress as it was before, zero if there
+	// was none.
+	SaveQuizAnswer(ctx context.Context, a QuizAnswer) (Progress, SaveResult, error)
+	// SaveQuizResult records the score of a finished attempt, as a percent,
+	// and whether it passed, and in the same atomic step completes the
+	// chapter if it passed and the video is completed. A completed chapter
+	// stays completed. It returns the progress as it was before, or
+	// ErrNotFound if there is none.
+	SaveQuizResult(ctx context.Context, userID, chapterID string, score int, passed bool) (Progress, error)
+	// SaveQuizSeed gives the current quiz attempt the seed that orders a
+	// shuffled quiz, unless it already has one, creating the record if
+	// needed. It returns the progress as stored.
+	SaveQuizSeed(ctx context.Context, userID, chapterID string, seed int64) (Progress, error)
+	// SaveQuizDraw gives the current quiz attempt the bank questions it
+	// drew, unless it already has some, creating the record if needed. It
+	// returns the progress as stored.
+	SaveQuizDraw(ctx context.Context, userID, chapterID string, questionIDs []string) (Progress, error)
+	// AddBookmark appends b to the chapter's bookmarks unless it already has
+	// maxBookmarks of them, in one atomic step, creating the record if
+	// needed. It returns the progress as stored, without b when it was full.
+	AddBookmark(ctx context.Context, userID, chapterID string, b Bookmark, maxBookmarks int) (Progress, error)
+	// DeleteBookmark removes one of the chapter's bookmarks, or returns
+	// ErrNotFound if it has none with that ID
+	DeleteBookmark(ctx context.Context, userID, chapterID, bookmarkID string) error
+	// DeleteForUser removes all of a user's progress and returns the count
+	DeleteForUser(ctx context.Context, userID string) (int64, error)
+}
+
+// SessionStore stores the sessions logins open; see auth_sessions.go. A
+// session is open until it is revoked or expires.
+type SessionStore interface {
+	// Start revokes the user's open sessions on session.Device, so logging
+	// in again doesn't pile them up, and inserts session
+	Start(ctx context.Context, session AuthSession) error
+	// Active reports whether the session with the public ID is open
+	Active(ctx context.Context, sessionID string) (bool, error)
+	// Rotate moves the open session whose refresh token hashes to hash on to
+	// newHash, keeping hash to spot its reuse, marks it used at now and
+	// extends it to expiresAt. It returns the session as stored, or
+	// ErrNotFound if no open session has the hash.
+	Rotate(ctx context.Context, hash, newHash string, now, expiresAt time.Time) (AuthSession, error)
+	// RevokeReused revokes the session whose previous refresh token hashes
+	// to hash and returns it, or ErrNotFound if there is none
+	RevokeReused(ctx context.Context, hash string, now time.Time) (AuthSession, error)
+	// List returns the user's open sessions, most recently used first
+	List(ctx context.Context, userID string) ([]AuthSession, error)
+	// Revoke revokes one of the user's sessions and returns it, or
+	// ErrNotFound if the user has no such session that isn't revoked
+	Revoke(ctx context.Context, userID, sessionID string, now time.Time) (AuthSession, error)
+	// RevokeForUser revokes all of the user's sessions, signing them out
+	// everywhere
+	RevokeForUser(ctx context.Context, userID string, now time.Time) error
+}
+
+// IdempotencyStore keeps the responses of requests sent with an
+// Idempotency-Key; see idempotency.go
+type IdempotencyStore interface {
+	// Claim stores claim unless a record with its ID holds the key: one that
+	// hasn't expired, and whose request has finished or was claimed after
+	// staleBefore. It reports whether it stored claim, and returns the
+	// holding record when it didn't.
+	Claim(ctx context.Context, claim IdempotencyRecord, staleBefore time.Time) (IdempotencyRecord, bool, error)
+	// Finish keeps the response of the claimed key's request
+	Finish(ctx context.Context, id string, status int, contentType string, body []byte) error
+	// Release frees a claimed key whose request hasn't finished
+	Release(ctx context.Context, id string) error
+}
+
+// ChapterQuery selects chapters for ChapterStore.List
+type ChapterQuery struct {
+	ChapterIDs []string // nil for all chapters
+	Sort       bson.D   // stored field names, 1 or -1
+}
+
+// ProgressQuery selects progress for ProgressStore.List
+type ProgressQuery struct {
+	UserID     string
+	ChapterIDs []string // only these chapters
+	Completed  *bool    // only chapters with this completion state
+	Sort       bson.D   // stored field names, 1 or -1
+	Page       offsetPage
+}
+
+// QuizAnswer is an answer for ProgressStore.SaveQuizAnswer
+type QuizAnswer struct {
+	UserID        string
+	ChapterID     string
+	QuestionIndex int
+	Answer        Answer
+	Completed     bool
+	Questions     int       // how many questions the quiz has
+	At            time.Time // when it was answered
+	Version       int       // the expected version, 0 for any
+}
+
+// apply records the answer on p the way SaveQuizAnswer describes, for
+// stores that do it in Go under a lock
+func (a QuizAnswer) apply(p *Progress) {
+	p.QuizAnswers = sizeQuizAnswers(p.QuizAnswers, a.Questions)
+	p.QuizAnswers[a.QuestionIndex] = a.Answer
+	p.QuizProgress = a.QuestionIndex
+	if p.QuizStartedAt == nil || p.QuizCompleted {
+		at := a.At
+		p.QuizStartedAt = &at
+	}
+	p.QuizCompleted = a.Completed
+	p.FieldUpdatedAt = a.fieldTimes()
+}
+
+// applyQuizResult records a finished attempt on p the way SaveQuizResult
+// describes
+func applyQuizResult(p *Progress, score int, passed bool) {
+	p.QuizScore = &score
+	p.QuizPassed = passed
+	p.ChapterCompleted = p.ChapterCompleted || (p.VideoCompleted && passed)
+}
+
+// fieldTimes are the field times the answer writes
+func (a QuizAnswer) fieldTimes() map[string]time.Time {
+	return map[string]time.Time{
+		FieldQuizProgress:                a.At,
+		FieldQuizCompleted:               a.At,
+		quizAnswerField(a.QuestionIndex): a.At,
+	}
+}
+
+// SaveResult reports what a progress write did
+type SaveResult struct {
+	Matched  int64 `json:"matched"`
+	Modified int64 `json:"modified"`
+	Upserted int64 `json:"upserted"`
+	Version  int   `json:"version"` // of the record after the write
+}
+
+// The stores in use, set up by setupStores
+var (
+	userStore        UserStore
+	chapterStore     ChapterStore
+	progressStore    ProgressStore
+	sessionStore     SessionStore
+	idempotencyStore IdempotencyStore
+)
+
+// Storage backends, chosen with STORAGE_BACKEND (or DATABASE_DRIVER)
+const (
+	StorageMongo    = "mongo"
+	StorageMemory   = "memory"
+	StoragePostgres = "postgres"
+)
+
+// backendNeedsMongo reports whether the storage backend keeps anything in
+// MongoDB. The memory and Postgres backends keep everything the stores
+// hold themselves and run without it.
+func backendNeedsMongo() bool {
+	return config.StorageBackend == "" || config.StorageBackend == StorageMongo
+}
+
+// mongoConnected reports whether InitDB connected to MongoDB. Without it,
+// only storeRoutes are served.
+func mongoConnected() bool {
+	return database != nil
+}
+
+// ErrCodeMongoRequired is the code of a request for a route that needs
+// MongoDB when the server runs without it
+const ErrCodeMongoRequired = "mongo_required"
+
+// storeRoutes are served through the stores alone, keyed like
+// routeTimeouts: logins and their sessions, the chapters and learners'
+// progress, and the probes and docs. They are what the memory and Postgres
+// backends serve.
+var storeRoutes = map[string]bool{
+	"GET /livez":                             true,
+	"GET /readyz":                            true,
+	"GET /api/health":                        true,
+	"GET /api/health/deep":                   true,
+	"GET /api/openapi.json":                  true,
+	"GET /api/docs":                          true,
+	"POST /api/login":                        true,
+	"POST /api/token/refresh":                true,
+	"GET /api/sessions":                      true,
+	"DELETE /api/sessions/{sessionId}":       true,
+	"GET /api/chapters":                      true,
+	"GET /api/chapters/{chapterId}":          true,
+	"GET /api/progress/{userId}":             true,
+	"GET /api/progress/{userId}/{chapterId}": true,
+	"POST /api/progress/video":               true,
+	"POST /api/progress/quiz":                true,
+}
+
+// StoreRoutesMiddleware answers 501 for the routes that need MongoDB when
+// the server runs without it, instead of letting them fail on a collection
+// that was never opened
+func StoreRoutesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mongoConnected() && !storeRoutes[routeKey(r)] {
+			sendErrorCode(w, http.StatusNotImplemented, ErrCodeMongoRequired,
+				"This route needs MongoDB, which STORAGE_BACKEND="+config.StorageBackend+" runs without")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setupStores picks the stores for STORAGE_BACKEND, MongoDB by default
+func setupStores() error {
+	switch backend := config.StorageBackend; backend {
+	case "", StorageMongo:
+		userStore = mongoUserStore{}
+		chapterStore = mongoChapterStore{}
+		progressStore = mongoProgressStore{}
+		sessionStore = mongoSessionStore{}
+		idempotencyStore = mongoIdempotencyStore{}
+	case StorageMemory:
+		chapters := []Chapter{}
+		for _, chapter := range seedChapters() {
+			chapters = append(chapters, newSeedChapter(chapter))
+		}
+		userStore = newMemUserStore()
+		chapterStore = newMemChapterStore(chapters)
+		progressStore = newMemProgressStore()
+		sessionStore = newMemSessionStore()
+		idempotencyStore = newMemIdempotencyStore()
+		log.Println("⚠️ Using in-memory storage; nothing is kept across restarts")
+	case StoragePostgres:
+		db, err := openPostgres()
+		if err != nil {
+			return err
+		}
+		postgresDB = db
+		userStore = pgUserStore{db}
+		chapterStore = pgChapterStore{db}
+		progressStore = pgProgressStore{db}
+		sessionStore = pgSessionStore{db}
+		idempotencyStore = pgIdempotencyStore{db}
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+	chapterStore = setupChapterCache(chapterStore)
+	progressService = &ProgressService{Progress: progressStore, Chapters: chapterStore}
+	return nil
+}
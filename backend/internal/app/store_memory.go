@@ -0,0 +1,630 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================================================
+// IN-MEMORY STORES
+// ============================================================================
+
+// The memory stores keep users, chapters, progress, sessions and
+// idempotency keys in process memory.
+// They are what STORAGE_BACKEND=memory runs on, and the fake for tests of
+// code written against the store interfaces. Nothing survives a restart.
+
+// memUserStore keeps users by user ID
+type memUserStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// memChapterStore keeps chapters by chapter ID
+type memChapterStore struct {
+	mu       sync.RWMutex
+	chapters map[string]Chapter
+}
+
+// memProgressStore keeps progress by user and chapter ID. seq stands in for
+// the insertion order Mongo's _id gives.
+type memProgressStore struct {
+	mu       sync.RWMutex
+	progress map[[2]string]memProgress
+	nextSeq  int64
+}
+
+type memProgress struct {
+	Progress
+	seq int64
+}
+
+// memSessionStore keeps auth sessions by public ID
+type memSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]AuthSession
+}
+
+// memIdempotencyStore keeps idempotency records by ID. Expired ones are
+// replaced when their key is claimed again rather than swept.
+type memIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+}
+
+func newMemUserStore() *memUserStore {
+	return &memUserStore{users: map[string]User{}}
+}
+
+// newMemChapterStore returns a chapter store holding chapters
+func newMemChapterStore(chapters []Chapter) *memChapterStore {
+	s := &memChapterStore{chapters: map[string]Chapter{}}
+	for _, chapter := range chapters {
+		s.chapters[chapter.ChapterID] = chapter
+	}
+	return s
+}
+
+func newMemProgressStore() *memProgressStore {
+	return &memProgressStore{progress: map[[2]string]memProgress{}}
+}
+
+func newMemSessionStore() *memSessionStore {
+	return &memSessionStore{sessions: map[string]AuthSession{}}
+}
+
+func newMemIdempotencyStore() *memIdempotencyStore {
+	return &memIdempotencyStore{records: map[string]IdempotencyRecord{}}
+}
+
+// inTenant reports whether a record of orgID is visible to the request, the
+// way tenantFilter scopes a query
+func inTenant(ctx context.Context, orgID string) bool {
+	t, ok := requestTenant(ctx)
+	return !ok || t.orgID == "" || t.orgID == orgID
+}
+
+func (s *memUserStore) Get(ctx context.Context, userID string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok || !inTenant(ctx, user.OrgID) {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *memUserStore) FindOrCreate(ctx context.Context, user User) (User, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.users[user.UserID]; ok {
+		if existing.OrgID != user.OrgID {
+			return User{}, false, ErrDuplicate
+		}
+		return existing, false, nil
+	}
+	s.users[user.UserID] = user
+	return user, true, nil
+}
+
+func (s *memUserStore) AddXP(ctx context.Context, userID string, xp int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || !inTenant(ctx, user.OrgID) {
+		return 0, ErrNotFound
+	}
+	user.XP += xp
+	user.UpdatedAt = time.Now()
+	s.users[userID] = user
+	return user.XP, nil
+}
+
+func (s *memChapterStore) Get(ctx context.Context, chapterID string) (Chapter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chapter, ok := s.chapters[chapterID]
+	if !ok || !sharedOrOwnChapter(ctx, chapter) {
+		return Chapter{}, ErrNotFound
+	}
+	return chapter, nil
+}
+
+func (s *memChapterStore) List(ctx context.Context, query ChapterQuery) ([]Chapter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chapters := []Chapter{}
+	if query.ChapterIDs == nil {
+		for _, chapter := range s.chapters {
+			if sharedOrOwnChapter(ctx, chapter) {
+				chapters = append(chapters, chapter)
+			}
+		}
+	} else {
+		for _, id := range uniqueStrings(query.ChapterIDs) {
+			if chapter, ok := s.chapters[id]; ok && sharedOrOwnChapter(ctx, chapter) {
+				chapters = append(chapters, chapter)
+			}
+		}
+	}
+
+	sortBy(chapters, query.Sort, func(c Chapter, field string) interface{} {
+		switch field {
+		case "order":
+			return c.Order
+		case "title":
+			return c.Title
+		case "duration":
+			return c.Duration
+		}
+		return c.ChapterID
+	})
+	return chapters, nil
+}
+
+func (s *memProgressStore) Get(ctx context.Context, userID, chapterID string) (Progress, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.progress[[2]string{userID, chapterID}]
+	if !ok || !inTenant(ctx, p.OrgID) {
+		return Progress{}, ErrNotFound
+	}
+	return p.copy(), nil
+}
+
+func (s *memProgressStore) List(ctx context.Context, query ProgressQuery) ([]Progress, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := []memProgress{}
+	for _, chapterID := range uniqueStrings(query.ChapterIDs) {
+		p, ok := s.progress[[2]string{query.UserID, chapterID}]
+		if !ok || !inTenant(ctx, p.OrgID) {
+			continue
+		}
+		if query.Completed != nil && p.ChapterCompleted != *query.Completed {
+			continue
+		}
+		matched = append(matched, p)
+	}
+
+	sortBy(matched, query.Sort, func(p memProgress, field string) interface{} {
+		switch field {
+		case "chapter_id":
+			return p.ChapterID
+		case "last_accessed_at":
+			return p.LastAccessedAt
+		case "updated_at":
+			return p.UpdatedAt
+		case "video_progress":
+			return p.VideoProgress
+		}
+		return p.seq
+	})
+
+	start, end := query.Page.bounds(len(matched))
+	progress := make([]Progress, 0, end-start)
+	for _, p := range matched[start:end] {
+		progress = append(progress, p.copy())
+	}
+	return progress, int64(len(matched)), nil
+}
+
+func (s *memProgressStore) SaveVideo(ctx context.Context, p Progress) (SaveResult, error) {
+	return s.upsert(ctx, p, true, func(stored *Progress) {
+		stored.VideoProgress = p.VideoProgress
+		stored.VideoCompleted = p.VideoCompleted
+		stored.ChapterCompleted = p.ChapterCompleted
+		if len(p.DevicePositions) > 0 {
+			positions := make(map[string]DevicePosition, len(stored.DevicePositions)+len(p.DevicePositions))
+			for device, position := range stored.DevicePositions {
+				positions[device] = position
+			}
+			for device, position := range p.DevicePositions {
+				positions[device] = position
+			}
+			stored.DevicePositions = positions
+		}
+	})
+}
+
+func (s *memProgressStore) SaveQuiz(ctx context.Context, p Progress) (SaveResult, error) {
+	return s.upsert(ctx, p, true, func(stored *Progress) {
+		stored.QuizProgress = p.QuizProgress
+		stored.QuizAnswers = append([]Answer{}, p.QuizAnswers...)
+		stored.QuizCompleted = p.QuizCompleted
+		stored.QuizStartedAt = p.QuizStartedAt
+		stored.ChapterCompleted = p.ChapterCompleted
+	})
+}
+
+// upsert applies set to p's record, creating it first if needed, and bumps
+// its version if bump is set. An expected version needs the record to exist
+// at that version.
+func (s *memProgressStore) upsert(ctx context.Context, p Progress, bump bool, set func(*Progress)) (SaveResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{p.UserID, p.ChapterID}
+	stored, ok := s.progress[key]
+	ok = ok && inTenant(ctx, stored.OrgID)
+	if p.Version != 0 && (!ok || stored.Version != p.Version) {
+		return SaveResult{}, ErrVersionConflict
+	}
+	result := SaveResult{Matched: 1}
+	if !ok {
+		s.nextSeq++
+		stored = memProgress{
+			Progress: Progress{
+				PublicID:    newPublicID(),
+				OrgID:       orgID(ctx),
+				UserID:      p.UserID,
+				ChapterID:   p.ChapterID,
+				QuizAnswers: []Answer{},
+				Version:     1,
+			},
+			seq: s.nextSeq,
+		}
+		result = SaveResult{Upserted: 1}
+	}
+
+	// Every save moves the timestamps, so a matched record is modified
+	if result.Matched == 1 {
+		result.Modified = 1
+		if bump {
+			stored.Version++
+		}
+	}
+	set(&stored.Progress)
+	if len(p.FieldUpdatedAt) > 0 {
+		fieldTimes := make(map[string]time.Time, len(stored.FieldUpdatedAt)+len(p.FieldUpdatedAt))
+		for field, at := range stored.FieldUpdatedAt {
+			fieldTimes[field] = at
+		}
+		for field, at := range p.FieldUpdatedAt {
+			fieldTimes[field] = at
+		}
+		stored.FieldUpdatedAt = fieldTimes
+	}
+	now := time.Now()
+	stored.LastAccessedAt = now
+	stored.UpdatedAt = now
+	s.progress[key] = stored
+	result.Version = stored.Version
+	return result, nil
+}
+
+func (s *memProgressStore) SaveQuizAnswer(ctx context.Context, a QuizAnswer) (Progress, SaveResult, error) {
+	// upsert holds the lock while set runs, which makes this atomic
+	var previous Progress
+	p := Progress{UserID: a.UserID, ChapterID: a.ChapterID, FieldUpdatedAt: a.fieldTimes(), Version: a.Version}
+	result, err := s.upsert(ctx, p, true, func(stored *Progress) {
+		previous = memProgress{Progress: *stored}.copy()
+		fieldTimes := stored.FieldUpdatedAt
+		a.apply(stored)
+		// upsert merges the answer's field times in after this
+		stored.FieldUpdatedAt = fieldTimes
+	})
+	if err != nil {
+		return Progress{}, SaveResult{}, err
+	}
+	if result.Upserted == 1 {
+		previous = Progress{}
+	}
+	return previous, result, nil
+}
+
+func (s *memProgressStore) SaveQuizResult(ctx context.Context, userID, chapterID string, score int, passed bool) (Progress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{userID, chapterID}
+	stored, ok := s.progress[key]
+	if !ok || !inTenant(ctx, stored.OrgID) {
+		return Progress{}, ErrNotFound
+	}
+	previous := stored.copy()
+	applyQuizResult(&stored.Progress, score, passed)
+	stored.UpdatedAt = time.Now()
+	s.progress[key] = stored
+	return previous, nil
+}
+
+func (s *memProgressStore) SaveQuizSeed(ctx context.Context, userID, chapterID string, seed int64) (Progress, error) {
+	var saved Progress
+	_, err := s.upsert(ctx, Progress{UserID: userID, ChapterID: chapterID}, false, func(stored *Progress) {
+		if stored.QuizSeed == 0 {
+			stored.QuizSeed = seed
+		}
+		saved = memProgress{Progress: *stored}.copy()
+	})
+	return saved, err
+}
+
+func (s *memProgressStore) SaveQuizDraw(ctx context.Context, userID, chapterID string, questionIDs []string) (Progress, error) {
+	var saved Progress
+	_, err := s.upsert(ctx, Progress{UserID: userID, ChapterID: chapterID}, false, func(stored *Progress) {
+		if len(stored.QuizQuestionIDs) == 0 {
+			stored.QuizQuestionIDs = append([]string{}, questionIDs...)
+		}
+		saved = memProgress{Progress: *stored}.copy()
+	})
+	return saved, err
+}
+
+func (s *memProgressStore) AddBookmark(ctx context.Context, userID, chapterID string, b Bookmark, maxBookmarks int) (Progress, error) {
+	var saved Progress
+	_, err := s.upsert(ctx, Progress{UserID: userID, ChapterID: chapterID}, false, func(stored *Progress) {
+		if len(stored.Bookmarks) < maxBookmarks {
+			stored.Bookmarks = append(append([]Bookmark{}, stored.Bookmarks...), b)
+		}
+		saved = memProgress{Progress: *stored}.copy()
+	})
+	return saved, err
+}
+
+func (s *memProgressStore) DeleteBookmark(ctx context.Context, userID, chapterID, bookmarkID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]string{userID, chapterID}
+	stored, ok := s.progress[key]
+	if !ok || !inTenant(ctx, stored.OrgID) {
+		return ErrNotFound
+	}
+	for i, b := range stored.Bookmarks {
+		if b.ID == bookmarkID {
+			bookmarks := append([]Bookmark{}, stored.Bookmarks[:i]...)
+			stored.Bookmarks = append(bookmarks, stored.Bookmarks[i+1:]...)
+			stored.UpdatedAt = time.Now()
+			s.progress[key] = stored
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *memProgressStore) DeleteForUser(ctx context.Context, userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, p := range s.progress {
+		if key[0] == userID && inTenant(ctx, p.OrgID) {
+			delete(s.progress, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// open reports whether the session is neither revoked nor expired
+func (s AuthSession) open(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+func (s *memSessionStore) Start(ctx context.Context, session AuthSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[session.PublicID]; ok {
+		return ErrDuplicate
+	}
+	for id, other := range s.sessions {
+		if other.UserID == session.UserID && other.Device == session.Device && other.RevokedAt == nil {
+			at := session.CreatedAt
+			other.RevokedAt = &at
+			s.sessions[id] = other
+		}
+	}
+	s.sessions[session.PublicID] = session
+	return nil
+}
+
+func (s *memSessionStore) Active(ctx context.Context, sessionID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	return ok && session.open(time.Now()), nil
+}
+
+func (s *memSessionStore) Rotate(ctx context.Context, hash, newHash string, now, expiresAt time.Time) (AuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.RefreshTokenHash == hash && session.open(now) {
+			session.RefreshTokenHash = newHash
+			session.PreviousTokenHash = hash
+			session.LastUsedAt = now
+			session.ExpiresAt = expiresAt
+			s.sessions[id] = session
+			return session, nil
+		}
+	}
+	return AuthSession{}, ErrNotFound
+}
+
+func (s *memSessionStore) RevokeReused(ctx context.Context, hash string, now time.Time) (AuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.PreviousTokenHash == hash && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.sessions[id] = session
+			return session, nil
+		}
+	}
+	return AuthSession{}, ErrNotFound
+}
+
+func (s *memSessionStore) List(ctx context.Context, userID string) ([]AuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	sessions := []AuthSession{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.open(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sortBy(sessions, bson.D{{Key: "last_used_at", Value: -1}}, func(s AuthSession, field string) interface{} {
+		return s.LastUsedAt
+	})
+	return sessions, nil
+}
+
+func (s *memSessionStore) Revoke(ctx context.Context, userID, sessionID string, now time.Time) (AuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return AuthSession{}, ErrNotFound
+	}
+	session.RevokedAt = &now
+	s.sessions[sessionID] = session
+	return session, nil
+}
+
+func (s *memSessionStore) RevokeForUser(ctx context.Context, userID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+func (s *memIdempotencyStore) Claim(ctx context.Context, claim IdempotencyRecord, staleBefore time.Time) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[claim.ID]
+	if ok && !record.ExpiresAt.Before(claim.CreatedAt) && (record.Status != 0 || !record.CreatedAt.Before(staleBefore)) {
+		return record, false, nil
+	}
+	s.records[claim.ID] = claim
+	return IdempotencyRecord{}, true, nil
+}
+
+func (s *memIdempotencyStore) Finish(ctx context.Context, id string, status int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[id]; ok {
+		record.Status, record.ContentType = status, contentType
+		record.Body = append([]byte(nil), body...)
+		s.records[id] = record
+	}
+	return nil
+}
+
+func (s *memIdempotencyStore) Release(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[id]; ok && record.Status == 0 {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+// copy returns the progress without sharing its answers, field times,
+// device positions or bookmarks with the store
+func (p memProgress) copy() Progress {
+	progress := p.Progress
+	progress.QuizAnswers = append([]Answer{}, p.QuizAnswers...)
+	if p.QuizQuestionIDs != nil {
+		progress.QuizQuestionIDs = append([]string{}, p.QuizQuestionIDs...)
+	}
+	if p.FieldUpdatedAt != nil {
+		progress.FieldUpdatedAt = make(map[string]time.Time, len(p.FieldUpdatedAt))
+		for field, at := range p.FieldUpdatedAt {
+			progress.FieldUpdatedAt[field] = at
+		}
+	}
+	if p.DevicePositions != nil {
+		progress.DevicePositions = make(map[string]DevicePosition, len(p.DevicePositions))
+		for device, position := range p.DevicePositions {
+			progress.DevicePositions[device] = position
+		}
+	}
+	if p.Bookmarks != nil {
+		progress.Bookmarks = append([]Bookmark{}, p.Bookmarks...)
+	}
+	return progress
+}
+
+// uniqueStrings returns ids without repeats, in their first order
+func uniqueStrings(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// sortBy orders items by a Mongo-style sort, reading each field with value
+func sortBy[T any](items []T, order bson.D, value func(T, string) interface{}) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, key := range order {
+			c := compareValues(value(items[i], key.Key), value(items[j], key.Key))
+			if c == 0 {
+				continue
+			}
+			if dir, _ := key.Value.(int); dir < 0 {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// compareValues compares two values of the same sortable type
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		return compareOrdered(a, b.(int))
+	case int64:
+		return compareOrdered(a, b.(int64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func compareOrdered[T int | int64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,519 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// MONGO STORES
+// ============================================================================
+
+// mongoUserStore stores users in usersCol
+type mongoUserStore struct{}
+
+// mongoChapterStore stores chapters in chaptersCol
+type mongoChapterStore struct{}
+
+// mongoProgressStore stores progress in progressCol
+type mongoProgressStore struct{}
+
+// mongoSessionStore stores auth sessions in authSessionsCol
+type mongoSessionStore struct{}
+
+// mongoIdempotencyStore stores idempotency keys in idempotencyKeysCol
+type mongoIdempotencyStore struct{}
+
+// storeError translates driver errors into store errors
+func storeError(err error) error {
+	switch {
+	case err == mongo.ErrNoDocuments:
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (mongoUserStore) Get(ctx context.Context, userID string) (User, error) {
+	var user User
+	err := usersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"user_id": userID})).Decode(&user)
+	return user, storeError(err)
+}
+
+// FindOrCreate upserts, so concurrent first logins don't race; the one whose
+// public ID ends up on the document is the one that created it
+func (mongoUserStore) FindOrCreate(ctx context.Context, user User) (User, bool, error) {
+	var found User
+	err := retryOnDuplicateKey(func() error {
+		return usersCol.FindOneAndUpdate(ctx,
+			bson.M{"user_id": user.UserID, "org_id": user.OrgID},
+			bson.M{"$setOnInsert": user},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&found)
+	})
+	if err != nil {
+		return User{}, false, storeError(err)
+	}
+	return found, found.PublicID == user.PublicID, nil
+}
+
+func (mongoUserStore) AddXP(ctx context.Context, userID string, xp int) (int, error) {
+	var user User
+	err := usersCol.FindOneAndUpdate(ctx,
+		tenantFilter(ctx, bson.M{"user_id": userID}),
+		bson.M{
+			"$inc": bson.M{"xp": xp},
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"xp": 1})).Decode(&user)
+	return user.XP, storeError(err)
+}
+
+func (mongoChapterStore) Get(ctx context.Context, chapterID string) (Chapter, error) {
+	var chapter Chapter
+	err := chaptersCol.FindOne(ctx, liveChapters(ctx, bson.M{"chapter_id": chapterID})).Decode(&chapter)
+	return chapter, storeError(err)
+}
+
+func (mongoChapterStore) List(ctx context.Context, query ChapterQuery) ([]Chapter, error) {
+	filter := bson.M{}
+	if query.ChapterIDs != nil {
+		filter["chapter_id"] = bson.M{"$in": query.ChapterIDs}
+	}
+
+	cursor, err := chaptersCol.Find(ctx, liveChapters(ctx, filter), options.Find().SetSort(query.Sort))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	chapters := []Chapter{}
+	if err := cursor.All(ctx, &chapters); err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+func (mongoProgressStore) Get(ctx context.Context, userID, chapterID string) (Progress, error) {
+	var progress Progress
+	err := progressCol.FindOne(ctx, tenantFilter(ctx, bson.M{
+		"user_id":    userID,
+		"chapter_id": chapterID,
+	})).Decode(&progress)
+	return progress, storeError(err)
+}
+
+func (mongoProgressStore) List(ctx context.Context, query ProgressQuery) ([]Progress, int64, error) {
+	filter := bson.M{"user_id": query.UserID, "chapter_id": bson.M{"$in": query.ChapterIDs}}
+	if query.Completed != nil {
+		filter["chapter_completed"] = *query.Completed
+	}
+	filter = tenantFilter(ctx, filter)
+
+	total, err := progressCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(query.Sort).SetSkip(int64(query.Page.Offset))
+	if query.Page.Limit > 0 {
+		opts.SetLimit(int64(query.Page.Limit))
+	}
+	cursor, err := progressCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	progress := []Progress{}
+	if err := cursor.All(ctx, &progress); err != nil {
+		return nil, 0, err
+	}
+	return progress, total, nil
+}
+
+func (s mongoProgressStore) SaveVideo(ctx context.Context, p Progress) (SaveResult, error) {
+	fields := bson.M{
+		"video_progress":    p.VideoProgress,
+		"video_completed":   p.VideoCompleted,
+		"chapter_completed": p.ChapterCompleted,
+	}
+	// Only the devices in p move; the others keep their positions
+	for device, position := range p.DevicePositions {
+		fields["device_positions."+device] = position
+	}
+	return s.upsert(ctx, p, fields,
+		bson.M{
+			"quiz_progress":  0,
+			"quiz_answers":   []int{},
+			"quiz_completed": false,
+		})
+}
+
+func (s mongoProgressStore) SaveQuiz(ctx context.Context, p Progress) (SaveResult, error) {
+	return s.upsert(ctx, p,
+		bson.M{
+			"quiz_progress":     p.QuizProgress,
+			"quiz_answers":      p.QuizAnswers,
+			"quiz_completed":    p.QuizCompleted,
+			"quiz_started_at":   p.QuizStartedAt,
+			"chapter_completed": p.ChapterCompleted,
+		},
+		bson.M{
+			"video_progress":  0,
+			"video_completed": false,
+		})
+}
+
+// upsert sets fields on p's record, or inserts it with defaults as well.
+// An expected version needs the record to exist at that version.
+func (mongoProgressStore) upsert(ctx context.Context, p Progress, fields, defaults bson.M) (SaveResult, error) {
+	filter := tenantFilter(ctx, bson.M{
+		"user_id":    p.UserID,
+		"chapter_id": p.ChapterID,
+	})
+	if p.Version != 0 {
+		filter["version"] = p.Version
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"user_id":          p.UserID,
+		"chapter_id":       p.ChapterID,
+		"last_accessed_at": now,
+		"updated_at":       now,
+	}
+	for k, v := range fields {
+		set[k] = v
+	}
+	for field, at := range p.FieldUpdatedAt {
+		set["field_updated_at."+field] = at
+	}
+	defaults["public_id"] = newPublicID()
+	defaults["org_id"] = orgID(ctx)
+	update := bson.M{"$set": set, "$setOnInsert": defaults, "$inc": bson.M{"version": 1}}
+
+	// Concurrent first writes for the same chapter can both try to insert;
+	// the loser retries and updates the document the winner created
+	var previous Progress
+	opts := options.FindOneAndUpdate().
+		SetUpsert(p.Version == 0).
+		SetReturnDocument(options.Before).
+		SetProjection(bson.M{"version": 1})
+	err := retryOnDuplicateKey(func() error {
+		previous = Progress{}
+		return progressCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	})
+	if err == mongo.ErrNoDocuments {
+		if p.Version != 0 {
+			return SaveResult{}, ErrVersionConflict
+		}
+		return SaveResult{Upserted: 1, Version: 1}, nil
+	} else if err != nil {
+		return SaveResult{}, err
+	}
+	// Every save moves the timestamps, so a matched record is modified
+	return SaveResult{Matched: 1, Modified: 1, Version: previous.Version + 1}, nil
+}
+
+func (mongoProgressStore) SaveQuizAnswer(ctx context.Context, a QuizAnswer) (Progress, SaveResult, error) {
+	filter := tenantFilter(ctx, bson.M{
+		"user_id":    a.UserID,
+		"chapter_id": a.ChapterID,
+	})
+	if a.Version != 0 {
+		filter["version"] = a.Version
+	}
+
+	// The answers fitted to the quiz with this one set: the stored answer
+	// for each question, -1 past the end of what was stored
+	stored := bson.M{"$ifNull": bson.A{"$quiz_answers", bson.A{}}}
+	answers := bson.M{"$map": bson.M{
+		"input": bson.M{"$range": bson.A{0, a.Questions}},
+		"as":    "i",
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$$i", a.QuestionIndex}},
+			// Literal, so a fill-in answer like "$x" isn't read as a field
+			bson.M{"$literal": a.Answer},
+			bson.M{"$cond": bson.A{
+				bson.M{"$lt": bson.A{"$$i", bson.M{"$size": stored}}},
+				bson.M{"$arrayElemAt": bson.A{stored, "$$i"}},
+				-1,
+			}},
+		}},
+	}}
+	// The first answer of an attempt starts its clock
+	startedAt := bson.M{"$cond": bson.A{
+		bson.M{"$or": bson.A{bson.M{"$not": bson.A{"$quiz_started_at"}}, bson.M{"$eq": bson.A{"$quiz_completed", true}}}},
+		a.At,
+		"$quiz_started_at",
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"user_id":           bson.M{"$literal": a.UserID},
+		"chapter_id":        bson.M{"$literal": a.ChapterID},
+		"public_id":         bson.M{"$ifNull": bson.A{"$public_id", newPublicID()}},
+		"org_id":            bson.M{"$ifNull": bson.A{"$org_id", bson.M{"$literal": orgID(ctx)}}},
+		"video_progress":    bson.M{"$ifNull": bson.A{"$video_progress", 0}},
+		"video_completed":   bson.M{"$ifNull": bson.A{"$video_completed", false}},
+		"quiz_answers":      answers,
+		"quiz_progress":     a.QuestionIndex,
+		"quiz_completed":    a.Completed,
+		"quiz_started_at":   startedAt,
+		"chapter_completed": bson.M{"$ifNull": bson.A{"$chapter_completed", false}},
+		"last_accessed_at":  a.At,
+		"updated_at":        a.At,
+		"version":           bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		"field_updated_at": bson.M{"$mergeObjects": bson.A{
+			bson.M{"$ifNull": bson.A{"$field_updated_at", bson.M{}}},
+			a.fieldTimes(),
+		}},
+	}}}}
+
+	var previous Progress
+	opts := options.FindOneAndUpdate().SetUpsert(a.Version == 0).SetReturnDocument(options.Before)
+	err := retryOnDuplicateKey(func() error {
+		previous = Progress{}
+		return progressCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	})
+	if err == mongo.ErrNoDocuments {
+		if a.Version != 0 {
+			return Progress{}, SaveResult{}, ErrVersionConflict
+		}
+		return Progress{}, SaveResult{Upserted: 1, Version: 1}, nil
+	} else if err != nil {
+		return Progress{}, SaveResult{}, err
+	}
+	return previous, SaveResult{Matched: 1, Modified: 1, Version: previous.Version + 1}, nil
+}
+
+func (mongoProgressStore) SaveQuizResult(ctx context.Context, userID, chapterID string, score int, passed bool) (Progress, error) {
+	filter := tenantFilter(ctx, bson.M{
+		"user_id":    userID,
+		"chapter_id": chapterID,
+	})
+	// Retaking the quiz doesn't undo a completed chapter
+	chapterCompleted := bson.M{"$or": bson.A{
+		bson.M{"$eq": bson.A{"$chapter_completed", true}},
+		bson.M{"$and": bson.A{bson.M{"$eq": bson.A{"$video_completed", true}}, passed}},
+	}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"quiz_score":        score,
+		"quiz_passed":       passed,
+		"chapter_completed": chapterCompleted,
+		"updated_at":        time.Now(),
+	}}}}
+
+	var previous Progress
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err := progressCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		return Progress{}, ErrNotFound
+	}
+	return previous, err
+}
+
+func (mongoProgressStore) SaveQuizSeed(ctx context.Context, userID, chapterID string, seed int64) (Progress, error) {
+	// Concurrent requests agree on whichever seed was stored first
+	return saveProgressField(ctx, userID, chapterID, "quiz_seed", bson.M{"$cond": bson.A{
+		bson.M{"$ne": bson.A{bson.M{"$ifNull": bson.A{"$quiz_seed", 0}}, 0}},
+		"$quiz_seed",
+		seed,
+	}})
+}
+
+func (mongoProgressStore) SaveQuizDraw(ctx context.Context, userID, chapterID string, questionIDs []string) (Progress, error) {
+	// Concurrent requests agree on whichever draw was stored first
+	return saveProgressField(ctx, userID, chapterID, "quiz_question_ids", bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$quiz_question_ids", bson.A{}}}}, 0}},
+		"$quiz_question_ids",
+		bson.M{"$literal": questionIDs},
+	}})
+}
+
+func (mongoProgressStore) AddBookmark(ctx context.Context, userID, chapterID string, b Bookmark, maxBookmarks int) (Progress, error) {
+	// Checking the count in the same update keeps concurrent adds from
+	// passing the limit together
+	bookmarks := bson.M{"$ifNull": bson.A{"$bookmarks", bson.A{}}}
+	return saveProgressField(ctx, userID, chapterID, "bookmarks", bson.M{"$cond": bson.A{
+		bson.M{"$lt": bson.A{bson.M{"$size": bookmarks}, maxBookmarks}},
+		bson.M{"$concatArrays": bson.A{bookmarks, bson.A{bson.M{"$literal": b}}}},
+		bookmarks,
+	}})
+}
+
+func (mongoProgressStore) DeleteBookmark(ctx context.Context, userID, chapterID, bookmarkID string) error {
+	filter := tenantFilter(ctx, bson.M{
+		"user_id":      userID,
+		"chapter_id":   chapterID,
+		"bookmarks.id": bookmarkID,
+	})
+	update := bson.M{
+		"$pull": bson.M{"bookmarks": bson.M{"id": bookmarkID}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	result, err := progressCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// saveProgressField sets one field to the result of an aggregation
+// expression, creating the record if needed, and returns the progress as
+// stored
+func saveProgressField(ctx context.Context, userID, chapterID, field string, value bson.M) (Progress, error) {
+	filter := tenantFilter(ctx, bson.M{
+		"user_id":    userID,
+		"chapter_id": chapterID,
+	})
+
+	now := time.Now()
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"user_id":           bson.M{"$literal": userID},
+		"chapter_id":        bson.M{"$literal": chapterID},
+		"public_id":         bson.M{"$ifNull": bson.A{"$public_id", newPublicID()}},
+		"org_id":            bson.M{"$ifNull": bson.A{"$org_id", bson.M{"$literal": orgID(ctx)}}},
+		"video_progress":    bson.M{"$ifNull": bson.A{"$video_progress", 0}},
+		"video_completed":   bson.M{"$ifNull": bson.A{"$video_completed", false}},
+		"quiz_answers":      bson.M{"$ifNull": bson.A{"$quiz_answers", bson.A{}}},
+		"quiz_progress":     bson.M{"$ifNull": bson.A{"$quiz_progress", 0}},
+		"quiz_completed":    bson.M{"$ifNull": bson.A{"$quiz_completed", false}},
+		"chapter_completed": bson.M{"$ifNull": bson.A{"$chapter_completed", false}},
+		"version":           bson.M{"$ifNull": bson.A{"$version", 1}},
+		field:               value,
+		"last_accessed_at":  now,
+		"updated_at":        now,
+	}}}}
+
+	var saved Progress
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := retryOnDuplicateKey(func() error {
+		saved = Progress{}
+		return progressCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&saved)
+	})
+	return saved, err
+}
+
+func (mongoProgressStore) DeleteForUser(ctx context.Context, userID string) (int64, error) {
+	result, err := progressCol.DeleteMany(ctx, tenantFilter(ctx, bson.M{"user_id": userID}))
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// openSessions filters the sessions that are neither revoked nor expired
+func openSessions(filter bson.M) bson.M {
+	filter["revoked_at"] = bson.M{"$exists": false}
+	filter["expires_at"] = bson.M{"$gt": time.Now()}
+	return filter
+}
+
+func (mongoSessionStore) Start(ctx context.Context, session AuthSession) error {
+	_, err := authSessionsCol.UpdateMany(ctx,
+		bson.M{"user_id": session.UserID, "device": session.Device, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": session.CreatedAt}})
+	if err != nil {
+		return err
+	}
+	_, err = authSessionsCol.InsertOne(ctx, session)
+	return storeError(err)
+}
+
+func (mongoSessionStore) Active(ctx context.Context, sessionID string) (bool, error) {
+	count, err := authSessionsCol.CountDocuments(ctx, openSessions(bson.M{"public_id": sessionID}))
+	return count > 0, err
+}
+
+func (mongoSessionStore) Rotate(ctx context.Context, hash, newHash string, now, expiresAt time.Time) (AuthSession, error) {
+	var session AuthSession
+	err := authSessionsCol.FindOneAndUpdate(ctx,
+		openSessions(bson.M{"refresh_token_hash": hash}),
+		bson.M{"$set": bson.M{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": hash,
+			"last_used_at":        now,
+			"expires_at":          expiresAt,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&session)
+	return session, storeError(err)
+}
+
+func (mongoSessionStore) RevokeReused(ctx context.Context, hash string, now time.Time) (AuthSession, error) {
+	var session AuthSession
+	err := authSessionsCol.FindOneAndUpdate(ctx,
+		bson.M{"previous_token_hash": hash, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now}}).Decode(&session)
+	return session, storeError(err)
+}
+
+func (mongoSessionStore) List(ctx context.Context, userID string) ([]AuthSession, error) {
+	cursor, err := authSessionsCol.Find(ctx, openSessions(bson.M{"user_id": userID}),
+		options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []AuthSession{}
+	err = cursor.All(ctx, &sessions)
+	return sessions, err
+}
+
+func (mongoSessionStore) Revoke(ctx context.Context, userID, sessionID string, now time.Time) (AuthSession, error) {
+	var session AuthSession
+	err := authSessionsCol.FindOneAndUpdate(ctx,
+		bson.M{"public_id": sessionID, "user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&session)
+	return session, storeError(err)
+}
+
+func (mongoSessionStore) RevokeForUser(ctx context.Context, userID string, now time.Time) error {
+	_, err := authSessionsCol.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now}})
+	return err
+}
+
+// Claim upserts over a free record, so two requests can't both claim a key:
+// the loser's upsert hits the unique _id and fails
+func (mongoIdempotencyStore) Claim(ctx context.Context, claim IdempotencyRecord, staleBefore time.Time) (IdempotencyRecord, bool, error) {
+	// An expired key the TTL index hasn't removed yet is free too
+	filter := bson.M{"_id": claim.ID, "$or": bson.A{
+		bson.M{"expires_at": bson.M{"$lt": claim.CreatedAt}},
+		bson.M{"status": 0, "created_at": bson.M{"$lt": staleBefore}},
+	}}
+	_, err := idempotencyKeysCol.ReplaceOne(ctx, filter, claim, options.Replace().SetUpsert(true))
+	if err == nil {
+		return IdempotencyRecord{}, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return IdempotencyRecord{}, false, err
+	}
+
+	var record IdempotencyRecord
+	err = idempotencyKeysCol.FindOne(ctx, bson.M{"_id": claim.ID}).Decode(&record)
+	return record, false, storeError(err)
+}
+
+func (mongoIdempotencyStore) Finish(ctx context.Context, id string, status int, contentType string, body []byte) error {
+	_, err := idempotencyKeysCol.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+	}})
+	return err
+}
+
+func (mongoIdempotencyStore) Release(ctx context.Context, id string) error {
+	_, err := idempotencyKeysCol.DeleteOne(ctx, bson.M{"_id": id, "status": 0})
+	return err
+}
@@ -0,0 +1,897 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the pgx driver
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================================================
+// POSTGRES STORES
+// ============================================================================
+
+// The Postgres stores keep users, chapters, quiz questions, progress, login
+// sessions and idempotency keys in tables created by the migrations in
+// migrations/postgres. They talk to the
+// database through database/sql with the pgx driver.
+
+// postgresDriver is the database/sql driver the stores open
+const postgresDriver = "pgx"
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// postgresDB is the open Postgres connection pool, if the stores use one
+var postgresDB *sql.DB
+
+type pgUserStore struct{ db *sql.DB }
+
+// pgChapterStore holds the seed chapters, the shared catalog. Chapters of an
+// organization are written through the admin routes, which need MongoDB.
+type pgChapterStore struct{ db *sql.DB }
+
+type pgProgressStore struct{ db *sql.DB }
+
+type pgSessionStore struct{ db *sql.DB }
+
+type pgIdempotencyStore struct{ db *sql.DB }
+
+// chapterColumns and progressColumns map the stored field names a sort
+// uses to columns
+var (
+	chapterColumns = map[string]string{
+		"chapter_id": "chapter_id",
+		"order":      `"order"`,
+		"title":      "title",
+		"duration":   "duration",
+	}
+	progressColumns = map[string]string{
+		"_id":              "id",
+		"chapter_id":       "chapter_id",
+		"last_accessed_at": "last_accessed_at",
+		"updated_at":       "updated_at",
+		"video_progress":   "video_progress",
+	}
+)
+
+// openPostgres connects to DATABASE_URL and brings its schema up to date
+func openPostgres() (*sql.DB, error) {
+	url := config.DatabaseURL
+	if url == "" {
+		return nil, errors.New("DATABASE_URL is required for the postgres backend")
+	}
+
+	db, err := sql.Open(postgresDriver, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Postgres: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping Postgres: %w", err)
+	}
+	if err := migratePostgres(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := seedPostgresChapters(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Println("✅ Connected to Postgres successfully")
+	return db, nil
+}
+
+// migratePostgres applies the migrations not yet recorded in
+// schema_migrations, in file name order, each in its own transaction
+func migratePostgres(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(postgresMigrations, "migrations/postgres/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(name[strings.LastIndex(name, "/")+1:], ".sql")
+
+		var applied bool
+		err := db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if applied {
+			continue
+		}
+
+		script, err := postgresMigrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %s failed: %w", version, err)
+		}
+		log.Printf("✅ Applied Postgres migration %s", version)
+	}
+	return nil
+}
+
+// seedPostgresChapters inserts the built-in chapters into an empty table
+func seedPostgresChapters(ctx context.Context, db *sql.DB) error {
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM chapters`).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count chapters: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, chapter := range seedChapters() {
+		if err := insertPostgresChapter(ctx, tx, newSeedChapter(chapter)); err != nil {
+			return fmt.Errorf("failed to seed chapter %s: %w", chapter.ChapterID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Println("✅ Initial chapters seeded successfully")
+	return nil
+}
+
+// insertPostgresChapter writes a chapter and its quiz questions
+func insertPostgresChapter(ctx context.Context, tx *sql.Tx, chapter Chapter) error {
+	_, err := tx.ExecContext(ctx, `INSERT INTO chapters
+		(chapter_id, public_id, title, description, video_url, thumbnail_url, duration, "order",
+		 prerequisites, skills, accessibility, pass_score, quiz_shuffle, quiz_draw)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		chapter.ChapterID, chapter.PublicID, chapter.Title, chapter.Description, chapter.VideoURL,
+		chapter.ThumbnailURL, chapter.Duration, chapter.Order,
+		jsonValue(chapter.Prerequisites), jsonValue(chapter.Skills), jsonValue(chapter.Accessibility), chapter.PassScore,
+		chapter.Quiz.Shuffle, jsonValue(chapter.Quiz.Draw))
+	if err != nil {
+		return err
+	}
+	for i, q := range chapter.Quiz.Questions {
+		_, err := tx.ExecContext(ctx, `INSERT INTO quizzes
+			(chapter_id, position, question_id, type, question_text, options, correct_answer,
+			 correct_answers, accepted_answers, skills, explanation)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			chapter.ChapterID, i, q.ID, q.Type, q.QuestionText, jsonValue(q.Options), q.CorrectAnswer,
+			jsonValue(q.CorrectAnswers), jsonValue(q.AcceptedAnswers), jsonValue(q.Skills), q.Explanation)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tenantClause scopes a WHERE clause to the request's organization the way
+// tenantFilter scopes a Mongo filter
+func tenantClause(ctx context.Context, where string, args []interface{}) (string, []interface{}) {
+	if t, ok := requestTenant(ctx); ok && t.orgID != "" {
+		args = append(args, t.orgID)
+		where += fmt.Sprintf(" AND org_id = $%d", len(args))
+	}
+	return where, args
+}
+
+// orderBy turns a Mongo-style sort into an ORDER BY list of known columns
+func orderBy(order bson.D, columns map[string]string) string {
+	terms := []string{}
+	for _, key := range order {
+		column, ok := columns[key.Key]
+		if !ok {
+			continue
+		}
+		if dir, _ := key.Value.(int); dir < 0 {
+			column += " DESC"
+		}
+		terms = append(terms, column)
+	}
+	if len(terms) == 0 {
+		return ""
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// jsonValue encodes v for a JSONB column, with nil slices as []
+func jsonValue(v interface{}) string {
+	b, _ := json.Marshal(nonNil(reflect.ValueOf(v)).Interface())
+	return string(b)
+}
+
+// sqlError translates database/sql errors into store errors
+func sqlError(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s pgUserStore) Get(ctx context.Context, userID string) (User, error) {
+	where, args := tenantClause(ctx, `user_id = $1`, []interface{}{userID})
+	return scanUser(s.db.QueryRowContext(ctx, userSelect+` WHERE `+where, args...))
+}
+
+const userSelect = `SELECT data, guest_secret_hash FROM users`
+
+// scanUser reads a row of userSelect
+func scanUser(row *sql.Row) (User, error) {
+	var data []byte
+	var secretHash sql.NullString
+	if err := row.Scan(&data, &secretHash); err != nil {
+		return User{}, sqlError(err)
+	}
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return User{}, err
+	}
+	user.GuestSecretHash = secretHash.String
+	return user, nil
+}
+
+func (s pgUserStore) FindOrCreate(ctx context.Context, user User) (User, bool, error) {
+	result, err := s.db.ExecContext(ctx, `INSERT INTO users (user_id, public_id, org_id, data, guest_secret_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7) ON CONFLICT (user_id) DO NOTHING`,
+		user.UserID, user.PublicID, user.OrgID, jsonValue(user), user.GuestSecretHash, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return User{}, false, err
+	}
+	if inserted, err := result.RowsAffected(); err == nil && inserted == 1 {
+		return user, true, nil
+	}
+
+	existing, err := scanUser(s.db.QueryRowContext(ctx, userSelect+` WHERE user_id = $1`, user.UserID))
+	if err != nil {
+		return User{}, false, err
+	}
+	// User IDs are unique across organizations
+	if existing.OrgID != user.OrgID {
+		return User{}, false, ErrDuplicate
+	}
+	return existing, false, nil
+}
+
+func (s pgUserStore) AddXP(ctx context.Context, userID string, xp int) (int, error) {
+	where, args := tenantClause(ctx, `user_id = $1`, []interface{}{userID, xp, time.Now()})
+	var total int
+	err := s.db.QueryRowContext(ctx, `UPDATE users
+		SET data = jsonb_set(data, '{xp}', to_jsonb(COALESCE((data->>'xp')::int, 0) + $2)), updated_at = $3
+		WHERE `+where+` RETURNING (data->>'xp')::int`, args...).Scan(&total)
+	return total, sqlError(err)
+}
+
+const chapterSelect = `SELECT chapter_id, public_id, title, description, video_url, thumbnail_url,
+	duration, "order", prerequisites, skills, accessibility, pass_score, quiz_shuffle, quiz_draw FROM chapters`
+
+func (s pgChapterStore) Get(ctx context.Context, chapterID string) (Chapter, error) {
+	chapters, err := s.query(ctx, chapterSelect+` WHERE chapter_id = $1`, chapterID)
+	if err != nil {
+		return Chapter{}, err
+	}
+	if len(chapters) == 0 {
+		return Chapter{}, ErrNotFound
+	}
+	return chapters[0], nil
+}
+
+func (s pgChapterStore) List(ctx context.Context, query ChapterQuery) ([]Chapter, error) {
+	if query.ChapterIDs == nil {
+		return s.query(ctx, chapterSelect+orderBy(query.Sort, chapterColumns))
+	}
+	return s.query(ctx, chapterSelect+` WHERE chapter_id IN (SELECT jsonb_array_elements_text($1::jsonb))`+
+		orderBy(query.Sort, chapterColumns), jsonValue(query.ChapterIDs))
+}
+
+// query reads chapters and their quiz questions
+func (s pgChapterStore) query(ctx context.Context, query string, args ...interface{}) ([]Chapter, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chapters := []Chapter{}
+	byID := map[string]int{}
+	for rows.Next() {
+		var c Chapter
+		var prerequisites, skills, accessibility, draw []byte
+		err := rows.Scan(&c.ChapterID, &c.PublicID, &c.Title, &c.Description, &c.VideoURL, &c.ThumbnailURL,
+			&c.Duration, &c.Order, &prerequisites, &skills, &accessibility, &c.PassScore, &c.Quiz.Shuffle, &draw)
+		if err != nil {
+			return nil, err
+		}
+		err = unmarshalColumns(prerequisites, &c.Prerequisites, skills, &c.Skills, accessibility, &c.Accessibility,
+			draw, &c.Quiz.Draw)
+		if err != nil {
+			return nil, err
+		}
+		c.Quiz.Questions = []Question{}
+		byID[c.ChapterID] = len(chapters)
+		chapters = append(chapters, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(chapters) == 0 {
+		return chapters, nil
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	rows, err = s.db.QueryContext(ctx, `SELECT chapter_id, question_id, type, question_text, options, correct_answer,
+		correct_answers, accepted_answers, skills, explanation
+		FROM quizzes WHERE chapter_id IN (SELECT jsonb_array_elements_text($1::jsonb))
+		ORDER BY chapter_id, position`, jsonValue(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chapterID string
+		var q Question
+		var options, correctAnswers, acceptedAnswers, skills []byte
+		err := rows.Scan(&chapterID, &q.ID, &q.Type, &q.QuestionText, &options, &q.CorrectAnswer,
+			&correctAnswers, &acceptedAnswers, &skills, &q.Explanation)
+		if err != nil {
+			return nil, err
+		}
+		err = unmarshalColumns(options, &q.Options, correctAnswers, &q.CorrectAnswers,
+			acceptedAnswers, &q.AcceptedAnswers, skills, &q.Skills)
+		if err != nil {
+			return nil, err
+		}
+		c := &chapters[byID[chapterID]]
+		c.Quiz.Questions = append(c.Quiz.Questions, q)
+	}
+	return chapters, rows.Err()
+}
+
+const progressSelect = `SELECT public_id, org_id, user_id, chapter_id, video_progress, video_completed,
+	quiz_progress, quiz_answers, quiz_completed, quiz_started_at, quiz_score, quiz_passed, quiz_seed, quiz_question_ids,
+	chapter_completed, last_accessed_at, updated_at, version, field_updated_at, device_positions, bookmarks FROM progress`
+
+func (s pgProgressStore) Get(ctx context.Context, userID, chapterID string) (Progress, error) {
+	where, args := tenantClause(ctx, `user_id = $1 AND chapter_id = $2`, []interface{}{userID, chapterID})
+	progress, err := s.query(ctx, progressSelect+` WHERE `+where, args...)
+	if err != nil {
+		return Progress{}, err
+	}
+	if len(progress) == 0 {
+		return Progress{}, ErrNotFound
+	}
+	return progress[0], nil
+}
+
+func (s pgProgressStore) List(ctx context.Context, query ProgressQuery) ([]Progress, int64, error) {
+	where := `user_id = $1 AND chapter_id IN (SELECT jsonb_array_elements_text($2::jsonb))`
+	args := []interface{}{query.UserID, jsonValue(query.ChapterIDs)}
+	if query.Completed != nil {
+		args = append(args, *query.Completed)
+		where += fmt.Sprintf(" AND chapter_completed = $%d", len(args))
+	}
+	where, args = tenantClause(ctx, where, args)
+
+	var total int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM progress WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page := fmt.Sprintf(" OFFSET %d", query.Page.Offset)
+	if query.Page.Limit > 0 {
+		page += fmt.Sprintf(" LIMIT %d", query.Page.Limit)
+	}
+	progress, err := s.query(ctx, progressSelect+` WHERE `+where+orderBy(query.Sort, progressColumns)+page, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return progress, total, nil
+}
+
+func (s pgProgressStore) SaveVideo(ctx context.Context, p Progress) (SaveResult, error) {
+	return s.upsert(ctx, p, map[string]interface{}{
+		"video_progress":    p.VideoProgress,
+		"video_completed":   p.VideoCompleted,
+		"chapter_completed": p.ChapterCompleted,
+	})
+}
+
+func (s pgProgressStore) SaveQuiz(ctx context.Context, p Progress) (SaveResult, error) {
+	return s.upsert(ctx, p, map[string]interface{}{
+		"quiz_progress":     p.QuizProgress,
+		"quiz_answers":      jsonValue(p.QuizAnswers),
+		"quiz_completed":    p.QuizCompleted,
+		"quiz_started_at":   p.QuizStartedAt,
+		"chapter_completed": p.ChapterCompleted,
+	})
+}
+
+// upsert sets fields on p's record and bumps its version, inserting it with
+// column defaults for the rest if there is none. An expected version needs
+// the record to exist at that version.
+func (s pgProgressStore) upsert(ctx context.Context, p Progress, fields map[string]interface{}) (SaveResult, error) {
+	now := time.Now()
+	columns := []string{"public_id", "org_id", "user_id", "chapter_id", "last_accessed_at", "updated_at"}
+	args := []interface{}{newPublicID(), orgID(ctx), p.UserID, p.ChapterID, now, now}
+	updates := []string{"last_accessed_at = EXCLUDED.last_accessed_at", "updated_at = EXCLUDED.updated_at",
+		"version = progress.version + 1"}
+
+	// Field times and device positions merge into the stored ones rather
+	// than replace them
+	columns = append(columns, "field_updated_at", "device_positions")
+	args = append(args, jsonValue(p.FieldUpdatedAt), jsonValue(p.DevicePositions))
+	updates = append(updates,
+		"field_updated_at = progress.field_updated_at || EXCLUDED.field_updated_at",
+		"device_positions = progress.device_positions || EXCLUDED.device_positions")
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		columns = append(columns, name)
+		args = append(args, fields[name])
+		updates = append(updates, name+" = EXCLUDED."+name)
+	}
+
+	placeholders := make([]string, len(args))
+	for i := range args {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	query := `INSERT INTO progress (` + strings.Join(columns, ", ") + `) VALUES (` + strings.Join(placeholders, ", ") + `)
+		ON CONFLICT (user_id, chapter_id) DO UPDATE SET ` + strings.Join(updates, ", ")
+	// A record of another organization is not this request's to update
+	if t, ok := requestTenant(ctx); ok && t.orgID != "" {
+		query += ` WHERE progress.org_id = EXCLUDED.org_id`
+	}
+	query += ` RETURNING xmax = 0, version`
+
+	var inserted bool
+	var version int
+	var err error
+	if p.Version == 0 {
+		err = s.db.QueryRowContext(ctx, query, args...).Scan(&inserted, &version)
+	} else {
+		inserted, version, err = s.saveAtVersion(ctx, p, query, args)
+	}
+	if err == sql.ErrNoRows {
+		return SaveResult{}, ErrDuplicate
+	} else if err != nil {
+		return SaveResult{}, err
+	}
+	if inserted {
+		return SaveResult{Upserted: 1, Version: version}, nil
+	}
+	return SaveResult{Matched: 1, Modified: 1, Version: version}, nil
+}
+
+// saveAtVersion runs upsert's query if p's record is at version p.Version,
+// holding the record locked so no other write slips in between
+func (s pgProgressStore) saveAtVersion(ctx context.Context, p Progress, query string, args []interface{}) (bool, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	where, whereArgs := tenantClause(ctx, `user_id = $1 AND chapter_id = $2`, []interface{}{p.UserID, p.ChapterID})
+	locked, err := queryProgress(ctx, tx, progressSelect+` WHERE `+where+` FOR UPDATE`, whereArgs...)
+	if err != nil {
+		return false, 0, err
+	}
+	if len(locked) == 0 || locked[0].Version != p.Version {
+		return false, 0, ErrVersionConflict
+	}
+
+	var inserted bool
+	var version int
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&inserted, &version); err != nil {
+		return false, 0, err
+	}
+	return inserted, version, tx.Commit()
+}
+
+func (s pgProgressStore) SaveQuizAnswer(ctx context.Context, a QuizAnswer) (Progress, SaveResult, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Progress{}, SaveResult{}, err
+	}
+	defer tx.Rollback()
+
+	// Make sure the row exists, then lock it so concurrent answers take
+	// turns rather than overwrite each other
+	now := time.Now()
+	var inserted bool
+	err = tx.QueryRowContext(ctx, `INSERT INTO progress (public_id, org_id, user_id, chapter_id, last_accessed_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5) ON CONFLICT (user_id, chapter_id) DO NOTHING RETURNING TRUE`,
+		newPublicID(), orgID(ctx), a.UserID, a.ChapterID, now).Scan(&inserted)
+	if err != nil && err != sql.ErrNoRows {
+		return Progress{}, SaveResult{}, err
+	}
+	where, args := tenantClause(ctx, `user_id = $1 AND chapter_id = $2`, []interface{}{a.UserID, a.ChapterID})
+	locked, err := queryProgress(ctx, tx, progressSelect+` WHERE `+where+` FOR UPDATE`, args...)
+	if err != nil {
+		return Progress{}, SaveResult{}, err
+	}
+	if len(locked) == 0 {
+		// The row belongs to another organization
+		return Progress{}, SaveResult{}, ErrDuplicate
+	}
+
+	previous := locked[0]
+	if a.Version != 0 && (inserted || previous.Version != a.Version) {
+		return Progress{}, SaveResult{}, ErrVersionConflict
+	}
+	updated := previous
+	a.apply(&updated)
+	// A new record starts at version 1
+	if !inserted {
+		updated.Version++
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE progress SET quiz_answers = $1, quiz_progress = $2, quiz_completed = $3,
+		quiz_started_at = $4, last_accessed_at = $5, updated_at = $5,
+		field_updated_at = field_updated_at || $6::jsonb, version = $7
+		WHERE user_id = $8 AND chapter_id = $9`,
+		jsonValue(updated.QuizAnswers), updated.QuizProgress, updated.QuizCompleted,
+		updated.QuizStartedAt, now, jsonValue(updated.FieldUpdatedAt), updated.Version, a.UserID, a.ChapterID)
+	if err != nil {
+		return Progress{}, SaveResult{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Progress{}, SaveResult{}, err
+	}
+
+	if inserted {
+		return Progress{}, SaveResult{Upserted: 1, Version: updated.Version}, nil
+	}
+	return previous, SaveResult{Matched: 1, Modified: 1, Version: updated.Version}, nil
+}
+
+func (s pgProgressStore) SaveQuizResult(ctx context.Context, userID, chapterID string, score int, passed bool) (Progress, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Progress{}, err
+	}
+	defer tx.Rollback()
+
+	where, args := tenantClause(ctx, `user_id = $1 AND chapter_id = $2`, []interface{}{userID, chapterID})
+	locked, err := queryProgress(ctx, tx, progressSelect+` WHERE `+where+` FOR UPDATE`, args...)
+	if err != nil {
+		return Progress{}, err
+	}
+	if len(locked) == 0 {
+		return Progress{}, ErrNotFound
+	}
+
+	previous := locked[0]
+	updated := previous
+	applyQuizResult(&updated, score, passed)
+	_, err = tx.ExecContext(ctx, `UPDATE progress SET quiz_score = $1, quiz_passed = $2, chapter_completed = $3,
+		updated_at = $4 WHERE user_id = $5 AND chapter_id = $6`,
+		score, passed, updated.ChapterCompleted, time.Now(), userID, chapterID)
+	if err != nil {
+		return Progress{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Progress{}, err
+	}
+	return previous, nil
+}
+
+func (s pgProgressStore) SaveQuizSeed(ctx context.Context, userID, chapterID string, seed int64) (Progress, error) {
+	// Concurrent requests agree on whichever seed was stored first
+	return s.saveProgressColumn(ctx, userID, chapterID, "quiz_seed",
+		"CASE WHEN progress.quiz_seed = 0 THEN EXCLUDED.quiz_seed ELSE progress.quiz_seed END", seed)
+}
+
+func (s pgProgressStore) SaveQuizDraw(ctx context.Context, userID, chapterID string, questionIDs []string) (Progress, error) {
+	// Concurrent requests agree on whichever draw was stored first
+	return s.saveProgressColumn(ctx, userID, chapterID, "quiz_question_ids",
+		"CASE WHEN jsonb_array_length(progress.quiz_question_ids) = 0 "+
+			"THEN EXCLUDED.quiz_question_ids ELSE progress.quiz_question_ids END", jsonValue(questionIDs))
+}
+
+func (s pgProgressStore) AddBookmark(ctx context.Context, userID, chapterID string, b Bookmark, maxBookmarks int) (Progress, error) {
+	return s.saveProgressColumn(ctx, userID, chapterID, "bookmarks",
+		fmt.Sprintf("CASE WHEN jsonb_array_length(progress.bookmarks) < %d "+
+			"THEN progress.bookmarks || EXCLUDED.bookmarks ELSE progress.bookmarks END", maxBookmarks),
+		jsonValue([]Bookmark{b}))
+}
+
+func (s pgProgressStore) DeleteBookmark(ctx context.Context, userID, chapterID, bookmarkID string) error {
+	where, args := tenantClause(ctx,
+		`user_id = $1 AND chapter_id = $2 AND bookmarks @> jsonb_build_array(jsonb_build_object('id', $3::text))`,
+		[]interface{}{userID, chapterID, bookmarkID, time.Now()})
+	result, err := s.db.ExecContext(ctx, `UPDATE progress SET
+		bookmarks = COALESCE((SELECT jsonb_agg(b ORDER BY i) FROM jsonb_array_elements(bookmarks) WITH ORDINALITY AS e(b, i)
+			WHERE b->>'id' <> $3), '[]'::jsonb),
+		updated_at = $4 WHERE `+where, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// saveProgressColumn sets one column, inserting value with the record if
+// there is none or else setting the column to update, an expression that
+// can read the stored row as progress and value as EXCLUDED. It returns the
+// progress as stored.
+func (s pgProgressStore) saveProgressColumn(ctx context.Context, userID, chapterID, column, update string, value interface{}) (Progress, error) {
+	now := time.Now()
+	query := `INSERT INTO progress (public_id, org_id, user_id, chapter_id, last_accessed_at, updated_at, ` + column + `)
+		VALUES ($1, $2, $3, $4, $5, $5, $6)
+		ON CONFLICT (user_id, chapter_id) DO UPDATE SET
+		` + column + ` = ` + update + `,
+		last_accessed_at = EXCLUDED.last_accessed_at, updated_at = EXCLUDED.updated_at`
+	// A record of another organization is not this request's to update
+	if t, ok := requestTenant(ctx); ok && t.orgID != "" {
+		query += ` WHERE progress.org_id = EXCLUDED.org_id`
+	}
+	query += ` RETURNING TRUE`
+
+	var saved bool
+	err := s.db.QueryRowContext(ctx, query, newPublicID(), orgID(ctx), userID, chapterID, now, value).Scan(&saved)
+	if err == sql.ErrNoRows {
+		return Progress{}, ErrDuplicate
+	} else if err != nil {
+		return Progress{}, err
+	}
+	return s.Get(ctx, userID, chapterID)
+}
+
+func (s pgProgressStore) DeleteForUser(ctx context.Context, userID string) (int64, error) {
+	where, args := tenantClause(ctx, `user_id = $1`, []interface{}{userID})
+	result, err := s.db.ExecContext(ctx, `DELETE FROM progress WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// query reads progress rows
+func (s pgProgressStore) query(ctx context.Context, query string, args ...interface{}) ([]Progress, error) {
+	return queryProgress(ctx, s.db, query, args...)
+}
+
+// pgQuerier is a *sql.DB or a *sql.Tx
+type pgQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryProgress(ctx context.Context, db pgQuerier, query string, args ...interface{}) ([]Progress, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []Progress{}
+	for rows.Next() {
+		var p Progress
+		var answers, questionIDs, fieldTimes, devicePositions, bookmarks []byte
+		var startedAt sql.NullTime
+		var score sql.NullInt64
+		err := rows.Scan(&p.PublicID, &p.OrgID, &p.UserID, &p.ChapterID, &p.VideoProgress, &p.VideoCompleted,
+			&p.QuizProgress, &answers, &p.QuizCompleted, &startedAt, &score, &p.QuizPassed, &p.QuizSeed, &questionIDs,
+			&p.ChapterCompleted, &p.LastAccessedAt, &p.UpdatedAt, &p.Version, &fieldTimes, &devicePositions, &bookmarks)
+		if err != nil {
+			return nil, err
+		}
+		err = unmarshalColumns(answers, &p.QuizAnswers, questionIDs, &p.QuizQuestionIDs, fieldTimes, &p.FieldUpdatedAt,
+			devicePositions, &p.DevicePositions, bookmarks, &p.Bookmarks)
+		if err != nil {
+			return nil, err
+		}
+		if startedAt.Valid {
+			p.QuizStartedAt = &startedAt.Time
+		}
+		if score.Valid {
+			s := int(score.Int64)
+			p.QuizScore = &s
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+// unmarshalColumns decodes pairs of JSONB column values and destinations
+func unmarshalColumns(pairs ...interface{}) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if err := json.Unmarshal(pairs[i].([]byte), pairs[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionColumns are the columns scanSession reads
+const sessionColumns = `public_id, user_id, org_id, role, device, platform, user_agent, ip,
+	refresh_token_hash, previous_token_hash, created_at, last_used_at, expires_at, revoked_at`
+
+// sessionOpen is the condition for a session that isn't revoked or expired
+const sessionOpen = `revoked_at IS NULL AND expires_at > now()`
+
+func (s pgSessionStore) Start(ctx context.Context, session AuthSession) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE auth_sessions SET revoked_at = $3
+		WHERE user_id = $1 AND device = $2 AND revoked_at IS NULL`,
+		session.UserID, session.Device, session.CreatedAt)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, `INSERT INTO auth_sessions (public_id, user_id, org_id, role, device, platform,
+		user_agent, ip, refresh_token_hash, previous_token_hash, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) ON CONFLICT (public_id) DO NOTHING`,
+		session.PublicID, session.UserID, session.OrgID, session.Role, session.Device, session.Platform,
+		session.UserAgent, session.IP, session.RefreshTokenHash, session.PreviousTokenHash,
+		session.CreatedAt, session.LastUsedAt, session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err == nil && inserted == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func (s pgSessionStore) Active(ctx context.Context, sessionID string) (bool, error) {
+	var active bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM auth_sessions WHERE public_id = $1 AND `+sessionOpen+`)`, sessionID).Scan(&active)
+	return active, err
+}
+
+func (s pgSessionStore) Rotate(ctx context.Context, hash, newHash string, now, expiresAt time.Time) (AuthSession, error) {
+	return s.updateOne(ctx, `UPDATE auth_sessions
+		SET refresh_token_hash = $2, previous_token_hash = $1, last_used_at = $3, expires_at = $4
+		WHERE public_id = (SELECT public_id FROM auth_sessions WHERE refresh_token_hash = $1 AND `+sessionOpen+` LIMIT 1)`,
+		hash, newHash, now, expiresAt)
+}
+
+func (s pgSessionStore) RevokeReused(ctx context.Context, hash string, now time.Time) (AuthSession, error) {
+	return s.updateOne(ctx, `UPDATE auth_sessions SET revoked_at = $2
+		WHERE public_id = (SELECT public_id FROM auth_sessions WHERE previous_token_hash = $1 AND revoked_at IS NULL LIMIT 1)`,
+		hash, now)
+}
+
+func (s pgSessionStore) List(ctx context.Context, userID string) ([]AuthSession, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sessionColumns+` FROM auth_sessions WHERE user_id = $1 AND `+sessionOpen+` ORDER BY last_used_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []AuthSession{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s pgSessionStore) Revoke(ctx context.Context, userID, sessionID string, now time.Time) (AuthSession, error) {
+	return s.updateOne(ctx, `UPDATE auth_sessions SET revoked_at = $3
+		WHERE public_id = $1 AND user_id = $2 AND revoked_at IS NULL`, sessionID, userID, now)
+}
+
+func (s pgSessionStore) RevokeForUser(ctx context.Context, userID string, now time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE auth_sessions SET revoked_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL`, userID, now)
+	return err
+}
+
+// updateOne runs an UPDATE of at most one session and returns the session
+// as updated, or ErrNotFound if it matched none
+func (s pgSessionStore) updateOne(ctx context.Context, update string, args ...interface{}) (AuthSession, error) {
+	session, err := scanSession(s.db.QueryRowContext(ctx, update+` RETURNING `+sessionColumns, args...))
+	return session, sqlError(err)
+}
+
+// scanSession reads a row of sessionColumns
+func scanSession(row interface{ Scan(...interface{}) error }) (AuthSession, error) {
+	var session AuthSession
+	var revokedAt sql.NullTime
+	err := row.Scan(&session.PublicID, &session.UserID, &session.OrgID, &session.Role, &session.Device,
+		&session.Platform, &session.UserAgent, &session.IP, &session.RefreshTokenHash, &session.PreviousTokenHash,
+		&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return AuthSession{}, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
+
+// Claim inserts the claim, or overwrites a free record in the same
+// statement, so two requests can't both claim a key: the row lock makes the
+// loser see the winner's claim and update nothing
+func (s pgIdempotencyStore) Claim(ctx context.Context, claim IdempotencyRecord, staleBefore time.Time) (IdempotencyRecord, bool, error) {
+	// An expired key is free too; nothing removes them otherwise
+	result, err := s.db.ExecContext(ctx, `INSERT INTO idempotency_keys (id, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET request_hash = EXCLUDED.request_hash, status = 0, content_type = '', body = NULL,
+			created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+			OR (idempotency_keys.status = 0 AND idempotency_keys.created_at < $5)`,
+		claim.ID, claim.RequestHash, claim.CreatedAt, claim.ExpiresAt, staleBefore)
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	if claimed, err := result.RowsAffected(); err == nil && claimed == 1 {
+		return IdempotencyRecord{}, true, nil
+	}
+
+	record := IdempotencyRecord{ID: claim.ID}
+	err = s.db.QueryRowContext(ctx, `SELECT request_hash, status, content_type, body, created_at, expires_at
+		FROM idempotency_keys WHERE id = $1`, claim.ID).Scan(
+		&record.RequestHash, &record.Status, &record.ContentType, &record.Body, &record.CreatedAt, &record.ExpiresAt)
+	if err != nil {
+		return IdempotencyRecord{}, false, sqlError(err)
+	}
+	return record, false, nil
+}
+
+func (s pgIdempotencyStore) Finish(ctx context.Context, id string, status int, contentType string, body []byte) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE idempotency_keys SET status = $2, content_type = $3, body = $4 WHERE id = $1`,
+		id, status, contentType, body)
+	return err
+}
+
+func (s pgIdempotencyStore) Release(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE id = $1 AND status = 0`, id)
+	return err
+}
@@ -0,0 +1,493 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The store tests run every store against the same expectations: the memory
+// stores always, the MongoDB stores when TEST_MONGODB_URI names a server
+// (they use a scratch database they drop), and the Postgres stores when
+// TEST_DATABASE_URL names a database the tests may write to.
+
+// storeSet is one backend's stores
+type storeSet struct {
+	users       UserStore
+	chapters    ChapterStore
+	progress    ProgressStore
+	sessions    SessionStore
+	idempotency IdempotencyStore
+}
+
+// forEachStore runs test against each backend's stores, as a subtest named
+// after the backend
+func forEachStore(t *testing.T, test func(t *testing.T, s storeSet)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) storeSet
+	}{
+		{StorageMemory, memoryTestStores},
+		{StorageMongo, mongoTestStores},
+		{StoragePostgres, postgresTestStores},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+func memoryTestStores(t *testing.T) storeSet {
+	chapters := []Chapter{}
+	for _, chapter := range seedChapters() {
+		chapters = append(chapters, newSeedChapter(chapter))
+	}
+	return storeSet{
+		users:       newMemUserStore(),
+		chapters:    newMemChapterStore(chapters),
+		progress:    newMemProgressStore(),
+		sessions:    newMemSessionStore(),
+		idempotency: newMemIdempotencyStore(),
+	}
+}
+
+// mongoTestStores points the collections the MongoDB stores use at a scratch
+// database, and back at the ones before when the test ends
+func mongoTestStores(t *testing.T) storeSet {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set")
+	}
+	ctx := context.Background()
+	testClient, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := testClient.Database("store_test_" + newPublicID()[:8])
+
+	saved := []*mongo.Collection{usersCol, chaptersCol, progressCol, authSessionsCol, idempotencyKeysCol}
+	usersCol = db.Collection("users")
+	chaptersCol = db.Collection("chapters")
+	progressCol = db.Collection("progress")
+	authSessionsCol = db.Collection("auth_sessions")
+	idempotencyKeysCol = db.Collection("idempotency_keys")
+	t.Cleanup(func() {
+		usersCol, chaptersCol, progressCol, authSessionsCol, idempotencyKeysCol = saved[0], saved[1], saved[2], saved[3], saved[4]
+		db.Drop(ctx)
+		testClient.Disconnect(ctx)
+	})
+
+	_, err = usersCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chapter := range seedChapters() {
+		if _, err := chaptersCol.InsertOne(ctx, newSeedChapter(chapter)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return storeSet{
+		users:       mongoUserStore{},
+		chapters:    mongoChapterStore{},
+		progress:    mongoProgressStore{},
+		sessions:    mongoSessionStore{},
+		idempotency: mongoIdempotencyStore{},
+	}
+}
+
+func postgresTestStores(t *testing.T) storeSet {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	saved := config.DatabaseURL
+	config.DatabaseURL = url
+	db, err := openPostgres()
+	config.DatabaseURL = saved
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return storeSet{
+		users:       pgUserStore{db},
+		chapters:    pgChapterStore{db},
+		progress:    pgProgressStore{db},
+		sessions:    pgSessionStore{db},
+		idempotency: pgIdempotencyStore{db},
+	}
+}
+
+// testUserID returns a user ID no earlier run used, so the tests can share
+// a database
+func testUserID(name string) string {
+	return name + "_" + newPublicID()[:8]
+}
+
+func TestUserStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storeSet) {
+		ctx := context.Background()
+		userID := testUserID("ada")
+
+		if _, err := s.users.Get(ctx, userID); err != ErrNotFound {
+			t.Fatalf("Get before insert: got %v, want ErrNotFound", err)
+		}
+		user, created, err := s.users.FindOrCreate(ctx, User{PublicID: newPublicID(), UserID: userID, Name: "Ada", GuestSecretHash: "hash", CreatedAt: time.Now()})
+		if err != nil || !created || user.UserID != userID {
+			t.Fatalf("FindOrCreate: got %+v, %v, %v", user, created, err)
+		}
+		user, created, err = s.users.FindOrCreate(ctx, User{PublicID: newPublicID(), UserID: userID, Name: "Someone else", GuestSecretHash: "other"})
+		if err != nil || created || user.Name != "Ada" || user.GuestSecretHash != "hash" {
+			t.Fatalf("FindOrCreate again: got %+v, %v, %v", user, created, err)
+		}
+
+		other := context.WithValue(ctx, tenantContextKey{}, tenant{orgID: "other_org"})
+		if _, _, err := s.users.FindOrCreate(other, User{PublicID: newPublicID(), UserID: userID, OrgID: "other_org"}); err != ErrDuplicate {
+			t.Fatalf("FindOrCreate in another organization: got %v, want ErrDuplicate", err)
+		}
+
+		if total, err := s.users.AddXP(ctx, userID, 30); err != nil || total != 30 {
+			t.Fatalf("AddXP: got %d, %v", total, err)
+		}
+		if total, err := s.users.AddXP(ctx, userID, 12); err != nil || total != 42 {
+			t.Fatalf("AddXP again: got %d, %v", total, err)
+		}
+		if user, err := s.users.Get(ctx, userID); err != nil || user.XP != 42 {
+			t.Fatalf("Get: got XP %d, %v", user.XP, err)
+		}
+	})
+}
+
+func TestChapterStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storeSet) {
+		ctx := context.Background()
+
+		chapter, err := s.chapters.Get(ctx, "chapter_1")
+		if err != nil || chapter.ChapterID != "chapter_1" {
+			t.Fatalf("Get: got %q, %v", chapter.ChapterID, err)
+		}
+		if _, err := s.chapters.Get(ctx, "no_such_chapter"); err != ErrNotFound {
+			t.Fatalf("Get missing: got %v, want ErrNotFound", err)
+		}
+		other := context.WithValue(ctx, tenantContextKey{}, tenant{orgID: "other_org"})
+		if _, err := s.chapters.Get(other, "chapter_1"); err != nil {
+			t.Fatalf("Get shared chapter in another organization: got %v", err)
+		}
+
+		all, err := s.chapters.List(ctx, ChapterQuery{Sort: bson.D{{Key: "order", Value: 1}}})
+		if err != nil || len(all) != len(seedChapters()) {
+			t.Fatalf("List: got %d chapters, %v", len(all), err)
+		}
+		for i := 1; i < len(all); i++ {
+			if all[i-1].Order > all[i].Order {
+				t.Fatalf("List: %s before %s", all[i-1].ChapterID, all[i].ChapterID)
+			}
+		}
+
+		some, err := s.chapters.List(ctx, ChapterQuery{
+			ChapterIDs: []string{"chapter_2", "chapter_1", "no_such_chapter"},
+			Sort:       bson.D{{Key: "order", Value: -1}},
+		})
+		if err != nil || len(some) != 2 || some[0].ChapterID != "chapter_2" {
+			t.Fatalf("List some: got %d chapters, %v", len(some), err)
+		}
+	})
+}
+
+func TestProgressStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storeSet) {
+		ctx := context.Background()
+		userID := testUserID("grace")
+		now := time.Now()
+
+		if _, err := s.progress.Get(ctx, userID, "chapter_1"); err != ErrNotFound {
+			t.Fatalf("Get before save: got %v, want ErrNotFound", err)
+		}
+
+		result, err := s.progress.SaveVideo(ctx, Progress{
+			UserID: userID, ChapterID: "chapter_1", VideoProgress: 60, VideoCompleted: true,
+			LastAccessedAt: now, UpdatedAt: now,
+		})
+		if err != nil || result.Upserted != 1 || result.Version != 1 {
+			t.Fatalf("SaveVideo: got %+v, %v", result, err)
+		}
+		result, err = s.progress.SaveVideo(ctx, Progress{
+			UserID: userID, ChapterID: "chapter_1", VideoProgress: 90, VideoCompleted: true,
+			LastAccessedAt: now, UpdatedAt: now, Version: 1,
+		})
+		if err != nil || result.Matched != 1 || result.Version != 2 {
+			t.Fatalf("SaveVideo at version 1: got %+v, %v", result, err)
+		}
+		_, err = s.progress.SaveVideo(ctx, Progress{
+			UserID: userID, ChapterID: "chapter_1", VideoProgress: 10,
+			LastAccessedAt: now, UpdatedAt: now, Version: 1,
+		})
+		if err != ErrVersionConflict {
+			t.Fatalf("SaveVideo over a newer version: got %v, want ErrVersionConflict", err)
+		}
+
+		previous, result, err := s.progress.SaveQuizAnswer(ctx, QuizAnswer{
+			UserID: userID, ChapterID: "chapter_1", QuestionIndex: 1,
+			Answer: choiceAnswer(2), Completed: true, Questions: 2, At: now,
+		})
+		if err != nil || previous.VideoProgress != 90 || result.Version != 3 {
+			t.Fatalf("SaveQuizAnswer: got %+v, %+v, %v", previous, result, err)
+		}
+
+		if _, err := s.progress.SaveQuizResult(ctx, userID, "chapter_2", 100, true); err != ErrNotFound {
+			t.Fatalf("SaveQuizResult without progress: got %v, want ErrNotFound", err)
+		}
+		if _, err := s.progress.SaveQuizResult(ctx, userID, "chapter_1", 100, true); err != nil {
+			t.Fatalf("SaveQuizResult: %v", err)
+		}
+		p, err := s.progress.Get(ctx, userID, "chapter_1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.QuizAnswers) != 2 || p.QuizAnswers[1].Choice != 2 || !p.QuizCompleted {
+			t.Fatalf("Get: got quiz answers %v, completed %v", p.QuizAnswers, p.QuizCompleted)
+		}
+		if p.QuizScore == nil || *p.QuizScore != 100 || !p.QuizPassed || !p.ChapterCompleted {
+			t.Fatalf("Get: got score %v, passed %v, chapter completed %v", p.QuizScore, p.QuizPassed, p.ChapterCompleted)
+		}
+
+		if p, err := s.progress.SaveQuizSeed(ctx, userID, "chapter_2", 7); err != nil || p.QuizSeed != 7 {
+			t.Fatalf("SaveQuizSeed: got %d, %v", p.QuizSeed, err)
+		}
+		if p, err := s.progress.SaveQuizSeed(ctx, userID, "chapter_2", 8); err != nil || p.QuizSeed != 7 {
+			t.Fatalf("SaveQuizSeed again: got %d, %v", p.QuizSeed, err)
+		}
+
+		completed := true
+		page, total, err := s.progress.List(ctx, ProgressQuery{
+			UserID:     userID,
+			ChapterIDs: []string{"chapter_1", "chapter_2", "chapter_3"},
+			Completed:  &completed,
+			Page:       offsetPage{Limit: 10},
+		})
+		if err != nil || total != 1 || len(page) != 1 || page[0].ChapterID != "chapter_1" {
+			t.Fatalf("List completed: got %d of %d, %v", len(page), total, err)
+		}
+		page, total, err = s.progress.List(ctx, ProgressQuery{
+			UserID:     userID,
+			ChapterIDs: []string{"chapter_1", "chapter_2", "chapter_3"},
+			Sort:       bson.D{{Key: "chapter_id", Value: 1}},
+			Page:       offsetPage{Limit: 1, Offset: 1},
+		})
+		if err != nil || total != 2 || len(page) != 1 || page[0].ChapterID != "chapter_2" {
+			t.Fatalf("List second page: got %d of %d, %v", len(page), total, err)
+		}
+
+		for i, id := range []string{"b1", "b2", "b3"} {
+			p, err := s.progress.AddBookmark(ctx, userID, "chapter_3", Bookmark{ID: id, Position: i, CreatedAt: now}, 2)
+			if err != nil || len(p.Bookmarks) != min(i+1, 2) {
+				t.Fatalf("AddBookmark %s: got %d bookmarks, %v", id, len(p.Bookmarks), err)
+			}
+		}
+		if err := s.progress.DeleteBookmark(ctx, userID, "chapter_3", "b3"); err != ErrNotFound {
+			t.Fatalf("DeleteBookmark of a refused bookmark: got %v, want ErrNotFound", err)
+		}
+		if err := s.progress.DeleteBookmark(ctx, userID, "chapter_3", "b1"); err != nil {
+			t.Fatalf("DeleteBookmark: %v", err)
+		}
+
+		if n, err := s.progress.DeleteForUser(ctx, userID); err != nil || n != 3 {
+			t.Fatalf("DeleteForUser: got %d, %v", n, err)
+		}
+		if _, err := s.progress.Get(ctx, userID, "chapter_1"); err != ErrNotFound {
+			t.Fatalf("Get after DeleteForUser: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestSessionStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storeSet) {
+		ctx := context.Background()
+		userID := testUserID("linus")
+		now := time.Now().Truncate(time.Millisecond)
+
+		session := func(device, hash string, lastUsed time.Time) AuthSession {
+			return AuthSession{
+				PublicID: newPublicID(), UserID: userID, OrgID: defaultOrgID, Device: device,
+				RefreshTokenHash: hash, CreatedAt: lastUsed, LastUsedAt: lastUsed, ExpiresAt: now.Add(time.Hour),
+			}
+		}
+		phone := session("phone", testUserID("hash"), now.Add(-time.Minute))
+		laptop := session("laptop", testUserID("hash"), now)
+		for _, session := range []AuthSession{phone, laptop} {
+			if err := s.sessions.Start(ctx, session); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		sessions, err := s.sessions.List(ctx, userID)
+		if err != nil || len(sessions) != 2 || sessions[0].PublicID != laptop.PublicID {
+			t.Fatalf("List: got %d sessions, %v", len(sessions), err)
+		}
+
+		// Logging in again on the phone signs its earlier session out
+		phoneAgain := session("phone", testUserID("hash"), now)
+		if err := s.sessions.Start(ctx, phoneAgain); err != nil {
+			t.Fatal(err)
+		}
+		if active, err := s.sessions.Active(ctx, phone.PublicID); err != nil || active {
+			t.Fatalf("Active of the replaced session: got %v, %v", active, err)
+		}
+
+		newHash := testUserID("hash")
+		rotated, err := s.sessions.Rotate(ctx, laptop.RefreshTokenHash, newHash, now, now.Add(2*time.Hour))
+		if err != nil || rotated.PublicID != laptop.PublicID || rotated.RefreshTokenHash != newHash {
+			t.Fatalf("Rotate: got %+v, %v", rotated, err)
+		}
+		if _, err := s.sessions.Rotate(ctx, laptop.RefreshTokenHash, testUserID("hash"), now, now.Add(time.Hour)); err != ErrNotFound {
+			t.Fatalf("Rotate of a rotated token: got %v, want ErrNotFound", err)
+		}
+		if revoked, err := s.sessions.RevokeReused(ctx, laptop.RefreshTokenHash, now); err != nil || revoked.PublicID != laptop.PublicID {
+			t.Fatalf("RevokeReused: got %+v, %v", revoked, err)
+		}
+		if active, err := s.sessions.Active(ctx, laptop.PublicID); err != nil || active {
+			t.Fatalf("Active of a reused session: got %v, %v", active, err)
+		}
+
+		if _, err := s.sessions.Revoke(ctx, "someone_else", phoneAgain.PublicID, now); err != ErrNotFound {
+			t.Fatalf("Revoke of another user's session: got %v, want ErrNotFound", err)
+		}
+		if revoked, err := s.sessions.Revoke(ctx, userID, phoneAgain.PublicID, now); err != nil || revoked.RevokedAt == nil {
+			t.Fatalf("Revoke: got %+v, %v", revoked, err)
+		}
+		if _, err := s.sessions.Revoke(ctx, userID, phoneAgain.PublicID, now); err != ErrNotFound {
+			t.Fatalf("Revoke again: got %v, want ErrNotFound", err)
+		}
+
+		tablet := session("tablet", testUserID("hash"), now)
+		if err := s.sessions.Start(ctx, tablet); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.sessions.RevokeForUser(ctx, userID, now); err != nil {
+			t.Fatal(err)
+		}
+		if sessions, err := s.sessions.List(ctx, userID); err != nil || len(sessions) != 0 {
+			t.Fatalf("List after RevokeForUser: got %d sessions, %v", len(sessions), err)
+		}
+	})
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s storeSet) {
+		ctx := context.Background()
+		now := time.Now().Truncate(time.Millisecond)
+		claim := IdempotencyRecord{
+			ID: testUserID("key"), RequestHash: "request", CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+		}
+		staleBefore := now.Add(-time.Minute)
+
+		if _, claimed, err := s.idempotency.Claim(ctx, claim, staleBefore); err != nil || !claimed {
+			t.Fatalf("Claim: got %v, %v", claimed, err)
+		}
+		held, claimed, err := s.idempotency.Claim(ctx, claim, staleBefore)
+		if err != nil || claimed || held.RequestHash != "request" || held.Status != 0 {
+			t.Fatalf("Claim of a held key: got %+v, %v, %v", held, claimed, err)
+		}
+
+		// A claim older than staleBefore whose request never finished is
+		// taken over
+		if _, claimed, err := s.idempotency.Claim(ctx, claim, now.Add(time.Second)); err != nil || !claimed {
+			t.Fatalf("Claim of a stale key: got %v, %v", claimed, err)
+		}
+
+		if err := s.idempotency.Finish(ctx, claim.ID, http.StatusCreated, "application/json", []byte(`{"success":true}`)); err != nil {
+			t.Fatal(err)
+		}
+		held, claimed, err = s.idempotency.Claim(ctx, claim, now.Add(time.Second))
+		if err != nil || claimed || held.Status != http.StatusCreated || string(held.Body) != `{"success":true}` {
+			t.Fatalf("Claim of a finished key: got %+v, %v, %v", held, claimed, err)
+		}
+
+		// An expired record frees its key
+		later := claim
+		later.CreatedAt = now.Add(2 * time.Hour)
+		later.ExpiresAt = later.CreatedAt.Add(time.Hour)
+		if _, claimed, err := s.idempotency.Claim(ctx, later, staleBefore); err != nil || !claimed {
+			t.Fatalf("Claim of an expired key: got %v, %v", claimed, err)
+		}
+
+		if err := s.idempotency.Release(ctx, claim.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, claimed, err := s.idempotency.Claim(ctx, claim, staleBefore); err != nil || !claimed {
+			t.Fatalf("Claim of a released key: got %v, %v", claimed, err)
+		}
+	})
+}
+
+// TestMemoryBackendFlow walks a learner through a chapter on the memory
+// backend the tests run on, which has no MongoDB
+func TestMemoryBackendFlow(t *testing.T) {
+	if mongoConnected() {
+		t.Fatal("the tests should run without MongoDB")
+	}
+	userID := testUserID("margaret")
+	token := login(t, userID).AccessToken
+
+	res := do(t, "GET", "/api/chapters", token, nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("GET /api/chapters: %d %s", res.Status, res.Body)
+	}
+	var chapters []Chapter
+	res.data(t, &chapters)
+	if len(chapters) != len(seedChapters()) {
+		t.Fatalf("GET /api/chapters: got %d chapters", len(chapters))
+	}
+	// The answer key isn't served, so take it from the store
+	chapter, err := chapterStore.Get(context.Background(), chapters[0].ChapterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res = do(t, "POST", "/api/progress/video", token, UpdateVideoProgressRequest{
+		UserID: userID, ChapterID: chapter.ChapterID, Progress: chapter.Duration, Completed: true,
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("POST /api/progress/video: %d %s", res.Status, res.Body)
+	}
+
+	questions := chapter.Quiz.Questions
+	for i, question := range questions {
+		res = do(t, "POST", "/api/progress/quiz", token, UpdateQuizProgressRequest{
+			UserID: userID, ChapterID: chapter.ChapterID, QuestionIndex: i,
+			Answer: choiceAnswer(question.CorrectAnswer), Completed: i == len(questions)-1,
+		})
+		if res.Status != http.StatusOK {
+			t.Fatalf("POST /api/progress/quiz %d: %d %s", i, res.Status, res.Body)
+		}
+	}
+
+	res = do(t, "GET", "/api/progress/"+userID+"/"+chapter.ChapterID, token, nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("GET progress: %d %s", res.Status, res.Body)
+	}
+	var progress Progress
+	res.data(t, &progress)
+	if !progress.VideoCompleted || !progress.QuizPassed || !progress.ChapterCompleted {
+		t.Fatalf("GET progress: got %+v", progress)
+	}
+
+	res = do(t, "GET", "/api/progress/"+userID, token, nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("GET progress list: %d %s", res.Status, res.Body)
+	}
+
+	res = do(t, "GET", "/api/courses", token, nil)
+	if res.Status != http.StatusNotImplemented || res.Code != ErrCodeMongoRequired {
+		t.Fatalf("GET /api/courses: got %d %q, want 501 %q", res.Status, res.Code, ErrCodeMongoRequired)
+	}
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// STREAK MODELS
+// ============================================================================
+
+// A day counts as active when the learner wrote any progress on it: a video
+// position, a quiz answer, or an offline sync. Days are calendar days in the
+// user's timezone at the time of the write, so a learner who changes
+// timezone keeps the days they already earned.
+
+// maxHeatmapDays bounds the ?from= to ?to= range of the streak heatmap
+const maxHeatmapDays = 366
+
+// DailyActivity is one day on which a user wrote progress
+type DailyActivity struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID  string             `bson:"user_id" json:"userId"`
+	Date    string             `bson:"date" json:"date"`     // YYYY-MM-DD in the user's timezone
+	Writes  int                `bson:"writes" json:"writes"` // progress writes that day
+	FirstAt time.Time          `bson:"first_at" json:"firstAt"`
+	LastAt  time.Time          `bson:"last_at" json:"lastAt"`
+}
+
+// Streak is a user's run of consecutive active days, with a heatmap
+type Streak struct {
+	CurrentStreak int         `json:"currentStreak"` // days, ending today or yesterday
+	LongestStreak int         `json:"longestStreak"`
+	ActiveToday   bool        `json:"activeToday"`
+	TotalDays     int         `json:"totalDays"` // active days ever
+	Timezone      string      `json:"timezone"`
+	From          string      `json:"from"`
+	To            string      `json:"to"`
+	Days          []ActiveDay `json:"days"` // active days from From to To, oldest first
+}
+
+// ActiveDay is one cell of the heatmap
+type ActiveDay struct {
+	Date   string `json:"date"`
+	Writes int    `json:"writes"`
+}
+
+// ============================================================================
+// STREAK HANDLERS
+// ============================================================================
+
+// GetUserStreak returns the user's current and longest streaks and the
+// active days between ?from= and ?to= (YYYY-MM-DD, inclusive, in the user's
+// timezone), the last 365 days by default
+func GetUserStreak(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	query := r.URL.Query()
+
+	ctx := r.Context()
+
+	loc := userLocation(ctx, userID)
+	today := startOfDay(time.Now(), loc)
+	from, to := today.AddDate(0, 0, -364), today
+
+	var errs fieldErrors
+	if v := query.Get("from"); v != "" {
+		if t, err := time.ParseInLocation(rollupDateLayout, v, loc); err != nil {
+			errs.add("from", CodeInvalid, "must be a YYYY-MM-DD date")
+		} else {
+			from = t
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if t, err := time.ParseInLocation(rollupDateLayout, v, loc); err != nil {
+			errs.add("to", CodeInvalid, "must be a YYYY-MM-DD date")
+		} else {
+			to = t
+		}
+	}
+	if len(errs) == 0 {
+		if to.Before(from) {
+			errs.add("to", CodeInvalid, "must not be before from")
+		} else if daysBetween(from, to, loc) >= maxHeatmapDays {
+			errs.add("from", CodeOutOfRange, "must be at most 366 days before to")
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	days, err := activeDays(ctx, userID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch activity")
+		return
+	}
+
+	streak := computeStreak(days, today)
+	streak.Timezone = loc.String()
+	streak.From = from.Format(rollupDateLayout)
+	streak.To = to.Format(rollupDateLayout)
+	streak.Days = []ActiveDay{}
+	for _, day := range days {
+		if day.Date >= streak.From && day.Date <= streak.To {
+			streak.Days = append(streak.Days, ActiveDay{Date: day.Date, Writes: day.Writes})
+		}
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Streak fetched successfully",
+		Data:    streak,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// STREAK HELPERS
+// ============================================================================
+
+// activeDays lists every day the user was active, oldest first. Streaks
+// need them all; a day is small, and one a day is few.
+func activeDays(ctx context.Context, userID string) ([]DailyActivity, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "date", Value: 1}}).
+		SetProjection(bson.M{"date": 1, "writes": 1})
+	cursor, err := dailyActivityCol.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var days []DailyActivity
+	if err := cursor.All(ctx, &days); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
+
+// computeStreak finds the streaks in days, sorted oldest first. The current
+// streak is still alive until a whole day is missed, so it may end
+// yesterday.
+func computeStreak(days []DailyActivity, today time.Time) Streak {
+	streak := Streak{TotalDays: len(days)}
+	var run int
+	var previous time.Time
+	for i, day := range days {
+		date, err := time.ParseInLocation(rollupDateLayout, day.Date, today.Location())
+		if err != nil {
+			continue
+		}
+		if i > 0 && daysBetween(previous, date, today.Location()) == 1 {
+			run++
+		} else {
+			run = 1
+		}
+		previous = date
+		if run > streak.LongestStreak {
+			streak.LongestStreak = run
+		}
+	}
+	if len(days) > 0 {
+		switch daysBetween(previous, today, today.Location()) {
+		case 0:
+			streak.CurrentStreak, streak.ActiveToday = run, true
+		case 1:
+			streak.CurrentStreak = run
+		}
+	}
+	return streak
+}
+
+// recordDailyActivity marks the days of progress writes made at the given
+// times as active. Failures are logged; streaks never fail a write, and
+// without MongoDB they aren't kept.
+func recordDailyActivity(ctx context.Context, userID string, at ...time.Time) {
+	if len(at) == 0 || !mongoConnected() {
+		return
+	}
+	loc := userLocation(ctx, userID)
+
+	type dayWrites struct {
+		writes      int
+		first, last time.Time
+	}
+	byDate := map[string]*dayWrites{}
+	for _, t := range at {
+		date := t.In(loc).Format(rollupDateLayout)
+		day, ok := byDate[date]
+		if !ok {
+			day = &dayWrites{first: t, last: t}
+			byDate[date] = day
+		}
+		day.writes++
+		if t.Before(day.first) {
+			day.first = t
+		}
+		if t.After(day.last) {
+			day.last = t
+		}
+	}
+
+	for date, day := range byDate {
+		err := retryOnDuplicateKey(func() error {
+			_, err := dailyActivityCol.UpdateOne(ctx,
+				bson.M{"user_id": userID, "date": date},
+				bson.M{
+					"$inc": bson.M{"writes": day.writes},
+					"$min": bson.M{"first_at": day.first},
+					"$max": bson.M{"last_at": day.last},
+				},
+				options.Update().SetUpsert(true))
+			return err
+		})
+		if err != nil {
+			log.Printf("❌ Error recording daily activity for %s: %v", userID, err)
+		}
+	}
+}
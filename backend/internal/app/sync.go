@@ -0,0 +1,374 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ============================================================================
+// OFFLINE SYNC
+// ============================================================================
+
+// Mobile apps queue progress while offline and send the queue to
+// POST /api/sync when they reconnect. Each progress field keeps the time it
+// was last written (Progress.FieldUpdatedAt), and a queued write only wins
+// over the stored value if it is newer, so a stale phone can't undo what the
+// web app recorded in the meantime.
+
+// maxSyncEvents bounds the events in one sync
+const maxSyncEvents = 1000
+
+// Kinds of queued event
+const (
+	SyncVideo = "video"
+	SyncQuiz  = "quiz"
+)
+
+// SyncEvent is one progress write a client recorded while offline. Video
+// events carry Progress and Completed; quiz events carry QuestionIndex,
+// Answer and Completed, like the online endpoints.
+type SyncEvent struct {
+	Type          string    `json:"type"`
+	ChapterID     string    `json:"chapterId"`
+	Timestamp     time.Time `json:"timestamp"` // when the client recorded it
+	Progress      int       `json:"progress"`  // in seconds
+	QuestionIndex int       `json:"questionIndex"`
+	Answer        Answer    `json:"answer"` // shaped by the question's type
+	Completed     bool      `json:"completed"`
+}
+
+type SyncRequest struct {
+	UserID    string      `json:"userId"`
+	Events    []SyncEvent `json:"events"`
+	SessionID string      `json:"sessionId"` // optional, falls back to the X-Session-ID header
+}
+
+// SyncResult is the merged progress of each chapter in a sync
+type SyncResult struct {
+	Received int                  `json:"received"` // events in the request
+	Chapters []SyncChapterOutcome `json:"chapters"`
+}
+
+// SyncChapterOutcome is what happened to one chapter's events
+type SyncChapterOutcome struct {
+	ChapterID string       `json:"chapterId"`
+	Status    string       `json:"status"`
+	Events    int          `json:"events"`             // events for this chapter
+	Applied   int          `json:"applied"`            // events newer than the server's values
+	Progress  *Progress    `json:"progress,omitempty"` // merged progress, for synced chapters
+	Code      string       `json:"code,omitempty"`     // why it was rejected
+	Message   string       `json:"message,omitempty"`  // why it was rejected
+	Lock      *ChapterLock `json:"lock,omitempty"`     // locked chapters only
+	XP        *XPAward     `json:"xp,omitempty"`       // XP the synced events earned
+}
+
+// SyncProgress merges a client's queued events into the stored progress
+// and returns the merged progress of each chapter. A chapter the user can't
+// write to is rejected on its own without failing the rest of the sync.
+func SyncProgress(w http.ResponseWriter, r *http.Request) {
+	var req SyncRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID, ok := actingUserID(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	req.UserID = userID
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("userId", req.UserID)
+	if len(req.Events) == 0 {
+		errs.add("events", CodeRequired, "must contain at least one event")
+	} else if len(req.Events) > maxSyncEvents {
+		errs.add("events", CodeInvalid, fmt.Sprintf("must contain at most %d events", maxSyncEvents))
+	}
+	for i, e := range req.Events {
+		field := fmt.Sprintf("events[%d]", i)
+		switch e.Type {
+		case SyncVideo:
+			if e.Progress < 0 {
+				errs.add(field+".progress", CodeInvalid, "must not be negative")
+			}
+		case SyncQuiz:
+			if e.QuestionIndex < 0 {
+				errs.add(field+".questionIndex", CodeInvalid, "must not be negative")
+			}
+		case "":
+			errs.add(field+".type", CodeRequired, "is required")
+		default:
+			errs.add(field+".type", CodeUnknownValue, fmt.Sprintf("must be %q or %q", SyncVideo, SyncQuiz))
+		}
+		errs.required(field+".chapterId", e.ChapterID)
+		if e.Timestamp.IsZero() {
+			errs.add(field+".timestamp", CodeRequired, "is required")
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	if !checkUserActive(ctx, w, req.UserID) {
+		return
+	}
+
+	access, err := loadChapterAccess(ctx, req.UserID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	source := QuizAnswerContext{SessionID: req.SessionID, UserAgent: r.UserAgent()}
+	if source.SessionID == "" {
+		source.SessionID = r.Header.Get("X-Session-ID")
+	}
+
+	result := SyncResult{Received: len(req.Events), Chapters: []SyncChapterOutcome{}}
+	for _, chapter := range syncEventsByChapter(ctx, req.Events) {
+		outcome := SyncChapterOutcome{ChapterID: chapter.chapterID, Events: len(chapter.events)}
+
+		block, err := access.check(ctx, chapter.chapterID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if block != nil {
+			outcome.Status = BatchRejected
+			outcome.Code, outcome.Message, outcome.Lock = block.code, T(responseLocale(w), block.message), block.lock
+			result.Chapters = append(result.Chapters, outcome)
+			continue
+		}
+
+		merged, applied, xp, err := progressService.Merge(ctx, req.UserID, chapter.chapterID, chapter.events, source)
+		if err != nil {
+			log.Printf("❌ Error syncing progress: %v", err)
+			sendError(w, http.StatusInternalServerError, "Failed to update progress")
+			return
+		}
+		outcome.Status = BatchSaved
+		outcome.Applied = applied
+		outcome.Progress = &merged
+		outcome.XP = xp
+		result.Chapters = append(result.Chapters, outcome)
+	}
+
+	touchSession(ctx, r, req.UserID, "")
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Progress synced successfully",
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+type chapterSyncEvents struct {
+	chapterID string
+	events    []SyncEvent
+}
+
+// syncEventsByChapter groups events by chapter, in the order the chapters
+// first appear
+func syncEventsByChapter(ctx context.Context, events []SyncEvent) []chapterSyncEvents {
+	index := map[string]int{}
+	chapters := []chapterSyncEvents{}
+	for _, e := range events {
+		// Clients may send public IDs instead of business keys
+		chapterID := resolveChapterKey(ctx, e.ChapterID)
+
+		i, seen := index[chapterID]
+		if !seen {
+			i = len(chapters)
+			index[chapterID] = i
+			chapters = append(chapters, chapterSyncEvents{chapterID: chapterID})
+		}
+		chapters[i].events = append(chapters[i].events, e)
+	}
+	return chapters
+}
+
+// Merge applies a chapter's queued events to its stored progress, field by
+// field, keeping whichever write is newer. It returns the merged progress,
+// how many events changed it, and the XP they earned. Event times in the future count as now,
+// so a fast clock can't pin a field.
+func (s *ProgressService) Merge(ctx context.Context, userID, chapterID string, events []SyncEvent, source QuizAnswerContext) (Progress, int, *XPAward, error) {
+	current, err := s.Progress.Get(ctx, userID, chapterID)
+	if err != nil && err != ErrNotFound {
+		return Progress{}, 0, nil, err
+	}
+
+	chapter, err := s.Chapters.Get(ctx, chapterID)
+	if err != nil {
+		return Progress{}, 0, nil, err
+	}
+	if chapter, err = s.attemptChapter(ctx, userID, chapter); err != nil {
+		return Progress{}, 0, nil, err
+	}
+
+	order := newQuizOrder(chapter.Quiz, current.QuizSeed)
+
+	merged := current
+	merged.UserID, merged.ChapterID = userID, chapterID
+	merged.QuizAnswers = sizeQuizAnswers(current.QuizAnswers, len(chapter.Quiz.Questions))
+
+	// Records from before field times were kept count as written at their
+	// last update
+	writtenAt := func(field string) time.Time {
+		if at, ok := current.FieldUpdatedAt[field]; ok {
+			return at
+		}
+		return current.UpdatedAt
+	}
+	videoTimes := map[string]time.Time{}
+	quizTimes := map[string]time.Time{}
+	newer := func(times map[string]time.Time, field string, at time.Time) bool {
+		if prev, ok := times[field]; ok {
+			if !at.After(prev) {
+				return false
+			}
+		} else if !at.After(writtenAt(field)) {
+			return false
+		}
+		times[field] = at
+		return true
+	}
+
+	sorted := append([]SyncEvent{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	now := time.Now()
+	applied := 0
+	var answerChanges []AnswerChange
+	var firstQuizAt *time.Time
+	var activeAt []time.Time
+	for _, e := range sorted {
+		at := e.Timestamp
+		if at.After(now) {
+			at = now
+		}
+
+		changed := false
+		switch e.Type {
+		case SyncVideo:
+			if newer(videoTimes, FieldVideoProgress, at) {
+				merged.VideoProgress = e.Progress
+				changed = true
+			}
+			if newer(videoTimes, FieldVideoCompleted, at) {
+				merged.VideoCompleted = e.Completed
+				changed = true
+			}
+		case SyncQuiz:
+			// Answers to a shuffled quiz come in the order the learner was
+			// shown; answers that don't fit the quiz are dropped
+			e.QuestionIndex, e.Answer = order.stored(e.QuestionIndex, e.Answer)
+			if len(quizAnswerErrors(chapter.Quiz, "", e.QuestionIndex, e.Answer)) > 0 {
+				break
+			}
+			if newer(quizTimes, quizAnswerField(e.QuestionIndex), at) {
+				if old := merged.QuizAnswers[e.QuestionIndex]; !old.Equal(e.Answer) {
+					answerChanges = append(answerChanges, AnswerChange{
+						UserID:        userID,
+						ChapterID:     chapterID,
+						QuestionIndex: e.QuestionIndex,
+						OldAnswer:     old,
+						NewAnswer:     e.Answer,
+						SessionID:     source.SessionID,
+						UserAgent:     source.UserAgent,
+					})
+				}
+				merged.QuizAnswers[e.QuestionIndex] = e.Answer
+				changed = true
+			}
+			if newer(quizTimes, FieldQuizProgress, at) {
+				merged.QuizProgress = e.QuestionIndex
+				changed = true
+			}
+			if newer(quizTimes, FieldQuizCompleted, at) {
+				merged.QuizCompleted = e.Completed
+				changed = true
+			}
+			if changed && firstQuizAt == nil {
+				at := at
+				firstQuizAt = &at
+			}
+		}
+		if changed {
+			applied++
+			activeAt = append(activeAt, at)
+		}
+	}
+	if applied == 0 {
+		current.UserID, current.ChapterID = userID, chapterID
+		return current, 0, nil, nil
+	}
+
+	// A passed quiz completes the chapter with the video; a quiz finished
+	// in this sync is scored below
+	merged.ChapterCompleted = current.ChapterCompleted || (merged.VideoCompleted && current.QuizPassed)
+	chapterChanged := merged.ChapterCompleted != current.ChapterCompleted
+
+	if len(videoTimes) > 0 {
+		video := merged
+		video.FieldUpdatedAt = videoTimes
+		// Synced events don't say which device they came from, and
+		// rewriting the stored positions could undo a newer one
+		video.DevicePositions = nil
+		if _, err := s.Progress.SaveVideo(ctx, video); err != nil {
+			return Progress{}, 0, nil, err
+		}
+	}
+	if len(quizTimes) > 0 {
+		// The first answer of an attempt starts its clock
+		if merged.QuizStartedAt == nil || current.QuizCompleted {
+			merged.QuizStartedAt = firstQuizAt
+		}
+		quiz := merged
+		quiz.FieldUpdatedAt = quizTimes
+		if _, err := s.Progress.SaveQuiz(ctx, quiz); err != nil {
+			return Progress{}, 0, nil, err
+		}
+	}
+
+	log.Printf("✅ Progress synced: user=%s, chapter=%s, events=%d, applied=%d",
+		userID, chapterID, len(events), applied)
+
+	recordVideoWatched(ctx, userID, chapterID, current.VideoProgress, merged.VideoProgress)
+	// Days count when the learner was active offline, not when they synced
+	recordDailyActivity(ctx, userID, activeAt...)
+	for _, change := range answerChanges {
+		recordAnswerChange(ctx, change)
+	}
+	var xp *XPAward
+	if merged.VideoCompleted && !current.VideoCompleted {
+		recordActivity(ctx, ActivityEvent{UserID: userID, Type: ActivityVideoCompleted, ChapterID: chapterID})
+		xp = awardXP(ctx, userID, chapterID, XPVideoCompleted)
+	}
+	if chapterChanged {
+		recordActivity(ctx, ActivityEvent{UserID: userID, Type: ActivityChapterCompleted, ChapterID: chapterID})
+	}
+	if merged.QuizCompleted && !current.QuizCompleted {
+		_, _, quizXP, err := s.finishQuiz(ctx, userID, chapter)
+		if err != nil {
+			return Progress{}, 0, nil, err
+		}
+		xp = xp.merge(quizXP)
+	}
+
+	stored, err := s.Progress.Get(ctx, userID, chapterID)
+	if err != nil {
+		return Progress{}, 0, nil, err
+	}
+	liveProgress.publish(userID, LiveMessage{Type: LiveProgress, Data: stored})
+	publishProgressEvent(ctx, stored)
+	evaluateAchievements(ctx, userID)
+	return stored, applied, xp, nil
+}
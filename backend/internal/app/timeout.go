@@ -0,0 +1,182 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================================================
+// REQUEST TIMEOUTS
+// ============================================================================
+
+// defaultRequestTimeout applies to routes without a specific budget, unless
+// REQUEST_TIMEOUT says otherwise
+const defaultRequestTimeout = 5 * time.Second
+
+// routeTimeouts are per-route budgets keyed by "METHOD /path/template".
+// Progress writes happen during playback and must stay snappy.
+var routeTimeouts = map[string]time.Duration{
+	"POST /api/progress/video": 2 * time.Second,
+	"POST /api/progress/quiz":  2 * time.Second,
+	// Whole-database repairs walk every progress document
+	"POST /api/admin/repair/progress": 2 * time.Minute,
+	// A cohort's matrix reads every learner's progress in the course
+	"GET /api/instructor/cohorts/{cohortId}/progress": 10 * time.Second,
+}
+
+// streamingRoutes hold their connection open for as long as the client
+// stays, or write a download as it is read, so they have no budget
+var streamingRoutes = map[string]bool{
+	"GET /api/ws":                         true,
+	"GET /api/admin/events":               true,
+	"GET /api/admin/reports/progress.csv": true,
+}
+
+// prefixTimeouts are budgets for whole route families, checked after
+// routeTimeouts. Admin reports and bulk operations get more room.
+var prefixTimeouts = []struct {
+	prefix  string
+	timeout time.Duration
+}{
+	{"/api/admin/", 10 * time.Second},
+}
+
+// routeTimeout returns the budget for the matched route
+func routeTimeout(r *http.Request) time.Duration {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return config.RequestTimeout
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return config.RequestTimeout
+	}
+
+	if timeout, ok := routeTimeouts[r.Method+" "+template]; ok {
+		return timeout
+	}
+	for _, p := range prefixTimeouts {
+		if strings.HasPrefix(template, p.prefix) {
+			return p.timeout
+		}
+	}
+	return config.RequestTimeout
+}
+
+// TimeoutMiddleware gives each request a deadline from its route budget.
+// Handlers see the deadline on r.Context(); if they haven't responded when
+// it passes, the client gets a 504 and anything written later is discarded.
+func TimeoutMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamingRoutes[routeKey(r)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timeout := routeTimeout(r)
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{w: w, h: make(http.Header)}
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					// Keep the stack of where it happened for the log
+					if p != http.ErrAbortHandler {
+						p = handlerPanic{value: p, stack: debug.Stack()}
+					}
+					panicChan <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			// Re-panic on the serving goroutine for RecoveryMiddleware
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := w.Header()
+			for k, v := range tw.h {
+				if k == "Vary" {
+					// Outer middleware vary the response too
+					dst[k] = append(dst[k], v...)
+					continue
+				}
+				dst[k] = v
+			}
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			response := ApiResponse{
+				Success: false,
+				Code:    ErrCodeTimeout,
+				Message: "Request timed out",
+				Data: map[string]interface{}{
+					"timeoutMs": timeout.Milliseconds(),
+				},
+			}
+			sendJSON(w, http.StatusGatewayTimeout, response)
+		}
+	})
+}
+
+// timeoutWriter buffers a response until the handler finishes in time
+type timeoutWriter struct {
+	w    http.ResponseWriter
+	h    http.Header
+	buf  bytes.Buffer
+	mu   sync.Mutex
+	code int
+
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+// Unwrap lets helpers such as responseLocale see the wrapped writer
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
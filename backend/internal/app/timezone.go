@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+	_ "time/tzdata" // container images may ship without a zoneinfo database
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// USER TIMEZONES
+// ============================================================================
+
+// Day boundaries (streaks, daily goals, reminders, per-day analytics) are
+// always taken in the user's timezone, never the server's. Users without a
+// timezone are treated as UTC.
+
+// digestHour is the local hour from which a due digest is sent
+const digestHour = 8
+
+// loadTimezone validates an IANA timezone name such as "Europe/Berlin".
+// Empty and "Local" are rejected so the server zone can never leak in.
+func loadTimezone(name string) (*time.Location, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+	return loc, true
+}
+
+// location returns the user's timezone, falling back to UTC
+func (u User) location() *time.Location {
+	if loc, ok := loadTimezone(u.Timezone); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// userLocation looks up a user's timezone by user ID
+func userLocation(ctx context.Context, userID string) *time.Location {
+	var user User
+	err := usersCol.FindOne(ctx, bson.M{"user_id": userID},
+		options.FindOne().SetProjection(bson.M{"timezone": 1})).Decode(&user)
+	if err != nil {
+		return time.UTC
+	}
+	return user.location()
+}
+
+// startOfDay returns midnight of t's calendar day in loc
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// daysBetween counts calendar days in loc from a to b, so 23:59 to 00:01 the
+// next day is one day apart
+func daysBetween(a, b time.Time, loc *time.Location) int {
+	from, to := startOfDay(a, loc), startOfDay(b, loc)
+	// Round rather than divide exactly: DST days are 23 or 25 hours long
+	return int((to.Sub(from) + 12*time.Hour) / (24 * time.Hour))
+}
+
+// parseDateOrTime accepts an RFC 3339 timestamp, or a plain YYYY-MM-DD date
+// meaning midnight of that day in loc
+func parseDateOrTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// ============================================================================
+// TRACING
+// ============================================================================
+
+// Each request gets a span named after its route, and each Mongo command it
+// runs a child span, so a slow progress update shows whether the time went
+// to the database. Spans go to an OpenTelemetry collector over OTLP when
+// an endpoint is configured (see tracing_otel.go); otherwise tracing is a
+// no-op.
+
+// Span is one timed operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts spans. Extract continues a trace from incoming headers
+// (W3C traceparent).
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+	Extract(ctx context.Context, header http.Header) context.Context
+}
+
+// tracer is the tracer in use, set up by setupTracing
+var tracer Tracer = noopTracer{}
+
+type noopTracer struct{}
+
+type noopSpan struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopTracer) Extract(ctx context.Context, header http.Header) context.Context {
+	return ctx
+}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+// setupTracing turns on OTLP export when an OTLP endpoint is configured and
+// returns a func that flushes and stops it
+func setupTracing() func(context.Context) error {
+	stop := func(context.Context) error { return nil }
+	if config.OTLPEndpoint == "" {
+		return stop
+	}
+
+	t, shutdown, err := newOTLPTracer()
+	if err != nil {
+		log.Printf("❌ Error setting up tracing, continuing without it: %v", err)
+		return stop
+	}
+	tracer = t
+	log.Printf("🔭 Exporting traces to %s", config.OTLPEndpoint)
+	return shutdown
+}
+
+// ============================================================================
+// HTTP SPANS
+// ============================================================================
+
+// statusRecorder remembers the status code a handler sent
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// TracingMiddleware wraps each request in a span named "METHOD /route"
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				name = template
+			}
+		}
+
+		ctx := tracer.Extract(r.Context(), r.Header)
+		ctx, span := tracer.Start(ctx, r.Method+" "+name)
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", name)
+		span.SetAttribute("http.target", r.URL.RequestURI())
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", rec.status)
+		if rec.status >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(rec.status)))
+		}
+	})
+}
+
+// ============================================================================
+// MONGO SPANS
+// ============================================================================
+
+// mongoSpans holds the open span of each in-flight command by request ID
+var mongoSpans sync.Map
+
+// mongoMonitor starts a span when the driver sends a command and ends it
+// when the reply arrives, under the span of the request that ran it
+func mongoMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if _, off := tracer.(noopTracer); off {
+				return
+			}
+			_, span := tracer.Start(ctx, "mongo."+evt.CommandName)
+			span.SetAttribute("db.system", "mongodb")
+			span.SetAttribute("db.name", evt.DatabaseName)
+			span.SetAttribute("db.operation", evt.CommandName)
+			if col, ok := evt.Command.Lookup(evt.CommandName).StringValueOK(); ok {
+				span.SetAttribute("db.mongodb.collection", col)
+			}
+			mongoSpans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			if span, ok := mongoSpans.LoadAndDelete(evt.RequestID); ok {
+				span.(Span).End()
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			if span, ok := mongoSpans.LoadAndDelete(evt.RequestID); ok {
+				span.(Span).SetAttribute("error.message", evt.Failure)
+				span.(Span).End()
+			}
+		},
+	}
+}
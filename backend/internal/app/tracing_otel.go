@@ -0,0 +1,85 @@
+package app
+
+// OTLP export of the tracing spans, on when an OTLP endpoint is configured
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultServiceName = "resume-learning-backend"
+
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+// newOTLPTracer exports spans in batches to the configured OTLP endpoint.
+// Like OTEL_EXPORTER_OTLP_ENDPOINT, whose name it takes, the endpoint is
+// the collector's base URL; spans go to its /v1/traces. The exporter reads
+// the other OTEL_EXPORTER_OTLP_* variables itself.
+func newOTLPTracer() (Tracer, func(context.Context) error, error) {
+	endpoint := strings.TrimSuffix(config.OTLPEndpoint, "/") + "/v1/traces"
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", config.OTELServiceName)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return otelTracer{provider.Tracer(defaultServiceName)}, provider.Shutdown, nil
+}
+
+func (t otelTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := t.tracer.Start(ctx, name)
+	return ctx, otelSpan{span}
+}
+
+func (otelTracer) Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+func (s otelSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// TRANSACTIONS
+// ============================================================================
+
+// Writes that must land together, such as a quiz result, its attempt and
+// the XP it earns, run in a MongoDB transaction, so a crash or error part
+// way leaves none of them. Transactions need a replica set or a sharded
+// cluster; on a standalone server, or when the stores aren't MongoDB's,
+// the writes run one after another as before. The function may run more
+// than once when the transaction is retried, so it must only write to the
+// database: events, sockets and logs belong after it returns.
+
+// transactionsSupported is set by InitDB when the server can run
+// transactions
+var transactionsSupported bool
+
+// detectTransactions checks whether the connected deployment is a replica
+// set or a sharded cluster
+func detectTransactions(ctx context.Context) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		log.Printf("⚠️ Could not check for transaction support, writing without transactions: %v", err)
+		return
+	}
+	transactionsSupported = hello.SetName != "" || hello.Msg == "isdbgrid"
+	if !transactionsSupported {
+		log.Println("⚠️ MongoDB is a standalone server; multi-document writes run without transactions")
+	}
+}
+
+// withTransaction runs fn in a transaction where one is available, and
+// otherwise just runs it. fn must use the context it is given. Inside a
+// transaction it runs fn directly, joining the outer one.
+func withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !transactionsSupported || !mongoStores() || mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// mongoStores reports whether users and progress are stored in MongoDB,
+// where a transaction covers them
+func mongoStores() bool {
+	_, users := userStore.(mongoUserStore)
+	_, progress := progressStore.(mongoProgressStore)
+	return users && progress
+}
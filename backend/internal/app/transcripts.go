@@ -0,0 +1,503 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// TRANSCRIPT MODELS
+// ============================================================================
+
+// Each chapter can have subtitles in several languages, uploaded as WebVTT
+// or SRT files and kept in transcripts, one per chapter and language. The
+// uploaded file is kept as it came, and its cues are parsed into plain-text
+// segments with timestamps: the player's caption track and the transcript
+// panel read those, or ask for the file back as WebVTT or SRT. Uploading or
+// deleting subtitles keeps the chapter's caption languages in step.
+
+// Subtitle formats
+const (
+	SubtitlesVTT  = "vtt"
+	SubtitlesSRT  = "srt"
+	SubtitlesJSON = "json" // segments, for GET only
+)
+
+const (
+	// maxSubtitleBytes bounds an uploaded subtitle file
+	maxSubtitleBytes = 2 << 20
+	// maxTranscriptSegments bounds the cues of one file
+	maxTranscriptSegments = 20000
+)
+
+// cueTagPattern matches the styling tags of a cue's text, e.g. <i> or
+// <c.yellow>, which the plain-text segments leave out
+var cueTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Transcript is a chapter's subtitles in one language
+type Transcript struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"-"`
+	PublicID  string              `bson:"public_id,omitempty" json:"id"`
+	ChapterID string              `bson:"chapter_id" json:"chapterId"`
+	Lang      string              `bson:"lang" json:"lang"`     // lowercase language tag, e.g. "pt-br"
+	Format    string              `bson:"format" json:"format"` // of the uploaded file, vtt or srt
+	Source    string              `bson:"source" json:"-"`      // the uploaded file
+	Segments  []TranscriptSegment `bson:"segments" json:"segments"`
+	CreatedAt time.Time           `bson:"created_at" json:"createdAt"`
+	UpdatedAt time.Time           `bson:"updated_at" json:"updatedAt"`
+}
+
+// TranscriptSegment is one cue: its text and when it is shown, in seconds
+// into the video
+type TranscriptSegment struct {
+	Start float64 `bson:"start" json:"start"`
+	End   float64 `bson:"end" json:"end"`
+	Text  string  `bson:"text" json:"text"`
+}
+
+// Subtitles are a chapter's subtitles in one language, and which other
+// languages it has
+type Subtitles struct {
+	Transcript
+	Languages []string `json:"languages"`
+}
+
+// ============================================================================
+// TRANSCRIPT HANDLERS
+// ============================================================================
+
+// GetChapterSubtitles returns a chapter's subtitles in ?lang=, falling back
+// to its base language and then English. Without ?lang= the request's
+// locale is tried, then any language the chapter has. ?format=vtt or srt
+// returns a subtitle file instead of JSON segments.
+func GetChapterSubtitles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+	query := r.URL.Query()
+
+	var errs fieldErrors
+	lang := strings.TrimSpace(query.Get("lang"))
+	if lang != "" && !languageTagPattern.MatchString(lang) {
+		errs.add("lang", CodeInvalid, "must be a language tag like en or pt-BR")
+	}
+	format := query.Get("format")
+	switch format {
+	case "":
+		format = SubtitlesJSON
+	case SubtitlesJSON, SubtitlesVTT, SubtitlesSRT:
+	default:
+		errs.add("format", CodeUnknownValue, "must be json, vtt or srt")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := chapterStore.Get(ctx, chapterID); err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeChapterNotFound, "Chapter not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	transcripts, err := chapterTranscripts(ctx, chapterID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch subtitles")
+		return
+	}
+	transcript, ok := pickTranscript(transcripts, lang, responseLocale(w))
+	if !ok {
+		sendError(w, http.StatusNotFound, "Subtitles not found")
+		return
+	}
+
+	switch format {
+	case SubtitlesVTT, SubtitlesSRT:
+		body, contentType := renderSubtitles(transcript, format)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Language", transcript.Lang)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, body)
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Subtitles fetched successfully",
+		Data:    Subtitles{Transcript: transcript, Languages: transcriptLanguages(transcripts)},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UploadChapterSubtitles stores a WebVTT or SRT file, the request body, as
+// a chapter's subtitles in a language, replacing any it had
+func UploadChapterSubtitles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+	tag := strings.TrimSpace(vars["lang"])
+
+	var errs fieldErrors
+	if !languageTagPattern.MatchString(tag) {
+		errs.add("lang", CodeInvalid, "must be a language tag like en or pt-BR")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSubtitleBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Subtitle files can be at most %d MB", maxSubtitleBytes>>20))
+		return
+	} else if err != nil {
+		sendError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if !utf8.Valid(body) {
+		errs.add("subtitles", CodeInvalid, "must be UTF-8 text")
+		sendValidationErrors(w, errs)
+		return
+	}
+	format, segments, err := parseSubtitles(string(body))
+	if err != nil {
+		errs.add("subtitles", CodeInvalid, err.Error())
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	if !checkOwnChapter(ctx, w, chapterID) {
+		return
+	}
+
+	now := time.Now()
+	lang := normalizeLocale(tag)
+	var transcript Transcript
+	err = transcriptsCol.FindOneAndUpdate(ctx,
+		bson.M{"chapter_id": chapterID, "lang": lang},
+		bson.M{
+			"$set": bson.M{
+				"format":     format,
+				"source":     string(body),
+				"segments":   segments,
+				"updated_at": now,
+			},
+			"$setOnInsert": bson.M{"public_id": newPublicID(), "created_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&transcript)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to save subtitles")
+		return
+	}
+	if err := syncCaptionLanguages(ctx, chapterID, tag, true); err != nil {
+		log.Printf("❌ Error updating caption languages of chapter %s: %v", chapterID, err)
+	}
+
+	log.Printf("💬 Subtitles uploaded: chapter=%s, lang=%s, format=%s, cues=%d", chapterID, lang, format, len(segments))
+
+	transcripts, err := chapterTranscripts(ctx, chapterID)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch subtitles")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Subtitles saved successfully",
+		Data:    Subtitles{Transcript: transcript, Languages: transcriptLanguages(transcripts)},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// DeleteChapterSubtitles removes a chapter's subtitles in a language
+func DeleteChapterSubtitles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chapterID := vars["chapterId"]
+	tag := strings.TrimSpace(vars["lang"])
+
+	ctx := r.Context()
+
+	if !checkOwnChapter(ctx, w, chapterID) {
+		return
+	}
+	result, err := transcriptsCol.DeleteOne(ctx, bson.M{"chapter_id": chapterID, "lang": normalizeLocale(tag)})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to delete subtitles")
+		return
+	}
+	if result.DeletedCount == 0 {
+		sendError(w, http.StatusNotFound, "Subtitles not found")
+		return
+	}
+	if err := syncCaptionLanguages(ctx, chapterID, tag, false); err != nil {
+		log.Printf("❌ Error updating caption languages of chapter %s: %v", chapterID, err)
+	}
+
+	log.Printf("💬 Subtitles deleted: chapter=%s, lang=%s", chapterID, normalizeLocale(tag))
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Subtitles deleted successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// TRANSCRIPT HELPERS
+// ============================================================================
+
+// chapterTranscripts lists a chapter's subtitles, by language
+func chapterTranscripts(ctx context.Context, chapterID string) ([]Transcript, error) {
+	cursor, err := transcriptsCol.Find(ctx, bson.M{"chapter_id": chapterID},
+		options.Find().SetSort(bson.D{{Key: "lang", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	transcripts := []Transcript{}
+	if err := cursor.All(ctx, &transcripts); err != nil {
+		return nil, err
+	}
+	return transcripts, nil
+}
+
+// pickTranscript chooses the subtitles for lang along its fallback chain,
+// or when lang is "" for locale and then the first language there is
+func pickTranscript(transcripts []Transcript, lang, locale string) (Transcript, bool) {
+	wanted := lang
+	if wanted == "" {
+		wanted = locale
+	}
+	for _, candidate := range localeChain(wanted) {
+		for _, t := range transcripts {
+			if t.Lang == candidate {
+				return t, true
+			}
+		}
+	}
+	if lang == "" && len(transcripts) > 0 {
+		return transcripts[0], true
+	}
+	return Transcript{}, false
+}
+
+// transcriptLanguages lists the languages of a chapter's subtitles
+func transcriptLanguages(transcripts []Transcript) []string {
+	languages := make([]string, 0, len(transcripts))
+	for _, t := range transcripts {
+		languages = append(languages, t.Lang)
+	}
+	return languages
+}
+
+// syncCaptionLanguages adds tag to or removes it from a chapter's caption
+// languages, whatever its case there, and sets has_captions to whether any
+// are left
+func syncCaptionLanguages(ctx context.Context, chapterID, tag string, add bool) error {
+	languages := bson.M{"$ifNull": bson.A{"$accessibility.caption_languages", bson.A{}}}
+	kept := bson.M{"$filter": bson.M{
+		"input": languages,
+		"as":    "lang",
+		"cond":  bson.M{"$ne": bson.A{bson.M{"$toLower": "$$lang"}, normalizeLocale(tag)}},
+	}}
+	if add {
+		kept = bson.M{"$concatArrays": bson.A{kept, bson.A{bson.M{"$literal": tag}}}}
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"accessibility.caption_languages": kept, "updated_at": time.Now()}}},
+		{{Key: "$set", Value: bson.M{"accessibility.has_captions": bson.M{
+			"$gt": bson.A{bson.M{"$size": "$accessibility.caption_languages"}, 0},
+		}}}},
+	}
+	if _, err := chaptersCol.UpdateOne(ctx, tenantFilter(ctx, bson.M{"chapter_id": chapterID}), update); err != nil {
+		return err
+	}
+	chaptersChanged(ctx, chapterID)
+	return nil
+}
+
+// parseSubtitles reads a WebVTT or SRT file into segments ordered by start
+// time, telling which format it was by the WEBVTT header. Errors name the
+// line that couldn't be read.
+func parseSubtitles(source string) (string, []TranscriptSegment, error) {
+	source = strings.TrimPrefix(source, "\ufeff")
+	source = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(source)
+	lines := strings.Split(source, "\n")
+
+	format := SubtitlesSRT
+	i := 0
+	if first := lines[0]; first == "WEBVTT" || strings.HasPrefix(first, "WEBVTT ") || strings.HasPrefix(first, "WEBVTT\t") {
+		format = SubtitlesVTT
+		// The header runs to the first blank line
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+			i++
+		}
+	}
+
+	segments := []TranscriptSegment{}
+	for i < len(lines) {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+			i++
+		}
+		block := lines[start:i]
+
+		if format == SubtitlesVTT {
+			keyword := strings.Fields(block[0])[0]
+			if keyword == "NOTE" || keyword == "STYLE" || keyword == "REGION" {
+				continue
+			}
+		}
+
+		// A cue is an optional identifier (SRT's counter), the timing line,
+		// then its text
+		timing := -1
+		for j := 0; j < len(block) && j < 2; j++ {
+			if strings.Contains(block[j], "-->") {
+				timing = j
+				break
+			}
+		}
+		if timing < 0 {
+			return "", nil, fmt.Errorf("line %d: expected a cue timing like 00:00:01.000 --> 00:00:04.000", start+1)
+		}
+		segment, err := parseCueTiming(block[timing])
+		if err != nil {
+			return "", nil, fmt.Errorf("line %d: %v", start+timing+1, err)
+		}
+		segment.Text = cueText(block[timing+1:])
+		if segment.Text == "" {
+			continue
+		}
+		segments = append(segments, segment)
+		if len(segments) > maxTranscriptSegments {
+			return "", nil, fmt.Errorf("must have at most %d cues", maxTranscriptSegments)
+		}
+	}
+	if len(segments) == 0 {
+		return "", nil, fmt.Errorf("has no cues")
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	return format, segments, nil
+}
+
+// parseCueTiming reads "start --> end", ignoring WebVTT cue settings after
+// the end
+func parseCueTiming(line string) (TranscriptSegment, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	end := strings.Fields(parts[1])
+	if len(end) == 0 {
+		return TranscriptSegment{}, fmt.Errorf("cue timing has no end")
+	}
+	startMs, err := parseCueTimestamp(parts[0])
+	if err != nil {
+		return TranscriptSegment{}, err
+	}
+	endMs, err := parseCueTimestamp(end[0])
+	if err != nil {
+		return TranscriptSegment{}, err
+	}
+	if endMs <= startMs {
+		return TranscriptSegment{}, fmt.Errorf("cue ends before it starts")
+	}
+	return TranscriptSegment{Start: float64(startMs) / 1000, End: float64(endMs) / 1000}, nil
+}
+
+// parseCueTimestamp reads [hh:]mm:ss.ttt, or SRT's hh:mm:ss,ttt, into
+// milliseconds
+func parseCueTimestamp(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	invalid := fmt.Errorf("invalid timestamp %q", s)
+
+	clock, fraction, ok := strings.Cut(strings.Replace(s, ",", ".", 1), ".")
+	if !ok || len(fraction) != 3 {
+		return 0, invalid
+	}
+	fields := strings.Split(clock, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, invalid
+	}
+	var units [3]int64
+	offset := 3 - len(fields)
+	for k, field := range fields {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || n < 0 || len(field) < 2 {
+			return 0, invalid
+		}
+		units[offset+k] = n
+	}
+	ms, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil || ms < 0 || units[1] > 59 || units[2] > 59 {
+		return 0, invalid
+	}
+	return ((units[0]*60+units[1])*60+units[2])*1000 + ms, nil
+}
+
+// cueText is a cue's text lines as plain text, without styling tags
+func cueText(lines []string) string {
+	text := strings.Join(lines, "\n")
+	text = html.UnescapeString(cueTagPattern.ReplaceAllString(text, ""))
+	return strings.TrimSpace(text)
+}
+
+// renderSubtitles writes a transcript as a subtitle file and returns it
+// with its content type. An uploaded WebVTT file is returned as it came, so
+// its styling survives.
+func renderSubtitles(t Transcript, format string) (string, string) {
+	if format == SubtitlesVTT {
+		if t.Format == SubtitlesVTT {
+			return t.Source, "text/vtt; charset=utf-8"
+		}
+		var b strings.Builder
+		b.WriteString("WEBVTT\n\n")
+		// Segments are plain text, and WebVTT reads & and < as markup
+		escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+		for _, s := range t.Segments {
+			fmt.Fprintf(&b, "%s --> %s\n%s\n\n", cueTimestamp(s.Start, '.'), cueTimestamp(s.End, '.'), escape.Replace(s.Text))
+		}
+		return b.String(), "text/vtt; charset=utf-8"
+	}
+
+	var b strings.Builder
+	for i, s := range t.Segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n", i+1, cueTimestamp(s.Start, ','), cueTimestamp(s.End, ','), s.Text)
+	}
+	return b.String(), "application/x-subrip; charset=utf-8"
+}
+
+// cueTimestamp writes seconds as hh:mm:ss.ttt, with sep before the
+// milliseconds
+func cueTimestamp(seconds float64, sep byte) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
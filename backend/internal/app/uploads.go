@@ -0,0 +1,263 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ============================================================================
+// UPLOAD MODELS
+// ============================================================================
+
+// Images are uploaded with POST /api/uploads (avatars, by learners) or POST
+// /api/admin/uploads (question images and chapter thumbnails, by platform
+// admins) and kept in the media store. Users, questions and chapters refer
+// to them by upload ID, and responses carry a freshly signed URL for each.
+
+// Upload kinds
+const (
+	UploadAvatar           = "avatar"
+	UploadQuestionImage    = "question_image"
+	UploadChapterThumbnail = "chapter_thumbnail"
+)
+
+// maxUploadBytes bounds an uploaded image
+const maxUploadBytes = 5 << 20
+
+// uploadContentTypes are the image types accepted, as sniffed from the
+// file rather than taken from the client. SVG is left out: it can carry
+// scripts.
+var uploadContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Upload is an uploaded image
+type Upload struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID     string             `bson:"public_id" json:"id"`
+	OrgID        string             `bson:"org_id,omitempty" json:"-"`
+	UserID       string             `bson:"user_id,omitempty" json:"userId,omitempty"` // the uploader; empty for admins
+	Kind         string             `bson:"kind" json:"kind"`
+	ContentType  string             `bson:"content_type" json:"contentType"`
+	Size         int64              `bson:"size" json:"size"`
+	URL          string             `bson:"-" json:"url"`
+	URLExpiresAt time.Time          `bson:"-" json:"urlExpiresAt"`
+	CreatedAt    time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// ImageRef points at an uploaded image. Its URL is signed as the response
+// is written, so it never goes stale in a stored document.
+type ImageRef struct {
+	UploadID string `bson:"upload_id" json:"uploadId"`
+	URL      string `bson:"-" json:"url,omitempty"` // read only
+}
+
+func (ref ImageRef) MarshalJSON() ([]byte, error) {
+	type plain ImageRef
+	signed := plain(ref)
+	signed.URL, _ = signedMediaURL(uploadKey(ref.UploadID))
+	return json.Marshal(signed)
+}
+
+// ============================================================================
+// UPLOAD HANDLERS
+// ============================================================================
+
+// CreateUpload stores an image sent as multipart/form-data, with the file
+// in "file" and its use in "kind". Learners upload their avatars; admins
+// upload question images and chapter thumbnails.
+func CreateUpload(w http.ResponseWriter, r *http.Request) {
+	createUpload(w, r, authUserID(r.Context()) == "")
+}
+
+// CreateContentUpload is CreateUpload for the admin routes, where staff
+// signed in with an access token upload content too
+func CreateContentUpload(w http.ResponseWriter, r *http.Request) {
+	createUpload(w, r, true)
+}
+
+// createUpload stores an upload, a question image or chapter thumbnail if
+// content is set and an avatar otherwise
+func createUpload(w http.ResponseWriter, r *http.Request, content bool) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		sendErrorCode(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType, "Content-Type must be multipart/form-data")
+		return
+	}
+
+	// Room for the other fields and the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+64<<10)
+	err := r.ParseMultipartForm(maxUploadBytes)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads can be at most %d MB", maxUploadBytes>>20))
+		return
+	} else if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid multipart body")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	ctx := r.Context()
+	self := authUserID(ctx)
+	kind := strings.TrimSpace(r.FormValue("kind"))
+
+	var errs fieldErrors
+	switch {
+	case kind == "":
+		errs.add("kind", CodeRequired, "is required")
+	case !content && kind != UploadAvatar:
+		errs.add("kind", CodeUnknownValue, "must be 'avatar'")
+	case content && kind != UploadQuestionImage && kind != UploadChapterThumbnail:
+		errs.add("kind", CodeUnknownValue, "must be 'question_image' or 'chapter_thumbnail'")
+	}
+
+	var body []byte
+	file, header, err := r.FormFile("file")
+	if err == http.ErrMissingFile {
+		errs.add("file", CodeRequired, "is required")
+	} else if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid multipart body")
+		return
+	} else {
+		defer file.Close()
+		if header.Size > maxUploadBytes {
+			sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Uploads can be at most %d MB", maxUploadBytes>>20))
+			return
+		}
+		if body, err = io.ReadAll(file); err != nil {
+			sendError(w, http.StatusBadRequest, "Failed to read the uploaded file")
+			return
+		}
+	}
+
+	contentType := http.DetectContentType(body)
+	if body != nil && !uploadContentTypes[contentType] {
+		errs.add("file", CodeInvalid, "must be a JPEG, PNG, GIF or WebP image")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	upload := Upload{
+		PublicID:    newPublicID(),
+		OrgID:       orgID(ctx),
+		UserID:      self,
+		Kind:        kind,
+		ContentType: contentType,
+		Size:        int64(len(body)),
+		CreatedAt:   time.Now(),
+	}
+	if err := mediaStore.Put(ctx, uploadKey(upload.PublicID), contentType, body); err != nil {
+		log.Printf("❌ Error storing upload %s: %v", upload.PublicID, err)
+		sendError(w, http.StatusBadGateway, "Failed to store the upload")
+		return
+	}
+	if _, err := uploadsCol.InsertOne(ctx, upload); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to save upload")
+		return
+	}
+
+	log.Printf("✅ Upload stored: id=%s, kind=%s, type=%s, bytes=%d, user=%s", upload.PublicID, kind, contentType, upload.Size, self)
+
+	upload.URL, upload.URLExpiresAt = signedMediaURL(uploadKey(upload.PublicID))
+	response := ApiResponse{
+		Success: true,
+		Message: "Upload stored successfully",
+		Data:    upload,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// GetMedia serves an upload kept in the local media store to the holder of
+// a signed URL
+func GetMedia(w http.ResponseWriter, r *http.Request) {
+	store, ok := mediaStore.(localMediaStore)
+	uploadID := mux.Vars(r)["uploadId"]
+	if !ok || !isPublicID(uploadID) {
+		sendError(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+
+	key := uploadKey(uploadID)
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || query.Get("sig") != localMediaSignature(key, query.Get("expires")) {
+		sendError(w, http.StatusForbidden, "Invalid signature")
+		return
+	}
+	remaining := time.Until(time.Unix(expires, 0))
+	if remaining <= 0 {
+		sendError(w, http.StatusForbidden, "This link has expired")
+		return
+	}
+
+	file, err := os.Open(store.path(key))
+	if err != nil {
+		sendError(w, http.StatusNotFound, "Upload not found")
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to read upload")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(remaining.Seconds())))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), file) // sniffs the content type
+}
+
+// ============================================================================
+// UPLOAD HELPERS
+// ============================================================================
+
+// uploadKey is where an upload is kept in the media store
+func uploadKey(uploadID string) string {
+	return "uploads/" + uploadID
+}
+
+// checkImageRef adds a field error unless ref names an upload of kind, and
+// with owner set, one owner uploaded. It only returns an error when the
+// database can't be checked.
+func checkImageRef(ctx context.Context, errs *fieldErrors, field string, ref *ImageRef, kind, owner string) error {
+	if ref == nil {
+		return nil
+	}
+	ref.UploadID, ref.URL = strings.TrimSpace(ref.UploadID), ""
+	if ref.UploadID == "" {
+		errs.add(field, CodeRequired, "is required")
+		return nil
+	}
+	filter := bson.M{"public_id": ref.UploadID, "kind": kind}
+	if owner != "" {
+		filter["user_id"] = owner
+	}
+	count, err := uploadsCol.CountDocuments(ctx, tenantFilter(ctx, filter))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		errs.add(field, CodeUnknownValue, "is not an upload of the right kind")
+	}
+	return nil
+}
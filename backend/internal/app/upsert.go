@@ -0,0 +1,22 @@
+package app
+
+import "go.mongodb.org/mongo-driver/mongo"
+
+// ============================================================================
+// UPSERTS
+// ============================================================================
+
+// maxUpsertRetries bounds retries of an upsert that lost an insert race
+const maxUpsertRetries = 2
+
+// retryOnDuplicateKey reruns an upsert that failed with a duplicate key
+// error. Two concurrent upserts of a missing document can both try to
+// insert it; the unique index rejects one of them, and on retry that one
+// matches the document the other inserted instead.
+func retryOnDuplicateKey(upsert func() error) error {
+	err := upsert()
+	for i := 0; i < maxUpsertRetries && mongo.IsDuplicateKeyError(err); i++ {
+		err = upsert()
+	}
+	return err
+}
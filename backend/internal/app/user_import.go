@@ -0,0 +1,467 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ============================================================================
+// USER IMPORT MODELS
+// ============================================================================
+
+// Schools onboard a whole class at once by importing its users from a CSV
+// file or a JSON list. Each row is checked on its own and the response
+// reports what happened to every row, so a file with a few bad lines still
+// imports the rest. Run it with ?dryRun=true first to see the report without
+// writing anything.
+
+// Import row outcomes
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated" // an existing user, with ?onDuplicate=update
+	ImportSkipped = "skipped" // an existing user, left as is
+	ImportFailed  = "failed"
+)
+
+const (
+	// maxImportRows bounds the users of one import
+	maxImportRows = 2000
+	// maxImportBytes bounds an import's request body
+	maxImportBytes = 5 << 20
+)
+
+// ImportUser is one row of an import. A cohort needs ?courseId=.
+type ImportUser struct {
+	UserID string `json:"userId"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Cohort string `json:"cohort"`
+}
+
+type ImportUsersRequest struct {
+	Users []ImportUser `json:"users"`
+}
+
+// ImportResult reports an import row by row
+type ImportResult struct {
+	DryRun   bool        `json:"dryRun"`
+	CourseID string      `json:"courseId,omitempty"`
+	Created  int         `json:"created"`
+	Updated  int         `json:"updated"`
+	Skipped  int         `json:"skipped"`
+	Failed   int         `json:"failed"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+// ImportRow is what happened to one row
+type ImportRow struct {
+	Row      int          `json:"row"` // 1-based, not counting a CSV header
+	UserID   string       `json:"userId"`
+	Status   string       `json:"status"`
+	Enrolled bool         `json:"enrolled,omitempty"` // in the ?courseId= course
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// ============================================================================
+// USER IMPORT HANDLERS
+// ============================================================================
+
+// ImportUsers creates the users of a CSV (text/csv, with a header row of
+// userId, name, email and cohort) or JSON body. Users that already exist are
+// skipped, or with ?onDuplicate=update get the row's name and email.
+// ?courseId= enrolls every imported user in a course, in the row's cohort
+// if it has one.
+func ImportUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	courseID := query.Get("courseId")
+
+	var errs fieldErrors
+	dryRun := false
+	if v := query.Get("dryRun"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			errs.add("dryRun", CodeInvalidType, "must be a boolean")
+		}
+	}
+	onDuplicate := query.Get("onDuplicate")
+	if onDuplicate == "" {
+		onDuplicate = "skip"
+	}
+	if onDuplicate != "skip" && onDuplicate != "update" {
+		errs.add("onDuplicate", CodeInvalid, "must be skip or update")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	var users []ImportUser
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "text/csv":
+		body, err := io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			sendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Imports can be at most %d MB", maxImportBytes>>20))
+			return
+		} else if err != nil {
+			sendError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if users, err = parseImportCSV(body); err != nil {
+			errs.add("body", CodeInvalid, err.Error())
+			sendValidationErrors(w, errs)
+			return
+		}
+	case isJSONContentType(r.Header.Get("Content-Type")):
+		var req ImportUsersRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		users = req.Users
+	default:
+		sendErrorCode(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType,
+			"Content-Type must be application/json or text/csv")
+		return
+	}
+	if len(users) == 0 {
+		errs.add("users", CodeRequired, "must have at least one user")
+	} else if len(users) > maxImportRows {
+		errs.add("users", CodeOutOfRange, fmt.Sprintf("must have at most %d users", maxImportRows))
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	var course *Course
+	cohorts := map[string]bool{}
+	if courseID != "" {
+		var err error
+		if course, err = findCourse(ctx, courseID); err == mongo.ErrNoDocuments {
+			sendErrorCode(w, http.StatusNotFound, ErrCodeCourseNotFound, "Course not found")
+			return
+		} else if err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if cohorts, err = courseCohortIDs(ctx, courseID); err != nil {
+			sendError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+	}
+
+	existing, emailOwners, err := importLookups(ctx, users)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	result := ImportResult{DryRun: dryRun, CourseID: courseID, Rows: []ImportRow{}}
+	locale := responseLocale(w)
+	seenIDs, seenEmails := map[string]bool{}, map[string]bool{}
+	for i := range users {
+		u := &users[i]
+		u.UserID = strings.TrimSpace(u.UserID)
+		u.Name = strings.TrimSpace(u.Name)
+		u.Email = strings.ToLower(strings.TrimSpace(u.Email))
+		u.Cohort = strings.TrimSpace(u.Cohort)
+
+		row := ImportRow{Row: i + 1, UserID: u.UserID}
+		user, exists := existing[u.UserID]
+
+		var rowErrs fieldErrors
+		rowErrs.required("userId", u.UserID)
+		switch {
+		case seenIDs[u.UserID] && u.UserID != "":
+			rowErrs.add("userId", CodeInvalid, "appears more than once in the import")
+		case exists && userOrgID(user) != orgID(ctx):
+			rowErrs.add("userId", CodeTaken, "belongs to another organization")
+		}
+		if utf8.RuneCountInString(u.Name) > maxDisplayNameLength {
+			rowErrs.add("name", CodeOutOfRange, "must be at most 80 characters")
+		}
+		if u.Email != "" {
+			switch {
+			case !isEmailAddress(u.Email):
+				rowErrs.add("email", CodeInvalid, "must be an email address")
+			case seenEmails[u.Email]:
+				rowErrs.add("email", CodeInvalid, "appears more than once in the import")
+			case emailOwners[u.Email] != "" && emailOwners[u.Email] != u.UserID:
+				rowErrs.add("email", CodeTaken, "is already in use")
+			}
+		}
+		if u.Cohort != "" {
+			if course == nil {
+				rowErrs.add("cohort", CodeInvalid, "needs a courseId")
+			} else if !cohorts[u.Cohort] {
+				rowErrs.add("cohort", CodeUnknownValue, "is not a cohort of this course")
+			}
+		}
+		seenIDs[u.UserID] = true
+		if u.Email != "" {
+			seenEmails[u.Email] = true
+		}
+
+		switch {
+		case len(rowErrs) > 0:
+			row.Status = ImportFailed
+			for _, e := range rowErrs {
+				e.Message = T(locale, e.Message)
+				row.Errors = append(row.Errors, e)
+			}
+		case !exists:
+			row.Status = ImportCreated
+		case onDuplicate == "update":
+			row.Status = ImportUpdated
+		default:
+			row.Status = ImportSkipped
+		}
+
+		if row.Status != ImportFailed && !dryRun {
+			if err := applyImportRow(ctx, *u, user, &row); err != nil {
+				log.Printf("❌ Error importing user %s: %v", u.UserID, err)
+				row.Status = ImportFailed
+				row.Errors = []FieldError{{Field: "userId", Code: CodeInvalid, Message: T(locale, "could not be saved")}}
+			}
+		}
+		if row.Status != ImportFailed && course != nil {
+			row.Enrolled = true
+			if !dryRun {
+				if err := enrollImportedUser(ctx, *course, *u); err != nil {
+					log.Printf("❌ Error enrolling imported user %s: %v", u.UserID, err)
+					row.Enrolled = false
+				}
+			}
+		}
+
+		switch row.Status {
+		case ImportCreated:
+			result.Created++
+		case ImportUpdated:
+			result.Updated++
+		case ImportSkipped:
+			result.Skipped++
+		default:
+			result.Failed++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	message := "Users imported"
+	if dryRun {
+		message = "Import checked, nothing was written"
+	} else {
+		log.Printf("✅ Users imported: created=%d, updated=%d, skipped=%d, failed=%d",
+			result.Created, result.Updated, result.Skipped, result.Failed)
+		recordAudit(ctx, AuditEntry{
+			Action: AuditUsersImported,
+			Target: AuditTarget{Type: "organization", ID: orgID(ctx)},
+			Details: bson.M{
+				"course_id":    courseID,
+				"on_duplicate": onDuplicate,
+				"created":      result.Created,
+				"updated":      result.Updated,
+				"skipped":      result.Skipped,
+				"failed":       result.Failed,
+			},
+		})
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: message,
+		Data:    result,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// USER IMPORT HELPERS
+// ============================================================================
+
+// importColumns maps the CSV header names an import understands, lowercased
+// and without separators, to the column they fill
+var importColumns = map[string]string{
+	"userid": "userId",
+	"name":   "name",
+	"email":  "email",
+	"cohort": "cohort",
+}
+
+// parseImportCSV reads an import's rows from CSV with a header row. Columns
+// it doesn't know are ignored.
+func parseImportCSV(body []byte) ([]ImportUser, error) {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")) // spreadsheets often save a BOM
+	if !utf8.Valid(body) {
+		return nil, fmt.Errorf("must be UTF-8 text")
+	}
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	columns := make([]string, len(header))
+	hasUserID := false
+	for i, name := range header {
+		key := strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		columns[i] = importColumns[key]
+		hasUserID = hasUserID || columns[i] == "userId"
+	}
+	if !hasUserID {
+		return nil, fmt.Errorf("the header row must have a userId column")
+	}
+
+	var users []ImportUser
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var u ImportUser
+		for i, value := range record {
+			switch columns[i] {
+			case "userId":
+				u.UserID = value
+			case "name":
+				u.Name = value
+			case "email":
+				u.Email = value
+			case "cohort":
+				u.Cohort = value
+			}
+		}
+		users = append(users, u)
+		if len(users) > maxImportRows {
+			break // reported by the caller
+		}
+	}
+	return users, nil
+}
+
+// importLookups loads the users an import names, in any organization, and
+// who owns each email address it uses
+func importLookups(ctx context.Context, users []ImportUser) (map[string]User, map[string]string, error) {
+	var userIDs, emails []string
+	for _, u := range users {
+		userIDs = append(userIDs, strings.TrimSpace(u.UserID))
+		if email := strings.ToLower(strings.TrimSpace(u.Email)); email != "" {
+			emails = append(emails, email)
+		}
+	}
+
+	existing := map[string]User{}
+	cursor, err := usersCol.Find(ctx, bson.M{"user_id": bson.M{"$in": uniqueStrings(userIDs)}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var found []User
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, nil, err
+	}
+	for _, user := range found {
+		existing[user.UserID] = user
+	}
+
+	owners := map[string]string{}
+	if len(emails) == 0 {
+		return existing, owners, nil
+	}
+	cursor, err = usersCol.Find(ctx, bson.M{"email": bson.M{"$in": uniqueStrings(emails)}})
+	if err != nil {
+		return nil, nil, err
+	}
+	found = nil
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, nil, err
+	}
+	for _, user := range found {
+		owners[user.Email] = user.UserID
+	}
+	return existing, owners, nil
+}
+
+// applyImportRow creates or updates the user of a checked row, as its
+// status says. A user created since the check fails the row.
+func applyImportRow(ctx context.Context, u ImportUser, existing User, row *ImportRow) error {
+	now := time.Now()
+	switch row.Status {
+	case ImportCreated:
+		name := u.Name
+		if name == "" {
+			name = u.UserID
+		}
+		user := User{
+			PublicID:  newPublicID(),
+			UserID:    u.UserID,
+			OrgID:     orgID(ctx),
+			Name:      name,
+			Email:     u.Email,
+			Role:      RoleLearner,
+			Status:    UserActive,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if _, err := usersCol.InsertOne(ctx, user); err != nil {
+			return err
+		}
+		welcomeUser(ctx, user)
+
+	case ImportUpdated:
+		set := bson.M{"updated_at": now}
+		unset := bson.M{}
+		if u.Name != "" {
+			set["name"] = u.Name
+		}
+		if u.Email != "" && u.Email != existing.Email {
+			set["email"] = u.Email
+			unset["email_verified_at"] = "" // the new address isn't verified
+		}
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		if _, err := usersCol.UpdateOne(ctx, tenantFilter(ctx, bson.M{"user_id": u.UserID}), update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enrollImportedUser enrolls an imported user in the import's course and
+// puts them in their row's cohort
+func enrollImportedUser(ctx context.Context, course Course, u ImportUser) error {
+	if _, err := enrollUser(ctx, u.UserID, course.CourseID, EnrollmentSourceAdmin, course.AccessDays); err != nil {
+		return err
+	}
+	if u.Cohort == "" {
+		return nil
+	}
+	_, err := enrollmentsCol.UpdateOne(ctx,
+		tenantFilter(ctx, bson.M{"course_id": course.CourseID, "user_id": u.UserID, "status": EnrollmentActive}),
+		bson.M{"$set": bson.M{"cohort": u.Cohort}})
+	return err
+}
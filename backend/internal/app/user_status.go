@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// USER STATUS
+// ============================================================================
+
+// User account states
+const (
+	UserActive      = "active"
+	UserSuspended   = "suspended"   // blocked by an admin, can be lifted
+	UserDeactivated = "deactivated" // closed account
+)
+
+// Error codes for blocked accounts
+const (
+	ErrCodeAccountSuspended   = "account_suspended"
+	ErrCodeAccountDeactivated = "account_deactivated"
+)
+
+type UpdateUserStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// accountStatus treats users created before statuses existed as active
+func accountStatus(user User) string {
+	if user.Status == "" {
+		return UserActive
+	}
+	return user.Status
+}
+
+// sendAccountBlocked responds 403 with the code for a blocked account
+func sendAccountBlocked(w http.ResponseWriter, status string) {
+	if status == UserSuspended {
+		sendErrorCode(w, http.StatusForbidden, ErrCodeAccountSuspended, "This account has been suspended")
+		return
+	}
+	sendErrorCode(w, http.StatusForbidden, ErrCodeAccountDeactivated, "This account has been deactivated")
+}
+
+// checkUserActive sends a 403 and returns false when the user exists but is
+// suspended or deactivated, and a 404 when they belong to another
+// organization. Unknown users pass; handlers deal with them.
+func checkUserActive(ctx context.Context, w http.ResponseWriter, userID string) bool {
+	var user User
+	var err error
+	if mongoConnected() {
+		err = usersCol.FindOne(ctx, bson.M{"user_id": userID},
+			options.FindOne().SetProjection(bson.M{"status": 1, "org_id": 1})).Decode(&user)
+	} else {
+		// Without MongoDB there is only the default organization, whose
+		// users the store finds
+		user, err = userStore.Get(ctx, userID)
+	}
+	if err != nil {
+		if err != mongo.ErrNoDocuments && err != ErrNotFound {
+			log.Printf("❌ Error checking status of user %s: %v", userID, err)
+		}
+		return true
+	}
+
+	if !checkUserInOrg(ctx, w, user) {
+		return false
+	}
+	if status := accountStatus(user); status != UserActive {
+		sendAccountBlocked(w, status)
+		return false
+	}
+	return true
+}
+
+// ActiveUserMiddleware rejects requests from a suspended or deactivated
+// account, so its access tokens stop working at once, and requests on routes
+// scoped to a {userId} that is blocked or belongs to another organization.
+// Admins and staff name users other than themselves.
+func ActiveUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		self := authUserID(ctx)
+		if self != "" && !checkUserActive(ctx, w, self) {
+			return
+		}
+		if named := mux.Vars(r)["userId"]; named != "" && named != self && !checkUserActive(ctx, w, named) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ============================================================================
+// ADMIN USER STATUS HANDLERS
+// ============================================================================
+
+// UpdateUserStatus suspends, deactivates or reactivates a user
+func UpdateUserStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	var req UpdateUserStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	switch req.Status {
+	case UserActive, UserSuspended, UserDeactivated:
+	default:
+		errs.add("status", CodeUnknownValue, "must be 'active', 'suspended' or 'deactivated'")
+	}
+	if req.Status == UserSuspended {
+		errs.required("reason", req.Reason)
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	now := time.Now()
+	var user User
+	err := usersCol.FindOneAndUpdate(ctx, tenantFilter(ctx, bson.M{"user_id": userID}), bson.M{"$set": bson.M{
+		"status":            req.Status,
+		"status_reason":     req.Reason,
+		"status_changed_at": now,
+		"updated_at":        now,
+	}}, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update user status")
+		return
+	}
+
+	recordAudit(ctx, AuditEntry{
+		OrgID:  userOrgID(user),
+		Action: AuditUserStatusChanged,
+		Target: AuditTarget{Type: "user", ID: userID},
+		Changes: auditDiff(
+			bson.M{"status": accountStatus(user), "status_reason": user.StatusReason},
+			bson.M{"status": req.Status, "status_reason": req.Reason}),
+	})
+	user.Status, user.StatusReason, user.UpdatedAt = req.Status, req.Reason, now
+
+	log.Printf("✅ User status changed: user=%s, status=%s, reason=%q", userID, req.Status, req.Reason)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "User status updated successfully",
+		Data:    user,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
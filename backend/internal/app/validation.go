@@ -0,0 +1,160 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ============================================================================
+// VALIDATION ERRORS
+// ============================================================================
+
+// Field error codes
+const (
+	CodeRequired     = "required"
+	CodeInvalid      = "invalid"
+	CodeInvalidType  = "invalid_type"
+	CodeOutOfRange   = "out_of_range"
+	CodeMalformed    = "malformed_json"
+	CodeEmptyBody    = "empty_body"
+	CodeUnknownValue = "unknown_value"
+	CodeTaken        = "taken" // must be unique and another record has it
+)
+
+// ErrCodeUnsupportedMediaType is returned for request bodies that aren't JSON
+const ErrCodeUnsupportedMediaType = "unsupported_media_type"
+
+// FieldError describes one problem with one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail,omitempty"` // untranslated specifics, e.g. where the JSON broke
+}
+
+// fieldErrors collects field problems while validating a request
+type fieldErrors []FieldError
+
+func (e *fieldErrors) add(field, code, message string) {
+	*e = append(*e, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Error lets a service hand validation problems back to its handler
+func (e fieldErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// required flags a blank string field
+func (e *fieldErrors) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		e.add(field, CodeRequired, "is required")
+	}
+}
+
+// sendValidationErrors responds 400 with one entry per failing field
+func sendValidationErrors(w http.ResponseWriter, errs []FieldError) {
+	locale := responseLocale(w)
+	translated := make([]FieldError, len(errs))
+	for i, e := range errs {
+		e.Message = T(locale, e.Message)
+		translated[i] = e
+	}
+
+	response := ApiResponse{
+		Success: false,
+		Code:    ErrCodeValidationFailed,
+		Message: "Validation failed",
+		Errors:  translated,
+	}
+	sendJSON(w, http.StatusBadRequest, response)
+}
+
+// decodeJSON decodes the request body into dst. On failure it sends a 415
+// for a body that isn't declared as JSON, or a validation error response
+// explaining what was wrong, and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		sendErrorCode(w, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMediaType,
+			"Content-Type must be application/json")
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		sendValidationErrors(w, []FieldError{decodeError(err)})
+		return false
+	}
+	return true
+}
+
+// decodeError translates encoding/json errors into field errors
+func decodeError(err error) FieldError {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return FieldError{
+			Field:   field,
+			Code:    CodeInvalidType,
+			Message: "must be " + jsonTypeName(typeErr.Type.Kind().String()),
+			Detail:  "got " + typeErr.Value,
+		}
+	case errors.As(err, &syntaxErr):
+		return FieldError{
+			Field:   "body",
+			Code:    CodeMalformed,
+			Message: "is not valid JSON",
+			Detail:  fmt.Sprintf("%s at byte %d", syntaxErr.Error(), syntaxErr.Offset),
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return FieldError{Field: "body", Code: CodeMalformed, Message: "is not valid JSON", Detail: "unexpected end of input"}
+	case errors.Is(err, io.EOF):
+		return FieldError{Field: "body", Code: CodeEmptyBody, Message: "is required"}
+	default:
+		return FieldError{Field: "body", Code: CodeInvalid, Message: "could not be decoded"}
+	}
+}
+
+// isJSONContentType accepts application/json and +json types, with any
+// parameters such as charset
+func isJSONContentType(header string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" ||
+		(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// jsonTypeName describes a Go kind in JSON terms
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "string":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64":
+		return "an integer"
+	case "float32", "float64":
+		return "a number"
+	case "slice", "array":
+		return "an array"
+	case "map", "struct":
+		return "an object"
+	default:
+		return "a " + kind
+	}
+}
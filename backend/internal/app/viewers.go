@@ -0,0 +1,535 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// VIEWER MODELS
+// ============================================================================
+
+// Viewer relationships
+const (
+	ViewerRelationshipManager = "manager"
+	ViewerRelationshipParent  = "parent"
+)
+
+// Grant states. A viewer asks for access (pending); the learner approves
+// (active) or declines. Either side can later revoke an active grant.
+const (
+	GrantPending  = "pending"
+	GrantActive   = "active"
+	GrantDeclined = "declined"
+	GrantRevoked  = "revoked"
+)
+
+// Digest frequencies
+const (
+	DigestNone   = "none"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// ViewerGrant gives a manager or parent read-only access to a learner's progress
+type ViewerGrant struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID        string             `bson:"public_id,omitempty" json:"id"`
+	OrgID           string             `bson:"org_id,omitempty" json:"-"`
+	LearnerID       string             `bson:"learner_id" json:"learnerId"`
+	ViewerID        string             `bson:"viewer_id" json:"viewerId"`
+	Relationship    string             `bson:"relationship" json:"relationship"`
+	Status          string             `bson:"status" json:"status"`
+	DigestFrequency string             `bson:"digest_frequency" json:"digestFrequency"`
+	DigestEmail     string             `bson:"digest_email,omitempty" json:"digestEmail,omitempty"`
+	LastDigestAt    time.Time          `bson:"last_digest_at,omitempty" json:"lastDigestAt,omitempty"`
+	RespondedAt     time.Time          `bson:"responded_at,omitempty" json:"respondedAt,omitempty"`
+	CreatedAt       time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+type RequestViewerAccessRequest struct {
+	ViewerID     string `json:"viewerId"`
+	LearnerID    string `json:"learnerId"`
+	Relationship string `json:"relationship"`
+}
+
+type RespondViewerGrantRequest struct {
+	Action string `json:"action"` // "approve", "decline" or "revoke"
+}
+
+type UpdateDigestRequest struct {
+	Frequency string `json:"frequency"`
+	Email     string `json:"email"`
+}
+
+// LearnerProgressReport is the read-only view a viewer gets of a learner
+type LearnerProgressReport struct {
+	LearnerID         string     `json:"learnerId"`
+	Name              string     `json:"name"`
+	TotalChapters     int64      `json:"totalChapters"`
+	CompletedChapters int        `json:"completedChapters"`
+	Progress          []Progress `json:"progress"`
+}
+
+// ============================================================================
+// CONSENT FLOW HANDLERS
+// ============================================================================
+
+// RequestViewerAccess creates a pending grant the learner must approve
+func RequestViewerAccess(w http.ResponseWriter, r *http.Request) {
+	var req RequestViewerAccessRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	viewerID, ok := actingUserID(w, r, req.ViewerID)
+	if !ok {
+		return
+	}
+	req.ViewerID = viewerID
+	req.LearnerID = resolveUserKey(r.Context(), req.LearnerID)
+
+	// Validate input
+	var errs fieldErrors
+	errs.required("viewerId", req.ViewerID)
+	errs.required("learnerId", req.LearnerID)
+	if req.ViewerID != "" && req.ViewerID == req.LearnerID {
+		errs.add("viewerId", CodeInvalid, "cannot be the learner")
+	}
+	if req.Relationship != ViewerRelationshipManager && req.Relationship != ViewerRelationshipParent {
+		errs.add("relationship", CodeUnknownValue, "must be 'manager' or 'parent'")
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	// Both in the request's organization: a viewer never reaches a learner
+	// of another
+	count, err := usersCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"user_id": bson.M{"$in": []string{req.ViewerID, req.LearnerID}}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count < 2 {
+		sendError(w, http.StatusNotFound, "Viewer or learner not found")
+		return
+	}
+
+	// Re-requesting after a decline or revoke starts a fresh consent request
+	filter := bson.M{
+		"learner_id": req.LearnerID,
+		"viewer_id":  req.ViewerID,
+		"status":     bson.M{"$in": []string{GrantDeclined, GrantRevoked}},
+	}
+	if _, err := viewerGrantsCol.DeleteMany(ctx, tenantFilter(ctx, filter)); err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	grant := ViewerGrant{
+		PublicID:        newPublicID(),
+		OrgID:           orgID(ctx),
+		LearnerID:       req.LearnerID,
+		ViewerID:        req.ViewerID,
+		Relationship:    req.Relationship,
+		Status:          GrantPending,
+		DigestFrequency: DigestNone,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+
+	result, err := viewerGrantsCol.InsertOne(ctx, grant)
+	if mongo.IsDuplicateKeyError(err) {
+		sendError(w, http.StatusConflict, "Access has already been requested")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to request access")
+		return
+	}
+	grant.ID = result.InsertedID.(primitive.ObjectID)
+
+	log.Printf("✅ Viewer access requested: viewer=%s, learner=%s", req.ViewerID, req.LearnerID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Access requested. Waiting for learner consent",
+		Data:    grant,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// GetLearnerViewerGrants lists everyone who has or requested access to a learner
+func GetLearnerViewerGrants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	grants, err := findViewerGrants(r.Context(), bson.M{"learner_id": userID})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch viewer grants")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Viewer grants fetched successfully",
+		Data:    grants,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// RespondViewerGrant lets the learner approve, decline or revoke a grant
+func RespondViewerGrant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+	filter, ok := idFilter(vars["grantId"])
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid grant ID")
+		return
+	}
+
+	var req RespondViewerGrantRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var from, to string
+	switch req.Action {
+	case "approve":
+		from, to = GrantPending, GrantActive
+	case "decline":
+		from, to = GrantPending, GrantDeclined
+	case "revoke":
+		from, to = GrantActive, GrantRevoked
+	default:
+		sendError(w, http.StatusBadRequest, "Action must be 'approve', 'decline' or 'revoke'")
+		return
+	}
+
+	filter["learner_id"] = userID
+	updateViewerGrantStatus(r.Context(), w, filter, from, to)
+}
+
+// RevokeViewerAccess lets a viewer drop their own active grant
+func RevokeViewerAccess(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	viewerID := vars["viewerId"]
+	filter, ok := idFilter(vars["grantId"])
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid grant ID")
+		return
+	}
+
+	filter["viewer_id"] = viewerID
+	updateViewerGrantStatus(r.Context(), w, filter, GrantActive, GrantRevoked)
+}
+
+// updateViewerGrantStatus moves a grant between consent states, refusing
+// transitions that don't start from the expected state
+func updateViewerGrantStatus(ctx context.Context, w http.ResponseWriter, filter bson.M, from, to string) {
+	filter["status"] = from
+	update := bson.M{"$set": bson.M{
+		"status":       to,
+		"responded_at": time.Now(),
+		"updated_at":   time.Now(),
+	}}
+
+	var grant ViewerGrant
+	err := viewerGrantsCol.FindOneAndUpdate(ctx, tenantFilter(ctx, filter), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&grant)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, fmt.Sprintf("No %s grant found", from))
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update viewer grant")
+		return
+	}
+
+	log.Printf("✅ Viewer grant %s: viewer=%s, learner=%s", to, grant.ViewerID, grant.LearnerID)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Viewer grant updated successfully",
+		Data:    grant,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// VIEWER-SCOPED HANDLERS
+// ============================================================================
+
+// GetViewerLearners lists the grants a viewer holds or has requested
+func GetViewerLearners(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	viewerID := vars["viewerId"]
+
+	grants, err := findViewerGrants(r.Context(), bson.M{"viewer_id": viewerID})
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch learners")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Learners fetched successfully",
+		Data:    grants,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetViewerLearnerProgress returns a learner's progress to a viewer with an active grant
+func GetViewerLearnerProgress(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	viewerID := vars["viewerId"]
+	learnerID := vars["learnerId"]
+
+	ctx := r.Context()
+
+	count, err := viewerGrantsCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{
+		"viewer_id":  viewerID,
+		"learner_id": learnerID,
+		"status":     GrantActive,
+	}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count == 0 {
+		sendError(w, http.StatusForbidden, "You do not have access to this learner's progress")
+		return
+	}
+
+	report, err := buildLearnerProgressReport(ctx, learnerID)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Learner not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to build progress report")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Learner progress fetched successfully",
+		Data:    report,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// UpdateViewerDigest configures the scheduled email digest for a grant
+func UpdateViewerDigest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	viewerID := vars["viewerId"]
+	filter, ok := idFilter(vars["grantId"])
+	if !ok {
+		sendError(w, http.StatusBadRequest, "Invalid grant ID")
+		return
+	}
+
+	var req UpdateDigestRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	switch req.Frequency {
+	case DigestNone:
+		req.Email = ""
+	case DigestDaily, DigestWeekly:
+		if _, err := mail.ParseAddress(req.Email); err != nil {
+			sendError(w, http.StatusBadRequest, "A valid email is required for digests")
+			return
+		}
+	default:
+		sendError(w, http.StatusBadRequest, "Frequency must be 'none', 'daily' or 'weekly'")
+		return
+	}
+
+	ctx := r.Context()
+
+	filter["viewer_id"] = viewerID
+	filter["status"] = GrantActive
+
+	var grant ViewerGrant
+	err := viewerGrantsCol.FindOneAndUpdate(ctx, tenantFilter(ctx, filter),
+		bson.M{"$set": bson.M{
+			"digest_frequency": req.Frequency,
+			"digest_email":     strings.TrimSpace(req.Email),
+			"updated_at":       time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&grant)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "No active grant found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update digest settings")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Digest settings updated successfully",
+		Data:    grant,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// VIEWER HELPERS
+// ============================================================================
+
+// findViewerGrants returns the grants matching filter in the request's
+// organization, newest first
+func findViewerGrants(ctx context.Context, filter bson.M) ([]ViewerGrant, error) {
+	cursor, err := viewerGrantsCol.Find(ctx, tenantFilter(ctx, filter), options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	grants := []ViewerGrant{}
+	if err := cursor.All(ctx, &grants); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func buildLearnerProgressReport(ctx context.Context, learnerID string) (*LearnerProgressReport, error) {
+	var user User
+	if err := usersCol.FindOne(ctx, tenantFilter(ctx, bson.M{"user_id": learnerID})).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	totalChapters, err := chaptersCol.CountDocuments(ctx, liveChapters(ctx, bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := progressCol.Find(ctx, tenantFilter(ctx, bson.M{"user_id": learnerID}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	progress := []Progress{}
+	if err := cursor.All(ctx, &progress); err != nil {
+		return nil, err
+	}
+
+	completed := 0
+	for _, p := range progress {
+		if p.ChapterCompleted {
+			completed++
+		}
+	}
+
+	return &LearnerProgressReport{
+		LearnerID:         user.UserID,
+		Name:              user.Name,
+		TotalChapters:     totalChapters,
+		CompletedChapters: completed,
+		Progress:          progress,
+	}, nil
+}
+
+// ============================================================================
+// DIGEST SCHEDULER
+// ============================================================================
+
+// digestDue reports whether a grant's next digest should go out at now.
+// Digests go out from digestHour in the viewer's timezone, once per local
+// day or once every seven local days.
+func digestDue(grant ViewerGrant, now time.Time, loc *time.Location) bool {
+	if now.In(loc).Hour() < digestHour {
+		return false
+	}
+	days := 1
+	if grant.DigestFrequency == DigestWeekly {
+		days = 7
+	}
+	return grant.LastDigestAt.IsZero() || daysBetween(grant.LastDigestAt, now, loc) >= days
+}
+
+// startDigestScheduler periodically emails progress digests to viewers who
+// opted in. It stops when ctx is cancelled.
+func startDigestScheduler(ctx context.Context) {
+	registerJob("digests", time.Hour)
+	ticker := time.NewTicker(time.Hour)
+	go func() {
+		defer ticker.Stop()
+		for {
+			recordJobRun("digests", sendDueDigests(ctx))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// sendDueDigests sends the digests that are due and returns the last error,
+// if any; one failed digest doesn't stop the others
+func sendDueDigests(ctx context.Context) error {
+	grants, err := findViewerGrants(ctx, bson.M{
+		"status":           GrantActive,
+		"digest_frequency": bson.M{"$in": []string{DigestDaily, DigestWeekly}},
+	})
+	if err != nil {
+		log.Printf("❌ Error loading digest grants: %v", err)
+		return err
+	}
+
+	var lastErr error
+
+	now := time.Now()
+	for _, grant := range grants {
+		if !digestDue(grant, now, userLocation(ctx, grant.ViewerID)) {
+			continue
+		}
+
+		report, err := buildLearnerProgressReport(ctx, grant.LearnerID)
+		if err != nil {
+			log.Printf("❌ Error building digest for learner %s: %v", grant.LearnerID, err)
+			lastErr = err
+			continue
+		}
+
+		// Digests are written in the viewer's language
+		locale := userLocale(ctx, grant.ViewerID)
+		subject := T(locale, "Learning progress for %s", report.Name)
+		body := T(locale, "%s has completed %d of %d chapters.",
+			report.Name, report.CompletedChapters, report.TotalChapters) + "\n"
+		for _, p := range report.Progress {
+			body += T(locale, "- %s: video %ds (completed: %v), quiz completed: %v",
+				p.ChapterID, p.VideoProgress, p.VideoCompleted, p.QuizCompleted) + "\n"
+		}
+
+		if err := sendEmail(grant.DigestEmail, subject, body); err != nil {
+			log.Printf("❌ Error sending digest: %v", err)
+			lastErr = err
+			continue
+		}
+
+		_, err = viewerGrantsCol.UpdateOne(ctx, bson.M{"_id": grant.ID},
+			bson.M{"$set": bson.M{"last_digest_at": now}})
+		if err != nil {
+			log.Printf("❌ Error recording digest for grant %s: %v", grant.ID.Hex(), err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,750 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// WEBHOOK MODELS
+// ============================================================================
+
+// Org admins register webhooks so their LMS or CRM hears about registrations
+// and completions as they happen. Each event becomes one delivery per
+// subscribed webhook, stored first and then POSTed by a background
+// dispatcher, so a slow or failing receiver never holds up a learner's
+// request. Bodies are signed with the webhook's secret; failed deliveries
+// are retried with growing delays and every attempt is logged.
+
+// Kinds of webhook event
+const (
+	WebhookUserRegistered   = "user_registered"
+	WebhookChapterCompleted = "chapter_completed"
+	WebhookCourseCompleted  = "course_completed"
+)
+
+var knownWebhookEvents = map[string]bool{
+	WebhookUserRegistered: true, WebhookChapterCompleted: true, WebhookCourseCompleted: true,
+}
+
+// Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // out of retries
+)
+
+// webhookRetryDelays are the waits after each failed attempt. A delivery
+// that fails once more after the last is given up on.
+var webhookRetryDelays = []time.Duration{
+	time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+}
+
+const (
+	// minWebhookSecretLength keeps signatures from being guessable
+	minWebhookSecretLength = 16
+	// maxWebhooksPerOrg bounds how many webhooks an organization registers
+	maxWebhooksPerOrg = 20
+	// webhookTimeout bounds one delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookLease is how long a claimed delivery is hidden from other
+	// dispatchers, longer than an attempt can take
+	webhookLease = time.Minute
+	// webhookDispatchInterval is how often due deliveries are looked for
+	webhookDispatchInterval = 10 * time.Second
+	// webhookDispatchBatch bounds the deliveries one run sends
+	webhookDispatchBatch = 100
+	// webhookDeliveryRetention is how long the delivery log is kept
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+	// maxWebhookResponseLog bounds how much of a receiver's answer is kept
+	maxWebhookResponseLog = 1024
+)
+
+// Webhook is a URL that an organization's events are POSTed to
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID  string             `bson:"public_id,omitempty" json:"id"`
+	OrgID     string             `bson:"org_id,omitempty" json:"-"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"-"` // signs deliveries; never returned
+	Events    []string           `bson:"events" json:"events"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// UpdateWebhookRequest replaces a webhook's settings; an empty secret keeps
+// the current one
+type UpdateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// WebhookDelivery is one event on its way to one webhook, with the log of
+// its attempts
+type WebhookDelivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PublicID      string             `bson:"public_id,omitempty" json:"id"`
+	OrgID         string             `bson:"org_id,omitempty" json:"-"`
+	WebhookID     string             `bson:"webhook_id" json:"webhookId"`
+	Event         string             `bson:"event" json:"event"`
+	Payload       string             `bson:"payload" json:"payload"` // the exact body signed and sent
+	Status        string             `bson:"status" json:"status"`
+	Attempts      []WebhookAttempt   `bson:"attempts" json:"attempts"`
+	NextAttemptAt *time.Time         `bson:"next_attempt_at,omitempty" json:"nextAttemptAt,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	DeliveredAt   *time.Time         `bson:"delivered_at,omitempty" json:"deliveredAt,omitempty"`
+}
+
+// WebhookAttempt is one try at sending a delivery
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"statusCode,omitempty"` // unset when no answer came
+	Response   string    `bson:"response,omitempty" json:"response,omitempty"`      // start of the answer's body
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs int64     `bson:"duration_ms" json:"durationMs"`
+}
+
+// webhookPayload is the body of a delivery
+type webhookPayload struct {
+	ID         string      `json:"id"` // the delivery's ID, the same on every retry
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// webhookClient sends deliveries. It only dials public addresses, checked
+// on the address actually dialled so a name that later resolves somewhere
+// else can't reach the internal network, and never follows a redirect.
+var webhookClient = newWebhookClient()
+
+func newWebhookClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would dial the receiver itself, past the check
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: webhookTimeout,
+		Control: webhookDialControl,
+	}).DialContext
+	return &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		// The 3xx is kept as the attempt's answer, which fails it
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// errWebhookAddress refuses to dial a non-public address
+var errWebhookAddress = errors.New("webhook address is not public")
+
+// webhookNudge wakes the dispatcher when deliveries are queued
+var webhookNudge = make(chan struct{}, 1)
+
+// ============================================================================
+// WEBHOOK HANDLERS
+// ============================================================================
+
+// GetWebhooks lists the organization's webhooks, oldest first
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cursor, err := webhooksCol.Find(ctx, tenantFilter(ctx, bson.M{}),
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch webhooks")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	webhooks := []Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode webhooks")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Webhooks fetched successfully",
+		Data:    webhooks,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// CreateWebhook registers a webhook for some of the organization's events
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	// Validate input
+	var errs fieldErrors
+	req.URL = strings.TrimSpace(req.URL)
+	validateWebhook(&errs, req.URL, req.Events)
+	errs.required("secret", req.Secret)
+	validateWebhookSecret(&errs, req.Secret)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	count, err := webhooksCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{}))
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count >= maxWebhooksPerOrg {
+		sendError(w, http.StatusConflict, fmt.Sprintf("An organization can have at most %d webhooks", maxWebhooksPerOrg))
+		return
+	}
+
+	now := time.Now()
+	webhook := Webhook{
+		PublicID:  newPublicID(),
+		OrgID:     orgID(ctx),
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    uniqueStrings(req.Events),
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	result, err := webhooksCol.InsertOne(ctx, webhook)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+	webhook.ID = result.InsertedID.(primitive.ObjectID)
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditWebhookCreated,
+		Target:  AuditTarget{Type: "webhook", ID: webhook.PublicID},
+		Changes: auditDiff(nil, webhook),
+	})
+
+	log.Printf("🪝 Webhook registered: org=%s, url=%s, events=%v", webhook.OrgID, webhook.URL, webhook.Events)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Webhook created successfully",
+		Data:    webhook,
+	}
+	sendJSON(w, http.StatusCreated, response)
+}
+
+// UpdateWebhook replaces a webhook's URL, events and active flag, and its
+// secret when a new one is given
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req UpdateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var errs fieldErrors
+	req.URL = strings.TrimSpace(req.URL)
+	validateWebhook(&errs, req.URL, req.Events)
+	validateWebhookSecret(&errs, req.Secret)
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	filter, ok := webhookFilter(ctx, w, vars["webhookId"])
+	if !ok {
+		return
+	}
+	events, now := uniqueStrings(req.Events), time.Now()
+	set := bson.M{
+		"url":        req.URL,
+		"events":     events,
+		"updated_at": now,
+	}
+	if req.Secret != "" {
+		set["secret"] = req.Secret
+	}
+	if req.Active != nil {
+		set["active"] = *req.Active
+	}
+
+	var before Webhook
+	err := webhooksCol.FindOneAndUpdate(ctx, filter, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Webhook not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+
+	webhook := before
+	webhook.URL, webhook.Events, webhook.UpdatedAt = req.URL, events, now
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditWebhookUpdated,
+		Target:  AuditTarget{Type: "webhook", ID: webhook.PublicID},
+		Changes: auditDiff(before, webhook),
+	})
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Webhook updated successfully",
+		Data:    webhook,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	ctx := r.Context()
+
+	filter, ok := webhookFilter(ctx, w, vars["webhookId"])
+	if !ok {
+		return
+	}
+	var webhook Webhook
+	err := webhooksCol.FindOneAndDelete(ctx, filter).Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Webhook not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	if _, err := webhookDeliveriesCol.DeleteMany(ctx, bson.M{"webhook_id": webhook.PublicID}); err != nil {
+		log.Printf("❌ Error deleting deliveries of webhook %s: %v", webhook.PublicID, err)
+	}
+
+	recordAudit(ctx, AuditEntry{
+		Action:  AuditWebhookDeleted,
+		Target:  AuditTarget{Type: "webhook", ID: webhook.PublicID},
+		Changes: auditDiff(webhook, nil),
+	})
+	log.Printf("🪝 Webhook deleted: org=%s, url=%s", webhook.OrgID, webhook.URL)
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Webhook deleted successfully",
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// GetWebhookDeliveries lists a webhook's deliveries with their attempts,
+// newest first, paged with ?limit= and ?cursor=. ?status= limits them to
+// pending, succeeded or failed ones.
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	var errs fieldErrors
+	limit := parsePageLimit(r, &errs)
+	filter := bson.M{}
+	switch status := query.Get("status"); status {
+	case "":
+	case DeliveryPending, DeliverySucceeded, DeliveryFailed:
+		filter["status"] = status
+	default:
+		errs.add("status", CodeUnknownValue, "must be pending, succeeded or failed")
+	}
+	var after bson.M
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := decodeTimeCursor(token)
+		if err != nil {
+			errs.add("cursor", CodeInvalid, "is not a valid cursor")
+		} else {
+			after = cursor.after("created_at")
+		}
+	}
+	if len(errs) > 0 {
+		sendValidationErrors(w, errs)
+		return
+	}
+
+	ctx := r.Context()
+
+	hookFilter, ok := webhookFilter(ctx, w, vars["webhookId"])
+	if !ok {
+		return
+	}
+	var webhook Webhook
+	err := webhooksCol.FindOne(ctx, hookFilter).Decode(&webhook)
+	if err == mongo.ErrNoDocuments {
+		sendError(w, http.StatusNotFound, "Webhook not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	filter["webhook_id"] = webhook.PublicID
+	if after != nil {
+		filter = bson.M{"$and": bson.A{filter, after}}
+	}
+
+	// Fetch one extra to know whether there is a next page
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+	cursor, err := webhookDeliveriesCol.Find(ctx, filter, opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode deliveries")
+		return
+	}
+
+	page := Page{}
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		last := deliveries[limit-1]
+		page.NextCursor = timeCursor{At: last.CreatedAt, ID: last.ID}.encode()
+	}
+	page.Items = deliveries
+
+	response := ApiResponse{
+		Success: true,
+		Message: "Deliveries fetched successfully",
+		Data:    page,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// WEBHOOK HELPERS
+// ============================================================================
+
+// validateWebhook checks the URL and events a webhook is saved with
+func validateWebhook(errs *fieldErrors, rawURL string, events []string) {
+	errs.required("url", rawURL)
+	if rawURL != "" {
+		u, err := url.Parse(rawURL)
+		switch {
+		case err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "":
+			errs.add("url", CodeInvalid, "must be an absolute http or https URL")
+		case u.Scheme != "https" && !config.development():
+			errs.add("url", CodeInvalid, "must be an https URL")
+		case !publicWebhookHost(u.Hostname()):
+			errs.add("url", CodeInvalid, "must not be a loopback, link-local or private address")
+		}
+	}
+	if len(events) == 0 {
+		errs.add("events", CodeRequired, "is required")
+	}
+	for i, event := range events {
+		if !knownWebhookEvents[event] {
+			errs.add(fmt.Sprintf("events[%d]", i), CodeUnknownValue,
+				"must be one of user_registered, chapter_completed or course_completed")
+		}
+	}
+}
+
+// validateWebhookSecret checks a secret when one is given
+func validateWebhookSecret(errs *fieldErrors, secret string) {
+	if secret != "" && len(secret) < minWebhookSecretLength {
+		errs.add("secret", CodeInvalid, fmt.Sprintf("must be at least %d characters", minWebhookSecretLength))
+	}
+}
+
+// webhookFilter matches one of the organization's webhooks by ID, sending
+// a 404 when the ID can't be one
+func webhookFilter(ctx context.Context, w http.ResponseWriter, webhookID string) (bson.M, bool) {
+	filter, ok := idFilter(webhookID)
+	if !ok {
+		sendError(w, http.StatusNotFound, "Webhook not found")
+		return nil, false
+	}
+	return tenantFilter(ctx, filter), true
+}
+
+// fireWebhook queues a delivery of an event to each of the organization's
+// active webhooks subscribed to it. Failures are logged; webhooks never fail
+// the request that caused the event.
+func fireWebhook(ctx context.Context, org, event string, data interface{}) {
+	// Webhooks are registered in MongoDB, so without it there are none
+	if !mongoConnected() {
+		return
+	}
+	cursor, err := webhooksCol.Find(ctx, bson.M{"org_id": org, "active": true, "events": event})
+	if err != nil {
+		log.Printf("❌ Error loading webhooks for %s: %v", event, err)
+		return
+	}
+	var webhooks []Webhook
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		log.Printf("❌ Error loading webhooks for %s: %v", event, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	now := time.Now()
+	deliveries := make([]interface{}, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		id := newPublicID()
+		payload, err := json.Marshal(webhookPayload{ID: id, Event: event, OccurredAt: now, Data: data})
+		if err != nil {
+			log.Printf("❌ Error encoding %s webhook: %v", event, err)
+			return
+		}
+		deliveries = append(deliveries, WebhookDelivery{
+			PublicID:      id,
+			OrgID:         org,
+			WebhookID:     webhook.PublicID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			Attempts:      []WebhookAttempt{},
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		})
+	}
+	if _, err := webhookDeliveriesCol.InsertMany(ctx, deliveries); err != nil {
+		log.Printf("❌ Error queueing %s webhooks: %v", event, err)
+		return
+	}
+
+	select {
+	case webhookNudge <- struct{}{}:
+	default:
+	}
+}
+
+// startWebhookDispatcher sends due deliveries every webhookDispatchInterval,
+// or as soon as new ones are queued. It stops when ctx is cancelled.
+func startWebhookDispatcher(ctx context.Context) {
+	registerJob("webhooks", webhookDispatchInterval)
+	ticker := time.NewTicker(webhookDispatchInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			recordJobRun("webhooks", dispatchWebhooks(ctx))
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-webhookNudge:
+			}
+		}
+	}()
+}
+
+// dispatchWebhooks sends the deliveries that are due, oldest first. Each is
+// claimed for webhookLease first, so several server instances can dispatch
+// without sending one twice.
+func dispatchWebhooks(ctx context.Context) error {
+	for i := 0; i < webhookDispatchBatch; i++ {
+		now := time.Now()
+		lease := now.Add(webhookLease)
+		var delivery WebhookDelivery
+		err := webhookDeliveriesCol.FindOneAndUpdate(ctx,
+			bson.M{"status": DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"next_attempt_at": lease}},
+			options.FindOneAndUpdate().
+				SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+				SetReturnDocument(options.After)).Decode(&delivery)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := deliverWebhook(ctx, delivery); err != nil {
+			log.Printf("❌ Error recording webhook delivery %s: %v", delivery.PublicID, err)
+		}
+	}
+	return nil
+}
+
+// deliverWebhook makes one attempt at a delivery and records it, scheduling
+// the next attempt after a failure
+func deliverWebhook(ctx context.Context, delivery WebhookDelivery) error {
+	var webhook Webhook
+	err := webhooksCol.FindOne(ctx, bson.M{"public_id": delivery.WebhookID}).Decode(&webhook)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	removed := err == mongo.ErrNoDocuments || !webhook.Active
+
+	var attempt WebhookAttempt
+	if removed {
+		attempt = WebhookAttempt{At: time.Now(), Error: "webhook was removed or deactivated"}
+	} else {
+		attempt = sendWebhook(ctx, webhook, delivery)
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	succeeded := attempt.StatusCode >= 200 && attempt.StatusCode < 300
+	retries := len(delivery.Attempts) // before this attempt
+	switch {
+	case succeeded:
+		set["status"] = DeliverySucceeded
+		set["delivered_at"] = attempt.At
+		unset["next_attempt_at"] = ""
+	case removed || retries >= len(webhookRetryDelays):
+		set["status"] = DeliveryFailed
+		unset["next_attempt_at"] = ""
+	default:
+		set["next_attempt_at"] = attempt.At.Add(webhookRetryDelays[retries])
+	}
+
+	update := bson.M{"$set": set, "$push": bson.M{"attempts": attempt}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	if _, err := webhookDeliveriesCol.UpdateOne(ctx, bson.M{"_id": delivery.ID}, update); err != nil {
+		return err
+	}
+
+	if succeeded {
+		log.Printf("🪝 Webhook delivered: event=%s, url=%s", delivery.Event, webhook.URL)
+	} else {
+		log.Printf("❌ Webhook delivery failed: event=%s, url=%s, attempt=%d: %s",
+			delivery.Event, webhook.URL, retries+1, attemptFailure(attempt))
+	}
+	return nil
+}
+
+// sendWebhook POSTs a delivery's payload to the webhook, signed with its
+// secret. The signature is the hex HMAC-SHA256 of "<timestamp>.<body>", so
+// a receiver can also refuse old, replayed deliveries.
+func sendWebhook(ctx context.Context, webhook Webhook, delivery WebhookDelivery) WebhookAttempt {
+	start := time.Now()
+	attempt := WebhookAttempt{At: start}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+
+	// Webhooks saved before https was required are refused here
+	if !strings.HasPrefix(webhook.URL, "https://") && !config.development() {
+		attempt.Error = "webhook URL must be https"
+		return attempt
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ResumeLearning-Webhooks/1.0")
+	req.Header.Set("X-Webhook-ID", delivery.PublicID)
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := webhookClient.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseLog))
+	attempt.Response = strings.ToValidUTF8(string(body), "")
+	return attempt
+}
+
+// publicWebhookHost reports whether a webhook may be saved with host. Names
+// other than localhost pass; where they point is checked when dialling.
+func publicWebhookHost(host string) bool {
+	if config.development() {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicWebhookAddr(addr)
+	}
+	return true
+}
+
+// publicWebhookAddr reports whether deliveries may be sent to addr
+func publicWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsUnspecified() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() && !sharedAddressSpace.Contains(addr)
+}
+
+// sharedAddressSpace is carrier-grade NAT space, private in all but name
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// webhookDialControl refuses connections to non-public addresses, except
+// in development, where receivers usually run on the same machine
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	if config.development() {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil || !publicWebhookAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errWebhookAddress, address)
+	}
+	return nil
+}
+
+// signWebhook is the signature of a delivery sent at timestamp
+func signWebhook(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// attemptFailure describes why an attempt failed, for the log
+func attemptFailure(attempt WebhookAttempt) string {
+	if attempt.Error != "" {
+		return attempt.Error
+	}
+	return fmt.Sprintf("status %d", attempt.StatusCode)
+}
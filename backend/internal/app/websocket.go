@@ -0,0 +1,279 @@
+package app
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// WEBSOCKETS
+// ============================================================================
+
+// wsConn is a minimal server side of RFC 6455: enough to push text
+// messages, keep the connection alive with pings and close it cleanly.
+// Messages from the client are read and discarded.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	writeMu sync.Mutex
+	send    chan []byte
+	done    chan struct{}
+	once    sync.Once
+}
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Frame opcodes
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// Close codes
+const (
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+	wsCloseProtocol  = 1002
+	wsClosePolicy    = 1008
+	wsCloseTooBig    = 1009
+)
+
+const (
+	wsMaxFrameSize = 64 << 10
+	wsSendBuffer   = 16
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 2 * wsPingInterval
+)
+
+var (
+	errWSClosed   = errors.New("websocket closed")
+	errWSTooBig   = errors.New("websocket frame too big")
+	errWSProtocol = errors.New("websocket protocol error")
+)
+
+// isWebSocketUpgrade reports whether r asks to switch to WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the opening handshake and takes over the
+// connection. On failure it has already sent an error response.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !isWebSocketUpgrade(r) || key == "" {
+		sendError(w, http.StatusBadRequest, "Expected a WebSocket upgrade")
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		sendError(w, http.StatusUpgradeRequired, "Unsupported WebSocket version")
+		return nil, errors.New("unsupported websocket version")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "WebSocket not supported")
+		return nil, err
+	}
+	// The server's deadlines carry over to a hijacked connection
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{
+		conn: conn,
+		r:    rw.Reader,
+		send: make(chan []byte, wsSendBuffer),
+		done: make(chan struct{}),
+	}, nil
+}
+
+// Send queues a text message. A client too slow to keep up with its queue
+// is disconnected rather than allowed to hold up the sender.
+func (c *wsConn) Send(msg []byte) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		go c.Close(wsClosePolicy, "too slow")
+	}
+}
+
+// Close sends a close frame and drops the connection; later calls do nothing
+func (c *wsConn) Close(code int, reason string) {
+	c.once.Do(func() {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		c.writeFrame(wsOpClose, append(payload, reason...))
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// Serve writes queued messages and pings until the connection closes,
+// reading (and discarding) whatever the client sends. It blocks until then.
+func (c *wsConn) Serve() {
+	go c.writeLoop()
+	err := c.readLoop()
+	switch {
+	case errors.Is(err, errWSClosed):
+		c.Close(wsCloseNormal, "")
+	case errors.Is(err, errWSTooBig):
+		c.Close(wsCloseTooBig, "")
+	case errors.Is(err, errWSProtocol):
+		c.Close(wsCloseProtocol, "")
+	default:
+		c.Close(wsCloseGoingAway, "")
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.writeFrame(wsOpText, msg); err != nil {
+				c.Close(wsCloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.writeFrame(wsOpPing, nil); err != nil {
+				c.Close(wsCloseGoingAway, "")
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLoop answers pings and close frames; any frame pushes back the
+// deadline, so a client that stops answering pings is dropped
+func (c *wsConn) readLoop() error {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return err
+			}
+		case wsOpClose:
+			return errWSClosed
+		case wsOpPong, wsOpText, wsOpBinary, wsOpContinuation:
+		default:
+			return errWSProtocol
+		}
+	}
+}
+
+// readFrame reads one frame. Clients must mask what they send.
+func (c *wsConn) readFrame() (int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	op := int(head[0] & 0x0F)
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+	if !masked || head[0]&0x70 != 0 {
+		return 0, nil, errWSProtocol
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxFrameSize {
+		return 0, nil, errWSTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+// writeFrame writes one unfragmented, unmasked frame
+func (c *wsConn) writeFrame(op int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := []byte{0x80 | byte(op)}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.conn.Write(append(frame, payload...)); err != nil {
+		return fmt.Errorf("websocket write: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================================================
+// XP MODELS
+// ============================================================================
+
+// Learners earn XP once per chapter for finishing its video, passing its
+// quiz, and getting every question right. Each award is kept in xp_awards,
+// whose unique index makes awarding idempotent: a retake, a resent
+// completion or a replayed sync can't earn the same XP twice. The total is
+// kept on the user with an atomic increment.
+
+// XP award reasons
+const (
+	XPVideoCompleted = "video_completed"
+	XPQuizPassed     = "quiz_passed"
+	XPPerfectScore   = "perfect_score"
+)
+
+// defaultXP is what each reason is worth unless XP_VIDEO_COMPLETED,
+// XP_QUIZ_PASSED or XP_PERFECT_SCORE say otherwise
+var defaultXP = map[string]int{
+	XPVideoCompleted: 10,
+	XPQuizPassed:     20,
+	XPPerfectScore:   30,
+}
+
+// recentXPAwards is how many awards GetUserXP lists
+const recentXPAwards = 20
+
+// XPAwardRecord is one award in a user's XP history
+type XPAwardRecord struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	OrgID     string             `bson:"org_id,omitempty" json:"-"`
+	UserID    string             `bson:"user_id" json:"userId"`
+	ChapterID string             `bson:"chapter_id" json:"chapterId"`
+	Reason    string             `bson:"reason" json:"reason"`
+	XP        int                `bson:"xp" json:"xp"`
+	AwardedAt time.Time          `bson:"awarded_at" json:"awardedAt"`
+}
+
+// XPAward is the XP a progress write earned, for the client to animate
+type XPAward struct {
+	Awarded int       `json:"awarded"` // XP earned by this write
+	Total   int       `json:"total"`   // the user's XP after it
+	Reasons []XPGrant `json:"reasons"`
+}
+
+// XPGrant is one reason an XPAward was given
+type XPGrant struct {
+	Reason string `json:"reason"`
+	XP     int    `json:"xp"`
+}
+
+// XPSummary is a user's XP and their latest awards
+type XPSummary struct {
+	UserID string          `json:"userId"`
+	XP     int             `json:"xp"`
+	Recent []XPAwardRecord `json:"recent"` // newest first
+}
+
+// ============================================================================
+// XP HANDLERS
+// ============================================================================
+
+// GetUserXP returns a user's XP total and latest awards
+func GetUserXP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["userId"]
+
+	ctx := r.Context()
+
+	user, err := userStore.Get(ctx, userID)
+	if err == ErrNotFound {
+		sendErrorCode(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	} else if err != nil {
+		sendError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "awarded_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(recentXPAwards)
+	cursor, err := xpAwardsCol.Find(ctx, tenantFilter(ctx, bson.M{"user_id": userID}), opts)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to fetch XP")
+		return
+	}
+	defer cursor.Close(ctx)
+
+	summary := XPSummary{UserID: userID, XP: user.XP, Recent: []XPAwardRecord{}}
+	if err := cursor.All(ctx, &summary.Recent); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to decode XP")
+		return
+	}
+
+	response := ApiResponse{
+		Success: true,
+		Message: "XP fetched successfully",
+		Data:    summary,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// ============================================================================
+// XP HELPERS
+// ============================================================================
+
+// xpFor is what a reason is worth; 0 turns it off
+func xpFor(reason string) int {
+	switch reason {
+	case XPVideoCompleted:
+		return config.XPVideoCompleted
+	case XPQuizPassed:
+		return config.XPQuizPassed
+	case XPPerfectScore:
+		return config.XPPerfectScore
+	}
+	return 0
+}
+
+// awardXP gives the user the XP for each reason they haven't been awarded
+// for this chapter yet, and returns what it gave, or nil if nothing.
+// Failures are logged; XP never fails a progress write. Without MongoDB,
+// which keeps the awards, there is no XP.
+func awardXP(ctx context.Context, userID, chapterID string, reasons ...string) *XPAward {
+	if !mongoConnected() {
+		return nil
+	}
+	var award *XPAward
+	for _, reason := range reasons {
+		xp := xpFor(reason)
+		if xp == 0 {
+			continue
+		}
+
+		// The award record goes first, so only one write can earn it
+		record := XPAwardRecord{OrgID: orgID(ctx), UserID: userID, ChapterID: chapterID, Reason: reason, XP: xp, AwardedAt: time.Now()}
+		result, err := xpAwardsCol.InsertOne(ctx, record)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		} else if err != nil {
+			log.Printf("❌ Error awarding XP: user=%s, reason=%s: %v", userID, reason, err)
+			continue
+		}
+
+		total, err := userStore.AddXP(ctx, userID, xp)
+		if err != nil {
+			// Not given, so not kept either; a later write can try again
+			log.Printf("❌ Error adding XP: user=%s, reason=%s: %v", userID, reason, err)
+			if _, err := xpAwardsCol.DeleteOne(ctx, bson.M{"_id": result.InsertedID}); err != nil {
+				log.Printf("❌ Error removing XP award: user=%s, reason=%s: %v", userID, reason, err)
+			}
+			continue
+		}
+
+		if award == nil {
+			award = &XPAward{Reasons: []XPGrant{}}
+		}
+		award.Awarded += xp
+		award.Total = total
+		award.Reasons = append(award.Reasons, XPGrant{Reason: reason, XP: xp})
+	}
+	logXPAward(userID, chapterID, award)
+	return award
+}
+
+// logXPAward logs each reason of an award, which may be nil
+func logXPAward(userID, chapterID string, award *XPAward) {
+	if award == nil {
+		return
+	}
+	for _, grant := range award.Reasons {
+		log.Printf("⭐ XP awarded: user=%s, chapter=%s, reason=%s, xp=%d, total=%d", userID, chapterID, grant.Reason, grant.XP, award.Total)
+	}
+}
+
+// grantXP is awardXP for use in withTransaction. A duplicate key error
+// would abort a transaction, so it looks for an earlier award first, and it
+// returns the first error so the whole transaction fails with it. The
+// caller logs the award with logXPAward once the transaction commits.
+func grantXP(ctx context.Context, userID, chapterID string, reasons ...string) (*XPAward, error) {
+	if !mongoConnected() {
+		return nil, nil
+	}
+	inTransaction := mongo.SessionFromContext(ctx) != nil
+	var award *XPAward
+	for _, reason := range reasons {
+		xp := xpFor(reason)
+		if xp == 0 {
+			continue
+		}
+
+		count, err := xpAwardsCol.CountDocuments(ctx, tenantFilter(ctx, bson.M{"user_id": userID, "chapter_id": chapterID, "reason": reason}))
+		if err != nil {
+			return nil, err
+		} else if count > 0 {
+			continue
+		}
+		record := XPAwardRecord{OrgID: orgID(ctx), UserID: userID, ChapterID: chapterID, Reason: reason, XP: xp, AwardedAt: time.Now()}
+		result, err := xpAwardsCol.InsertOne(ctx, record)
+		if mongo.IsDuplicateKeyError(err) && !inTransaction {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		total, err := userStore.AddXP(ctx, userID, xp)
+		if err != nil {
+			// Without a transaction the award is taken back by hand
+			if !inTransaction {
+				if _, err := xpAwardsCol.DeleteOne(ctx, bson.M{"_id": result.InsertedID}); err != nil {
+					log.Printf("❌ Error removing XP award: user=%s, reason=%s: %v", userID, reason, err)
+				}
+			}
+			return nil, err
+		}
+
+		if award == nil {
+			award = &XPAward{Reasons: []XPGrant{}}
+		}
+		award.Awarded += xp
+		award.Total = total
+		award.Reasons = append(award.Reasons, XPGrant{Reason: reason, XP: xp})
+	}
+	return award, nil
+}
+
+// quizXPReasons are the awards a finished quiz earns
+func quizXPReasons(score QuizResult) []string {
+	if !score.Passed {
+		return nil
+	}
+	reasons := []string{XPQuizPassed}
+	if score.Total > 0 && score.Score == score.Total {
+		reasons = append(reasons, XPPerfectScore)
+	}
+	return reasons
+}
+
+// merge adds other's XP to the award, either of which may be nil
+func (a *XPAward) merge(other *XPAward) *XPAward {
+	if a == nil {
+		return other
+	}
+	if other == nil {
+		return a
+	}
+	return &XPAward{
+		Awarded: a.Awarded + other.Awarded,
+		Total:   max(a.Total, other.Total),
+		Reasons: append(append([]XPGrant{}, a.Reasons...), other.Reasons...),
+	}
+}
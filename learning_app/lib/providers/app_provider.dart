@@ -31,7 +31,17 @@ class AppProvider with ChangeNotifier {
     try {
       final savedUser = await _storageService.getUser();
       if (savedUser != null) {
-        _currentUser = savedUser;
+        // Refresh the saved session, or log in again if it has ended
+        final refreshToken = await _storageService.getRefreshToken();
+        try {
+          if (refreshToken == null) throw Exception('No saved session');
+          await _apiService.refreshSession(refreshToken);
+          _currentUser = savedUser;
+        } catch (_) {
+          _currentUser = await _apiService.login(savedUser.userId, savedUser.name,
+              guestSecret: await _storageService.getGuestSecret(savedUser.userId));
+        }
+        await _storageService.saveRefreshToken(_apiService.refreshToken);
         await loadChapters();
         await loadUserProgress();
       }
@@ -55,10 +65,15 @@ class AppProvider with ChangeNotifier {
       }
 
       // Call API
-      final user = await _apiService.login(userId, name.isEmpty ? userId : name);
+      final user = await _apiService.login(userId, name.isEmpty ? userId : name,
+          guestSecret: await _storageService.getGuestSecret(userId));
       
       // Save user locally
       await _storageService.saveUser(user);
+      if (_apiService.guestSecret != null) {
+        await _storageService.saveGuestSecret(user.userId, _apiService.guestSecret!);
+      }
+      await _storageService.saveRefreshToken(_apiService.refreshToken);
       
       // Update state
       _currentUser = user;
@@ -79,7 +94,9 @@ class AppProvider with ChangeNotifier {
 
   /// Logout user
   Future<void> logout() async {
+    await _apiService.revokeCurrentSession();
     await _storageService.clearUser();
+    _apiService.clearAccessToken();
     _currentUser = null;
     _chapters = [];
     _progressMap = {};
@@ -1,4 +1,5 @@
 import 'dart:convert';
+import 'package:flutter/foundation.dart';
 import 'package:http/http.dart' as http;
 import '../models/user.dart';
 import '../models/chapter.dart';
@@ -11,17 +12,52 @@ class ApiService {
   // For Android emulator: http://10.0.2.2:8080
   // For iOS simulator: http://localhost:8080
   static const String baseUrl = 'http://172.31.112.1:8080/api';
+
+  // Organization this build belongs to; white-label builds pass
+  // --dart-define=ORG_ID=<orgId>
+  static const String orgId = String.fromEnvironment('ORG_ID');
   
   // Singleton pattern
   static final ApiService _instance = ApiService._internal();
   factory ApiService() => _instance;
   ApiService._internal();
 
+  // Access token from the last login or refresh, sent with every request
+  String? _accessToken;
+
+  // Refresh token and session from the last login or refresh
+  String? _refreshToken;
+  String? _sessionId;
+
+  String? get refreshToken => _refreshToken;
+
+  // Secret of a guest this login created; later logins as the guest need it
+  String? _guestSecret;
+
+  String? get guestSecret => _guestSecret;
+
+  /// Forget the tokens (logout)
+  void clearAccessToken() {
+    _accessToken = null;
+    _refreshToken = null;
+    _sessionId = null;
+  }
+
+  /// Keep the tokens from a login or refresh response
+  void _saveTokens(Map<String, dynamic> data) {
+    _accessToken = data['accessToken'];
+    _refreshToken = data['refreshToken'];
+    _sessionId = data['sessionId'];
+  }
+
   /// Helper method to get headers
   Map<String, String> _getHeaders() {
     return {
       'Content-Type': 'application/json',
       'Accept': 'application/json',
+      'X-Platform': kIsWeb ? 'web' : defaultTargetPlatform.name.toLowerCase(),
+      if (orgId.isNotEmpty) 'X-Org-ID': orgId,
+      if (_accessToken != null) 'Authorization': 'Bearer $_accessToken',
     };
   }
 
@@ -39,8 +75,9 @@ class ApiService {
   // AUTH ENDPOINTS
   // ============================================================================
 
-  /// Login or register user
-  Future<User> login(String userId, String name) async {
+  /// Login or register user, with the secret the guest was given when it
+  /// was created
+  Future<User> login(String userId, String name, {String? guestSecret}) async {
     try {
       final response = await http.post(
         Uri.parse('$baseUrl/login'),
@@ -48,16 +85,48 @@ class ApiService {
         body: json.encode({
           'userId': userId,
           'name': name,
+          if (guestSecret != null) 'guestSecret': guestSecret,
         }),
       );
 
       final data = _handleResponse(response);
-      return User.fromJson(data['user']);
+      _saveTokens(data['data']);
+      _guestSecret = data['data']['guestSecret'];
+      return User.fromJson(data['data']);
     } catch (e) {
       throw Exception('Login failed: $e');
     }
   }
 
+  /// Trade a refresh token for new tokens, staying logged in
+  Future<void> refreshSession(String refreshToken) async {
+    try {
+      final response = await http.post(
+        Uri.parse('$baseUrl/token/refresh'),
+        headers: _getHeaders(),
+        body: json.encode({'refreshToken': refreshToken}),
+      );
+
+      final data = _handleResponse(response);
+      _saveTokens(data['data']);
+    } catch (e) {
+      throw Exception('Session refresh failed: $e');
+    }
+  }
+
+  /// Sign this device out on the server
+  Future<void> revokeCurrentSession() async {
+    if (_sessionId == null) return;
+    try {
+      await http.delete(
+        Uri.parse('$baseUrl/sessions/$_sessionId'),
+        headers: _getHeaders(),
+      );
+    } catch (e) {
+      print('Error revoking session: $e');
+    }
+  }
+
   // ============================================================================
   // CHAPTER ENDPOINTS
   // ============================================================================
@@ -106,13 +175,7 @@ class ApiService {
       );
 
       final data = _handleResponse(response);
-      final List<dynamic>? progressJson = data['progress'];
-      
-      // Handle null or empty response
-      if (progressJson == null) {
-        return [];
-      }
-      
+      final List<dynamic> progressJson = data['data'];
       return progressJson.map((json) => Progress.fromJson(json)).toList();
     } catch (e) {
       print('Error fetching user progress: $e');
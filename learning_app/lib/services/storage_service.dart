@@ -6,6 +6,8 @@ import '../models/user.dart';
 class StorageService {
   static const String _userKey = 'current_user';
   static const String _userIdKey = 'user_id';
+  static const String _refreshTokenKey = 'refresh_token';
+  static const String _guestSecretKeyPrefix = 'guest_secret_';
 
   // Singleton pattern
   static final StorageService _instance = StorageService._internal();
@@ -58,6 +60,35 @@ class StorageService {
     return _prefs.getString(_userIdKey);
   }
 
+  /// Save the refresh token that keeps the user logged in
+  Future<void> saveRefreshToken(String? token) async {
+    await init();
+    if (token == null) {
+      await _prefs.remove(_refreshTokenKey);
+    } else {
+      await _prefs.setString(_refreshTokenKey, token);
+    }
+  }
+
+  /// Get the saved refresh token
+  Future<String?> getRefreshToken() async {
+    await init();
+    return _prefs.getString(_refreshTokenKey);
+  }
+
+  /// Save the secret a guest was given; kept after logout so the guest
+  /// can log in again
+  Future<void> saveGuestSecret(String userId, String secret) async {
+    await init();
+    await _prefs.setString(_guestSecretKeyPrefix + userId, secret);
+  }
+
+  /// Get the saved secret of a guest
+  Future<String?> getGuestSecret(String userId) async {
+    await init();
+    return _prefs.getString(_guestSecretKeyPrefix + userId);
+  }
+
   /// Check if user is logged in
   Future<bool> isLoggedIn() async {
     await init();
@@ -70,6 +101,7 @@ class StorageService {
     try {
       await _prefs.remove(_userKey);
       await _prefs.remove(_userIdKey);
+      await _prefs.remove(_refreshTokenKey);
       return true;
     } catch (e) {
       print('Error clearing user: $e');